@@ -7,6 +7,11 @@
 // 没有k8s 这个编译标签
 package config
 
+import (
+	"net/http"
+	"time"
+)
+
 var Config = config{
 	DB: DBConfig{
 		// 本地连接
@@ -14,5 +19,87 @@ var Config = config{
 	},
 	Redis: RedisConfig{
 		Addr: "localhost:6379",
+		Breaker: RedisBreakerConfig{
+			ConsecutiveFailures: 5,
+			OpenTimeout:         time.Second * 10,
+			HalfOpenRequests:    1,
+		},
+	},
+	SMS: SMSConfig{
+		PhoneDailyQuota:    10,
+		IPDailyQuota:       50,
+		DryRun:             true,
+		CodeLength:         6,
+		CodeCharset:        "numeric",
+		CodeTTL:            time.Minute * 10,
+		CodeResendInterval: time.Minute,
+		CodeCacheFallback:  true,
+	},
+	Admin: AdminConfig{
+		Uids: []int64{1},
+	},
+	Sensitive: SensitiveConfig{
+		Mask: false,
+	},
+	RememberMe: RememberMeConfig{
+		TTL: time.Hour * 24 * 30,
+	},
+	Region: RegionConfig{
+		AllowOverseas: true,
+	},
+	Validation: ValidationConfig{
+		MinimumAgeYears: 13,
+	},
+	Profile: ProfileConfig{
+		CompletenessWeights: map[string]int{
+			"nickname": 20,
+			"birthday": 15,
+			"brief":    15,
+			"email":    25,
+			"phone":    25,
+		},
+		LocalCacheSize: 10000,
+		LocalCacheTTL:  time.Second * 10,
+	},
+	Session: SessionConfig{
+		Secure:   false,
+		SameSite: http.SameSiteLaxMode,
+	},
+	Email: EmailConfig{
+		RequireVerified:  false,
+		VerifyLinkPrefix: "http://localhost:8080/users/email/verify?token=",
+		ResendInterval:   time.Minute,
+		TokenKey:         "fJ3kQ8wZp2LxV7nR5tYc9mBg4sHd6uAe",
+	},
+	Password: PasswordConfig{
+		Scheme: "argon2id",
+	},
+	JWT: JWTConfig{
+		MinClaimsVersion: 0,
+		ClockSkew:        time.Second * 5,
+	},
+	Internal: InternalConfig{
+		Tokens: []string{"dev-internal-token"},
+	},
+	IDGen: IDGenConfig{
+		Snowflake: false,
+	},
+	AccessLog: AccessLogConfig{
+		Enabled:     true,
+		CaptureBody: true,
+	},
+	Signup: SignupConfig{
+		Mode:               "email",
+		DisposableEmail:    "reject",
+		DomainRate:         20,
+		DomainRateInterval: time.Hour,
+	},
+	// 本地直接连，没有代理
+	Server: ServerConfig{},
+	Identity: IdentityConfig{
+		IDCardHashKey: "Xc7hTjY2s9fPq4WnE8vLm3RbK6dZa1Gu",
+	},
+	FormToken: FormTokenConfig{
+		Key: "k6CswdUm75WKcbM68UQUuxVsHSpTCwgA",
 	},
 }
@@ -0,0 +1,15 @@
+//go:build !k8s
+
+package config
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+)
+
+// 本地用 HTTP 开发，Secure 的 cookie 浏览器不会带上
+func TestDevSessionConfig(t *testing.T) {
+	assert.False(t, Config.Session.Secure)
+	assert.Equal(t, http.SameSiteLaxMode, Config.Session.SameSite)
+}
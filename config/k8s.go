@@ -3,6 +3,12 @@
 // 使用 k8s 这个编译标签
 package config
 
+import (
+	"net/http"
+	"os"
+	"time"
+)
+
 var Config = config{
 	DB: DBConfig{
 		// 本地连接
@@ -10,5 +16,85 @@ var Config = config{
 	},
 	Redis: RedisConfig{
 		Addr: "webook-live-redis:11479",
+		Breaker: RedisBreakerConfig{
+			ConsecutiveFailures: 5,
+			OpenTimeout:         time.Second * 10,
+			HalfOpenRequests:    1,
+		},
+	},
+	SMS: SMSConfig{
+		PhoneDailyQuota:    10,
+		IPDailyQuota:       50,
+		DryRun:             false,
+		CodeLength:         6,
+		CodeCharset:        "numeric",
+		CodeTTL:            time.Minute * 10,
+		CodeResendInterval: time.Minute,
+		CodeCacheFallback:  true,
+	},
+	Admin: AdminConfig{
+		Uids: []int64{},
+	},
+	Sensitive: SensitiveConfig{
+		Mask: false,
+	},
+	RememberMe: RememberMeConfig{
+		TTL: time.Hour * 24 * 30,
+	},
+	Region: RegionConfig{
+		AllowOverseas: true,
+	},
+	Validation: ValidationConfig{
+		MinimumAgeYears: 13,
+	},
+	Profile: ProfileConfig{
+		CompletenessWeights: map[string]int{
+			"nickname": 20,
+			"birthday": 15,
+			"brief":    15,
+			"email":    25,
+			"phone":    25,
+		},
+		LocalCacheSize: 10000,
+		LocalCacheTTL:  time.Second * 10,
+	},
+	Session: SessionConfig{
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	},
+	Email: EmailConfig{
+		RequireVerified:  false,
+		VerifyLinkPrefix: "https://yourcompany.com/users/email/verify?token=",
+		ResendInterval:   time.Minute,
+		// 从 k8s 的 secret 注入，不写在代码里面
+		TokenKey: os.Getenv("WEBOOK_EMAIL_TOKEN_KEY"),
+	},
+	Password: PasswordConfig{
+		Scheme: "bcrypt",
+	},
+	JWT: JWTConfig{
+		MinClaimsVersion: 0,
+		ClockSkew:        time.Second * 5,
+	},
+	// 部署的时候再填，不要提交到代码里面
+	Internal: InternalConfig{},
+	IDGen: IDGenConfig{
+		Snowflake: false,
+	},
+	AccessLog: AccessLogConfig{
+		Enabled:     true,
+		CaptureBody: false,
+	},
+	Server: ServerConfig{
+		// 前面是集群里面的 ingress-nginx
+		TrustedProxies: []string{"10.0.0.0/8"},
+	},
+	Identity: IdentityConfig{
+		// 从 k8s 的 secret 注入
+		IDCardHashKey: os.Getenv("WEBOOK_ID_CARD_HASH_KEY"),
+	},
+	FormToken: FormTokenConfig{
+		// 从 k8s 的 secret 注入
+		Key: os.Getenv("WEBOOK_FORM_TOKEN_KEY"),
 	},
 }
@@ -0,0 +1,14 @@
+//go:build k8s
+
+package config
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+)
+
+func TestK8sSessionConfig(t *testing.T) {
+	assert.True(t, Config.Session.Secure)
+	assert.Equal(t, http.SameSiteLaxMode, Config.Session.SameSite)
+}
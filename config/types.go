@@ -1,13 +1,208 @@
 package config
 
+import (
+	"net/http"
+	"time"
+)
+
 type config struct {
-	DB    DBConfig
-	Redis RedisConfig
+	DB         DBConfig
+	Redis      RedisConfig
+	SMS        SMSConfig
+	Admin      AdminConfig
+	Sensitive  SensitiveConfig
+	RememberMe RememberMeConfig
+	Region     RegionConfig
+	Profile    ProfileConfig
+	Validation ValidationConfig
+	Session    SessionConfig
+	Signup     SignupConfig
+	Email      EmailConfig
+	Password   PasswordConfig
+	JWT        JWTConfig
+	Internal   InternalConfig
+	IDGen      IDGenConfig
+	AccessLog  AccessLogConfig
+	Server     ServerConfig
+	Identity   IdentityConfig
+	FormToken  FormTokenConfig
+}
+
+// FormTokenConfig 发短信之前要拿的一次性表单 token
+type FormTokenConfig struct {
+	// token 是用这个 key 签的 HMAC，不能是空的。换了之后已经发出去还没用的 token 就都失效了
+	Key string
+}
+
+// IdentityConfig 实名认证
+type IdentityConfig struct {
+	// 身份证号码存的是用这个 key 算的 HMAC，不能是空的。换了之后已经认证过的就对不上了
+	IDCardHashKey string
+}
+
+// ServerConfig HTTP 服务本身的配置
+type ServerConfig struct {
+	// TrustedProxies 只有从这些地址（可以是 CIDR）过来的请求才认 X-Forwarded-For，
+	// 空的就是谁都不信，ClientIP 就是对端的地址。
+	// 不配的话任何人都能伪造 X-Forwarded-For，绕过按 IP 的限流和短信配额
+	TrustedProxies []string
 }
 
 type DBConfig struct {
 	DSN string
 }
 type RedisConfig struct {
-	Addr string
+	Addr    string
+	Breaker RedisBreakerConfig
+	Pool    RedisPoolConfig
+}
+
+// RedisPoolConfig 连接池，零值就是用 go-redis 的默认值
+type RedisPoolConfig struct {
+	MaxOpenConns    int
+	MinIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+	PoolTimeout     time.Duration
+}
+
+// RedisBreakerConfig Redis 的熔断器，ConsecutiveFailures 为 0 就是不启用
+type RedisBreakerConfig struct {
+	// 连续失败多少次之后熔断
+	ConsecutiveFailures uint32
+	// 熔断之后多久放几个请求过去试探
+	OpenTimeout time.Duration
+	// 试探的时候最多放多少个请求过去
+	HalfOpenRequests uint32
+}
+
+type SMSConfig struct {
+	// 每个手机号每天最多发几条验证码，0 代表不限制
+	PhoneDailyQuota int
+	// 每个 IP 每天最多发几条验证码，0 代表不限制
+	IPDailyQuota int
+	// 只打日志不真的发短信，staging 环境用
+	DryRun bool
+	// 验证码多少位，0 就是默认的 6 位
+	CodeLength int
+	// 验证码的字符集，numeric 或者 alphanumeric，空的就是 numeric。
+	// alphanumeric 不区分大小写，也不会出现 0 O 1 I L 这种容易看错的字符
+	CodeCharset string
+	// 验证码多久过期，0 就是十分钟
+	CodeTTL time.Duration
+	// 第一次重发要等多久，后面每重发一次多等这么久，要比 CodeTTL 短。0 就是一分钟
+	CodeResendInterval time.Duration
+	// Redis 连不上的时候验证码先放在本地，Redis 恢复了再切回去。
+	// 故障期间发的验证码只能在发它的那个实例上验证
+	CodeCacheFallback bool
+}
+
+type AdminConfig struct {
+	// 管理员的用户 ID，只有这些用户能访问 /admin 下面的接口
+	Uids []int64
+}
+
+type SensitiveConfig struct {
+	// 命中敏感词的时候替换成 * 保存，false 就是直接拒绝
+	Mask bool
+}
+
+type RememberMeConfig struct {
+	// 记住我多久不用就失效，每次用它恢复登录态都会重新算
+	TTL time.Duration
+}
+
+type RegionConfig struct {
+	// 允许海外用户不选省市，直接填一段文字
+	AllowOverseas bool
+}
+
+type ValidationConfig struct {
+	// 只允许这些域名的邮箱注册，比如说 mycompany.com，空的就是不限制。
+	// 不区分大小写，子域名要单独写
+	AllowedEmailDomains []string
+	// 填生日的话要满几周岁，0 就是不限制
+	MinimumAgeYears int
+}
+
+type ProfileConfig struct {
+	// 资料完整度每一项的权重，key 是 nickname、birthday、brief、email、email_verified、phone，
+	// 不配置就用默认的权重
+	CompletenessWeights map[string]int
+	// 公开资料在进程内最多缓存多少个用户，0 就是不开，只用 Redis
+	LocalCacheSize int
+	// 进程内缓存的过期时间，别的实例的删除通知丢了的话最多旧这么久
+	LocalCacheTTL time.Duration
+}
+
+type SessionConfig struct {
+	// cookie 只通过 HTTPS 发送，本地 HTTP 开发的时候是 false
+	Secure bool
+	// 不配置就是 Lax
+	SameSite http.SameSite
+}
+
+// SignupConfig 注册的防刷，默认都不开
+type SignupConfig struct {
+	// 注册要填什么：email 邮箱加密码，phone 手机号加验证码，either 两种都行。空的就是 email
+	Mode string
+	// 一次性邮箱怎么处理：reject 直接拒绝，flag 允许注册但是打个标记，空的就是不检查
+	DisposableEmail string
+	// 除了内置的列表，再定时从这个地址拉一份，一行一个域名。空的就是只用内置的
+	DisposableListURL string
+	// 多久拉一次，不配置就是一天
+	DisposableListRefresh time.Duration
+	// 同一个邮箱域名 DomainRateInterval 内最多注册 DomainRate 个，0 就是不限制。
+	// 公司邮箱这种正常就会集中注册的域名，要把值配大一点
+	DomainRate         int
+	DomainRateInterval time.Duration
+}
+
+// InternalConfig 给内部服务用的接口，比如说 /users/batch
+type InternalConfig struct {
+	// 内部服务带在 X-Internal-Token 里面，可以配多个方便换 token。空的就是不开放
+	Tokens []string
+}
+
+// IDGenConfig 用户 ID 怎么生成，默认还是数据库自增
+type IDGenConfig struct {
+	// 用 snowflake 生成，以后分库分表的时候 ID 不会冲突
+	Snowflake bool
+	// snowflake 的节点编号，0 到 1023，每个实例都要不一样
+	NodeID int64
+}
+
+// AccessLogConfig 访问日志
+type AccessLogConfig struct {
+	Enabled bool
+	// 把请求和响应的 body 也打出来，密码之类的字段会替换掉。线上不要开
+	CaptureBody bool
+	// body 最多打多长，0 就是 1KB
+	MaxBodyLength int
+}
+
+type JWTConfig struct {
+	// 低于这个版本的 JWT 直接当没登录，逼用户重新登录。
+	// UserClaims 加了字段、老的 token 没法迁移的时候调上去，0 就是都接受
+	MinClaimsVersion int
+	// 机器之间的时钟误差，校验 iat、nbf、exp 的时候放宽这么多。0 就是不放宽
+	ClockSkew time.Duration
+}
+
+type PasswordConfig struct {
+	// 新密码用哪种算法，bcrypt 或者 argon2id，空的就是 bcrypt。
+	// 两种都能验证，老算法的密码在登录的时候换成新的
+	Scheme string
+}
+
+type EmailConfig struct {
+	// 邮箱验证过了才能用邮箱密码登录，默认不开。
+	// 打开之前注册的用户也要先验证，登录的时候会提示重发验证邮件
+	RequireVerified bool
+	// 验证邮件里面的链接，后面直接拼上 token
+	VerifyLinkPrefix string
+	// 同一个账号多久可以重发一次验证邮件，0 就是一分钟
+	ResendInterval time.Duration
+	// 验证链接里面 token 的 HMAC 密钥，不能是空的。换了之前发出去的链接就都失效了
+	TokenKey string
 }
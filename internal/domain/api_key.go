@@ -0,0 +1,20 @@
+package domain
+
+import "time"
+
+// APIKey 给机器调用的凭证。原始的 key 只在创建的时候返回一次，
+// 我们只保存它的哈希值
+type APIKey struct {
+	Id      int64
+	UserId  int64
+	KeyHash string
+	Name    string
+	// 零值代表永不过期
+	ExpiresAt  time.Time
+	LastUsedAt time.Time
+	CreatedAt  time.Time
+}
+
+func (k APIKey) Expired(now time.Time) bool {
+	return !k.ExpiresAt.IsZero() && !now.Before(k.ExpiresAt)
+}
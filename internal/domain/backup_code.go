@@ -0,0 +1,14 @@
+package domain
+
+import "time"
+
+// BackupCode 备用码，验证器丢了的时候用来登录。原始的码只在生成的时候返回一次，
+// 我们只保存 bcrypt 之后的哈希，用过一次就作废
+type BackupCode struct {
+	Id       int64
+	UserId   int64
+	CodeHash string
+	// 零值代表还没用过
+	UsedAt    time.Time
+	CreatedAt time.Time
+}
@@ -0,0 +1,42 @@
+package domain
+
+import "time"
+
+// UserBan 一次封禁记录
+type UserBan struct {
+	Id  int64
+	Uid int64
+	// 封禁的管理员
+	Operator int64
+	Reason   string
+	// 零值代表永久封禁
+	Until time.Time
+	Ctime time.Time
+}
+
+// Active 到了 Until 就自动解封，不需要定时任务去改状态
+func (b UserBan) Active(now time.Time) bool {
+	return b.Until.IsZero() || now.Before(b.Until)
+}
+
+// AdminAction 管理后台的操作类型，审计日志里面用
+type AdminAction string
+
+const (
+	AdminActionBanUser   AdminAction = "ban_user"
+	AdminActionUnbanUser AdminAction = "unban_user"
+	AdminActionMergeUser AdminAction = "merge_user"
+	// AdminActionImpersonate 客服代办登录，Detail 里面有 JWT 的 ID 和过期时间
+	AdminActionImpersonate AdminAction = "impersonate_user"
+)
+
+// AdminAuditLog 管理员做了什么，对谁做的
+type AdminAuditLog struct {
+	Operator int64
+	Action   AdminAction
+	// 被操作的用户
+	TargetUid int64
+	// 操作的参数，JSON
+	Detail string
+	Ctime  time.Time
+}
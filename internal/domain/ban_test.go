@@ -0,0 +1,16 @@
+package domain
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestUserBan_Active(t *testing.T) {
+	now := time.Now()
+	assert.True(t, UserBan{}.Active(now))
+	assert.True(t, UserBan{Until: now.Add(time.Minute)}.Active(now))
+	// 到期自动解封
+	assert.False(t, UserBan{Until: now}.Active(now))
+	assert.False(t, UserBan{Until: now.Add(-time.Minute)}.Active(now))
+}
@@ -0,0 +1,28 @@
+package domain
+
+import "time"
+
+// PublicBirthday 别人能看到的生日。用户没有打开 ShowBirthYear 的时候只给月和日，比如 03-15，
+// 这样可以收到生日祝福又不暴露年龄。所有给别人看的地方都要用这个，不要直接用 Birthday
+func (u User) PublicBirthday() string {
+	if u.ShowBirthYear {
+		return u.Birthday
+	}
+	// Birthday 存的是 1992-03-15 这种格式，格式不对的老数据不知道哪一部分是年份，宁可不给
+	if len(u.Birthday) != len("2006-01-02") {
+		return ""
+	}
+	return u.Birthday[len("2006-"):]
+}
+
+// Age 到 now 那天满了几周岁，生日当天就算满了。
+// 2 月 29 日出生的，不是闰年的时候要到 3 月 1 日才算过了生日。
+// birthday 晚于 now 的话是负数，调用方自己判断
+func Age(birthday, now time.Time) int {
+	age := now.Year() - birthday.Year()
+	if now.Month() < birthday.Month() ||
+		(now.Month() == birthday.Month() && now.Day() < birthday.Day()) {
+		age--
+	}
+	return age
+}
@@ -0,0 +1,55 @@
+package domain
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestUser_PublicBirthday(t *testing.T) {
+	testCases := []struct {
+		name string
+		user User
+		want string
+	}{
+		{name: "不显示年份", user: User{Birthday: "1992-03-15"}, want: "03-15"},
+		{name: "显示年份", user: User{Birthday: "1992-03-15", ShowBirthYear: true}, want: "1992-03-15"},
+		{name: "没填生日", user: User{}, want: ""},
+		{name: "格式不对的老数据", user: User{Birthday: "92-3-15"}, want: ""},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, tc.user.PublicBirthday())
+		})
+	}
+}
+
+func TestAge(t *testing.T) {
+	date := func(s string) time.Time {
+		d, err := time.Parse("2006-01-02", s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+	testCases := []struct {
+		name     string
+		birthday string
+		now      string
+		want     int
+	}{
+		{name: "今天刚好满 13 岁", birthday: "2011-05-20", now: "2024-05-20", want: 13},
+		{name: "12 岁 364 天", birthday: "2011-05-21", now: "2024-05-20", want: 12},
+		{name: "今天出生", birthday: "2024-05-20", now: "2024-05-20", want: 0},
+		{name: "明天", birthday: "2024-05-21", now: "2024-05-20", want: -1},
+		{name: "明年", birthday: "2025-01-01", now: "2024-05-20", want: -1},
+		{name: "闰年出生，闰年生日当天", birthday: "2008-02-29", now: "2024-02-29", want: 16},
+		{name: "闰年出生，平年的 2 月 28 日还没过生日", birthday: "2008-02-29", now: "2021-02-28", want: 12},
+		{name: "闰年出生，平年的 3 月 1 日过了生日", birthday: "2008-02-29", now: "2021-03-01", want: 13},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, Age(date(tc.birthday), date(tc.now)))
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package domain
+
+import "time"
+
+// UserDataExport 导出给用户本人的个人数据
+type UserDataExport struct {
+	GeneratedAt time.Time       `json:"generated_at"`
+	Profile     ExportProfile   `json:"profile"`
+	Identities  []BoundIdentity `json:"identities"`
+	// 最近的 100 条，最近的在前面
+	LoginHistory []ExportLoginEvent `json:"login_history"`
+}
+
+type ExportProfile struct {
+	Id       int64  `json:"id"`
+	Nickname string `json:"nickname"`
+	Birthday string `json:"birthday"`
+	// 导出的是用户自己的数据，生日一直是完整的，这里只是记一下公开的设置
+	ShowBirthYear bool      `json:"show_birth_year"`
+	Brief         string    `json:"brief"`
+	Gender        string    `json:"gender"`
+	Region        string    `json:"region"`
+	City          string    `json:"city"`
+	Location      string    `json:"location"`
+	Website       string    `json:"website"`
+	Timezone      string    `json:"timezone"`
+	Locale        string    `json:"locale"`
+	Ctime         time.Time `json:"ctime"`
+}
+
+// BoundIdentity 账号上绑定的登录凭证，比如说邮箱、手机号
+type BoundIdentity struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+type ExportLoginEvent struct {
+	IP        string    `json:"ip"`
+	UserAgent string    `json:"user_agent"`
+	Status    string    `json:"status"`
+	Ctime     time.Time `json:"ctime"`
+}
+
+type ExportTaskStatus string
+
+const (
+	ExportTaskPending ExportTaskStatus = "pending"
+	ExportTaskDone    ExportTaskStatus = "done"
+	ExportTaskFailed  ExportTaskStatus = "failed"
+)
+
+// ExportTask 异步导出任务
+type ExportTask struct {
+	Id     string
+	Uid    int64
+	Status ExportTaskStatus
+	// 生成好的 JSON 文档，gzip 压缩过的
+	Data     []byte
+	FileName string
+}
@@ -0,0 +1,15 @@
+package domain
+
+import "time"
+
+// Impersonation 管理员代办登录成某个用户，客服排查问题用。
+// 只能看，不能改，到期之后也不会续约
+type Impersonation struct {
+	// 放在 JWT 的 jti 里面，跟审计日志对得上
+	Id      string
+	AdminId int64
+	Uid     int64
+	Ctime   time.Time
+	// JWT 的过期时间
+	ExpiresAt time.Time
+}
@@ -0,0 +1,32 @@
+package domain
+
+import "time"
+
+type LoginEventStatus uint8
+
+const (
+	LoginEventStatusUnknown LoginEventStatus = iota
+	LoginEventStatusSuccess
+	LoginEventStatusFailed
+)
+
+func (s LoginEventStatus) String() string {
+	switch s {
+	case LoginEventStatusSuccess:
+		return "success"
+	case LoginEventStatusFailed:
+		return "failed"
+	default:
+		return "unknown"
+	}
+}
+
+// LoginEvent 一次登录，给用户自己看账号在哪里登录过
+type LoginEvent struct {
+	ID        int64
+	UserID    int64
+	IP        string
+	UserAgent string
+	Status    LoginEventStatus
+	CreatedAt time.Time
+}
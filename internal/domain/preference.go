@@ -0,0 +1,42 @@
+package domain
+
+import (
+	"golang.org/x/text/language"
+	"time"
+	// 把时区数据编译进来，不依赖机器上有没有装 tzdata，容器镜像里面经常没有
+	_ "time/tzdata"
+)
+
+// ParseTimezone 校验 IANA 时区名，比如 Asia/Shanghai。
+// Local 取决于服务器的配置，不能用
+func ParseTimezone(name string) (string, bool) {
+	if name == "" || name == "Local" {
+		return "", false
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return "", false
+	}
+	return loc.String(), true
+}
+
+// ParseLocale 校验 BCP 47 的语言标签，返回规范的写法，比如 zh-cn 会变成 zh-CN
+func ParseLocale(s string) (string, bool) {
+	tag, err := language.Parse(s)
+	if err != nil || tag == language.Und {
+		return "", false
+	}
+	return tag.String(), true
+}
+
+// TimeLocation 用户的时区，没有设置就用服务器的时区
+func (u User) TimeLocation() *time.Location {
+	if u.Timezone == "" {
+		return time.Local
+	}
+	loc, err := time.LoadLocation(u.Timezone)
+	if err != nil {
+		return time.Local
+	}
+	return loc
+}
@@ -0,0 +1,139 @@
+package domain
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestParseTimezone(t *testing.T) {
+	testCases := []struct {
+		name  string
+		input string
+
+		want   string
+		wantOk bool
+	}{
+		{name: "上海", input: "Asia/Shanghai", want: "Asia/Shanghai", wantOk: true},
+		{name: "纽约", input: "America/New_York", want: "America/New_York", wantOk: true},
+		{name: "UTC", input: "UTC", want: "UTC", wantOk: true},
+		{name: "Local 不行", input: "Local"},
+		{name: "空的", input: ""},
+		{name: "不存在", input: "Mars/Olympus_Mons"},
+		{name: "大小写不对", input: "asia/shanghai"},
+		{name: "偏移量不行", input: "+08:00"},
+		{name: "路径穿越", input: "../../etc/passwd"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, ok := ParseTimezone(tc.input)
+			assert.Equal(t, tc.wantOk, ok)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestParseLocale(t *testing.T) {
+	testCases := []struct {
+		name  string
+		input string
+
+		want   string
+		wantOk bool
+	}{
+		{name: "规范写法", input: "zh-CN", want: "zh-CN", wantOk: true},
+		{name: "小写", input: "zh-cn", want: "zh-CN", wantOk: true},
+		{name: "下划线", input: "en_US", want: "en-US", wantOk: true},
+		{name: "只有语言", input: "ja", want: "ja", wantOk: true},
+		{name: "空的", input: ""},
+		{name: "乱写", input: "!!"},
+		{name: "und", input: "und"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, ok := ParseLocale(tc.input)
+			assert.Equal(t, tc.wantOk, ok)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+// 夏令时切换前后，同一个时区的偏移量不一样，日期也可能不一样
+func TestUser_TimeLocation_DST(t *testing.T) {
+	testCases := []struct {
+		name     string
+		timezone string
+		at       time.Time
+		layout   string
+
+		want string
+	}{
+		{
+			name:     "纽约开始夏令时之前",
+			timezone: "America/New_York",
+			at:       time.Date(2024, 3, 10, 6, 59, 0, 0, time.UTC),
+			layout:   time.RFC3339,
+			want:     "2024-03-10T01:59:00-05:00",
+		},
+		{
+			name:     "纽约开始夏令时之后，跳过了两点",
+			timezone: "America/New_York",
+			at:       time.Date(2024, 3, 10, 7, 0, 0, 0, time.UTC),
+			layout:   time.RFC3339,
+			want:     "2024-03-10T03:00:00-04:00",
+		},
+		{
+			name:     "纽约结束夏令时之前，第一个一点半",
+			timezone: "America/New_York",
+			at:       time.Date(2024, 11, 3, 5, 30, 0, 0, time.UTC),
+			layout:   time.RFC3339,
+			want:     "2024-11-03T01:30:00-04:00",
+		},
+		{
+			name:     "纽约结束夏令时之后，第二个一点半",
+			timezone: "America/New_York",
+			at:       time.Date(2024, 11, 3, 6, 30, 0, 0, time.UTC),
+			layout:   time.RFC3339,
+			want:     "2024-11-03T01:30:00-05:00",
+		},
+		{
+			name:     "伦敦开始夏令时",
+			timezone: "Europe/London",
+			at:       time.Date(2024, 3, 31, 1, 0, 0, 0, time.UTC),
+			layout:   time.RFC3339,
+			want:     "2024-03-31T02:00:00+01:00",
+		},
+		{
+			name:     "夏令时让日期变成前一天",
+			timezone: "America/New_York",
+			at:       time.Date(2024, 3, 10, 4, 30, 0, 0, time.UTC),
+			layout:   time.DateOnly,
+			want:     "2024-03-09",
+		},
+		{
+			name:     "同一个 UTC 时刻，夏令时期间已经是当天",
+			timezone: "America/New_York",
+			at:       time.Date(2024, 7, 10, 4, 30, 0, 0, time.UTC),
+			layout:   time.DateOnly,
+			want:     "2024-07-10",
+		},
+		{
+			name:     "上海没有夏令时",
+			timezone: "Asia/Shanghai",
+			at:       time.Date(2024, 3, 10, 7, 0, 0, 0, time.UTC),
+			layout:   time.RFC3339,
+			want:     "2024-03-10T15:00:00+08:00",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			u := User{Timezone: tc.timezone}
+			assert.Equal(t, tc.want, tc.at.In(u.TimeLocation()).Format(tc.layout))
+		})
+	}
+}
+
+func TestUser_TimeLocation_Fallback(t *testing.T) {
+	assert.Equal(t, time.Local, User{}.TimeLocation())
+	assert.Equal(t, time.Local, User{Timezone: "Mars/Olympus_Mons"}.TimeLocation())
+}
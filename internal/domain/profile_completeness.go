@@ -0,0 +1,59 @@
+package domain
+
+// ProfileItem 资料完整度统计的一项
+type ProfileItem string
+
+const (
+	// ProfileItemNickname 注册的时候昵称是空的，改过了才算
+	ProfileItemNickname ProfileItem = "nickname"
+	ProfileItemBirthday ProfileItem = "birthday"
+	ProfileItemBrief    ProfileItem = "brief"
+	// ProfileItemEmail 绑定了邮箱，用手机号注册的用户没有邮箱
+	ProfileItemEmail ProfileItem = "email"
+	// ProfileItemEmailVerified 邮箱点过验证链接了，比 ProfileItemEmail 要求高
+	ProfileItemEmailVerified ProfileItem = "email_verified"
+	// ProfileItemPhone 绑定了手机号，用邮箱注册的用户没有手机号
+	ProfileItemPhone ProfileItem = "phone"
+)
+
+// ProfileItems 所有可以统计的项，配置权重的时候只认这些
+var ProfileItems = []ProfileItem{
+	ProfileItemNickname,
+	ProfileItemBirthday,
+	ProfileItemBrief,
+	ProfileItemEmail,
+	ProfileItemEmailVerified,
+	ProfileItemPhone,
+}
+
+// ProfileCompleteness 资料完整度，Percent 是 0 到 100
+type ProfileCompleteness struct {
+	Percent int
+	// 按权重从大到小排，前端可以直接拿来当引导的顺序
+	Missing []MissingProfileItem
+}
+
+type MissingProfileItem struct {
+	Item   ProfileItem
+	Weight int
+}
+
+// HasProfileItem 不认识的项当成没有填
+func (u User) HasProfileItem(item ProfileItem) bool {
+	switch item {
+	case ProfileItemNickname:
+		return u.Nickname != ""
+	case ProfileItemBirthday:
+		return u.Birthday != ""
+	case ProfileItemBrief:
+		return u.Brief != ""
+	case ProfileItemEmail:
+		return u.Email != ""
+	case ProfileItemEmailVerified:
+		return u.Email != "" && !u.EmailVerifiedAt.IsZero()
+	case ProfileItemPhone:
+		return u.Phone != ""
+	default:
+		return false
+	}
+}
@@ -0,0 +1,20 @@
+package domain
+
+import "time"
+
+// RememberMeToken "记住我"的持久登录凭证。cookie 里面放的是 series 和原始的 token，
+// 我们只保存 token 的哈希值。每次用它换登录态，token 都会换一个新的，series 不变
+type RememberMeToken struct {
+	Series    string
+	TokenHash string
+	// 上一个 token 的哈希，并发刷新的时候用来区分是不是被偷了
+	PrevTokenHash string
+	Uid           int64
+	ExpiresAt     time.Time
+	// 上一次轮换 token 的时间
+	RotatedAt time.Time
+}
+
+func (t RememberMeToken) Expired(now time.Time) bool {
+	return !now.Before(t.ExpiresAt)
+}
@@ -1,7 +1,9 @@
 package domain
 
 import (
+	"fmt"
 	"time"
+	"webook/pkg/mask"
 )
 
 // User 领域对象，是 DDD 中的 entity
@@ -10,10 +12,120 @@ type User struct {
 	Id       int64
 	Email    string
 	Password string
+	Phone    string
 	Nickname string
 	Birthday string
-	Brief    string
-	Ctime    time.Time
+	// 公开的资料里面生日带不带年份，默认不带。给别人看的时候用 PublicBirthday
+	ShowBirthYear bool
+	Brief         string
+	Gender        Gender
+	// 省和地级市的行政区划代码，名字展示的时候再查字典
+	Region string
+	City   string
+	// 海外用户自己填的地区，跟 Region、City 二选一
+	Location string
+	// 个人主页，http 或者 https 的链接，可以不填
+	Website string
+	// IANA 时区名，比如 Asia/Shanghai，空的就是没有设置
+	Timezone string
+	// BCP 47 的语言标签，比如 zh-CN，空的就是没有设置
+	Locale string
+	Status UserStatus
+	// 上一次修改昵称的时间，没改过就是零值
+	NicknameChangedAt time.Time
+	// 身份证号码的哈希值，不存原始的号码
+	IDCardHash string
+	// 实名认证的时间，nil 就是没有认证过
+	VerifiedAt *time.Time
+	// 点了验证邮件里面的链接的时间，零值就是邮箱还没有验证
+	EmailVerifiedAt time.Time
+	// 注销时间，没有注销就是零值
+	DeletedAt time.Time
+	// 最近一次封禁，nil 就是没有被封禁过或者已经解封了。过期了的还在，要用 Active 判断
+	Ban *UserBan
+	// 注册的时候用的是一次性邮箱
+	DisposableEmail bool
+	Ctime           time.Time
+	// 最后一次修改的时间，资料的 ETag 就是用这个算的
+	Utime time.Time
+}
+
+// String 打日志用的，邮箱和手机号打码，密码不输出。
+// %v、%+v、%s 都会走这里，直接把 User 丢给日志也不会泄露联系方式
+func (u User) String() string {
+	return fmt.Sprintf("User{Id: %d, Email: %s, Phone: %s, Nickname: %s, Status: %s}",
+		u.Id, mask.Email(u.Email), mask.Phone(u.Phone), u.Nickname, u.Status)
+}
+
+type UserStatus uint8
+
+const (
+	// UserStatusActive 正常状态
+	UserStatusActive UserStatus = iota
+	// UserStatusDeleted 已经注销，但是还在冷静期，没有真的删除
+	UserStatusDeleted
+	// UserStatusMerged 被管理员合并到别的账号上了
+	UserStatusMerged
+	// UserStatusDeactivated 用户自己停用了，下次登录的时候确认一下就恢复
+	UserStatusDeactivated
+)
+
+var userStatusNames = map[UserStatus]string{
+	UserStatusActive:      "active",
+	UserStatusDeleted:     "deleted",
+	UserStatusMerged:      "merged",
+	UserStatusDeactivated: "deactivated",
+}
+
+func (s UserStatus) String() string {
+	if name, ok := userStatusNames[s]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// ParseUserStatus 把 active、deleted、merged、deactivated 转成 UserStatus，别的值都返回 false
+func ParseUserStatus(s string) (UserStatus, bool) {
+	for status, name := range userStatusNames {
+		if name == s {
+			return status, true
+		}
+	}
+	return UserStatusActive, false
+}
+
+// Gender 性别，用户可以不填，不填就是 GenderUnknown
+type Gender uint8
+
+const (
+	GenderUnknown Gender = iota
+	GenderMale
+	GenderFemale
+	GenderOther
+)
+
+var genderNames = map[Gender]string{
+	GenderUnknown: "unknown",
+	GenderMale:    "male",
+	GenderFemale:  "female",
+	GenderOther:   "other",
+}
+
+func (g Gender) String() string {
+	if name, ok := genderNames[g]; ok {
+		return name
+	}
+	return genderNames[GenderUnknown]
+}
+
+// ParseGender 把 unknown、male、female、other 转成 Gender，别的值都返回 false
+func ParseGender(s string) (Gender, bool) {
+	for g, name := range genderNames {
+		if name == s {
+			return g, true
+		}
+	}
+	return GenderUnknown, false
 }
 
 //type Address struct {
@@ -0,0 +1,37 @@
+package domain
+
+import (
+	"time"
+	"webook/pkg/pagination"
+)
+
+// UserSortField 后台用户列表的排序字段
+type UserSortField string
+
+const (
+	UserSortById    UserSortField = "id"
+	UserSortByCtime UserSortField = "ctime"
+)
+
+// UserListQuery 后台查用户列表的条件，零值的条件都是不限制
+type UserListQuery struct {
+	// 邮箱包含这一段
+	Email string
+	// 手机号完全一样
+	Phone  string
+	Status *UserStatus
+	// 注册时间 [CtimeStart, CtimeEnd)
+	CtimeStart time.Time
+	CtimeEnd   time.Time
+
+	SortBy UserSortField
+	Desc   bool
+
+	// After 是上一页的最后一条，有的话就从它后面开始查，不用 Offset
+	After  *UserCursor
+	Offset int
+	Limit  int
+}
+
+// UserCursor 翻页的位置，跟别的列表用的是同一种游标
+type UserCursor = pagination.Cursor
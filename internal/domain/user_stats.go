@@ -0,0 +1,22 @@
+package domain
+
+import "time"
+
+// UserStatsSuspended 统计里面才有的 key，不是真的 UserStatus：状态是正常的，但是正在封禁中
+const UserStatsSuspended = "suspended"
+
+// UserStats 后台首页的用户统计
+type UserStats struct {
+	// 正常的，不包括封禁中的
+	Active    int64
+	Suspended int64
+	// 注销了还在冷静期的
+	Deleted int64
+	Merged  int64
+	// 用户自己停用的
+	Deactivated int64
+	// 服务器时区的今天零点到现在
+	RegistrationsToday int64
+	// 什么时候统计的，有缓存，可能是一分钟之前的
+	At time.Time
+}
@@ -0,0 +1,72 @@
+package domain
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParseGender(t *testing.T) {
+	testCases := []struct {
+		name  string
+		input string
+
+		wantGender Gender
+		wantOk     bool
+	}{
+		{name: "未知", input: "unknown", wantGender: GenderUnknown, wantOk: true},
+		{name: "男", input: "male", wantGender: GenderMale, wantOk: true},
+		{name: "女", input: "female", wantGender: GenderFemale, wantOk: true},
+		{name: "其它", input: "other", wantGender: GenderOther, wantOk: true},
+		{name: "大小写不对", input: "Male", wantGender: GenderUnknown},
+		{name: "数字", input: "1", wantGender: GenderUnknown},
+		{name: "乱写", input: "abc", wantGender: GenderUnknown},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g, ok := ParseGender(tc.input)
+			assert.Equal(t, tc.wantOk, ok)
+			assert.Equal(t, tc.wantGender, g)
+			if ok {
+				assert.Equal(t, tc.input, g.String())
+			}
+		})
+	}
+}
+
+func TestGender_String(t *testing.T) {
+	// 数据库里面的脏数据当成未知
+	assert.Equal(t, "unknown", Gender(100).String())
+}
+
+func TestUser_String(t *testing.T) {
+	testCases := []struct {
+		name string
+		u    User
+
+		want string
+	}{
+		{
+			name: "邮箱和手机号打码",
+			u: User{Id: 3, Email: "zhangsan@qq.com", Phone: "15212345678",
+				Password: "$2a$10$hash", Nickname: "Tom"},
+			want: "User{Id: 3, Email: z***n@qq.com, Phone: 152****5678, Nickname: Tom, Status: active}",
+		},
+		{
+			name: "用户名很短",
+			u:    User{Id: 3, Email: "a@qq.com", Status: UserStatusDeleted},
+			want: "User{Id: 3, Email: a***@qq.com, Phone: , Nickname: , Status: deleted}",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, tc.u.String())
+			// 格式化的时候也不能露出来
+			for _, s := range []string{fmt.Sprintf("%v", tc.u), fmt.Sprintf("%+v", &tc.u)} {
+				assert.Equal(t, tc.want, s)
+				assert.NotContains(t, s, "hash")
+			}
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package domain
+
+import (
+	"net/url"
+	"strings"
+)
+
+// WebsiteMaxLength 按字节算，数据库里面是 varchar(255)
+const WebsiteMaxLength = 255
+
+// ParseWebsite 校验个人主页的链接，只能是带域名的 http 或者 https 链接，返回规范的写法。
+// 带用户名密码的不行，https://good.com@evil.com 这种看起来像是 good.com，实际上去的是 evil.com
+func ParseWebsite(s string) (string, bool) {
+	if s == "" || len(s) > WebsiteMaxLength {
+		return "", false
+	}
+	// 空格和控制字符都不行
+	if strings.IndexFunc(s, func(r rune) bool {
+		return r <= ' ' || r == 0x7f
+	}) >= 0 {
+		return "", false
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return "", false
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", false
+	}
+	if u.Host == "" || u.Hostname() == "" || u.User != nil {
+		return "", false
+	}
+	u.Host = strings.ToLower(u.Host)
+	res := u.String()
+	if len(res) > WebsiteMaxLength {
+		return "", false
+	}
+	return res, true
+}
@@ -0,0 +1,38 @@
+package domain
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestParseWebsite(t *testing.T) {
+	testCases := []struct {
+		name  string
+		input string
+
+		want   string
+		wantOk bool
+	}{
+		{name: "https", input: "https://example.com/me", want: "https://example.com/me", wantOk: true},
+		{name: "http 带端口", input: "http://example.com:8080", want: "http://example.com:8080", wantOk: true},
+		{name: "大写的协议和域名", input: "HTTPS://Example.COM/Me", want: "https://example.com/Me", wantOk: true},
+		{name: "空的", input: ""},
+		{name: "没有协议", input: "example.com"},
+		{name: "javascript", input: "javascript:alert(1)"},
+		{name: "ftp", input: "ftp://example.com"},
+		{name: "没有域名", input: "https:///path"},
+		{name: "只有端口", input: "https://:443"},
+		{name: "带用户名", input: "https://good.com@evil.com"},
+		{name: "有空格", input: "https://example.com/a b"},
+		{name: "有换行", input: "https://example.com/\n"},
+		{name: "太长", input: "https://example.com/" + strings.Repeat("a", 240)},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, ok := ParseWebsite(tc.input)
+			assert.Equal(t, tc.wantOk, ok)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package grpc_test
+
+import (
+	"context"
+	"fmt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"time"
+	userv1 "webook/api/proto/gen/user/v1"
+)
+
+// 其它服务调用 UserService 的例子。连接要复用，不要每次调用都 Dial
+func ExampleUserServiceServer_client() {
+	cc, err := grpc.Dial("localhost:8090",
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		panic(err)
+	}
+	defer cc.Close()
+	client := userv1.NewUserServiceClient(cc)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	resp, err := client.FindByEmail(ctx, &userv1.FindByEmailRequest{Email: "123@qq.com"})
+	switch status.Code(err) {
+	case codes.OK:
+		fmt.Println(resp.GetUser().GetId(), resp.GetUser().GetNickname())
+	case codes.NotFound:
+		fmt.Println("用户不存在")
+	default:
+		fmt.Println("系统错误", err)
+	}
+}
@@ -0,0 +1,92 @@
+// Package grpc 把 service 包装成 gRPC 服务，给内部的其它服务调用。
+// 跟 web 包一样只是一层适配，业务逻辑都在 service 里面
+package grpc
+
+import (
+	"context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	userv1 "webook/api/proto/gen/user/v1"
+	"webook/internal/domain"
+	"webook/internal/service"
+)
+
+var _ userv1.UserServiceServer = (*UserServiceServer)(nil)
+
+type UserServiceServer struct {
+	userv1.UnimplementedUserServiceServer
+	svc *service.UserService
+}
+
+func NewUserServiceServer(svc *service.UserService) *UserServiceServer {
+	return &UserServiceServer{
+		svc: svc,
+	}
+}
+
+func (s *UserServiceServer) GetProfile(ctx context.Context,
+	req *userv1.GetProfileRequest) (*userv1.GetProfileResponse, error) {
+	if req.GetId() <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "id 不对")
+	}
+	u, err := s.svc.GetProfile(ctx, req.GetId())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	// GetProfile 查出来的没有 id
+	u.Id = req.GetId()
+	return &userv1.GetProfileResponse{User: toDTO(u)}, nil
+}
+
+func (s *UserServiceServer) FindByEmail(ctx context.Context,
+	req *userv1.FindByEmailRequest) (*userv1.FindByEmailResponse, error) {
+	if req.GetEmail() == "" {
+		return nil, status.Error(codes.InvalidArgument, "邮箱不能为空")
+	}
+	u, err := s.svc.FindByEmail(ctx, req.GetEmail())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &userv1.FindByEmailResponse{User: toDTO(u)}, nil
+}
+
+// FindOrCreateByPhone 调用方自己保证手机号已经验证过了，这里不校验验证码
+func (s *UserServiceServer) FindOrCreateByPhone(ctx context.Context,
+	req *userv1.FindOrCreateByPhoneRequest) (*userv1.FindOrCreateByPhoneResponse, error) {
+	if req.GetPhone() == "" {
+		return nil, status.Error(codes.InvalidArgument, "手机号不能为空")
+	}
+	u, err := s.svc.FindOrCreate(ctx, req.GetPhone())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &userv1.FindOrCreateByPhoneResponse{User: toDTO(u)}, nil
+}
+
+// toStatus 业务错误转成对应的状态码，别的都是 INTERNAL，不把内部的错误信息带出去
+func toStatus(err error) error {
+	switch err {
+	case service.ErrUserNotFound:
+		return status.Error(codes.NotFound, "用户不存在")
+	default:
+		return status.Error(codes.Internal, "系统错误")
+	}
+}
+
+func toDTO(u domain.User) *userv1.User {
+	return &userv1.User{
+		Id:       u.Id,
+		Email:    u.Email,
+		Phone:    u.Phone,
+		Nickname: u.Nickname,
+		Birthday: u.Birthday,
+		Brief:    u.Brief,
+		Gender:   u.Gender.String(),
+		Region:   u.Region,
+		City:     u.City,
+		Location: u.Location,
+		Timezone: u.Timezone,
+		Locale:   u.Locale,
+		Verified: u.VerifiedAt != nil,
+	}
+}
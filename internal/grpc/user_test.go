@@ -0,0 +1,53 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"net"
+	"testing"
+	userv1 "webook/api/proto/gen/user/v1"
+	"webook/internal/service"
+)
+
+func TestToStatus(t *testing.T) {
+	assert.Equal(t, codes.NotFound, status.Code(toStatus(service.ErrUserNotFound)))
+	err := toStatus(errors.New("dial tcp 10.0.0.1:3306: connection refused"))
+	assert.Equal(t, codes.Internal, status.Code(err))
+	// 内部的错误信息不能带出去
+	assert.Equal(t, "系统错误", status.Convert(err).Message())
+}
+
+// 参数不对的时候还没有调用 service，走一遍真的 gRPC 看看状态码有没有传到客户端
+func TestUserServiceServer_InvalidArgument(t *testing.T) {
+	lis := bufconn.Listen(1024 * 1024)
+	server := grpc.NewServer()
+	userv1.RegisterUserServiceServer(server, NewUserServiceServer(nil))
+	go func() {
+		_ = server.Serve(lis)
+	}()
+	defer server.Stop()
+
+	cc, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, s string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer cc.Close()
+	client := userv1.NewUserServiceClient(cc)
+
+	ctx := context.Background()
+	_, err = client.GetProfile(ctx, &userv1.GetProfileRequest{})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = client.FindByEmail(ctx, &userv1.FindByEmailRequest{})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = client.FindOrCreateByPhone(ctx, &userv1.FindOrCreateByPhoneRequest{})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
@@ -4,10 +4,12 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 	"webook/internal/web"
@@ -46,7 +48,8 @@ func TestUserHandler_e2e_SendLoginSMSCode(t *testing.T) {
 			},
 			reqBody: `
 {
-	"phone": "15212345678"
+	"phone": "15212345678",
+	"formToken": "%s"
 }
 `,
 			wantCode: 200,
@@ -77,7 +80,8 @@ func TestUserHandler_e2e_SendLoginSMSCode(t *testing.T) {
 			},
 			reqBody: `
 {
-	"phone": "15212345678"
+	"phone": "15212345678",
+	"formToken": "%s"
 }
 `,
 			wantCode: 200,
@@ -107,7 +111,8 @@ func TestUserHandler_e2e_SendLoginSMSCode(t *testing.T) {
 			},
 			reqBody: `
 {
-	"phone": "15212345678"
+	"phone": "15212345678",
+	"formToken": "%s"
 }
 `,
 			wantCode: 200,
@@ -125,7 +130,8 @@ func TestUserHandler_e2e_SendLoginSMSCode(t *testing.T) {
 			},
 			reqBody: `
 {
-	"phone": ""
+	"phone": "",
+	"formToken": "%s"
 }
 `,
 			wantCode: 200,
@@ -155,8 +161,12 @@ func TestUserHandler_e2e_SendLoginSMSCode(t *testing.T) {
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			tc.before(t)
+			reqBody := tc.reqBody
+			if strings.Contains(reqBody, "%s") {
+				reqBody = fmt.Sprintf(reqBody, prepareFormToken(t, server))
+			}
 			req, err := http.NewRequest(http.MethodPost,
-				"/users/login_sms/code/send", bytes.NewBuffer([]byte(tc.reqBody)))
+				"/users/login_sms/code/send", bytes.NewBuffer([]byte(reqBody)))
 			require.NoError(t, err)
 			// 数据是 JSON 格式
 			req.Header.Set("Content-Type", "application/json")
@@ -180,3 +190,64 @@ func TestUserHandler_e2e_SendLoginSMSCode(t *testing.T) {
 		})
 	}
 }
+
+func TestUserHandler_e2e_SignUp_DuplicatePhone(t *testing.T) {
+	server := InitWebServer()
+	db := ioc.InitDB()
+	const phone = "15212345679"
+	cleanup := func() {
+		err := db.Exec("DELETE FROM users WHERE phone = ? OR email IN ?",
+			phone, []string{"dup_phone1@qq.com", "dup_phone2@qq.com"}).Error
+		require.NoError(t, err)
+	}
+	cleanup()
+	defer cleanup()
+
+	signUp := func(email string) *httptest.ResponseRecorder {
+		reqBody := fmt.Sprintf(`
+{
+	"email": "%s",
+	"password": "hello#world123",
+	"confirmPassword": "hello#world123",
+	"phone": "%s"
+}
+`, email, phone)
+		req, err := http.NewRequest(http.MethodPost,
+			"/users/signup", bytes.NewBuffer([]byte(reqBody)))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		resp := httptest.NewRecorder()
+		server.ServeHTTP(resp, req)
+		return resp
+	}
+
+	resp := signUp("dup_phone1@qq.com")
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, "注册成功", resp.Body.String())
+
+	// 换一个邮箱，手机号还是同一个
+	resp = signUp("dup_phone2@qq.com")
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, "该手机号已注册", resp.Body.String())
+
+	var cnt int64
+	err := db.Table("users").Where("phone = ?", phone).Count(&cnt).Error
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), cnt)
+}
+
+// prepareFormToken 模拟页面加载的时候拿表单 token，然后等一下再提交
+func prepareFormToken(t *testing.T, server http.Handler) string {
+	req, err := http.NewRequest(http.MethodGet, "/users/sms/prepare", nil)
+	require.NoError(t, err)
+	resp := httptest.NewRecorder()
+	server.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusOK, resp.Code)
+	var res web.Result
+	err = json.NewDecoder(resp.Body).Decode(&res)
+	require.NoError(t, err)
+	token, ok := res.Data.(string)
+	require.True(t, ok)
+	time.Sleep(time.Second)
+	return token
+}
@@ -0,0 +1,52 @@
+package job
+
+import (
+	"context"
+	"log"
+	"time"
+	"webook/internal/service"
+)
+
+// PurgeDeletedUserJob 定时把过了冷静期的注销账号彻底删掉。
+// 冷静期内用户重新登录恢复了账号，这里就不会再删除
+type PurgeDeletedUserJob struct {
+	svc       *service.UserService
+	interval  time.Duration
+	batchSize int
+}
+
+func NewPurgeDeletedUserJob(svc *service.UserService) *PurgeDeletedUserJob {
+	return &PurgeDeletedUserJob{
+		svc:       svc,
+		interval:  time.Hour,
+		batchSize: 100,
+	}
+}
+
+// Start 会一直阻塞，直到 ctx 被取消
+func (j *PurgeDeletedUserJob) Start(ctx context.Context) {
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			j.run(ctx)
+		}
+	}
+}
+
+func (j *PurgeDeletedUserJob) run(ctx context.Context) {
+	for {
+		cnt, err := j.svc.PurgeDeletedUsers(ctx, j.batchSize)
+		if err != nil {
+			log.Println("清理注销账号失败", err)
+			return
+		}
+		// 不满一批，说明已经删完了
+		if cnt < j.batchSize {
+			return
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"context"
+	"time"
+	"webook/internal/domain"
+	"webook/internal/repository/dao"
+)
+
+var ErrAPIKeyNotFound = dao.ErrAPIKeyNotFound
+
+type APIKeyRepository interface {
+	Create(ctx context.Context, k domain.APIKey) (int64, error)
+	FindByHash(ctx context.Context, hash string) (domain.APIKey, error)
+	FindByUserId(ctx context.Context, uid int64) ([]domain.APIKey, error)
+	Delete(ctx context.Context, uid int64, id int64) error
+	UpdateLastUsed(ctx context.Context, id int64, t time.Time) error
+}
+
+type apiKeyRepository struct {
+	dao dao.APIKeyDAO
+}
+
+func NewAPIKeyRepository(dao dao.APIKeyDAO) APIKeyRepository {
+	return &apiKeyRepository{
+		dao: dao,
+	}
+}
+
+func (repo *apiKeyRepository) Create(ctx context.Context, k domain.APIKey) (int64, error) {
+	return repo.dao.Insert(ctx, repo.domainToEntity(k))
+}
+
+func (repo *apiKeyRepository) FindByHash(ctx context.Context, hash string) (domain.APIKey, error) {
+	k, err := repo.dao.FindByHash(ctx, hash)
+	if err != nil {
+		return domain.APIKey{}, err
+	}
+	return repo.entityToDomain(k), nil
+}
+
+func (repo *apiKeyRepository) FindByUserId(ctx context.Context, uid int64) ([]domain.APIKey, error) {
+	ks, err := repo.dao.FindByUserId(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]domain.APIKey, 0, len(ks))
+	for _, k := range ks {
+		res = append(res, repo.entityToDomain(k))
+	}
+	return res, nil
+}
+
+func (repo *apiKeyRepository) Delete(ctx context.Context, uid int64, id int64) error {
+	return repo.dao.Delete(ctx, uid, id)
+}
+
+func (repo *apiKeyRepository) UpdateLastUsed(ctx context.Context, id int64, t time.Time) error {
+	return repo.dao.UpdateLastUsed(ctx, id, t.UnixMilli())
+}
+
+func (repo *apiKeyRepository) domainToEntity(k domain.APIKey) dao.APIKey {
+	res := dao.APIKey{
+		Id:      k.Id,
+		UserId:  k.UserId,
+		KeyHash: k.KeyHash,
+		Name:    k.Name,
+	}
+	if !k.ExpiresAt.IsZero() {
+		res.ExpiresAt = k.ExpiresAt.UnixMilli()
+	}
+	return res
+}
+
+func (repo *apiKeyRepository) entityToDomain(k dao.APIKey) domain.APIKey {
+	res := domain.APIKey{
+		Id:        k.Id,
+		UserId:    k.UserId,
+		KeyHash:   k.KeyHash,
+		Name:      k.Name,
+		CreatedAt: time.UnixMilli(k.Ctime),
+	}
+	if k.ExpiresAt > 0 {
+		res.ExpiresAt = time.UnixMilli(k.ExpiresAt)
+	}
+	if k.LastUsedAt > 0 {
+		res.LastUsedAt = time.UnixMilli(k.LastUsedAt)
+	}
+	return res
+}
@@ -0,0 +1,68 @@
+package repository
+
+import (
+	"context"
+	"time"
+	"webook/internal/domain"
+	"webook/internal/repository/dao"
+)
+
+var ErrBackupCodeUsed = dao.ErrBackupCodeUsed
+
+type BackupCodeRepository interface {
+	Replace(ctx context.Context, uid int64, codes []domain.BackupCode) error
+	FindUnused(ctx context.Context, uid int64) ([]domain.BackupCode, error)
+	CountUnused(ctx context.Context, uid int64) (int64, error)
+	MarkUsed(ctx context.Context, id int64, t time.Time) error
+}
+
+type backupCodeRepository struct {
+	dao dao.BackupCodeDAO
+}
+
+func NewBackupCodeRepository(dao dao.BackupCodeDAO) BackupCodeRepository {
+	return &backupCodeRepository{
+		dao: dao,
+	}
+}
+
+func (repo *backupCodeRepository) Replace(ctx context.Context, uid int64, codes []domain.BackupCode) error {
+	entities := make([]dao.BackupCode, 0, len(codes))
+	for _, c := range codes {
+		entities = append(entities, dao.BackupCode{CodeHash: c.CodeHash})
+	}
+	return repo.dao.Replace(ctx, uid, entities)
+}
+
+func (repo *backupCodeRepository) FindUnused(ctx context.Context, uid int64) ([]domain.BackupCode, error) {
+	cs, err := repo.dao.FindUnused(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]domain.BackupCode, 0, len(cs))
+	for _, c := range cs {
+		res = append(res, repo.entityToDomain(c))
+	}
+	return res, nil
+}
+
+func (repo *backupCodeRepository) CountUnused(ctx context.Context, uid int64) (int64, error) {
+	return repo.dao.CountUnused(ctx, uid)
+}
+
+func (repo *backupCodeRepository) MarkUsed(ctx context.Context, id int64, t time.Time) error {
+	return repo.dao.MarkUsed(ctx, id, t.UnixMilli())
+}
+
+func (repo *backupCodeRepository) entityToDomain(c dao.BackupCode) domain.BackupCode {
+	res := domain.BackupCode{
+		Id:        c.Id,
+		UserId:    c.UserId,
+		CodeHash:  c.CodeHash,
+		CreatedAt: time.UnixMilli(c.Ctime),
+	}
+	if c.UsedAt > 0 {
+		res.UsedAt = time.UnixMilli(c.UsedAt)
+	}
+	return res
+}
@@ -0,0 +1,62 @@
+package cache
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrAnonymousSessionNotFound = errors.New("匿名会话不存在或者已经过期")
+	ErrAnonymousSessionBound    = errors.New("匿名会话已经属于别的用户")
+)
+
+//go:embed lua/bind_anonymous_session.lua
+var luaBindAnonymousSession string
+
+// AnonymousSessionCache 登录之前的匿名会话
+type AnonymousSessionCache interface {
+	Create(ctx context.Context, id string, expiration time.Duration) error
+	// Bind 把匿名会话关联到登录的用户上，同一个用户重复关联不算错
+	Bind(ctx context.Context, id string, uid int64) error
+}
+
+type RedisAnonymousSessionCache struct {
+	client redis.Cmdable
+}
+
+func NewAnonymousSessionCache(client redis.Cmdable) AnonymousSessionCache {
+	return &RedisAnonymousSessionCache{
+		client: client,
+	}
+}
+
+func (c *RedisAnonymousSessionCache) Create(ctx context.Context, id string, expiration time.Duration) error {
+	return c.client.Set(ctx, c.key(id), "", expiration).Err()
+}
+
+func (c *RedisAnonymousSessionCache) Bind(ctx context.Context, id string, uid int64) error {
+	res, err := c.client.Eval(ctx, luaBindAnonymousSession,
+		[]string{c.key(id)}, strconv.FormatInt(uid, 10)).Int()
+	if err != nil {
+		return err
+	}
+	switch res {
+	case 0:
+		return nil
+	case -1:
+		return ErrAnonymousSessionNotFound
+	case -2:
+		return ErrAnonymousSessionBound
+	default:
+		return errors.New("系统错误")
+	}
+}
+
+func (c *RedisAnonymousSessionCache) key(id string) string {
+	return fmt.Sprintf("anon_session:%s", id)
+}
@@ -1,20 +1,24 @@
 package cache
 
 import (
+	"container/list"
 	"context"
+	"crypto/subtle"
 	_ "embed"
 	"errors"
 	"fmt"
-	"github.com/patrickmn/go-cache"
 	"github.com/redis/go-redis/v9"
+	"hash/fnv"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 var (
-	ErrCodeSendTooMany        = errors.New("发送验证码太频繁")
-	ErrCodeVerifyTooManyTimes = errors.New("验证次数太多")
-	ErrUnknownForCode         = errors.New("我也不知发生什么了，反正是跟 code 有关")
+	ErrCodeSendTooMany         = errors.New("发送验证码太频繁")
+	ErrCodeVerifyTooManyTimes  = errors.New("验证次数太多")
+	ErrUnknownForCode          = errors.New("我也不知发生什么了，反正是跟 code 有关")
+	ErrInvalidCodeCacheOptions = errors.New("验证码配置不合法")
 )
 
 // 编译器会在编译的时候，把 set_code 的代码放进来这个 luaSetCode 变量里
@@ -25,50 +29,259 @@ var luaSetCode string
 //go:embed lua/verify_code.lua
 var luaVerifyCode string
 
+//go:embed lua/peek_code.lua
+var luaPeekCode string
+
+//go:embed lua/code_cooldown.lua
+var luaCodeCooldown string
+
+// CodeSendTooManyError Set 在冷却期内返回的，带着还要等多久。
+// errors.Is(err, ErrCodeSendTooMany) 还是成立的，只关心是不是太频繁的不用改
+type CodeSendTooManyError struct {
+	// RetryAfter 按秒向上取整过的，跟 Cooldown 返回的一样
+	RetryAfter time.Duration
+}
+
+func (e *CodeSendTooManyError) Error() string {
+	return fmt.Sprintf("%s，%s 之后再试", ErrCodeSendTooMany.Error(), e.RetryAfter)
+}
+
+func (e *CodeSendTooManyError) Unwrap() error {
+	return ErrCodeSendTooMany
+}
+
+// VerifyResult Verify 的详细结果
+type VerifyResult struct {
+	OK bool
+	// RemainingAttempts 输错之后还能再试几次，0 就是用完了，再验证会返回 ErrCodeVerifyTooManyTimes。
+	// 没发过、过期了、已经用掉了的时候不知道，是 -1
+	RemainingAttempts int
+}
+
 type CodeCache interface {
+	// Set 验证码还没过期的时候再发就是重发，会换一个新的，旧的马上不能用了。
+	// 每重发一次，下一次要等的时间就多一个 Cooldown。
+	// 在冷却期内返回 *CodeSendTooManyError，不用再调一次 Cooldown 就知道要等多久
 	Set(ctx context.Context, biz, phone, code string) error
+	// Verify 验证通过了就把验证码删掉，再验证一次跟输错了是一样的。
+	// 删掉之后冷却期也没了，可以马上再发。
+	// 输错了、没发过、过期了都是 (false, nil)，error 只在验证次数用完了或者真的出错了的时候返回
 	Verify(ctx context.Context, biz, phone, inputCode string) (bool, error)
+	// VerifyWithResult 跟 Verify 一样，输错了的时候多告诉你还能再试几次
+	VerifyWithResult(ctx context.Context, biz, phone, inputCode string) (VerifyResult, error)
+	// Peek 只检查验证码对不对，不会消耗验证码：不扣减验证次数，也不会删掉。
+	// 它只能用来做预检查，比如前端展示用，最终校验一定要用 Verify，
+	// 不然验证码可以被无限次地猜
+	Peek(ctx context.Context, biz, phone, inputCode string) (bool, error)
+	// Cooldown 这个手机号还要等多久才能再发，不在冷却期就是 0
+	Cooldown(ctx context.Context, biz, phone string) (time.Duration, error)
+}
+
+// CodeCacheOptions 验证码的限制。Redis 只认秒，不满一秒的部分会被忽略
+type CodeCacheOptions struct {
+	// TTL 验证码多久过期
+	TTL time.Duration
+	// Cooldown 同一个手机号两次发送至少间隔多久。
+	// 同一个验证码有效期内第 n 次重发要等 n 个 Cooldown
+	Cooldown time.Duration
+	// MaxVerifyAttempts 一个验证码最多可以验证几次
+	MaxVerifyAttempts int
+}
+
+// DefaultCodeCacheOptions 十分钟过期，第一次重发要等一分钟，最多验证三次
+func DefaultCodeCacheOptions() CodeCacheOptions {
+	return CodeCacheOptions{
+		TTL:               time.Minute * 10,
+		Cooldown:          time.Minute,
+		MaxVerifyAttempts: 3,
+	}
+}
+
+// Validate 冷却时间要比过期时间短，不然验证码过期了还不能重新发
+func (o CodeCacheOptions) Validate() error {
+	if o.TTL < time.Second || o.Cooldown < time.Second ||
+		o.Cooldown >= o.TTL || o.MaxVerifyAttempts <= 0 {
+		return ErrInvalidCodeCacheOptions
+	}
+	return nil
+}
+
+// CodeCacheOption Redis 和本地的两种实现都能用，没有设置的就是 DefaultCodeCacheOptions 里面的
+type CodeCacheOption func(o *CodeCacheOptions)
+
+func (opt CodeCacheOption) applyLocal(c *LocalCodeCache) {
+	opt(&c.opts)
+}
+
+func (opt CodeCacheOption) applyRedis(c *RedisCodeCache) {
+	opt(&c.opts)
+}
+
+// WithTTL 验证码多久过期
+func WithTTL(ttl time.Duration) CodeCacheOption {
+	return func(o *CodeCacheOptions) {
+		o.TTL = ttl
+	}
+}
+
+// WithCooldown 第一次重发要等多久，后面每重发一次多等这么久
+func WithCooldown(d time.Duration) CodeCacheOption {
+	return func(o *CodeCacheOptions) {
+		o.Cooldown = d
+	}
+}
+
+// WithResendInterval 就是 WithCooldown，配置里面叫重发间隔
+func WithResendInterval(interval time.Duration) CodeCacheOption {
+	return WithCooldown(interval)
+}
+
+// WithMaxAttempts 一个验证码最多可以验证几次
+func WithMaxAttempts(n int) CodeCacheOption {
+	return func(o *CodeCacheOptions) {
+		o.MaxVerifyAttempts = n
+	}
+}
+
+// RedisCodeCacheOption 控制 RedisCodeCache 的一些参数，CodeCacheOption 也可以直接传
+type RedisCodeCacheOption interface {
+	applyRedis(c *RedisCodeCache)
+}
+
+type redisCodeCacheOptionFunc func(c *RedisCodeCache)
+
+func (f redisCodeCacheOptionFunc) applyRedis(c *RedisCodeCache) {
+	f(c)
+}
+
+// WithKeyPrefix key 是 prefix:biz:phone，默认是 phone_code。
+// 几套配置共用一个 Redis 的时候用不同的前缀隔开，不然冷却期、验证次数都会混在一起
+func WithKeyPrefix(prefix string) RedisCodeCacheOption {
+	return redisCodeCacheOptionFunc(func(c *RedisCodeCache) {
+		c.keyPrefix = prefix
+	})
 }
 
 type RedisCodeCache struct {
-	client redis.Cmdable
+	client    redis.Cmdable
+	keyPrefix string
+
+	// 运行时可以改，每次 Set 都是读最新的，通过 ARGV 传给 lua 脚本
+	mutex sync.RWMutex
+	opts  CodeCacheOptions
+}
+
+// NewCodeCacheGoBestPractice Go 的最佳实践是返回具体类型。
+// 配置不合法是写代码或者写配置的时候的错误，启动的时候就 panic
+func NewCodeCacheGoBestPractice(client redis.Cmdable, opts ...RedisCodeCacheOption) *RedisCodeCache {
+	c := &RedisCodeCache{
+		client:    client,
+		keyPrefix: "phone_code",
+		opts:      DefaultCodeCacheOptions(),
+	}
+	for _, opt := range opts {
+		opt.applyRedis(c)
+	}
+	if err := c.opts.Validate(); err != nil {
+		panic(fmt.Sprintf("%s：%+v", err, c.opts))
+	}
+	return c
+}
+
+// Options 返回的是一个副本，改了也不会影响 RedisCodeCache
+func (c *RedisCodeCache) Options() CodeCacheOptions {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.opts
 }
 
-// NewCodeCacheGoBestPractice Go 的最佳实践是返回具体类型
-func NewCodeCacheGoBestPractice(client redis.Cmdable) *RedisCodeCache {
-	return &RedisCodeCache{
-		client: client,
+// SetOptions 下一次调用 Set 就生效，已经发出去的验证码还是按照发送时候的配置。
+// 只改了当前这个实例，多个实例要各自调用
+func (c *RedisCodeCache) SetOptions(opts CodeCacheOptions) error {
+	if err := opts.Validate(); err != nil {
+		return err
 	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.opts = opts
+	return nil
 }
 
-/*func NewCodeCache(client redis.Cmdable) CodeCache {
-	return &RedisCodeCache{
-		client: client,
+// PoolStats Redis 连接池的情况。client 不是 *redis.Client 或者 *redis.ClusterClient 的时候
+// （比如说测试里面的 mock）返回零值
+func (c *RedisCodeCache) PoolStats() redis.PoolStats {
+	if s, ok := c.client.(poolStatser); ok {
+		return *s.PoolStats()
 	}
-}*/
+	return redis.PoolStats{}
+}
+
+// NewCodeCache 跟 NewCodeCacheGoBestPractice 一样，返回的是接口
+func NewCodeCache(client redis.Cmdable, opts ...RedisCodeCacheOption) CodeCache {
+	return NewCodeCacheGoBestPractice(client, opts...)
+}
 
 func (c *RedisCodeCache) Set(ctx context.Context, biz, phone, code string) error {
-	res, err := c.client.Eval(ctx, luaSetCode, []string{c.key(biz, phone)}, code).Int()
+	opts := c.Options()
+	res, err := c.client.Eval(ctx, luaSetCode, []string{c.key(biz, phone)}, code,
+		int64(opts.TTL/time.Second), int64(opts.Cooldown/time.Second), opts.MaxVerifyAttempts).Result()
 	if err != nil {
 		return err
 	}
-	switch res {
-	case 0:
-		// 毫无问题
-		return nil
-	case -1:
-		// 发送太频繁
-		return ErrCodeSendTooMany
-	//case -2:
-	//	return
-	default:
-		// 系统错误
-		return errors.New("系统错误")
+	switch val := res.(type) {
+	case int64:
+		if val == 0 {
+			// 毫无问题
+			return nil
+		}
+	case []any:
+		// 发送太频繁，{-1, 还要等几秒}
+		if len(val) == 2 && val[0] == int64(-1) {
+			if remaining, ok := val[1].(int64); ok {
+				return &CodeSendTooManyError{RetryAfter: time.Duration(remaining) * time.Second}
+			}
+		}
 	}
+	// 系统错误
+	return errors.New("系统错误")
 }
 
 func (c *RedisCodeCache) Verify(ctx context.Context, biz, phone, inputCode string) (bool, error) {
-	res, err := c.client.Eval(ctx, luaVerifyCode, []string{c.key(biz, phone)}, inputCode).Int()
+	res, err := c.VerifyWithResult(ctx, biz, phone, inputCode)
+	return res.OK, err
+}
+
+func (c *RedisCodeCache) VerifyWithResult(ctx context.Context, biz, phone, inputCode string) (VerifyResult, error) {
+	res, err := c.client.Eval(ctx, luaVerifyCode, []string{c.key(biz, phone)}, inputCode).Result()
+	if err != nil {
+		return VerifyResult{RemainingAttempts: -1}, err
+	}
+	switch val := res.(type) {
+	case int64:
+		switch val {
+		case 0:
+			return VerifyResult{OK: true}, nil
+		case -1:
+			// 正常来说，如果频繁出现这个错误，你就要告警，因为有人搞你
+			return VerifyResult{}, ErrCodeVerifyTooManyTimes
+		case -2:
+			return VerifyResult{RemainingAttempts: -1}, nil
+		}
+	case []any:
+		// 输错了，{-2, 还剩几次}
+		if len(val) == 2 {
+			remaining, ok := val[1].(int64)
+			if ok && val[0] == int64(-2) {
+				return VerifyResult{RemainingAttempts: int(remaining)}, nil
+			}
+		}
+	}
+	return VerifyResult{RemainingAttempts: -1}, ErrUnknownForCode
+}
+
+// Peek 不会消耗验证码，不能用来做最终校验
+func (c *RedisCodeCache) Peek(ctx context.Context, biz, phone, inputCode string) (bool, error) {
+	res, err := c.client.Eval(ctx, luaPeekCode, []string{c.key(biz, phone)}, inputCode).Int()
 	if err != nil {
 		return false, err
 	}
@@ -76,114 +289,419 @@ func (c *RedisCodeCache) Verify(ctx context.Context, biz, phone, inputCode strin
 	case 0:
 		return true, nil
 	case -1:
-		// 正常来说，如果频繁出现这个错误，你就要告警，因为有人搞你
 		return false, ErrCodeVerifyTooManyTimes
 	case -2:
 		return false, nil
-		//default:
-		//	return false, ErrUnknownForCode
 	}
 	return false, ErrUnknownForCode
 }
 
+// Cooldown 跟 set_code.lua 的判断一样，重发过的要多等，见 code_cooldown.lua
+func (c *RedisCodeCache) Cooldown(ctx context.Context, biz, phone string) (time.Duration, error) {
+	opts := c.Options()
+	remaining, err := c.client.Eval(ctx, luaCodeCooldown, []string{c.key(biz, phone)},
+		int64(opts.TTL/time.Second), int64(opts.Cooldown/time.Second)).Int64()
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(remaining) * time.Second, nil
+}
+
 //func (c *RedisCodeCache) Verify(ctx context.Context, biz, phone, code string) error {
 //
 //}
 
 func (c *RedisCodeCache) key(biz, phone string) string {
-	return fmt.Sprintf("phone_code:%s:%s", biz, phone)
+	return fmt.Sprintf("%s:%s:%s", c.keyPrefix, biz, phone)
 }
 
 // LocalCodeCache 假如说你要切换这个，你是不是得把 lua 脚本的逻辑，在这里再写一遍？
+// 过期的验证码由后台的 goroutine 定期清理，用完记得 Close 或者 cancel 掉传进来的 ctx，
+// 不然这个 goroutine 会一直在。
+//
+// 存的验证码个数有上限，不然换着手机号一直发，内存会一直涨到下一次清理。
+// 满了就淘汰最早发的，还没过期的被淘汰了也计数，见 Evictions
 type LocalCodeCache struct {
-	cache *cache.Cache
+	// Set 是先读再写，按 key 分段加锁，不同手机号之间不会互相等；
+	// Verify 只在查找的时候加锁，扣减次数用的是 atomic
+	shards []*localCodeCacheShard
+	// 还没过期就被淘汰掉的
+	evictions atomic.Int64
+
+	// 跟 RedisCodeCache 一样的限制，创建之后就不会再改了
+	opts          CodeCacheOptions
+	purgeInterval time.Duration
+	maxEntries    int
+	// 过期、冷却期都按它算，测试的时候可以换掉
+	now    func() time.Time
+	cancel context.CancelFunc
+	// 清理的 goroutine 退出之后会关掉
+	done chan struct{}
+}
+
+// localCodeCacheShards 分多少段锁，手机号是均匀散开的，64 段已经很难撞上了
+const localCodeCacheShards = 64
+
+// DefaultLocalCodeCacheMaxEntries 一个验证码一百来个字节，十万个也就十几 MB
+const DefaultLocalCodeCacheMaxEntries = 100_000
+
+// localCodeCacheShard 每一段自己淘汰，上限是总数平均分下来的
+type localCodeCacheShard struct {
 	mutex sync.Mutex
+	// key => lru 里面的 *localCodeCacheEntry
+	items map[string]*list.Element
+	// 最近 Set 的在前面。TTL 都一样，越往后越早过期
+	lru      *list.List
+	capacity int
+}
+
+type localCodeCacheEntry struct {
+	key   string
+	value *localCodeCacheValue
 }
 
+// localCodeCacheValue 除了 times 之外放进去之后就不会再改了，要改就整个换掉
 type localCodeCacheValue struct {
-	code       string
-	times      int64
-	createTime int64
+	code string
+	// 还能验证几次，-1 是已经用过了，马上就会删掉
+	times atomic.Int64
+	// 有效期内重发了几次
+	resends    int64
+	expiration time.Time
+}
+
+func newLocalCodeCacheShards(n, maxEntries int) []*localCodeCacheShard {
+	capacity := maxEntries / n
+	if capacity < 1 {
+		capacity = 1
+	}
+	res := make([]*localCodeCacheShard, n)
+	for i := range res {
+		res[i] = &localCodeCacheShard{
+			items:    make(map[string]*list.Element),
+			lru:      list.New(),
+			capacity: capacity,
+		}
+	}
+	return res
 }
 
-func NewCodeCache() CodeCache {
-	return &LocalCodeCache{
-		cache: cache.New(cache.NoExpiration, time.Minute*10),
+// get 调用的人要拿着锁
+func (s *localCodeCacheShard) get(key string) (*localCodeCacheValue, bool) {
+	elem, ok := s.items[key]
+	if !ok {
+		return nil, false
+	}
+	return elem.Value.(*localCodeCacheEntry).value, true
+}
+
+// put 调用的人要拿着锁。先把后面过期了的清掉，还是超过上限的话淘汰最后一个，
+// 返回淘汰了几个还没过期的
+func (s *localCodeCacheShard) put(key string, value *localCodeCacheValue, now time.Time) int64 {
+	if elem, ok := s.items[key]; ok {
+		elem.Value.(*localCodeCacheEntry).value = value
+		s.lru.MoveToFront(elem)
+	} else {
+		s.items[key] = s.lru.PushFront(&localCodeCacheEntry{key: key, value: value})
+	}
+	for {
+		back := s.lru.Back()
+		if back == nil || !now.After(back.Value.(*localCodeCacheEntry).value.expiration) {
+			break
+		}
+		s.remove(back)
+	}
+	var evicted int64
+	for s.lru.Len() > s.capacity {
+		s.remove(s.lru.Back())
+		evicted++
+	}
+	return evicted
+}
+
+func (s *localCodeCacheShard) remove(elem *list.Element) {
+	s.lru.Remove(elem)
+	delete(s.items, elem.Value.(*localCodeCacheEntry).key)
+}
+
+// LocalCodeCacheOption 控制 LocalCodeCache 的一些参数，CodeCacheOption 也可以直接传
+type LocalCodeCacheOption interface {
+	applyLocal(c *LocalCodeCache)
+}
+
+type localCodeCacheOptionFunc func(c *LocalCodeCache)
+
+func (f localCodeCacheOptionFunc) applyLocal(c *LocalCodeCache) {
+	f(c)
+}
+
+// WithClock 换掉 time.Now，测试的时候用来假装时间过去了
+func WithClock(now func() time.Time) LocalCodeCacheOption {
+	return localCodeCacheOptionFunc(func(c *LocalCodeCache) {
+		c.now = now
+	})
+}
+
+// WithPurgeInterval 多久清理一次过期的验证码，默认 10 分钟
+func WithPurgeInterval(interval time.Duration) LocalCodeCacheOption {
+	return localCodeCacheOptionFunc(func(c *LocalCodeCache) {
+		c.purgeInterval = interval
+	})
+}
+
+// WithMaxEntries 最多存多少个验证码，默认 DefaultLocalCodeCacheMaxEntries。
+// 每一段的上限是 n 平均分下来的，所以一段满了的时候总数可能还没到 n
+func WithMaxEntries(n int) LocalCodeCacheOption {
+	return localCodeCacheOptionFunc(func(c *LocalCodeCache) {
+		c.maxEntries = n
+	})
+}
+
+func NewLocalCodeCache() CodeCache {
+	return NewLocalCodeCacheWithContext(context.Background())
+}
+
+// NewLocalCodeCacheWithContext ctx 被取消的时候，后台清理的 goroutine 就会退出
+func NewLocalCodeCacheWithContext(ctx context.Context, opts ...LocalCodeCacheOption) *LocalCodeCache {
+	c := &LocalCodeCache{
+		opts:          DefaultCodeCacheOptions(),
+		purgeInterval: time.Minute * 10,
+		maxEntries:    DefaultLocalCodeCacheMaxEntries,
+		now:           time.Now,
+		done:          make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt.applyLocal(c)
+	}
+	if err := c.opts.Validate(); err != nil {
+		panic(fmt.Sprintf("%s：%+v", err, c.opts))
+	}
+	if c.maxEntries <= 0 {
+		panic(fmt.Sprintf("%s：max entries %d", ErrInvalidCodeCacheOptions, c.maxEntries))
+	}
+	c.shards = newLocalCodeCacheShards(localCodeCacheShards, c.maxEntries)
+	ctx, c.cancel = context.WithCancel(ctx)
+	go c.purge(ctx)
+	return c
+}
+
+// Close 停掉后台清理的 goroutine，会等它真的退出了再返回。可以重复调用
+func (c *LocalCodeCache) Close() error {
+	c.cancel()
+	<-c.done
+	return nil
+}
+
+func (c *LocalCodeCache) purge(ctx context.Context) {
+	defer close(c.done)
+	ticker := time.NewTicker(c.purgeInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			now := c.now()
+			for _, s := range c.shards {
+				s.mutex.Lock()
+				for elem := s.lru.Front(); elem != nil; {
+					next := elem.Next()
+					if now.After(elem.Value.(*localCodeCacheEntry).value.expiration) {
+						s.remove(elem)
+					}
+					elem = next
+				}
+				s.mutex.Unlock()
+			}
+		}
 	}
 }
 
+// Options 创建的时候定下来的配置
+func (c *LocalCodeCache) Options() CodeCacheOptions {
+	return c.opts
+}
+
+// Len 现在存着多少个验证码，包括过期了还没清理掉的，给监控用
+func (c *LocalCodeCache) Len() int {
+	var res int
+	for _, s := range c.shards {
+		s.mutex.Lock()
+		res += s.lru.Len()
+		s.mutex.Unlock()
+	}
+	return res
+}
+
+// Evictions 从创建到现在，因为满了被淘汰掉的还没过期的验证码。
+// 一直在涨说明上限太小了，或者有人在刷
+func (c *LocalCodeCache) Evictions() int64 {
+	return c.evictions.Load()
+}
+
 func (c *LocalCodeCache) getValue(code string) *localCodeCacheValue {
-	return &localCodeCacheValue{
+	value := &localCodeCacheValue{
 		code:       code,
-		times:      3,
-		createTime: time.Now().Unix(),
+		expiration: c.now().Add(c.opts.TTL),
+	}
+	value.times.Store(int64(c.opts.MaxVerifyAttempts))
+	return value
+}
+
+// cooldown 跟 code_cooldown.lua 一样按剩下的秒数算，还要等几秒才能再发，0 就是现在就能发
+func (c *LocalCodeCache) cooldown(v *localCodeCacheValue, now time.Time) int64 {
+	// Redis 的 TTL 是四舍五入到秒的
+	ttl := int64((v.expiration.Sub(now) + time.Second/2) / time.Second)
+	expiration := int64(c.opts.TTL / time.Second)
+	cooldown := int64(c.opts.Cooldown / time.Second)
+	remaining := ttl - (expiration - cooldown*(v.resends+1)) + 1
+	// 最多等到过期
+	if remaining > ttl+1 {
+		remaining = ttl + 1
+	}
+	if remaining < 0 {
+		return 0
 	}
+	return remaining
 }
 
 func (c *LocalCodeCache) key(biz, phone string) string {
 	return fmt.Sprintf("phone_code:%s:%s", biz, phone)
 }
 
-func (c *LocalCodeCache) Set(ctx context.Context, biz, phone, code string) error {
+// shard 同一个 key 永远在同一段
+func (c *LocalCodeCache) shard(key string) *localCodeCacheShard {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	return c.shards[h.Sum32()%uint32(len(c.shards))]
+}
 
-	c.mutex.Lock()
-	defer c.mutex.Unlock()
+// get 调用的人要拿着 s 的锁。过期了但是还没来得及清理的也当成不存在
+func (c *LocalCodeCache) get(s *localCodeCacheShard, key string) (*localCodeCacheValue, bool) {
+	value, found := s.get(key)
+	if !found || c.now().After(value.expiration) {
+		return nil, false
+	}
+	return value, true
+}
 
+func (c *LocalCodeCache) Set(ctx context.Context, biz, phone, code string) error {
 	//查找
 	key := c.key(biz, phone)
-
-	if item, found := c.cache.Get(key); found {
-		//key存在,验证过期时间
-		value, ok := item.(*localCodeCacheValue)
-		if !ok {
-			return ErrUnknownForCode
-		}
-		//小于1分钟
-		if time.Now().Unix()-value.createTime < 60 {
-			return ErrCodeSendTooMany
+	s := c.shard(key)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	value, found := c.get(s, key)
+	//key存在,还在冷却期，重发过的要多等
+	if found {
+		if remaining := c.cooldown(value, c.now()); remaining > 0 {
+			return &CodeSendTooManyError{RetryAfter: time.Duration(remaining) * time.Second}
 		}
 	}
 
-	c.cache.Set(key, c.getValue(code), time.Minute*5)
+	newValue := c.getValue(code)
+	if found {
+		newValue.resends = value.resends + 1
+	}
+	if evicted := s.put(key, newValue, c.now()); evicted > 0 {
+		c.evictions.Add(evicted)
+	}
 	return nil
 }
 
 func (c *LocalCodeCache) Verify(ctx context.Context, biz, phone, inputCode string) (bool, error) {
+	res, err := c.VerifyWithResult(ctx, biz, phone, inputCode)
+	return res.OK, err
+}
 
-	c.mutex.Lock()
-	defer c.mutex.Unlock()
-
+func (c *LocalCodeCache) VerifyWithResult(ctx context.Context, biz, phone, inputCode string) (VerifyResult, error) {
 	//查找
 	key := c.key(biz, phone)
-
-	item, found := c.cache.Get(key)
-
-	//没有
+	s := c.shard(key)
+	s.mutex.Lock()
+	value, found := c.get(s, key)
+	s.mutex.Unlock()
+	//没有，跟 lua 脚本一样当输错了
 	if !found {
-		return false, ErrUnknownForCode
+		return VerifyResult{RemainingAttempts: -1}, nil
 	}
 
-	value, ok := item.(*localCodeCacheValue)
-
+	ok := codeEqual(value.code, inputCode)
+	// 并发的时候 CAS 失败了就重新读，保证次数不会被扣成负数，
+	// 也不会有两个人同时用掉同一个验证码
+	var next int64
+	for {
+		times := value.times.Load()
+		//被别人抢先用掉了，马上就会删掉，跟没有一样
+		if times < 0 {
+			return VerifyResult{RemainingAttempts: -1}, nil
+		}
+		//说明，用户一直输错，有人搞你
+		if times == 0 {
+			return VerifyResult{}, ErrCodeVerifyTooManyTimes
+		}
+		next = times - 1
+		if ok {
+			next = -1
+		}
+		if value.times.CompareAndSwap(times, next) {
+			break
+		}
+	}
+	//用户手一抖，输错了
+	//可验证次数 -1
 	if !ok {
-		return false, ErrUnknownForCode
+		return VerifyResult{RemainingAttempts: int(next)}, nil
+	}
+
+	// 用过了直接删掉，中间被 Set 换掉了就不管了
+	s.mutex.Lock()
+	if elem, exists := s.items[key]; exists && elem.Value.(*localCodeCacheEntry).value == value {
+		s.remove(elem)
+	}
+	s.mutex.Unlock()
+	return VerifyResult{OK: true}, nil
+}
+
+// Peek 不会消耗验证码，不能用来做最终校验
+func (c *LocalCodeCache) Peek(ctx context.Context, biz, phone, inputCode string) (bool, error) {
+	key := c.key(biz, phone)
+	s := c.shard(key)
+	s.mutex.Lock()
+	value, found := c.get(s, key)
+	s.mutex.Unlock()
+	if !found {
+		return false, nil
 	}
 
-	//说明，用户一直输错，有人搞你
-	//或者已经用过了，也是有人搞你
-	if value.times <= 0 {
+	times := value.times.Load()
+	// 刚用掉还没删的，跟没有一样
+	if times < 0 {
+		return false, nil
+	}
+	if times == 0 {
 		return false, ErrCodeVerifyTooManyTimes
 	}
 
-	//用户手一抖，输错了
-	//可验证次数 -1
-	if value.code != inputCode {
-		value.times--
-		c.cache.Set(key, value, time.Minute*5)
-		return false, ErrUnknownForCode
+	// 不管对不对，都不动 times
+	return codeEqual(value.code, inputCode), nil
+}
+
+func (c *LocalCodeCache) Cooldown(ctx context.Context, biz, phone string) (time.Duration, error) {
+	key := c.key(biz, phone)
+	s := c.shard(key)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	value, found := c.get(s, key)
+	if !found {
+		return 0, nil
 	}
+	return time.Duration(c.cooldown(value, c.now())) * time.Second, nil
+}
 
-	value.times = -1
-	c.cache.Set(key, value, time.Second)
-	return true, nil
+// codeEqual 比较的时间跟有几位相同没有关系，不能靠响应时间一位一位猜出来。
+// 长度不一样会直接返回，验证码的长度本来就不是秘密
+func codeEqual(code, inputCode string) bool {
+	return subtle.ConstantTimeCompare([]byte(code), []byte(inputCode)) == 1
 }
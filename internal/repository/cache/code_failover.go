@@ -0,0 +1,188 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"github.com/redis/go-redis/v9"
+	"io"
+	"log"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// FailoverCodeCache 平时用 primary（Redis），连不上 Redis 的时候切到 fallback（本地），
+// 后台定期探测，Redis 好了再切回来。发太频繁、验证次数用完这种业务上的错误不会切换。
+//
+// 切换期间有一个一致性的问题，这里是接受了的：
+//   - 故障期间发的验证码只存在发它的那个实例上，请求落到别的实例就验证不了；
+//   - 切回 Redis 之后，故障期间发的验证码也验证不了了，Redis 里面没有，用户要重发；
+//   - 冷却期和验证次数是各记各的，切换的时候会重新算。
+//
+// 总比 Redis 挂了的时候谁都登录不了好。切换了几次看 Activations
+type FailoverCodeCache struct {
+	primary  CodeCache
+	fallback CodeCache
+	// probe 返回 nil 就当 Redis 恢复了，一般是 Ping
+	probe         func(ctx context.Context) error
+	probeInterval time.Duration
+
+	down atomic.Bool
+	// 从正常切到本地的次数
+	activations atomic.Int64
+
+	// mutex 保护探测的 goroutine 的启动和 Close
+	mutex  sync.Mutex
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// FailoverCodeCacheOption 控制 FailoverCodeCache 的一些参数
+type FailoverCodeCacheOption func(c *FailoverCodeCache)
+
+// WithProbeInterval 切到本地之后多久探测一次 Redis，默认 5 秒
+func WithProbeInterval(interval time.Duration) FailoverCodeCacheOption {
+	return func(c *FailoverCodeCache) {
+		c.probeInterval = interval
+	}
+}
+
+// NewFailoverCodeCache ctx 被取消或者 Close 之后不会再探测，
+// 已经切到本地的就一直用本地的
+func NewFailoverCodeCache(ctx context.Context, primary, fallback CodeCache,
+	probe func(ctx context.Context) error, opts ...FailoverCodeCacheOption) *FailoverCodeCache {
+	c := &FailoverCodeCache{
+		primary:       primary,
+		fallback:      fallback,
+		probe:         probe,
+		probeInterval: time.Second * 5,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	c.ctx, c.cancel = context.WithCancel(ctx)
+	return c
+}
+
+// Close 停掉探测的 goroutine，会等它真的退出了再返回。可以重复调用
+func (c *FailoverCodeCache) Close() error {
+	c.mutex.Lock()
+	c.cancel()
+	c.mutex.Unlock()
+	c.wg.Wait()
+	return nil
+}
+
+// Down 现在是不是在用本地的，给监控用
+func (c *FailoverCodeCache) Down() bool {
+	return c.down.Load()
+}
+
+// Activations 从创建到现在切到本地几次了
+func (c *FailoverCodeCache) Activations() int64 {
+	return c.activations.Load()
+}
+
+func (c *FailoverCodeCache) Set(ctx context.Context, biz, phone, code string) error {
+	_, err := failover(c, func(cc CodeCache) (struct{}, error) {
+		return struct{}{}, cc.Set(ctx, biz, phone, code)
+	})
+	return err
+}
+
+func (c *FailoverCodeCache) Verify(ctx context.Context, biz, phone, inputCode string) (bool, error) {
+	return failover(c, func(cc CodeCache) (bool, error) {
+		return cc.Verify(ctx, biz, phone, inputCode)
+	})
+}
+
+func (c *FailoverCodeCache) VerifyWithResult(ctx context.Context, biz, phone, inputCode string) (VerifyResult, error) {
+	return failover(c, func(cc CodeCache) (VerifyResult, error) {
+		return cc.VerifyWithResult(ctx, biz, phone, inputCode)
+	})
+}
+
+func (c *FailoverCodeCache) Peek(ctx context.Context, biz, phone, inputCode string) (bool, error) {
+	return failover(c, func(cc CodeCache) (bool, error) {
+		return cc.Peek(ctx, biz, phone, inputCode)
+	})
+}
+
+func (c *FailoverCodeCache) Cooldown(ctx context.Context, biz, phone string) (time.Duration, error) {
+	return failover(c, func(cc CodeCache) (time.Duration, error) {
+		return cc.Cooldown(ctx, biz, phone)
+	})
+}
+
+// failover 这一次 Redis 连不上的话，这一次就直接用本地的重试
+func failover[T any](c *FailoverCodeCache, call func(cc CodeCache) (T, error)) (T, error) {
+	if !c.down.Load() {
+		res, err := call(c.primary)
+		if !isConnectionErr(err) {
+			return res, err
+		}
+		c.markDown(err)
+	}
+	return call(c.fallback)
+}
+
+func (c *FailoverCodeCache) markDown(err error) {
+	if !c.down.CompareAndSwap(false, true) {
+		return
+	}
+	c.activations.Add(1)
+	log.Println("验证码缓存：Redis 连不上，切到本地", err)
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.ctx.Err() != nil {
+		return
+	}
+	c.wg.Add(1)
+	go c.watch()
+}
+
+// watch 一直探测到 Redis 恢复
+func (c *FailoverCodeCache) watch() {
+	defer c.wg.Done()
+	ticker := time.NewTicker(c.probeInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(c.ctx, c.probeInterval)
+			err := c.probe(ctx)
+			cancel()
+			if err == nil {
+				c.down.Store(false)
+				log.Println("验证码缓存：Redis 恢复了，切回 Redis")
+				return
+			}
+		}
+	}
+}
+
+// isConnectionErr 只有连不上、连接断了才算。
+// Redis 返回的错误（比如脚本出错）、调用的人自己取消了，都不切换
+func isConnectionErr(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, ErrCodeSendTooMany) {
+		return false
+	}
+	switch err {
+	case ErrCodeVerifyTooManyTimes, ErrUnknownForCode:
+		return false
+	}
+	// context 的超时也实现了 net.Error，要先排除掉
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, redis.ErrClosed)
+}
@@ -0,0 +1,171 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/goleak"
+	"io"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// downCmdable down 了之后 Eval 和 Ping 都跟连不上 Redis 一样返回 *net.OpError
+type downCmdable struct {
+	redis.Cmdable
+	down atomic.Bool
+}
+
+func (c *downCmdable) err() error {
+	return &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+}
+
+func (c *downCmdable) Eval(ctx context.Context, script string, keys []string, args ...any) *redis.Cmd {
+	if c.down.Load() {
+		res := redis.NewCmd(ctx)
+		res.SetErr(c.err())
+		return res
+	}
+	return c.Cmdable.Eval(ctx, script, keys, args...)
+}
+
+func (c *downCmdable) Ping(ctx context.Context) *redis.StatusCmd {
+	if c.down.Load() {
+		res := redis.NewStatusCmd(ctx)
+		res.SetErr(c.err())
+		return res
+	}
+	return c.Cmdable.Ping(ctx)
+}
+
+func newTestFailoverCodeCache(t *testing.T) (*FailoverCodeCache, *downCmdable, *LocalCodeCache) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	cmd := &downCmdable{Cmdable: client}
+	local := NewLocalCodeCacheWithContext(context.Background())
+	c := NewFailoverCodeCache(context.Background(), NewCodeCacheGoBestPractice(cmd), local,
+		func(ctx context.Context) error {
+			return cmd.Ping(ctx).Err()
+		}, WithProbeInterval(time.Millisecond*10))
+	t.Cleanup(func() {
+		_ = c.Close()
+		_ = local.Close()
+		_ = client.Close()
+	})
+	return c, cmd, local
+}
+
+func TestFailoverCodeCache(t *testing.T) {
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+	ctx := context.Background()
+
+	t.Run("Redis 正常的时候不会用本地的", func(t *testing.T) {
+		c, _, local := newTestFailoverCodeCache(t)
+		require.NoError(t, c.Set(ctx, "login", "152", "123456"))
+		assert.Equal(t, 0, local.Len())
+		ok, err := c.Verify(ctx, "login", "152", "123456")
+		require.NoError(t, err)
+		assert.True(t, ok)
+		assert.False(t, c.Down())
+		assert.Equal(t, int64(0), c.Activations())
+	})
+
+	t.Run("业务上的错误不切换", func(t *testing.T) {
+		c, _, _ := newTestFailoverCodeCache(t)
+		require.NoError(t, c.Set(ctx, "login", "152", "123456"))
+		assert.ErrorIs(t, c.Set(ctx, "login", "152", "654321"), ErrCodeSendTooMany)
+		for i := 0; i < 3; i++ {
+			ok, err := c.Verify(ctx, "login", "152", "654321")
+			require.NoError(t, err)
+			assert.False(t, ok)
+		}
+		_, err := c.Verify(ctx, "login", "152", "123456")
+		assert.Equal(t, ErrCodeVerifyTooManyTimes, err)
+		assert.False(t, c.Down())
+		assert.Equal(t, int64(0), c.Activations())
+	})
+
+	t.Run("连不上 Redis 切到本地", func(t *testing.T) {
+		c, cmd, local := newTestFailoverCodeCache(t)
+		cmd.down.Store(true)
+		// 这一次就用本地的重试，调用方感知不到
+		require.NoError(t, c.Set(ctx, "login", "152", "123456"))
+		assert.True(t, c.Down())
+		assert.Equal(t, int64(1), c.Activations())
+		assert.Equal(t, 1, local.Len())
+		// 切过去了之后也是本地的冷却期
+		assert.ErrorIs(t, c.Set(ctx, "login", "152", "654321"), ErrCodeSendTooMany)
+		ok, err := c.Verify(ctx, "login", "152", "123456")
+		require.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, int64(1), c.Activations())
+	})
+
+	t.Run("Redis 恢复了切回去", func(t *testing.T) {
+		c, cmd, local := newTestFailoverCodeCache(t)
+		cmd.down.Store(true)
+		require.NoError(t, c.Set(ctx, "login", "152", "123456"))
+		require.True(t, c.Down())
+		cmd.down.Store(false)
+		require.Eventually(t, func() bool {
+			return !c.Down()
+		}, time.Second, time.Millisecond*10)
+
+		// 故障期间发的验证码 Redis 里面没有，这是接受了的
+		ok, err := c.Verify(ctx, "login", "152", "123456")
+		require.NoError(t, err)
+		assert.False(t, ok)
+		// 新发的又放回 Redis 里了
+		require.NoError(t, c.Set(ctx, "login", "153", "123456"))
+		assert.Equal(t, 1, local.Len())
+
+		// 再挂一次，次数要累加
+		cmd.down.Store(true)
+		_, err = c.Cooldown(ctx, "login", "154")
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), c.Activations())
+	})
+
+	t.Run("Close 之后不再探测", func(t *testing.T) {
+		c, cmd, _ := newTestFailoverCodeCache(t)
+		cmd.down.Store(true)
+		_, err := c.Peek(ctx, "login", "152", "123456")
+		require.NoError(t, err)
+		require.True(t, c.Down())
+		require.NoError(t, c.Close())
+		require.NoError(t, c.Close())
+		cmd.down.Store(false)
+		time.Sleep(time.Millisecond * 50)
+		assert.True(t, c.Down())
+	})
+}
+
+func TestIsConnectionErr(t *testing.T) {
+	testCases := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "nil", err: nil, want: false},
+		{name: "发送太频繁", err: ErrCodeSendTooMany, want: false},
+		{name: "发送太频繁，带了要等多久", err: &CodeSendTooManyError{RetryAfter: time.Minute}, want: false},
+		{name: "验证次数用完", err: ErrCodeVerifyTooManyTimes, want: false},
+		{name: "验证码不对", err: ErrUnknownForCode, want: false},
+		{name: "Redis 返回的错误", err: redis.Nil, want: false},
+		{name: "调用方超时", err: context.DeadlineExceeded, want: false},
+		{name: "调用方取消", err: context.Canceled, want: false},
+		{name: "连不上", err: &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}, want: true},
+		{name: "连接断了", err: io.EOF, want: true},
+		{name: "客户端关了", err: redis.ErrClosed, want: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, isConnectionErr(tc.err))
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package cache
+
+import (
+	"context"
+	"github.com/prometheus/client_golang/prometheus"
+	"time"
+)
+
+const (
+	// CodeCacheImplRedis 打在指标上的 impl 标签
+	CodeCacheImplRedis = "redis"
+	CodeCacheImplLocal = "local"
+)
+
+// CodeCacheMetrics 一个进程里面只注册一次，多个 CodeCache 用 impl 区分。
+//
+// result 跟 tracedCodeCache 打在 span 上的一样：ok、mismatch（验证码不对）、
+// send_too_many（发太频繁）、verify_too_many（验证次数用完）、error。
+// verify_too_many 一直涨就是有人在猜验证码，要配告警。
+// 耗时也带 result，Lua 脚本慢的时候分得清是正常发出去的慢还是超时报错的慢
+type CodeCacheMetrics struct {
+	results  *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+}
+
+// NewCodeCacheMetrics 已经注册过了就用注册过的那一份，测试里面会初始化好几次
+func NewCodeCacheMetrics(reg prometheus.Registerer) (*CodeCacheMetrics, error) {
+	m := &CodeCacheMetrics{
+		results: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "webook",
+			Subsystem: "code_cache",
+			Name:      "results_total",
+			Help:      "验证码缓存每种操作的结果",
+		}, []string{"op", "biz", "impl", "result"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "webook",
+			Subsystem: "code_cache",
+			Name:      "duration_seconds",
+			Help:      "验证码缓存每种操作的耗时",
+			// Redis 正常是一毫秒以内，本地的更快，超过一百毫秒基本就是出问题了
+			Buckets: []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 1},
+		}, []string{"op", "biz", "impl", "result"}),
+	}
+	if err := reg.Register(m.results); err != nil {
+		are, ok := err.(prometheus.AlreadyRegisteredError)
+		if !ok {
+			return nil, err
+		}
+		m.results = are.ExistingCollector.(*prometheus.CounterVec)
+	}
+	if err := reg.Register(m.duration); err != nil {
+		are, ok := err.(prometheus.AlreadyRegisteredError)
+		if !ok {
+			return nil, err
+		}
+		m.duration = are.ExistingCollector.(*prometheus.HistogramVec)
+	}
+	return m, nil
+}
+
+// metricsCodeCache 错误原样返回。多出来的开销见 BenchmarkMetricsCodeCache_Peek
+type metricsCodeCache struct {
+	cache   CodeCache
+	impl    string
+	metrics *CodeCacheMetrics
+}
+
+// NewMetricsCodeCache impl 一般是 CodeCacheImplRedis 或者 CodeCacheImplLocal
+func NewMetricsCodeCache(cache CodeCache, impl string, m *CodeCacheMetrics) CodeCache {
+	return &metricsCodeCache{
+		cache:   cache,
+		impl:    impl,
+		metrics: m,
+	}
+}
+
+// NewRedisCodeCacheWithMetrics 跟 NewCodeCacheGoBestPractice 一样，外面包了一层指标
+func NewRedisCodeCacheWithMetrics(c *RedisCodeCache, m *CodeCacheMetrics) CodeCache {
+	return NewMetricsCodeCache(c, CodeCacheImplRedis, m)
+}
+
+// NewLocalCodeCacheWithMetrics 跟 NewLocalCodeCacheWithContext 一样，外面包了一层指标
+func NewLocalCodeCacheWithMetrics(c *LocalCodeCache, m *CodeCacheMetrics) CodeCache {
+	return NewMetricsCodeCache(c, CodeCacheImplLocal, m)
+}
+
+func (c *metricsCodeCache) Set(ctx context.Context, biz, phone, code string) error {
+	start := time.Now()
+	err := c.cache.Set(ctx, biz, phone, code)
+	c.observe("set_code", biz, start, err, "ok")
+	return err
+}
+
+func (c *metricsCodeCache) Verify(ctx context.Context, biz, phone, inputCode string) (bool, error) {
+	res, err := c.VerifyWithResult(ctx, biz, phone, inputCode)
+	return res.OK, err
+}
+
+func (c *metricsCodeCache) VerifyWithResult(ctx context.Context, biz, phone, inputCode string) (VerifyResult, error) {
+	start := time.Now()
+	res, err := c.cache.VerifyWithResult(ctx, biz, phone, inputCode)
+	c.observe("verify_code", biz, start, err, verifyResult(res.OK))
+	return res, err
+}
+
+func (c *metricsCodeCache) Peek(ctx context.Context, biz, phone, inputCode string) (bool, error) {
+	start := time.Now()
+	ok, err := c.cache.Peek(ctx, biz, phone, inputCode)
+	c.observe("peek_code", biz, start, err, verifyResult(ok))
+	return ok, err
+}
+
+func (c *metricsCodeCache) Cooldown(ctx context.Context, biz, phone string) (time.Duration, error) {
+	start := time.Now()
+	remaining, err := c.cache.Cooldown(ctx, biz, phone)
+	c.observe("cooldown", biz, start, err, "ok")
+	return remaining, err
+}
+
+// observe biz 是代码里面写死的那几个，不会把标签撑爆；手机号不能放进来
+func (c *metricsCodeCache) observe(op, biz string, start time.Time, err error, result string) {
+	if err != nil {
+		result = codeCacheErrResult(err)
+	}
+	c.metrics.duration.WithLabelValues(op, biz, c.impl, result).Observe(time.Since(start).Seconds())
+	c.metrics.results.WithLabelValues(op, biz, c.impl, result).Inc()
+}
@@ -0,0 +1,161 @@
+package cache
+
+import (
+	"context"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func TestMetricsCodeCache(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	m, err := NewCodeCacheMetrics(reg)
+	require.NoError(t, err)
+	// 再注册一次，用的还是同一份
+	again, err := NewCodeCacheMetrics(reg)
+	require.NoError(t, err)
+	assert.Same(t, m.results, again.results)
+
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+	local := NewLocalCodeCacheWithContext(context.Background())
+	t.Cleanup(func() { _ = local.Close() })
+	ctx := context.Background()
+
+	for _, c := range []CodeCache{
+		NewRedisCodeCacheWithMetrics(NewCodeCacheGoBestPractice(client), m),
+		NewLocalCodeCacheWithMetrics(local, m),
+	} {
+		require.NoError(t, c.Set(ctx, "login", "152", "123456"))
+		assert.ErrorIs(t, c.Set(ctx, "login", "152", "654321"), ErrCodeSendTooMany)
+		// 输错三次，第四次次数就用完了
+		for i := 0; i < 3; i++ {
+			ok, err := c.Verify(ctx, "login", "152", "000000")
+			require.NoError(t, err)
+			assert.False(t, ok)
+		}
+		_, err = c.Verify(ctx, "login", "152", "123456")
+		assert.Equal(t, ErrCodeVerifyTooManyTimes, err)
+
+		require.NoError(t, c.Set(ctx, "register", "153", "123456"))
+		ok, err := c.Verify(ctx, "register", "153", "123456")
+		require.NoError(t, err)
+		assert.True(t, ok)
+	}
+
+	for _, impl := range []string{CodeCacheImplRedis, CodeCacheImplLocal} {
+		for _, tc := range []struct {
+			op, biz, result string
+			want            float64
+		}{
+			{op: "set_code", biz: "login", result: "ok", want: 1},
+			{op: "set_code", biz: "login", result: "send_too_many", want: 1},
+			{op: "verify_code", biz: "login", result: "mismatch", want: 3},
+			{op: "verify_code", biz: "login", result: "verify_too_many", want: 1},
+			{op: "set_code", biz: "register", result: "ok", want: 1},
+			{op: "verify_code", biz: "register", result: "ok", want: 1},
+		} {
+			got := testutil.ToFloat64(m.results.WithLabelValues(tc.op, tc.biz, impl, tc.result))
+			assert.Equal(t, tc.want, got, "%s %s %s %s", impl, tc.op, tc.biz, tc.result)
+		}
+	}
+
+	// 从 registry 抓一遍，跟 /metrics 看到的一样
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+# HELP webook_code_cache_results_total 验证码缓存每种操作的结果
+# TYPE webook_code_cache_results_total counter
+webook_code_cache_results_total{biz="login",impl="local",op="set_code",result="ok"} 1
+webook_code_cache_results_total{biz="login",impl="local",op="set_code",result="send_too_many"} 1
+webook_code_cache_results_total{biz="login",impl="local",op="verify_code",result="mismatch"} 3
+webook_code_cache_results_total{biz="login",impl="local",op="verify_code",result="verify_too_many"} 1
+webook_code_cache_results_total{biz="login",impl="redis",op="set_code",result="ok"} 1
+webook_code_cache_results_total{biz="login",impl="redis",op="set_code",result="send_too_many"} 1
+webook_code_cache_results_total{biz="login",impl="redis",op="verify_code",result="mismatch"} 3
+webook_code_cache_results_total{biz="login",impl="redis",op="verify_code",result="verify_too_many"} 1
+webook_code_cache_results_total{biz="register",impl="local",op="set_code",result="ok"} 1
+webook_code_cache_results_total{biz="register",impl="local",op="verify_code",result="ok"} 1
+webook_code_cache_results_total{biz="register",impl="redis",op="set_code",result="ok"} 1
+webook_code_cache_results_total{biz="register",impl="redis",op="verify_code",result="ok"} 1
+`), "webook_code_cache_results_total"))
+	// 每种 op、biz、impl、result 一个直方图
+	assert.Equal(t, 12, testutil.CollectAndCount(m.duration))
+}
+
+func TestMetricsCodeCache_SetDuration(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	m, err := NewCodeCacheMetrics(reg)
+	require.NoError(t, err)
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+	c := NewRedisCodeCacheWithMetrics(NewCodeCacheGoBestPractice(client), m)
+	ctx := context.Background()
+
+	require.NoError(t, c.Set(ctx, "login", "152", "123456"))
+	assert.ErrorIs(t, c.Set(ctx, "login", "152", "654321"), ErrCodeSendTooMany)
+	assert.Equal(t, 2, testutil.CollectAndCount(m.duration))
+
+	mfs, err := reg.Gather()
+	require.NoError(t, err)
+	counts := make(map[string]uint64)
+	for _, mf := range mfs {
+		if mf.GetName() != "webook_code_cache_duration_seconds" {
+			continue
+		}
+		for _, metric := range mf.GetMetric() {
+			labels := make(map[string]string)
+			for _, l := range metric.GetLabel() {
+				labels[l.GetName()] = l.GetValue()
+			}
+			assert.Equal(t, "set_code", labels["op"])
+			assert.Equal(t, "login", labels["biz"])
+			assert.Equal(t, CodeCacheImplRedis, labels["impl"])
+			counts[labels["result"]] = metric.GetHistogram().GetSampleCount()
+		}
+	}
+	assert.Equal(t, map[string]uint64{"ok": 1, "send_too_many": 1}, counts)
+}
+
+func TestMetricsCodeCache_Error(t *testing.T) {
+	m, err := NewCodeCacheMetrics(prometheus.NewRegistry())
+	require.NoError(t, err)
+	// 连不上 Redis，错误要原样返回
+	cmd := &downCmdable{}
+	cmd.down.Store(true)
+	c := NewRedisCodeCacheWithMetrics(NewCodeCacheGoBestPractice(cmd), m)
+	ctx := context.Background()
+
+	err = c.Set(ctx, "login", "152", "123456")
+	assert.Equal(t, cmd.err(), err)
+	_, err = c.Cooldown(ctx, "login", "152")
+	assert.Equal(t, cmd.err(), err)
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.results.WithLabelValues("set_code", "login", "redis", "error")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.results.WithLabelValues("cooldown", "login", "redis", "error")))
+}
+
+// BenchmarkMetricsCodeCache_Peek 多出来的是找标签和记直方图，一次几百纳秒，跟 Redis 的往返比可以忽略：
+//
+// go test -bench MetricsCodeCache -run ^$ ./internal/repository/cache/
+func BenchmarkMetricsCodeCache_Peek(b *testing.B) {
+	m, err := NewCodeCacheMetrics(prometheus.NewRegistry())
+	require.NoError(b, err)
+	local := NewLocalCodeCacheWithContext(context.Background())
+	b.Cleanup(func() { _ = local.Close() })
+	ctx := context.Background()
+	for name, c := range map[string]CodeCache{
+		"raw":     local,
+		"metrics": NewLocalCodeCacheWithMetrics(local, m),
+	} {
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				_, _ = c.Peek(ctx, "login", "152", "123456")
+			}
+		})
+	}
+}
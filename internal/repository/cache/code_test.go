@@ -3,10 +3,17 @@ package cache
 import (
 	"context"
 	"errors"
+	"github.com/alicebob/miniredis/v2"
 	"github.com/redis/go-redis/v9"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/goleak"
 	"go.uber.org/mock/gomock"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 	"webook/internal/repository/cache/redismocks"
 )
 
@@ -31,7 +38,7 @@ func TestRedisCodeCache_Set(t *testing.T) {
 				res.SetVal(int64(0))
 				cmd.EXPECT().Eval(gomock.Any(), luaSetCode,
 					[]string{"phone_code:login:152"},
-					[]any{"123456"},
+					[]any{"123456", int64(600), int64(60), 3},
 				).Return(res)
 				return cmd
 			},
@@ -50,7 +57,7 @@ func TestRedisCodeCache_Set(t *testing.T) {
 				//res.SetVal(int64(0))
 				cmd.EXPECT().Eval(gomock.Any(), luaSetCode,
 					[]string{"phone_code:login:152"},
-					[]any{"123456"},
+					[]any{"123456", int64(600), int64(60), 3},
 				).Return(res)
 				return cmd
 			},
@@ -68,10 +75,11 @@ func TestRedisCodeCache_Set(t *testing.T) {
 				cmd := redismocks.NewMockCmdable(ctrl)
 				res := redis.NewCmd(context.Background())
 				//res.SetErr(nil)
-				res.SetVal(int64(-1))
+				// 还要等 37 秒
+				res.SetVal([]any{int64(-1), int64(37)})
 				cmd.EXPECT().Eval(gomock.Any(), luaSetCode,
 					[]string{"phone_code:login:152"},
-					[]any{"123456"},
+					[]any{"123456", int64(600), int64(60), 3},
 				).Return(res)
 				return cmd
 			},
@@ -81,7 +89,7 @@ func TestRedisCodeCache_Set(t *testing.T) {
 			phone: "152",
 			code:  "123456",
 
-			wantErr: ErrCodeSendTooMany,
+			wantErr: &CodeSendTooManyError{RetryAfter: 37 * time.Second},
 		},
 		{
 			name: "系统错误",
@@ -92,7 +100,7 @@ func TestRedisCodeCache_Set(t *testing.T) {
 				res.SetVal(int64(-10))
 				cmd.EXPECT().Eval(gomock.Any(), luaSetCode,
 					[]string{"phone_code:login:152"},
-					[]any{"123456"},
+					[]any{"123456", int64(600), int64(60), 3},
 				).Return(res)
 				return cmd
 			},
@@ -115,3 +123,951 @@ func TestRedisCodeCache_Set(t *testing.T) {
 		})
 	}
 }
+
+func TestRedisCodeCache_Peek(t *testing.T) {
+	testCases := []struct {
+		name string
+		mock func(ctrl *gomock.Controller) redis.Cmdable
+		// 同一个验证码 Peek 几次
+		times int
+
+		wantOk  bool
+		wantErr error
+	}{
+		{
+			name: "验证码正确",
+			mock: func(ctrl *gomock.Controller) redis.Cmdable {
+				cmd := redismocks.NewMockCmdable(ctrl)
+				res := redis.NewCmd(context.Background())
+				res.SetVal(int64(0))
+				cmd.EXPECT().Eval(gomock.Any(), luaPeekCode,
+					[]string{"phone_code:login:152"},
+					[]any{"123456"},
+				).Return(res)
+				return cmd
+			},
+			times:  1,
+			wantOk: true,
+		},
+		{
+			name: "错三次也不会触发验证次数太多",
+			mock: func(ctrl *gomock.Controller) redis.Cmdable {
+				cmd := redismocks.NewMockCmdable(ctrl)
+				res := redis.NewCmd(context.Background())
+				res.SetVal(int64(-2))
+				// 只会执行 peek 的脚本，不会去执行 verify 的脚本
+				cmd.EXPECT().Eval(gomock.Any(), luaPeekCode,
+					[]string{"phone_code:login:152"},
+					[]any{"123456"},
+				).Return(res).Times(3)
+				return cmd
+			},
+			times: 3,
+		},
+		{
+			name: "错太多次了",
+			mock: func(ctrl *gomock.Controller) redis.Cmdable {
+				cmd := redismocks.NewMockCmdable(ctrl)
+				res := redis.NewCmd(context.Background())
+				res.SetVal(int64(-1))
+				cmd.EXPECT().Eval(gomock.Any(), luaPeekCode,
+					[]string{"phone_code:login:152"},
+					[]any{"123456"},
+				).Return(res)
+				return cmd
+			},
+			times:   1,
+			wantErr: ErrCodeVerifyTooManyTimes,
+		},
+		{
+			name: "redis错误",
+			mock: func(ctrl *gomock.Controller) redis.Cmdable {
+				cmd := redismocks.NewMockCmdable(ctrl)
+				res := redis.NewCmd(context.Background())
+				res.SetErr(errors.New("mock redis 错误"))
+				cmd.EXPECT().Eval(gomock.Any(), luaPeekCode,
+					[]string{"phone_code:login:152"},
+					[]any{"123456"},
+				).Return(res)
+				return cmd
+			},
+			times:   1,
+			wantErr: errors.New("mock redis 错误"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			c := NewCodeCacheGoBestPractice(tc.mock(ctrl))
+			for i := 0; i < tc.times; i++ {
+				ok, err := c.Peek(context.Background(), "login", "152", "123456")
+				assert.Equal(t, tc.wantErr, err)
+				assert.Equal(t, tc.wantOk, ok)
+			}
+		})
+	}
+}
+
+// TestPeekCodeLuaReadOnly peek 的脚本只能读，不能改验证次数
+func TestPeekCodeLuaReadOnly(t *testing.T) {
+	for _, cmd := range []string{`"set"`, `"decr"`, `"incr"`, `"del"`, `"expire"`} {
+		assert.NotContains(t, luaPeekCode, cmd)
+	}
+}
+
+func TestLocalCodeCache_Peek(t *testing.T) {
+	ctx := context.Background()
+	c := NewLocalCodeCacheWithContext(ctx)
+	defer c.Close()
+	err := c.Set(ctx, "login", "152", "123456")
+	assert.NoError(t, err)
+
+	// 错三次也不会扣减验证次数
+	for i := 0; i < 3; i++ {
+		ok, err := c.Peek(ctx, "login", "152", "654321")
+		assert.NoError(t, err)
+		assert.False(t, ok)
+	}
+	ok, err := c.Peek(ctx, "login", "152", "123456")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	// Peek 过之后照样能正常验证
+	ok, err = c.Verify(ctx, "login", "152", "123456")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	// 用过了就删掉了，Peek 也不行了
+	ok, err = c.Peek(ctx, "login", "152", "123456")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	// 不存在的
+	ok, err = c.Peek(ctx, "login", "153", "123456")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestLocalCodeCache_VerifyConcurrent(t *testing.T) {
+	const n = 1000
+	testCases := []struct {
+		name string
+		// 第 i 个 goroutine 输的验证码
+		input func(i int) string
+		// 验证通过的、输错的个数，剩下的都是 ErrCodeVerifyTooManyTimes。
+		// 用掉之后验证码就删了，再来的都算输错
+		check func(t *testing.T, okCnt, missCnt int64)
+	}{
+		{
+			name:  "一起输错，只能扣三次",
+			input: func(i int) string { return "654321" },
+			check: func(t *testing.T, okCnt, missCnt int64) {
+				assert.Equal(t, int64(0), okCnt)
+				assert.Equal(t, int64(3), missCnt)
+			},
+		},
+		{
+			name:  "一起输对，只有一个能用上",
+			input: func(i int) string { return "123456" },
+			check: func(t *testing.T, okCnt, missCnt int64) {
+				assert.Equal(t, int64(1), okCnt)
+				assert.Equal(t, int64(n-1), missCnt)
+			},
+		},
+		{
+			// 谁先抢到不一定，可能三次都被输错的用掉了
+			name: "有对有错",
+			input: func(i int) string {
+				if i%2 == 0 {
+					return "123456"
+				}
+				return "654321"
+			},
+			check: func(t *testing.T, okCnt, missCnt int64) {
+				assert.LessOrEqual(t, okCnt, int64(1))
+				if okCnt == 0 {
+					assert.Equal(t, int64(3), missCnt)
+				}
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			c := NewLocalCodeCacheWithContext(ctx)
+			defer c.Close()
+			assert.NoError(t, c.Set(ctx, "login", "152", "123456"))
+
+			var okCnt, missCnt, tooManyCnt atomic.Int64
+			var wg sync.WaitGroup
+			for i := 0; i < n; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					ok, err := c.Verify(ctx, "login", "152", tc.input(i))
+					switch {
+					case ok:
+						okCnt.Add(1)
+					case err == nil:
+						missCnt.Add(1)
+					case err == ErrCodeVerifyTooManyTimes:
+						tooManyCnt.Add(1)
+					}
+				}(i)
+			}
+			wg.Wait()
+			tc.check(t, okCnt.Load(), missCnt.Load())
+			assert.Equal(t, int64(n), okCnt.Load()+missCnt.Load()+tooManyCnt.Load())
+			// 用完了之后输对了也不行
+			ok, _ := c.Verify(ctx, "login", "152", "123456")
+			assert.False(t, ok)
+		})
+	}
+}
+
+// 同一个手机号一起发，只能有一个成功，存下来的也是成功的那个。
+// 要带上 -race 跑
+func TestLocalCodeCache_SetConcurrent(t *testing.T) {
+	const n = 1000
+	ctx := context.Background()
+	c := NewLocalCodeCacheWithContext(ctx)
+	defer c.Close()
+
+	var okCnt atomic.Int64
+	var winner atomic.Value
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			code := strconv.Itoa(100000 + i)
+			err := c.Set(ctx, "login", "152", code)
+			if err == nil {
+				okCnt.Add(1)
+				winner.Store(code)
+				return
+			}
+			assert.ErrorIs(t, err, ErrCodeSendTooMany)
+			// 一边发一边查冷却时间、验证
+			_, err = c.Cooldown(ctx, "login", "152")
+			assert.NoError(t, err)
+			_, _ = c.Peek(ctx, "login", "152", code)
+		}(i)
+	}
+	wg.Wait()
+	require.Equal(t, int64(1), okCnt.Load())
+	ok, err := c.Verify(ctx, "login", "152", winner.Load().(string))
+	require.NoError(t, err)
+	assert.True(t, ok)
+}
+
+func TestLocalCodeCache_NoLeak(t *testing.T) {
+	defer goleak.VerifyNone(t)
+	for i := 0; i < 100; i++ {
+		if i%2 == 0 {
+			// 一半用 Close 关掉
+			c := NewLocalCodeCacheWithContext(context.Background())
+			assert.NoError(t, c.Close())
+			// 重复关闭也没问题
+			assert.NoError(t, c.Close())
+			continue
+		}
+		// 一半用 ctx 取消
+		ctx, cancel := context.WithCancel(context.Background())
+		c := NewLocalCodeCacheWithContext(ctx)
+		cancel()
+		<-c.done
+	}
+}
+
+// load 测试里面直接看存着的验证码，过期了的也能看到
+func (c *LocalCodeCache) load(key string) (*localCodeCacheValue, bool) {
+	s := c.shard(key)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.get(key)
+}
+
+// store 直接放进去，不会顺便清理过期的，也不会淘汰
+func (c *LocalCodeCache) store(key string, value *localCodeCacheValue) {
+	s := c.shard(key)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.items[key] = s.lru.PushFront(&localCodeCacheEntry{key: key, value: value})
+}
+
+func TestLocalCodeCache_Purge(t *testing.T) {
+	c := NewLocalCodeCacheWithContext(context.Background(), WithPurgeInterval(time.Millisecond*10))
+	defer c.Close()
+	c.store("expired", &localCodeCacheValue{
+		code:       "123456",
+		expiration: time.Now().Add(-time.Second),
+	})
+	c.store("valid", &localCodeCacheValue{
+		code:       "123456",
+		expiration: time.Now().Add(time.Minute),
+	})
+	assert.Eventually(t, func() bool {
+		_, ok := c.load("expired")
+		return !ok
+	}, time.Second, time.Millisecond*10)
+	_, ok := c.load("valid")
+	assert.True(t, ok)
+}
+
+// 运行时改了配置，下一次 Set 就要用新的配置
+func TestRedisCodeCache_SetOptions(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	cmd := redismocks.NewMockCmdable(ctrl)
+	ok := redis.NewCmd(context.Background())
+	ok.SetVal(int64(0))
+	gomock.InOrder(
+		cmd.EXPECT().Eval(gomock.Any(), luaSetCode, []string{"phone_code:login:152"},
+			[]any{"123456", int64(600), int64(60), 3}).Return(ok),
+		cmd.EXPECT().Eval(gomock.Any(), luaSetCode, []string{"phone_code:login:152"},
+			[]any{"654321", int64(300), int64(120), 5}).Return(ok),
+	)
+	c := NewCodeCacheGoBestPractice(cmd)
+	assert.Equal(t, DefaultCodeCacheOptions(), c.Options())
+	assert.NoError(t, c.Set(context.Background(), "login", "152", "123456"))
+
+	opts := CodeCacheOptions{
+		TTL:               time.Minute * 5,
+		Cooldown:          time.Minute * 2,
+		MaxVerifyAttempts: 5,
+	}
+	assert.NoError(t, c.SetOptions(opts))
+	assert.Equal(t, opts, c.Options())
+	assert.NoError(t, c.Set(context.Background(), "login", "152", "654321"))
+
+	// 不合法的配置不会生效
+	assert.Equal(t, ErrInvalidCodeCacheOptions, c.SetOptions(CodeCacheOptions{
+		TTL:               time.Minute,
+		Cooldown:          time.Minute * 2,
+		MaxVerifyAttempts: 3,
+	}))
+	assert.Equal(t, ErrInvalidCodeCacheOptions, c.SetOptions(CodeCacheOptions{
+		TTL:      time.Minute,
+		Cooldown: time.Second,
+	}))
+	assert.Equal(t, opts, c.Options())
+}
+
+// 本地缓存比较验证码要用 subtle.ConstantTimeCompare，耗时跟猜对了几位没有关系。
+// 这里没法稳定地测耗时，只保证结果是对的
+func TestCodeEqual(t *testing.T) {
+	testCases := []struct {
+		name      string
+		code      string
+		inputCode string
+		want      bool
+	}{
+		{name: "相等", code: "123456", inputCode: "123456", want: true},
+		{name: "最后一位不对", code: "123456", inputCode: "123457"},
+		{name: "第一位不对", code: "123456", inputCode: "023456"},
+		{name: "长度不一样", code: "123456", inputCode: "12345"},
+		{name: "前缀不算", code: "123456", inputCode: "1234567"},
+		{name: "空的", code: "123456", inputCode: ""},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, codeEqual(tc.code, tc.inputCode))
+		})
+	}
+}
+
+func TestRedisCodeCache_Cooldown(t *testing.T) {
+	testCases := []struct {
+		name string
+		// lua 脚本算出来的秒数
+		seconds int64
+		err     error
+
+		wantRemaining time.Duration
+		wantErr       error
+	}{
+		{
+			name:          "还要等",
+			seconds:       61,
+			wantRemaining: 61 * time.Second,
+		},
+		{
+			name: "可以发了",
+		},
+		{
+			name:    "redis错误",
+			err:     errors.New("mock redis 错误"),
+			wantErr: errors.New("mock redis 错误"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			cmd := redismocks.NewMockCmdable(ctrl)
+			res := redis.NewCmd(context.Background())
+			if tc.err != nil {
+				res.SetErr(tc.err)
+			} else {
+				res.SetVal(tc.seconds)
+			}
+			cmd.EXPECT().Eval(gomock.Any(), luaCodeCooldown, []string{"phone_code:login:152"},
+				int64(600), int64(60)).Return(res)
+			remaining, err := NewCodeCacheGoBestPractice(cmd).Cooldown(context.Background(), "login", "152")
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.wantRemaining, remaining)
+		})
+	}
+}
+
+// 要本地起一个 Redis，没有的话跳过。
+// 等不了真的一分钟，改 key 的过期时间来假装时间过去了
+func TestRedisCodeCache_Resend(t *testing.T) {
+	client := redis.NewClient(&redis.Options{Addr: "localhost:6379"})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		t.Skip("本地没有 Redis", err)
+	}
+	c := NewCodeCacheGoBestPractice(client)
+	phone := "152" + strconv.FormatInt(time.Now().UnixNano(), 10)
+	key := "phone_code:resend_test:" + phone
+	defer client.Del(context.Background(), key, key+":cnt", key+":resend")
+	// 假装过去了 elapsed 秒
+	elapse := func(elapsed int) {
+		require.NoError(t, client.Expire(ctx, key, time.Duration(600-elapsed)*time.Second).Err())
+	}
+
+	require.NoError(t, c.Set(ctx, "resend_test", phone, "111111"))
+	remaining, err := c.Cooldown(ctx, "resend_test", phone)
+	require.NoError(t, err)
+	assert.Equal(t, 61*time.Second, remaining)
+
+	// 第一次重发要等 60 秒
+	elapse(59)
+	assert.ErrorIs(t, c.Set(ctx, "resend_test", phone, "222222"), ErrCodeSendTooMany)
+	elapse(61)
+	require.NoError(t, c.Set(ctx, "resend_test", phone, "222222"))
+
+	// 第二次要等 120 秒
+	remaining, err = c.Cooldown(ctx, "resend_test", phone)
+	require.NoError(t, err)
+	assert.Equal(t, 121*time.Second, remaining)
+	elapse(119)
+	assert.ErrorIs(t, c.Set(ctx, "resend_test", phone, "333333"), ErrCodeSendTooMany)
+	remaining, err = c.Cooldown(ctx, "resend_test", phone)
+	require.NoError(t, err)
+	assert.Equal(t, 2*time.Second, remaining)
+	elapse(121)
+	require.NoError(t, c.Set(ctx, "resend_test", phone, "333333"))
+
+	// 旧的验证码不能用了
+	ok, err := c.Verify(ctx, "resend_test", phone, "222222")
+	require.NoError(t, err)
+	assert.False(t, ok)
+	ok, err = c.Verify(ctx, "resend_test", phone, "333333")
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	// 过期之后重新开始算
+	require.NoError(t, client.Del(ctx, key).Err())
+	require.NoError(t, c.Set(ctx, "resend_test", phone, "444444"))
+	remaining, err = c.Cooldown(ctx, "resend_test", phone)
+	require.NoError(t, err)
+	assert.Equal(t, 61*time.Second, remaining)
+}
+
+func TestLocalCodeCache_Resend(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := NewLocalCodeCacheWithContext(ctx)
+	// 假装过去了 elapsed 秒
+	elapse := func(elapsed int64) {
+		val, ok := c.load(c.key("login", "152"))
+		require.True(t, ok)
+		val.expiration = time.Now().Add(time.Duration(600-elapsed) * time.Second)
+	}
+
+	require.NoError(t, c.Set(ctx, "login", "152", "111111"))
+	elapse(61)
+	require.NoError(t, c.Set(ctx, "login", "152", "222222"))
+	// 第二次要等两分钟
+	elapse(61)
+	assert.ErrorIs(t, c.Set(ctx, "login", "152", "333333"), ErrCodeSendTooMany)
+	remaining, err := c.Cooldown(ctx, "login", "152")
+	require.NoError(t, err)
+	assert.Equal(t, 60*time.Second, remaining)
+	elapse(121)
+	require.NoError(t, c.Set(ctx, "login", "152", "333333"))
+
+	// 重发之后旧的就不能用了
+	ok, err := c.Verify(ctx, "login", "152", "222222")
+	require.NoError(t, err)
+	assert.False(t, ok)
+	ok, err = c.Verify(ctx, "login", "152", "333333")
+	require.NoError(t, err)
+	assert.True(t, ok)
+}
+
+// 两种实现验证通过之后都要把验证码删掉，再验证一次跟输错了一样
+func TestCodeCache_VerifyDeletes(t *testing.T) {
+	testCases := []struct {
+		name string
+		// exists 验证码还在不在
+		newCache func(t *testing.T) (c CodeCache, exists func(key string) bool)
+	}{
+		{
+			name: "Redis",
+			newCache: func(t *testing.T) (CodeCache, func(key string) bool) {
+				mr := miniredis.RunT(t)
+				client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+				return NewCodeCacheGoBestPractice(client), func(key string) bool {
+					return mr.Exists(key) || mr.Exists(key+":cnt") || mr.Exists(key+":resend")
+				}
+			},
+		},
+		{
+			name: "本地",
+			newCache: func(t *testing.T) (CodeCache, func(key string) bool) {
+				c := NewLocalCodeCacheWithContext(context.Background())
+				t.Cleanup(func() { _ = c.Close() })
+				return c, func(key string) bool {
+					_, ok := c.load(key)
+					return ok
+				}
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			c, exists := tc.newCache(t)
+			// 这个实现输错了是什么结果
+			require.NoError(t, c.Set(ctx, "login", "153", "123456"))
+			wantOk, wantErr := c.Verify(ctx, "login", "153", "654321")
+			assert.False(t, wantOk)
+
+			require.NoError(t, c.Set(ctx, "login", "152", "123456"))
+			ok, err := c.Verify(ctx, "login", "152", "123456")
+			require.NoError(t, err)
+			assert.True(t, ok)
+			assert.False(t, exists("phone_code:login:152"))
+
+			// 再用一次，不是验证通过，也不是验证次数太多
+			ok, err = c.Verify(ctx, "login", "152", "123456")
+			assert.Equal(t, wantOk, ok)
+			assert.Equal(t, wantErr, err)
+			ok, err = c.Peek(ctx, "login", "152", "123456")
+			assert.NoError(t, err)
+			assert.False(t, ok)
+
+			// 删掉了就可以马上再发
+			assert.NoError(t, c.Set(ctx, "login", "152", "111111"))
+		})
+	}
+}
+
+// codeCacheImpls 两种实现，跑同一套测试用的。elapse 假装时间过去了 d
+var codeCacheImpls = []struct {
+	name     string
+	newCache func(t *testing.T, opts ...CodeCacheOption) (c CodeCache, elapse func(d time.Duration))
+}{
+	{
+		name: "Redis",
+		newCache: func(t *testing.T, opts ...CodeCacheOption) (CodeCache, func(d time.Duration)) {
+			mr := miniredis.RunT(t)
+			client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+			redisOpts := make([]RedisCodeCacheOption, 0, len(opts))
+			for _, opt := range opts {
+				redisOpts = append(redisOpts, opt)
+			}
+			return NewCodeCacheGoBestPractice(client, redisOpts...), mr.FastForward
+		},
+	},
+	{
+		name: "本地",
+		newCache: func(t *testing.T, opts ...CodeCacheOption) (CodeCache, func(d time.Duration)) {
+			clock := newFakeClock()
+			localOpts := []LocalCodeCacheOption{WithClock(clock.Now)}
+			for _, opt := range opts {
+				localOpts = append(localOpts, opt)
+			}
+			c := NewLocalCodeCacheWithContext(context.Background(), localOpts...)
+			t.Cleanup(func() { _ = c.Close() })
+			return c, clock.Advance
+		},
+	},
+}
+
+// 冷却期内 Set 带回来的等待时间跟 Cooldown 查出来的一样，前端拿来倒计时
+func TestCodeCache_SetRetryAfter(t *testing.T) {
+	for _, tc := range codeCacheImpls {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			c, elapse := tc.newCache(t)
+			require.NoError(t, c.Set(ctx, "login", "152", "111111"))
+			elapse(23 * time.Second)
+
+			err := c.Set(ctx, "login", "152", "222222")
+			assert.ErrorIs(t, err, ErrCodeSendTooMany)
+			var tooMany *CodeSendTooManyError
+			require.ErrorAs(t, err, &tooMany)
+			assert.Equal(t, 38*time.Second, tooMany.RetryAfter)
+			remaining, err := c.Cooldown(ctx, "login", "152")
+			require.NoError(t, err)
+			assert.Equal(t, remaining, tooMany.RetryAfter)
+		})
+	}
+}
+
+func TestCodeCache_VerifyWithResult(t *testing.T) {
+	for _, tc := range codeCacheImpls {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			c, _ := tc.newCache(t)
+			// 没发过的不知道还剩几次
+			res, err := c.VerifyWithResult(ctx, "login", "152", "000000")
+			require.NoError(t, err)
+			assert.Equal(t, VerifyResult{RemainingAttempts: -1}, res)
+
+			require.NoError(t, c.Set(ctx, "login", "152", "123456"))
+			for _, want := range []int{2, 1, 0} {
+				res, err = c.VerifyWithResult(ctx, "login", "152", "000000")
+				require.NoError(t, err)
+				assert.Equal(t, VerifyResult{RemainingAttempts: want}, res)
+			}
+			// 用完了，输对了也不行
+			res, err = c.VerifyWithResult(ctx, "login", "152", "123456")
+			assert.Equal(t, ErrCodeVerifyTooManyTimes, err)
+			assert.False(t, res.OK)
+
+			require.NoError(t, c.Set(ctx, "register", "152", "123456"))
+			res, err = c.VerifyWithResult(ctx, "register", "152", "000000")
+			require.NoError(t, err)
+			assert.Equal(t, VerifyResult{RemainingAttempts: 2}, res)
+			res, err = c.VerifyWithResult(ctx, "register", "152", "123456")
+			require.NoError(t, err)
+			assert.True(t, res.OK)
+			// 用掉了就跟没发过一样
+			ok, err := c.Verify(ctx, "register", "152", "123456")
+			require.NoError(t, err)
+			assert.False(t, ok)
+		})
+	}
+}
+
+// 两种实现用同一套非默认的配置，结果要完全一样
+func TestCodeCache_Options(t *testing.T) {
+	for _, tc := range codeCacheImpls {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			// 两分钟过期，第一次重发要等 20 秒
+			c, elapse := tc.newCache(t, WithTTL(2*time.Minute), WithResendInterval(20*time.Second))
+			cooldown := func(want time.Duration) {
+				t.Helper()
+				remaining, err := c.Cooldown(ctx, "login", "152")
+				require.NoError(t, err)
+				assert.Equal(t, want, remaining)
+			}
+
+			require.NoError(t, c.Set(ctx, "login", "152", "111111"))
+			cooldown(21 * time.Second)
+			elapse(20 * time.Second)
+			assert.ErrorIs(t, c.Set(ctx, "login", "152", "222222"), ErrCodeSendTooMany)
+			cooldown(time.Second)
+			elapse(time.Second)
+			require.NoError(t, c.Set(ctx, "login", "152", "222222"))
+
+			// 第二次重发要等 40 秒
+			cooldown(41 * time.Second)
+			elapse(40 * time.Second)
+			assert.ErrorIs(t, c.Set(ctx, "login", "152", "333333"), ErrCodeSendTooMany)
+			elapse(time.Second)
+			require.NoError(t, c.Set(ctx, "login", "152", "333333"))
+
+			// 两分钟之后过期了，不能验证，重发也重新开始算
+			elapse(2*time.Minute + time.Second)
+			cooldown(0)
+			ok, _ := c.Verify(ctx, "login", "152", "333333")
+			assert.False(t, ok)
+			require.NoError(t, c.Set(ctx, "login", "152", "444444"))
+			cooldown(21 * time.Second)
+			ok, err := c.Verify(ctx, "login", "152", "444444")
+			require.NoError(t, err)
+			assert.True(t, ok)
+
+			// 重发间隔要比过期时间短
+			assert.Panics(t, func() {
+				tc.newCache(t, WithTTL(time.Minute), WithResendInterval(time.Minute))
+			})
+		})
+	}
+}
+
+// 两个实例共用一个 Redis，前缀不一样的话配置互不影响
+func TestRedisCodeCache_Independent(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	fast := NewCodeCacheGoBestPractice(client, WithKeyPrefix("fast_code"),
+		WithCooldown(10*time.Second), WithMaxAttempts(1))
+	slow := NewCodeCacheGoBestPractice(client, WithKeyPrefix("slow_code"),
+		WithCooldown(30*time.Second), WithTTL(5*time.Minute))
+	ctx := context.Background()
+
+	require.NoError(t, fast.Set(ctx, "login", "152", "111111"))
+	require.NoError(t, slow.Set(ctx, "login", "152", "111111"))
+	assert.True(t, mr.Exists("fast_code:login:152"))
+	assert.True(t, mr.Exists("slow_code:login:152"))
+	assert.Equal(t, 10*time.Minute, mr.TTL("fast_code:login:152"))
+	assert.Equal(t, 5*time.Minute, mr.TTL("slow_code:login:152"))
+
+	mr.FastForward(15 * time.Second)
+	require.NoError(t, fast.Set(ctx, "login", "152", "222222"))
+	assert.ErrorIs(t, slow.Set(ctx, "login", "152", "222222"), ErrCodeSendTooMany)
+
+	// fast 只能验证一次，slow 输错了还能再试
+	ok, err := fast.Verify(ctx, "login", "152", "000000")
+	require.NoError(t, err)
+	assert.False(t, ok)
+	_, err = fast.Verify(ctx, "login", "152", "222222")
+	assert.Equal(t, ErrCodeVerifyTooManyTimes, err)
+	ok, err = slow.Verify(ctx, "login", "152", "000000")
+	require.NoError(t, err)
+	assert.False(t, ok)
+	ok, err = slow.Verify(ctx, "login", "152", "111111")
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	assert.Panics(t, func() {
+		NewCodeCacheGoBestPractice(client, WithMaxAttempts(0))
+	})
+}
+
+// codeCacheStep 一步操作，elapse 不为 0 的时候只是让时间过去这么久
+type codeCacheStep struct {
+	op     string
+	code   string
+	elapse time.Duration
+
+	wantOk  bool
+	wantErr error
+}
+
+// 两种实现的返回值要完全一样：输错了、没发过、过期了、已经用掉了都是 (false, nil)，
+// 只有验证次数用完了和真的出错了才返回 error
+func TestCodeCache_Conformance(t *testing.T) {
+	set := func(code string) codeCacheStep {
+		return codeCacheStep{op: "set", code: code}
+	}
+	verify := func(code string, wantOk bool, wantErr error) codeCacheStep {
+		return codeCacheStep{op: "verify", code: code, wantOk: wantOk, wantErr: wantErr}
+	}
+	peek := func(code string, wantOk bool, wantErr error) codeCacheStep {
+		return codeCacheStep{op: "peek", code: code, wantOk: wantOk, wantErr: wantErr}
+	}
+	elapse := func(d time.Duration) codeCacheStep {
+		return codeCacheStep{elapse: d}
+	}
+	testCases := []struct {
+		name  string
+		steps []codeCacheStep
+	}{
+		{
+			name:  "输对了",
+			steps: []codeCacheStep{set("111111"), verify("111111", true, nil)},
+		},
+		{
+			name: "已经用掉了",
+			steps: []codeCacheStep{set("111111"), verify("111111", true, nil),
+				verify("111111", false, nil), peek("111111", false, nil)},
+		},
+		{
+			name: "输错了还能再试",
+			steps: []codeCacheStep{set("111111"), verify("222222", false, nil),
+				verify("111111", true, nil)},
+		},
+		{
+			name: "输错三次",
+			steps: []codeCacheStep{set("111111"),
+				verify("222222", false, nil), verify("222222", false, nil), verify("222222", false, nil),
+				verify("111111", false, ErrCodeVerifyTooManyTimes),
+				peek("111111", false, ErrCodeVerifyTooManyTimes)},
+		},
+		{
+			name:  "没发过",
+			steps: []codeCacheStep{verify("111111", false, nil), peek("111111", false, nil)},
+		},
+		{
+			name: "过期了",
+			steps: []codeCacheStep{set("111111"), elapse(10*time.Minute + time.Second),
+				verify("111111", false, nil), peek("111111", false, nil)},
+		},
+		{
+			name: "重发之后旧的不能用",
+			steps: []codeCacheStep{set("111111"), elapse(61 * time.Second), set("222222"),
+				verify("111111", false, nil), verify("222222", true, nil)},
+		},
+		{
+			name: "Peek 不扣次数",
+			steps: []codeCacheStep{set("111111"),
+				peek("222222", false, nil), peek("222222", false, nil), peek("222222", false, nil),
+				peek("111111", true, nil), verify("111111", true, nil)},
+		},
+	}
+	for _, impl := range codeCacheImpls {
+		t.Run(impl.name, func(t *testing.T) {
+			for _, tc := range testCases {
+				t.Run(tc.name, func(t *testing.T) {
+					ctx := context.Background()
+					c, elapseFn := impl.newCache(t)
+					for i, step := range tc.steps {
+						var (
+							ok  bool
+							err error
+						)
+						switch {
+						case step.elapse > 0:
+							elapseFn(step.elapse)
+							continue
+						case step.op == "set":
+							require.NoError(t, c.Set(ctx, "login", "152", step.code), "第 %d 步", i)
+							continue
+						case step.op == "verify":
+							ok, err = c.Verify(ctx, "login", "152", step.code)
+						case step.op == "peek":
+							ok, err = c.Peek(ctx, "login", "152", step.code)
+						}
+						assert.Equal(t, step.wantErr, err, "第 %d 步", i)
+						assert.Equal(t, step.wantOk, ok, "第 %d 步", i)
+					}
+				})
+			}
+		})
+	}
+}
+
+// fakeClock 给 WithClock 用，只有调 Advance 时间才会走
+type fakeClock struct {
+	mutex sync.Mutex
+	now   time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Now()}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// 过期了还没被清理掉的验证码跟没有一样：可以马上重发，重发次数也从头算
+func TestCodeCache_ExpiredResend(t *testing.T) {
+	for _, impl := range codeCacheImpls {
+		t.Run(impl.name, func(t *testing.T) {
+			ctx := context.Background()
+			c, elapse := impl.newCache(t)
+			require.NoError(t, c.Set(ctx, "login", "152", "111111"))
+			elapse(61 * time.Second)
+			require.NoError(t, c.Set(ctx, "login", "152", "222222"))
+			remaining, err := c.Cooldown(ctx, "login", "152")
+			require.NoError(t, err)
+			assert.Equal(t, 121*time.Second, remaining)
+
+			elapse(10*time.Minute + time.Second)
+			remaining, err = c.Cooldown(ctx, "login", "152")
+			require.NoError(t, err)
+			assert.Equal(t, time.Duration(0), remaining)
+			ok, err := c.Verify(ctx, "login", "152", "222222")
+			require.NoError(t, err)
+			assert.False(t, ok)
+			require.NoError(t, c.Set(ctx, "login", "152", "333333"))
+			// 第一次发，只要等一个冷却期
+			remaining, err = c.Cooldown(ctx, "login", "152")
+			require.NoError(t, err)
+			assert.Equal(t, 61*time.Second, remaining)
+		})
+	}
+}
+
+// 清理也按注入的时钟算
+func TestLocalCodeCache_PurgeClock(t *testing.T) {
+	clock := newFakeClock()
+	c := NewLocalCodeCacheWithContext(context.Background(),
+		WithClock(clock.Now), WithPurgeInterval(10*time.Millisecond))
+	defer c.Close()
+	require.NoError(t, c.Set(context.Background(), "login", "152", "111111"))
+	time.Sleep(50 * time.Millisecond)
+	_, ok := c.load(c.key("login", "152"))
+	assert.True(t, ok)
+
+	clock.Advance(10*time.Minute + time.Second)
+	assert.Eventually(t, func() bool {
+		_, ok := c.load(c.key("login", "152"))
+		return !ok
+	}, time.Second, 10*time.Millisecond)
+}
+
+// 换着手机号一直发，存着的验证码也不会超过上限
+func TestLocalCodeCache_MaxEntries(t *testing.T) {
+	const maxEntries = 1000
+	ctx := context.Background()
+	c := NewLocalCodeCacheWithContext(ctx, WithMaxEntries(maxEntries))
+	defer c.Close()
+	for i := 0; i < maxEntries*10; i++ {
+		require.NoError(t, c.Set(ctx, "login", strconv.Itoa(i), "123456"))
+		require.LessOrEqual(t, c.Len(), maxEntries)
+	}
+	// 都还没过期，多出来的全都是淘汰掉的
+	assert.Equal(t, int64(maxEntries*10-c.Len()), c.Evictions())
+	// 最后发的还在
+	ok, err := c.Verify(ctx, "login", strconv.Itoa(maxEntries*10-1), "123456")
+	require.NoError(t, err)
+	assert.True(t, ok)
+}
+
+func TestLocalCodeCache_Evict(t *testing.T) {
+	ctx := context.Background()
+	clock := newFakeClock()
+	c := NewLocalCodeCacheWithContext(ctx, WithClock(clock.Now))
+	defer c.Close()
+	// 只有一段，淘汰的顺序是确定的
+	c.shards = newLocalCodeCacheShards(1, 3)
+
+	require.NoError(t, c.Set(ctx, "login", "1", "111111"))
+	clock.Advance(time.Minute * 2)
+	require.NoError(t, c.Set(ctx, "login", "2", "222222"))
+	require.NoError(t, c.Set(ctx, "login", "3", "333333"))
+	// 重发过的算是最近用过的
+	require.NoError(t, c.Set(ctx, "login", "1", "444444"))
+	require.NoError(t, c.Set(ctx, "login", "4", "555555"))
+	assert.Equal(t, 3, c.Len())
+	assert.Equal(t, int64(1), c.Evictions())
+	_, ok := c.load(c.key("login", "2"))
+	assert.False(t, ok)
+	ok, err := c.Verify(ctx, "login", "1", "444444")
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	// 过期了的顺手清掉，不算淘汰
+	clock.Advance(time.Minute * 11)
+	require.NoError(t, c.Set(ctx, "login", "5", "666666"))
+	assert.Equal(t, 1, c.Len())
+	assert.Equal(t, int64(1), c.Evictions())
+}
+
+func TestLocalCodeCache_InvalidMaxEntries(t *testing.T) {
+	assert.Panics(t, func() {
+		NewLocalCodeCacheWithContext(context.Background(), WithMaxEntries(0))
+	})
+}
@@ -0,0 +1,120 @@
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"time"
+)
+
+const codeCacheTracerName = "webook/internal/repository/cache"
+
+// tracedCodeCache 给 CodeCache 的每次调用开一个子 span。
+// Lua 脚本在 Redis 里面是原子执行的，没有这一层的话链路上就看不到这次 Redis 调用。
+//
+// 不在 Lua 脚本前面拼 trace ID 注释：脚本内容每次都不一样，
+// Redis 会把每一个都当成新脚本缓存起来，EVALSHA 也用不上
+type tracedCodeCache struct {
+	cache  CodeCache
+	tracer trace.Tracer
+}
+
+// NewTracedCodeCache 可以装饰任意一个 CodeCache，
+// tp 一般传 otel.GetTracerProvider()，没有配置的时候是 noop 的
+func NewTracedCodeCache(cache CodeCache, tp trace.TracerProvider) CodeCache {
+	return &tracedCodeCache{
+		cache:  cache,
+		tracer: tp.Tracer(codeCacheTracerName),
+	}
+}
+
+func (c *tracedCodeCache) Set(ctx context.Context, biz, phone, code string) error {
+	ctx, span := c.start(ctx, "set_code", biz, phone)
+	defer span.End()
+	err := c.cache.Set(ctx, biz, phone, code)
+	c.end(span, err, "ok")
+	return err
+}
+
+func (c *tracedCodeCache) Verify(ctx context.Context, biz, phone, inputCode string) (bool, error) {
+	res, err := c.VerifyWithResult(ctx, biz, phone, inputCode)
+	return res.OK, err
+}
+
+func (c *tracedCodeCache) VerifyWithResult(ctx context.Context, biz, phone, inputCode string) (VerifyResult, error) {
+	ctx, span := c.start(ctx, "verify_code", biz, phone)
+	defer span.End()
+	res, err := c.cache.VerifyWithResult(ctx, biz, phone, inputCode)
+	c.end(span, err, verifyResult(res.OK))
+	return res, err
+}
+
+func (c *tracedCodeCache) Peek(ctx context.Context, biz, phone, inputCode string) (bool, error) {
+	ctx, span := c.start(ctx, "peek_code", biz, phone)
+	defer span.End()
+	ok, err := c.cache.Peek(ctx, biz, phone, inputCode)
+	c.end(span, err, verifyResult(ok))
+	return ok, err
+}
+
+func (c *tracedCodeCache) Cooldown(ctx context.Context, biz, phone string) (time.Duration, error) {
+	ctx, span := c.start(ctx, "cooldown", biz, phone)
+	defer span.End()
+	remaining, err := c.cache.Cooldown(ctx, biz, phone)
+	c.end(span, err, "ok")
+	return remaining, err
+}
+
+// start 手机号是敏感信息，span 里面只放哈希，
+// 用来把同一个手机号的几次调用串起来
+func (c *tracedCodeCache) start(ctx context.Context, op, biz, phone string) (context.Context, trace.Span) {
+	return c.tracer.Start(ctx, "CodeCache."+op,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", "redis"),
+			attribute.String("db.operation", op),
+			attribute.String("phone_biz", biz),
+			attribute.String("phone_hash", PhoneHash(phone)),
+		))
+}
+
+// PhoneHash SHA-256 的前 16 个十六进制字符。
+// 手机号就那么多，穷举一遍就能反查出来，所以只能放在链路、日志这种内部能看到的地方，
+// 不能当成脱敏以后可以随便给出去的数据
+func PhoneHash(phone string) string {
+	sum := sha256.Sum256([]byte(phone))
+	return hex.EncodeToString(sum[:8])
+}
+
+func (c *tracedCodeCache) end(span trace.Span, err error, result string) {
+	if err != nil {
+		result = codeCacheErrResult(err)
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.SetAttributes(attribute.String("code_cache.result", result))
+}
+
+func verifyResult(ok bool) string {
+	if ok {
+		return "ok"
+	}
+	return "mismatch"
+}
+
+// codeCacheErrResult 业务上的错误给一个固定的值，方便按结果聚合。
+// 发送太频繁返回的是 *CodeSendTooManyError，要用 errors.Is
+func codeCacheErrResult(err error) string {
+	switch {
+	case errors.Is(err, ErrCodeSendTooMany):
+		return "send_too_many"
+	case err == ErrCodeVerifyTooManyTimes:
+		return "verify_too_many"
+	default:
+		return "error"
+	}
+}
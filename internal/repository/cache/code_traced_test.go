@@ -0,0 +1,161 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/mock/gomock"
+	"testing"
+	"time"
+	"webook/internal/repository/cache/redismocks"
+)
+
+func TestTracedCodeCache(t *testing.T) {
+	testCases := []struct {
+		name string
+		// 返回 Eval 的结果
+		evalRes func() *redis.Cmd
+		call    func(ctx context.Context, c CodeCache) error
+
+		wantName   string
+		wantOp     string
+		wantResult string
+		wantErr    error
+	}{
+		{
+			name: "Set 成功",
+			evalRes: func() *redis.Cmd {
+				res := redis.NewCmd(context.Background())
+				res.SetVal(int64(0))
+				return res
+			},
+			call: func(ctx context.Context, c CodeCache) error {
+				return c.Set(ctx, "login", "152", "123456")
+			},
+			wantName:   "CodeCache.set_code",
+			wantOp:     "set_code",
+			wantResult: "ok",
+		},
+		{
+			name: "Set 太频繁",
+			evalRes: func() *redis.Cmd {
+				res := redis.NewCmd(context.Background())
+				res.SetVal([]any{int64(-1), int64(60)})
+				return res
+			},
+			call: func(ctx context.Context, c CodeCache) error {
+				return c.Set(ctx, "login", "152", "123456")
+			},
+			wantName:   "CodeCache.set_code",
+			wantOp:     "set_code",
+			wantResult: "send_too_many",
+			wantErr:    &CodeSendTooManyError{RetryAfter: time.Minute},
+		},
+		{
+			name: "Verify 通过",
+			evalRes: func() *redis.Cmd {
+				res := redis.NewCmd(context.Background())
+				res.SetVal(int64(0))
+				return res
+			},
+			call: func(ctx context.Context, c CodeCache) error {
+				_, err := c.Verify(ctx, "login", "152", "123456")
+				return err
+			},
+			wantName:   "CodeCache.verify_code",
+			wantOp:     "verify_code",
+			wantResult: "ok",
+		},
+		{
+			name: "Verify 验证码不对",
+			evalRes: func() *redis.Cmd {
+				res := redis.NewCmd(context.Background())
+				res.SetVal(int64(-2))
+				return res
+			},
+			call: func(ctx context.Context, c CodeCache) error {
+				_, err := c.Verify(ctx, "login", "152", "123456")
+				return err
+			},
+			wantName:   "CodeCache.verify_code",
+			wantOp:     "verify_code",
+			wantResult: "mismatch",
+		},
+		{
+			name: "Verify redis 错误",
+			evalRes: func() *redis.Cmd {
+				res := redis.NewCmd(context.Background())
+				res.SetErr(errors.New("mock redis 错误"))
+				return res
+			},
+			call: func(ctx context.Context, c CodeCache) error {
+				_, err := c.Verify(ctx, "login", "152", "123456")
+				return err
+			},
+			wantName:   "CodeCache.verify_code",
+			wantOp:     "verify_code",
+			wantResult: "error",
+			wantErr:    errors.New("mock redis 错误"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			recorder := tracetest.NewSpanRecorder()
+			tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+			// 传给 Redis 的 ctx 里面应该是子 span，这样 Redis 客户端自己的 span 也能挂上来
+			var evalCtx context.Context
+			cmd := redismocks.NewMockCmdable(ctrl)
+			cmd.EXPECT().Eval(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+				DoAndReturn(func(ctx context.Context, script string, keys []string, args ...any) *redis.Cmd {
+					evalCtx = ctx
+					return tc.evalRes()
+				})
+			c := NewTracedCodeCache(NewCodeCacheGoBestPractice(cmd), tp)
+
+			ctx, parent := tp.Tracer("test").Start(context.Background(), "login")
+			err := tc.call(ctx, c)
+			parent.End()
+			assert.Equal(t, tc.wantErr, err)
+
+			spans := recorder.Ended()
+			require.Len(t, spans, 2)
+			child, root := spans[0], spans[1]
+			assert.Equal(t, "login", root.Name())
+			assert.Equal(t, tc.wantName, child.Name())
+			assert.Equal(t, trace.SpanKindClient, child.SpanKind())
+			assert.Equal(t, root.SpanContext().TraceID(), child.SpanContext().TraceID())
+			assert.Equal(t, root.SpanContext().SpanID(), child.Parent().SpanID())
+			assert.Equal(t, child.SpanContext().SpanID(),
+				trace.SpanFromContext(evalCtx).SpanContext().SpanID())
+
+			attrs := make(map[attribute.Key]string)
+			for _, kv := range child.Attributes() {
+				attrs[kv.Key] = kv.Value.Emit()
+			}
+			assert.Equal(t, "redis", attrs["db.system"])
+			assert.Equal(t, tc.wantOp, attrs["db.operation"])
+			assert.Equal(t, "login", attrs["phone_biz"])
+			assert.Equal(t, tc.wantResult, attrs["code_cache.result"])
+			assert.Equal(t, PhoneHash("152"), attrs["phone_hash"])
+			// 手机号不能出现在 span 里面
+			for _, v := range attrs {
+				assert.NotContains(t, v, "152")
+			}
+			if tc.wantErr != nil {
+				assert.Equal(t, codes.Error, child.Status().Code)
+			} else {
+				assert.Equal(t, codes.Unset, child.Status().Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"github.com/vmihailenco/msgpack/v5"
+	"time"
+)
+
+// Serializer 决定缓存里面的值用什么格式存
+type Serializer interface {
+	Marshal(val any) ([]byte, error)
+	Unmarshal(data []byte, val any) error
+}
+
+type JSONSerializer struct{}
+
+func (JSONSerializer) Marshal(val any) ([]byte, error) {
+	return json.Marshal(val)
+}
+
+func (JSONSerializer) Unmarshal(data []byte, val any) error {
+	return json.Unmarshal(data, val)
+}
+
+// MsgpackSerializer 比 JSON 省空间，但是存进去的东西人不好直接看
+type MsgpackSerializer struct{}
+
+func (MsgpackSerializer) Marshal(val any) ([]byte, error) {
+	return msgpack.Marshal(val)
+}
+
+func (MsgpackSerializer) Unmarshal(data []byte, val any) error {
+	return msgpack.Unmarshal(data, val)
+}
+
+// CacheDecorator 封装了序列化和 Redis 的读写，每一种实体的缓存都不用再自己写一遍
+// 数据不存在的时候 Get 返回 ErrKeyNotExist，其它 Redis 的错误原样返回
+type CacheDecorator[T any] struct {
+	client     redis.Cmdable
+	serializer Serializer
+}
+
+func NewCacheDecorator[T any](client redis.Cmdable, serializer Serializer) *CacheDecorator[T] {
+	return &CacheDecorator[T]{
+		client:     client,
+		serializer: serializer,
+	}
+}
+
+func (c *CacheDecorator[T]) Get(ctx context.Context, key string) (T, error) {
+	var val T
+	data, err := c.client.Get(ctx, key).Bytes()
+	if err != nil {
+		// 数据不存在的时候 err 就是 redis.Nil，也就是 ErrKeyNotExist
+		return val, err
+	}
+	err = c.serializer.Unmarshal(data, &val)
+	if err != nil {
+		return val, fmt.Errorf("反序列化缓存 %s 失败 %w", key, err)
+	}
+	return val, nil
+}
+
+func (c *CacheDecorator[T]) Set(ctx context.Context, key string, val T, ttl time.Duration) error {
+	data, err := c.serializer.Marshal(val)
+	if err != nil {
+		return err
+	}
+	return c.client.Set(ctx, key, data, ttl).Err()
+}
+
+func (c *CacheDecorator[T]) Del(ctx context.Context, key string) error {
+	return c.client.Del(ctx, key).Err()
+}
+
+// MGet 批量查询，返回的 map 里面只有命中了的 key。
+// 跟 Get 不一样，反序列化失败的直接当成没命中，不影响别的 key
+func (c *CacheDecorator[T]) MGet(ctx context.Context, keys ...string) (map[string]T, error) {
+	res := make(map[string]T, len(keys))
+	if len(keys) == 0 {
+		return res, nil
+	}
+	vals, err := c.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+	for i, v := range vals {
+		// 没有这个 key 的时候是 nil
+		str, ok := v.(string)
+		if !ok {
+			continue
+		}
+		var val T
+		if err = c.serializer.Unmarshal([]byte(str), &val); err != nil {
+			continue
+		}
+		res[keys[i]] = val
+	}
+	return res, nil
+}
+
+// MSet 用 pipeline 批量写，所有 key 的过期时间都一样
+func (c *CacheDecorator[T]) MSet(ctx context.Context, vals map[string]T, ttl time.Duration) error {
+	if len(vals) == 0 {
+		return nil
+	}
+	_, err := c.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for key, val := range vals {
+			data, err := c.serializer.Marshal(val)
+			if err != nil {
+				return err
+			}
+			pipe.Set(ctx, key, data, ttl)
+		}
+		return nil
+	})
+	return err
+}
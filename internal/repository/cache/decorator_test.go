@@ -0,0 +1,247 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"testing"
+	"time"
+	"webook/internal/domain"
+	"webook/internal/repository/cache/redismocks"
+)
+
+type decoratorTestStruct struct {
+	Name  string
+	Tags  []string
+	Score float64
+}
+
+func TestCacheDecorator(t *testing.T) {
+	serializers := map[string]Serializer{
+		"json":    JSONSerializer{},
+		"msgpack": MsgpackSerializer{},
+	}
+	for name, s := range serializers {
+		t.Run(name, func(t *testing.T) {
+			t.Run("int64", func(t *testing.T) {
+				testCacheDecoratorContract[int64](t, s, 123)
+			})
+			t.Run("string", func(t *testing.T) {
+				testCacheDecoratorContract[string](t, s, "hello")
+			})
+			t.Run("struct", func(t *testing.T) {
+				testCacheDecoratorContract[decoratorTestStruct](t, s, decoratorTestStruct{
+					Name:  "Tom",
+					Tags:  []string{"a", "b"},
+					Score: 9.5,
+				})
+			})
+			t.Run("domain.User", func(t *testing.T) {
+				testCacheDecoratorContract[domain.User](t, s, domain.User{
+					Id:       1,
+					Email:    "123@qq.com",
+					Nickname: "Tom",
+				})
+			})
+		})
+	}
+}
+
+// testCacheDecoratorContract 不管什么类型、什么序列化方式都要满足的约定
+func testCacheDecoratorContract[T any](t *testing.T, s Serializer, val T) {
+	const key = "test:key"
+	const ttl = time.Minute
+	data, err := s.Marshal(val)
+	require.NoError(t, err)
+	redisErr := errors.New("redis 崩了")
+
+	testCases := []struct {
+		name string
+		mock func(ctrl *gomock.Controller) redis.Cmdable
+		// 调用 Get / Set / Del，返回 Get 拿到的值
+		call func(c *CacheDecorator[T]) (T, error)
+
+		wantVal T
+		wantErr error
+		// 只校验有没有 error，不校验具体是哪个
+		wantAnyErr bool
+	}{
+		{
+			name: "Set 成功",
+			mock: func(ctrl *gomock.Controller) redis.Cmdable {
+				cmd := redismocks.NewMockCmdable(ctrl)
+				res := redis.NewStatusCmd(context.Background())
+				res.SetVal("OK")
+				cmd.EXPECT().Set(gomock.Any(), key, data, ttl).Return(res)
+				return cmd
+			},
+			call: func(c *CacheDecorator[T]) (T, error) {
+				var zero T
+				return zero, c.Set(context.Background(), key, val, ttl)
+			},
+		},
+		{
+			name: "Set Redis 出错",
+			mock: func(ctrl *gomock.Controller) redis.Cmdable {
+				cmd := redismocks.NewMockCmdable(ctrl)
+				res := redis.NewStatusCmd(context.Background())
+				res.SetErr(redisErr)
+				cmd.EXPECT().Set(gomock.Any(), key, data, ttl).Return(res)
+				return cmd
+			},
+			call: func(c *CacheDecorator[T]) (T, error) {
+				var zero T
+				return zero, c.Set(context.Background(), key, val, ttl)
+			},
+			wantErr: redisErr,
+		},
+		{
+			name: "Get 成功",
+			mock: func(ctrl *gomock.Controller) redis.Cmdable {
+				cmd := redismocks.NewMockCmdable(ctrl)
+				res := redis.NewStringCmd(context.Background())
+				res.SetVal(string(data))
+				cmd.EXPECT().Get(gomock.Any(), key).Return(res)
+				return cmd
+			},
+			call: func(c *CacheDecorator[T]) (T, error) {
+				return c.Get(context.Background(), key)
+			},
+			wantVal: val,
+		},
+		{
+			name: "Get 数据不存在",
+			mock: func(ctrl *gomock.Controller) redis.Cmdable {
+				cmd := redismocks.NewMockCmdable(ctrl)
+				res := redis.NewStringCmd(context.Background())
+				res.SetErr(redis.Nil)
+				cmd.EXPECT().Get(gomock.Any(), key).Return(res)
+				return cmd
+			},
+			call: func(c *CacheDecorator[T]) (T, error) {
+				return c.Get(context.Background(), key)
+			},
+			wantErr: ErrKeyNotExist,
+		},
+		{
+			name: "Get Redis 出错",
+			mock: func(ctrl *gomock.Controller) redis.Cmdable {
+				cmd := redismocks.NewMockCmdable(ctrl)
+				res := redis.NewStringCmd(context.Background())
+				res.SetErr(redisErr)
+				cmd.EXPECT().Get(gomock.Any(), key).Return(res)
+				return cmd
+			},
+			call: func(c *CacheDecorator[T]) (T, error) {
+				return c.Get(context.Background(), key)
+			},
+			wantErr: redisErr,
+		},
+		{
+			name: "Get 数据坏了",
+			mock: func(ctrl *gomock.Controller) redis.Cmdable {
+				cmd := redismocks.NewMockCmdable(ctrl)
+				res := redis.NewStringCmd(context.Background())
+				res.SetVal("\xc1")
+				cmd.EXPECT().Get(gomock.Any(), key).Return(res)
+				return cmd
+			},
+			call: func(c *CacheDecorator[T]) (T, error) {
+				return c.Get(context.Background(), key)
+			},
+			wantAnyErr: true,
+		},
+		{
+			name: "Del 成功",
+			mock: func(ctrl *gomock.Controller) redis.Cmdable {
+				cmd := redismocks.NewMockCmdable(ctrl)
+				res := redis.NewIntCmd(context.Background())
+				res.SetVal(1)
+				cmd.EXPECT().Del(gomock.Any(), key).Return(res)
+				return cmd
+			},
+			call: func(c *CacheDecorator[T]) (T, error) {
+				var zero T
+				return zero, c.Del(context.Background(), key)
+			},
+		},
+		{
+			name: "Del Redis 出错",
+			mock: func(ctrl *gomock.Controller) redis.Cmdable {
+				cmd := redismocks.NewMockCmdable(ctrl)
+				res := redis.NewIntCmd(context.Background())
+				res.SetErr(redisErr)
+				cmd.EXPECT().Del(gomock.Any(), key).Return(res)
+				return cmd
+			},
+			call: func(c *CacheDecorator[T]) (T, error) {
+				var zero T
+				return zero, c.Del(context.Background(), key)
+			},
+			wantErr: redisErr,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			c := NewCacheDecorator[T](tc.mock(ctrl), s)
+			got, err := tc.call(c)
+			if tc.wantAnyErr {
+				assert.Error(t, err)
+				// 数据坏了不能当成数据不存在
+				assert.False(t, errors.Is(err, ErrKeyNotExist))
+				return
+			}
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.wantVal, got)
+		})
+	}
+}
+
+func TestCacheDecorator_MGet(t *testing.T) {
+	s := JSONSerializer{}
+	data, err := s.Marshal(decoratorTestStruct{Name: "Tom"})
+	require.NoError(t, err)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	cmd := redismocks.NewMockCmdable(ctrl)
+	res := redis.NewSliceCmd(context.Background())
+	// 第二个不存在，第三个数据坏了，都当成没命中
+	res.SetVal([]any{string(data), nil, "\xc1"})
+	cmd.EXPECT().MGet(gomock.Any(), "k1", "k2", "k3").Return(res)
+
+	c := NewCacheDecorator[decoratorTestStruct](cmd, s)
+	vals, err := c.MGet(context.Background(), "k1", "k2", "k3")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]decoratorTestStruct{"k1": {Name: "Tom"}}, vals)
+
+	// 没有 key 不用访问 Redis
+	vals, err = c.MGet(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, vals)
+}
+
+func TestCacheDecorator_MSet(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	cmd := redismocks.NewMockCmdable(ctrl)
+	cmd.EXPECT().Pipelined(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(ctx context.Context, fn func(redis.Pipeliner) error) ([]redis.Cmder, error) {
+			// 真的 pipeline 只是把命令攒起来，不 Exec 就不会连 Redis
+			pipe := redis.NewClient(&redis.Options{}).Pipeline()
+			require.NoError(t, fn(pipe))
+			assert.Equal(t, 2, pipe.Len())
+			return nil, nil
+		})
+
+	c := NewCacheDecorator[int64](cmd, JSONSerializer{})
+	err := c.MSet(context.Background(), map[string]int64{"k1": 1, "k2": 2}, time.Minute)
+	assert.NoError(t, err)
+	// 空的不用访问 Redis
+	assert.NoError(t, c.MSet(context.Background(), nil, time.Minute))
+}
@@ -0,0 +1,40 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"time"
+	"webook/internal/domain"
+)
+
+// UserExportCache 临时存放导出任务和导出的结果，过期就没了
+type UserExportCache interface {
+	Set(ctx context.Context, task domain.ExportTask) error
+	// Get 如果没有数据，返回 ErrKeyNotExist
+	Get(ctx context.Context, id string) (domain.ExportTask, error)
+}
+
+type RedisUserExportCache struct {
+	tasks      *CacheDecorator[domain.ExportTask]
+	expiration time.Duration
+}
+
+func NewUserExportCache(client redis.Cmdable) UserExportCache {
+	return &RedisUserExportCache{
+		tasks:      NewCacheDecorator[domain.ExportTask](client, JSONSerializer{}),
+		expiration: time.Hour * 24,
+	}
+}
+
+func (c *RedisUserExportCache) Set(ctx context.Context, task domain.ExportTask) error {
+	return c.tasks.Set(ctx, c.key(task.Id), task, c.expiration)
+}
+
+func (c *RedisUserExportCache) Get(ctx context.Context, id string) (domain.ExportTask, error) {
+	return c.tasks.Get(ctx, c.key(id))
+}
+
+func (c *RedisUserExportCache) key(id string) string {
+	return fmt.Sprintf("user:export:%s", id)
+}
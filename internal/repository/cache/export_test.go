@@ -0,0 +1,26 @@
+package cache
+
+import (
+	"context"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"testing"
+	"time"
+	"webook/internal/domain"
+	"webook/internal/repository/cache/redismocks"
+)
+
+// 导出的结果只保留 24 小时
+func TestRedisUserExportCache_Set(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	cmd := redismocks.NewMockCmdable(ctrl)
+	cmd.EXPECT().Set(gomock.Any(), "user:export:job-1", gomock.Any(), time.Hour*24).
+		Return(redis.NewStatusCmd(context.Background()))
+
+	err := NewUserExportCache(cmd).Set(context.Background(), domain.ExportTask{
+		Id: "job-1", Uid: 3, Status: domain.ExportTaskDone, Data: []byte("gz"),
+	})
+	assert.NoError(t, err)
+}
@@ -0,0 +1,32 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"time"
+)
+
+// FormTokenCache 记录用过的表单 token，保证一个 token 只能用一次
+type FormTokenCache interface {
+	// MarkUsed 返回 false 代表这个 token 之前已经用过了
+	MarkUsed(ctx context.Context, nonce string, expiration time.Duration) (bool, error)
+}
+
+type RedisFormTokenCache struct {
+	client redis.Cmdable
+}
+
+func NewFormTokenCache(client redis.Cmdable) FormTokenCache {
+	return &RedisFormTokenCache{
+		client: client,
+	}
+}
+
+func (c *RedisFormTokenCache) MarkUsed(ctx context.Context, nonce string, expiration time.Duration) (bool, error) {
+	return c.client.SetNX(ctx, c.key(nonce), 1, expiration).Result()
+}
+
+func (c *RedisFormTokenCache) key(nonce string) string {
+	return fmt.Sprintf("form_token:used:%s", nonce)
+}
@@ -0,0 +1,44 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"time"
+)
+
+// JWTNonceCache 每个签发出去的 JWT 一个 nonce，跟 token 一起过期。
+// 删掉之后这个 token 就算签名没问题也不能用了
+type JWTNonceCache interface {
+	Set(ctx context.Context, uid int64, nonce string, expiration time.Duration) error
+	Exists(ctx context.Context, uid int64, nonce string) (bool, error)
+	Delete(ctx context.Context, uid int64, nonce string) error
+}
+
+type RedisJWTNonceCache struct {
+	client redis.Cmdable
+}
+
+func NewJWTNonceCache(client redis.Cmdable) JWTNonceCache {
+	return &RedisJWTNonceCache{
+		client: client,
+	}
+}
+
+func (c *RedisJWTNonceCache) Set(ctx context.Context, uid int64, nonce string, expiration time.Duration) error {
+	return c.client.Set(ctx, c.key(uid, nonce), "", expiration).Err()
+}
+
+func (c *RedisJWTNonceCache) Exists(ctx context.Context, uid int64, nonce string) (bool, error) {
+	cnt, err := c.client.Exists(ctx, c.key(uid, nonce)).Result()
+	return cnt > 0, err
+}
+
+func (c *RedisJWTNonceCache) Delete(ctx context.Context, uid int64, nonce string) error {
+	return c.client.Del(ctx, c.key(uid, nonce)).Err()
+}
+
+// key 带上 uid，别人的 nonce 拿过来也对不上
+func (c *RedisJWTNonceCache) key(uid int64, nonce string) string {
+	return fmt.Sprintf("user:jwt_nonce:%d:%s", uid, nonce)
+}
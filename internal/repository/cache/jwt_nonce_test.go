@@ -0,0 +1,43 @@
+package cache
+
+import (
+	"context"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestRedisJWTNonceCache(t *testing.T) {
+	mr := miniredis.RunT(t)
+	c := NewJWTNonceCache(redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+	ctx := context.Background()
+
+	require.NoError(t, c.Set(ctx, 3, "n1", time.Minute))
+	require.NoError(t, c.Set(ctx, 3, "n2", time.Minute))
+	assert.Equal(t, time.Minute, mr.TTL("user:jwt_nonce:3:n1"))
+	ok, err := c.Exists(ctx, 3, "n1")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	// 别人的 nonce 对不上
+	ok, err = c.Exists(ctx, 4, "n1")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	// 删掉一个，另一个不受影响
+	require.NoError(t, c.Delete(ctx, 3, "n1"))
+	ok, err = c.Exists(ctx, 3, "n1")
+	require.NoError(t, err)
+	assert.False(t, ok)
+	ok, err = c.Exists(ctx, 3, "n2")
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	// 跟 token 一起过期
+	mr.FastForward(time.Minute)
+	ok, err = c.Exists(ctx, 3, "n2")
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
@@ -0,0 +1,18 @@
+-- anon_session:xxx，还没登录的时候值是空字符串，登录之后是用户 ID
+local key = KEYS[1]
+local uid = ARGV[1]
+local val = redis.call("get", key)
+if val == false then
+    -- 不存在或者过期了
+    return -1
+elseif val == "" then
+    -- 保留原来的过期时间，登录之后还带着这个 ID 上报的数据也能找到用户
+    redis.call("set", key, uid, "KEEPTTL")
+    return 0
+elseif val == uid then
+    -- 重复登录
+    return 0
+else
+    -- 已经被别的用户用了
+    return -2
+end
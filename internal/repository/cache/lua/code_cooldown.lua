@@ -0,0 +1,20 @@
+-- 跟 set_code.lua 的判断一样，还要等几秒才能再发，0 就是现在就能发
+local key = KEYS[1]
+local expiration = tonumber(ARGV[1])
+local cooldown = tonumber(ARGV[2])
+local ttl = tonumber(redis.call("ttl", key))
+-- 没有这个 key 是 -2，没有过期时间是 -1
+if ttl < 0 then
+    return 0
+end
+local resends = tonumber(redis.call("get", key..":resend") or "0")
+-- ttl 是按秒取整的，所以多算一秒
+local remaining = ttl - (expiration - cooldown * (resends + 1)) + 1
+-- 过期了就能发，最多等到过期
+if remaining > ttl + 1 then
+    remaining = ttl + 1
+end
+if remaining < 0 then
+    return 0
+end
+return remaining
@@ -0,0 +1,9 @@
+-- user:sessions:123 是这个用户所有会话的 ssid，每个会话是 user:sessions:123:ssid
+local key = KEYS[1]
+local ssids = redis.call("smembers", key)
+for _, ssid in ipairs(ssids) do
+    -- 已经过期了的也在里面，删一下没关系
+    redis.call("del", key .. ":" .. ssid)
+end
+redis.call("del", key)
+return #ssids
@@ -0,0 +1,18 @@
+-- 只检查验证码对不对，不扣减验证次数，也不会把验证码标记成用过了
+local key = KEYS[1]
+-- 用户输入的 code
+local expectedCode = ARGV[1]
+local code = redis.call("get", key)
+local cntKey = key..":cnt"
+local cnt = tonumber(redis.call("get", cntKey))
+if code == false or cnt == nil then
+    -- 验证码不存在或者过期了
+    return -2
+elseif cnt <= 0 then
+    -- 输错太多次了
+    return -1
+elseif expectedCode == code then
+    return 0
+else
+    return -2
+end
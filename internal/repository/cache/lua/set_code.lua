@@ -1,27 +1,53 @@
 --你的验证码在 Redis 上的 key
 -- phone_code:login:152xxxxxxxx
 local key = KEYS[1]
--- 验证次数，我们一个验证码，最多重复三次，这个记录还可以验证几次
+-- 验证次数，一个验证码最多可以验证几次，这个记录还可以验证几次
 -- phone_code:login:152xxxxxxxx:cnt
 local cntKey = key..":cnt"
+-- 验证码还没过期又发了几次，没收到验证码重发的时候用
+-- phone_code:login:152xxxxxxxx:resend
+local resendKey = key..":resend"
 -- 你的验证码 123456
 local val= ARGV[1]
+-- 验证码多久过期，秒
+local expiration = tonumber(ARGV[2])
+-- 两次发送至少间隔多久，秒
+local cooldown = tonumber(ARGV[3])
+-- 最多验证几次
+local maxAttempts = tonumber(ARGV[4])
 -- 过期时间
 local ttl = tonumber(redis.call("ttl", key))
 if ttl == -1 then
     --    key 存在，但是没有过期时间
     -- 系统错误，你的同事手贱，手动设置了这个 key，但是没给过期时间
     return -2
-    --    540 = 600-60 九分钟
-elseif ttl == -2 or ttl < 540 then
+end
+local resends = 0
+if ttl ~= -2 then
+    resends = tonumber(redis.call("get", resendKey) or "0")
+end
+-- 每重发一次，下一次就要多等一个 cooldown：60 秒、120 秒、180 秒……
+-- 默认配置下第一次重发是 540 = 600-60 九分钟
+-- 等的时间比有效期还长的话，就只能等这个验证码过期了
+if ttl == -2 or ttl < expiration - cooldown * (resends + 1) then
     redis.call("set", key, val)
-    redis.call("expire", key, 600)
-    redis.call("set", cntKey, 3)
-    redis.call("expire", cntKey, 600)
+    redis.call("expire", key, expiration)
+    redis.call("set", cntKey, maxAttempts)
+    redis.call("expire", cntKey, expiration)
+    if ttl == -2 then
+        redis.call("del", resendKey)
+    else
+        -- 旧的验证码已经被换掉了，不能再用
+        redis.call("set", resendKey, resends + 1)
+        redis.call("expire", resendKey, expiration)
+    end
     -- 完美，符合预期
     return 0
 else
-    -- 发送太频繁
-    return -1
+    -- 发送太频繁，顺便告诉调用方还要等几秒，跟 code_cooldown.lua 的算法一样
+    local remaining = ttl - (expiration - cooldown * (resends + 1)) + 1
+    if remaining > ttl + 1 then
+        remaining = ttl + 1
+    end
+    return {-1, remaining}
 end
-
@@ -0,0 +1,26 @@
+-- 按手机号计数的 key，sms_quota:phone:20230801:152xxxxxxxx
+local phoneKey = KEYS[1]
+-- 按 IP 计数的 key，sms_quota:ip:20230801:127.0.0.1
+local ipKey = KEYS[2]
+-- 每个手机号每天最多发几条，0 代表不限制
+local phoneLimit = tonumber(ARGV[1])
+-- 每个 IP 每天最多发几条，0 代表不限制
+local ipLimit = tonumber(ARGV[2])
+-- 第二天零点，unix 时间戳，秒
+local expireAt = tonumber(ARGV[3])
+
+local phoneCnt = tonumber(redis.call("get", phoneKey) or "0")
+if phoneLimit > 0 and phoneCnt >= phoneLimit then
+    -- 这个手机号今天发太多了
+    return -1
+end
+local ipCnt = tonumber(redis.call("get", ipKey) or "0")
+if ipLimit > 0 and ipCnt >= ipLimit then
+    -- 这个 IP 今天发太多了
+    return -2
+end
+redis.call("incr", phoneKey)
+redis.call("expireat", phoneKey, expireAt)
+redis.call("incr", ipKey)
+redis.call("expireat", ipKey, expireAt)
+return 0
@@ -3,20 +3,22 @@ local key = KEYS[1]
 local expectedCode = ARGV[1]
 local code = redis.call("get", key)
 local cntKey = key..":cnt"
+if code == false then
+    -- 没发过、过期了，或者已经用掉删了，跟输错一样，但是不知道还剩几次
+    return -2
+end
 -- 转成一个数字
 local cnt = tonumber(redis.call("get", cntKey))
-if cnt <= 0 then
+if cnt == nil or cnt <= 0 then
 --    说明，用户一直输错，有人搞你
---    或者已经用过了，也是有人搞你
     return -1
 elseif expectedCode == code then
     -- 输入对了
-    -- 用完，不能再用了
-    redis.call("set", cntKey, -1)
+    -- 用完，不能再用了，直接删掉
+    redis.call("del", key, cntKey, key..":resend")
     return 0
 else
     -- 用户手一抖，输错了
-    -- 可验证次数 -1
-    redis.call("decr", cntKey)
-    return -2
-end
\ No newline at end of file
+    -- 可验证次数 -1，顺便告诉用户还剩几次
+    return {-2, redis.call("decr", cntKey)}
+end
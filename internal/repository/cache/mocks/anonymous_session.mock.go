@@ -0,0 +1,64 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: webook/internal/repository/cache/anonymous_session.go
+
+// Package cachemocks is a generated GoMock package.
+package cachemocks
+
+import (
+	context "context"
+	reflect "reflect"
+	time "time"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockAnonymousSessionCache is a mock of AnonymousSessionCache interface.
+type MockAnonymousSessionCache struct {
+	ctrl     *gomock.Controller
+	recorder *MockAnonymousSessionCacheMockRecorder
+}
+
+// MockAnonymousSessionCacheMockRecorder is the mock recorder for MockAnonymousSessionCache.
+type MockAnonymousSessionCacheMockRecorder struct {
+	mock *MockAnonymousSessionCache
+}
+
+// NewMockAnonymousSessionCache creates a new mock instance.
+func NewMockAnonymousSessionCache(ctrl *gomock.Controller) *MockAnonymousSessionCache {
+	mock := &MockAnonymousSessionCache{ctrl: ctrl}
+	mock.recorder = &MockAnonymousSessionCacheMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockAnonymousSessionCache) EXPECT() *MockAnonymousSessionCacheMockRecorder {
+	return m.recorder
+}
+
+// Bind mocks base method.
+func (m *MockAnonymousSessionCache) Bind(ctx context.Context, id string, uid int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Bind", ctx, id, uid)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Bind indicates an expected call of Bind.
+func (mr *MockAnonymousSessionCacheMockRecorder) Bind(ctx, id, uid interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Bind", reflect.TypeOf((*MockAnonymousSessionCache)(nil).Bind), ctx, id, uid)
+}
+
+// Create mocks base method.
+func (m *MockAnonymousSessionCache) Create(ctx context.Context, id string, expiration time.Duration) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Create", ctx, id, expiration)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Create indicates an expected call of Create.
+func (mr *MockAnonymousSessionCacheMockRecorder) Create(ctx, id, expiration interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockAnonymousSessionCache)(nil).Create), ctx, id, expiration)
+}
@@ -0,0 +1,51 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: webook/internal/repository/cache/form_token.go
+
+// Package cachemocks is a generated GoMock package.
+package cachemocks
+
+import (
+	context "context"
+	reflect "reflect"
+	time "time"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockFormTokenCache is a mock of FormTokenCache interface.
+type MockFormTokenCache struct {
+	ctrl     *gomock.Controller
+	recorder *MockFormTokenCacheMockRecorder
+}
+
+// MockFormTokenCacheMockRecorder is the mock recorder for MockFormTokenCache.
+type MockFormTokenCacheMockRecorder struct {
+	mock *MockFormTokenCache
+}
+
+// NewMockFormTokenCache creates a new mock instance.
+func NewMockFormTokenCache(ctrl *gomock.Controller) *MockFormTokenCache {
+	mock := &MockFormTokenCache{ctrl: ctrl}
+	mock.recorder = &MockFormTokenCacheMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockFormTokenCache) EXPECT() *MockFormTokenCacheMockRecorder {
+	return m.recorder
+}
+
+// MarkUsed mocks base method.
+func (m *MockFormTokenCache) MarkUsed(ctx context.Context, nonce string, expiration time.Duration) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MarkUsed", ctx, nonce, expiration)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// MarkUsed indicates an expected call of MarkUsed.
+func (mr *MockFormTokenCacheMockRecorder) MarkUsed(ctx, nonce, expiration interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MarkUsed", reflect.TypeOf((*MockFormTokenCache)(nil).MarkUsed), ctx, nonce, expiration)
+}
@@ -0,0 +1,79 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: webook/internal/repository/cache/jwt_nonce.go
+
+// Package cachemocks is a generated GoMock package.
+package cachemocks
+
+import (
+	context "context"
+	reflect "reflect"
+	time "time"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockJWTNonceCache is a mock of JWTNonceCache interface.
+type MockJWTNonceCache struct {
+	ctrl     *gomock.Controller
+	recorder *MockJWTNonceCacheMockRecorder
+}
+
+// MockJWTNonceCacheMockRecorder is the mock recorder for MockJWTNonceCache.
+type MockJWTNonceCacheMockRecorder struct {
+	mock *MockJWTNonceCache
+}
+
+// NewMockJWTNonceCache creates a new mock instance.
+func NewMockJWTNonceCache(ctrl *gomock.Controller) *MockJWTNonceCache {
+	mock := &MockJWTNonceCache{ctrl: ctrl}
+	mock.recorder = &MockJWTNonceCacheMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockJWTNonceCache) EXPECT() *MockJWTNonceCacheMockRecorder {
+	return m.recorder
+}
+
+// Delete mocks base method.
+func (m *MockJWTNonceCache) Delete(ctx context.Context, uid int64, nonce string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Delete", ctx, uid, nonce)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Delete indicates an expected call of Delete.
+func (mr *MockJWTNonceCacheMockRecorder) Delete(ctx, uid, nonce interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockJWTNonceCache)(nil).Delete), ctx, uid, nonce)
+}
+
+// Exists mocks base method.
+func (m *MockJWTNonceCache) Exists(ctx context.Context, uid int64, nonce string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Exists", ctx, uid, nonce)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Exists indicates an expected call of Exists.
+func (mr *MockJWTNonceCacheMockRecorder) Exists(ctx, uid, nonce interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Exists", reflect.TypeOf((*MockJWTNonceCache)(nil).Exists), ctx, uid, nonce)
+}
+
+// Set mocks base method.
+func (m *MockJWTNonceCache) Set(ctx context.Context, uid int64, nonce string, expiration time.Duration) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Set", ctx, uid, nonce, expiration)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Set indicates an expected call of Set.
+func (mr *MockJWTNonceCacheMockRecorder) Set(ctx, uid, nonce, expiration interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Set", reflect.TypeOf((*MockJWTNonceCache)(nil).Set), ctx, uid, nonce, expiration)
+}
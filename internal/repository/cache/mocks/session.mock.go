@@ -0,0 +1,93 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: webook/internal/repository/cache/session.go
+
+// Package cachemocks is a generated GoMock package.
+package cachemocks
+
+import (
+	context "context"
+	reflect "reflect"
+	time "time"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockSessionCache is a mock of SessionCache interface.
+type MockSessionCache struct {
+	ctrl     *gomock.Controller
+	recorder *MockSessionCacheMockRecorder
+}
+
+// MockSessionCacheMockRecorder is the mock recorder for MockSessionCache.
+type MockSessionCacheMockRecorder struct {
+	mock *MockSessionCache
+}
+
+// NewMockSessionCache creates a new mock instance.
+func NewMockSessionCache(ctrl *gomock.Controller) *MockSessionCache {
+	mock := &MockSessionCache{ctrl: ctrl}
+	mock.recorder = &MockSessionCacheMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockSessionCache) EXPECT() *MockSessionCacheMockRecorder {
+	return m.recorder
+}
+
+// Create mocks base method.
+func (m *MockSessionCache) Create(ctx context.Context, uid int64, ssid, userAgent string, expiration time.Duration) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Create", ctx, uid, ssid, userAgent, expiration)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Create indicates an expected call of Create.
+func (mr *MockSessionCacheMockRecorder) Create(ctx, uid, ssid, userAgent, expiration interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockSessionCache)(nil).Create), ctx, uid, ssid, userAgent, expiration)
+}
+
+// Delete mocks base method.
+func (m *MockSessionCache) Delete(ctx context.Context, uid int64, ssid string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Delete", ctx, uid, ssid)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Delete indicates an expected call of Delete.
+func (mr *MockSessionCacheMockRecorder) Delete(ctx, uid, ssid interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockSessionCache)(nil).Delete), ctx, uid, ssid)
+}
+
+// DeleteAll mocks base method.
+func (m *MockSessionCache) DeleteAll(ctx context.Context, uid int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteAll", ctx, uid)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteAll indicates an expected call of DeleteAll.
+func (mr *MockSessionCacheMockRecorder) DeleteAll(ctx, uid interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteAll", reflect.TypeOf((*MockSessionCache)(nil).DeleteAll), ctx, uid)
+}
+
+// Touch mocks base method.
+func (m *MockSessionCache) Touch(ctx context.Context, uid int64, ssid string, expiration time.Duration) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Touch", ctx, uid, ssid, expiration)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Touch indicates an expected call of Touch.
+func (mr *MockSessionCacheMockRecorder) Touch(ctx, uid, ssid, expiration interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Touch", reflect.TypeOf((*MockSessionCache)(nil).Touch), ctx, uid, ssid, expiration)
+}
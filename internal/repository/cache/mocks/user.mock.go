@@ -7,8 +7,9 @@ package cachemocks
 import (
 	context "context"
 	reflect "reflect"
-
+	time "time"
 	domain "webook/internal/domain"
+
 	gomock "go.uber.org/mock/gomock"
 )
 
@@ -35,6 +36,34 @@ func (m *MockUserCache) EXPECT() *MockUserCacheMockRecorder {
 	return m.recorder
 }
 
+// Del mocks base method.
+func (m *MockUserCache) Del(ctx context.Context, id int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Del", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Del indicates an expected call of Del.
+func (mr *MockUserCacheMockRecorder) Del(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Del", reflect.TypeOf((*MockUserCache)(nil).Del), ctx, id)
+}
+
+// DelProfile mocks base method.
+func (m *MockUserCache) DelProfile(ctx context.Context, id int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DelProfile", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DelProfile indicates an expected call of DelProfile.
+func (mr *MockUserCacheMockRecorder) DelProfile(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DelProfile", reflect.TypeOf((*MockUserCache)(nil).DelProfile), ctx, id)
+}
+
 // Get mocks base method.
 func (m *MockUserCache) Get(ctx context.Context, id int64) (domain.User, error) {
 	m.ctrl.T.Helper()
@@ -50,6 +79,79 @@ func (mr *MockUserCacheMockRecorder) Get(ctx, id interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockUserCache)(nil).Get), ctx, id)
 }
 
+// GetProfiles mocks base method.
+func (m *MockUserCache) GetProfiles(ctx context.Context, ids []int64) (map[int64]domain.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetProfiles", ctx, ids)
+	ret0, _ := ret[0].(map[int64]domain.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetProfiles indicates an expected call of GetProfiles.
+func (mr *MockUserCacheMockRecorder) GetProfiles(ctx, ids interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetProfiles", reflect.TypeOf((*MockUserCache)(nil).GetProfiles), ctx, ids)
+}
+
+// IsRevoked mocks base method.
+func (m *MockUserCache) IsRevoked(ctx context.Context, id int64) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IsRevoked", ctx, id)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// IsRevoked indicates an expected call of IsRevoked.
+func (mr *MockUserCacheMockRecorder) IsRevoked(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsRevoked", reflect.TypeOf((*MockUserCache)(nil).IsRevoked), ctx, id)
+}
+
+// ProfileAge mocks base method.
+func (m *MockUserCache) ProfileAge(ctx context.Context, id int64) (time.Duration, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ProfileAge", ctx, id)
+	ret0, _ := ret[0].(time.Duration)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ProfileAge indicates an expected call of ProfileAge.
+func (mr *MockUserCacheMockRecorder) ProfileAge(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ProfileAge", reflect.TypeOf((*MockUserCache)(nil).ProfileAge), ctx, id)
+}
+
+// Restore mocks base method.
+func (m *MockUserCache) Restore(ctx context.Context, id int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Restore", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Restore indicates an expected call of Restore.
+func (mr *MockUserCacheMockRecorder) Restore(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Restore", reflect.TypeOf((*MockUserCache)(nil).Restore), ctx, id)
+}
+
+// Revoke mocks base method.
+func (m *MockUserCache) Revoke(ctx context.Context, id int64, expiration time.Duration) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Revoke", ctx, id, expiration)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Revoke indicates an expected call of Revoke.
+func (mr *MockUserCacheMockRecorder) Revoke(ctx, id, expiration interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Revoke", reflect.TypeOf((*MockUserCache)(nil).Revoke), ctx, id, expiration)
+}
+
 // Set mocks base method.
 func (m *MockUserCache) Set(ctx context.Context, u domain.User) error {
 	m.ctrl.T.Helper()
@@ -63,3 +165,17 @@ func (mr *MockUserCacheMockRecorder) Set(ctx, u interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Set", reflect.TypeOf((*MockUserCache)(nil).Set), ctx, u)
 }
+
+// SetProfiles mocks base method.
+func (m *MockUserCache) SetProfiles(ctx context.Context, us []domain.User) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetProfiles", ctx, us)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetProfiles indicates an expected call of SetProfiles.
+func (mr *MockUserCacheMockRecorder) SetProfiles(ctx, us interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetProfiles", reflect.TypeOf((*MockUserCache)(nil).SetProfiles), ctx, us)
+}
@@ -0,0 +1,41 @@
+package cache
+
+import (
+	"github.com/redis/go-redis/v9"
+	"time"
+)
+
+// RedisPoolConfig Redis 客户端的连接池，零值就是用 go-redis 的默认值
+type RedisPoolConfig struct {
+	// 最多多少个连接，默认是 10 * GOMAXPROCS。满了之后新的请求最多等 PoolTimeout
+	MaxOpenConns int
+	// 至少保持多少个空闲的连接，流量突然上来的时候不用现建
+	MinIdleConns int
+	// 连接最多用多久，到了就关掉重建，默认不限制
+	ConnMaxLifetime time.Duration
+	// 空闲多久之后关掉，默认 30 分钟
+	ConnMaxIdleTime time.Duration
+	// 连接池满了的时候最多等多久，默认是读超时再加一秒
+	PoolTimeout time.Duration
+}
+
+// Options 要自己加 hook 之类的，用这个去 redis.NewClient
+func (cfg RedisPoolConfig) Options(addr string) *redis.Options {
+	return &redis.Options{
+		Addr:            addr,
+		PoolSize:        cfg.MaxOpenConns,
+		MinIdleConns:    cfg.MinIdleConns,
+		ConnMaxLifetime: cfg.ConnMaxLifetime,
+		ConnMaxIdleTime: cfg.ConnMaxIdleTime,
+		PoolTimeout:     cfg.PoolTimeout,
+	}
+}
+
+func NewRedisClient(addr string, cfg RedisPoolConfig) redis.Cmdable {
+	return redis.NewClient(cfg.Options(addr))
+}
+
+// poolStatser *redis.Client 和 *redis.ClusterClient 都实现了
+type poolStatser interface {
+	PoolStats() *redis.PoolStats
+}
@@ -0,0 +1,44 @@
+package cache
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 要本地起一个 Redis，没有的话跳过
+func TestNewRedisClient_MaxOpenConns(t *testing.T) {
+	client := NewRedisClient("localhost:6379", RedisPoolConfig{
+		MaxOpenConns: 2,
+		PoolTimeout:  5 * time.Second,
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		t.Skip("本地没有 Redis", err)
+	}
+	c := NewCodeCacheGoBestPractice(client)
+
+	var wg sync.WaitGroup
+	errs := make([]error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			phone := "152" + strconv.FormatInt(time.Now().UnixNano(), 10) + strconv.Itoa(i)
+			errs[i] = c.Set(context.Background(), "pool_test", phone, "123456")
+		}(i)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		require.NoError(t, err)
+	}
+
+	stats := c.PoolStats()
+	assert.LessOrEqual(t, stats.TotalConns, uint32(2))
+	assert.Zero(t, stats.Timeouts)
+}
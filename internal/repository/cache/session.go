@@ -0,0 +1,79 @@
+package cache
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"time"
+)
+
+//go:embed lua/delete_user_sessions.lua
+var luaDeleteUserSessions string
+
+// SessionCache 登录之后每台设备一个会话，用 ssid 区分。
+// 会话自己有过期时间，每次用到都会续期；另外每个用户有一个 set 记着他所有的 ssid，退出所有设备的时候用
+type SessionCache interface {
+	Create(ctx context.Context, uid int64, ssid string, userAgent string, expiration time.Duration) error
+	// Touch 会话还在的话续期，返回 false 是已经退出或者过期了
+	Touch(ctx context.Context, uid int64, ssid string, expiration time.Duration) (bool, error)
+	Delete(ctx context.Context, uid int64, ssid string) error
+	// DeleteAll 删掉这个用户所有的会话
+	DeleteAll(ctx context.Context, uid int64) error
+}
+
+type RedisSessionCache struct {
+	client redis.Cmdable
+}
+
+func NewSessionCache(client redis.Cmdable) SessionCache {
+	return &RedisSessionCache{
+		client: client,
+	}
+}
+
+func (c *RedisSessionCache) Create(ctx context.Context, uid int64, ssid string,
+	userAgent string, expiration time.Duration) error {
+	_, err := c.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, c.key(uid, ssid), userAgent, expiration)
+		pipe.SAdd(ctx, c.indexKey(uid), ssid)
+		// set 跟着最新的会话走，保证比里面的会话活得长
+		pipe.Expire(ctx, c.indexKey(uid), expiration)
+		return nil
+	})
+	return err
+}
+
+func (c *RedisSessionCache) Touch(ctx context.Context, uid int64, ssid string, expiration time.Duration) (bool, error) {
+	var touched *redis.BoolCmd
+	_, err := c.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		touched = pipe.Expire(ctx, c.key(uid, ssid), expiration)
+		pipe.Expire(ctx, c.indexKey(uid), expiration)
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	return touched.Val(), nil
+}
+
+func (c *RedisSessionCache) Delete(ctx context.Context, uid int64, ssid string) error {
+	_, err := c.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, c.key(uid, ssid))
+		pipe.SRem(ctx, c.indexKey(uid), ssid)
+		return nil
+	})
+	return err
+}
+
+func (c *RedisSessionCache) DeleteAll(ctx context.Context, uid int64) error {
+	return c.client.Eval(ctx, luaDeleteUserSessions, []string{c.indexKey(uid)}).Err()
+}
+
+func (c *RedisSessionCache) key(uid int64, ssid string) string {
+	return fmt.Sprintf("%s:%s", c.indexKey(uid), ssid)
+}
+
+func (c *RedisSessionCache) indexKey(uid int64) string {
+	return fmt.Sprintf("user:sessions:%d", uid)
+}
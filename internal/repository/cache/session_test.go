@@ -0,0 +1,61 @@
+package cache
+
+import (
+	"context"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestRedisSessionCache(t *testing.T) {
+	mr := miniredis.RunT(t)
+	c := NewSessionCache(redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+	ctx := context.Background()
+
+	require.NoError(t, c.Create(ctx, 3, "phone", "iPhone", time.Minute))
+	require.NoError(t, c.Create(ctx, 3, "laptop", "Chrome", time.Minute))
+	val, err := mr.Get("user:sessions:3:phone")
+	require.NoError(t, err)
+	assert.Equal(t, "iPhone", val)
+	members, err := mr.Members("user:sessions:3")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"phone", "laptop"}, members)
+
+	// 一直在用的话会续期
+	mr.FastForward(40 * time.Second)
+	ok, err := c.Touch(ctx, 3, "phone", time.Minute)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	mr.FastForward(40 * time.Second)
+	ok, err = c.Touch(ctx, 3, "phone", time.Minute)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = c.Touch(ctx, 3, "laptop", time.Minute)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	// 退出这台设备，别的设备不受影响
+	require.NoError(t, c.Create(ctx, 3, "laptop", "Chrome", time.Minute))
+	require.NoError(t, c.Delete(ctx, 3, "phone"))
+	ok, err = c.Touch(ctx, 3, "phone", time.Minute)
+	require.NoError(t, err)
+	assert.False(t, ok)
+	ok, err = c.Touch(ctx, 3, "laptop", time.Minute)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	members, err = mr.Members("user:sessions:3")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"laptop"}, members)
+
+	// 退出所有设备，别的用户不受影响
+	require.NoError(t, c.Create(ctx, 4, "other", "Chrome", time.Minute))
+	require.NoError(t, c.DeleteAll(ctx, 3))
+	assert.False(t, mr.Exists("user:sessions:3"))
+	assert.False(t, mr.Exists("user:sessions:3:laptop"))
+	ok, err = c.Touch(ctx, 4, "other", time.Minute)
+	require.NoError(t, err)
+	assert.True(t, ok)
+}
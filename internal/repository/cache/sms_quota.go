@@ -0,0 +1,173 @@
+package cache
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var (
+	ErrPhoneQuotaExceeded = errors.New("该手机号今日短信发送次数已达上限")
+	ErrIPQuotaExceeded    = errors.New("该 IP 今日短信发送次数已达上限")
+)
+
+//go:embed lua/sms_quota.lua
+var luaSMSQuota string
+
+// SMSQuotaExceededError 配额用完了，带着什么时候重新计数。
+// errors.Is(err, ErrPhoneQuotaExceeded) 或者 ErrIPQuotaExceeded 是成立的
+type SMSQuotaExceededError struct {
+	// Err 是 ErrPhoneQuotaExceeded 或者 ErrIPQuotaExceeded
+	Err error
+	// ResetAt 第二天零点
+	ResetAt time.Time
+}
+
+func (e *SMSQuotaExceededError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *SMSQuotaExceededError) Unwrap() error {
+	return e.Err
+}
+
+// SMSQuotaCache 每天的短信配额，过了零点就重新计数。
+// 手机号的配额不分 biz，登录、注册、改手机号加起来算
+type SMSQuotaCache interface {
+	// Check 只看配额用完了没有，不计数，用完了返回 *SMSQuotaExceededError
+	Check(ctx context.Context, phone, ip string, phoneLimit, ipLimit int) error
+	// Incr 配额没用完就计数加一，用完了返回 *SMSQuotaExceededError。
+	// limit 为 0 代表不限制
+	Incr(ctx context.Context, phone, ip string, phoneLimit, ipLimit int) error
+}
+
+// nextMidnight 配额在这个时候重新计数
+func nextMidnight(now time.Time) time.Time {
+	y, m, d := now.Date()
+	return time.Date(y, m, d+1, 0, 0, 0, 0, now.Location())
+}
+
+type RedisSMSQuotaCache struct {
+	client redis.Cmdable
+	now    func() time.Time
+}
+
+func NewSMSQuotaCache(client redis.Cmdable) SMSQuotaCache {
+	return &RedisSMSQuotaCache{
+		client: client,
+		now:    time.Now,
+	}
+}
+
+func (c *RedisSMSQuotaCache) Check(ctx context.Context, phone, ip string, phoneLimit, ipLimit int) error {
+	now := c.now()
+	day := now.Format("20060102")
+	vals, err := c.client.MGet(ctx,
+		fmt.Sprintf("sms_quota:phone:%s:%s", day, phone),
+		fmt.Sprintf("sms_quota:ip:%s:%s", day, ip)).Result()
+	if err != nil {
+		return err
+	}
+	return checkSMSQuota(now, quotaCount(vals[0]), quotaCount(vals[1]), phoneLimit, ipLimit)
+}
+
+// quotaCount key 不存在的时候 MGET 返回 nil，就是今天还没发过
+func quotaCount(val any) int {
+	s, ok := val.(string)
+	if !ok {
+		return 0
+	}
+	cnt, err := strconv.Atoi(s)
+	if err != nil {
+		return 0
+	}
+	return cnt
+}
+
+// checkSMSQuota 跟 sms_quota.lua 的判断一样
+func checkSMSQuota(now time.Time, phoneCnt, ipCnt, phoneLimit, ipLimit int) error {
+	if phoneLimit > 0 && phoneCnt >= phoneLimit {
+		return &SMSQuotaExceededError{Err: ErrPhoneQuotaExceeded, ResetAt: nextMidnight(now)}
+	}
+	if ipLimit > 0 && ipCnt >= ipLimit {
+		return &SMSQuotaExceededError{Err: ErrIPQuotaExceeded, ResetAt: nextMidnight(now)}
+	}
+	return nil
+}
+
+func (c *RedisSMSQuotaCache) Incr(ctx context.Context, phone, ip string, phoneLimit, ipLimit int) error {
+	now := c.now()
+	day := now.Format("20060102")
+	tomorrow := nextMidnight(now)
+	res, err := c.client.Eval(ctx, luaSMSQuota,
+		[]string{
+			fmt.Sprintf("sms_quota:phone:%s:%s", day, phone),
+			fmt.Sprintf("sms_quota:ip:%s:%s", day, ip),
+		},
+		phoneLimit, ipLimit, tomorrow.Unix()).Int()
+	if err != nil {
+		return err
+	}
+	switch res {
+	case 0:
+		return nil
+	case -1:
+		return &SMSQuotaExceededError{Err: ErrPhoneQuotaExceeded, ResetAt: tomorrow}
+	case -2:
+		return &SMSQuotaExceededError{Err: ErrIPQuotaExceeded, ResetAt: tomorrow}
+	default:
+		return errors.New("系统错误")
+	}
+}
+
+// LocalSMSQuotaCache 跟 sms_quota.lua 的判断一样，计数放在进程里面。
+// 多个实例的话每个实例各算各的，只适合单机部署和测试
+type LocalSMSQuotaCache struct {
+	mutex sync.Mutex
+	// day 计数是哪一天的，过了零点整个清掉
+	day    string
+	phones map[string]int
+	ips    map[string]int
+	now    func() time.Time
+}
+
+func NewLocalSMSQuotaCache() *LocalSMSQuotaCache {
+	return &LocalSMSQuotaCache{
+		now: time.Now,
+	}
+}
+
+func (c *LocalSMSQuotaCache) Check(ctx context.Context, phone, ip string, phoneLimit, ipLimit int) error {
+	now := c.now()
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.rollover(now)
+	return checkSMSQuota(now, c.phones[phone], c.ips[ip], phoneLimit, ipLimit)
+}
+
+func (c *LocalSMSQuotaCache) Incr(ctx context.Context, phone, ip string, phoneLimit, ipLimit int) error {
+	now := c.now()
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.rollover(now)
+	if err := checkSMSQuota(now, c.phones[phone], c.ips[ip], phoneLimit, ipLimit); err != nil {
+		return err
+	}
+	c.phones[phone]++
+	c.ips[ip]++
+	return nil
+}
+
+// rollover 过了零点整个清掉，要拿着锁调
+func (c *LocalSMSQuotaCache) rollover(now time.Time) {
+	if day := now.Format("20060102"); day != c.day {
+		c.day = day
+		c.phones = make(map[string]int)
+		c.ips = make(map[string]int)
+	}
+}
@@ -0,0 +1,208 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"testing"
+	"time"
+	"webook/internal/repository/cache/redismocks"
+)
+
+func TestRedisSMSQuotaCache_Incr(t *testing.T) {
+	now := time.Date(2023, 8, 1, 15, 30, 0, 0, time.Local)
+	tomorrow := time.Date(2023, 8, 2, 0, 0, 0, 0, time.Local)
+	keys := []string{
+		"sms_quota:phone:20230801:152",
+		"sms_quota:ip:20230801:127.0.0.1",
+	}
+	testCases := []struct {
+		name string
+		mock func(ctrl *gomock.Controller) redis.Cmdable
+
+		wantErr error
+	}{
+		{
+			name: "配额没用完",
+			mock: func(ctrl *gomock.Controller) redis.Cmdable {
+				cmd := redismocks.NewMockCmdable(ctrl)
+				res := redis.NewCmd(context.Background())
+				res.SetVal(int64(0))
+				cmd.EXPECT().Eval(gomock.Any(), luaSMSQuota, keys,
+					10, 50, tomorrow.Unix()).Return(res)
+				return cmd
+			},
+		},
+		{
+			name: "手机号配额用完了",
+			mock: func(ctrl *gomock.Controller) redis.Cmdable {
+				cmd := redismocks.NewMockCmdable(ctrl)
+				res := redis.NewCmd(context.Background())
+				res.SetVal(int64(-1))
+				cmd.EXPECT().Eval(gomock.Any(), luaSMSQuota, keys,
+					10, 50, tomorrow.Unix()).Return(res)
+				return cmd
+			},
+			wantErr: ErrPhoneQuotaExceeded,
+		},
+		{
+			name: "IP 配额用完了",
+			mock: func(ctrl *gomock.Controller) redis.Cmdable {
+				cmd := redismocks.NewMockCmdable(ctrl)
+				res := redis.NewCmd(context.Background())
+				res.SetVal(int64(-2))
+				cmd.EXPECT().Eval(gomock.Any(), luaSMSQuota, keys,
+					10, 50, tomorrow.Unix()).Return(res)
+				return cmd
+			},
+			wantErr: ErrIPQuotaExceeded,
+		},
+		{
+			name: "redis错误",
+			mock: func(ctrl *gomock.Controller) redis.Cmdable {
+				cmd := redismocks.NewMockCmdable(ctrl)
+				res := redis.NewCmd(context.Background())
+				res.SetErr(errors.New("mock redis 错误"))
+				cmd.EXPECT().Eval(gomock.Any(), luaSMSQuota, keys,
+					10, 50, tomorrow.Unix()).Return(res)
+				return cmd
+			},
+			wantErr: errors.New("mock redis 错误"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			c := NewSMSQuotaCache(tc.mock(ctrl)).(*RedisSMSQuotaCache)
+			c.now = func() time.Time { return now }
+			err := c.Incr(context.Background(), "152", "127.0.0.1", 10, 50)
+			var quotaErr *SMSQuotaExceededError
+			if errors.As(err, &quotaErr) {
+				assert.ErrorIs(t, err, tc.wantErr)
+				assert.Equal(t, tomorrow, quotaErr.ResetAt)
+				return
+			}
+			assert.Equal(t, tc.wantErr, err)
+		})
+	}
+}
+
+func TestLocalSMSQuotaCache_Incr(t *testing.T) {
+	now := time.Date(2023, 8, 1, 15, 30, 0, 0, time.Local)
+	c := NewLocalSMSQuotaCache()
+	c.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	// 手机号一天两条，IP 一天三条
+	assert.NoError(t, c.Incr(ctx, "152", "127.0.0.1", 2, 3))
+	assert.NoError(t, c.Incr(ctx, "152", "127.0.0.1", 2, 3))
+	err := c.Incr(ctx, "152", "127.0.0.1", 2, 3)
+	assert.ErrorIs(t, err, ErrPhoneQuotaExceeded)
+	var quotaErr *SMSQuotaExceededError
+	assert.True(t, errors.As(err, &quotaErr))
+	assert.Equal(t, time.Date(2023, 8, 2, 0, 0, 0, 0, time.Local), quotaErr.ResetAt)
+	// 被拒绝的不计数，IP 还剩一条
+	assert.NoError(t, c.Incr(ctx, "153", "127.0.0.1", 2, 3))
+	assert.ErrorIs(t, c.Incr(ctx, "154", "127.0.0.1", 2, 3), ErrIPQuotaExceeded)
+	// 0 代表不限制
+	assert.NoError(t, c.Incr(ctx, "152", "127.0.0.2", 0, 0))
+
+	// 过了零点重新计数
+	now = now.Add(9 * time.Hour)
+	assert.NoError(t, c.Incr(ctx, "152", "127.0.0.1", 2, 3))
+}
+
+func TestRedisSMSQuotaCache_Check(t *testing.T) {
+	now := time.Date(2023, 8, 1, 15, 30, 0, 0, time.Local)
+	tomorrow := time.Date(2023, 8, 2, 0, 0, 0, 0, time.Local)
+	testCases := []struct {
+		name string
+		mock func(ctrl *gomock.Controller) redis.Cmdable
+
+		wantErr error
+	}{
+		{
+			name: "今天还没发过",
+			mock: func(ctrl *gomock.Controller) redis.Cmdable {
+				cmd := redismocks.NewMockCmdable(ctrl)
+				res := redis.NewSliceCmd(context.Background())
+				res.SetVal([]any{nil, nil})
+				cmd.EXPECT().MGet(gomock.Any(), "sms_quota:phone:20230801:152",
+					"sms_quota:ip:20230801:127.0.0.1").Return(res)
+				return cmd
+			},
+		},
+		{
+			name: "手机号配额用完了",
+			mock: func(ctrl *gomock.Controller) redis.Cmdable {
+				cmd := redismocks.NewMockCmdable(ctrl)
+				res := redis.NewSliceCmd(context.Background())
+				res.SetVal([]any{"10", "10"})
+				cmd.EXPECT().MGet(gomock.Any(), "sms_quota:phone:20230801:152",
+					"sms_quota:ip:20230801:127.0.0.1").Return(res)
+				return cmd
+			},
+			wantErr: ErrPhoneQuotaExceeded,
+		},
+		{
+			name: "IP 配额用完了",
+			mock: func(ctrl *gomock.Controller) redis.Cmdable {
+				cmd := redismocks.NewMockCmdable(ctrl)
+				res := redis.NewSliceCmd(context.Background())
+				res.SetVal([]any{"1", "50"})
+				cmd.EXPECT().MGet(gomock.Any(), "sms_quota:phone:20230801:152",
+					"sms_quota:ip:20230801:127.0.0.1").Return(res)
+				return cmd
+			},
+			wantErr: ErrIPQuotaExceeded,
+		},
+		{
+			name: "redis错误",
+			mock: func(ctrl *gomock.Controller) redis.Cmdable {
+				cmd := redismocks.NewMockCmdable(ctrl)
+				res := redis.NewSliceCmd(context.Background())
+				res.SetErr(errors.New("mock redis 错误"))
+				cmd.EXPECT().MGet(gomock.Any(), "sms_quota:phone:20230801:152",
+					"sms_quota:ip:20230801:127.0.0.1").Return(res)
+				return cmd
+			},
+			wantErr: errors.New("mock redis 错误"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			c := NewSMSQuotaCache(tc.mock(ctrl)).(*RedisSMSQuotaCache)
+			c.now = func() time.Time { return now }
+			err := c.Check(context.Background(), "152", "127.0.0.1", 10, 50)
+			var quotaErr *SMSQuotaExceededError
+			if errors.As(err, &quotaErr) {
+				assert.ErrorIs(t, err, tc.wantErr)
+				assert.Equal(t, tomorrow, quotaErr.ResetAt)
+				return
+			}
+			assert.Equal(t, tc.wantErr, err)
+		})
+	}
+}
+
+func TestLocalSMSQuotaCache_Check(t *testing.T) {
+	now := time.Date(2023, 8, 1, 15, 30, 0, 0, time.Local)
+	c := NewLocalSMSQuotaCache()
+	c.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	// Check 不计数
+	assert.NoError(t, c.Check(ctx, "152", "127.0.0.1", 1, 3))
+	assert.NoError(t, c.Check(ctx, "152", "127.0.0.1", 1, 3))
+	assert.NoError(t, c.Incr(ctx, "152", "127.0.0.1", 1, 3))
+	assert.ErrorIs(t, c.Check(ctx, "152", "127.0.0.1", 1, 3), ErrPhoneQuotaExceeded)
+
+	// 过了零点重新计数
+	now = now.Add(9 * time.Hour)
+	assert.NoError(t, c.Check(ctx, "152", "127.0.0.1", 1, 3))
+}
@@ -2,9 +2,9 @@ package cache
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"github.com/redis/go-redis/v9"
+	"math/rand"
 	"time"
 	"webook/internal/domain"
 )
@@ -12,22 +12,42 @@ import (
 var ErrKeyNotExist = redis.Nil
 
 type UserCache interface {
+	// Get 用户自己看的资料，没有缓存返回 ErrKeyNotExist
 	Get(ctx context.Context, id int64) (domain.User, error)
 	Set(ctx context.Context, u domain.User) error
+	Del(ctx context.Context, id int64) error
+
+	// GetProfiles 批量查公开的资料，返回的 map 里面只有命中了的
+	GetProfiles(ctx context.Context, ids []int64) (map[int64]domain.User, error)
+	SetProfiles(ctx context.Context, us []domain.User) error
+	// DelProfile 资料改了就要删掉
+	DelProfile(ctx context.Context, id int64) error
+	// ProfileAge 缓存里面的资料写进去多久了，没有缓存返回 ErrKeyNotExist
+	ProfileAge(ctx context.Context, id int64) (time.Duration, error)
+
+	// Revoke 让这个用户所有的登录态都失效，一直到 Restore 或者过期
+	Revoke(ctx context.Context, id int64, expiration time.Duration) error
+	IsRevoked(ctx context.Context, id int64) (bool, error)
+	Restore(ctx context.Context, id int64) error
 }
 
 type RedisUserCache struct {
 	// 传单机 Redis 可以
 	// 传 cluster 的 Redis 也可以
 	client     redis.Cmdable
+	users      *CacheDecorator[domain.User]
 	expiration time.Duration
+	// Set 的时候过期时间再随机加上 [0, jitter)，避免同一批写进去的一起过期
+	jitter time.Duration
 }
 
 func NewUserCacheV1(addr string) UserCache {
 	client := redis.NewClient(&redis.Options{})
 	return &RedisUserCache{
 		client:     client,
+		users:      NewCacheDecorator[domain.User](client, JSONSerializer{}),
 		expiration: time.Minute * 15,
+		jitter:     time.Minute * 3,
 	}
 }
 
@@ -39,40 +59,96 @@ func NewUserCacheV1(addr string) UserCache {
 func NewUserCache(client redis.Cmdable) UserCache {
 	return &RedisUserCache{
 		client:     client,
+		users:      NewCacheDecorator[domain.User](client, JSONSerializer{}),
 		expiration: time.Minute * 15,
+		jitter:     time.Minute * 3,
 	}
 }
 
-// Get 如果没有数据，返回一个特定的 error
+// Get 如果没有数据，返回 ErrKeyNotExist
 func (cache *RedisUserCache) Get(ctx context.Context, id int64) (domain.User, error) {
-	key := cache.key(id)
-	// 数据不存在，err = redis.Nil
-	val, err := cache.client.Get(ctx, key).Bytes()
-	if err != nil {
-		return domain.User{}, err
-	}
-	var u domain.User
-	err = json.Unmarshal(val, &u)
-	//if err != nil {
-	//	return domain.User{}, err
-	//}
-	//return u, nil
-	return u, err
+	return cache.users.Get(ctx, cache.key(id))
 }
 
 func (cache *RedisUserCache) Set(ctx context.Context, u domain.User) error {
-	val, err := json.Marshal(u)
-	if err != nil {
-		return err
+	expiration := cache.expiration
+	if cache.jitter > 0 {
+		expiration += time.Duration(rand.Int63n(int64(cache.jitter)))
 	}
-	key := cache.key(u.Id)
-	return cache.client.Set(ctx, key, val, cache.expiration).Err()
+	return cache.users.Set(ctx, cache.key(u.Id), u, expiration)
+}
+
+func (cache *RedisUserCache) Del(ctx context.Context, id int64) error {
+	return cache.users.Del(ctx, cache.key(id))
 }
 
 func (cache *RedisUserCache) key(id int64) string {
 	return fmt.Sprintf("user:info:%d", id)
 }
 
+func (cache *RedisUserCache) GetProfiles(ctx context.Context, ids []int64) (map[int64]domain.User, error) {
+	keys := make([]string, 0, len(ids))
+	for _, id := range ids {
+		keys = append(keys, cache.profileKey(id))
+	}
+	vals, err := cache.users.MGet(ctx, keys...)
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[int64]domain.User, len(vals))
+	for _, u := range vals {
+		res[u.Id] = u
+	}
+	return res, nil
+}
+
+func (cache *RedisUserCache) SetProfiles(ctx context.Context, us []domain.User) error {
+	vals := make(map[string]domain.User, len(us))
+	for _, u := range us {
+		vals[cache.profileKey(u.Id)] = u
+	}
+	return cache.users.MSet(ctx, vals, cache.expiration)
+}
+
+func (cache *RedisUserCache) DelProfile(ctx context.Context, id int64) error {
+	return cache.users.Del(ctx, cache.profileKey(id))
+}
+
+// ProfileAge 用剩下的过期时间倒推，写进去的时候过期时间都是 expiration
+func (cache *RedisUserCache) ProfileAge(ctx context.Context, id int64) (time.Duration, error) {
+	ttl, err := cache.client.PTTL(ctx, cache.profileKey(id)).Result()
+	if err != nil {
+		return 0, err
+	}
+	// -2 是不存在，-1 是没有过期时间，正常写进去的不会这样
+	if ttl < 0 {
+		return 0, ErrKeyNotExist
+	}
+	return cache.expiration - ttl, nil
+}
+
+// profileKey 只放公开的资料，跟 user:info 分开，那里面有密码之类的东西
+func (cache *RedisUserCache) profileKey(id int64) string {
+	return fmt.Sprintf("user:profile:%d", id)
+}
+
+func (cache *RedisUserCache) Revoke(ctx context.Context, id int64, expiration time.Duration) error {
+	return cache.client.Set(ctx, cache.revokedKey(id), time.Now().UnixMilli(), expiration).Err()
+}
+
+func (cache *RedisUserCache) IsRevoked(ctx context.Context, id int64) (bool, error) {
+	cnt, err := cache.client.Exists(ctx, cache.revokedKey(id)).Result()
+	return cnt > 0, err
+}
+
+func (cache *RedisUserCache) Restore(ctx context.Context, id int64) error {
+	return cache.client.Del(ctx, cache.revokedKey(id)).Err()
+}
+
+func (cache *RedisUserCache) revokedKey(id int64) string {
+	return fmt.Sprintf("user:revoked:%d", id)
+}
+
 // main 函数里面初始化好
 //var RedisClient *redis.Client
 
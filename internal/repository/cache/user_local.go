@@ -0,0 +1,133 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"github.com/redis/go-redis/v9"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+	"webook/internal/domain"
+)
+
+// ProfileInvalidationChannel 资料改了之后在这里发用户 ID，别的实例收到了就删掉本地的
+const ProfileInvalidationChannel = "user:profile:invalidate"
+
+// LocalProfileCache 进程内的公开资料缓存，挡在 Redis 前面给热点用户用。
+// 超过 capacity 按 LRU 淘汰，过期时间要很短：
+// 通知丢了或者跟修改并发读到了旧数据，最多也就旧这么久
+type LocalProfileCache struct {
+	client   redis.Cmdable
+	capacity int
+	ttl      time.Duration
+
+	mu sync.Mutex
+	// 最近用过的在前面
+	ll    *list.List
+	items map[int64]*list.Element
+	now   func() time.Time
+}
+
+type localProfileEntry struct {
+	user       domain.User
+	expiration time.Time
+}
+
+// NewLocalProfileCache client 用来给别的实例发删除的通知
+func NewLocalProfileCache(client redis.Cmdable, capacity int, ttl time.Duration) *LocalProfileCache {
+	return &LocalProfileCache{
+		client:   client,
+		capacity: capacity,
+		ttl:      ttl,
+		ll:       list.New(),
+		items:    make(map[int64]*list.Element, capacity),
+		now:      time.Now,
+	}
+}
+
+// Get 返回的 map 里面只有命中了并且没有过期的
+func (c *LocalProfileCache) Get(ids []int64) map[int64]domain.User {
+	now := c.now()
+	res := make(map[int64]domain.User, len(ids))
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, id := range ids {
+		elem, ok := c.items[id]
+		if !ok {
+			continue
+		}
+		entry := elem.Value.(*localProfileEntry)
+		if now.After(entry.expiration) {
+			c.removeElement(elem)
+			continue
+		}
+		c.ll.MoveToFront(elem)
+		res[id] = entry.user
+	}
+	return res
+}
+
+func (c *LocalProfileCache) Set(us []domain.User) {
+	expiration := c.now().Add(c.ttl)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, u := range us {
+		if elem, ok := c.items[u.Id]; ok {
+			elem.Value = &localProfileEntry{user: u, expiration: expiration}
+			c.ll.MoveToFront(elem)
+			continue
+		}
+		c.items[u.Id] = c.ll.PushFront(&localProfileEntry{user: u, expiration: expiration})
+		if c.ll.Len() > c.capacity {
+			c.removeElement(c.ll.Back())
+		}
+	}
+}
+
+// Del 删掉本地的，再通知别的实例也删掉
+func (c *LocalProfileCache) Del(ctx context.Context, id int64) error {
+	c.remove(id)
+	return c.client.Publish(ctx, ProfileInvalidationChannel, strconv.FormatInt(id, 10)).Err()
+}
+
+// Listen 处理别的实例发过来的删除通知，msgs 关掉或者 ctx 取消的时候退出。
+// 自己发的也会收到，多删一次没关系
+func (c *LocalProfileCache) Listen(ctx context.Context, msgs <-chan *redis.Message) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-msgs:
+			if !ok {
+				return
+			}
+			id, err := strconv.ParseInt(msg.Payload, 10, 64)
+			if err != nil {
+				log.Println("资料缓存：不认识的删除通知", msg.Payload)
+				continue
+			}
+			c.remove(id)
+		}
+	}
+}
+
+// Len 包括已经过期了但是还没有被清掉的
+func (c *LocalProfileCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ll.Len()
+}
+
+func (c *LocalProfileCache) remove(id int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.items[id]; ok {
+		c.removeElement(elem)
+	}
+}
+
+func (c *LocalProfileCache) removeElement(elem *list.Element) {
+	c.ll.Remove(elem)
+	delete(c.items, elem.Value.(*localProfileEntry).user.Id)
+}
@@ -0,0 +1,81 @@
+package cache
+
+import (
+	"context"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"testing"
+	"time"
+	"webook/internal/domain"
+	"webook/internal/repository/cache/redismocks"
+)
+
+func TestLocalProfileCache_LRU(t *testing.T) {
+	c := NewLocalProfileCache(nil, 2, time.Minute)
+	c.Set([]domain.User{{Id: 1, Nickname: "a"}, {Id: 2, Nickname: "b"}})
+	// 1 最近用过，再放一个进来淘汰的是 2
+	assert.Len(t, c.Get([]int64{1}), 1)
+	c.Set([]domain.User{{Id: 3, Nickname: "c"}})
+	assert.Equal(t, map[int64]domain.User{
+		1: {Id: 1, Nickname: "a"},
+		3: {Id: 3, Nickname: "c"},
+	}, c.Get([]int64{1, 2, 3}))
+	assert.Equal(t, 2, c.Len())
+
+	// 已经有的就是更新，不会淘汰别的
+	c.Set([]domain.User{{Id: 3, Nickname: "cc"}})
+	assert.Equal(t, 2, c.Len())
+	assert.Equal(t, "cc", c.Get([]int64{3})[3].Nickname)
+}
+
+func TestLocalProfileCache_Expiration(t *testing.T) {
+	now := time.Now()
+	c := NewLocalProfileCache(nil, 10, time.Second*10)
+	c.now = func() time.Time { return now }
+	c.Set([]domain.User{{Id: 1}})
+	assert.Len(t, c.Get([]int64{1}), 1)
+
+	now = now.Add(time.Second * 11)
+	assert.Empty(t, c.Get([]int64{1}))
+	// 过期的读的时候顺便清掉
+	assert.Equal(t, 0, c.Len())
+}
+
+func TestLocalProfileCache_Del(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	cmd := redismocks.NewMockCmdable(ctrl)
+	cmd.EXPECT().Publish(gomock.Any(), ProfileInvalidationChannel, "1").
+		Return(redis.NewIntCmd(context.Background()))
+	c := NewLocalProfileCache(cmd, 10, time.Minute)
+	c.Set([]domain.User{{Id: 1}, {Id: 2}})
+
+	assert.NoError(t, c.Del(context.Background(), 1))
+	assert.Equal(t, []int64{2}, keys(c.Get([]int64{1, 2})))
+}
+
+func TestLocalProfileCache_Listen(t *testing.T) {
+	c := NewLocalProfileCache(nil, 10, time.Minute)
+	c.Set([]domain.User{{Id: 1}, {Id: 2}})
+	msgs := make(chan *redis.Message)
+	done := make(chan struct{})
+	go func() {
+		c.Listen(context.Background(), msgs)
+		close(done)
+	}()
+	msgs <- &redis.Message{Channel: ProfileInvalidationChannel, Payload: "abc"}
+	msgs <- &redis.Message{Channel: ProfileInvalidationChannel, Payload: "2"}
+	// channel 关掉之后 Listen 才会退出，这时候前面的通知一定处理完了
+	close(msgs)
+	<-done
+	assert.Equal(t, []int64{1}, keys(c.Get([]int64{1, 2})))
+}
+
+func keys(m map[int64]domain.User) []int64 {
+	res := make([]int64, 0, len(m))
+	for id := range m {
+		res = append(res, id)
+	}
+	return res
+}
@@ -0,0 +1,76 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"testing"
+	"time"
+	"webook/internal/domain"
+	"webook/internal/repository/cache/redismocks"
+)
+
+func TestRedisUserCache_ProfileAge(t *testing.T) {
+	testCases := []struct {
+		name string
+		ttl  time.Duration
+		err  error
+
+		wantAge time.Duration
+		wantErr error
+	}{
+		{
+			name:    "写进去一分钟了",
+			ttl:     time.Minute * 14,
+			wantAge: time.Minute,
+		},
+		{
+			name:    "没有缓存",
+			ttl:     -2,
+			wantErr: ErrKeyNotExist,
+		},
+		{
+			name:    "没有过期时间",
+			ttl:     -1,
+			wantErr: ErrKeyNotExist,
+		},
+		{
+			name:    "redis 错误",
+			err:     errors.New("mock redis 错误"),
+			wantErr: errors.New("mock redis 错误"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			cmd := redismocks.NewMockCmdable(ctrl)
+			res := redis.NewDurationCmd(context.Background(), time.Millisecond)
+			res.SetVal(tc.ttl)
+			res.SetErr(tc.err)
+			cmd.EXPECT().PTTL(gomock.Any(), "user:profile:3").Return(res)
+
+			age, err := NewUserCache(cmd).ProfileAge(context.Background(), 3)
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.wantAge, age)
+		})
+	}
+}
+
+func TestRedisUserCache_SetJitter(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	cmd := redismocks.NewMockCmdable(ctrl)
+	cmd.EXPECT().Set(gomock.Any(), "user:info:3", gomock.Any(), gomock.Any()).
+		DoAndReturn(func(ctx context.Context, key string, val any, expiration time.Duration) *redis.StatusCmd {
+			assert.GreaterOrEqual(t, expiration, time.Minute*15)
+			assert.Less(t, expiration, time.Minute*18)
+			return redis.NewStatusCmd(ctx)
+		}).Times(10)
+	c := NewUserCache(cmd)
+	for i := 0; i < 10; i++ {
+		assert.NoError(t, c.Set(context.Background(), domain.User{Id: 3}))
+	}
+}
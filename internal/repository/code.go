@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"time"
 	"webook/internal/repository/cache"
 )
 
@@ -10,10 +11,18 @@ var (
 	ErrCodeVerifyTooManyTimes = cache.ErrCodeVerifyTooManyTimes
 )
 
+// CodeSendTooManyError 发送太频繁的时候带着还要等多久，errors.Is(err, ErrCodeSendTooMany) 成立
+type CodeSendTooManyError = cache.CodeSendTooManyError
+
+// VerifyResult 输错了的时候带着还能再试几次
+type VerifyResult = cache.VerifyResult
+
 type CodeRepository interface {
 	Store(ctx context.Context, biz string,
 		phone string, code string) error
 	Verify(ctx context.Context, biz, phone, inputCode string) (bool, error)
+	VerifyWithResult(ctx context.Context, biz, phone, inputCode string) (VerifyResult, error)
+	Cooldown(ctx context.Context, biz, phone string) (time.Duration, error)
 }
 type CachedCodeRepository struct {
 	cache cache.CodeCache
@@ -33,3 +42,11 @@ func (repo *CachedCodeRepository) Store(ctx context.Context, biz string,
 func (repo *CachedCodeRepository) Verify(ctx context.Context, biz, phone, inputCode string) (bool, error) {
 	return repo.cache.Verify(ctx, biz, phone, inputCode)
 }
+
+func (repo *CachedCodeRepository) VerifyWithResult(ctx context.Context, biz, phone, inputCode string) (VerifyResult, error) {
+	return repo.cache.VerifyWithResult(ctx, biz, phone, inputCode)
+}
+
+func (repo *CachedCodeRepository) Cooldown(ctx context.Context, biz, phone string) (time.Duration, error) {
+	return repo.cache.Cooldown(ctx, biz, phone)
+}
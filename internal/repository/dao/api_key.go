@@ -0,0 +1,83 @@
+package dao
+
+import (
+	"context"
+	"gorm.io/gorm"
+	"time"
+)
+
+var ErrAPIKeyNotFound = gorm.ErrRecordNotFound
+
+type APIKeyDAO interface {
+	Insert(ctx context.Context, k APIKey) (int64, error)
+	FindByHash(ctx context.Context, hash string) (APIKey, error)
+	FindByUserId(ctx context.Context, uid int64) ([]APIKey, error)
+	Delete(ctx context.Context, uid int64, id int64) error
+	UpdateLastUsed(ctx context.Context, id int64, lastUsed int64) error
+}
+
+type GORMAPIKeyDAO struct {
+	db *gorm.DB
+}
+
+func NewAPIKeyDAO(db *gorm.DB) APIKeyDAO {
+	return &GORMAPIKeyDAO{
+		db: db,
+	}
+}
+
+func (dao *GORMAPIKeyDAO) Insert(ctx context.Context, k APIKey) (int64, error) {
+	now := time.Now().UnixMilli()
+	k.Ctime = now
+	k.Utime = now
+	err := dao.db.WithContext(ctx).Create(&k).Error
+	return k.Id, err
+}
+
+func (dao *GORMAPIKeyDAO) FindByHash(ctx context.Context, hash string) (APIKey, error) {
+	var k APIKey
+	err := dao.db.WithContext(ctx).Where("key_hash = ?", hash).First(&k).Error
+	return k, err
+}
+
+func (dao *GORMAPIKeyDAO) FindByUserId(ctx context.Context, uid int64) ([]APIKey, error) {
+	var res []APIKey
+	err := dao.db.WithContext(ctx).Where("user_id = ?", uid).
+		Order("id DESC").Find(&res).Error
+	return res, err
+}
+
+// Delete 带上 user_id，防止删掉别人的 key
+func (dao *GORMAPIKeyDAO) Delete(ctx context.Context, uid int64, id int64) error {
+	res := dao.db.WithContext(ctx).Where("id = ? AND user_id = ?", id, uid).
+		Delete(&APIKey{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+func (dao *GORMAPIKeyDAO) UpdateLastUsed(ctx context.Context, id int64, lastUsed int64) error {
+	return dao.db.WithContext(ctx).Model(&APIKey{}).Where("id = ?", id).
+		Updates(map[string]any{
+			"last_used_at": lastUsed,
+			"utime":        time.Now().UnixMilli(),
+		}).Error
+}
+
+type APIKey struct {
+	Id     int64 `gorm:"primaryKey,autoIncrement"`
+	UserId int64 `gorm:"index"`
+	// sha256 之后的十六进制
+	KeyHash string `gorm:"type:char(64);unique"`
+	Name    string
+	// 毫秒数，0 代表永不过期
+	ExpiresAt  int64
+	LastUsedAt int64
+
+	Ctime int64
+	Utime int64
+}
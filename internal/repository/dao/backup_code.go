@@ -0,0 +1,88 @@
+package dao
+
+import (
+	"context"
+	"errors"
+	"gorm.io/gorm"
+	"time"
+)
+
+// ErrBackupCodeUsed 标记的时候发现已经被别的请求用掉了
+var ErrBackupCodeUsed = errors.New("备用码已经用过了")
+
+type BackupCodeDAO interface {
+	// Replace 删掉这个用户以前所有的备用码，换成新的
+	Replace(ctx context.Context, uid int64, codes []BackupCode) error
+	FindUnused(ctx context.Context, uid int64) ([]BackupCode, error)
+	CountUnused(ctx context.Context, uid int64) (int64, error)
+	// MarkUsed 只有还没用过的时候才会更新，相当于 CAS
+	MarkUsed(ctx context.Context, id int64, usedAt int64) error
+}
+
+type GORMBackupCodeDAO struct {
+	db *gorm.DB
+}
+
+func NewBackupCodeDAO(db *gorm.DB) BackupCodeDAO {
+	return &GORMBackupCodeDAO{
+		db: db,
+	}
+}
+
+func (dao *GORMBackupCodeDAO) Replace(ctx context.Context, uid int64, codes []BackupCode) error {
+	now := time.Now().UnixMilli()
+	for i := range codes {
+		codes[i].UserId = uid
+		codes[i].Ctime = now
+		codes[i].Utime = now
+	}
+	return dao.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("user_id = ?", uid).Delete(&BackupCode{}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Create(&codes).Error
+	})
+}
+
+func (dao *GORMBackupCodeDAO) FindUnused(ctx context.Context, uid int64) ([]BackupCode, error) {
+	var res []BackupCode
+	err := dao.db.WithContext(ctx).Where("user_id = ? AND used_at = ?", uid, 0).
+		Find(&res).Error
+	return res, err
+}
+
+func (dao *GORMBackupCodeDAO) CountUnused(ctx context.Context, uid int64) (int64, error) {
+	var cnt int64
+	err := dao.db.WithContext(ctx).Model(&BackupCode{}).
+		Where("user_id = ? AND used_at = ?", uid, 0).Count(&cnt).Error
+	return cnt, err
+}
+
+func (dao *GORMBackupCodeDAO) MarkUsed(ctx context.Context, id int64, usedAt int64) error {
+	res := dao.db.WithContext(ctx).Model(&BackupCode{}).
+		Where("id = ? AND used_at = ?", id, 0).
+		Updates(map[string]any{
+			"used_at": usedAt,
+			"utime":   time.Now().UnixMilli(),
+		})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrBackupCodeUsed
+	}
+	return nil
+}
+
+type BackupCode struct {
+	Id     int64 `gorm:"primaryKey,autoIncrement"`
+	UserId int64 `gorm:"index"`
+	// bcrypt 之后的
+	CodeHash string `gorm:"type:varchar(60)"`
+	// 毫秒数，0 代表还没用过
+	UsedAt int64
+
+	Ctime int64
+	Utime int64
+}
@@ -0,0 +1,25 @@
+package dao
+
+// UserBan 封禁记录，users 表里面只有当前的封禁，历史都在这里
+type UserBan struct {
+	Id       int64 `gorm:"primaryKey,autoIncrement"`
+	Uid      int64 `gorm:"index"`
+	Operator int64
+	Reason   string
+	// 毫秒数，0 是永久封禁
+	Until int64
+	// 提前解封的管理员和时间，被新的封禁覆盖也算。时间是毫秒数，0 就是没有解除
+	LiftedBy int64
+	LiftedAt int64
+	Ctime    int64
+}
+
+// AdminAuditLog 管理后台的操作记录，只增不改
+type AdminAuditLog struct {
+	Id        int64  `gorm:"primaryKey,autoIncrement"`
+	Operator  int64  `gorm:"index"`
+	Action    string `gorm:"type:varchar(64)"`
+	TargetUid int64  `gorm:"index"`
+	Detail    string `gorm:"type:text"`
+	Ctime     int64
+}
@@ -1,7 +1,69 @@
 package dao
 
-import "gorm.io/gorm"
+import (
+	"github.com/go-sql-driver/mysql"
+	"gorm.io/gorm"
+	"log"
+)
 
 func InitTable(db *gorm.DB) error {
-	return db.AutoMigrate(&User{})
+	err := db.AutoMigrate(&User{}, &APIKey{}, &RememberMeToken{}, &UserBan{}, &AdminAuditLog{}, &BackupCode{}, &LoginEvent{})
+	if err != nil {
+		return err
+	}
+	if err = backfillUserCtime(db); err != nil {
+		return err
+	}
+	if err = lowercaseUserEmails(db); err != nil {
+		return err
+	}
+	return createEmailLowerIndex(db)
+}
+
+// lowercaseUserEmails 以前注册的时候邮箱是原样存的，这里统一改成小写。
+// 按 id 一条一条改，小写以后跟别的账号冲突了的就不改，留给管理员用 MergeAccounts 合并：
+// 已经是小写的那个会留下来，都不是小写的话最早注册的那个会留下来。
+// 排序规则是 _ci 的话唯一索引本来就不区分大小写，不会有冲突
+func lowercaseUserEmails(db *gorm.DB) error {
+	var us []User
+	// BINARY 才是按字节比较，不然 _ci 的排序规则下永远相等
+	err := db.Select("id", "email").
+		Where("email IS NOT NULL AND BINARY email <> LOWER(email)").
+		Order("id").Find(&us).Error
+	if err != nil {
+		return err
+	}
+	for _, u := range us {
+		err = db.Model(&User{Id: u.Id}).Update("email", gorm.Expr("LOWER(email)")).Error
+		if mysqlErr, ok := err.(*mysql.MySQLError); ok && mysqlErr.Number == 1062 {
+			log.Println("邮箱改成小写以后跟别的账号冲突了，需要人工合并", u.Id)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// createEmailLowerIndex GORM 的 tag 表达不了函数索引，只能自己建。
+// MySQL 8.0.13 以下不支持，建不了只打日志，查询还是对的，就是要扫表
+func createEmailLowerIndex(db *gorm.DB) error {
+	const name = "idx_users_email_lower"
+	if db.Migrator().HasIndex(&User{}, name) {
+		return nil
+	}
+	err := db.Exec("CREATE INDEX " + name + " ON users ((LOWER(email)))").Error
+	if err != nil {
+		log.Println("建邮箱小写的函数索引失败", err)
+	}
+	return nil
+}
+
+// backfillUserCtime 很早的数据没有记录创建时间，用更新时间代替，
+// 至少不会比真实的注册时间早。两个都没有的就只能留空了
+func backfillUserCtime(db *gorm.DB) error {
+	return db.Model(&User{}).
+		Where("ctime = ? AND utime > ?", 0, 0).
+		Update("ctime", gorm.Expr("utime")).Error
 }
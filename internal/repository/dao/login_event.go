@@ -0,0 +1,47 @@
+package dao
+
+import (
+	"context"
+	"gorm.io/gorm"
+	"time"
+)
+
+type LoginEventDAO interface {
+	Insert(ctx context.Context, e LoginEvent) error
+	// FindByUserId 最近的在前面
+	FindByUserId(ctx context.Context, uid int64, limit int) ([]LoginEvent, error)
+}
+
+type GORMLoginEventDAO struct {
+	db *gorm.DB
+}
+
+func NewLoginEventDAO(db *gorm.DB) LoginEventDAO {
+	return &GORMLoginEventDAO{
+		db: db,
+	}
+}
+
+func (dao *GORMLoginEventDAO) Insert(ctx context.Context, e LoginEvent) error {
+	e.Ctime = time.Now().UnixMilli()
+	return dao.db.WithContext(ctx).Create(&e).Error
+}
+
+// FindByUserId 同一毫秒的按 id 排，保证顺序是稳定的
+func (dao *GORMLoginEventDAO) FindByUserId(ctx context.Context, uid int64, limit int) ([]LoginEvent, error) {
+	var res []LoginEvent
+	err := dao.db.WithContext(ctx).Where("user_id = ?", uid).
+		Order("ctime DESC, id DESC").Limit(limit).Find(&res).Error
+	return res, err
+}
+
+// LoginEvent 只会插入，不会更新，所以没有 utime
+type LoginEvent struct {
+	Id     int64  `gorm:"primaryKey,autoIncrement"`
+	UserId int64  `gorm:"index:idx_user_ctime,priority:1"`
+	IP     string `gorm:"type:varchar(64)"`
+	// 超过长度的截断了再存
+	UserAgent string `gorm:"type:varchar(512)"`
+	Status    uint8
+	Ctime     int64 `gorm:"index:idx_user_ctime,priority:2"`
+}
@@ -0,0 +1,109 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: webook/internal/repository/dao/api_key.go
+
+// Package daomocks is a generated GoMock package.
+package daomocks
+
+import (
+	context "context"
+	reflect "reflect"
+	dao "webook/internal/repository/dao"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockAPIKeyDAO is a mock of APIKeyDAO interface.
+type MockAPIKeyDAO struct {
+	ctrl     *gomock.Controller
+	recorder *MockAPIKeyDAOMockRecorder
+}
+
+// MockAPIKeyDAOMockRecorder is the mock recorder for MockAPIKeyDAO.
+type MockAPIKeyDAOMockRecorder struct {
+	mock *MockAPIKeyDAO
+}
+
+// NewMockAPIKeyDAO creates a new mock instance.
+func NewMockAPIKeyDAO(ctrl *gomock.Controller) *MockAPIKeyDAO {
+	mock := &MockAPIKeyDAO{ctrl: ctrl}
+	mock.recorder = &MockAPIKeyDAOMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockAPIKeyDAO) EXPECT() *MockAPIKeyDAOMockRecorder {
+	return m.recorder
+}
+
+// Delete mocks base method.
+func (m *MockAPIKeyDAO) Delete(ctx context.Context, uid, id int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Delete", ctx, uid, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Delete indicates an expected call of Delete.
+func (mr *MockAPIKeyDAOMockRecorder) Delete(ctx, uid, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockAPIKeyDAO)(nil).Delete), ctx, uid, id)
+}
+
+// FindByHash mocks base method.
+func (m *MockAPIKeyDAO) FindByHash(ctx context.Context, hash string) (dao.APIKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindByHash", ctx, hash)
+	ret0, _ := ret[0].(dao.APIKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindByHash indicates an expected call of FindByHash.
+func (mr *MockAPIKeyDAOMockRecorder) FindByHash(ctx, hash interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindByHash", reflect.TypeOf((*MockAPIKeyDAO)(nil).FindByHash), ctx, hash)
+}
+
+// FindByUserId mocks base method.
+func (m *MockAPIKeyDAO) FindByUserId(ctx context.Context, uid int64) ([]dao.APIKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindByUserId", ctx, uid)
+	ret0, _ := ret[0].([]dao.APIKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindByUserId indicates an expected call of FindByUserId.
+func (mr *MockAPIKeyDAOMockRecorder) FindByUserId(ctx, uid interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindByUserId", reflect.TypeOf((*MockAPIKeyDAO)(nil).FindByUserId), ctx, uid)
+}
+
+// Insert mocks base method.
+func (m *MockAPIKeyDAO) Insert(ctx context.Context, k dao.APIKey) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Insert", ctx, k)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Insert indicates an expected call of Insert.
+func (mr *MockAPIKeyDAOMockRecorder) Insert(ctx, k interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Insert", reflect.TypeOf((*MockAPIKeyDAO)(nil).Insert), ctx, k)
+}
+
+// UpdateLastUsed mocks base method.
+func (m *MockAPIKeyDAO) UpdateLastUsed(ctx context.Context, id, lastUsed int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateLastUsed", ctx, id, lastUsed)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateLastUsed indicates an expected call of UpdateLastUsed.
+func (mr *MockAPIKeyDAOMockRecorder) UpdateLastUsed(ctx, id, lastUsed interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateLastUsed", reflect.TypeOf((*MockAPIKeyDAO)(nil).UpdateLastUsed), ctx, id, lastUsed)
+}
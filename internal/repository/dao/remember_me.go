@@ -0,0 +1,90 @@
+package dao
+
+import (
+	"context"
+	"errors"
+	"gorm.io/gorm"
+	"time"
+)
+
+var (
+	ErrRememberMeNotFound = gorm.ErrRecordNotFound
+	// ErrRememberMeRotated 轮换的时候发现 token 已经被别的请求换掉了
+	ErrRememberMeRotated = errors.New("记住我的 token 已经被轮换")
+)
+
+type RememberMeDAO interface {
+	Insert(ctx context.Context, t RememberMeToken) error
+	FindBySeries(ctx context.Context, series string) (RememberMeToken, error)
+	// Rotate 只有 token_hash 还是 oldHash 的时候才会更新，相当于 CAS
+	Rotate(ctx context.Context, series string, oldHash string, newHash string, expiresAt int64) error
+	DeleteBySeries(ctx context.Context, series string) error
+	DeleteByUid(ctx context.Context, uid int64) error
+}
+
+type GORMRememberMeDAO struct {
+	db *gorm.DB
+}
+
+func NewRememberMeDAO(db *gorm.DB) RememberMeDAO {
+	return &GORMRememberMeDAO{
+		db: db,
+	}
+}
+
+func (dao *GORMRememberMeDAO) Insert(ctx context.Context, t RememberMeToken) error {
+	now := time.Now().UnixMilli()
+	t.Ctime = now
+	t.Utime = now
+	return dao.db.WithContext(ctx).Create(&t).Error
+}
+
+func (dao *GORMRememberMeDAO) FindBySeries(ctx context.Context, series string) (RememberMeToken, error) {
+	var t RememberMeToken
+	err := dao.db.WithContext(ctx).Where("series = ?", series).First(&t).Error
+	return t, err
+}
+
+func (dao *GORMRememberMeDAO) Rotate(ctx context.Context, series string,
+	oldHash string, newHash string, expiresAt int64) error {
+	res := dao.db.WithContext(ctx).Model(&RememberMeToken{}).
+		Where("series = ? AND token_hash = ?", series, oldHash).
+		Updates(map[string]any{
+			"token_hash":      newHash,
+			"prev_token_hash": oldHash,
+			"expires_at":      expiresAt,
+			"utime":           time.Now().UnixMilli(),
+		})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrRememberMeRotated
+	}
+	return nil
+}
+
+func (dao *GORMRememberMeDAO) DeleteBySeries(ctx context.Context, series string) error {
+	return dao.db.WithContext(ctx).Where("series = ?", series).
+		Delete(&RememberMeToken{}).Error
+}
+
+func (dao *GORMRememberMeDAO) DeleteByUid(ctx context.Context, uid int64) error {
+	return dao.db.WithContext(ctx).Where("uid = ?", uid).
+		Delete(&RememberMeToken{}).Error
+}
+
+type RememberMeToken struct {
+	Id     int64  `gorm:"primaryKey,autoIncrement"`
+	Series string `gorm:"type:varchar(64);unique"`
+	// sha256 之后的十六进制
+	TokenHash     string `gorm:"type:char(64)"`
+	PrevTokenHash string `gorm:"type:char(64)"`
+	Uid           int64  `gorm:"index"`
+	// 毫秒数
+	ExpiresAt int64
+
+	Ctime int64
+	// 也是上一次轮换 token 的时间
+	Utime int64
+}
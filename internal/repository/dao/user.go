@@ -2,15 +2,22 @@ package dao
 
 import (
 	"context"
+	"database/sql"
+	"encoding/json"
 	"errors"
 	"github.com/go-sql-driver/mysql"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"strings"
 	"time"
 )
 
 var (
-	ErrUserDuplicateEmail = errors.New("邮箱冲突")
-	ErrUserNotFound       = gorm.ErrRecordNotFound
+	ErrUserDuplicateEmail  = errors.New("邮箱冲突")
+	ErrUserDuplicatePhone  = errors.New("手机号冲突")
+	ErrUserNotFound        = gorm.ErrRecordNotFound
+	ErrUserAlreadyVerified = errors.New("已经实名认证过了")
+	ErrUserNotBanned       = errors.New("用户没有被封禁")
 )
 
 type UserDAO struct {
@@ -30,12 +37,35 @@ func (dao *UserDAO) FindByEmail(ctx context.Context, email string) (User, error)
 	return u, err
 }
 
+// FindByEmailCaseInsensitive 走 idx_users_email_lower 这个函数索引，见 InitTable。
+// 大小写不一样的老数据迁移的时候没有合并掉的，返回最早注册的那个
+func (dao *UserDAO) FindByEmailCaseInsensitive(ctx context.Context, email string) (User, error) {
+	var u User
+	err := dao.db.WithContext(ctx).Where("LOWER(email) = LOWER(?)", email).First(&u).Error
+	return u, err
+}
+
+func (dao *UserDAO) FindByPhone(ctx context.Context, phone string) (User, error) {
+	var u User
+	err := dao.db.WithContext(ctx).Where("phone = ?", phone).First(&u).Error
+	return u, err
+}
+
 func (dao *UserDAO) FindByUserId(ctx context.Context, id int64) (User, error) {
 	var u User
 	err := dao.db.WithContext(ctx).Where("id = ?", id).First(&u).Error
 	return u, err
 }
 
+// FindByIds 批量查询，已经注销的不会返回
+func (dao *UserDAO) FindByIds(ctx context.Context, ids []int64) ([]User, error) {
+	var res []User
+	err := dao.db.WithContext(ctx).
+		Where("id IN ? AND status = ?", ids, UserStatusActive).
+		Find(&res).Error
+	return res, err
+}
+
 func (dao *UserDAO) Insert(ctx context.Context, u User) error {
 	// 存毫秒数
 	now := time.Now().UnixMilli()
@@ -45,18 +75,35 @@ func (dao *UserDAO) Insert(ctx context.Context, u User) error {
 	if mysqlErr, ok := err.(*mysql.MySQLError); ok {
 		const uniqueConflictsErrNo uint16 = 1062
 		if mysqlErr.Number == uniqueConflictsErrNo {
-			// 邮箱冲突
-			return ErrUserDuplicateEmail
+			// 邮箱或者手机号冲突
+			return duplicateErr(mysqlErr)
 		}
 	}
 	return err
 }
 
+// duplicateErr 唯一索引冲突的时候，根据是哪个索引冲突了区分邮箱和手机号。
+// MySQL 的错误信息长这样：Duplicate entry '152' for key 'users.phone'，
+// 只看 for key 后面的，不然邮箱里面带了 phone 也会被当成手机号冲突
+func duplicateErr(mysqlErr *mysql.MySQLError) error {
+	idx := strings.LastIndex(mysqlErr.Message, "for key ")
+	if idx >= 0 && strings.Contains(mysqlErr.Message[idx:], "phone") {
+		return ErrUserDuplicatePhone
+	}
+	return ErrUserDuplicateEmail
+}
+
 func (dao *UserDAO) Edit(ctx context.Context, u User) error {
 	// 存毫秒数
 	now := time.Now().UnixMilli()
 	u.Utime = now
-	err := dao.db.WithContext(ctx).Model(&User{Id: u.Id}).Select("Nickname", "birthday", "Brief").Updates(u).Error
+	cols := []string{"Nickname", "birthday", "ShowBirthYear", "Brief", "Gender", "Region", "City",
+		"Location", "Website", "Timezone", "Locale", "Utime"}
+	if u.NicknameChangedAt > 0 {
+		// 只有改了昵称才更新
+		cols = append(cols, "NicknameChangedAt")
+	}
+	err := dao.db.WithContext(ctx).Model(&User{Id: u.Id}).Select(cols).Updates(u).Error
 	if mysqlErr, ok := err.(*mysql.MySQLError); ok {
 		const uniqueConflictsErrNo uint16 = 1062
 		if mysqlErr.Number == uniqueConflictsErrNo {
@@ -66,21 +113,592 @@ func (dao *UserDAO) Edit(ctx context.Context, u User) error {
 	return err
 }
 
+// VerifyEmail 邮箱跟验证的时候不一样了（比如说注销了）就当作不存在。
+// 已经验证过的再验证一次不会改验证时间
+func (dao *UserDAO) VerifyEmail(ctx context.Context, id int64, email string) error {
+	now := time.Now().UnixMilli()
+	res := dao.db.WithContext(ctx).Model(&User{}).
+		Where("id = ? AND email = ? AND status = ?", id, email, UserStatusActive).
+		Updates(map[string]any{
+			"email_verified_at": gorm.Expr("IF(email_verified_at = 0, ?, email_verified_at)", now),
+			"utime":             now,
+		})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+// UpdatePhone 换绑手机号，注销了、停用了的账号不能换。
+// 新手机号已经被别人用了返回 ErrUserDuplicatePhone
+func (dao *UserDAO) UpdatePhone(ctx context.Context, id int64, phone string) error {
+	res := dao.db.WithContext(ctx).Model(&User{}).
+		Where("id = ? AND status = ?", id, UserStatusActive).
+		Updates(map[string]any{
+			"phone": sql.NullString{String: phone, Valid: phone != ""},
+			"utime": time.Now().UnixMilli(),
+		})
+	if mysqlErr, ok := res.Error.(*mysql.MySQLError); ok {
+		const uniqueConflictsErrNo uint16 = 1062
+		if mysqlErr.Number == uniqueConflictsErrNo {
+			return ErrUserDuplicatePhone
+		}
+	}
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+// UpdatePassword 只换密码的哈希，登录的时候换算法用
+func (dao *UserDAO) UpdatePassword(ctx context.Context, id int64, hash string) error {
+	return dao.db.WithContext(ctx).Model(&User{}).Where("id = ?", id).
+		Updates(map[string]any{
+			"password": hash,
+			"utime":    time.Now().UnixMilli(),
+		}).Error
+}
+
+// UpdateNickname 只改昵称，不动 nickname_changed_at，给管理员绕过冷却期用
+func (dao *UserDAO) UpdateNickname(ctx context.Context, id int64, nickname string) error {
+	return dao.db.WithContext(ctx).Model(&User{}).Where("id = ?", id).
+		Updates(map[string]any{
+			"nickname": nickname,
+			"utime":    time.Now().UnixMilli(),
+		}).Error
+}
+
+// UpdateIdentity 实名认证。只有没有认证过的才能更新，
+// 返回 ErrUserAlreadyVerified 说明已经认证过了
+func (dao *UserDAO) UpdateIdentity(ctx context.Context, id int64, idCardHash string, verifiedAt int64) error {
+	res := dao.db.WithContext(ctx).Model(&User{}).
+		Where("id = ? AND verified_at = 0", id).
+		Updates(map[string]any{
+			"id_card_hash": idCardHash,
+			"verified_at":  verifiedAt,
+			"utime":        time.Now().UnixMilli(),
+		})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrUserAlreadyVerified
+	}
+	return nil
+}
+
+// SoftDelete 注销账号，只是标记一下，并不会真的删除。
+// 同时把邮箱和手机号腾出来，这样别人就可以用这个邮箱和手机号注册了，
+// 原本的邮箱和手机号挪到 original_email 和 original_phone 里面，冷静期内还可以找回来
+func (dao *UserDAO) SoftDelete(ctx context.Context, id int64) error {
+	return dao.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var u User
+		err := tx.Where("id = ? AND status = ?", id, UserStatusActive).First(&u).Error
+		if err != nil {
+			return err
+		}
+		now := time.Now().UnixMilli()
+		return tx.Model(&User{Id: id}).Updates(map[string]any{
+			"original_email": u.Email.String,
+			"original_phone": u.Phone.String,
+			"email":          sql.NullString{},
+			"phone":          sql.NullString{},
+			"status":         UserStatusDeleted,
+			"deleted_at":     now,
+			"utime":          now,
+		}).Error
+	})
+}
+
+// FindDeletedByEmail 按照注销前的邮箱查找还没有被彻底删除的账号
+func (dao *UserDAO) FindDeletedByEmail(ctx context.Context, email string) (User, error) {
+	var u User
+	err := dao.db.WithContext(ctx).
+		Where("original_email = ? AND status = ?", email, UserStatusDeleted).
+		Order("deleted_at DESC").First(&u).Error
+	return u, err
+}
+
+// Reactivate 冷静期内恢复账号，把邮箱和手机号还回去
+func (dao *UserDAO) Reactivate(ctx context.Context, id int64) error {
+	err := dao.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var u User
+		err := tx.Where("id = ? AND status = ?", id, UserStatusDeleted).First(&u).Error
+		if err != nil {
+			return err
+		}
+		return tx.Model(&User{Id: id}).Updates(map[string]any{
+			"email": sql.NullString{
+				String: u.OriginalEmail,
+				Valid:  u.OriginalEmail != "",
+			},
+			"phone": sql.NullString{
+				String: u.OriginalPhone,
+				Valid:  u.OriginalPhone != "",
+			},
+			"original_email": "",
+			"original_phone": "",
+			"status":         UserStatusActive,
+			"deleted_at":     0,
+			"utime":          time.Now().UnixMilli(),
+		}).Error
+	})
+	if mysqlErr, ok := err.(*mysql.MySQLError); ok {
+		const uniqueConflictsErrNo uint16 = 1062
+		if mysqlErr.Number == uniqueConflictsErrNo {
+			// 冷静期内邮箱或者手机号被别人注册了
+			return duplicateErr(mysqlErr)
+		}
+	}
+	return err
+}
+
+// Deactivate 用户自己停用账号。跟注销不一样，邮箱和手机号不腾出来，停用期间别人不能拿去注册
+func (dao *UserDAO) Deactivate(ctx context.Context, id int64) error {
+	res := dao.db.WithContext(ctx).Model(&User{}).
+		Where("id = ? AND status = ?", id, UserStatusActive).
+		Updates(map[string]any{
+			"status": UserStatusDeactivated,
+			"utime":  time.Now().UnixMilli(),
+		})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+// EndDeactivation 停用的账号重新登录之后恢复正常
+func (dao *UserDAO) EndDeactivation(ctx context.Context, id int64) error {
+	res := dao.db.WithContext(ctx).Model(&User{}).
+		Where("id = ? AND status = ?", id, UserStatusDeactivated).
+		Updates(map[string]any{
+			"status": UserStatusActive,
+			"utime":  time.Now().UnixMilli(),
+		})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+// FindDeletedBefore 找出在 before（毫秒数）之前注销的账号
+func (dao *UserDAO) FindDeletedBefore(ctx context.Context, before int64, limit int) ([]User, error) {
+	var res []User
+	err := dao.db.WithContext(ctx).
+		Where("status = ? AND deleted_at < ?", UserStatusDeleted, before).
+		Limit(limit).Find(&res).Error
+	return res, err
+}
+
+// HardDelete 彻底删除，登录记录、API key、备用码和记住我也一起删掉。
+// 带上状态和时间的条件，避免删掉了刚刚恢复的账号，没删到的话别的也不动
+func (dao *UserDAO) HardDelete(ctx context.Context, id int64, before int64) error {
+	return dao.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Where("id = ? AND status = ? AND deleted_at < ?", id, UserStatusDeleted, before).
+			Delete(&User{})
+		if res.Error != nil || res.RowsAffected == 0 {
+			return res.Error
+		}
+		if err := tx.Where("user_id = ?", id).Delete(&LoginEvent{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", id).Delete(&APIKey{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", id).Delete(&BackupCode{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("uid = ?", id).Delete(&RememberMeToken{}).Error
+	})
+}
+
+// UserListQuery 后台查用户列表，零值的条件都是不限制
+type UserListQuery struct {
+	// 邮箱包含这一段，LIKE '%x%' 用不上索引，只能扫
+	Email string
+	// 手机号完全一样，走唯一索引
+	Phone  string
+	Status *uint8
+	// 毫秒数，[CtimeStart, CtimeEnd)
+	CtimeStart int64
+	CtimeEnd   int64
+	// id 或者 ctime，别的值都当作 id
+	SortBy string
+	Desc   bool
+	// 上一页最后一条的 id 和 ctime，AfterId > 0 的时候不用 Offset
+	AfterId    int64
+	AfterCtime int64
+	Offset     int
+	Limit      int
+}
+
+// List 深翻页要用 AfterId，OFFSET 越大扫的行越多
+func (dao *UserDAO) List(ctx context.Context, q UserListQuery) ([]User, error) {
+	var res []User
+	err := listUsersQuery(dao.db.WithContext(ctx), q).Find(&res).Error
+	return res, err
+}
+
+// Count 只用过滤条件，不管翻页。数据多了很慢，前端明确要的时候才查
+func (dao *UserDAO) Count(ctx context.Context, q UserListQuery) (int64, error) {
+	var cnt int64
+	err := filterUsersQuery(dao.db.WithContext(ctx).Model(&User{}), q).Count(&cnt).Error
+	return cnt, err
+}
+
+// CountByStatus 每种状态有多少个用户，没有用户的状态不在 map 里面
+func (dao *UserDAO) CountByStatus(ctx context.Context) (map[uint8]int64, error) {
+	var rows []struct {
+		Status uint8
+		Cnt    int64
+	}
+	err := dao.db.WithContext(ctx).Model(&User{}).
+		Select("status, COUNT(*) AS cnt").Group("status").Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[uint8]int64, len(rows))
+	for _, row := range rows {
+		res[row.Status] = row.Cnt
+	}
+	return res, nil
+}
+
+// CountBanned 正常状态的用户里面，now 的时候还在封禁中的有多少个
+func (dao *UserDAO) CountBanned(ctx context.Context, now int64) (int64, error) {
+	var cnt int64
+	err := dao.db.WithContext(ctx).Model(&User{}).
+		Where("status = ? AND banned_at > 0 AND (banned_until = 0 OR banned_until > ?)", UserStatusActive, now).
+		Count(&cnt).Error
+	return cnt, err
+}
+
+// CountCreatedBetween [start, end) 之间注册的，毫秒数
+func (dao *UserDAO) CountCreatedBetween(ctx context.Context, start, end int64) (int64, error) {
+	var cnt int64
+	err := dao.db.WithContext(ctx).Model(&User{}).
+		Where("ctime >= ? AND ctime < ?", start, end).Count(&cnt).Error
+	return cnt, err
+}
+
+func filterUsersQuery(db *gorm.DB, q UserListQuery) *gorm.DB {
+	if q.Email != "" {
+		db = db.Where("email LIKE ?", "%"+escapeLike(q.Email)+"%")
+	}
+	if q.Phone != "" {
+		db = db.Where("phone = ?", q.Phone)
+	}
+	if q.Status != nil {
+		db = db.Where("status = ?", *q.Status)
+	}
+	if q.CtimeStart > 0 {
+		db = db.Where("ctime >= ?", q.CtimeStart)
+	}
+	if q.CtimeEnd > 0 {
+		db = db.Where("ctime < ?", q.CtimeEnd)
+	}
+	return db
+}
+
+// listUsersQuery 按 ctime 排序的时候 ctime 可能重复，再按 id 排保证顺序是确定的，
+// 翻页的条件也要带上 id
+func listUsersQuery(db *gorm.DB, q UserListQuery) *gorm.DB {
+	db = filterUsersQuery(db, q)
+	op, dir := ">", "ASC"
+	if q.Desc {
+		op, dir = "<", "DESC"
+	}
+	if q.SortBy == "ctime" {
+		if q.AfterId > 0 {
+			// 跟别的条件一起的时候 GORM 会自己加上括号
+			db = db.Where("ctime "+op+" ? OR (ctime = ? AND id "+op+" ?)",
+				q.AfterCtime, q.AfterCtime, q.AfterId)
+		}
+		db = db.Order("ctime " + dir + ", id " + dir)
+	} else {
+		if q.AfterId > 0 {
+			db = db.Where("id "+op+" ?", q.AfterId)
+		}
+		db = db.Order("id " + dir)
+	}
+	if q.AfterId <= 0 && q.Offset > 0 {
+		db = db.Offset(q.Offset)
+	}
+	return db.Limit(q.Limit)
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// escapeLike 用户输入的 % 和 _ 当成普通字符
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
+
+// Ban 封禁用户，同时记一条封禁记录和审计日志。
+// 已经在封禁中的，以这一次为准，之前的记录标记为已经解除
+func (dao *UserDAO) Ban(ctx context.Context, b UserBan, log AdminAuditLog) error {
+	return dao.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&User{}).Where("id = ? AND status = ?", b.Uid, UserStatusActive).
+			Updates(map[string]any{
+				"banned_at":    b.Ctime,
+				"banned_until": b.Until,
+				"ban_reason":   b.Reason,
+				"utime":        b.Ctime,
+			})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrUserNotFound
+		}
+		err := liftBans(tx, b.Uid, b.Operator, b.Ctime)
+		if err != nil {
+			return err
+		}
+		if err = tx.Create(&b).Error; err != nil {
+			return err
+		}
+		return tx.Create(&log).Error
+	})
+}
+
+// InsertAuditLog 不跟着别的修改一起的审计日志，比如说代办登录
+func (dao *UserDAO) InsertAuditLog(ctx context.Context, log AdminAuditLog) error {
+	return dao.db.WithContext(ctx).Create(&log).Error
+}
+
+// Unban 提前解封，已经过期了的封禁也可以解，相当于清理掉。返回解封之前的数据
+func (dao *UserDAO) Unban(ctx context.Context, uid int64, operator int64, log AdminAuditLog) (User, error) {
+	var u User
+	err := dao.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("id = ?", uid).First(&u).Error
+		if err != nil {
+			return err
+		}
+		if u.BannedAt == 0 {
+			return ErrUserNotBanned
+		}
+		err = tx.Model(&User{Id: uid}).Updates(map[string]any{
+			"banned_at":    0,
+			"banned_until": 0,
+			"ban_reason":   "",
+			"utime":        log.Ctime,
+		}).Error
+		if err != nil {
+			return err
+		}
+		if err = liftBans(tx, uid, operator, log.Ctime); err != nil {
+			return err
+		}
+		return tx.Create(&log).Error
+	})
+	return u, err
+}
+
+// MergeUsers 把 secondaryId 合并到 primaryId 上面。
+// 主账号没有的邮箱、手机号、实名信息从副账号挪过来，两边都有的以主账号为准，副账号的丢掉。
+// API key、封禁记录和登录记录归到主账号，副账号的记住我全部删掉，副账号标记为已经合并。
+// 挪了哪些、丢了哪些写在审计日志的 Detail 里面，只记字段名，不记具体的值
+func (dao *UserDAO) MergeUsers(ctx context.Context, primaryId, secondaryId int64, log AdminAuditLog) error {
+	err := dao.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// 按主键顺序加锁，两个管理员反着合并也不会死锁
+		var us []User
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id IN ? AND status = ?", []int64{primaryId, secondaryId}, UserStatusActive).
+			Order("id").Find(&us).Error
+		if err != nil {
+			return err
+		}
+		if len(us) != 2 {
+			return ErrUserNotFound
+		}
+		p, s := us[0], us[1]
+		if p.Id != primaryId {
+			p, s = s, p
+		}
+		updates, detail := mergeFields(p, s)
+		now := log.Ctime
+		// 先把副账号的唯一索引腾出来，主账号才能用
+		err = tx.Model(&User{Id: s.Id}).Updates(map[string]any{
+			"email":       sql.NullString{},
+			"phone":       sql.NullString{},
+			"status":      UserStatusMerged,
+			"merged_into": p.Id,
+			"deleted_at":  now,
+			"utime":       now,
+		}).Error
+		if err != nil {
+			return err
+		}
+		updates["utime"] = now
+		if err = tx.Model(&User{Id: p.Id}).Updates(updates).Error; err != nil {
+			return err
+		}
+		err = tx.Model(&APIKey{}).Where("user_id = ?", s.Id).
+			Updates(map[string]any{"user_id": p.Id, "utime": now}).Error
+		if err != nil {
+			return err
+		}
+		err = tx.Model(&UserBan{}).Where("uid = ?", s.Id).Update("uid", p.Id).Error
+		if err != nil {
+			return err
+		}
+		// 登录记录也跟着走，不然主账号的登录历史里面看不到副账号以前的登录
+		err = tx.Model(&LoginEvent{}).Where("user_id = ?", s.Id).Update("user_id", p.Id).Error
+		if err != nil {
+			return err
+		}
+		err = tx.Where("uid = ?", s.Id).Delete(&RememberMeToken{}).Error
+		if err != nil {
+			return err
+		}
+		detail.Secondary = s.Id
+		val, err := json.Marshal(detail)
+		if err != nil {
+			return err
+		}
+		log.Detail = string(val)
+		return tx.Create(&log).Error
+	})
+	if mysqlErr, ok := err.(*mysql.MySQLError); ok {
+		const uniqueConflictsErrNo uint16 = 1062
+		if mysqlErr.Number == uniqueConflictsErrNo {
+			return duplicateErr(mysqlErr)
+		}
+	}
+	return err
+}
+
+type mergeDetail struct {
+	Secondary int64 `json:"secondary"`
+	// 从副账号挪到主账号的字段
+	Moved []string `json:"moved"`
+	// 两边都有，副账号的丢掉了
+	Dropped []string `json:"dropped"`
+}
+
+// mergeFields 算出主账号要更新的列
+func mergeFields(p, s User) (map[string]any, mergeDetail) {
+	updates := make(map[string]any)
+	detail := mergeDetail{Moved: []string{}, Dropped: []string{}}
+	switch {
+	case !p.Email.Valid && s.Email.Valid:
+		updates["email"] = s.Email
+		updates["email_verified_at"] = s.EmailVerifiedAt
+		detail.Moved = append(detail.Moved, "email")
+		// 用手机号注册的没有密码，邮箱过来了密码也要跟着过来，不然邮箱登录不了
+		if p.Password == "" && s.Password != "" {
+			updates["password"] = s.Password
+			detail.Moved = append(detail.Moved, "password")
+		}
+	case p.Email.Valid && s.Email.Valid:
+		detail.Dropped = append(detail.Dropped, "email")
+	}
+	switch {
+	case !p.Phone.Valid && s.Phone.Valid:
+		updates["phone"] = s.Phone
+		detail.Moved = append(detail.Moved, "phone")
+	case p.Phone.Valid && s.Phone.Valid:
+		detail.Dropped = append(detail.Dropped, "phone")
+	}
+	switch {
+	case p.IdCardHash == "" && s.IdCardHash != "":
+		updates["id_card_hash"] = s.IdCardHash
+		updates["verified_at"] = s.VerifiedAt
+		detail.Moved = append(detail.Moved, "identity")
+	case p.IdCardHash != "" && s.IdCardHash != "" && p.IdCardHash != s.IdCardHash:
+		detail.Dropped = append(detail.Dropped, "identity")
+	}
+	return updates, detail
+}
+
+// liftBans 把还没解除的封禁记录标记为已经解除
+func liftBans(tx *gorm.DB, uid int64, operator int64, now int64) error {
+	return tx.Model(&UserBan{}).Where("uid = ? AND lifted_at = ?", uid, 0).
+		Updates(map[string]any{
+			"lifted_by": operator,
+			"lifted_at": now,
+		}).Error
+}
+
+const (
+	UserStatusActive uint8 = iota
+	UserStatusDeleted
+	// UserStatusMerged 合并到别的账号了，不能登录也不能恢复，清理注销账号的任务也不会删它
+	UserStatusMerged
+	// UserStatusDeactivated 用户自己停用的，邮箱和手机号还占着
+	UserStatusDeactivated
+)
+
 // User 直接对应数据库表结构
 // 有些人叫做 entity，有些人叫做 model，有些人叫做 PO(persistent object)
 type User struct {
 	Id int64 `gorm:"primaryKey,autoIncrement"`
 	// 全部用户唯一
-	Email    string `gorm:"unique"`
+	// 用手机号注册的用户没有邮箱，唯一索引允许有多个空值
+	Email    sql.NullString `gorm:"unique"`
 	Password string
+	Phone    sql.NullString `gorm:"unique"`
 
 	// 往这面加
 	Nickname string
 	Birthday string
-	Brief    string
+	// 公开的资料里面生日带不带年份
+	ShowBirthYear bool `gorm:"not null;default:false"`
+	Brief         string
+	// 性别，0 未知 1 男 2 女 3 其它，老数据自动迁移的时候默认就是 0
+	Gender uint8 `gorm:"type:tinyint;not null;default:0"`
+	// 省和地级市的行政区划代码，只存代码不存名字
+	Region string `gorm:"type:char(6);not null;default:''"`
+	City   string `gorm:"type:char(6);not null;default:''"`
+	// 海外用户自己填的地区
+	Location string
+	Website  string `gorm:"type:varchar(255);not null;default:''"`
+	// IANA 时区名
+	Timezone string `gorm:"type:varchar(64);not null;default:''"`
+	// BCP 47 的语言标签
+	Locale string `gorm:"type:varchar(35);not null;default:''"`
+	// 上一次修改昵称的时间，毫秒数，0 就是还没改过
+	NicknameChangedAt int64
+
+	Status uint8
+	// 身份证号码的哈希值
+	IdCardHash string `gorm:"index"`
+	// 实名认证的时间，毫秒数，0 就是没有认证过
+	VerifiedAt int64
+	// 邮箱验证的时间，毫秒数，0 就是还没有验证
+	EmailVerifiedAt int64
+	// 注销时间，毫秒数
+	DeletedAt int64 `gorm:"index"`
+	// 注销前的邮箱和手机号，冷静期内用来找回账号
+	OriginalEmail string `gorm:"index"`
+	OriginalPhone string
+	// 合并到了哪个账号，0 就是没有合并过
+	MergedInto int64
+
+	// 封禁时间，毫秒数，0 就是没有被封禁
+	BannedAt int64
+	// 自动解封的时间，毫秒数，0 是永久封禁。过期之后不会改这几个字段，读的时候判断
+	BannedUntil int64
+	BanReason   string
+
+	// 注册的时候用的是一次性邮箱，后台看到了再决定要不要处理
+	DisposableEmail bool
 
-	// 创建时间，毫秒数
-	Ctime int64
+	// 创建时间，毫秒数。后台按注册时间查和排序要用索引
+	Ctime int64 `gorm:"index"`
 	// 更新时间，毫秒数
 	Utime int64
 }
@@ -59,7 +59,37 @@ func TestGORMUserDAO_Insert(t *testing.T) {
 				return mockDB
 			},
 			user:    User{},
-			wantErr: ErrUserDuplicate,
+			wantErr: ErrUserDuplicateEmail,
+		},
+		{
+			name: "手机号冲突",
+			mock: func(t *testing.T) *sql.DB {
+				mockDB, mock, err := sqlmock.New()
+				mock.ExpectExec("INSERT INTO `users` .*").
+					WillReturnError(&mysql.MySQLError{
+						Number:  1062,
+						Message: "Duplicate entry '15212345678' for key 'users.phone'",
+					})
+				require.NoError(t, err)
+				return mockDB
+			},
+			user:    User{},
+			wantErr: ErrUserDuplicatePhone,
+		},
+		{
+			name: "邮箱里面带了 phone 也是邮箱冲突",
+			mock: func(t *testing.T) *sql.DB {
+				mockDB, mock, err := sqlmock.New()
+				mock.ExpectExec("INSERT INTO `users` .*").
+					WillReturnError(&mysql.MySQLError{
+						Number:  1062,
+						Message: "Duplicate entry 'phone@qq.com' for key 'users.email'",
+					})
+				require.NoError(t, err)
+				return mockDB
+			},
+			user:    User{},
+			wantErr: ErrUserDuplicateEmail,
 		},
 		{
 			name: "数据库错误",
@@ -104,3 +134,557 @@ func TestGORMUserDAO_Insert(t *testing.T) {
 		// COMMIT;
 	}
 }
+
+func TestGORMUserDAO_FindByIds(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	// 一条 SQL 查完，注销了的不要
+	mock.ExpectQuery("SELECT \\* FROM `users` WHERE id IN \\(\\?,\\?\\) AND status = \\?").
+		WithArgs(int64(1), int64(2), UserStatusActive).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "nickname"}).
+			AddRow(1, "Tom"))
+	db, err := gorm.Open(gormMysql.New(gormMysql.Config{
+		Conn:                      mockDB,
+		SkipInitializeWithVersion: true,
+	}), &gorm.Config{
+		DisableAutomaticPing:   true,
+		SkipDefaultTransaction: true,
+	})
+	require.NoError(t, err)
+	us, err := NewUserDAO(db).FindByIds(context.Background(), []int64{1, 2})
+	require.NoError(t, err)
+	assert.Equal(t, []User{{Id: 1, Nickname: "Tom"}}, us)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestBackfillUserCtime(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	mock.ExpectExec("UPDATE `users` SET `ctime`=utime WHERE ctime = \\? AND utime > \\?").
+		WithArgs(0, 0).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	db, err := gorm.Open(gormMysql.New(gormMysql.Config{
+		Conn:                      mockDB,
+		SkipInitializeWithVersion: true,
+	}), &gorm.Config{
+		DisableAutomaticPing:   true,
+		SkipDefaultTransaction: true,
+	})
+	require.NoError(t, err)
+	assert.NoError(t, backfillUserCtime(db))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// 第二个改成小写以后跟第一个冲突了，跳过，不影响后面的
+func TestLowercaseUserEmails(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	mock.ExpectQuery("SELECT `id`,`email` FROM `users` WHERE email IS NOT NULL AND BINARY email <> LOWER\\(email\\) ORDER BY id").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "email"}).
+			AddRow(1, "User@Test.com").
+			AddRow(2, "USER@test.com").
+			AddRow(3, "Tom@QQ.com"))
+	mock.ExpectExec("UPDATE `users` SET `email`=LOWER\\(email\\) WHERE `id` = \\?").
+		WithArgs(int64(1)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE `users` SET `email`=LOWER\\(email\\) WHERE `id` = \\?").
+		WithArgs(int64(2)).
+		WillReturnError(&mysql.MySQLError{
+			Number:  1062,
+			Message: "Duplicate entry 'user@test.com' for key 'users.email'",
+		})
+	mock.ExpectExec("UPDATE `users` SET `email`=LOWER\\(email\\) WHERE `id` = \\?").
+		WithArgs(int64(3)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	db, err := gorm.Open(gormMysql.New(gormMysql.Config{
+		Conn:                      mockDB,
+		SkipInitializeWithVersion: true,
+	}), &gorm.Config{
+		DisableAutomaticPing:   true,
+		SkipDefaultTransaction: true,
+	})
+	require.NoError(t, err)
+	assert.NoError(t, lowercaseUserEmails(db))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGORMUserDAO_FindByEmailCaseInsensitive(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	mock.ExpectQuery("SELECT \\* FROM `users` WHERE LOWER\\(email\\) = LOWER\\(\\?\\) ORDER BY `users`.`id` LIMIT 1").
+		WithArgs("USER@test.com").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "email"}).AddRow(1, "user@test.com"))
+	db, err := gorm.Open(gormMysql.New(gormMysql.Config{
+		Conn:                      mockDB,
+		SkipInitializeWithVersion: true,
+	}), &gorm.Config{
+		DisableAutomaticPing:   true,
+		SkipDefaultTransaction: true,
+	})
+	require.NoError(t, err)
+	u, err := NewUserDAO(db).FindByEmailCaseInsensitive(context.Background(), "USER@test.com")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), u.Id)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGORMUserDAO_Ban(t *testing.T) {
+	testCases := []struct {
+		name string
+		mock func(mock sqlmock.Sqlmock)
+
+		wantErr error
+	}{
+		{
+			name: "封禁成功",
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec("UPDATE `users` SET .* WHERE id = \\? AND status = \\?").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				// 之前的封禁被这一次覆盖
+				mock.ExpectExec("UPDATE `user_bans` SET `lifted_at`=\\?,`lifted_by`=\\? WHERE uid = \\? AND lifted_at = \\?").
+					WithArgs(int64(1000), int64(1), int64(3), 0).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec("INSERT INTO `user_bans` .*").
+					WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectExec("INSERT INTO `admin_audit_logs` .*").
+					WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectCommit()
+			},
+		},
+		{
+			name: "用户不存在",
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec("UPDATE `users` SET .* WHERE id = \\? AND status = \\?").
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectRollback()
+			},
+			wantErr: ErrUserNotFound,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockDB, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			tc.mock(mock)
+			db, err := gorm.Open(gormMysql.New(gormMysql.Config{
+				Conn:                      mockDB,
+				SkipInitializeWithVersion: true,
+			}), &gorm.Config{
+				DisableAutomaticPing:   true,
+				SkipDefaultTransaction: true,
+			})
+			require.NoError(t, err)
+			err = NewUserDAO(db).Ban(context.Background(), UserBan{
+				Uid:      3,
+				Operator: 1,
+				Reason:   "广告",
+				Ctime:    1000,
+			}, AdminAuditLog{
+				Operator:  1,
+				Action:    "ban_user",
+				TargetUid: 3,
+				Ctime:     1000,
+			})
+			assert.Equal(t, tc.wantErr, err)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestListUsersQuery(t *testing.T) {
+	deleted := UserStatusDeleted
+	testCases := []struct {
+		name string
+		q    UserListQuery
+
+		wantSQL       string
+		wantVars      []any
+		wantCountSQL  string
+		wantCountVars []any
+	}{
+		{
+			name:         "没有条件",
+			q:            UserListQuery{Limit: 20},
+			wantSQL:      "SELECT * FROM `users` ORDER BY id ASC LIMIT 20",
+			wantCountSQL: "SELECT count(*) FROM `users`",
+		},
+		{
+			name:          "邮箱",
+			q:             UserListQuery{Email: "qq.com", Limit: 20},
+			wantSQL:       "SELECT * FROM `users` WHERE email LIKE ? ORDER BY id ASC LIMIT 20",
+			wantVars:      []any{"%qq.com%"},
+			wantCountSQL:  "SELECT count(*) FROM `users` WHERE email LIKE ?",
+			wantCountVars: []any{"%qq.com%"},
+		},
+		{
+			name:          "邮箱里面的通配符当成普通字符",
+			q:             UserListQuery{Email: `a_b%c\`, Limit: 20},
+			wantSQL:       "SELECT * FROM `users` WHERE email LIKE ? ORDER BY id ASC LIMIT 20",
+			wantVars:      []any{`%a\_b\%c\\%`},
+			wantCountSQL:  "SELECT count(*) FROM `users` WHERE email LIKE ?",
+			wantCountVars: []any{`%a\_b\%c\\%`},
+		},
+		{
+			name:          "手机号",
+			q:             UserListQuery{Phone: "15212345678", Limit: 20},
+			wantSQL:       "SELECT * FROM `users` WHERE phone = ? ORDER BY id ASC LIMIT 20",
+			wantVars:      []any{"15212345678"},
+			wantCountSQL:  "SELECT count(*) FROM `users` WHERE phone = ?",
+			wantCountVars: []any{"15212345678"},
+		},
+		{
+			name:          "状态",
+			q:             UserListQuery{Status: &deleted, Limit: 20},
+			wantSQL:       "SELECT * FROM `users` WHERE status = ? ORDER BY id ASC LIMIT 20",
+			wantVars:      []any{UserStatusDeleted},
+			wantCountSQL:  "SELECT count(*) FROM `users` WHERE status = ?",
+			wantCountVars: []any{UserStatusDeleted},
+		},
+		{
+			name:          "只有开始时间",
+			q:             UserListQuery{CtimeStart: 1000, Limit: 20},
+			wantSQL:       "SELECT * FROM `users` WHERE ctime >= ? ORDER BY id ASC LIMIT 20",
+			wantVars:      []any{int64(1000)},
+			wantCountSQL:  "SELECT count(*) FROM `users` WHERE ctime >= ?",
+			wantCountVars: []any{int64(1000)},
+		},
+		{
+			name:          "注册时间范围",
+			q:             UserListQuery{CtimeStart: 1000, CtimeEnd: 2000, Limit: 20},
+			wantSQL:       "SELECT * FROM `users` WHERE ctime >= ? AND ctime < ? ORDER BY id ASC LIMIT 20",
+			wantVars:      []any{int64(1000), int64(2000)},
+			wantCountSQL:  "SELECT count(*) FROM `users` WHERE ctime >= ? AND ctime < ?",
+			wantCountVars: []any{int64(1000), int64(2000)},
+		},
+		{
+			name: "全部条件",
+			q: UserListQuery{Email: "qq.com", Phone: "15212345678", Status: &deleted,
+				CtimeStart: 1000, CtimeEnd: 2000, SortBy: "ctime", Desc: true, Limit: 20},
+			wantSQL: "SELECT * FROM `users` WHERE email LIKE ? AND phone = ? AND status = ? " +
+				"AND ctime >= ? AND ctime < ? ORDER BY ctime DESC, id DESC LIMIT 20",
+			wantVars: []any{"%qq.com%", "15212345678", UserStatusDeleted, int64(1000), int64(2000)},
+			wantCountSQL: "SELECT count(*) FROM `users` WHERE email LIKE ? AND phone = ? AND status = ? " +
+				"AND ctime >= ? AND ctime < ?",
+			wantCountVars: []any{"%qq.com%", "15212345678", UserStatusDeleted, int64(1000), int64(2000)},
+		},
+		{
+			name:         "页码",
+			q:            UserListQuery{Offset: 40, Desc: true, Limit: 20},
+			wantSQL:      "SELECT * FROM `users` ORDER BY id DESC LIMIT 20 OFFSET 40",
+			wantCountSQL: "SELECT count(*) FROM `users`",
+		},
+		{
+			name:         "按 id 倒序翻页",
+			q:            UserListQuery{AfterId: 100, Desc: true, Limit: 20},
+			wantSQL:      "SELECT * FROM `users` WHERE id < ? ORDER BY id DESC LIMIT 20",
+			wantVars:     []any{int64(100)},
+			wantCountSQL: "SELECT count(*) FROM `users`",
+		},
+		{
+			name:         "按 id 正序翻页",
+			q:            UserListQuery{AfterId: 100, Limit: 20},
+			wantSQL:      "SELECT * FROM `users` WHERE id > ? ORDER BY id ASC LIMIT 20",
+			wantVars:     []any{int64(100)},
+			wantCountSQL: "SELECT count(*) FROM `users`",
+		},
+		{
+			name:         "按注册时间倒序翻页",
+			q:            UserListQuery{SortBy: "ctime", AfterId: 100, AfterCtime: 1500, Desc: true, Limit: 20},
+			wantSQL:      "SELECT * FROM `users` WHERE ctime < ? OR (ctime = ? AND id < ?) ORDER BY ctime DESC, id DESC LIMIT 20",
+			wantVars:     []any{int64(1500), int64(1500), int64(100)},
+			wantCountSQL: "SELECT count(*) FROM `users`",
+		},
+		{
+			name: "按注册时间正序翻页，带上过滤条件",
+			q: UserListQuery{Status: &deleted, CtimeStart: 1000, SortBy: "ctime",
+				AfterId: 100, AfterCtime: 1500, Limit: 20},
+			wantSQL: "SELECT * FROM `users` WHERE status = ? AND ctime >= ? " +
+				"AND (ctime > ? OR (ctime = ? AND id > ?)) ORDER BY ctime ASC, id ASC LIMIT 20",
+			wantVars:      []any{UserStatusDeleted, int64(1000), int64(1500), int64(1500), int64(100)},
+			wantCountSQL:  "SELECT count(*) FROM `users` WHERE status = ? AND ctime >= ?",
+			wantCountVars: []any{UserStatusDeleted, int64(1000)},
+		},
+		{
+			name:         "有游标就不用 OFFSET",
+			q:            UserListQuery{AfterId: 100, Offset: 40, Desc: true, Limit: 20},
+			wantSQL:      "SELECT * FROM `users` WHERE id < ? ORDER BY id DESC LIMIT 20",
+			wantVars:     []any{int64(100)},
+			wantCountSQL: "SELECT count(*) FROM `users`",
+		},
+		{
+			name:         "不认识的排序字段按 id",
+			q:            UserListQuery{SortBy: "password", Limit: 20},
+			wantSQL:      "SELECT * FROM `users` ORDER BY id ASC LIMIT 20",
+			wantCountSQL: "SELECT count(*) FROM `users`",
+		},
+	}
+
+	mockDB, _, err := sqlmock.New()
+	require.NoError(t, err)
+	// 只生成 SQL，不执行
+	db, err := gorm.Open(gormMysql.New(gormMysql.Config{
+		Conn:                      mockDB,
+		SkipInitializeWithVersion: true,
+	}), &gorm.Config{
+		DryRun:               true,
+		DisableAutomaticPing: true,
+	})
+	require.NoError(t, err)
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			stmt := listUsersQuery(db, tc.q).Find(&[]User{}).Statement
+			assert.Equal(t, tc.wantSQL, stmt.SQL.String())
+			assert.Equal(t, tc.wantVars, nilIfEmpty(stmt.Vars))
+
+			// 总数不管翻页
+			stmt = filterUsersQuery(db.Model(&User{}), tc.q).Count(new(int64)).Statement
+			assert.Equal(t, tc.wantCountSQL, stmt.SQL.String())
+			assert.Equal(t, tc.wantCountVars, nilIfEmpty(stmt.Vars))
+		})
+	}
+}
+
+func nilIfEmpty(vars []any) []any {
+	if len(vars) == 0 {
+		return nil
+	}
+	return vars
+}
+
+func TestGORMUserDAO_MergeUsers(t *testing.T) {
+	cols := []string{"id", "email", "phone", "password", "status"}
+	testCases := []struct {
+		name string
+		mock func(mock sqlmock.Sqlmock)
+
+		wantErr error
+	}{
+		{
+			name: "合并成功",
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery("SELECT \\* FROM `users` WHERE id IN \\(\\?,\\?\\) AND status = \\? ORDER BY id FOR UPDATE").
+					WithArgs(int64(5), int64(3), UserStatusActive).
+					WillReturnRows(sqlmock.NewRows(cols).
+						AddRow(3, "123@qq.com", "15212345678", "hash", UserStatusActive).
+						AddRow(5, nil, "15287654321", "", UserStatusActive))
+				// 副账号先把邮箱和手机号腾出来
+				mock.ExpectExec("UPDATE `users` SET `deleted_at`=\\?,`email`=\\?,`merged_into`=\\?,`phone`=\\?,`status`=\\?,`utime`=\\? WHERE `id` = \\?").
+					WithArgs(int64(1000), nil, int64(5), nil, UserStatusMerged, int64(1000), int64(3)).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec("UPDATE `users` SET `email`=\\?,`email_verified_at`=\\?,`password`=\\?,`utime`=\\? WHERE `id` = \\?").
+					WithArgs("123@qq.com", int64(0), "hash", int64(1000), int64(5)).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec("UPDATE `api_keys` SET `user_id`=\\?,`utime`=\\? WHERE user_id = \\?").
+					WithArgs(int64(5), int64(1000), int64(3)).
+					WillReturnResult(sqlmock.NewResult(0, 2))
+				mock.ExpectExec("UPDATE `user_bans` SET `uid`=\\? WHERE uid = \\?").
+					WithArgs(int64(5), int64(3)).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec("UPDATE `login_events` SET `user_id`=\\? WHERE user_id = \\?").
+					WithArgs(int64(5), int64(3)).
+					WillReturnResult(sqlmock.NewResult(0, 4))
+				mock.ExpectExec("DELETE FROM `remember_me_tokens` WHERE uid = \\?").
+					WithArgs(int64(3)).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec("INSERT INTO `admin_audit_logs` .*").
+					WithArgs(int64(1), "merge_user", int64(5),
+						`{"secondary":3,"moved":["email","password"],"dropped":["phone"]}`, int64(1000)).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectCommit()
+			},
+		},
+		{
+			name: "有一个账号不存在",
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery("SELECT \\* FROM `users` .* FOR UPDATE").
+					WillReturnRows(sqlmock.NewRows(cols).
+						AddRow(5, "123@qq.com", nil, "hash", UserStatusActive))
+				mock.ExpectRollback()
+			},
+			wantErr: ErrUserNotFound,
+		},
+		{
+			name: "挪登录记录失败",
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery("SELECT \\* FROM `users` .* FOR UPDATE").
+					WillReturnRows(sqlmock.NewRows(cols).
+						AddRow(3, nil, "15212345678", "", UserStatusActive).
+						AddRow(5, "123@qq.com", nil, "hash", UserStatusActive))
+				mock.ExpectExec("UPDATE `users` .*").WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec("UPDATE `users` .*").WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec("UPDATE `api_keys` .*").WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec("UPDATE `user_bans` .*").WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec("UPDATE `login_events` SET `user_id`=\\? WHERE user_id = \\?").
+					WithArgs(int64(5), int64(3)).
+					WillReturnError(errors.New("mock db 错误"))
+				mock.ExpectRollback()
+			},
+			wantErr: errors.New("mock db 错误"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockDB, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			tc.mock(mock)
+			db, err := gorm.Open(gormMysql.New(gormMysql.Config{
+				Conn:                      mockDB,
+				SkipInitializeWithVersion: true,
+			}), &gorm.Config{
+				DisableAutomaticPing:   true,
+				SkipDefaultTransaction: true,
+			})
+			require.NoError(t, err)
+			err = NewUserDAO(db).MergeUsers(context.Background(), 5, 3, AdminAuditLog{
+				Operator:  1,
+				Action:    "merge_user",
+				TargetUid: 5,
+				Ctime:     1000,
+			})
+			assert.Equal(t, tc.wantErr, err)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestGORMUserDAO_HardDelete(t *testing.T) {
+	testCases := []struct {
+		name string
+		mock func(mock sqlmock.Sqlmock)
+
+		wantErr error
+	}{
+		{
+			name: "删除成功",
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec("DELETE FROM `users` WHERE id = \\? AND status = \\? AND deleted_at < \\?").
+					WithArgs(int64(3), UserStatusDeleted, int64(1000)).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec("DELETE FROM `login_events` WHERE user_id = \\?").
+					WithArgs(int64(3)).WillReturnResult(sqlmock.NewResult(0, 4))
+				mock.ExpectExec("DELETE FROM `api_keys` WHERE user_id = \\?").
+					WithArgs(int64(3)).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec("DELETE FROM `backup_codes` WHERE user_id = \\?").
+					WithArgs(int64(3)).WillReturnResult(sqlmock.NewResult(0, 10))
+				mock.ExpectExec("DELETE FROM `remember_me_tokens` WHERE uid = \\?").
+					WithArgs(int64(3)).WillReturnResult(sqlmock.NewResult(0, 2))
+				mock.ExpectCommit()
+			},
+		},
+		{
+			name: "刚刚恢复了，别的都不动",
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec("DELETE FROM `users` .*").
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectCommit()
+			},
+		},
+		{
+			name: "删备用码失败",
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec("DELETE FROM `users` .*").WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec("DELETE FROM `login_events` .*").WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec("DELETE FROM `api_keys` .*").WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec("DELETE FROM `backup_codes` .*").
+					WillReturnError(errors.New("mock db 错误"))
+				mock.ExpectRollback()
+			},
+			wantErr: errors.New("mock db 错误"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockDB, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			tc.mock(mock)
+			db, err := gorm.Open(gormMysql.New(gormMysql.Config{
+				Conn:                      mockDB,
+				SkipInitializeWithVersion: true,
+			}), &gorm.Config{
+				DisableAutomaticPing:   true,
+				SkipDefaultTransaction: true,
+			})
+			require.NoError(t, err)
+			err = NewUserDAO(db).HardDelete(context.Background(), 3, 1000)
+			assert.Equal(t, tc.wantErr, err)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestMergeFields(t *testing.T) {
+	email := func(s string) sql.NullString {
+		return sql.NullString{String: s, Valid: true}
+	}
+	testCases := []struct {
+		name      string
+		primary   User
+		secondary User
+
+		wantUpdates map[string]any
+		wantMoved   []string
+		wantDropped []string
+	}{
+		{
+			name:      "主账号有的都保留",
+			primary:   User{Email: email("a@qq.com"), Phone: email("152"), IdCardHash: "h1"},
+			secondary: User{Email: email("b@qq.com"), Phone: email("153"), IdCardHash: "h2"},
+
+			wantUpdates: map[string]any{},
+			wantMoved:   []string{},
+			wantDropped: []string{"email", "phone", "identity"},
+		},
+		{
+			name:      "主账号没有的挪过来",
+			primary:   User{Phone: email("152")},
+			secondary: User{Email: email("b@qq.com"), Password: "hash", EmailVerifiedAt: 100, IdCardHash: "h2", VerifiedAt: 200},
+
+			wantUpdates: map[string]any{
+				"email":             email("b@qq.com"),
+				"email_verified_at": int64(100),
+				"password":          "hash",
+				"id_card_hash":      "h2",
+				"verified_at":       int64(200),
+			},
+			wantMoved:   []string{"email", "password", "identity"},
+			wantDropped: []string{},
+		},
+		{
+			name:      "主账号有密码就不动",
+			primary:   User{Phone: email("152"), Password: "mine"},
+			secondary: User{Email: email("b@qq.com"), Password: "hash"},
+
+			wantUpdates: map[string]any{
+				"email":             email("b@qq.com"),
+				"email_verified_at": int64(0),
+			},
+			wantMoved:   []string{"email"},
+			wantDropped: []string{},
+		},
+		{
+			name:      "同一个人实名的不算丢",
+			primary:   User{IdCardHash: "h1"},
+			secondary: User{IdCardHash: "h1"},
+
+			wantUpdates: map[string]any{},
+			wantMoved:   []string{},
+			wantDropped: []string{},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			updates, detail := mergeFields(tc.primary, tc.secondary)
+			assert.Equal(t, tc.wantUpdates, updates)
+			assert.Equal(t, tc.wantMoved, detail.Moved)
+			assert.Equal(t, tc.wantDropped, detail.Dropped)
+		})
+	}
+}
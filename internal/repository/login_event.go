@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"context"
+	"time"
+	"webook/internal/domain"
+	"webook/internal/repository/dao"
+)
+
+type LoginEventRepository interface {
+	Create(ctx context.Context, e domain.LoginEvent) error
+	FindByUserId(ctx context.Context, uid int64, limit int) ([]domain.LoginEvent, error)
+}
+
+type loginEventRepository struct {
+	dao dao.LoginEventDAO
+}
+
+func NewLoginEventRepository(dao dao.LoginEventDAO) LoginEventRepository {
+	return &loginEventRepository{
+		dao: dao,
+	}
+}
+
+func (repo *loginEventRepository) Create(ctx context.Context, e domain.LoginEvent) error {
+	return repo.dao.Insert(ctx, dao.LoginEvent{
+		UserId:    e.UserID,
+		IP:        e.IP,
+		UserAgent: e.UserAgent,
+		Status:    uint8(e.Status),
+	})
+}
+
+func (repo *loginEventRepository) FindByUserId(ctx context.Context, uid int64, limit int) ([]domain.LoginEvent, error) {
+	es, err := repo.dao.FindByUserId(ctx, uid, limit)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]domain.LoginEvent, 0, len(es))
+	for _, e := range es {
+		res = append(res, domain.LoginEvent{
+			ID:        e.Id,
+			UserID:    e.UserId,
+			IP:        e.IP,
+			UserAgent: e.UserAgent,
+			Status:    domain.LoginEventStatus(e.Status),
+			CreatedAt: time.UnixMilli(e.Ctime),
+		})
+	}
+	return res, nil
+}
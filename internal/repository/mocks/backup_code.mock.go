@@ -0,0 +1,95 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: webook/internal/repository/backup_code.go
+
+// Package repomocks is a generated GoMock package.
+package repomocks
+
+import (
+	context "context"
+	reflect "reflect"
+	time "time"
+	domain "webook/internal/domain"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockBackupCodeRepository is a mock of BackupCodeRepository interface.
+type MockBackupCodeRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockBackupCodeRepositoryMockRecorder
+}
+
+// MockBackupCodeRepositoryMockRecorder is the mock recorder for MockBackupCodeRepository.
+type MockBackupCodeRepositoryMockRecorder struct {
+	mock *MockBackupCodeRepository
+}
+
+// NewMockBackupCodeRepository creates a new mock instance.
+func NewMockBackupCodeRepository(ctrl *gomock.Controller) *MockBackupCodeRepository {
+	mock := &MockBackupCodeRepository{ctrl: ctrl}
+	mock.recorder = &MockBackupCodeRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockBackupCodeRepository) EXPECT() *MockBackupCodeRepositoryMockRecorder {
+	return m.recorder
+}
+
+// CountUnused mocks base method.
+func (m *MockBackupCodeRepository) CountUnused(ctx context.Context, uid int64) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CountUnused", ctx, uid)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CountUnused indicates an expected call of CountUnused.
+func (mr *MockBackupCodeRepositoryMockRecorder) CountUnused(ctx, uid interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CountUnused", reflect.TypeOf((*MockBackupCodeRepository)(nil).CountUnused), ctx, uid)
+}
+
+// FindUnused mocks base method.
+func (m *MockBackupCodeRepository) FindUnused(ctx context.Context, uid int64) ([]domain.BackupCode, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindUnused", ctx, uid)
+	ret0, _ := ret[0].([]domain.BackupCode)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindUnused indicates an expected call of FindUnused.
+func (mr *MockBackupCodeRepositoryMockRecorder) FindUnused(ctx, uid interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindUnused", reflect.TypeOf((*MockBackupCodeRepository)(nil).FindUnused), ctx, uid)
+}
+
+// MarkUsed mocks base method.
+func (m *MockBackupCodeRepository) MarkUsed(ctx context.Context, id int64, t time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MarkUsed", ctx, id, t)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// MarkUsed indicates an expected call of MarkUsed.
+func (mr *MockBackupCodeRepositoryMockRecorder) MarkUsed(ctx, id, t interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MarkUsed", reflect.TypeOf((*MockBackupCodeRepository)(nil).MarkUsed), ctx, id, t)
+}
+
+// Replace mocks base method.
+func (m *MockBackupCodeRepository) Replace(ctx context.Context, uid int64, codes []domain.BackupCode) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Replace", ctx, uid, codes)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Replace indicates an expected call of Replace.
+func (mr *MockBackupCodeRepositoryMockRecorder) Replace(ctx, uid, codes interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Replace", reflect.TypeOf((*MockBackupCodeRepository)(nil).Replace), ctx, uid, codes)
+}
@@ -7,6 +7,8 @@ package repomocks
 import (
 	context "context"
 	reflect "reflect"
+	time "time"
+	repository "webook/internal/repository"
 
 	gomock "go.uber.org/mock/gomock"
 )
@@ -34,6 +36,21 @@ func (m *MockCodeRepository) EXPECT() *MockCodeRepositoryMockRecorder {
 	return m.recorder
 }
 
+// Cooldown mocks base method.
+func (m *MockCodeRepository) Cooldown(ctx context.Context, biz, phone string) (time.Duration, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Cooldown", ctx, biz, phone)
+	ret0, _ := ret[0].(time.Duration)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Cooldown indicates an expected call of Cooldown.
+func (mr *MockCodeRepositoryMockRecorder) Cooldown(ctx, biz, phone interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Cooldown", reflect.TypeOf((*MockCodeRepository)(nil).Cooldown), ctx, biz, phone)
+}
+
 // Store mocks base method.
 func (m *MockCodeRepository) Store(ctx context.Context, biz, phone, code string) error {
 	m.ctrl.T.Helper()
@@ -62,3 +79,18 @@ func (mr *MockCodeRepositoryMockRecorder) Verify(ctx, biz, phone, inputCode inte
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Verify", reflect.TypeOf((*MockCodeRepository)(nil).Verify), ctx, biz, phone, inputCode)
 }
+
+// VerifyWithResult mocks base method.
+func (m *MockCodeRepository) VerifyWithResult(ctx context.Context, biz, phone, inputCode string) (repository.VerifyResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "VerifyWithResult", ctx, biz, phone, inputCode)
+	ret0, _ := ret[0].(repository.VerifyResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// VerifyWithResult indicates an expected call of VerifyWithResult.
+func (mr *MockCodeRepositoryMockRecorder) VerifyWithResult(ctx, biz, phone, inputCode interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "VerifyWithResult", reflect.TypeOf((*MockCodeRepository)(nil).VerifyWithResult), ctx, biz, phone, inputCode)
+}
@@ -0,0 +1,65 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: webook/internal/repository/login_event.go
+
+// Package repomocks is a generated GoMock package.
+package repomocks
+
+import (
+	context "context"
+	reflect "reflect"
+	domain "webook/internal/domain"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockLoginEventRepository is a mock of LoginEventRepository interface.
+type MockLoginEventRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockLoginEventRepositoryMockRecorder
+}
+
+// MockLoginEventRepositoryMockRecorder is the mock recorder for MockLoginEventRepository.
+type MockLoginEventRepositoryMockRecorder struct {
+	mock *MockLoginEventRepository
+}
+
+// NewMockLoginEventRepository creates a new mock instance.
+func NewMockLoginEventRepository(ctrl *gomock.Controller) *MockLoginEventRepository {
+	mock := &MockLoginEventRepository{ctrl: ctrl}
+	mock.recorder = &MockLoginEventRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockLoginEventRepository) EXPECT() *MockLoginEventRepositoryMockRecorder {
+	return m.recorder
+}
+
+// Create mocks base method.
+func (m *MockLoginEventRepository) Create(ctx context.Context, e domain.LoginEvent) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Create", ctx, e)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Create indicates an expected call of Create.
+func (mr *MockLoginEventRepositoryMockRecorder) Create(ctx, e interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockLoginEventRepository)(nil).Create), ctx, e)
+}
+
+// FindByUserId mocks base method.
+func (m *MockLoginEventRepository) FindByUserId(ctx context.Context, uid int64, limit int) ([]domain.LoginEvent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindByUserId", ctx, uid, limit)
+	ret0, _ := ret[0].([]domain.LoginEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindByUserId indicates an expected call of FindByUserId.
+func (mr *MockLoginEventRepositoryMockRecorder) FindByUserId(ctx, uid, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindByUserId", reflect.TypeOf((*MockLoginEventRepository)(nil).FindByUserId), ctx, uid, limit)
+}
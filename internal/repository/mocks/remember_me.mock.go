@@ -0,0 +1,108 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: webook/internal/repository/remember_me.go
+
+// Package repomocks is a generated GoMock package.
+package repomocks
+
+import (
+	context "context"
+	reflect "reflect"
+	time "time"
+	domain "webook/internal/domain"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockRememberMeRepository is a mock of RememberMeRepository interface.
+type MockRememberMeRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockRememberMeRepositoryMockRecorder
+}
+
+// MockRememberMeRepositoryMockRecorder is the mock recorder for MockRememberMeRepository.
+type MockRememberMeRepositoryMockRecorder struct {
+	mock *MockRememberMeRepository
+}
+
+// NewMockRememberMeRepository creates a new mock instance.
+func NewMockRememberMeRepository(ctrl *gomock.Controller) *MockRememberMeRepository {
+	mock := &MockRememberMeRepository{ctrl: ctrl}
+	mock.recorder = &MockRememberMeRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockRememberMeRepository) EXPECT() *MockRememberMeRepositoryMockRecorder {
+	return m.recorder
+}
+
+// Create mocks base method.
+func (m *MockRememberMeRepository) Create(ctx context.Context, t domain.RememberMeToken) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Create", ctx, t)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Create indicates an expected call of Create.
+func (mr *MockRememberMeRepositoryMockRecorder) Create(ctx, t interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockRememberMeRepository)(nil).Create), ctx, t)
+}
+
+// DeleteBySeries mocks base method.
+func (m *MockRememberMeRepository) DeleteBySeries(ctx context.Context, series string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteBySeries", ctx, series)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteBySeries indicates an expected call of DeleteBySeries.
+func (mr *MockRememberMeRepositoryMockRecorder) DeleteBySeries(ctx, series interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteBySeries", reflect.TypeOf((*MockRememberMeRepository)(nil).DeleteBySeries), ctx, series)
+}
+
+// DeleteByUid mocks base method.
+func (m *MockRememberMeRepository) DeleteByUid(ctx context.Context, uid int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteByUid", ctx, uid)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteByUid indicates an expected call of DeleteByUid.
+func (mr *MockRememberMeRepositoryMockRecorder) DeleteByUid(ctx, uid interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteByUid", reflect.TypeOf((*MockRememberMeRepository)(nil).DeleteByUid), ctx, uid)
+}
+
+// FindBySeries mocks base method.
+func (m *MockRememberMeRepository) FindBySeries(ctx context.Context, series string) (domain.RememberMeToken, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindBySeries", ctx, series)
+	ret0, _ := ret[0].(domain.RememberMeToken)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindBySeries indicates an expected call of FindBySeries.
+func (mr *MockRememberMeRepositoryMockRecorder) FindBySeries(ctx, series interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindBySeries", reflect.TypeOf((*MockRememberMeRepository)(nil).FindBySeries), ctx, series)
+}
+
+// Rotate mocks base method.
+func (m *MockRememberMeRepository) Rotate(ctx context.Context, series, oldHash, newHash string, expiresAt time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Rotate", ctx, series, oldHash, newHash, expiresAt)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Rotate indicates an expected call of Rotate.
+func (mr *MockRememberMeRepositoryMockRecorder) Rotate(ctx, series, oldHash, newHash, expiresAt interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Rotate", reflect.TypeOf((*MockRememberMeRepository)(nil).Rotate), ctx, series, oldHash, newHash, expiresAt)
+}
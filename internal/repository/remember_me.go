@@ -0,0 +1,68 @@
+package repository
+
+import (
+	"context"
+	"time"
+	"webook/internal/domain"
+	"webook/internal/repository/dao"
+)
+
+var (
+	ErrRememberMeNotFound = dao.ErrRememberMeNotFound
+	ErrRememberMeRotated  = dao.ErrRememberMeRotated
+)
+
+type RememberMeRepository interface {
+	Create(ctx context.Context, t domain.RememberMeToken) error
+	FindBySeries(ctx context.Context, series string) (domain.RememberMeToken, error)
+	Rotate(ctx context.Context, series string, oldHash string, newHash string, expiresAt time.Time) error
+	DeleteBySeries(ctx context.Context, series string) error
+	DeleteByUid(ctx context.Context, uid int64) error
+}
+
+type rememberMeRepository struct {
+	dao dao.RememberMeDAO
+}
+
+func NewRememberMeRepository(dao dao.RememberMeDAO) RememberMeRepository {
+	return &rememberMeRepository{
+		dao: dao,
+	}
+}
+
+func (repo *rememberMeRepository) Create(ctx context.Context, t domain.RememberMeToken) error {
+	return repo.dao.Insert(ctx, dao.RememberMeToken{
+		Series:    t.Series,
+		TokenHash: t.TokenHash,
+		Uid:       t.Uid,
+		ExpiresAt: t.ExpiresAt.UnixMilli(),
+	})
+}
+
+func (repo *rememberMeRepository) FindBySeries(ctx context.Context, series string) (domain.RememberMeToken, error) {
+	t, err := repo.dao.FindBySeries(ctx, series)
+	if err != nil {
+		return domain.RememberMeToken{}, err
+	}
+	return domain.RememberMeToken{
+		Series:        t.Series,
+		TokenHash:     t.TokenHash,
+		PrevTokenHash: t.PrevTokenHash,
+		Uid:           t.Uid,
+		ExpiresAt:     time.UnixMilli(t.ExpiresAt),
+		RotatedAt:     time.UnixMilli(t.Utime),
+	}, nil
+}
+
+func (repo *rememberMeRepository) Rotate(ctx context.Context, series string,
+	oldHash string, newHash string, expiresAt time.Time) error {
+	return repo.dao.Rotate(ctx, series, oldHash, newHash, expiresAt.UnixMilli())
+}
+
+func (repo *rememberMeRepository) DeleteBySeries(ctx context.Context, series string) error {
+	return repo.dao.DeleteBySeries(ctx, series)
+}
+
+func (repo *rememberMeRepository) DeleteByUid(ctx context.Context, uid int64) error {
+	return repo.dao.DeleteByUid(ctx, uid)
+}
@@ -2,23 +2,62 @@ package repository
 
 import (
 	"basic-go/webook/internal/domain"
+	"basic-go/webook/internal/repository/cache"
 	"basic-go/webook/internal/repository/dao"
+	"basic-go/webook/pkg/idgen"
 	"context"
+	"database/sql"
+	"golang.org/x/sync/singleflight"
+	"strconv"
+	"time"
 )
 
 var (
-	ErrUserDuplicateEmail = dao.ErrUserDuplicateEmail
-	ErrUserNotFound       = dao.ErrUserNotFound
+	ErrUserDuplicateEmail  = dao.ErrUserDuplicateEmail
+	ErrUserDuplicatePhone  = dao.ErrUserDuplicatePhone
+	ErrUserNotFound        = dao.ErrUserNotFound
+	ErrUserAlreadyVerified = dao.ErrUserAlreadyVerified
+	ErrUserNotBanned       = dao.ErrUserNotBanned
+	ErrProfileNotCached    = cache.ErrKeyNotExist
 )
 
 type UserRepository struct {
-	dao *dao.UserDAO
+	dao   *dao.UserDAO
+	cache cache.UserCache
+	// 公开资料在进程内的缓存，nil 就是不用
+	local *cache.LocalProfileCache
+	// 缓存没命中的时候，同一个用户同时只查一次数据库
+	loader singleflight.Group
+	// 新用户的 ID，默认是数据库自增
+	idGen idgen.IDGenerator
 }
 
-func NewUserRepository(dao *dao.UserDAO) *UserRepository {
-	return &UserRepository{
-		dao: dao,
+type UserRepositoryOption func(r *UserRepository)
+
+// WithLocalProfileCache 公开资料在 Redis 前面再加一层进程内的缓存
+func WithLocalProfileCache(local *cache.LocalProfileCache) UserRepositoryOption {
+	return func(r *UserRepository) {
+		r.local = local
+	}
+}
+
+// WithIDGenerator 新用户的 ID 由 gen 生成，比如说 snowflake
+func WithIDGenerator(gen idgen.IDGenerator) UserRepositoryOption {
+	return func(r *UserRepository) {
+		r.idGen = gen
+	}
+}
+
+func NewUserRepository(dao *dao.UserDAO, c cache.UserCache, opts ...UserRepositoryOption) *UserRepository {
+	r := &UserRepository{
+		dao:   dao,
+		cache: c,
+		idGen: idgen.AutoIncrement{},
+	}
+	for _, opt := range opts {
+		opt(r)
 	}
+	return r
 }
 
 func (r *UserRepository) FindByEmail(ctx context.Context, email string) (domain.User, error) {
@@ -27,43 +66,595 @@ func (r *UserRepository) FindByEmail(ctx context.Context, email string) (domain.
 	if err != nil {
 		return domain.User{}, err
 	}
-	return domain.User{
-		Id:       u.Id,
-		Email:    u.Email,
-		Password: u.Password,
-	}, nil
+	return r.entityToDomain(u), nil
+}
+
+// FindByEmailCaseInsensitive 查注册过没有用这个，User@Example.com 和 user@example.com 算同一个
+func (r *UserRepository) FindByEmailCaseInsensitive(ctx context.Context, email string) (domain.User, error) {
+	u, err := r.dao.FindByEmailCaseInsensitive(ctx, email)
+	if err != nil {
+		return domain.User{}, err
+	}
+	return r.entityToDomain(u), nil
+}
+
+func (r *UserRepository) FindByPhone(ctx context.Context, phone string) (domain.User, error) {
+	u, err := r.dao.FindByPhone(ctx, phone)
+	if err != nil {
+		return domain.User{}, err
+	}
+	return r.entityToDomain(u), nil
 }
 
 func (r *UserRepository) Create(ctx context.Context, u domain.User) error {
+	// 0 就是交给数据库自增
+	id, err := r.idGen.NextID()
+	if err != nil {
+		return err
+	}
 	return r.dao.Insert(ctx, dao.User{
-		Email:    u.Email,
-		Password: u.Password,
+		Id: id,
+		Email: sql.NullString{
+			String: u.Email,
+			Valid:  u.Email != "",
+		},
+		Phone: sql.NullString{
+			String: u.Phone,
+			Valid:  u.Phone != "",
+		},
+		Password:        u.Password,
+		DisposableEmail: u.DisposableEmail,
 	})
 }
 
 func (r *UserRepository) Edit(ctx context.Context, u domain.User) error {
-	return r.dao.Edit(ctx, dao.User{
-		Id:       u.Id,
-		Nickname: u.Nickname,
-		Birthday: u.Birthday,
-		Brief:    u.Brief,
-	})
+	entity := dao.User{
+		Id:            u.Id,
+		Nickname:      u.Nickname,
+		Birthday:      u.Birthday,
+		ShowBirthYear: u.ShowBirthYear,
+		Brief:         u.Brief,
+		Gender:        uint8(u.Gender),
+		Region:        u.Region,
+		City:          u.City,
+		Location:      u.Location,
+		Website:       u.Website,
+		Timezone:      u.Timezone,
+		Locale:        u.Locale,
+	}
+	if !u.NicknameChangedAt.IsZero() {
+		entity.NicknameChangedAt = u.NicknameChangedAt.UnixMilli()
+	}
+	err := r.dao.Edit(ctx, entity)
+	if err != nil {
+		return err
+	}
+	r.delProfile(ctx, u.Id)
+	return nil
+}
+
+func (r *UserRepository) UpdateNickname(ctx context.Context, id int64, nickname string) error {
+	err := r.dao.UpdateNickname(ctx, id, nickname)
+	if err != nil {
+		return err
+	}
+	r.delProfile(ctx, id)
+	return nil
 }
 
+// GetProfile 用户自己看的资料，先查缓存。
+// 缓存出问题了就直接查数据库，数据库查询失败的时候不回写缓存
 func (r *UserRepository) GetProfile(ctx context.Context, userId int64) (domain.User, error) {
-	u, err := r.dao.FindByUserId(ctx, userId)
+	res, err := r.cache.Get(ctx, userId)
+	if err == nil {
+		return res, nil
+	}
+	// 热点用户的缓存过期的时候只有一个请求去查数据库和回写缓存
+	val, err := r.loadShared(ctx, "profile:"+strconv.FormatInt(userId, 10), func(ctx context.Context) (any, error) {
+		u, err := r.dao.FindByUserId(ctx, userId)
+		if err != nil {
+			return domain.User{}, err
+		}
+		res := domain.User{
+			Id:            u.Id,
+			Email:         u.Email.String,
+			Phone:         u.Phone.String,
+			Nickname:      u.Nickname,
+			Birthday:      u.Birthday,
+			ShowBirthYear: u.ShowBirthYear,
+			Brief:         u.Brief,
+			Gender:        domain.Gender(u.Gender),
+			Region:        u.Region,
+			City:          u.City,
+			Location:      u.Location,
+			Website:       u.Website,
+			Timezone:      u.Timezone,
+			Locale:        u.Locale,
+		}
+		if u.NicknameChangedAt > 0 {
+			res.NicknameChangedAt = time.UnixMilli(u.NicknameChangedAt)
+		}
+		if u.VerifiedAt > 0 {
+			verifiedAt := time.UnixMilli(u.VerifiedAt)
+			res.VerifiedAt = &verifiedAt
+		}
+		if u.Ctime > 0 {
+			res.Ctime = time.UnixMilli(u.Ctime)
+		}
+		// 跟着资料一起进缓存，算 ETag 的时候就不用查数据库了
+		if u.Utime > 0 {
+			res.Utime = time.UnixMilli(u.Utime)
+		}
+		// 回写失败了下次再查数据库就可以。
+		// 跟并发的 Edit 撞上的话可能会写进去旧的数据，最多保留到缓存过期
+		_ = r.cache.Set(ctx, res)
+		return res, nil
+	})
 	if err != nil {
 		return domain.User{}, err
 	}
-	return domain.User{
-		Nickname: u.Nickname,
-		Birthday: u.Birthday,
-		Brief:    u.Brief,
-	}, nil
+	return val.(domain.User), nil
 }
 
-func (r *UserRepository) FindById(int64) {
+// GetProfiles 批量查公开的资料，先查缓存，没命中的用一条 SQL 查出来再回写缓存。
+// 不存在或者已经注销的用户不在返回的 map 里面
+func (r *UserRepository) GetProfiles(ctx context.Context, ids []int64) (map[int64]domain.User, error) {
+	var res map[int64]domain.User
+	if r.local != nil {
+		res = r.local.Get(ids)
+		ids = missedIds(ids, res)
+		if len(ids) == 0 {
+			return res, nil
+		}
+	} else {
+		res = make(map[int64]domain.User, len(ids))
+	}
+	cached, err := r.cache.GetProfiles(ctx, ids)
+	// 缓存出问题了就全部查数据库
+	if err == nil {
+		r.setLocal(cached)
+		for id, u := range cached {
+			res[id] = u
+		}
+	}
+	missed := missedIds(ids, res)
+	if len(missed) == 0 {
+		return res, nil
+	}
+	us, err := r.dao.FindByIds(ctx, missed)
+	if err != nil {
+		return nil, err
+	}
+	profiles := make([]domain.User, 0, len(us))
+	for _, u := range us {
+		p := r.entityToProfile(u)
+		res[p.Id] = p
+		profiles = append(profiles, p)
+	}
+	// 回写失败了下次再查数据库就可以
+	_ = r.cache.SetProfiles(ctx, profiles)
+	if r.local != nil {
+		r.local.Set(profiles)
+	}
+	return res, nil
+}
+
+func (r *UserRepository) setLocal(us map[int64]domain.User) {
+	if r.local == nil || len(us) == 0 {
+		return
+	}
+	vals := make([]domain.User, 0, len(us))
+	for _, u := range us {
+		vals = append(vals, u)
+	}
+	r.local.Set(vals)
+}
+
+// missedIds ids 里面不在 found 里面的，found 里面可以有 ids 以外的
+func missedIds(ids []int64, found map[int64]domain.User) []int64 {
+	res := make([]int64, 0, len(ids))
+	for _, id := range ids {
+		if _, ok := found[id]; !ok {
+			res = append(res, id)
+		}
+	}
+	return res
+}
+
+// RefreshProfile 不看缓存，直接查数据库然后覆盖掉缓存。
+// 给绕过了 Edit 直接改数据库的场景用，跟 GetProfiles 一样注销了的当作不存在
+func (r *UserRepository) RefreshProfile(ctx context.Context, id int64) (domain.User, error) {
+	us, err := r.dao.FindByIds(ctx, []int64{id})
+	if err != nil {
+		return domain.User{}, err
+	}
+	if len(us) == 0 {
+		// 可能是刚注销的，缓存里面的也不能要了
+		r.delProfile(ctx, id)
+		return domain.User{}, ErrUserNotFound
+	}
+	p := r.entityToProfile(us[0])
+	// 专门来刷新缓存的，写失败了要告诉调用方
+	if err = r.cache.SetProfiles(ctx, []domain.User{p}); err != nil {
+		return domain.User{}, err
+	}
+	if r.local != nil {
+		// 别的实例本地的也是旧的
+		_ = r.local.Del(ctx, id)
+	}
+	return p, nil
+}
+
+func (r *UserRepository) ProfileCacheAge(ctx context.Context, id int64) (time.Duration, error) {
+	return r.cache.ProfileAge(ctx, id)
+}
+
+func (r *UserRepository) UpdateIdentity(ctx context.Context, id int64,
+	idCardHash string, verifiedAt time.Time) error {
+	err := r.dao.UpdateIdentity(ctx, id, idCardHash, verifiedAt.UnixMilli())
+	if err != nil {
+		return err
+	}
+	r.delProfile(ctx, id)
+	return nil
+}
+
+func (r *UserRepository) UpdatePhone(ctx context.Context, id int64, phone string) error {
+	err := r.dao.UpdatePhone(ctx, id, phone)
+	if err != nil {
+		return err
+	}
+	r.delProfile(ctx, id)
+	return nil
+}
+
+// UpdatePassword 只换密码的哈希
+func (r *UserRepository) UpdatePassword(ctx context.Context, id int64, hash string) error {
+	err := r.dao.UpdatePassword(ctx, id, hash)
+	if err != nil {
+		return err
+	}
+	r.delProfile(ctx, id)
+	return nil
+}
+
+func (r *UserRepository) VerifyEmail(ctx context.Context, id int64, email string) error {
+	return r.dao.VerifyEmail(ctx, id, email)
+}
+
+func (r *UserRepository) FindById(ctx context.Context, id int64) (domain.User, error) {
 	// 先从 cache 里面找
 	// 再从 dao 里面找
 	// 找到了回写 cache
+	val, err := r.loadShared(ctx, "user:"+strconv.FormatInt(id, 10), func(ctx context.Context) (any, error) {
+		return r.dao.FindByUserId(ctx, id)
+	})
+	if err != nil {
+		return domain.User{}, err
+	}
+	return r.entityToDomain(val.(dao.User)), nil
+}
+
+// loadUserTimeout loadShared 不跟着调用者的 ctx 走，自己要有一个超时
+const loadUserTimeout = 3 * time.Second
+
+// loadShared 同一个 key 同时只有一个 load 在跑，其它的等着用它的结果，出错了（包括没找到）也是一起返回。
+// 等着的人 ctx 取消了自己先返回，正在跑的 load 不受影响，不然第一个人取消了大家都拿不到结果
+func (r *UserRepository) loadShared(ctx context.Context, key string,
+	load func(ctx context.Context) (any, error)) (any, error) {
+	ch := r.loader.DoChan(key, func() (any, error) {
+		ctx, cancel := context.WithTimeout(detachedContext{ctx}, loadUserTimeout)
+		defer cancel()
+		return load(ctx)
+	})
+	select {
+	case res := <-ch:
+		return res.Val, res.Err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// detachedContext 保留 ctx 里面的值（比如 trace），但是不会被取消。
+// go.mod 还是 1.20，用不了 context.WithoutCancel
+type detachedContext struct {
+	context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (detachedContext) Done() <-chan struct{} {
+	return nil
+}
+
+func (detachedContext) Err() error {
+	return nil
+}
+
+// SoftDelete 注销账号，并且让这个用户所有的登录态都失效
+func (r *UserRepository) SoftDelete(ctx context.Context, id int64, gracePeriod time.Duration) error {
+	err := r.dao.SoftDelete(ctx, id)
+	if err != nil {
+		return err
+	}
+	r.delProfile(ctx, id)
+	// 冷静期过了账号就被删掉了，这个 key 也就没有用了
+	return r.cache.Revoke(ctx, id, gracePeriod)
+}
+
+func (r *UserRepository) FindDeletedByEmail(ctx context.Context, email string) (domain.User, error) {
+	u, err := r.dao.FindDeletedByEmail(ctx, email)
+	if err != nil {
+		return domain.User{}, err
+	}
+	return r.entityToDomain(u), nil
+}
+
+func (r *UserRepository) Reactivate(ctx context.Context, id int64) error {
+	err := r.dao.Reactivate(ctx, id)
+	if err != nil {
+		return err
+	}
+	r.delProfile(ctx, id)
+	return r.cache.Restore(ctx, id)
+}
+
+// Deactivate 停用之后所有的登录态都失效，恢复之前一直有效，所以 key 不过期
+func (r *UserRepository) Deactivate(ctx context.Context, id int64) error {
+	err := r.dao.Deactivate(ctx, id)
+	if err != nil {
+		return err
+	}
+	r.delProfile(ctx, id)
+	return r.cache.Revoke(ctx, id, 0)
+}
+
+func (r *UserRepository) EndDeactivation(ctx context.Context, id int64) error {
+	err := r.dao.EndDeactivation(ctx, id)
+	if err != nil {
+		return err
+	}
+	r.delProfile(ctx, id)
+	return r.cache.Restore(ctx, id)
+}
+
+func (r *UserRepository) IsRevoked(ctx context.Context, id int64) (bool, error) {
+	return r.cache.IsRevoked(ctx, id)
+}
+
+func (r *UserRepository) FindDeletedBefore(ctx context.Context, before time.Time, limit int) ([]domain.User, error) {
+	us, err := r.dao.FindDeletedBefore(ctx, before.UnixMilli(), limit)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]domain.User, 0, len(us))
+	for _, u := range us {
+		res = append(res, r.entityToDomain(u))
+	}
+	return res, nil
+}
+
+func (r *UserRepository) HardDelete(ctx context.Context, id int64, before time.Time) error {
+	err := r.dao.HardDelete(ctx, id, before.UnixMilli())
+	if err != nil {
+		return err
+	}
+	r.delProfile(ctx, id)
+	return nil
+}
+
+// Ban 封禁之后马上让这个用户所有的登录态失效，到期之后 key 也跟着过期
+func (r *UserRepository) Ban(ctx context.Context, b domain.UserBan, log domain.AdminAuditLog) error {
+	entity := dao.UserBan{
+		Uid:      b.Uid,
+		Operator: b.Operator,
+		Reason:   b.Reason,
+		Ctime:    b.Ctime.UnixMilli(),
+	}
+	// 0 就是永久封禁，key 不过期
+	var expiration time.Duration
+	if !b.Until.IsZero() {
+		entity.Until = b.Until.UnixMilli()
+		expiration = b.Until.Sub(b.Ctime)
+	}
+	err := r.dao.Ban(ctx, entity, r.auditLogToEntity(log))
+	if err != nil {
+		return err
+	}
+	r.delProfile(ctx, b.Uid)
+	return r.cache.Revoke(ctx, b.Uid, expiration)
+}
+
+func (r *UserRepository) Unban(ctx context.Context, uid int64, operator int64, log domain.AdminAuditLog) error {
+	u, err := r.dao.Unban(ctx, uid, operator, r.auditLogToEntity(log))
+	if err != nil {
+		return err
+	}
+	r.delProfile(ctx, uid)
+	if u.Status == dao.UserStatusDeleted || u.Status == dao.UserStatusDeactivated {
+		// 注销了、停用了的账号登录态还是要失效
+		return nil
+	}
+	return r.cache.Restore(ctx, uid)
+}
+
+// MergeUsers 副账号合并之后就不能再用了，登录态马上失效
+func (r *UserRepository) MergeUsers(ctx context.Context, primaryId, secondaryId int64,
+	log domain.AdminAuditLog) error {
+	err := r.dao.MergeUsers(ctx, primaryId, secondaryId, r.auditLogToEntity(log))
+	if err != nil {
+		return err
+	}
+	r.delProfile(ctx, primaryId)
+	r.delProfile(ctx, secondaryId)
+	return r.cache.Revoke(ctx, secondaryId, 0)
+}
+
+func (r *UserRepository) CreateAuditLog(ctx context.Context, log domain.AdminAuditLog) error {
+	return r.dao.InsertAuditLog(ctx, r.auditLogToEntity(log))
+}
+
+func (r *UserRepository) List(ctx context.Context, q domain.UserListQuery) ([]domain.User, error) {
+	us, err := r.dao.List(ctx, r.listQueryToEntity(q))
+	if err != nil {
+		return nil, err
+	}
+	res := make([]domain.User, 0, len(us))
+	for _, u := range us {
+		res = append(res, r.entityToDomain(u))
+	}
+	return res, nil
+}
+
+func (r *UserRepository) Count(ctx context.Context, q domain.UserListQuery) (int64, error) {
+	return r.dao.Count(ctx, r.listQueryToEntity(q))
+}
+
+// CountByStatus key 是 active、deleted、merged、deactivated，再加上一个 suspended：
+// 正常状态但是正在封禁中的，这部分不算在 active 里面
+func (r *UserRepository) CountByStatus(ctx context.Context) (map[string]int64, error) {
+	cnts, err := r.dao.CountByStatus(ctx)
+	if err != nil {
+		return nil, err
+	}
+	banned, err := r.dao.CountBanned(ctx, time.Now().UnixMilli())
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[string]int64, len(cnts)+1)
+	for status, cnt := range cnts {
+		res[domain.UserStatus(status).String()] += cnt
+	}
+	res[domain.UserStatusActive.String()] -= banned
+	res[domain.UserStatsSuspended] = banned
+	return res, nil
+}
+
+// CountRegistrationsInPeriod [start, end) 之间注册的用户数
+func (r *UserRepository) CountRegistrationsInPeriod(ctx context.Context, start, end time.Time) (int64, error) {
+	return r.dao.CountCreatedBetween(ctx, start.UnixMilli(), end.UnixMilli())
+}
+
+func (r *UserRepository) listQueryToEntity(q domain.UserListQuery) dao.UserListQuery {
+	res := dao.UserListQuery{
+		Email:  q.Email,
+		Phone:  q.Phone,
+		SortBy: string(q.SortBy),
+		Desc:   q.Desc,
+		Offset: q.Offset,
+		Limit:  q.Limit,
+	}
+	if q.Status != nil {
+		status := uint8(*q.Status)
+		res.Status = &status
+	}
+	if !q.CtimeStart.IsZero() {
+		res.CtimeStart = q.CtimeStart.UnixMilli()
+	}
+	if !q.CtimeEnd.IsZero() {
+		res.CtimeEnd = q.CtimeEnd.UnixMilli()
+	}
+	if q.After != nil {
+		res.AfterId = q.After.Id
+		res.AfterCtime = q.After.Ctime.UnixMilli()
+	}
+	return res
+}
+
+func (r *UserRepository) auditLogToEntity(log domain.AdminAuditLog) dao.AdminAuditLog {
+	return dao.AdminAuditLog{
+		Operator:  log.Operator,
+		Action:    string(log.Action),
+		TargetUid: log.TargetUid,
+		Detail:    log.Detail,
+		Ctime:     log.Ctime.UnixMilli(),
+	}
+}
+
+// delProfile 公开的资料和用户自己看的资料在 Redis 和本地的缓存都删掉。
+// 数据库已经改成功了，删缓存失败不影响这次操作，
+// 最多就是在缓存过期之前看到的还是旧的资料
+func (r *UserRepository) delProfile(ctx context.Context, id int64) {
+	_ = r.cache.DelProfile(ctx, id)
+	_ = r.cache.Del(ctx, id)
+	if r.local != nil {
+		// 本地的一定会删掉，通知别的实例失败了就等它们自己过期
+		_ = r.local.Del(ctx, id)
+	}
+}
+
+// entityToProfile 只保留别人能看到的字段，缓存里面也只放这些
+func (r *UserRepository) entityToProfile(u dao.User) domain.User {
+	res := domain.User{
+		Id:       u.Id,
+		Nickname: u.Nickname,
+		// 完整的生日也放进来，给别人看的时候用 PublicBirthday
+		Birthday:      u.Birthday,
+		ShowBirthYear: u.ShowBirthYear,
+		Brief:         u.Brief,
+		Gender:        domain.Gender(u.Gender),
+		Region:        u.Region,
+		City:          u.City,
+		Location:      u.Location,
+		Website:       u.Website,
+	}
+	if u.VerifiedAt > 0 {
+		verifiedAt := time.UnixMilli(u.VerifiedAt)
+		res.VerifiedAt = &verifiedAt
+	}
+	if u.Ctime > 0 {
+		res.Ctime = time.UnixMilli(u.Ctime)
+	}
+	return res
+}
+
+func (r *UserRepository) entityToDomain(u dao.User) domain.User {
+	res := domain.User{
+		Id:            u.Id,
+		Email:         u.Email.String,
+		Password:      u.Password,
+		Phone:         u.Phone.String,
+		Nickname:      u.Nickname,
+		Birthday:      u.Birthday,
+		ShowBirthYear: u.ShowBirthYear,
+		Brief:         u.Brief,
+		Gender:        domain.Gender(u.Gender),
+		Region:        u.Region,
+		City:          u.City,
+		Location:      u.Location,
+		Website:       u.Website,
+		Timezone:      u.Timezone,
+		Locale:        u.Locale,
+		Status:        domain.UserStatus(u.Status),
+		Ctime:         time.UnixMilli(u.Ctime),
+
+		IDCardHash:      u.IdCardHash,
+		DisposableEmail: u.DisposableEmail,
+	}
+	if u.VerifiedAt > 0 {
+		verifiedAt := time.UnixMilli(u.VerifiedAt)
+		res.VerifiedAt = &verifiedAt
+	}
+	if u.EmailVerifiedAt > 0 {
+		res.EmailVerifiedAt = time.UnixMilli(u.EmailVerifiedAt)
+	}
+	if u.Status == dao.UserStatusDeleted {
+		// 注销了的，对外展示的还是注销之前的邮箱和手机号
+		res.Email = u.OriginalEmail
+		res.Phone = u.OriginalPhone
+		res.DeletedAt = time.UnixMilli(u.DeletedAt)
+	}
+	if u.BannedAt > 0 {
+		res.Ban = &domain.UserBan{
+			Uid:    u.Id,
+			Reason: u.BanReason,
+			Ctime:  time.UnixMilli(u.BannedAt),
+		}
+		if u.BannedUntil > 0 {
+			res.Ban.Until = time.UnixMilli(u.BannedUntil)
+		}
+	}
+	return res
 }
@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"context"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"testing"
+	"webook/internal/domain"
+	"webook/internal/repository/dao"
+	"webook/pkg/idgen"
+)
+
+type fixedIDGen struct {
+	id  int64
+	err error
+}
+
+func (g fixedIDGen) NextID() (int64, error) {
+	return g.id, g.err
+}
+
+func TestUserRepository_CreateIDGenerator(t *testing.T) {
+	testCases := []struct {
+		name string
+		gen  idgen.IDGenerator
+		mock func(mock sqlmock.Sqlmock)
+
+		wantId  int64
+		wantErr error
+	}{
+		{
+			name: "默认数据库自增",
+			mock: func(mock sqlmock.Sqlmock) {
+				// 没有 id 这一列
+				mock.ExpectExec("INSERT INTO `users` \\(`email`,").
+					WillReturnResult(sqlmock.NewResult(1, 1))
+			},
+		},
+		{
+			name: "用生成的 ID",
+			gen:  fixedIDGen{id: 123456789012345},
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec("INSERT INTO `users` \\(.*`id`\\)").
+					WillReturnResult(sqlmock.NewResult(123456789012345, 1))
+			},
+			wantId: 123456789012345,
+		},
+		{
+			name:    "生成 ID 失败",
+			gen:     fixedIDGen{err: idgen.ErrClockBackwards},
+			mock:    func(mock sqlmock.Sqlmock) {},
+			wantErr: idgen.ErrClockBackwards,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, mock := mockUserDB(t)
+			tc.mock(mock)
+			// 看一下真的写进数据库的 ID
+			var insertedId int64
+			err := db.Callback().Create().Before("gorm:create").Register("test:id", func(db *gorm.DB) {
+				insertedId = db.Statement.Dest.(*dao.User).Id
+			})
+			require.NoError(t, err)
+			var opts []UserRepositoryOption
+			if tc.gen != nil {
+				opts = append(opts, WithIDGenerator(tc.gen))
+			}
+			repo := NewUserRepository(dao.NewUserDAO(db), nil, opts...)
+			err = repo.Create(context.Background(), domain.User{Email: "123@qq.com", Password: "hash"})
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.wantId, insertedId)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
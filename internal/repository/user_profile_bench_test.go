@@ -0,0 +1,68 @@
+package repository
+
+import (
+	"context"
+	"github.com/redis/go-redis/v9"
+	"testing"
+	"time"
+	"webook/internal/domain"
+	"webook/internal/repository/cache"
+	"webook/internal/repository/dao"
+)
+
+// BenchmarkUserRepository_GetProfiles 要本地起一个 Redis（localhost:6379），没有的话跳过。
+// 数据都提前放进了 Redis，不会查数据库：
+//   - redis：只有 Redis，每次一个 MGET
+//   - redis+local：热点用户全部在本地命中
+//   - redis+local-churn：本地放不下，大部分还是要查 Redis，看看多一层的开销
+//
+// go test -bench GetProfiles -run ^$ ./internal/repository/
+func BenchmarkUserRepository_GetProfiles(b *testing.B) {
+	client := redis.NewClient(&redis.Options{Addr: "localhost:6379"})
+	ctx := context.Background()
+	if err := client.Ping(ctx).Err(); err != nil {
+		b.Skip("没有 Redis", err)
+	}
+	defer client.Close()
+
+	const users = 1000
+	profiles := make([]domain.User, 0, users)
+	for i := int64(1); i <= users; i++ {
+		profiles = append(profiles, domain.User{Id: i, Nickname: "bench"})
+	}
+	c := cache.NewUserCache(client)
+	if err := c.SetProfiles(ctx, profiles); err != nil {
+		b.Fatal(err)
+	}
+	db, _ := mockUserDB(b)
+
+	testCases := []struct {
+		name  string
+		local *cache.LocalProfileCache
+	}{
+		{name: "redis"},
+		{name: "redis+local", local: cache.NewLocalProfileCache(client, users, time.Minute)},
+		{name: "redis+local-churn", local: cache.NewLocalProfileCache(client, users/10, time.Minute)},
+	}
+	for _, tc := range testCases {
+		b.Run(tc.name, func(b *testing.B) {
+			var opts []UserRepositoryOption
+			if tc.local != nil {
+				opts = append(opts, WithLocalProfileCache(tc.local))
+			}
+			repo := NewUserRepository(dao.NewUserDAO(db), c, opts...)
+			ids := make([]int64, 20)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				// 一页 20 个作者
+				for j := range ids {
+					ids[j] = int64((i*len(ids)+j)%users) + 1
+				}
+				us, err := repo.GetProfiles(ctx, ids)
+				if err != nil || len(us) != len(ids) {
+					b.Fatal("应该全部命中缓存", err)
+				}
+			}
+		})
+	}
+}
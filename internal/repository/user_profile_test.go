@@ -0,0 +1,204 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	gormMysql "gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"testing"
+	"time"
+	"webook/internal/domain"
+	"webook/internal/repository/cache"
+	cachemocks "webook/internal/repository/cache/mocks"
+	"webook/internal/repository/cache/redismocks"
+	"webook/internal/repository/dao"
+)
+
+func TestUserRepository_GetProfile(t *testing.T) {
+	tom := domain.User{
+		Id:       3,
+		Email:    "tom@qq.com",
+		Nickname: "Tom",
+	}
+	query := "SELECT \\* FROM `users` WHERE id = \\?"
+	testCases := []struct {
+		name string
+		mock func(ctrl *gomock.Controller, mock sqlmock.Sqlmock) cache.UserCache
+
+		wantUser domain.User
+		wantErr  error
+	}{
+		{
+			name: "缓存命中",
+			mock: func(ctrl *gomock.Controller, mock sqlmock.Sqlmock) cache.UserCache {
+				c := cachemocks.NewMockUserCache(ctrl)
+				c.EXPECT().Get(gomock.Any(), int64(3)).Return(tom, nil)
+				return c
+			},
+			wantUser: tom,
+		},
+		{
+			name: "缓存没命中，查数据库回写缓存",
+			mock: func(ctrl *gomock.Controller, mock sqlmock.Sqlmock) cache.UserCache {
+				c := cachemocks.NewMockUserCache(ctrl)
+				c.EXPECT().Get(gomock.Any(), int64(3)).Return(domain.User{}, cache.ErrKeyNotExist)
+				mock.ExpectQuery(query).WillReturnRows(
+					sqlmock.NewRows([]string{"id", "email", "nickname"}).AddRow(3, "tom@qq.com", "Tom"))
+				c.EXPECT().Set(gomock.Any(), tom).Return(nil)
+				return c
+			},
+			wantUser: tom,
+		},
+		{
+			name: "Redis 挂了，直接查数据库",
+			mock: func(ctrl *gomock.Controller, mock sqlmock.Sqlmock) cache.UserCache {
+				c := cachemocks.NewMockUserCache(ctrl)
+				c.EXPECT().Get(gomock.Any(), int64(3)).Return(domain.User{}, errors.New("mock redis 错误"))
+				mock.ExpectQuery(query).WillReturnRows(
+					sqlmock.NewRows([]string{"id", "email", "nickname"}).AddRow(3, "tom@qq.com", "Tom"))
+				c.EXPECT().Set(gomock.Any(), tom).Return(errors.New("mock redis 错误"))
+				return c
+			},
+			wantUser: tom,
+		},
+		{
+			name: "缓存没命中，数据库也出错了",
+			mock: func(ctrl *gomock.Controller, mock sqlmock.Sqlmock) cache.UserCache {
+				c := cachemocks.NewMockUserCache(ctrl)
+				c.EXPECT().Get(gomock.Any(), int64(3)).Return(domain.User{}, cache.ErrKeyNotExist)
+				mock.ExpectQuery(query).WillReturnError(errors.New("mock db 错误"))
+				// 不能把零值写进缓存
+				return c
+			},
+			wantErr: errors.New("mock db 错误"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			db, mock := mockUserDB(t)
+			repo := NewUserRepository(dao.NewUserDAO(db), tc.mock(ctrl, mock))
+
+			u, err := repo.GetProfile(context.Background(), 3)
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.wantUser, u)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestUserRepository_EditInvalidatesProfile(t *testing.T) {
+	testCases := []struct {
+		name string
+		mock func(ctrl *gomock.Controller, mock sqlmock.Sqlmock) cache.UserCache
+
+		wantErr error
+	}{
+		{
+			name: "改成功了，两份缓存都删掉",
+			mock: func(ctrl *gomock.Controller, mock sqlmock.Sqlmock) cache.UserCache {
+				mock.ExpectExec("UPDATE `users` SET .* WHERE `id` = \\?").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				c := cachemocks.NewMockUserCache(ctrl)
+				c.EXPECT().DelProfile(gomock.Any(), int64(3)).Return(nil)
+				c.EXPECT().Del(gomock.Any(), int64(3)).Return(nil)
+				return c
+			},
+		},
+		{
+			name: "删缓存失败不影响修改",
+			mock: func(ctrl *gomock.Controller, mock sqlmock.Sqlmock) cache.UserCache {
+				mock.ExpectExec("UPDATE `users` SET .* WHERE `id` = \\?").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				c := cachemocks.NewMockUserCache(ctrl)
+				c.EXPECT().DelProfile(gomock.Any(), int64(3)).Return(errors.New("mock redis 错误"))
+				c.EXPECT().Del(gomock.Any(), int64(3)).Return(errors.New("mock redis 错误"))
+				return c
+			},
+		},
+		{
+			name: "数据库没改成功，缓存不动",
+			mock: func(ctrl *gomock.Controller, mock sqlmock.Sqlmock) cache.UserCache {
+				mock.ExpectExec("UPDATE `users` SET .* WHERE `id` = \\?").
+					WillReturnError(errors.New("mock db 错误"))
+				return cachemocks.NewMockUserCache(ctrl)
+			},
+			wantErr: errors.New("mock db 错误"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			db, mock := mockUserDB(t)
+			repo := NewUserRepository(dao.NewUserDAO(db), tc.mock(ctrl, mock))
+
+			err := repo.Edit(context.Background(), domain.User{Id: 3, Nickname: "Jerry"})
+			assert.Equal(t, tc.wantErr, err)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func mockUserDB(t testing.TB) (*gorm.DB, sqlmock.Sqlmock) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	db, err := gorm.Open(gormMysql.New(gormMysql.Config{
+		Conn:                      mockDB,
+		SkipInitializeWithVersion: true,
+	}), &gorm.Config{
+		DisableAutomaticPing:   true,
+		SkipDefaultTransaction: true,
+	})
+	require.NoError(t, err)
+	return db, mock
+}
+
+func TestUserRepository_GetProfilesLocalCache(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	db, mock := mockUserDB(t)
+	c := cachemocks.NewMockUserCache(ctrl)
+	cmd := redismocks.NewMockCmdable(ctrl)
+	local := cache.NewLocalProfileCache(cmd, 100, time.Minute)
+	repo := NewUserRepository(dao.NewUserDAO(db), c, WithLocalProfileCache(local))
+	ctx := context.Background()
+
+	// 第一次：1 在 Redis 里面，2 要查数据库，都会放到本地
+	c.EXPECT().GetProfiles(gomock.Any(), []int64{1, 2}).
+		Return(map[int64]domain.User{1: {Id: 1, Nickname: "Tom"}}, nil)
+	mock.ExpectQuery("SELECT \\* FROM `users` WHERE id IN \\(\\?\\)").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "nickname"}).AddRow(2, "Jerry"))
+	c.EXPECT().SetProfiles(gomock.Any(), []domain.User{{Id: 2, Nickname: "Jerry"}}).Return(nil)
+	us, err := repo.GetProfiles(ctx, []int64{1, 2})
+	require.NoError(t, err)
+	assert.Len(t, us, 2)
+
+	// 第二次：本地都命中了，Redis 和数据库都不用查
+	us, err = repo.GetProfiles(ctx, []int64{1, 2})
+	require.NoError(t, err)
+	assert.Equal(t, "Jerry", us[2].Nickname)
+
+	// 改了资料：Redis、本地都删掉，再通知别的实例
+	mock.ExpectExec("UPDATE `users` SET .* WHERE `id` = \\?").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	c.EXPECT().DelProfile(gomock.Any(), int64(2)).Return(nil)
+	c.EXPECT().Del(gomock.Any(), int64(2)).Return(nil)
+	cmd.EXPECT().Publish(gomock.Any(), cache.ProfileInvalidationChannel, "2").
+		Return(redis.NewIntCmd(ctx))
+	require.NoError(t, repo.Edit(ctx, domain.User{Id: 2, Nickname: "Spike"}))
+
+	// 只有 2 要回去查 Redis
+	c.EXPECT().GetProfiles(gomock.Any(), []int64{2}).
+		Return(map[int64]domain.User{2: {Id: 2, Nickname: "Spike"}}, nil)
+	us, err = repo.GetProfiles(ctx, []int64{1, 2})
+	require.NoError(t, err)
+	assert.Equal(t, "Spike", us[2].Nickname)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,160 @@
+package repository
+
+import (
+	"context"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"gorm.io/gorm"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+	"webook/internal/domain"
+	"webook/internal/repository/cache"
+	cachemocks "webook/internal/repository/cache/mocks"
+	"webook/internal/repository/dao"
+)
+
+// countQueries 数一下真的发到数据库的查询
+func countQueries(t *testing.T, db *gorm.DB) *int32 {
+	var cnt int32
+	err := db.Callback().Query().After("gorm:query").Register("test:count", func(*gorm.DB) {
+		atomic.AddInt32(&cnt, 1)
+	})
+	require.NoError(t, err)
+	return &cnt
+}
+
+func TestUserRepository_GetProfileSingleflight(t *testing.T) {
+	const n = 50
+	query := "SELECT \\* FROM `users` WHERE id = \\?"
+	testCases := []struct {
+		name string
+		mock func(ctrl *gomock.Controller, mock sqlmock.Sqlmock) cache.UserCache
+
+		wantUser domain.User
+		wantErr  error
+	}{
+		{
+			name: "大家一起等一次查询",
+			mock: func(ctrl *gomock.Controller, mock sqlmock.Sqlmock) cache.UserCache {
+				c := cachemocks.NewMockUserCache(ctrl)
+				c.EXPECT().Get(gomock.Any(), int64(3)).Return(domain.User{}, cache.ErrKeyNotExist).Times(n)
+				// 慢一点，保证所有人都赶上这一次
+				mock.ExpectQuery(query).WillDelayFor(200 * time.Millisecond).WillReturnRows(
+					sqlmock.NewRows([]string{"id", "nickname"}).AddRow(3, "Tom"))
+				// 回写缓存也只有一次
+				c.EXPECT().Set(gomock.Any(), domain.User{Id: 3, Nickname: "Tom"}).Return(nil).Times(1)
+				return c
+			},
+			wantUser: domain.User{Id: 3, Nickname: "Tom"},
+		},
+		{
+			name: "没找到也是一起返回",
+			mock: func(ctrl *gomock.Controller, mock sqlmock.Sqlmock) cache.UserCache {
+				c := cachemocks.NewMockUserCache(ctrl)
+				c.EXPECT().Get(gomock.Any(), int64(3)).Return(domain.User{}, cache.ErrKeyNotExist).Times(n)
+				mock.ExpectQuery(query).WillDelayFor(200 * time.Millisecond).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}))
+				return c
+			},
+			wantErr: ErrUserNotFound,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			db, mock := mockUserDB(t)
+			cnt := countQueries(t, db)
+			repo := NewUserRepository(dao.NewUserDAO(db), tc.mock(ctrl, mock))
+
+			var wg sync.WaitGroup
+			users := make([]domain.User, n)
+			errs := make([]error, n)
+			for i := 0; i < n; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					users[i], errs[i] = repo.GetProfile(context.Background(), 3)
+				}(i)
+			}
+			wg.Wait()
+
+			assert.Equal(t, int32(1), atomic.LoadInt32(cnt))
+			for i := 0; i < n; i++ {
+				assert.Equal(t, tc.wantErr, errs[i])
+				assert.Equal(t, tc.wantUser, users[i])
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestUserRepository_FindByIdSingleflight(t *testing.T) {
+	const n = 50
+	db, mock := mockUserDB(t)
+	cnt := countQueries(t, db)
+	mock.ExpectQuery("SELECT \\* FROM `users` WHERE id = \\?").
+		WillDelayFor(200 * time.Millisecond).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "nickname"}).AddRow(3, "Tom"))
+	repo := NewUserRepository(dao.NewUserDAO(db), nil)
+
+	var wg sync.WaitGroup
+	users := make([]domain.User, n)
+	errs := make([]error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			users[i], errs[i] = repo.FindById(context.Background(), 3)
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(cnt))
+	for i := 0; i < n; i++ {
+		require.NoError(t, errs[i])
+		assert.Equal(t, int64(3), users[i].Id)
+		assert.Equal(t, "Tom", users[i].Nickname)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// 先来的人取消了，查询还是要跑完，后面等着的人照样拿到结果
+func TestUserRepository_FindByIdSingleflightCancel(t *testing.T) {
+	db, mock := mockUserDB(t)
+	mock.ExpectQuery("SELECT \\* FROM `users` WHERE id = \\?").
+		WillDelayFor(200 * time.Millisecond).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "nickname"}).AddRow(3, "Tom"))
+	repo := NewUserRepository(dao.NewUserDAO(db), nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	leaderErr := make(chan error, 1)
+	go func() {
+		_, err := repo.FindById(ctx, 3)
+		leaderErr <- err
+	}()
+	// 等第一个人把查询发出去
+	time.Sleep(50 * time.Millisecond)
+
+	var (
+		u   domain.User
+		err error
+	)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		u, err = repo.FindById(context.Background(), 3)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	assert.Equal(t, context.Canceled, <-leaderErr)
+
+	<-done
+	require.NoError(t, err)
+	assert.Equal(t, "Tom", u.Nickname)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
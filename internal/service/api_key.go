@@ -0,0 +1,108 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+	"webook/internal/domain"
+	"webook/internal/repository"
+)
+
+// APIKeyPrefix 所有 API key 都以这个开头，方便区分 JWT
+const APIKeyPrefix = "apk_"
+
+var (
+	ErrAPIKeyNotFound = repository.ErrAPIKeyNotFound
+	ErrInvalidAPIKey  = errors.New("API key 无效或者已经过期")
+)
+
+type APIKeyService interface {
+	// Create 返回的 string 是原始的 key，只有这里能拿到
+	Create(ctx context.Context, uid int64, name string, ttl time.Duration) (domain.APIKey, string, error)
+	List(ctx context.Context, uid int64) ([]domain.APIKey, error)
+	Revoke(ctx context.Context, uid int64, id int64) error
+	// Authenticate 校验原始的 key，并且更新最后使用时间
+	Authenticate(ctx context.Context, rawKey string) (domain.APIKey, error)
+}
+
+type apiKeyService struct {
+	repo repository.APIKeyRepository
+}
+
+func NewAPIKeyService(repo repository.APIKeyRepository) APIKeyService {
+	return &apiKeyService{
+		repo: repo,
+	}
+}
+
+func (svc *apiKeyService) Create(ctx context.Context, uid int64,
+	name string, ttl time.Duration) (domain.APIKey, string, error) {
+	rawKey, err := svc.generateKey()
+	if err != nil {
+		return domain.APIKey{}, "", err
+	}
+	now := time.Now()
+	k := domain.APIKey{
+		UserId:    uid,
+		KeyHash:   HashAPIKey(rawKey),
+		Name:      name,
+		CreatedAt: now,
+	}
+	// ttl 为 0 就是永不过期
+	if ttl > 0 {
+		k.ExpiresAt = now.Add(ttl)
+	}
+	k.Id, err = svc.repo.Create(ctx, k)
+	if err != nil {
+		return domain.APIKey{}, "", err
+	}
+	return k, rawKey, nil
+}
+
+func (svc *apiKeyService) List(ctx context.Context, uid int64) ([]domain.APIKey, error) {
+	return svc.repo.FindByUserId(ctx, uid)
+}
+
+func (svc *apiKeyService) Revoke(ctx context.Context, uid int64, id int64) error {
+	return svc.repo.Delete(ctx, uid, id)
+}
+
+func (svc *apiKeyService) Authenticate(ctx context.Context, rawKey string) (domain.APIKey, error) {
+	if !strings.HasPrefix(rawKey, APIKeyPrefix) {
+		return domain.APIKey{}, ErrInvalidAPIKey
+	}
+	k, err := svc.repo.FindByHash(ctx, HashAPIKey(rawKey))
+	if err == repository.ErrAPIKeyNotFound {
+		return domain.APIKey{}, ErrInvalidAPIKey
+	}
+	if err != nil {
+		return domain.APIKey{}, err
+	}
+	now := time.Now()
+	if k.Expired(now) {
+		return domain.APIKey{}, ErrInvalidAPIKey
+	}
+	// 更新失败也不影响这次调用
+	if err = svc.repo.UpdateLastUsed(ctx, k.Id, now); err == nil {
+		k.LastUsedAt = now
+	}
+	return k, nil
+}
+
+func (svc *apiKeyService) generateKey() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return APIKeyPrefix + hex.EncodeToString(buf), nil
+}
+
+// HashAPIKey 数据库里面只存哈希值，数据库泄露了也拿不到原始的 key
+func HashAPIKey(rawKey string) string {
+	sum := sha256.Sum256([]byte(rawKey))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,183 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"strings"
+	"testing"
+	"time"
+	"webook/internal/domain"
+	"webook/internal/repository"
+	repomocks "webook/internal/repository/mocks"
+)
+
+func TestAPIKeyService_Create(t *testing.T) {
+	testCases := []struct {
+		name string
+		mock func(ctrl *gomock.Controller) repository.APIKeyRepository
+
+		ttl time.Duration
+
+		wantExpires bool
+		wantErr     error
+	}{
+		{
+			name: "创建成功，永不过期",
+			mock: func(ctrl *gomock.Controller) repository.APIKeyRepository {
+				repo := repomocks.NewMockAPIKeyRepository(ctrl)
+				repo.EXPECT().Create(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(ctx context.Context, k domain.APIKey) (int64, error) {
+						assert.Equal(t, int64(123), k.UserId)
+						assert.Equal(t, "ci", k.Name)
+						assert.Len(t, k.KeyHash, 64)
+						assert.True(t, k.ExpiresAt.IsZero())
+						return 1, nil
+					})
+				return repo
+			},
+		},
+		{
+			name: "创建成功，有过期时间",
+			mock: func(ctrl *gomock.Controller) repository.APIKeyRepository {
+				repo := repomocks.NewMockAPIKeyRepository(ctrl)
+				repo.EXPECT().Create(gomock.Any(), gomock.Any()).Return(int64(1), nil)
+				return repo
+			},
+			ttl:         time.Hour,
+			wantExpires: true,
+		},
+		{
+			name: "数据库错误",
+			mock: func(ctrl *gomock.Controller) repository.APIKeyRepository {
+				repo := repomocks.NewMockAPIKeyRepository(ctrl)
+				repo.EXPECT().Create(gomock.Any(), gomock.Any()).
+					Return(int64(0), errors.New("mock db 错误"))
+				return repo
+			},
+			wantErr: errors.New("mock db 错误"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			svc := NewAPIKeyService(tc.mock(ctrl))
+			k, rawKey, err := svc.Create(context.Background(), 123, "ci", tc.ttl)
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, int64(1), k.Id)
+			assert.True(t, strings.HasPrefix(rawKey, APIKeyPrefix))
+			// 数据库里面存的是哈希，不是原始的 key
+			assert.Equal(t, HashAPIKey(rawKey), k.KeyHash)
+			assert.NotEqual(t, rawKey, k.KeyHash)
+			assert.Equal(t, tc.wantExpires, !k.ExpiresAt.IsZero())
+		})
+	}
+}
+
+func TestAPIKeyService_ListAndRevoke(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	repo := repomocks.NewMockAPIKeyRepository(ctrl)
+	keys := []domain.APIKey{{Id: 2, UserId: 123}, {Id: 1, UserId: 123}}
+	repo.EXPECT().FindByUserId(gomock.Any(), int64(123)).Return(keys, nil)
+	repo.EXPECT().Delete(gomock.Any(), int64(123), int64(2)).Return(nil)
+	repo.EXPECT().Delete(gomock.Any(), int64(123), int64(3)).Return(repository.ErrAPIKeyNotFound)
+
+	svc := NewAPIKeyService(repo)
+	res, err := svc.List(context.Background(), 123)
+	require.NoError(t, err)
+	assert.Equal(t, keys, res)
+	assert.NoError(t, svc.Revoke(context.Background(), 123, 2))
+	assert.Equal(t, ErrAPIKeyNotFound, svc.Revoke(context.Background(), 123, 3))
+}
+
+func TestAPIKeyService_Authenticate(t *testing.T) {
+	const rawKey = APIKeyPrefix + "abc"
+	testCases := []struct {
+		name string
+		mock func(ctrl *gomock.Controller) repository.APIKeyRepository
+
+		rawKey string
+
+		wantUid int64
+		wantErr error
+	}{
+		{
+			name: "校验成功",
+			mock: func(ctrl *gomock.Controller) repository.APIKeyRepository {
+				repo := repomocks.NewMockAPIKeyRepository(ctrl)
+				repo.EXPECT().FindByHash(gomock.Any(), HashAPIKey(rawKey)).
+					Return(domain.APIKey{Id: 1, UserId: 123}, nil)
+				repo.EXPECT().UpdateLastUsed(gomock.Any(), int64(1), gomock.Any()).Return(nil)
+				return repo
+			},
+			rawKey:  rawKey,
+			wantUid: 123,
+		},
+		{
+			name: "前缀不对",
+			mock: func(ctrl *gomock.Controller) repository.APIKeyRepository {
+				return repomocks.NewMockAPIKeyRepository(ctrl)
+			},
+			rawKey:  "abc",
+			wantErr: ErrInvalidAPIKey,
+		},
+		{
+			name: "key 不存在",
+			mock: func(ctrl *gomock.Controller) repository.APIKeyRepository {
+				repo := repomocks.NewMockAPIKeyRepository(ctrl)
+				repo.EXPECT().FindByHash(gomock.Any(), HashAPIKey(rawKey)).
+					Return(domain.APIKey{}, repository.ErrAPIKeyNotFound)
+				return repo
+			},
+			rawKey:  rawKey,
+			wantErr: ErrInvalidAPIKey,
+		},
+		{
+			name: "已经过期",
+			mock: func(ctrl *gomock.Controller) repository.APIKeyRepository {
+				repo := repomocks.NewMockAPIKeyRepository(ctrl)
+				repo.EXPECT().FindByHash(gomock.Any(), HashAPIKey(rawKey)).
+					Return(domain.APIKey{
+						Id:        1,
+						UserId:    123,
+						ExpiresAt: time.Now().Add(-time.Minute),
+					}, nil)
+				return repo
+			},
+			rawKey:  rawKey,
+			wantErr: ErrInvalidAPIKey,
+		},
+		{
+			name: "数据库错误",
+			mock: func(ctrl *gomock.Controller) repository.APIKeyRepository {
+				repo := repomocks.NewMockAPIKeyRepository(ctrl)
+				repo.EXPECT().FindByHash(gomock.Any(), HashAPIKey(rawKey)).
+					Return(domain.APIKey{}, errors.New("mock db 错误"))
+				return repo
+			},
+			rawKey:  rawKey,
+			wantErr: errors.New("mock db 错误"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			svc := NewAPIKeyService(tc.mock(ctrl))
+			k, err := svc.Authenticate(context.Background(), tc.rawKey)
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantUid, k.UserId)
+			assert.False(t, k.LastUsedAt.IsZero())
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"golang.org/x/crypto/bcrypt"
+	"strings"
+	"time"
+	"webook/internal/domain"
+	"webook/internal/repository"
+)
+
+const (
+	// BackupCodeCount 一次生成多少个备用码
+	BackupCodeCount = 10
+	// BackupCodeLength 每个备用码多少位
+	BackupCodeLength = 8
+)
+
+var ErrInvalidBackupCode = errors.New("备用码不对或者已经用过了")
+
+// BackupCodeService 验证器丢了的时候用备用码代替 TOTP 的验证码，每个只能用一次
+type BackupCodeService interface {
+	// Generate 以前的全部作废，返回的是原始的码，只有这里能拿到
+	Generate(ctx context.Context, uid int64) ([]string, error)
+	// Verify 对上了就把这个码标记为用过了，不对返回 ErrInvalidBackupCode
+	Verify(ctx context.Context, uid int64, code string) error
+	// Count 还剩几个没用过
+	Count(ctx context.Context, uid int64) (int64, error)
+}
+
+type backupCodeService struct {
+	repo      repository.BackupCodeRepository
+	generator CodeGenerator
+	// bcrypt 的 cost，测试里面会调小
+	cost int
+}
+
+func NewBackupCodeService(repo repository.BackupCodeRepository) BackupCodeService {
+	return &backupCodeService{
+		repo: repo,
+		// 去掉了容易看错的字符，抄在纸上也不容易抄错
+		generator: NewCodeGenerator(CodeConfig{
+			Length:  BackupCodeLength,
+			Charset: CodeCharsetAlphanumeric,
+		}),
+		cost: bcrypt.DefaultCost,
+	}
+}
+
+func (svc *backupCodeService) Generate(ctx context.Context, uid int64) ([]string, error) {
+	codes := make([]string, 0, BackupCodeCount)
+	hashes := make([]domain.BackupCode, 0, BackupCodeCount)
+	for i := 0; i < BackupCodeCount; i++ {
+		code, err := svc.generator.Generate()
+		if err != nil {
+			return nil, err
+		}
+		hash, err := bcrypt.GenerateFromPassword([]byte(code), svc.cost)
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+		hashes = append(hashes, domain.BackupCode{CodeHash: string(hash)})
+	}
+	if err := svc.repo.Replace(ctx, uid, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+func (svc *backupCodeService) Verify(ctx context.Context, uid int64, code string) error {
+	code = svc.normalize(code)
+	// 长度都不对就不用一个个去比了，bcrypt 很慢
+	if len(code) != BackupCodeLength {
+		return ErrInvalidBackupCode
+	}
+	cs, err := svc.repo.FindUnused(ctx, uid)
+	if err != nil {
+		return err
+	}
+	for _, c := range cs {
+		if bcrypt.CompareHashAndPassword([]byte(c.CodeHash), []byte(code)) != nil {
+			continue
+		}
+		err = svc.repo.MarkUsed(ctx, c.Id, time.Now())
+		// 同时有两个请求用同一个码，只有一个能成功
+		if err == repository.ErrBackupCodeUsed {
+			return ErrInvalidBackupCode
+		}
+		return err
+	}
+	return ErrInvalidBackupCode
+}
+
+func (svc *backupCodeService) Count(ctx context.Context, uid int64) (int64, error) {
+	return svc.repo.CountUnused(ctx, uid)
+}
+
+// normalize 用户可能会按四位一组写成 ABCD-EFGH，也可能输入小写
+func (svc *backupCodeService) normalize(code string) string {
+	code = strings.NewReplacer("-", "", " ", "").Replace(code)
+	return svc.generator.Normalize(code)
+}
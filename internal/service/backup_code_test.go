@@ -0,0 +1,186 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"golang.org/x/crypto/bcrypt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"webook/internal/domain"
+	"webook/internal/repository"
+	repomocks "webook/internal/repository/mocks"
+)
+
+// memoryBackupCodeRepository 跟数据库一样，MarkUsed 是 CAS
+type memoryBackupCodeRepository struct {
+	mutex sync.Mutex
+	codes map[int64][]domain.BackupCode
+	id    int64
+}
+
+func (r *memoryBackupCodeRepository) Replace(ctx context.Context, uid int64, codes []domain.BackupCode) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.codes == nil {
+		r.codes = map[int64][]domain.BackupCode{}
+	}
+	res := make([]domain.BackupCode, 0, len(codes))
+	for _, c := range codes {
+		r.id++
+		c.Id, c.UserId = r.id, uid
+		res = append(res, c)
+	}
+	r.codes[uid] = res
+	return nil
+}
+
+func (r *memoryBackupCodeRepository) FindUnused(ctx context.Context, uid int64) ([]domain.BackupCode, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	var res []domain.BackupCode
+	for _, c := range r.codes[uid] {
+		if c.UsedAt.IsZero() {
+			res = append(res, c)
+		}
+	}
+	return res, nil
+}
+
+func (r *memoryBackupCodeRepository) CountUnused(ctx context.Context, uid int64) (int64, error) {
+	cs, err := r.FindUnused(ctx, uid)
+	return int64(len(cs)), err
+}
+
+func (r *memoryBackupCodeRepository) MarkUsed(ctx context.Context, id int64, t time.Time) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for _, cs := range r.codes {
+		for i := range cs {
+			if cs[i].Id != id {
+				continue
+			}
+			if !cs[i].UsedAt.IsZero() {
+				return repository.ErrBackupCodeUsed
+			}
+			cs[i].UsedAt = t
+			return nil
+		}
+	}
+	return repository.ErrBackupCodeUsed
+}
+
+func newTestBackupCodeService(repo repository.BackupCodeRepository) BackupCodeService {
+	svc := NewBackupCodeService(repo).(*backupCodeService)
+	svc.cost = bcrypt.MinCost
+	return svc
+}
+
+func TestBackupCodeService_Generate(t *testing.T) {
+	repo := &memoryBackupCodeRepository{}
+	svc := newTestBackupCodeService(repo)
+	ctx := context.Background()
+
+	codes, err := svc.Generate(ctx, 123)
+	require.NoError(t, err)
+	require.Len(t, codes, BackupCodeCount)
+	seen := map[string]bool{}
+	for _, code := range codes {
+		assert.Len(t, code, BackupCodeLength)
+		// 跟验证码一样，没有容易看错的字符
+		assert.False(t, strings.ContainsAny(code, "01ILO"), code)
+		seen[code] = true
+	}
+	assert.Len(t, seen, BackupCodeCount)
+	// 存的是哈希
+	for _, c := range repo.codes[123] {
+		assert.True(t, strings.HasPrefix(c.CodeHash, "$2a$"))
+	}
+	cnt, err := svc.Count(ctx, 123)
+	require.NoError(t, err)
+	assert.Equal(t, int64(BackupCodeCount), cnt)
+
+	// 重新生成，以前的就不能用了
+	again, err := svc.Generate(ctx, 123)
+	require.NoError(t, err)
+	assert.Equal(t, ErrInvalidBackupCode, svc.Verify(ctx, 123, codes[0]))
+	assert.NoError(t, svc.Verify(ctx, 123, again[0]))
+}
+
+func TestBackupCodeService_Verify(t *testing.T) {
+	repo := &memoryBackupCodeRepository{}
+	svc := newTestBackupCodeService(repo)
+	ctx := context.Background()
+	codes, err := svc.Generate(ctx, 123)
+	require.NoError(t, err)
+
+	// 别人的码不能用
+	assert.Equal(t, ErrInvalidBackupCode, svc.Verify(ctx, 456, codes[0]))
+	assert.Equal(t, ErrInvalidBackupCode, svc.Verify(ctx, 123, "ABCD"))
+	assert.Equal(t, ErrInvalidBackupCode, svc.Verify(ctx, 123, ""))
+
+	// 小写、四位一组写的也认
+	input := strings.ToLower(codes[0][:4] + "-" + codes[0][4:])
+	require.NoError(t, svc.Verify(ctx, 123, input))
+	// 只能用一次
+	assert.Equal(t, ErrInvalidBackupCode, svc.Verify(ctx, 123, codes[0]))
+	cnt, err := svc.Count(ctx, 123)
+	require.NoError(t, err)
+	assert.Equal(t, int64(BackupCodeCount-1), cnt)
+
+	// 全部用完
+	for _, code := range codes[1:] {
+		require.NoError(t, svc.Verify(ctx, 123, code))
+	}
+	cnt, err = svc.Count(ctx, 123)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), cnt)
+	for _, code := range codes {
+		assert.Equal(t, ErrInvalidBackupCode, svc.Verify(ctx, 123, code))
+	}
+}
+
+func TestBackupCodeService_VerifyConcurrent(t *testing.T) {
+	svc := newTestBackupCodeService(&memoryBackupCodeRepository{})
+	ctx := context.Background()
+	codes, err := svc.Generate(ctx, 123)
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	succeeded := 0
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if svc.Verify(ctx, 123, codes[0]) == nil {
+				mutex.Lock()
+				succeeded++
+				mutex.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 1, succeeded)
+}
+
+func TestBackupCodeService_Errors(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	repo := repomocks.NewMockBackupCodeRepository(ctrl)
+	svc := newTestBackupCodeService(repo)
+	ctx := context.Background()
+
+	repo.EXPECT().Replace(gomock.Any(), int64(123), gomock.Len(BackupCodeCount)).
+		Return(errors.New("mock db 错误"))
+	codes, err := svc.Generate(ctx, 123)
+	assert.Equal(t, errors.New("mock db 错误"), err)
+	assert.Nil(t, codes)
+
+	repo.EXPECT().FindUnused(gomock.Any(), int64(123)).Return(nil, errors.New("mock db 错误"))
+	assert.Equal(t, errors.New("mock db 错误"), svc.Verify(ctx, 123, "ABCDEFGH"))
+}
@@ -2,10 +2,12 @@ package service
 
 import (
 	"context"
-	"fmt"
-	"math/rand"
+	"errors"
+	"time"
 	"webook/internal/repository"
+	"webook/internal/repository/cache"
 	"webook/internal/service/sms"
+	"webook/pkg/redisx"
 )
 
 const codeTplId = "1877556"
@@ -13,26 +15,77 @@ const codeTplId = "1877556"
 var (
 	ErrCodeVerifyTooManyTimes = repository.ErrCodeVerifyTooManyTimes
 	ErrCodeSendTooMany        = repository.ErrCodeSendTooMany
+	// ErrSMSQuotaExceeded 手机号或者 IP 当天的短信配额用完了
+	ErrSMSQuotaExceeded = errors.New("今日短信发送次数已达上限")
+	// ErrCodeServiceBusy Redis 被熔断了，验证码存不进去也查不出来
+	ErrCodeServiceBusy = redisx.ErrCircuitOpen
 )
 
+// CodeSendTooManyError Send 在冷却期内返回的，带着还要等多久，要用 errors.Is 判断
+type CodeSendTooManyError = repository.CodeSendTooManyError
+
+// VerifyResult 输错了的时候带着还能再试几次，用来提示用户。
+// 接口上写的是 repository.VerifyResult，不然生成的 mock 会循环引用
+type VerifyResult = repository.VerifyResult
+
+// SMSQuotaExceededError Send 在当天配额用完了的时候返回的，带着什么时候重新计数，
+// 要用 errors.Is(err, ErrSMSQuotaExceeded) 判断
+type SMSQuotaExceededError struct {
+	ResetAt time.Time
+}
+
+func (e *SMSQuotaExceededError) Error() string {
+	return ErrSMSQuotaExceeded.Error()
+}
+
+func (e *SMSQuotaExceededError) Unwrap() error {
+	return ErrSMSQuotaExceeded
+}
+
+// SMSQuotaConfig 每天的短信配额，0 代表不限制
+type SMSQuotaConfig struct {
+	// 每个手机号每天最多发几条
+	PhoneDaily int
+	// 每个 IP 每天最多发几条
+	IPDaily int
+}
+
 type CodeService interface {
 	Send(ctx context.Context,
 		// 区别业务场景
-		biz string, phone string) error
+		biz string, phone string,
+		// 发起请求的 IP，用来控制每个 IP 的配额
+		ip string) error
 	Verify(ctx context.Context, biz string,
 		phone string, inputCode string) (bool, error)
+	// VerifyWithResult 跟 Verify 一样，输错了的时候多告诉你还能再试几次
+	VerifyWithResult(ctx context.Context, biz string,
+		phone string, inputCode string) (repository.VerifyResult, error)
+	// Cooldown 这个手机号还要等多久才能再发验证码，不在冷却期就是 0
+	Cooldown(ctx context.Context, biz, phone string) (time.Duration, error)
 }
 
 type codeService struct {
 	repo   repository.CodeRepository
 	smsSvc sms.Service
 	//tplId string
+	quota     cache.SMSQuotaCache
+	quotaCfg  SMSQuotaConfig
+	generator CodeGenerator
 }
 
-func NewCodeService(repo repository.CodeRepository, smsSvc sms.Service) CodeService {
+// NewCodeService generator 为 nil 的话是默认的六位数字
+func NewCodeService(repo repository.CodeRepository, smsSvc sms.Service,
+	quota cache.SMSQuotaCache, quotaCfg SMSQuotaConfig, generator CodeGenerator) CodeService {
+	if generator == nil {
+		generator = NewCodeGenerator(CodeConfig{})
+	}
 	return &codeService{
-		repo:   repo,
-		smsSvc: smsSvc,
+		repo:      repo,
+		smsSvc:    smsSvc,
+		quota:     quota,
+		quotaCfg:  quotaCfg,
+		generator: generator,
 	}
 }
 
@@ -40,17 +93,35 @@ func NewCodeService(repo repository.CodeRepository, smsSvc sms.Service) CodeServ
 func (svc *codeService) Send(ctx context.Context,
 	// 区别业务场景
 	biz string,
-	phone string) error {
+	phone string, ip string) error {
 	// 生成一个验证码
-	code := svc.generateCode()
+	code, err := svc.generator.Generate()
+	if err != nil {
+		return err
+	}
+	// 配额用完了就不能再 Store，不然用户手上那个还没用的验证码会被一个发不出去的换掉，
+	// 还白白进了冷却期。这里只看不计数，计数还是放在 Store 后面
+	err = svc.quota.Check(ctx, phone, ip, svc.quotaCfg.PhoneDaily, svc.quotaCfg.IPDaily)
+	if err != nil {
+		return svc.quotaErr(err)
+	}
 	// 塞进去 Redis
-	err := svc.repo.Store(ctx, biz, phone, code)
+	err = svc.repo.Store(ctx, biz, phone, code)
 	if err != nil {
 		// 有问题
 		return err
 	}
 	// 这前面成功了
 
+	// 放在 Store 后面计数，这样发送太频繁被拒绝的请求不会占用配额，
+	// 别人没办法靠狂点发送把受害者的配额耗光。
+	// 手机号的配额不分 biz，换着业务场景发也绕不过去。
+	// 只有并发发送的时候才会在 Check 之后才用完，这时候也不发了
+	err = svc.quota.Incr(ctx, phone, ip, svc.quotaCfg.PhoneDaily, svc.quotaCfg.IPDaily)
+	if err != nil {
+		return svc.quotaErr(err)
+	}
+
 	// 发送出去
 
 	err = svc.smsSvc.Send(ctx, codeTplId, []string{code}, phone)
@@ -65,17 +136,27 @@ func (svc *codeService) Send(ctx context.Context,
 	return err
 }
 
+// quotaErr 不告诉对方是手机号还是 IP 用完了
+func (svc *codeService) quotaErr(err error) error {
+	var quotaErr *cache.SMSQuotaExceededError
+	if errors.As(err, &quotaErr) {
+		return &SMSQuotaExceededError{ResetAt: quotaErr.ResetAt}
+	}
+	return err
+}
+
 func (svc *codeService) Verify(ctx context.Context, biz string,
 	phone string, inputCode string) (bool, error) {
-	return svc.repo.Verify(ctx, biz, phone, inputCode)
+	return svc.repo.Verify(ctx, biz, phone, svc.generator.Normalize(inputCode))
+}
+
+func (svc *codeService) VerifyWithResult(ctx context.Context, biz string,
+	phone string, inputCode string) (VerifyResult, error) {
+	return svc.repo.VerifyWithResult(ctx, biz, phone, svc.generator.Normalize(inputCode))
 }
 
-func (svc *codeService) generateCode() string {
-	// 六位数，num 在 0, 999999 之间，包含 0 和 999999
-	num := rand.Intn(1000000)
-	// 不够六位的，加上前导 0
-	// 000001
-	return fmt.Sprintf("%06d", num)
+func (svc *codeService) Cooldown(ctx context.Context, biz, phone string) (time.Duration, error) {
+	return svc.repo.Cooldown(ctx, biz, phone)
 }
 
 //func (svc *codeService) VerifyV1(ctx context.Context, biz string,
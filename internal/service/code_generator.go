@@ -0,0 +1,111 @@
+package service
+
+import (
+	"crypto/rand"
+	"math/big"
+	"strings"
+	"sync"
+)
+
+// CodeCharset 验证码用哪些字符
+type CodeCharset string
+
+const (
+	CodeCharsetNumeric CodeCharset = "numeric"
+	// CodeCharsetAlphanumeric 大写字母加数字，去掉了容易看错的 0 O 1 I L，
+	// 校验的时候不区分大小写
+	CodeCharsetAlphanumeric CodeCharset = "alphanumeric"
+)
+
+var codeCharsets = map[CodeCharset]string{
+	CodeCharsetNumeric:      "0123456789",
+	CodeCharsetAlphanumeric: "23456789ABCDEFGHJKMNPQRSTUVWXYZ",
+}
+
+// CodeConfig 验证码的长度和字符集，零值就用默认的六位数字
+type CodeConfig struct {
+	Length  int
+	Charset CodeCharset
+}
+
+// CodeGenerator 生成验证码。短信和邮件可以用不同的 CodeGenerator，各自一个 CodeService
+type CodeGenerator interface {
+	Generate() (string, error)
+	// Normalize 用户输入的验证码转成生成的时候的格式再去比较，比如说字母不区分大小写
+	Normalize(inputCode string) string
+}
+
+type randomCodeGenerator struct {
+	length   int
+	charset  CodeCharset
+	alphabet string
+}
+
+// NewCodeGenerator 用 crypto/rand 生成，字符集不认识的话直接 panic，这是配置写错了
+func NewCodeGenerator(cfg CodeConfig) CodeGenerator {
+	if cfg.Length <= 0 {
+		cfg.Length = 6
+	}
+	if cfg.Charset == "" {
+		cfg.Charset = CodeCharsetNumeric
+	}
+	alphabet, ok := codeCharsets[cfg.Charset]
+	if !ok {
+		panic("验证码字符集不存在：" + string(cfg.Charset))
+	}
+	return &randomCodeGenerator{
+		length:   cfg.Length,
+		charset:  cfg.Charset,
+		alphabet: alphabet,
+	}
+}
+
+// Generate 验证码不能被猜出来，每一位都是均匀分布的
+func (g *randomCodeGenerator) Generate() (string, error) {
+	n := big.NewInt(int64(len(g.alphabet)))
+	code := make([]byte, g.length)
+	for i := range code {
+		idx, err := rand.Int(rand.Reader, n)
+		if err != nil {
+			return "", err
+		}
+		code[i] = g.alphabet[idx.Int64()]
+	}
+	return string(code), nil
+}
+
+// Normalize 存的都是大写，用户输入的小写也算对
+func (g *randomCodeGenerator) Normalize(inputCode string) string {
+	if g.charset == CodeCharsetAlphanumeric {
+		return strings.ToUpper(inputCode)
+	}
+	return inputCode
+}
+
+// FixedCodeGenerator 按顺序返回给定的验证码，用完了从头再来。测试用的
+type FixedCodeGenerator struct {
+	mutex sync.Mutex
+	codes []string
+	next  int
+}
+
+func NewFixedCodeGenerator(codes ...string) *FixedCodeGenerator {
+	if len(codes) == 0 {
+		codes = []string{"123456"}
+	}
+	return &FixedCodeGenerator{
+		codes: codes,
+	}
+}
+
+func (g *FixedCodeGenerator) Generate() (string, error) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	code := g.codes[g.next%len(g.codes)]
+	g.next++
+	return code, nil
+}
+
+func (g *FixedCodeGenerator) Normalize(inputCode string) string {
+	return inputCode
+}
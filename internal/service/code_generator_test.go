@@ -0,0 +1,73 @@
+package service
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func TestCodeGenerator_Generate(t *testing.T) {
+	testCases := []struct {
+		name string
+		cfg  CodeConfig
+
+		wantLen      int
+		wantAlphabet string
+	}{
+		{
+			name:         "默认六位数字",
+			wantLen:      6,
+			wantAlphabet: "0123456789",
+		},
+		{
+			name:         "四位数字",
+			cfg:          CodeConfig{Length: 4},
+			wantLen:      4,
+			wantAlphabet: "0123456789",
+		},
+		{
+			name:         "字母加数字",
+			cfg:          CodeConfig{Length: 8, Charset: CodeCharsetAlphanumeric},
+			wantLen:      8,
+			wantAlphabet: "23456789ABCDEFGHJKMNPQRSTUVWXYZ",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewCodeGenerator(tc.cfg)
+			const rounds = 20000
+			counts := make(map[rune]int)
+			for i := 0; i < rounds; i++ {
+				code, err := g.Generate()
+				require.NoError(t, err)
+				require.Len(t, code, tc.wantLen)
+				for _, c := range code {
+					require.True(t, strings.ContainsRune(tc.wantAlphabet, c), code)
+					counts[c]++
+				}
+			}
+			// 每个字符都要出现，并且次数跟期望值差不多，误差 15% 以内
+			assert.Len(t, counts, len(tc.wantAlphabet))
+			expected := float64(rounds*tc.wantLen) / float64(len(tc.wantAlphabet))
+			for c, cnt := range counts {
+				assert.InDelta(t, expected, float64(cnt), expected*0.15, string(c))
+			}
+		})
+	}
+}
+
+func TestNewCodeGenerator_UnknownCharset(t *testing.T) {
+	assert.Panics(t, func() {
+		NewCodeGenerator(CodeConfig{Charset: "emoji"})
+	})
+}
+
+func TestFixedCodeGenerator(t *testing.T) {
+	g := NewFixedCodeGenerator("111111", "222222")
+	for _, want := range []string{"111111", "222222", "111111"} {
+		code, err := g.Generate()
+		require.NoError(t, err)
+		assert.Equal(t, want, code)
+	}
+}
@@ -1,10 +1,100 @@
 package service
 
 import (
+	"context"
 	"fmt"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
 	"testing"
+	"time"
+	"webook/internal/repository/cache"
+	repomocks "webook/internal/repository/mocks"
 )
 
 func TestFormate(t *testing.T) {
 	t.Log(fmt.Sprintf("%06d", 10))
 }
+
+func TestCodeService_Verify(t *testing.T) {
+	testCases := []struct {
+		name    string
+		charset CodeCharset
+		input   string
+
+		wantPassed string
+	}{
+		{
+			name:       "字母加数字不区分大小写",
+			charset:    CodeCharsetAlphanumeric,
+			input:      "ab3k9x",
+			wantPassed: "AB3K9X",
+		},
+		{
+			name:       "数字原样校验",
+			charset:    CodeCharsetNumeric,
+			input:      "012345",
+			wantPassed: "012345",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			repo := repomocks.NewMockCodeRepository(ctrl)
+			repo.EXPECT().Verify(gomock.Any(), "login", "152", tc.wantPassed).Return(true, nil)
+			svc := NewCodeService(repo, nil, nil, SMSQuotaConfig{}, NewCodeGenerator(CodeConfig{Charset: tc.charset}))
+			ok, err := svc.Verify(context.Background(), "login", "152", tc.input)
+			assert.NoError(t, err)
+			assert.True(t, ok)
+		})
+	}
+}
+
+// recordSMSService 记下来发出去的验证码
+type recordSMSService struct {
+	args []string
+}
+
+func (s *recordSMSService) Send(ctx context.Context, tpl string, args []string, numbers ...string) error {
+	s.args = args
+	return nil
+}
+
+// 存进去的和发出去的都是 CodeGenerator 生成的
+func TestCodeService_SendGenerator(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	repo := repomocks.NewMockCodeRepository(ctrl)
+	repo.EXPECT().Store(gomock.Any(), "login", "152", "AB3K9X").Return(nil)
+	smsSvc := &recordSMSService{}
+	mr := miniredis.RunT(t)
+	quota := cache.NewSMSQuotaCache(redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+	svc := NewCodeService(repo, smsSvc, quota, SMSQuotaConfig{}, NewFixedCodeGenerator("AB3K9X"))
+
+	require.NoError(t, svc.Send(context.Background(), "login", "152", "127.0.0.1"))
+	assert.Equal(t, []string{"AB3K9X"}, smsSvc.args)
+}
+
+// 手机号的配额是所有 biz 一起算的
+func TestCodeService_SendPhoneDailyQuota(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	repo := repomocks.NewMockCodeRepository(ctrl)
+	// 配额用完了就不会再存，用户手上那个验证码不会被换掉
+	repo.EXPECT().Store(gomock.Any(), gomock.Any(), "152", "123456").Return(nil).Times(2)
+	svc := NewCodeService(repo, &recordSMSService{}, cache.NewLocalSMSQuotaCache(),
+		SMSQuotaConfig{PhoneDaily: 2}, NewFixedCodeGenerator("123456"))
+	ctx := context.Background()
+
+	require.NoError(t, svc.Send(ctx, "login", "152", "127.0.0.1"))
+	require.NoError(t, svc.Send(ctx, "change_phone", "152", "127.0.0.2"))
+	err := svc.Send(ctx, "delete_account", "152", "127.0.0.3")
+	assert.ErrorIs(t, err, ErrSMSQuotaExceeded)
+	var quotaErr *SMSQuotaExceededError
+	require.ErrorAs(t, err, &quotaErr)
+	assert.True(t, quotaErr.ResetAt.After(time.Now()))
+	assert.Equal(t, 0, quotaErr.ResetAt.Hour())
+}
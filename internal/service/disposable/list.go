@@ -0,0 +1,100 @@
+package disposable
+
+import (
+	"bufio"
+	"context"
+	_ "embed"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+)
+
+//go:embed domains.txt
+var defaultDomains string
+
+// 远程列表最多读这么多，防止对方返回一个巨大的文件
+const maxRemoteSize = 4 << 20
+
+// List 一次性邮箱的域名列表。内置的和启动时传进来的不会变，
+// 远程拉下来的每次整体替换，所以 Contains 不需要加锁
+type List struct {
+	base   map[string]struct{}
+	remote atomic.Pointer[map[string]struct{}]
+}
+
+// NewList 用内置的列表再加上 domains 初始化
+func NewList(domains ...string) *List {
+	l := &List{base: make(map[string]struct{})}
+	for _, d := range parse(strings.NewReader(defaultDomains)) {
+		l.base[d] = struct{}{}
+	}
+	for _, d := range domains {
+		if d = normalize(d); d != "" {
+			l.base[d] = struct{}{}
+		}
+	}
+	return l
+}
+
+// Contains domain 或者它的上级域名在列表里面
+func (l *List) Contains(domain string) bool {
+	domain = normalize(domain)
+	remote := l.remote.Load()
+	// 只剩顶级域名的时候就不用再看了
+	for strings.Contains(domain, ".") {
+		if _, ok := l.base[domain]; ok {
+			return true
+		}
+		if remote != nil {
+			if _, ok := (*remote)[domain]; ok {
+				return true
+			}
+		}
+		domain = domain[strings.Index(domain, ".")+1:]
+	}
+	return false
+}
+
+// Fetch 从 url 拉一份列表替换掉上一次拉的，格式跟内置的一样。
+// 失败的时候还是用上一次的
+func (l *List) Fetch(ctx context.Context, client *http.Client, url string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("拉取一次性邮箱列表失败，状态码 %d", resp.StatusCode)
+	}
+	remote := make(map[string]struct{})
+	for _, d := range parse(io.LimitReader(resp.Body, maxRemoteSize)) {
+		remote[d] = struct{}{}
+	}
+	l.remote.Store(&remote)
+	return nil
+}
+
+func parse(r io.Reader) []string {
+	var res []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if d := normalize(line); d != "" {
+			res = append(res, d)
+		}
+	}
+	return res
+}
+
+func normalize(domain string) string {
+	return strings.ToLower(strings.TrimPrefix(strings.TrimSpace(domain), "@"))
+}
@@ -0,0 +1,87 @@
+package disposable
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestList_Contains(t *testing.T) {
+	testCases := []struct {
+		name   string
+		domain string
+
+		want bool
+	}{
+		{
+			name:   "内置的",
+			domain: "mailinator.com",
+			want:   true,
+		},
+		{
+			name:   "大小写",
+			domain: "YopMail.com",
+			want:   true,
+		},
+		{
+			name:   "子域名",
+			domain: "abc.mailinator.com",
+			want:   true,
+		},
+		{
+			name:   "额外加的",
+			domain: "example-temp.com",
+			want:   true,
+		},
+		{
+			name:   "正常邮箱",
+			domain: "qq.com",
+		},
+		{
+			name:   "只有后缀一样",
+			domain: "notmailinator.com",
+		},
+		{
+			name:   "顶级域名",
+			domain: "com",
+		},
+	}
+	l := NewList("@Example-Temp.com")
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, l.Contains(tc.domain))
+		})
+	}
+}
+
+func TestList_Fetch(t *testing.T) {
+	body := "# 注释\nfresh-temp.com\n\n  Another-Temp.net \n"
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	l := NewList()
+	assert.False(t, l.Contains("fresh-temp.com"))
+	require.NoError(t, l.Fetch(context.Background(), server.Client(), server.URL))
+	assert.True(t, l.Contains("fresh-temp.com"))
+	assert.True(t, l.Contains("another-temp.net"))
+	// 内置的还在
+	assert.True(t, l.Contains("mailinator.com"))
+
+	// 失败了还是用上一次的
+	status = http.StatusInternalServerError
+	assert.Error(t, l.Fetch(context.Background(), server.Client(), server.URL))
+	assert.True(t, l.Contains("fresh-temp.com"))
+
+	// 每次拉下来的整体替换
+	status, body = http.StatusOK, "other-temp.com"
+	require.NoError(t, l.Fetch(context.Background(), server.Client(), server.URL))
+	assert.False(t, l.Contains("fresh-temp.com"))
+	assert.True(t, l.Contains("other-temp.com"))
+}
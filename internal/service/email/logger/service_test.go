@@ -0,0 +1,36 @@
+package logger
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestRedactToken(t *testing.T) {
+	testCases := []struct {
+		name string
+		body string
+
+		want string
+	}{
+		{
+			name: "验证链接",
+			body: "点击下面的链接验证你的邮箱，24 小时内有效：\nhttp://localhost:8080/users/email/verify?token=3.1690000000.a%40qq.com.c2ln",
+			want: "点击下面的链接验证你的邮箱，24 小时内有效：\nhttp://localhost:8080/users/email/verify?token=***",
+		},
+		{
+			name: "后面还有参数",
+			body: "https://a.com/verify?token=abc&from=mail",
+			want: "https://a.com/verify?token=***&from=mail",
+		},
+		{
+			name: "没有 token",
+			body: "你好",
+			want: "你好",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, redactToken(tc.body))
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"webook/internal/service/email"
+)
+
+// Service 不真的发，只记录下来，测试用。记录的邮件不会清掉，不要在线上用，线上用 logger.Service
+type Service struct {
+	mu   sync.Mutex
+	msgs []Message
+}
+
+var _ email.Service = (*Service)(nil)
+
+// Message 一封本来要发出去的邮件
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+func NewService() *Service {
+	return &Service{}
+}
+
+func (s *Service) Send(ctx context.Context, to, subject, body string) error {
+	msg := Message{
+		To:      to,
+		Subject: subject,
+		Body:    body,
+	}
+	s.mu.Lock()
+	s.msgs = append(s.msgs, msg)
+	s.mu.Unlock()
+	return nil
+}
+
+// Messages 到目前为止记录下来的邮件
+func (s *Service) Messages() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	res := make([]Message, len(s.msgs))
+	copy(res, s.msgs)
+	return res
+}
@@ -0,0 +1,8 @@
+package email
+
+import "context"
+
+// Service 发邮件，body 是纯文本
+type Service interface {
+	Send(ctx context.Context, to, subject, body string) error
+}
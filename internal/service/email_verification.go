@@ -0,0 +1,120 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"webook/internal/domain"
+	"webook/internal/repository"
+	"webook/internal/service/email"
+	"webook/pkg/ratelimit"
+)
+
+var (
+	ErrEmailNotVerified        = errors.New("邮箱还没有验证")
+	ErrEmailAlreadyVerified    = errors.New("邮箱已经验证过了")
+	ErrEmailVerifyTooFrequent  = errors.New("验证邮件发送太频繁")
+	ErrEmailVerifyTokenInvalid = errors.New("验证链接无效或者已经过期")
+)
+
+// EmailVerificationService 给用户的邮箱发验证链接，点了链接就算验证通过
+type EmailVerificationService interface {
+	// Send 同一个账号发送的频率有限制，太频繁返回 ErrEmailVerifyTooFrequent
+	Send(ctx context.Context, u domain.User) error
+	Verify(ctx context.Context, token string) error
+}
+
+type emailVerificationService struct {
+	repo    *repository.UserRepository
+	sender  email.Service
+	limiter ratelimit.Limiter
+	key     []byte
+	// 链接的前缀，后面直接拼上 token
+	linkPrefix string
+	ttl        time.Duration
+	now        func() time.Time
+}
+
+// NewEmailVerificationService limiter 控制每个账号重发的频率
+func NewEmailVerificationService(repo *repository.UserRepository, sender email.Service,
+	limiter ratelimit.Limiter, key []byte, linkPrefix string, ttl time.Duration) EmailVerificationService {
+	return &emailVerificationService{
+		repo:       repo,
+		sender:     sender,
+		limiter:    limiter,
+		key:        key,
+		linkPrefix: linkPrefix,
+		ttl:        ttl,
+		now:        time.Now,
+	}
+}
+
+func (svc *emailVerificationService) Send(ctx context.Context, u domain.User) error {
+	if !u.EmailVerifiedAt.IsZero() {
+		return ErrEmailAlreadyVerified
+	}
+	limited, err := svc.limiter.Limit(ctx, fmt.Sprintf("email-verify:%d", u.Id))
+	if err != nil {
+		return err
+	}
+	if limited {
+		return ErrEmailVerifyTooFrequent
+	}
+	link := svc.linkPrefix + url.QueryEscape(svc.token(u.Id, u.Email))
+	body := fmt.Sprintf("点击下面的链接验证你的邮箱，%d 小时内有效：\n%s", int(svc.ttl.Hours()), link)
+	return svc.sender.Send(ctx, u.Email, "验证你的邮箱", body)
+}
+
+// token 的格式是 uid.过期时间.邮箱.签名，验证的时候不需要查任何东西。
+// 邮箱放在里面，邮箱变了之后旧的链接就不能用了
+func (svc *emailVerificationService) token(uid int64, addr string) string {
+	payload := fmt.Sprintf("%d.%d.%s", uid, svc.now().Add(svc.ttl).UnixMilli(),
+		base64.RawURLEncoding.EncodeToString([]byte(addr)))
+	return payload + "." + svc.sign(payload)
+}
+
+func (svc *emailVerificationService) Verify(ctx context.Context, token string) error {
+	i := strings.LastIndex(token, ".")
+	if i < 0 {
+		return ErrEmailVerifyTokenInvalid
+	}
+	payload, sig := token[:i], token[i+1:]
+	if !hmac.Equal([]byte(sig), []byte(svc.sign(payload))) {
+		return ErrEmailVerifyTokenInvalid
+	}
+	parts := strings.Split(payload, ".")
+	if len(parts) != 3 {
+		return ErrEmailVerifyTokenInvalid
+	}
+	uid, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return ErrEmailVerifyTokenInvalid
+	}
+	expireAt, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || svc.now().UnixMilli() > expireAt {
+		return ErrEmailVerifyTokenInvalid
+	}
+	addr, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return ErrEmailVerifyTokenInvalid
+	}
+	err = svc.repo.VerifyEmail(ctx, uid, string(addr))
+	if err == repository.ErrUserNotFound {
+		return ErrEmailVerifyTokenInvalid
+	}
+	return err
+}
+
+func (svc *emailVerificationService) sign(payload string) string {
+	mac := hmac.New(sha256.New, svc.key)
+	mac.Write([]byte(payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
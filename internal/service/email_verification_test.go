@@ -0,0 +1,272 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	gormMysql "gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+	"webook/internal/domain"
+	"webook/internal/repository"
+	"webook/internal/repository/dao"
+	"webook/internal/service/email/memory"
+	limitmocks "webook/pkg/ratelimit/mocks"
+)
+
+const verifyLinkPrefix = "http://localhost/users/email/verify?token="
+
+func TestEmailVerificationService_Send(t *testing.T) {
+	testCases := []struct {
+		name string
+		mock func(ctrl *gomock.Controller) *limitmocks.MockLimiter
+		user domain.User
+
+		wantErr  error
+		wantSent bool
+	}{
+		{
+			name: "发送成功",
+			mock: func(ctrl *gomock.Controller) *limitmocks.MockLimiter {
+				l := limitmocks.NewMockLimiter(ctrl)
+				l.EXPECT().Limit(gomock.Any(), "email-verify:3").Return(false, nil)
+				return l
+			},
+			user:     domain.User{Id: 3, Email: "tom@qq.com"},
+			wantSent: true,
+		},
+		{
+			name: "已经验证过了",
+			mock: func(ctrl *gomock.Controller) *limitmocks.MockLimiter {
+				return limitmocks.NewMockLimiter(ctrl)
+			},
+			user:    domain.User{Id: 3, Email: "tom@qq.com", EmailVerifiedAt: time.Now()},
+			wantErr: ErrEmailAlreadyVerified,
+		},
+		{
+			name: "发送太频繁",
+			mock: func(ctrl *gomock.Controller) *limitmocks.MockLimiter {
+				l := limitmocks.NewMockLimiter(ctrl)
+				l.EXPECT().Limit(gomock.Any(), "email-verify:3").Return(true, nil)
+				return l
+			},
+			user:    domain.User{Id: 3, Email: "tom@qq.com"},
+			wantErr: ErrEmailVerifyTooFrequent,
+		},
+		{
+			name: "限流器出错",
+			mock: func(ctrl *gomock.Controller) *limitmocks.MockLimiter {
+				l := limitmocks.NewMockLimiter(ctrl)
+				l.EXPECT().Limit(gomock.Any(), "email-verify:3").Return(false, errors.New("mock redis 错误"))
+				return l
+			},
+			user:    domain.User{Id: 3, Email: "tom@qq.com"},
+			wantErr: errors.New("mock redis 错误"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			sender := memory.NewService()
+			svc := NewEmailVerificationService(nil, sender, tc.mock(ctrl),
+				[]byte("test-key"), verifyLinkPrefix, time.Hour)
+
+			err := svc.Send(context.Background(), tc.user)
+			assert.Equal(t, tc.wantErr, err)
+			msgs := sender.Messages()
+			if !tc.wantSent {
+				assert.Empty(t, msgs)
+				return
+			}
+			require.Len(t, msgs, 1)
+			assert.Equal(t, "tom@qq.com", msgs[0].To)
+			assert.Contains(t, msgs[0].Body, verifyLinkPrefix)
+		})
+	}
+}
+
+func TestEmailVerificationService_Verify(t *testing.T) {
+	now := time.Now()
+	issue := func(uid int64, addr string, ttl time.Duration) string {
+		svc := &emailVerificationService{
+			key: []byte("test-key"),
+			ttl: ttl,
+			now: func() time.Time { return now },
+		}
+		return svc.token(uid, addr)
+	}
+	update := "UPDATE `users` SET .* WHERE id = \\? AND email = \\? AND status = \\?"
+	testCases := []struct {
+		name  string
+		token string
+		mock  func(mock sqlmock.Sqlmock)
+
+		wantErr error
+	}{
+		{
+			name:  "验证成功",
+			token: issue(3, "tom@qq.com", time.Hour),
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(update).
+					WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), 3, "tom@qq.com", 0).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+		},
+		{
+			name:  "邮箱已经不是这个了",
+			token: issue(3, "tom@qq.com", time.Hour),
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(update).WillReturnResult(sqlmock.NewResult(0, 0))
+			},
+			wantErr: ErrEmailVerifyTokenInvalid,
+		},
+		{
+			name:    "过期了",
+			token:   issue(3, "tom@qq.com", -time.Second),
+			wantErr: ErrEmailVerifyTokenInvalid,
+		},
+		{
+			name:    "改了 uid",
+			token:   "4" + strings.TrimPrefix(issue(3, "tom@qq.com", time.Hour), "3"),
+			wantErr: ErrEmailVerifyTokenInvalid,
+		},
+		{
+			name:    "乱写的",
+			token:   "abc",
+			wantErr: ErrEmailVerifyTokenInvalid,
+		},
+		{
+			name:  "数据库出错",
+			token: issue(3, "tom@qq.com", time.Hour),
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(update).WillReturnError(errors.New("mock db 错误"))
+			},
+			wantErr: errors.New("mock db 错误"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockDB, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			db, err := gorm.Open(gormMysql.New(gormMysql.Config{
+				Conn:                      mockDB,
+				SkipInitializeWithVersion: true,
+			}), &gorm.Config{
+				DisableAutomaticPing:   true,
+				SkipDefaultTransaction: true,
+			})
+			require.NoError(t, err)
+			if tc.mock != nil {
+				tc.mock(mock)
+			}
+			svc := NewEmailVerificationService(repository.NewUserRepository(dao.NewUserDAO(db), nil),
+				nil, nil, []byte("test-key"), verifyLinkPrefix, time.Hour)
+
+			err = svc.Verify(context.Background(), tc.token)
+			assert.Equal(t, tc.wantErr, err)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+// 邮件里面的链接拿出来就能直接用
+func TestEmailVerificationService_Link(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	l := limitmocks.NewMockLimiter(ctrl)
+	l.EXPECT().Limit(gomock.Any(), gomock.Any()).Return(false, nil)
+	sender := memory.NewService()
+	svc := NewEmailVerificationService(nil, sender, l, []byte("test-key"), verifyLinkPrefix, time.Hour)
+	require.NoError(t, svc.Send(context.Background(), domain.User{Id: 3, Email: "tom.cat@qq.com"}))
+
+	body := sender.Messages()[0].Body
+	link, err := url.Parse(body[strings.Index(body, verifyLinkPrefix):])
+	require.NoError(t, err)
+	token := link.Query().Get("token")
+	i := strings.LastIndex(token, ".")
+	assert.Equal(t, svc.(*emailVerificationService).sign(token[:i]), token[i+1:])
+}
+
+func TestUserService_ResendVerificationEmail(t *testing.T) {
+	query := "SELECT \\* FROM `users` WHERE email = \\?"
+	testCases := []struct {
+		name    string
+		limited bool
+		mock    func(mock sqlmock.Sqlmock)
+
+		wantErr  error
+		wantSent bool
+	}{
+		{
+			name: "还没验证，发送成功",
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(query).WillReturnRows(
+					sqlmock.NewRows([]string{"id", "email"}).AddRow(3, "tom@qq.com"))
+			},
+			wantSent: true,
+		},
+		{
+			name: "已经验证过了",
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(query).WillReturnRows(
+					sqlmock.NewRows([]string{"id", "email", "email_verified_at"}).
+						AddRow(3, "tom@qq.com", 1700000000000))
+			},
+			wantErr: ErrEmailAlreadyVerified,
+		},
+		{
+			// 跟发送成功一样，不能让人知道这个邮箱没注册过
+			name: "没有这个账号",
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(query).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+			},
+		},
+		{
+			// 被限流了就不查数据库了
+			name:    "重发太频繁",
+			limited: true,
+			wantErr: ErrEmailVerifyTooFrequent,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			mockDB, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			db, err := gorm.Open(gormMysql.New(gormMysql.Config{
+				Conn:                      mockDB,
+				SkipInitializeWithVersion: true,
+			}), &gorm.Config{
+				DisableAutomaticPing:   true,
+				SkipDefaultTransaction: true,
+			})
+			require.NoError(t, err)
+			if tc.mock != nil {
+				tc.mock(mock)
+			}
+			resendLimiter := limitmocks.NewMockLimiter(ctrl)
+			resendLimiter.EXPECT().Limit(gomock.Any(), "email_resend:tom@qq.com").Return(tc.limited, nil)
+			sendLimiter := limitmocks.NewMockLimiter(ctrl)
+			sendLimiter.EXPECT().Limit(gomock.Any(), "email-verify:3").Return(false, nil).AnyTimes()
+			sender := memory.NewService()
+			repo := repository.NewUserRepository(dao.NewUserDAO(db), nil)
+			svc := NewUserService(repo, nil, nil, SensitiveModeReject, nil, nil,
+				WithEmailVerification(NewEmailVerificationService(repo, sender, sendLimiter,
+					[]byte("test-key"), verifyLinkPrefix, time.Hour), false),
+				WithEmailResendLimiter(resendLimiter))
+
+			err = svc.ResendVerificationEmail(context.Background(), "Tom@qq.com")
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.wantSent, len(sender.Messages()) == 1)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
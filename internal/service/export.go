@@ -0,0 +1,186 @@
+package service
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"time"
+	"webook/internal/domain"
+	"webook/internal/repository/cache"
+	"webook/pkg/ratelimit"
+)
+
+var (
+	ErrExportTooFrequent = errors.New("一天只能导出一次")
+	ErrExportNotFound    = errors.New("导出任务不存在或者已经过期")
+)
+
+// UserExportService 异步导出用户的个人数据
+type UserExportService interface {
+	// Start 发起导出，返回任务 ID
+	Start(ctx context.Context, uid int64) (string, error)
+	// Get 只能拿到自己的任务
+	Get(ctx context.Context, uid int64, taskId string) (domain.ExportTask, error)
+}
+
+// UserDataExporter 生成导出文件，*UserService 实现了它
+type UserDataExporter interface {
+	ExportUserData(ctx context.Context, uid int64) (io.Reader, string, error)
+}
+
+type userExportService struct {
+	exporter UserDataExporter
+	cache    cache.UserExportCache
+	limiter  ratelimit.Limiter
+	// 生成一次导出最多花多长时间
+	timeout time.Duration
+}
+
+// NewUserExportService limiter 用来控制每个用户的导出频率
+func NewUserExportService(exporter UserDataExporter,
+	c cache.UserExportCache, limiter ratelimit.Limiter) UserExportService {
+	return &userExportService{
+		exporter: exporter,
+		cache:    c,
+		limiter:  limiter,
+		timeout:  time.Minute,
+	}
+}
+
+func (svc *userExportService) Start(ctx context.Context, uid int64) (string, error) {
+	limited, err := svc.limiter.Limit(ctx, fmt.Sprintf("user-export:%d", uid))
+	if err != nil {
+		return "", err
+	}
+	if limited {
+		return "", ErrExportTooFrequent
+	}
+	id, err := svc.newTaskId()
+	if err != nil {
+		return "", err
+	}
+	task := domain.ExportTask{
+		Id:     id,
+		Uid:    uid,
+		Status: domain.ExportTaskPending,
+	}
+	if err = svc.cache.Set(ctx, task); err != nil {
+		return "", err
+	}
+	// 数据多的时候生成比较慢，所以异步生成。
+	// 不能用请求的 ctx，请求返回了它就被取消了
+	go svc.generate(task)
+	return id, nil
+}
+
+func (svc *userExportService) generate(task domain.ExportTask) {
+	ctx, cancel := context.WithTimeout(context.Background(), svc.timeout)
+	defer cancel()
+	data, fileName, err := svc.export(ctx, task.Uid)
+	if err != nil {
+		log.Println("导出用户数据失败", task.Uid, err)
+		task.Status = domain.ExportTaskFailed
+	} else {
+		task.Status = domain.ExportTaskDone
+		task.Data = data
+		task.FileName = fileName
+	}
+	if err = svc.cache.Set(ctx, task); err != nil {
+		log.Println("保存导出结果失败", task.Uid, err)
+	}
+}
+
+func (svc *userExportService) export(ctx context.Context, uid int64) ([]byte, string, error) {
+	r, fileName, err := svc.exporter.ExportUserData(ctx, uid)
+	if err != nil {
+		return nil, "", err
+	}
+	data, err := io.ReadAll(r)
+	return data, fileName, err
+}
+
+// ExportUserData 把分散在各个地方的数据聚合成一个 JSON 文档，用 gzip 压缩。
+// 返回的 string 是建议的文件名。每次调用都会重新查一遍，要缓存的话用 UserExportService
+func (svc *UserService) ExportUserData(ctx context.Context, uid int64) (io.Reader, string, error) {
+	u, err := svc.repo.FindById(ctx, uid)
+	if err != nil {
+		return nil, "", err
+	}
+	res := domain.UserDataExport{
+		GeneratedAt: time.Now(),
+		Profile: domain.ExportProfile{
+			Id:            u.Id,
+			Nickname:      u.Nickname,
+			Birthday:      u.Birthday,
+			ShowBirthYear: u.ShowBirthYear,
+			Brief:         u.Brief,
+			Gender:        u.Gender.String(),
+			Region:        u.Region,
+			City:          u.City,
+			Location:      u.Location,
+			Website:       u.Website,
+			Timezone:      u.Timezone,
+			Locale:        u.Locale,
+			Ctime:         u.Ctime,
+		},
+		Identities: []domain.BoundIdentity{},
+	}
+	if u.Email != "" {
+		res.Identities = append(res.Identities, domain.BoundIdentity{Type: "email", Value: u.Email})
+	}
+	if u.Phone != "" {
+		res.Identities = append(res.Identities, domain.BoundIdentity{Type: "phone", Value: u.Phone})
+	}
+	events, err := svc.GetLoginHistory(ctx, uid, MaxLoginHistoryLimit)
+	if err != nil {
+		return nil, "", err
+	}
+	res.LoginHistory = make([]domain.ExportLoginEvent, 0, len(events))
+	for _, e := range events {
+		res.LoginHistory = append(res.LoginHistory, domain.ExportLoginEvent{
+			IP:        e.IP,
+			UserAgent: e.UserAgent,
+			Status:    e.Status.String(),
+			Ctime:     e.CreatedAt,
+		})
+	}
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	if err = json.NewEncoder(gw).Encode(res); err != nil {
+		return nil, "", err
+	}
+	if err = gw.Close(); err != nil {
+		return nil, "", err
+	}
+	return &buf, fmt.Sprintf("webook-export-%d.json.gz", uid), nil
+}
+
+func (svc *userExportService) Get(ctx context.Context, uid int64, taskId string) (domain.ExportTask, error) {
+	task, err := svc.cache.Get(ctx, taskId)
+	if err == cache.ErrKeyNotExist {
+		return domain.ExportTask{}, ErrExportNotFound
+	}
+	if err != nil {
+		return domain.ExportTask{}, err
+	}
+	// 别人的任务，就当作不存在
+	if task.Uid != uid {
+		return domain.ExportTask{}, ErrExportNotFound
+	}
+	return task, nil
+}
+
+func (svc *userExportService) newTaskId() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,104 @@
+package service
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	gormMysql "gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"io"
+	"testing"
+	"time"
+	"webook/internal/domain"
+	"webook/internal/repository"
+	cachemocks "webook/internal/repository/cache/mocks"
+	"webook/internal/repository/dao"
+	limitmocks "webook/pkg/ratelimit/mocks"
+)
+
+// exportUserService 按 id 查用户的时候返回 rows
+func exportUserService(t *testing.T, rows *sqlmock.Rows) *UserService {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	mock.ExpectQuery("SELECT \\* FROM `users` WHERE id = \\?").WillReturnRows(rows)
+	db, err := gorm.Open(gormMysql.New(gormMysql.Config{
+		Conn:                      mockDB,
+		SkipInitializeWithVersion: true,
+	}), &gorm.Config{
+		DisableAutomaticPing:   true,
+		SkipDefaultTransaction: true,
+	})
+	require.NoError(t, err)
+	return NewUserService(repository.NewUserRepository(dao.NewUserDAO(db), nil),
+		nil, nil, SensitiveModeReject, nil, nil)
+}
+
+// readExport 解压出来应该是一个完整的 JSON 文档
+func readExport(t *testing.T, r io.Reader) domain.UserDataExport {
+	gr, err := gzip.NewReader(r)
+	require.NoError(t, err)
+	var res domain.UserDataExport
+	require.NoError(t, json.NewDecoder(gr).Decode(&res))
+	require.NoError(t, gr.Close())
+	return res
+}
+
+func TestUserService_ExportUserData(t *testing.T) {
+	svc := exportUserService(t, sqlmock.NewRows([]string{"id", "nickname", "email", "phone"}).
+		AddRow(3, "Tom", "tom@qq.com", "15212345678"))
+	r, fileName, err := svc.ExportUserData(context.Background(), 3)
+	require.NoError(t, err)
+	assert.Equal(t, "webook-export-3.json.gz", fileName)
+
+	res := readExport(t, r)
+	assert.WithinDuration(t, time.Now(), res.GeneratedAt, time.Minute)
+	assert.Equal(t, int64(3), res.Profile.Id)
+	assert.Equal(t, "Tom", res.Profile.Nickname)
+	assert.Equal(t, []domain.BoundIdentity{
+		{Type: "email", Value: "tom@qq.com"},
+		{Type: "phone", Value: "15212345678"},
+	}, res.Identities)
+}
+
+func TestUserExportService_Start(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	limiter := limitmocks.NewMockLimiter(ctrl)
+	limiter.EXPECT().Limit(gomock.Any(), "user-export:3").Return(false, nil)
+
+	// 先存一个 pending 的，生成好了再存一次
+	done := make(chan domain.ExportTask, 1)
+	c := cachemocks.NewMockUserExportCache(ctrl)
+	gomock.InOrder(
+		c.EXPECT().Set(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, task domain.ExportTask) error {
+			assert.Equal(t, domain.ExportTaskPending, task.Status)
+			return nil
+		}),
+		c.EXPECT().Set(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, task domain.ExportTask) error {
+			done <- task
+			return nil
+		}),
+	)
+	svc := NewUserExportService(exportUserService(t,
+		sqlmock.NewRows([]string{"id", "nickname"}).AddRow(3, "Tom")), c, limiter)
+
+	id, err := svc.Start(context.Background(), 3)
+	require.NoError(t, err)
+	select {
+	case task := <-done:
+		assert.Equal(t, id, task.Id)
+		assert.Equal(t, int64(3), task.Uid)
+		assert.Equal(t, domain.ExportTaskDone, task.Status)
+		assert.Equal(t, "webook-export-3.json.gz", task.FileName)
+		res := readExport(t, bytes.NewReader(task.Data))
+		assert.Equal(t, "Tom", res.Profile.Nickname)
+		assert.Empty(t, res.Identities)
+	case <-time.After(time.Second * 5):
+		t.Fatal("导出超时")
+	}
+}
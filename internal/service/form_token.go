@@ -0,0 +1,98 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"webook/internal/repository/cache"
+)
+
+var (
+	ErrFormTokenInvalid = errors.New("表单 token 无效或者已经过期")
+	ErrFormTokenTooFast = errors.New("表单提交太快")
+	ErrFormTokenUsed    = errors.New("表单 token 已经用过了")
+)
+
+// FormTokenService 签发和校验一次性的表单 token。
+// 页面加载的时候先拿一个 token，提交的时候带上。
+// 提交得太快（脚本）或者重复使用 token 都会被拒绝
+type FormTokenService interface {
+	Issue(ctx context.Context) (string, error)
+	Consume(ctx context.Context, token string) error
+}
+
+type formTokenService struct {
+	cache cache.FormTokenCache
+	key   []byte
+	// 签发之后至少要过这么久才能用
+	minDelay time.Duration
+	// 签发之后过了这么久就不能用了
+	ttl time.Duration
+	now func() time.Time
+}
+
+func NewFormTokenService(c cache.FormTokenCache, key []byte,
+	minDelay time.Duration, ttl time.Duration) FormTokenService {
+	return &formTokenService{
+		cache:    c,
+		key:      key,
+		minDelay: minDelay,
+		ttl:      ttl,
+		now:      time.Now,
+	}
+}
+
+// Issue token 的格式是 nonce.签发时间.签名，签发的时候不需要存任何东西
+func (svc *formTokenService) Issue(ctx context.Context) (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	payload := fmt.Sprintf("%s.%d", hex.EncodeToString(buf), svc.now().UnixMilli())
+	return payload + "." + svc.sign(payload), nil
+}
+
+func (svc *formTokenService) Consume(ctx context.Context, token string) error {
+	segs := strings.Split(token, ".")
+	if len(segs) != 3 {
+		return ErrFormTokenInvalid
+	}
+	payload := segs[0] + "." + segs[1]
+	if !hmac.Equal([]byte(svc.sign(payload)), []byte(segs[2])) {
+		// 有人伪造 token
+		return ErrFormTokenInvalid
+	}
+	issuedAt, err := strconv.ParseInt(segs[1], 10, 64)
+	if err != nil {
+		return ErrFormTokenInvalid
+	}
+	elapsed := svc.now().Sub(time.UnixMilli(issuedAt))
+	if elapsed > svc.ttl {
+		return ErrFormTokenInvalid
+	}
+	if elapsed < svc.minDelay {
+		// 人不可能这么快，大概率是脚本
+		return ErrFormTokenTooFast
+	}
+	ok, err := svc.cache.MarkUsed(ctx, segs[0], svc.ttl)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrFormTokenUsed
+	}
+	return nil
+}
+
+func (svc *formTokenService) sign(payload string) string {
+	mac := hmac.New(sha256.New, svc.key)
+	mac.Write([]byte(payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
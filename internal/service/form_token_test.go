@@ -0,0 +1,116 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"strings"
+	"testing"
+	"time"
+	"webook/internal/repository/cache"
+	cachemocks "webook/internal/repository/cache/mocks"
+)
+
+func TestFormTokenService_Consume(t *testing.T) {
+	now := time.Now()
+	testCases := []struct {
+		name string
+		mock func(ctrl *gomock.Controller) cache.FormTokenCache
+		// 签发之后过了多久才提交
+		elapsed time.Duration
+		// 提交之前对 token 动点手脚
+		tamper func(token string) string
+
+		wantErr error
+	}{
+		{
+			name: "校验通过",
+			mock: func(ctrl *gomock.Controller) cache.FormTokenCache {
+				c := cachemocks.NewMockFormTokenCache(ctrl)
+				c.EXPECT().MarkUsed(gomock.Any(), gomock.Any(), time.Minute*10).Return(true, nil)
+				return c
+			},
+			elapsed: time.Second * 3,
+		},
+		{
+			name: "提交太快",
+			mock: func(ctrl *gomock.Controller) cache.FormTokenCache {
+				return cachemocks.NewMockFormTokenCache(ctrl)
+			},
+			elapsed: time.Millisecond * 100,
+			wantErr: ErrFormTokenTooFast,
+		},
+		{
+			name: "已经过期",
+			mock: func(ctrl *gomock.Controller) cache.FormTokenCache {
+				return cachemocks.NewMockFormTokenCache(ctrl)
+			},
+			elapsed: time.Minute * 11,
+			wantErr: ErrFormTokenInvalid,
+		},
+		{
+			name: "重复使用",
+			mock: func(ctrl *gomock.Controller) cache.FormTokenCache {
+				c := cachemocks.NewMockFormTokenCache(ctrl)
+				c.EXPECT().MarkUsed(gomock.Any(), gomock.Any(), time.Minute*10).Return(false, nil)
+				return c
+			},
+			elapsed: time.Second * 3,
+			wantErr: ErrFormTokenUsed,
+		},
+		{
+			name: "篡改了签发时间",
+			mock: func(ctrl *gomock.Controller) cache.FormTokenCache {
+				return cachemocks.NewMockFormTokenCache(ctrl)
+			},
+			elapsed: time.Second * 3,
+			tamper: func(token string) string {
+				segs := strings.Split(token, ".")
+				segs[1] = "0"
+				return strings.Join(segs, ".")
+			},
+			wantErr: ErrFormTokenInvalid,
+		},
+		{
+			name: "格式不对",
+			mock: func(ctrl *gomock.Controller) cache.FormTokenCache {
+				return cachemocks.NewMockFormTokenCache(ctrl)
+			},
+			elapsed: time.Second * 3,
+			tamper: func(token string) string {
+				return "abc"
+			},
+			wantErr: ErrFormTokenInvalid,
+		},
+		{
+			name: "redis错误",
+			mock: func(ctrl *gomock.Controller) cache.FormTokenCache {
+				c := cachemocks.NewMockFormTokenCache(ctrl)
+				c.EXPECT().MarkUsed(gomock.Any(), gomock.Any(), time.Minute*10).
+					Return(false, errors.New("mock redis 错误"))
+				return c
+			},
+			elapsed: time.Second * 3,
+			wantErr: errors.New("mock redis 错误"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			svc := NewFormTokenService(tc.mock(ctrl), []byte("test key"),
+				time.Second, time.Minute*10).(*formTokenService)
+			svc.now = func() time.Time { return now }
+			token, err := svc.Issue(context.Background())
+			require.NoError(t, err)
+			if tc.tamper != nil {
+				token = tc.tamper(token)
+			}
+			svc.now = func() time.Time { return now.Add(tc.elapsed) }
+			err = svc.Consume(context.Background(), token)
+			assert.Equal(t, tc.wantErr, err)
+		})
+	}
+}
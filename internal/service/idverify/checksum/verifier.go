@@ -0,0 +1,38 @@
+package checksum
+
+import (
+	"strings"
+	"webook/internal/service/idverify"
+)
+
+// Verifier 桩实现，不校验姓名，只校验身份证号码的校验位，
+// 在没有接入真实的实名认证服务之前用
+type Verifier struct {
+}
+
+func NewVerifier() idverify.IDVerifier {
+	return &Verifier{}
+}
+
+// 前 17 位的加权因子
+var weights = [17]int{7, 9, 10, 5, 8, 4, 2, 1, 6, 3, 7, 9, 10, 5, 8, 4, 2}
+
+// 加权和模 11 之后对应的校验位
+const checkCodes = "10X98765432"
+
+func (v *Verifier) Verify(realName, idCard string) (bool, error) {
+	if realName == "" || len(idCard) != 18 {
+		return false, nil
+	}
+	idCard = strings.ToUpper(idCard)
+	sum := 0
+	for i := 0; i < 17; i++ {
+		c := idCard[i]
+		if c < '0' || c > '9' {
+			return false, nil
+		}
+		sum += int(c-'0') * weights[i]
+	}
+	// GB 11643 规定的 ISO 7064 MOD 11-2 校验
+	return idCard[17] == checkCodes[sum%11], nil
+}
@@ -0,0 +1,31 @@
+package checksum
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestVerifier_Verify(t *testing.T) {
+	testCases := []struct {
+		name     string
+		realName string
+		idCard   string
+		want     bool
+	}{
+		{name: "校验位正确", realName: "张三", idCard: "11010519491231002X", want: true},
+		{name: "校验位是小写的 x", realName: "张三", idCard: "11010519491231002x", want: true},
+		{name: "校验位是数字", realName: "张三", idCard: "440524188001010014", want: true},
+		{name: "校验位不对", realName: "张三", idCard: "110105194912310021"},
+		{name: "长度不对", realName: "张三", idCard: "1101051949123100"},
+		{name: "有非数字", realName: "张三", idCard: "1101051949A231002X"},
+		{name: "没有姓名", idCard: "11010519491231002X"},
+	}
+	v := NewVerifier()
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ok, err := v.Verify(tc.realName, tc.idCard)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, ok)
+		})
+	}
+}
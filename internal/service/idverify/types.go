@@ -0,0 +1,7 @@
+package idverify
+
+// IDVerifier 实名认证。真实环境下对接公安或者第三方的实名认证接口
+type IDVerifier interface {
+	// Verify 姓名和身份证号是否匹配
+	Verify(realName, idCard string) (bool, error)
+}
@@ -0,0 +1,49 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+	"webook/internal/repository/cache"
+)
+
+// JWTNonceService 每个 JWT 里面带一个随机的 nonce，相当于 jti。
+// 中间件每次都检查 nonce 还在不在，退出登录的时候删掉，手上的 token 马上就不能用了，
+// 不用等它过期
+type JWTNonceService interface {
+	// Issue 签发 token 之前调用，nonce 跟 token 同时过期
+	Issue(ctx context.Context, uid int64, expiresAt time.Time) (string, error)
+	Check(ctx context.Context, uid int64, nonce string) (bool, error)
+	Revoke(ctx context.Context, uid int64, nonce string) error
+}
+
+type jwtNonceService struct {
+	cache cache.JWTNonceCache
+}
+
+func NewJWTNonceService(cache cache.JWTNonceCache) JWTNonceService {
+	return &jwtNonceService{
+		cache: cache,
+	}
+}
+
+func (svc *jwtNonceService) Issue(ctx context.Context, uid int64, expiresAt time.Time) (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	nonce := hex.EncodeToString(buf)
+	if err := svc.cache.Set(ctx, uid, nonce, time.Until(expiresAt)); err != nil {
+		return "", err
+	}
+	return nonce, nil
+}
+
+func (svc *jwtNonceService) Check(ctx context.Context, uid int64, nonce string) (bool, error) {
+	return svc.cache.Exists(ctx, uid, nonce)
+}
+
+func (svc *jwtNonceService) Revoke(ctx context.Context, uid int64, nonce string) error {
+	return svc.cache.Delete(ctx, uid, nonce)
+}
@@ -0,0 +1,63 @@
+package service
+
+import (
+	"context"
+	"unicode/utf8"
+	"webook/internal/domain"
+	"webook/internal/repository"
+)
+
+const (
+	// DefaultLoginHistoryLimit 不传 limit 的时候查最近这么多条
+	DefaultLoginHistoryLimit = 20
+	// MaxLoginHistoryLimit 一次最多查这么多条
+	MaxLoginHistoryLimit = 100
+	// 跟 login_events 表里面的长度一样
+	loginEventUserAgentMaxRunes = 512
+)
+
+// WithLoginHistory 登录的时候记下来 IP 和 User-Agent，用户自己可以看
+func WithLoginHistory(repo repository.LoginEventRepository) UserServiceOption {
+	return func(svc *UserService) {
+		svc.loginEvents = repo
+	}
+}
+
+// RecordLogin 没有配置 WithLoginHistory 的时候什么都不做
+func (svc *UserService) RecordLogin(ctx context.Context, userID int64, ip, userAgent string, success bool) error {
+	if svc.loginEvents == nil {
+		return nil
+	}
+	status := domain.LoginEventStatusFailed
+	if success {
+		status = domain.LoginEventStatusSuccess
+	}
+	return svc.loginEvents.Create(ctx, domain.LoginEvent{
+		UserID:    userID,
+		IP:        ip,
+		UserAgent: truncateRunes(userAgent, loginEventUserAgentMaxRunes),
+		Status:    status,
+	})
+}
+
+// GetLoginHistory 最近的在前面。limit 不是正数就用 DefaultLoginHistoryLimit，
+// 超过 MaxLoginHistoryLimit 的按 MaxLoginHistoryLimit 算
+func (svc *UserService) GetLoginHistory(ctx context.Context, userID int64, limit int) ([]domain.LoginEvent, error) {
+	if svc.loginEvents == nil {
+		return []domain.LoginEvent{}, nil
+	}
+	if limit <= 0 {
+		limit = DefaultLoginHistoryLimit
+	}
+	if limit > MaxLoginHistoryLimit {
+		limit = MaxLoginHistoryLimit
+	}
+	return svc.loginEvents.FindByUserId(ctx, userID, limit)
+}
+
+func truncateRunes(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n])
+}
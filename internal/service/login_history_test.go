@@ -0,0 +1,108 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	gormMysql "gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"strings"
+	"testing"
+	"time"
+	"webook/internal/domain"
+	"webook/internal/repository"
+	"webook/internal/repository/dao"
+)
+
+func loginHistoryService(t *testing.T) (*UserService, sqlmock.Sqlmock) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	db, err := gorm.Open(gormMysql.New(gormMysql.Config{
+		Conn:                      mockDB,
+		SkipInitializeWithVersion: true,
+	}), &gorm.Config{
+		DisableAutomaticPing:   true,
+		SkipDefaultTransaction: true,
+	})
+	require.NoError(t, err)
+	svc := NewUserService(repository.NewUserRepository(dao.NewUserDAO(db), nil),
+		nil, nil, SensitiveModeReject, nil, nil,
+		WithLoginHistory(repository.NewLoginEventRepository(dao.NewLoginEventDAO(db))))
+	return svc, mock
+}
+
+// seedLoginEvents 25 条，一分钟一条，数据库按 ctime DESC 排好了之后取前 n 条
+func seedLoginEvents(base time.Time, n int) *sqlmock.Rows {
+	rows := sqlmock.NewRows([]string{"id", "user_id", "ip", "user_agent", "status", "ctime"})
+	for i := 25; i > 25-n && i > 0; i-- {
+		rows.AddRow(i, 3, fmt.Sprintf("10.0.0.%d", i), "Chrome", uint8(domain.LoginEventStatusSuccess),
+			base.Add(time.Duration(i)*time.Minute).UnixMilli())
+	}
+	return rows
+}
+
+func TestUserService_GetLoginHistory(t *testing.T) {
+	base := time.UnixMilli(1700000000000)
+	testCases := []struct {
+		name  string
+		limit int
+
+		wantLimit int
+	}{
+		{name: "默认 20 条", limit: 0, wantLimit: 20},
+		{name: "负数按默认的", limit: -1, wantLimit: 20},
+		{name: "指定条数", limit: 5, wantLimit: 5},
+		// 一共只有 25 条
+		{name: "比有的多", limit: 50, wantLimit: 50},
+		{name: "最多 100 条", limit: 500, wantLimit: 100},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			svc, mock := loginHistoryService(t)
+			mock.ExpectQuery("SELECT \\* FROM `login_events` WHERE user_id = \\? " +
+				"ORDER BY ctime DESC, id DESC LIMIT " + fmt.Sprint(tc.wantLimit)).
+				WithArgs(int64(3)).
+				WillReturnRows(seedLoginEvents(base, tc.wantLimit))
+
+			events, err := svc.GetLoginHistory(context.Background(), 3, tc.limit)
+			require.NoError(t, err)
+			assert.NoError(t, mock.ExpectationsWereMet())
+			wantLen := tc.wantLimit
+			if wantLen > 25 {
+				wantLen = 25
+			}
+			require.Len(t, events, wantLen)
+			// 最近的在前面
+			assert.Equal(t, int64(25), events[0].ID)
+			assert.Equal(t, base.Add(25*time.Minute).UnixMilli(), events[0].CreatedAt.UnixMilli())
+			for i := 1; i < len(events); i++ {
+				assert.True(t, events[i-1].CreatedAt.After(events[i].CreatedAt))
+			}
+			assert.Equal(t, domain.LoginEventStatusSuccess, events[0].Status)
+		})
+	}
+}
+
+func TestUserService_RecordLogin(t *testing.T) {
+	t.Run("记下来", func(t *testing.T) {
+		svc, mock := loginHistoryService(t)
+		mock.ExpectExec("INSERT INTO `login_events` .*").
+			WithArgs(int64(3), "10.0.0.1", strings.Repeat("a", 512),
+				uint8(domain.LoginEventStatusSuccess), sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		// User-Agent 太长了截断
+		err := svc.RecordLogin(context.Background(), 3, "10.0.0.1", strings.Repeat("a", 600), true)
+		require.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("没有配置就不记", func(t *testing.T) {
+		svc := NewUserService(nil, nil, nil, SensitiveModeReject, nil, nil)
+		require.NoError(t, svc.RecordLogin(context.Background(), 3, "10.0.0.1", "Chrome", false))
+		events, err := svc.GetLoginHistory(context.Background(), 3, 20)
+		require.NoError(t, err)
+		assert.Empty(t, events)
+	})
+}
@@ -0,0 +1,79 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: webook/internal/service/backup_code.go
+
+// Package svcmocks is a generated GoMock package.
+package svcmocks
+
+import (
+	context "context"
+	reflect "reflect"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockBackupCodeService is a mock of BackupCodeService interface.
+type MockBackupCodeService struct {
+	ctrl     *gomock.Controller
+	recorder *MockBackupCodeServiceMockRecorder
+}
+
+// MockBackupCodeServiceMockRecorder is the mock recorder for MockBackupCodeService.
+type MockBackupCodeServiceMockRecorder struct {
+	mock *MockBackupCodeService
+}
+
+// NewMockBackupCodeService creates a new mock instance.
+func NewMockBackupCodeService(ctrl *gomock.Controller) *MockBackupCodeService {
+	mock := &MockBackupCodeService{ctrl: ctrl}
+	mock.recorder = &MockBackupCodeServiceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockBackupCodeService) EXPECT() *MockBackupCodeServiceMockRecorder {
+	return m.recorder
+}
+
+// Count mocks base method.
+func (m *MockBackupCodeService) Count(ctx context.Context, uid int64) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Count", ctx, uid)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Count indicates an expected call of Count.
+func (mr *MockBackupCodeServiceMockRecorder) Count(ctx, uid interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Count", reflect.TypeOf((*MockBackupCodeService)(nil).Count), ctx, uid)
+}
+
+// Generate mocks base method.
+func (m *MockBackupCodeService) Generate(ctx context.Context, uid int64) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Generate", ctx, uid)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Generate indicates an expected call of Generate.
+func (mr *MockBackupCodeServiceMockRecorder) Generate(ctx, uid interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Generate", reflect.TypeOf((*MockBackupCodeService)(nil).Generate), ctx, uid)
+}
+
+// Verify mocks base method.
+func (m *MockBackupCodeService) Verify(ctx context.Context, uid int64, code string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Verify", ctx, uid, code)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Verify indicates an expected call of Verify.
+func (mr *MockBackupCodeServiceMockRecorder) Verify(ctx, uid, code interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Verify", reflect.TypeOf((*MockBackupCodeService)(nil).Verify), ctx, uid, code)
+}
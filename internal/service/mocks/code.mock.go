@@ -7,6 +7,8 @@ package svcmocks
 import (
 	context "context"
 	reflect "reflect"
+	time "time"
+	repository "webook/internal/repository"
 
 	gomock "go.uber.org/mock/gomock"
 )
@@ -34,18 +36,33 @@ func (m *MockCodeService) EXPECT() *MockCodeServiceMockRecorder {
 	return m.recorder
 }
 
+// Cooldown mocks base method.
+func (m *MockCodeService) Cooldown(ctx context.Context, biz, phone string) (time.Duration, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Cooldown", ctx, biz, phone)
+	ret0, _ := ret[0].(time.Duration)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Cooldown indicates an expected call of Cooldown.
+func (mr *MockCodeServiceMockRecorder) Cooldown(ctx, biz, phone interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Cooldown", reflect.TypeOf((*MockCodeService)(nil).Cooldown), ctx, biz, phone)
+}
+
 // Send mocks base method.
-func (m *MockCodeService) Send(ctx context.Context, biz, phone string) error {
+func (m *MockCodeService) Send(ctx context.Context, biz, phone, ip string) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Send", ctx, biz, phone)
+	ret := m.ctrl.Call(m, "Send", ctx, biz, phone, ip)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // Send indicates an expected call of Send.
-func (mr *MockCodeServiceMockRecorder) Send(ctx, biz, phone interface{}) *gomock.Call {
+func (mr *MockCodeServiceMockRecorder) Send(ctx, biz, phone, ip interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Send", reflect.TypeOf((*MockCodeService)(nil).Send), ctx, biz, phone)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Send", reflect.TypeOf((*MockCodeService)(nil).Send), ctx, biz, phone, ip)
 }
 
 // Verify mocks base method.
@@ -62,3 +79,18 @@ func (mr *MockCodeServiceMockRecorder) Verify(ctx, biz, phone, inputCode interfa
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Verify", reflect.TypeOf((*MockCodeService)(nil).Verify), ctx, biz, phone, inputCode)
 }
+
+// VerifyWithResult mocks base method.
+func (m *MockCodeService) VerifyWithResult(ctx context.Context, biz, phone, inputCode string) (repository.VerifyResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "VerifyWithResult", ctx, biz, phone, inputCode)
+	ret0, _ := ret[0].(repository.VerifyResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// VerifyWithResult indicates an expected call of VerifyWithResult.
+func (mr *MockCodeServiceMockRecorder) VerifyWithResult(ctx, biz, phone, inputCode interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "VerifyWithResult", reflect.TypeOf((*MockCodeService)(nil).VerifyWithResult), ctx, biz, phone, inputCode)
+}
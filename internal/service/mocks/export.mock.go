@@ -0,0 +1,106 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: webook/internal/service/export.go
+
+// Package svcmocks is a generated GoMock package.
+package svcmocks
+
+import (
+	context "context"
+	io "io"
+	reflect "reflect"
+	domain "webook/internal/domain"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockUserExportService is a mock of UserExportService interface.
+type MockUserExportService struct {
+	ctrl     *gomock.Controller
+	recorder *MockUserExportServiceMockRecorder
+}
+
+// MockUserExportServiceMockRecorder is the mock recorder for MockUserExportService.
+type MockUserExportServiceMockRecorder struct {
+	mock *MockUserExportService
+}
+
+// NewMockUserExportService creates a new mock instance.
+func NewMockUserExportService(ctrl *gomock.Controller) *MockUserExportService {
+	mock := &MockUserExportService{ctrl: ctrl}
+	mock.recorder = &MockUserExportServiceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockUserExportService) EXPECT() *MockUserExportServiceMockRecorder {
+	return m.recorder
+}
+
+// Get mocks base method.
+func (m *MockUserExportService) Get(ctx context.Context, uid int64, taskId string) (domain.ExportTask, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Get", ctx, uid, taskId)
+	ret0, _ := ret[0].(domain.ExportTask)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Get indicates an expected call of Get.
+func (mr *MockUserExportServiceMockRecorder) Get(ctx, uid, taskId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockUserExportService)(nil).Get), ctx, uid, taskId)
+}
+
+// Start mocks base method.
+func (m *MockUserExportService) Start(ctx context.Context, uid int64) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Start", ctx, uid)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Start indicates an expected call of Start.
+func (mr *MockUserExportServiceMockRecorder) Start(ctx, uid interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Start", reflect.TypeOf((*MockUserExportService)(nil).Start), ctx, uid)
+}
+
+// MockUserDataExporter is a mock of UserDataExporter interface.
+type MockUserDataExporter struct {
+	ctrl     *gomock.Controller
+	recorder *MockUserDataExporterMockRecorder
+}
+
+// MockUserDataExporterMockRecorder is the mock recorder for MockUserDataExporter.
+type MockUserDataExporterMockRecorder struct {
+	mock *MockUserDataExporter
+}
+
+// NewMockUserDataExporter creates a new mock instance.
+func NewMockUserDataExporter(ctrl *gomock.Controller) *MockUserDataExporter {
+	mock := &MockUserDataExporter{ctrl: ctrl}
+	mock.recorder = &MockUserDataExporterMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockUserDataExporter) EXPECT() *MockUserDataExporterMockRecorder {
+	return m.recorder
+}
+
+// ExportUserData mocks base method.
+func (m *MockUserDataExporter) ExportUserData(ctx context.Context, uid int64) (io.Reader, string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExportUserData", ctx, uid)
+	ret0, _ := ret[0].(io.Reader)
+	ret1, _ := ret[1].(string)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// ExportUserData indicates an expected call of ExportUserData.
+func (mr *MockUserDataExporterMockRecorder) ExportUserData(ctx, uid interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExportUserData", reflect.TypeOf((*MockUserDataExporter)(nil).ExportUserData), ctx, uid)
+}
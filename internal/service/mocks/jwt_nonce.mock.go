@@ -0,0 +1,80 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: webook/internal/service/jwt_nonce.go
+
+// Package svcmocks is a generated GoMock package.
+package svcmocks
+
+import (
+	context "context"
+	reflect "reflect"
+	time "time"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockJWTNonceService is a mock of JWTNonceService interface.
+type MockJWTNonceService struct {
+	ctrl     *gomock.Controller
+	recorder *MockJWTNonceServiceMockRecorder
+}
+
+// MockJWTNonceServiceMockRecorder is the mock recorder for MockJWTNonceService.
+type MockJWTNonceServiceMockRecorder struct {
+	mock *MockJWTNonceService
+}
+
+// NewMockJWTNonceService creates a new mock instance.
+func NewMockJWTNonceService(ctrl *gomock.Controller) *MockJWTNonceService {
+	mock := &MockJWTNonceService{ctrl: ctrl}
+	mock.recorder = &MockJWTNonceServiceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockJWTNonceService) EXPECT() *MockJWTNonceServiceMockRecorder {
+	return m.recorder
+}
+
+// Check mocks base method.
+func (m *MockJWTNonceService) Check(ctx context.Context, uid int64, nonce string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Check", ctx, uid, nonce)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Check indicates an expected call of Check.
+func (mr *MockJWTNonceServiceMockRecorder) Check(ctx, uid, nonce interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Check", reflect.TypeOf((*MockJWTNonceService)(nil).Check), ctx, uid, nonce)
+}
+
+// Issue mocks base method.
+func (m *MockJWTNonceService) Issue(ctx context.Context, uid int64, expiresAt time.Time) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Issue", ctx, uid, expiresAt)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Issue indicates an expected call of Issue.
+func (mr *MockJWTNonceServiceMockRecorder) Issue(ctx, uid, expiresAt interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Issue", reflect.TypeOf((*MockJWTNonceService)(nil).Issue), ctx, uid, expiresAt)
+}
+
+// Revoke mocks base method.
+func (m *MockJWTNonceService) Revoke(ctx context.Context, uid int64, nonce string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Revoke", ctx, uid, nonce)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Revoke indicates an expected call of Revoke.
+func (mr *MockJWTNonceServiceMockRecorder) Revoke(ctx, uid, nonce interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Revoke", reflect.TypeOf((*MockJWTNonceService)(nil).Revoke), ctx, uid, nonce)
+}
@@ -0,0 +1,96 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: webook/internal/service/remember_me.go
+
+// Package svcmocks is a generated GoMock package.
+package svcmocks
+
+import (
+	context "context"
+	reflect "reflect"
+	domain "webook/internal/domain"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockRememberMeService is a mock of RememberMeService interface.
+type MockRememberMeService struct {
+	ctrl     *gomock.Controller
+	recorder *MockRememberMeServiceMockRecorder
+}
+
+// MockRememberMeServiceMockRecorder is the mock recorder for MockRememberMeService.
+type MockRememberMeServiceMockRecorder struct {
+	mock *MockRememberMeService
+}
+
+// NewMockRememberMeService creates a new mock instance.
+func NewMockRememberMeService(ctrl *gomock.Controller) *MockRememberMeService {
+	mock := &MockRememberMeService{ctrl: ctrl}
+	mock.recorder = &MockRememberMeServiceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockRememberMeService) EXPECT() *MockRememberMeServiceMockRecorder {
+	return m.recorder
+}
+
+// Issue mocks base method.
+func (m *MockRememberMeService) Issue(ctx context.Context, uid int64) (domain.RememberMeToken, string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Issue", ctx, uid)
+	ret0, _ := ret[0].(domain.RememberMeToken)
+	ret1, _ := ret[1].(string)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// Issue indicates an expected call of Issue.
+func (mr *MockRememberMeServiceMockRecorder) Issue(ctx, uid interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Issue", reflect.TypeOf((*MockRememberMeService)(nil).Issue), ctx, uid)
+}
+
+// Refresh mocks base method.
+func (m *MockRememberMeService) Refresh(ctx context.Context, series, token string) (domain.RememberMeToken, string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Refresh", ctx, series, token)
+	ret0, _ := ret[0].(domain.RememberMeToken)
+	ret1, _ := ret[1].(string)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// Refresh indicates an expected call of Refresh.
+func (mr *MockRememberMeServiceMockRecorder) Refresh(ctx, series, token interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Refresh", reflect.TypeOf((*MockRememberMeService)(nil).Refresh), ctx, series, token)
+}
+
+// Revoke mocks base method.
+func (m *MockRememberMeService) Revoke(ctx context.Context, series string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Revoke", ctx, series)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Revoke indicates an expected call of Revoke.
+func (mr *MockRememberMeServiceMockRecorder) Revoke(ctx, series interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Revoke", reflect.TypeOf((*MockRememberMeService)(nil).Revoke), ctx, series)
+}
+
+// RevokeAll mocks base method.
+func (m *MockRememberMeService) RevokeAll(ctx context.Context, uid int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeAll", ctx, uid)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeAll indicates an expected call of RevokeAll.
+func (mr *MockRememberMeServiceMockRecorder) RevokeAll(ctx, uid interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeAll", reflect.TypeOf((*MockRememberMeService)(nil).RevokeAll), ctx, uid)
+}
@@ -0,0 +1,105 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: webook/internal/service/user_stats.go
+
+// Package svcmocks is a generated GoMock package.
+package svcmocks
+
+import (
+	context "context"
+	reflect "reflect"
+	time "time"
+	domain "webook/internal/domain"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockUserStatsRepository is a mock of UserStatsRepository interface.
+type MockUserStatsRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockUserStatsRepositoryMockRecorder
+}
+
+// MockUserStatsRepositoryMockRecorder is the mock recorder for MockUserStatsRepository.
+type MockUserStatsRepositoryMockRecorder struct {
+	mock *MockUserStatsRepository
+}
+
+// NewMockUserStatsRepository creates a new mock instance.
+func NewMockUserStatsRepository(ctrl *gomock.Controller) *MockUserStatsRepository {
+	mock := &MockUserStatsRepository{ctrl: ctrl}
+	mock.recorder = &MockUserStatsRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockUserStatsRepository) EXPECT() *MockUserStatsRepositoryMockRecorder {
+	return m.recorder
+}
+
+// CountByStatus mocks base method.
+func (m *MockUserStatsRepository) CountByStatus(ctx context.Context) (map[string]int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CountByStatus", ctx)
+	ret0, _ := ret[0].(map[string]int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CountByStatus indicates an expected call of CountByStatus.
+func (mr *MockUserStatsRepositoryMockRecorder) CountByStatus(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CountByStatus", reflect.TypeOf((*MockUserStatsRepository)(nil).CountByStatus), ctx)
+}
+
+// CountRegistrationsInPeriod mocks base method.
+func (m *MockUserStatsRepository) CountRegistrationsInPeriod(ctx context.Context, start, end time.Time) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CountRegistrationsInPeriod", ctx, start, end)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CountRegistrationsInPeriod indicates an expected call of CountRegistrationsInPeriod.
+func (mr *MockUserStatsRepositoryMockRecorder) CountRegistrationsInPeriod(ctx, start, end interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CountRegistrationsInPeriod", reflect.TypeOf((*MockUserStatsRepository)(nil).CountRegistrationsInPeriod), ctx, start, end)
+}
+
+// MockUserStatsService is a mock of UserStatsService interface.
+type MockUserStatsService struct {
+	ctrl     *gomock.Controller
+	recorder *MockUserStatsServiceMockRecorder
+}
+
+// MockUserStatsServiceMockRecorder is the mock recorder for MockUserStatsService.
+type MockUserStatsServiceMockRecorder struct {
+	mock *MockUserStatsService
+}
+
+// NewMockUserStatsService creates a new mock instance.
+func NewMockUserStatsService(ctrl *gomock.Controller) *MockUserStatsService {
+	mock := &MockUserStatsService{ctrl: ctrl}
+	mock.recorder = &MockUserStatsServiceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockUserStatsService) EXPECT() *MockUserStatsServiceMockRecorder {
+	return m.recorder
+}
+
+// UserStats mocks base method.
+func (m *MockUserStatsService) UserStats(ctx context.Context) (domain.UserStats, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UserStats", ctx)
+	ret0, _ := ret[0].(domain.UserStats)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UserStats indicates an expected call of UserStats.
+func (mr *MockUserStatsServiceMockRecorder) UserStats(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UserStats", reflect.TypeOf((*MockUserStatsService)(nil).UserStats), ctx)
+}
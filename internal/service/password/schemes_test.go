@@ -0,0 +1,109 @@
+package password
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+	"testing"
+)
+
+// 测试里面用小一点的参数，不然太慢
+var testArgon2Params = Argon2Params{Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+func TestHashers(t *testing.T) {
+	testCases := []struct {
+		name   string
+		hasher PasswordHasher
+		other  PasswordHasher
+	}{
+		{
+			name:   "bcrypt",
+			hasher: NewBcryptHasher(bcrypt.MinCost),
+			other:  NewArgon2Hasher(testArgon2Params),
+		},
+		{
+			name:   "argon2id",
+			hasher: NewArgon2Hasher(testArgon2Params),
+			other:  NewBcryptHasher(bcrypt.MinCost),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			hash, err := tc.hasher.Hash("hello#world123")
+			require.NoError(t, err)
+			assert.True(t, tc.hasher.Recognize(hash))
+			assert.False(t, tc.other.Recognize(hash))
+			assert.False(t, tc.hasher.NeedsRehash(hash))
+			assert.NoError(t, tc.hasher.Compare(hash, "hello#world123"))
+			assert.Equal(t, ErrMismatchedPassword, tc.hasher.Compare(hash, "hello#world456"))
+
+			// 盐每次都不一样
+			again, err := tc.hasher.Hash("hello#world123")
+			require.NoError(t, err)
+			assert.NotEqual(t, hash, again)
+		})
+	}
+}
+
+func TestArgon2Hasher_Params(t *testing.T) {
+	old := NewArgon2Hasher(testArgon2Params)
+	hash, err := old.Hash("hello#world123")
+	require.NoError(t, err)
+	assert.Regexp(t, `^\$argon2id\$v=19\$m=64,t=1,p=1\$[^$]+\$[^$]+$`, hash)
+
+	params := testArgon2Params
+	params.Iterations = 2
+	h := NewArgon2Hasher(params)
+	// 参数改了，以前的密码还是能验证，但是要重新算
+	assert.NoError(t, h.Compare(hash, "hello#world123"))
+	assert.True(t, h.NeedsRehash(hash))
+
+	for _, bad := range []string{"", "$argon2id$", "$argon2id$v=19$m=64,t=1,p=1$c2FsdA",
+		"$argon2id$v=18$m=64,t=1,p=1$c2FsdA$a2V5", "$argon2id$v=19$m=x,t=1,p=1$c2FsdA$a2V5",
+		"$argon2id$v=19$m=64,t=1,p=1$!!$a2V5"} {
+		assert.Equal(t, ErrUnknownScheme, h.Compare(bad, "hello#world123"), bad)
+	}
+}
+
+func TestSchemes_Compare(t *testing.T) {
+	bc := NewBcryptHasher(bcrypt.MinCost)
+	ar := NewArgon2Hasher(testArgon2Params)
+	s := NewSchemes(ar, bc)
+	bcryptHash, err := bc.Hash("hello#world123")
+	require.NoError(t, err)
+	argonHash, err := s.Hash("hello#world123")
+	require.NoError(t, err)
+	assert.True(t, ar.Recognize(argonHash))
+	// bcrypt 的 cost 变了也要重新算
+	oldCostHash, err := NewBcryptHasher(bcrypt.MinCost + 1).Hash("hello#world123")
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name     string
+		schemes  *Schemes
+		hash     string
+		password string
+
+		wantRehash bool
+		wantErr    error
+	}{
+		{name: "老算法", schemes: s, hash: bcryptHash, password: "hello#world123", wantRehash: true},
+		{name: "新算法", schemes: s, hash: argonHash, password: "hello#world123"},
+		{name: "老算法密码不对", schemes: s, hash: bcryptHash, password: "hello#world456",
+			wantErr: ErrMismatchedPassword},
+		{name: "不认识", schemes: s, hash: "md5:abc", password: "hello#world123",
+			wantErr: ErrUnknownScheme},
+		{name: "没有密码", schemes: s, hash: "", password: "", wantErr: ErrUnknownScheme},
+		{name: "cost 变了", schemes: NewSchemes(bc), hash: oldCostHash, password: "hello#world123",
+			wantRehash: true},
+		{name: "没有配置老算法", schemes: NewSchemes(ar), hash: bcryptHash, password: "hello#world123",
+			wantErr: ErrUnknownScheme},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rehash, err := tc.schemes.Compare(tc.hash, tc.password)
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.wantRehash, rehash)
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package password
+
+import "errors"
+
+var (
+	// ErrMismatchedPassword 密码不对
+	ErrMismatchedPassword = errors.New("密码不对")
+	// ErrUnknownScheme 认不出来是哪种算法，比如说手机号注册的没有密码
+	ErrUnknownScheme = errors.New("不认识的密码哈希")
+)
+
+// PasswordHasher 一种密码哈希算法。算法和参数都编码在 Hash 返回的字符串里面，
+// 看前缀就知道是哪种，所以数据库里面不需要单独存
+type PasswordHasher interface {
+	// Hash 每次都会用新的盐
+	Hash(password string) (string, error)
+	// Compare 不对返回 ErrMismatchedPassword
+	Compare(hash, password string) error
+	// Recognize 是不是这种算法算出来的
+	Recognize(hash string) bool
+	// NeedsRehash 是这种算法算出来的，但是参数跟现在的不一样
+	NeedsRehash(hash string) bool
+}
+
+// Schemes 新的密码用 current 算，老的密码哪种算法算的就用哪种比较。
+// 登录的时候发现不是 current 算的，或者参数过时了，就重新算一遍存起来，
+// 这样不用让用户重置密码也能慢慢迁移过去
+type Schemes struct {
+	current PasswordHasher
+	all     []PasswordHasher
+}
+
+// NewSchemes legacy 是以前用过的算法，只用来比较
+func NewSchemes(current PasswordHasher, legacy ...PasswordHasher) *Schemes {
+	return &Schemes{
+		current: current,
+		all:     append([]PasswordHasher{current}, legacy...),
+	}
+}
+
+func (s *Schemes) Hash(password string) (string, error) {
+	return s.current.Hash(password)
+}
+
+// Compare 密码对了的时候 needRehash 代表要不要用 current 重新算一遍
+func (s *Schemes) Compare(hash, password string) (needRehash bool, err error) {
+	for _, h := range s.all {
+		if !h.Recognize(hash) {
+			continue
+		}
+		if err = h.Compare(hash, password); err != nil {
+			return false, err
+		}
+		return h != s.current || h.NeedsRehash(hash), nil
+	}
+	return false, ErrUnknownScheme
+}
@@ -0,0 +1,50 @@
+package service
+
+import (
+	"sort"
+	"webook/internal/domain"
+)
+
+// ProfileWeights 资料完整度里面每一项的权重，没有配置的项不参与计算
+type ProfileWeights map[domain.ProfileItem]int
+
+// DefaultProfileWeights 加起来正好是 100，调整的时候不要求加起来是 100
+func DefaultProfileWeights() ProfileWeights {
+	return ProfileWeights{
+		domain.ProfileItemNickname: 20,
+		domain.ProfileItemBirthday: 15,
+		domain.ProfileItemBrief:    15,
+		domain.ProfileItemEmail:    25,
+		domain.ProfileItemPhone:    25,
+	}
+}
+
+// Score 已经填了的权重占总权重的百分比，向下取整，
+// 这样只有全部填完了才会是 100。一项都没有配置就当成是完整的
+func (w ProfileWeights) Score(u domain.User) domain.ProfileCompleteness {
+	total, filled := 0, 0
+	missing := make([]domain.MissingProfileItem, 0, len(w))
+	for item, weight := range w {
+		if weight <= 0 {
+			continue
+		}
+		total += weight
+		if u.HasProfileItem(item) {
+			filled += weight
+			continue
+		}
+		missing = append(missing, domain.MissingProfileItem{Item: item, Weight: weight})
+	}
+	// map 的顺序是随机的，排一下保证每次返回的都一样
+	sort.Slice(missing, func(i, j int) bool {
+		if missing[i].Weight != missing[j].Weight {
+			return missing[i].Weight > missing[j].Weight
+		}
+		return missing[i].Item < missing[j].Item
+	})
+	res := domain.ProfileCompleteness{Percent: 100, Missing: missing}
+	if total > 0 {
+		res.Percent = filled * 100 / total
+	}
+	return res
+}
@@ -0,0 +1,133 @@
+package service
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+	"webook/internal/domain"
+)
+
+func TestProfileWeights_Score(t *testing.T) {
+	testCases := []struct {
+		name    string
+		weights ProfileWeights
+		user    domain.User
+
+		want domain.ProfileCompleteness
+	}{
+		{
+			name:    "刚注册",
+			weights: DefaultProfileWeights(),
+			user:    domain.User{Email: "123@qq.com"},
+			want: domain.ProfileCompleteness{
+				Percent: 25,
+				Missing: []domain.MissingProfileItem{
+					{Item: domain.ProfileItemPhone, Weight: 25},
+					{Item: domain.ProfileItemNickname, Weight: 20},
+					{Item: domain.ProfileItemBirthday, Weight: 15},
+					{Item: domain.ProfileItemBrief, Weight: 15},
+				},
+			},
+		},
+		{
+			name:    "全部填了",
+			weights: DefaultProfileWeights(),
+			user: domain.User{
+				Email:    "123@qq.com",
+				Phone:    "15212345678",
+				Nickname: "Tom",
+				Birthday: "2000-01-01",
+				Brief:    "hello",
+			},
+			want: domain.ProfileCompleteness{
+				Percent: 100,
+				Missing: []domain.MissingProfileItem{},
+			},
+		},
+		{
+			name: "向下取整",
+			weights: ProfileWeights{
+				domain.ProfileItemNickname: 1,
+				domain.ProfileItemBrief:    1,
+				domain.ProfileItemBirthday: 1,
+			},
+			user: domain.User{Nickname: "Tom"},
+			want: domain.ProfileCompleteness{
+				Percent: 33,
+				Missing: []domain.MissingProfileItem{
+					{Item: domain.ProfileItemBirthday, Weight: 1},
+					{Item: domain.ProfileItemBrief, Weight: 1},
+				},
+			},
+		},
+		{
+			name: "权重为 0 的不算",
+			weights: ProfileWeights{
+				domain.ProfileItemNickname: 10,
+				domain.ProfileItemPhone:    0,
+			},
+			user: domain.User{Nickname: "Tom"},
+			want: domain.ProfileCompleteness{
+				Percent: 100,
+				Missing: []domain.MissingProfileItem{},
+			},
+		},
+		{
+			// 绑了邮箱但是没有验证，只拿到 email 的分
+			name: "邮箱没有验证",
+			weights: ProfileWeights{
+				domain.ProfileItemNickname:      30,
+				domain.ProfileItemEmail:         20,
+				domain.ProfileItemEmailVerified: 50,
+			},
+			user: domain.User{Nickname: "Tom", Email: "tom@qq.com"},
+			want: domain.ProfileCompleteness{
+				Percent: 50,
+				Missing: []domain.MissingProfileItem{
+					{Item: domain.ProfileItemEmailVerified, Weight: 50},
+				},
+			},
+		},
+		{
+			name: "邮箱验证过了",
+			weights: ProfileWeights{
+				domain.ProfileItemNickname:      30,
+				domain.ProfileItemEmail:         20,
+				domain.ProfileItemEmailVerified: 50,
+			},
+			user: domain.User{Nickname: "Tom", Email: "tom@qq.com", EmailVerifiedAt: time.UnixMilli(1700000000000)},
+			want: domain.ProfileCompleteness{
+				Percent: 100,
+				Missing: []domain.MissingProfileItem{},
+			},
+		},
+		{
+			// 权重不要求加起来是 100：7 里面填了 3 和 2，5*100/7 向下取整
+			name: "权重加起来不是 100",
+			weights: ProfileWeights{
+				domain.ProfileItemBirthday:      3,
+				domain.ProfileItemBrief:         2,
+				domain.ProfileItemEmailVerified: 2,
+			},
+			user: domain.User{Birthday: "1992-01-01", Brief: "hello"},
+			want: domain.ProfileCompleteness{
+				Percent: 71,
+				Missing: []domain.MissingProfileItem{
+					{Item: domain.ProfileItemEmailVerified, Weight: 2},
+				},
+			},
+		},
+		{
+			name: "没有配置",
+			want: domain.ProfileCompleteness{
+				Percent: 100,
+				Missing: []domain.MissingProfileItem{},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, tc.weights.Score(tc.user))
+		})
+	}
+}
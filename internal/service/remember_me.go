@@ -0,0 +1,145 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"log"
+	"time"
+	"webook/internal/domain"
+	"webook/internal/repository"
+)
+
+// rememberMeReuseGrace 两个请求同时带着同一个 token 来刷新，后到的那个会发现 token 对不上。
+// 它带的是刚刚被换掉的那个 token，并且在这个时间之内，就不当成被偷了
+const rememberMeReuseGrace = time.Second * 30
+
+var (
+	ErrRememberMeInvalid = errors.New("记住我的凭证无效或者已经过期")
+	// ErrRememberMeTheft series 是对的但是 token 是旧的，说明 cookie 被人偷了，
+	// 而且有一方已经用过了。这时候这个用户所有的记住我都会失效
+	ErrRememberMeTheft = errors.New("记住我的凭证被盗用")
+)
+
+type RememberMeService interface {
+	// Issue 登录的时候勾选了记住我，生成一个新的 series。返回的 string 是原始的 token
+	Issue(ctx context.Context, uid int64) (domain.RememberMeToken, string, error)
+	// Refresh 用 cookie 里面的 series 和 token 换一个新的 token，series 不变
+	Refresh(ctx context.Context, series string, token string) (domain.RememberMeToken, string, error)
+	// Revoke 退出登录的时候删掉这个 series
+	Revoke(ctx context.Context, series string) error
+	// RevokeAll 退出所有设备的时候，这个用户所有的 series 都删掉
+	RevokeAll(ctx context.Context, uid int64) error
+}
+
+type rememberMeService struct {
+	repo repository.RememberMeRepository
+	// 多久不用就失效，每次刷新都会重新算
+	ttl time.Duration
+}
+
+func NewRememberMeService(repo repository.RememberMeRepository, ttl time.Duration) RememberMeService {
+	return &rememberMeService{
+		repo: repo,
+		ttl:  ttl,
+	}
+}
+
+func (svc *rememberMeService) Issue(ctx context.Context, uid int64) (domain.RememberMeToken, string, error) {
+	series, err := randomHex(16)
+	if err != nil {
+		return domain.RememberMeToken{}, "", err
+	}
+	token, err := randomHex(32)
+	if err != nil {
+		return domain.RememberMeToken{}, "", err
+	}
+	now := time.Now()
+	t := domain.RememberMeToken{
+		Series:    series,
+		TokenHash: hashRememberMeToken(token),
+		Uid:       uid,
+		ExpiresAt: now.Add(svc.ttl),
+		RotatedAt: now,
+	}
+	if err = svc.repo.Create(ctx, t); err != nil {
+		return domain.RememberMeToken{}, "", err
+	}
+	return t, token, nil
+}
+
+func (svc *rememberMeService) Refresh(ctx context.Context,
+	series string, token string) (domain.RememberMeToken, string, error) {
+	t, err := svc.repo.FindBySeries(ctx, series)
+	if err == repository.ErrRememberMeNotFound {
+		return domain.RememberMeToken{}, "", ErrRememberMeInvalid
+	}
+	if err != nil {
+		return domain.RememberMeToken{}, "", err
+	}
+	now := time.Now()
+	if t.Expired(now) {
+		// 删不掉也无所谓，反正已经过期了
+		_ = svc.repo.DeleteBySeries(ctx, series)
+		return domain.RememberMeToken{}, "", ErrRememberMeInvalid
+	}
+	hash := hashRememberMeToken(token)
+	if !hashEqual(hash, t.TokenHash) {
+		if hashEqual(hash, t.PrevTokenHash) && now.Sub(t.RotatedAt) < rememberMeReuseGrace {
+			// 并发刷新，另外一个请求已经换过了
+			return domain.RememberMeToken{}, "", ErrRememberMeInvalid
+		}
+		// 你要监控这个
+		log.Printf("[WARN] 记住我的凭证被盗用 uid=%d series=%s", t.Uid, series)
+		if err = svc.repo.DeleteByUid(ctx, t.Uid); err != nil {
+			return domain.RememberMeToken{}, "", err
+		}
+		return domain.RememberMeToken{}, "", ErrRememberMeTheft
+	}
+	newToken, err := randomHex(32)
+	if err != nil {
+		return domain.RememberMeToken{}, "", err
+	}
+	newHash := hashRememberMeToken(newToken)
+	expiresAt := now.Add(svc.ttl)
+	err = svc.repo.Rotate(ctx, series, t.TokenHash, newHash, expiresAt)
+	if err == repository.ErrRememberMeRotated {
+		return domain.RememberMeToken{}, "", ErrRememberMeInvalid
+	}
+	if err != nil {
+		return domain.RememberMeToken{}, "", err
+	}
+	t.PrevTokenHash = t.TokenHash
+	t.TokenHash = newHash
+	t.ExpiresAt = expiresAt
+	t.RotatedAt = now
+	return t, newToken, nil
+}
+
+func (svc *rememberMeService) Revoke(ctx context.Context, series string) error {
+	return svc.repo.DeleteBySeries(ctx, series)
+}
+
+func (svc *rememberMeService) RevokeAll(ctx context.Context, uid int64) error {
+	return svc.repo.DeleteByUid(ctx, uid)
+}
+
+func randomHex(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+func hashRememberMeToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func hashEqual(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
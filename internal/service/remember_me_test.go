@@ -0,0 +1,174 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"testing"
+	"time"
+	"webook/internal/domain"
+	"webook/internal/repository"
+	repomocks "webook/internal/repository/mocks"
+)
+
+func TestRememberMeService_Issue(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	repo := repomocks.NewMockRememberMeRepository(ctrl)
+	var saved domain.RememberMeToken
+	repo.EXPECT().Create(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(ctx context.Context, t domain.RememberMeToken) error {
+			saved = t
+			return nil
+		})
+	svc := NewRememberMeService(repo, time.Hour)
+	tk, token, err := svc.Issue(context.Background(), 123)
+	assert.NoError(t, err)
+	assert.Equal(t, saved, tk)
+	assert.Equal(t, int64(123), tk.Uid)
+	assert.Len(t, tk.Series, 32)
+	// 数据库里面存的是哈希，不是原始的 token
+	assert.Equal(t, hashRememberMeToken(token), tk.TokenHash)
+	assert.NotEqual(t, token, tk.TokenHash)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), tk.ExpiresAt, time.Second)
+}
+
+func TestRememberMeService_Refresh(t *testing.T) {
+	const (
+		series   = "series"
+		token    = "token"
+		oldToken = "old-token"
+	)
+	valid := domain.RememberMeToken{
+		Series:        series,
+		TokenHash:     hashRememberMeToken(token),
+		PrevTokenHash: hashRememberMeToken(oldToken),
+		Uid:           123,
+		ExpiresAt:     time.Now().Add(time.Hour),
+		RotatedAt:     time.Now().Add(-time.Hour),
+	}
+	testCases := []struct {
+		name string
+		mock func(ctrl *gomock.Controller) repository.RememberMeRepository
+
+		token string
+
+		wantUid int64
+		wantErr error
+	}{
+		{
+			name: "刷新成功",
+			mock: func(ctrl *gomock.Controller) repository.RememberMeRepository {
+				repo := repomocks.NewMockRememberMeRepository(ctrl)
+				repo.EXPECT().FindBySeries(gomock.Any(), series).Return(valid, nil)
+				repo.EXPECT().Rotate(gomock.Any(), series, valid.TokenHash, gomock.Any(), gomock.Any()).
+					DoAndReturn(func(ctx context.Context, series, oldHash, newHash string, expiresAt time.Time) error {
+						assert.NotEqual(t, oldHash, newHash)
+						return nil
+					})
+				return repo
+			},
+			token:   token,
+			wantUid: 123,
+		},
+		{
+			name: "series 不存在",
+			mock: func(ctrl *gomock.Controller) repository.RememberMeRepository {
+				repo := repomocks.NewMockRememberMeRepository(ctrl)
+				repo.EXPECT().FindBySeries(gomock.Any(), series).
+					Return(domain.RememberMeToken{}, repository.ErrRememberMeNotFound)
+				return repo
+			},
+			token:   token,
+			wantErr: ErrRememberMeInvalid,
+		},
+		{
+			name: "已经过期",
+			mock: func(ctrl *gomock.Controller) repository.RememberMeRepository {
+				repo := repomocks.NewMockRememberMeRepository(ctrl)
+				expired := valid
+				expired.ExpiresAt = time.Now().Add(-time.Minute)
+				repo.EXPECT().FindBySeries(gomock.Any(), series).Return(expired, nil)
+				repo.EXPECT().DeleteBySeries(gomock.Any(), series).Return(nil)
+				return repo
+			},
+			token:   token,
+			wantErr: ErrRememberMeInvalid,
+		},
+		{
+			name: "旧的 token 被重放，所有的记住我都失效",
+			mock: func(ctrl *gomock.Controller) repository.RememberMeRepository {
+				repo := repomocks.NewMockRememberMeRepository(ctrl)
+				repo.EXPECT().FindBySeries(gomock.Any(), series).Return(valid, nil)
+				repo.EXPECT().DeleteByUid(gomock.Any(), int64(123)).Return(nil)
+				return repo
+			},
+			token:   oldToken,
+			wantErr: ErrRememberMeTheft,
+		},
+		{
+			name: "瞎编的 token 也当成被偷了",
+			mock: func(ctrl *gomock.Controller) repository.RememberMeRepository {
+				repo := repomocks.NewMockRememberMeRepository(ctrl)
+				repo.EXPECT().FindBySeries(gomock.Any(), series).Return(valid, nil)
+				repo.EXPECT().DeleteByUid(gomock.Any(), int64(123)).Return(nil)
+				return repo
+			},
+			token:   "abc",
+			wantErr: ErrRememberMeTheft,
+		},
+		{
+			name: "刚刚被并发刷新过，不算被偷",
+			mock: func(ctrl *gomock.Controller) repository.RememberMeRepository {
+				repo := repomocks.NewMockRememberMeRepository(ctrl)
+				justRotated := valid
+				justRotated.RotatedAt = time.Now().Add(-time.Second)
+				repo.EXPECT().FindBySeries(gomock.Any(), series).Return(justRotated, nil)
+				return repo
+			},
+			token:   oldToken,
+			wantErr: ErrRememberMeInvalid,
+		},
+		{
+			name: "轮换的时候被别的请求抢先了",
+			mock: func(ctrl *gomock.Controller) repository.RememberMeRepository {
+				repo := repomocks.NewMockRememberMeRepository(ctrl)
+				repo.EXPECT().FindBySeries(gomock.Any(), series).Return(valid, nil)
+				repo.EXPECT().Rotate(gomock.Any(), series, valid.TokenHash, gomock.Any(), gomock.Any()).
+					Return(repository.ErrRememberMeRotated)
+				return repo
+			},
+			token:   token,
+			wantErr: ErrRememberMeInvalid,
+		},
+		{
+			name: "数据库错误",
+			mock: func(ctrl *gomock.Controller) repository.RememberMeRepository {
+				repo := repomocks.NewMockRememberMeRepository(ctrl)
+				repo.EXPECT().FindBySeries(gomock.Any(), series).
+					Return(domain.RememberMeToken{}, errors.New("mock db 错误"))
+				return repo
+			},
+			token:   token,
+			wantErr: errors.New("mock db 错误"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			svc := NewRememberMeService(tc.mock(ctrl), time.Hour)
+			tk, newToken, err := svc.Refresh(context.Background(), series, tc.token)
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantUid, tk.Uid)
+			assert.Equal(t, series, tk.Series)
+			assert.NotEqual(t, tc.token, newToken)
+			assert.Equal(t, hashRememberMeToken(newToken), tk.TokenHash)
+			assert.Equal(t, valid.TokenHash, tk.PrevTokenHash)
+		})
+	}
+}
@@ -0,0 +1,205 @@
+package ac
+
+import (
+	_ "embed"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"unicode"
+	"webook/internal/service/sensitive"
+)
+
+//go:embed words.txt
+var defaultWords string
+
+// Filter 用 Aho-Corasick 自动机做多模式匹配，一次扫描就能找出所有命中的敏感词。
+// 自动机构建好之后就不会再改了，AddWords 会重新构建一个再整体替换，
+// 所以 Match 和 Mask 不需要加锁
+type Filter struct {
+	// 保护 words，让重建串行执行
+	mu    sync.Mutex
+	words []string
+	m     atomic.Pointer[automaton]
+}
+
+var _ sensitive.Filter = (*Filter)(nil)
+
+// NewFilter 用内置的词库再加上 words 初始化
+func NewFilter(words ...string) *Filter {
+	f := &Filter{}
+	f.AddWords(append(DefaultWords(), words...)...)
+	return f
+}
+
+// DefaultWords 内置的词库
+func DefaultWords() []string {
+	var res []string
+	for _, line := range strings.Split(defaultWords, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		res = append(res, line)
+	}
+	return res
+}
+
+func (f *Filter) AddWords(words ...string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.words = append(f.words, words...)
+	f.m.Store(newAutomaton(f.words))
+}
+
+func (f *Filter) Match(text string) []string {
+	runes := []rune(text)
+	var res []string
+	seen := make(map[string]struct{})
+	f.m.Load().scan(runes, func(start, end int) {
+		w := string(runes[start:end])
+		if _, ok := seen[w]; ok {
+			return
+		}
+		seen[w] = struct{}{}
+		res = append(res, w)
+	})
+	return res
+}
+
+func (f *Filter) Mask(text string) string {
+	runes := []rune(text)
+	masked := false
+	f.m.Load().scan(runes, func(start, end int) {
+		masked = true
+		for i := start; i < end; i++ {
+			runes[i] = '*'
+		}
+	})
+	if !masked {
+		return text
+	}
+	return string(runes)
+}
+
+type node struct {
+	children map[rune]int32
+	fail     int32
+	// 以这个节点结尾的最长的敏感词的长度（按 rune 算），0 就是没有敏感词在这里结尾。
+	// 包括了 fail 链上的，所以扫描的时候不用再沿着 fail 链找
+	outLen int32
+}
+
+type automaton struct {
+	nodes []node
+}
+
+func newAutomaton(words []string) *automaton {
+	a := &automaton{nodes: []node{{children: map[rune]int32{}}}}
+	for _, w := range words {
+		a.insert(w)
+	}
+	a.buildFail()
+	return a
+}
+
+func (a *automaton) insert(word string) {
+	var cur int32
+	var l int32
+	for _, r := range word {
+		r = normalize(r)
+		next, ok := a.nodes[cur].children[r]
+		if !ok {
+			next = int32(len(a.nodes))
+			a.nodes = append(a.nodes, node{children: map[rune]int32{}})
+			a.nodes[cur].children[r] = next
+		}
+		cur = next
+		l++
+	}
+	if l > a.nodes[cur].outLen {
+		a.nodes[cur].outLen = l
+	}
+}
+
+// buildFail 按层序遍历算 fail 指针
+func (a *automaton) buildFail() {
+	queue := make([]int32, 0, len(a.nodes))
+	for _, child := range a.nodes[0].children {
+		queue = append(queue, child)
+	}
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+		for r, child := range a.nodes[cur].children {
+			f := a.nodes[cur].fail
+			for {
+				if next, ok := a.nodes[f].children[r]; ok && next != child {
+					a.nodes[child].fail = next
+					break
+				}
+				if f == 0 {
+					break
+				}
+				f = a.nodes[f].fail
+			}
+			if l := a.nodes[a.nodes[child].fail].outLen; l > a.nodes[child].outLen {
+				a.nodes[child].outLen = l
+			}
+			queue = append(queue, child)
+		}
+	}
+}
+
+// scan 每命中一次就回调一次 fn，[start, end) 是命中的部分在 runes 里面的下标
+func (a *automaton) scan(runes []rune, fn func(start, end int)) {
+	var cur int32
+	for i, r := range runes {
+		r = normalize(r)
+		for {
+			if next, ok := a.nodes[cur].children[r]; ok {
+				cur = next
+				break
+			}
+			if cur == 0 {
+				break
+			}
+			cur = a.nodes[cur].fail
+		}
+		if l := int(a.nodes[cur].outLen); l > 0 {
+			fn(i+1-l, i+1)
+		}
+	}
+}
+
+// homoglyphs 常见的用数字、符号冒充字母的写法。
+// 1 和 l 长得太像了，都当成 i
+var homoglyphs = map[rune]rune{
+	'0': 'o',
+	'1': 'i',
+	'l': 'i',
+	'!': 'i',
+	'3': 'e',
+	'4': 'a',
+	'@': 'a',
+	'5': 's',
+	'$': 's',
+	'7': 't',
+	'8': 'b',
+	'9': 'g',
+}
+
+// normalize 全角转半角，转小写，再处理冒充字母的数字。
+// 一个 rune 只会变成一个 rune，这样下标和原文是对得上的
+func normalize(r rune) rune {
+	switch {
+	case r == '　':
+		r = ' '
+	case r >= '！' && r <= '～':
+		r -= 0xfee0
+	}
+	r = unicode.ToLower(r)
+	if h, ok := homoglyphs[r]; ok {
+		return h
+	}
+	return r
+}
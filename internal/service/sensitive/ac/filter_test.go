@@ -0,0 +1,121 @@
+package ac
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestFilter(t *testing.T) {
+	testCases := []struct {
+		name  string
+		words []string
+		text  string
+
+		wantMatch []string
+		wantMask  string
+	}{
+		{
+			name:     "没有命中",
+			words:    []string{"坏人"},
+			text:     "我是好人",
+			wantMask: "我是好人",
+		},
+		{
+			name:      "命中内置词库",
+			text:      "我是管理员",
+			wantMatch: []string{"管理员"},
+			wantMask:  "我是***",
+		},
+		{
+			name:      "命中多个",
+			words:     []string{"坏人", "坏蛋"},
+			text:      "坏人和坏蛋",
+			wantMatch: []string{"坏人", "坏蛋"},
+			wantMask:  "**和**",
+		},
+		{
+			name:      "重叠的词",
+			words:     []string{"abc", "bcd"},
+			text:      "xabcdx",
+			wantMatch: []string{"abc", "bcd"},
+			wantMask:  "x****x",
+		},
+		{
+			name:      "一个词是另一个词的后缀",
+			words:     []string{"she", "he"},
+			text:      "ushers",
+			wantMatch: []string{"she"},
+			wantMask:  "u***rs",
+		},
+		{
+			name:      "大小写",
+			text:      "FuCk you",
+			wantMatch: []string{"FuCk"},
+			wantMask:  "**** you",
+		},
+		{
+			name:      "全角",
+			text:      "ｆｕｃｋ you",
+			wantMatch: []string{"ｆｕｃｋ"},
+			wantMask:  "**** you",
+		},
+		{
+			name:      "数字冒充字母",
+			text:      "5h1t happens",
+			wantMatch: []string{"5h1t"},
+			wantMask:  "**** happens",
+		},
+		{
+			name:      "全角数字冒充字母",
+			text:      "ｓｈ１ｔ",
+			wantMatch: []string{"ｓｈ１ｔ"},
+			wantMask:  "****",
+		},
+		{
+			name:      "词库里面的词也会归一化",
+			words:     []string{"ＢＡＤ"},
+			text:      "so b4d",
+			wantMatch: []string{"b4d"},
+			wantMask:  "so ***",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			f := NewFilter(tc.words...)
+			assert.Equal(t, tc.wantMatch, f.Match(tc.text))
+			assert.Equal(t, tc.wantMask, f.Mask(tc.text))
+		})
+	}
+}
+
+func TestFilter_AddWords(t *testing.T) {
+	f := NewFilter()
+	assert.Empty(t, f.Match("新词"))
+	f.AddWords("新词")
+	assert.Equal(t, []string{"新词"}, f.Match("这是新词"))
+	// 原来的词还在
+	assert.Equal(t, []string{"管理员"}, f.Match("管理员"))
+}
+
+func BenchmarkFilter_Match(b *testing.B) {
+	f := NewFilter()
+	// 昵称和简介最长 255 个字符，最后才命中
+	text := strings.Repeat("这是一段正常的个人简介ａｂｃ", 20) + "管理员"
+	text = string([]rune(text)[len([]rune(text))-255:])
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		f.Match(text)
+	}
+}
+
+func BenchmarkFilter_Mask(b *testing.B) {
+	f := NewFilter()
+	text := strings.Repeat("fuck 这是一段正常的简介", 20)
+	text = string([]rune(text)[:255])
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		f.Mask(text)
+	}
+}
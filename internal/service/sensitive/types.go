@@ -0,0 +1,11 @@
+package sensitive
+
+// Filter 敏感词过滤。实现要能处理全角半角混用，还有用数字冒充字母这种简单的变形
+type Filter interface {
+	// Match 返回 text 里面命中的敏感词（原文中的片段），没有命中就是空的
+	Match(text string) []string
+	// Mask 把命中的部分替换成 *
+	Mask(text string) string
+	// AddWords 运行期间追加敏感词
+	AddWords(words ...string)
+}
@@ -0,0 +1,55 @@
+package service
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"time"
+	"webook/internal/repository/cache"
+)
+
+// SessionIdleTTL 会话多久没有请求就失效。JWT 只有一分钟，比这个短得多，
+// 所以只要还在用，会话就一直在；过期了要靠记住我或者重新登录建一个新的
+const SessionIdleTTL = time.Minute * 30
+
+// SessionService 每台设备一个会话，ssid 放在 JWT 里面。
+// 退出登录的时候删掉会话，这台设备手上还没过期的 JWT 也就跟着不能用了
+type SessionService interface {
+	// Create 登录成功的时候调用，返回新会话的 ssid
+	Create(ctx context.Context, uid int64, userAgent string) (string, error)
+	// Check 会话还在的话顺便续期
+	Check(ctx context.Context, uid int64, ssid string) (bool, error)
+	// Logout 只退出这台设备
+	Logout(ctx context.Context, uid int64, ssid string) error
+	// LogoutAll 退出所有设备
+	LogoutAll(ctx context.Context, uid int64) error
+}
+
+type sessionService struct {
+	cache cache.SessionCache
+}
+
+func NewSessionService(cache cache.SessionCache) SessionService {
+	return &sessionService{
+		cache: cache,
+	}
+}
+
+func (svc *sessionService) Create(ctx context.Context, uid int64, userAgent string) (string, error) {
+	ssid := uuid.New().String()
+	if err := svc.cache.Create(ctx, uid, ssid, userAgent, SessionIdleTTL); err != nil {
+		return "", err
+	}
+	return ssid, nil
+}
+
+func (svc *sessionService) Check(ctx context.Context, uid int64, ssid string) (bool, error) {
+	return svc.cache.Touch(ctx, uid, ssid, SessionIdleTTL)
+}
+
+func (svc *sessionService) Logout(ctx context.Context, uid int64, ssid string) error {
+	return svc.cache.Delete(ctx, uid, ssid)
+}
+
+func (svc *sessionService) LogoutAll(ctx context.Context, uid int64) error {
+	return svc.cache.DeleteAll(ctx, uid)
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/goccy/go-json"
 	"math/rand"
 	"time"
+	"webook/pkg/mask"
 )
 
 /**
@@ -57,8 +58,9 @@ func (s *Service) SendSms(ctx context.Context, signName, tplCode string, phone [
 		// 3. 发送短信
 		smsResponse, _ := s.client.SendSms(smsRequest)
 		if *smsResponse.Body.Code == "OK" {
-			fmt.Println(phoneSignle, string(bcode))
-			fmt.Printf("发送手机号: %s 的短信成功,验证码为【%s】\n", phoneSignle, code)
+			// 手机号不能明文打出来
+			fmt.Println(mask.Phone(phoneSignle), string(bcode))
+			fmt.Printf("发送手机号: %s 的短信成功,验证码为【%s】\n", mask.Phone(phoneSignle), code)
 		}
 		fmt.Println(errors.New(*smsResponse.Body.Message))
 	}
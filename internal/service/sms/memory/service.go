@@ -3,16 +3,78 @@ package memory
 import (
 	"context"
 	"fmt"
+	"log"
+	"sync"
+	"webook/pkg/mask"
 )
 
 type Service struct {
+	// dryRun 模式下不真的发，只打日志，staging 环境用
+	dryRun bool
+	// record 发过的都留下来，不会清掉，只有测试用
+	record bool
+	mu     sync.Mutex
+	msgs   []Message
+}
+
+// Message 一条本来要发出去的短信
+type Message struct {
+	Tpl     string
+	Args    []string
+	Numbers []string
+}
+
+// String 渲染成人看得懂的样子，手机号打码，日志里面不能有明文
+func (m Message) String() string {
+	numbers := make([]string, 0, len(m.Numbers))
+	for _, n := range m.Numbers {
+		numbers = append(numbers, mask.Phone(n))
+	}
+	return fmt.Sprintf("模板 %s，参数 %v，发送给 %v", m.Tpl, m.Args, numbers)
 }
 
 func NewService() *Service {
 	return &Service{}
 }
 
+func NewDryRunService() *Service {
+	return &Service{
+		dryRun: true,
+	}
+}
+
+// NewRecordingService 跟 dryRun 一样，另外把发过的短信都记下来，测试用 Messages 检查
+func NewRecordingService() *Service {
+	return &Service{
+		dryRun: true,
+		record: true,
+	}
+}
+
 func (s *Service) Send(ctx context.Context, tpl string, args []string, numbers ...string) error {
-	fmt.Println(args)
+	if !s.dryRun {
+		fmt.Println(args)
+		return nil
+	}
+	msg := Message{
+		Tpl:     tpl,
+		Args:    args,
+		Numbers: numbers,
+	}
+	if s.record {
+		s.mu.Lock()
+		s.msgs = append(s.msgs, msg)
+		s.mu.Unlock()
+	}
+	log.Printf("[INFO] sms dry-run: %s", msg)
 	return nil
 }
+
+// Messages 到目前为止记录下来的短信，只有 NewRecordingService 才会记录
+func (s *Service) Messages() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	res := make([]Message, len(s.msgs))
+	copy(res, s.msgs)
+	return res
+}
@@ -0,0 +1,70 @@
+package memory
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestService_DryRun(t *testing.T) {
+	testCases := []struct {
+		name string
+		// 依次发送的短信
+		sends   []Message
+		dryRun  bool
+		record  bool
+		wantMsg []Message
+	}{
+		{
+			name:   "测试的时候记录下来",
+			dryRun: true,
+			record: true,
+			sends: []Message{
+				{Tpl: "1877556", Args: []string{"123456"}, Numbers: []string{"15212345678"}},
+				{Tpl: "1877557", Args: []string{"654321", "5"}, Numbers: []string{"15212345679", "15212345670"}},
+			},
+			wantMsg: []Message{
+				{Tpl: "1877556", Args: []string{"123456"}, Numbers: []string{"15212345678"}},
+				{Tpl: "1877557", Args: []string{"654321", "5"}, Numbers: []string{"15212345679", "15212345670"}},
+			},
+		},
+		{
+			name:   "dry-run 只打日志不记录",
+			dryRun: true,
+			sends: []Message{
+				{Tpl: "1877556", Args: []string{"123456"}, Numbers: []string{"15212345678"}},
+			},
+			wantMsg: []Message{},
+		},
+		{
+			name: "不是 dry-run 不记录",
+			sends: []Message{
+				{Tpl: "1877556", Args: []string{"123456"}, Numbers: []string{"15212345678"}},
+			},
+			wantMsg: []Message{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			svc := NewService()
+			if tc.dryRun {
+				svc = NewDryRunService()
+			}
+			if tc.record {
+				svc = NewRecordingService()
+			}
+			for _, m := range tc.sends {
+				err := svc.Send(context.Background(), m.Tpl, m.Args, m.Numbers...)
+				require.NoError(t, err)
+			}
+			assert.Equal(t, tc.wantMsg, svc.Messages())
+		})
+	}
+}
+
+func TestMessage_String(t *testing.T) {
+	m := Message{Tpl: "1877556", Args: []string{"123456"}, Numbers: []string{"15212345678", "12345"}}
+	assert.Equal(t, "模板 1877556，参数 [123456]，发送给 [152****5678 ***45]", m.String())
+}
@@ -0,0 +1,43 @@
+package opentelemetry
+
+import (
+	"context"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"webook/internal/service/sms"
+)
+
+const tracerName = "webook/internal/service/sms"
+
+// TracedSMSService 装饰器，每次发短信开一个子 span，
+// 看得出来慢的时候是不是短信服务商那边慢
+type TracedSMSService struct {
+	svc    sms.Service
+	tracer trace.Tracer
+}
+
+// NewTracedSMSService tp 一般传 otel.GetTracerProvider()，没有配置的时候是 noop 的
+func NewTracedSMSService(svc sms.Service, tp trace.TracerProvider) sms.Service {
+	return &TracedSMSService{
+		svc:    svc,
+		tracer: tp.Tracer(tracerName),
+	}
+}
+
+// Send 参数里面有验证码，手机号也是敏感信息，都不放进 span 里面
+func (s *TracedSMSService) Send(ctx context.Context, tpl string, args []string, numbers ...string) error {
+	ctx, span := s.tracer.Start(ctx, "SMS.Send",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("sms.tpl", tpl),
+			attribute.Int("sms.numbers", len(numbers)),
+		))
+	defer span.End()
+	err := s.svc.Send(ctx, tpl, args, numbers...)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return err
+}
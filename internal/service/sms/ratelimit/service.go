@@ -0,0 +1,38 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"webook/internal/service/sms"
+	"webook/pkg/ratelimit"
+)
+
+var errLimited = errors.New("触发了限流")
+
+// RatelimitSMSService 装饰器，在发送短信之前先检查有没有触发限流
+type RatelimitSMSService struct {
+	svc     sms.Service
+	limiter ratelimit.Limiter
+}
+
+func NewRatelimitSMSService(svc sms.Service, limiter ratelimit.Limiter) sms.Service {
+	return &RatelimitSMSService{
+		svc:     svc,
+		limiter: limiter,
+	}
+}
+
+func (s *RatelimitSMSService) Send(ctx context.Context, tpl string, args []string, numbers ...string) error {
+	limited, err := s.limiter.Limit(ctx, "sms:tencent")
+	if err != nil {
+		// 系统错误
+		// 可以限流：保守策略，你的下游很坑的时候，
+		// 可以不限：你的下游很强，业务可用性要求很高，尽量容错策略
+		return fmt.Errorf("短信服务判断是否限流出现问题，%w", err)
+	}
+	if limited {
+		return errLimited
+	}
+	return s.svc.Send(ctx, tpl, args, numbers...)
+}
@@ -3,57 +3,831 @@ package service
 import (
 	"basic-go/webook/internal/domain"
 	"basic-go/webook/internal/repository"
+	"basic-go/webook/internal/repository/cache"
+	"basic-go/webook/internal/service/disposable"
+	"basic-go/webook/internal/service/idverify"
+	"basic-go/webook/internal/service/password"
+	"basic-go/webook/internal/service/sensitive"
+	"basic-go/webook/pkg/ratelimit"
+	"basic-go/webook/pkg/sanitize"
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
+	"log"
+	"strings"
+	"time"
 )
 
 var ErrUserDuplicateEmail = repository.ErrUserDuplicateEmail
+var ErrUserDuplicatePhone = repository.ErrUserDuplicatePhone
+var ErrUserNotFound = repository.ErrUserNotFound
 var ErrInvalidUserOrPassword = errors.New("账号/邮箱或密码不对")
+var ErrAccountPendingDeletion = errors.New("账号已注销，还在冷静期内")
+var ErrAccountDeactivated = errors.New("账号已停用")
+var ErrAlreadyVerified = repository.ErrUserAlreadyVerified
+var ErrIdentityMismatch = errors.New("姓名和身份证号不匹配")
+var ErrSensitiveContent = errors.New("包含敏感词")
+var ErrProfileBatchTooLarge = errors.New("一次查询的用户太多")
+var ErrUserNotBanned = repository.ErrUserNotBanned
+var ErrInvalidBanUntil = errors.New("解封时间必须晚于现在")
+var ErrProfileNotCached = repository.ErrProfileNotCached
+var ErrUserListPageTooDeep = errors.New("翻页太深了，要用游标")
+var ErrAnonymousSessionInvalid = cache.ErrAnonymousSessionNotFound
+var ErrAnonymousSessionBound = cache.ErrAnonymousSessionBound
+var ErrDisposableEmail = errors.New("不支持使用一次性邮箱注册")
+var ErrSignupTooFrequent = errors.New("这个邮箱域名注册太频繁了")
+var ErrMergeSameAccount = errors.New("不能把账号合并到自己")
+var ErrImpersonateSelf = errors.New("不能代办登录自己")
+var ErrPhoneUnchanged = errors.New("新手机号跟原来的一样")
+
+// MaxUserListOffset 后台用户列表超过这么多条之后不能再用页码翻，
+// OFFSET 越大数据库扫的行越多
+const MaxUserListOffset = 1000
+
+// AnonymousSessionTTL 匿名会话只用来关联登录之前的行为，不需要太长
+const AnonymousSessionTTL = time.Hour
+
+// NicknameCooldownError 冷却期内改昵称，NextAvailableAt 是下一次可以改的时间
+type NicknameCooldownError struct {
+	NextAvailableAt time.Time
+}
+
+func (e *NicknameCooldownError) Error() string {
+	return fmt.Sprintf("昵称 %s 之后才能再次修改", e.NextAvailableAt.Format(time.DateOnly))
+}
+
+// UserBannedError 账号被封禁了，登录的时候把原因和解封时间告诉用户。Until 零值是永久封禁
+type UserBannedError struct {
+	Reason string
+	Until  time.Time
+}
+
+func (e *UserBannedError) Error() string {
+	return "账号已被封禁：" + e.Reason
+}
+
+// ErrIDCardHashKeyMissing 没有配置 WithIDCardHashKey，不能做实名认证
+var ErrIDCardHashKeyMissing = errors.New("没有配置身份证号码的 HMAC 密钥")
+
+// AccountDeletionGracePeriod 注销之后的冷静期，冷静期内登录可以恢复账号，过了就彻底删除
+const AccountDeletionGracePeriod = time.Hour * 24 * 30
+
+// NicknameCooldown 两次修改昵称至少要间隔这么久
+const NicknameCooldown = time.Hour * 24 * 30
+
+// 昵称和简介最多这么多个字符
+const profileTextMaxRunes = 255
+
+// 海外用户自己填的地区最多这么多个字符
+const locationMaxRunes = 64
+
+// MaxProfileBatch GetProfiles 一次最多查这么多个用户
+const MaxProfileBatch = 100
+
+// SensitiveMode 昵称、简介命中敏感词之后怎么处理
+type SensitiveMode uint8
+
+const (
+	// SensitiveModeReject 直接拒绝，返回 ErrSensitiveContent
+	SensitiveModeReject SensitiveMode = iota
+	// SensitiveModeMask 把敏感词替换成 * 再保存
+	SensitiveModeMask
+)
+
+// DisposableEmailMode 用一次性邮箱注册的时候怎么处理
+type DisposableEmailMode uint8
+
+const (
+	// DisposableEmailAllow 不检查
+	DisposableEmailAllow DisposableEmailMode = iota
+	// DisposableEmailReject 直接拒绝，返回 ErrDisposableEmail
+	DisposableEmailReject
+	// DisposableEmailFlag 允许注册，但是在用户上面打个标记，后台可以看到
+	DisposableEmailFlag
+)
 
 type UserService struct {
-	repo *repository.UserRepository
+	repo           *repository.UserRepository
+	idVerifier     idverify.IDVerifier
+	filter         sensitive.Filter
+	sensitiveMode  SensitiveMode
+	profileWeights ProfileWeights
+	anonCache      cache.AnonymousSessionCache
+
+	disposableList *disposable.List
+	disposableMode DisposableEmailMode
+	// 按邮箱域名限制注册的频率，nil 就是不限制
+	signupLimiter ratelimit.Limiter
+
+	emailVerifier EmailVerificationService
+	// 邮箱没有验证过的不能用邮箱密码登录
+	requireEmailVerified bool
+	// 按邮箱限制重发验证邮件的频率，nil 就是不限制
+	emailResendLimiter ratelimit.Limiter
+
+	// 登录的时候发现是老算法算的密码，会用新的重新算一遍
+	passwords *password.Schemes
+
+	// nil 就是不记录登录历史
+	loginEvents repository.LoginEventRepository
+
+	// 身份证号码的取值空间很小，直接 sha256 很容易被穷举，所以用 HMAC。
+	// 空的就不能做实名认证
+	idCardHashKey []byte
+}
+
+type UserServiceOption func(svc *UserService)
+
+// WithDisposableEmailCheck 注册的时候检查是不是一次性邮箱
+func WithDisposableEmailCheck(list *disposable.List, mode DisposableEmailMode) UserServiceOption {
+	return func(svc *UserService) {
+		svc.disposableList = list
+		svc.disposableMode = mode
+	}
+}
+
+// WithSignupDomainLimiter 同一个邮箱域名注册的频率由 limiter 控制
+func WithSignupDomainLimiter(limiter ratelimit.Limiter) UserServiceOption {
+	return func(svc *UserService) {
+		svc.signupLimiter = limiter
+	}
 }
 
-func NewUserService(repo *repository.UserRepository) *UserService {
-	return &UserService{
-		repo: repo,
+// WithEmailVerification required 的时候邮箱验证过了才能登录
+func WithEmailVerification(verifier EmailVerificationService, required bool) UserServiceOption {
+	return func(svc *UserService) {
+		svc.emailVerifier = verifier
+		svc.requireEmailVerified = required
 	}
 }
 
+// WithEmailResendLimiter 同一个邮箱重发验证邮件的频率由 limiter 控制
+func WithEmailResendLimiter(limiter ratelimit.Limiter) UserServiceOption {
+	return func(svc *UserService) {
+		svc.emailResendLimiter = limiter
+	}
+}
+
+// WithPasswordSchemes 默认只有 bcrypt
+func WithPasswordSchemes(s *password.Schemes) UserServiceOption {
+	return func(svc *UserService) {
+		svc.passwords = s
+	}
+}
+
+// WithIDCardHashKey 实名认证的时候身份证号码用这个 key 算 HMAC 再存。
+// 换了之后老的记录就对不上了
+func WithIDCardHashKey(key []byte) UserServiceOption {
+	return func(svc *UserService) {
+		svc.idCardHashKey = key
+	}
+}
+
+func NewUserService(repo *repository.UserRepository, idVerifier idverify.IDVerifier,
+	filter sensitive.Filter, sensitiveMode SensitiveMode, profileWeights ProfileWeights,
+	anonCache cache.AnonymousSessionCache, opts ...UserServiceOption) *UserService {
+	svc := &UserService{
+		repo:           repo,
+		idVerifier:     idVerifier,
+		filter:         filter,
+		sensitiveMode:  sensitiveMode,
+		profileWeights: profileWeights,
+		anonCache:      anonCache,
+		passwords:      password.NewSchemes(password.NewBcryptHasher(bcrypt.DefaultCost)),
+	}
+	for _, opt := range opts {
+		opt(svc)
+	}
+	return svc
+}
+
+// CreateAnonymousSession 还没登录的用户先发一个匿名 ID
+func (svc *UserService) CreateAnonymousSession(ctx context.Context) (string, error) {
+	id := uuid.New().String()
+	if err := svc.anonCache.Create(ctx, id, AnonymousSessionTTL); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// UpgradeAnonymousSession 登录成功之后把匿名会话归到这个用户名下。
+// 现在还没有单独存登录之前的行为，带着匿名 ID 上报的数据之后按这个关联去找用户
+func (svc *UserService) UpgradeAnonymousSession(ctx context.Context, anonID string, uid int64) error {
+	return svc.anonCache.Bind(ctx, anonID, uid)
+}
+
+// Login 邮箱存的都是小写的，输入的先转成小写。
+// 找到了用户但是登录不了的（密码不对、封禁了、停用了、邮箱没验证），返回的 User 里面只有 Id，用来记登录失败
 func (svc *UserService) Login(ctx context.Context, email, password string) (domain.User, error) {
+	email = strings.ToLower(email)
 	// 先找用户
 	u, err := svc.repo.FindByEmail(ctx, email)
 	if err == repository.ErrUserNotFound {
+		// 有可能是注销了的账号
+		_, err = svc.findDeletedInGracePeriod(ctx, email, password)
+		if err == nil {
+			return domain.User{}, ErrAccountPendingDeletion
+		}
 		return domain.User{}, ErrInvalidUserOrPassword
 	}
 	if err != nil {
 		return domain.User{}, err
 	}
 	// 比较密码了
-	err = bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(password))
+	needRehash, err := svc.passwords.Compare(u.Password, password)
 	if err != nil {
 		// DEBUG
-		return domain.User{}, ErrInvalidUserOrPassword
+		return domain.User{Id: u.Id}, ErrInvalidUserOrPassword
+	}
+	if needRehash {
+		svc.rehashPassword(ctx, u.Id, password)
+	}
+	// 密码对了才告诉他被封禁了
+	if err = svc.CheckBan(u); err != nil {
+		return domain.User{Id: u.Id}, err
+	}
+	if err = svc.CheckDeactivated(u); err != nil {
+		return domain.User{Id: u.Id}, err
+	}
+	if err = svc.checkEmailVerified(u); err != nil {
+		return domain.User{Id: u.Id}, err
 	}
 	return u, nil
 }
 
+// rehashPassword 换成现在的算法重新算，失败了也不影响这次登录，下次登录再试
+func (svc *UserService) rehashPassword(ctx context.Context, uid int64, pwd string) {
+	hash, err := svc.passwords.Hash(pwd)
+	if err == nil {
+		err = svc.repo.UpdatePassword(ctx, uid, hash)
+	}
+	if err != nil {
+		log.Println("重新计算密码哈希失败", uid, err)
+	}
+}
+
+// CheckDeactivated 停用了的账号返回 ErrAccountDeactivated，要用户确认之后再恢复
+func (svc *UserService) CheckDeactivated(u domain.User) error {
+	if u.Status == domain.UserStatusDeactivated {
+		return ErrAccountDeactivated
+	}
+	return nil
+}
+
+func (svc *UserService) checkEmailVerified(u domain.User) error {
+	if svc.requireEmailVerified && u.EmailVerifiedAt.IsZero() {
+		return ErrEmailNotVerified
+	}
+	return nil
+}
+
+// ResendVerificationEmail 邮箱没验证登录不了，不需要密码，只要邮箱。
+// 为了不让人拿这个接口来试哪些邮箱注册过，没有这个账号也返回 nil，
+// 限流也是按邮箱来的，不管账号存不存在都算一次
+func (svc *UserService) ResendVerificationEmail(ctx context.Context, email string) error {
+	if svc.emailResendLimiter != nil {
+		limited, err := svc.emailResendLimiter.Limit(ctx, "email_resend:"+strings.ToLower(email))
+		if err != nil {
+			return err
+		}
+		if limited {
+			return ErrEmailVerifyTooFrequent
+		}
+	}
+	u, err := svc.repo.FindByEmail(ctx, strings.ToLower(email))
+	if err == repository.ErrUserNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return svc.emailVerifier.Send(ctx, u)
+}
+
+// VerifyEmail token 是验证邮件的链接里面带的
+func (svc *UserService) VerifyEmail(ctx context.Context, token string) error {
+	return svc.emailVerifier.Verify(ctx, token)
+}
+
+// CheckBan 登录之前检查，封禁中返回 *UserBannedError。
+// 到期了就自动解封，不需要定时任务去改数据
+func (svc *UserService) CheckBan(u domain.User) error {
+	if u.Ban == nil || !u.Ban.Active(time.Now()) {
+		return nil
+	}
+	return &UserBannedError{
+		Reason: u.Ban.Reason,
+		Until:  u.Ban.Until,
+	}
+}
+
+// Ban 管理员封禁用户，until 零值是永久封禁。会同时记审计日志
+func (svc *UserService) Ban(ctx context.Context, operator int64, uid int64,
+	reason string, until time.Time) error {
+	now := time.Now()
+	if !until.IsZero() && !until.After(now) {
+		return ErrInvalidBanUntil
+	}
+	// 毫秒数，0 是永久封禁
+	var untilMs int64
+	if !until.IsZero() {
+		untilMs = until.UnixMilli()
+	}
+	detail, err := json.Marshal(map[string]any{
+		"reason": reason,
+		"until":  untilMs,
+	})
+	if err != nil {
+		return err
+	}
+	return svc.repo.Ban(ctx, domain.UserBan{
+		Uid:      uid,
+		Operator: operator,
+		Reason:   reason,
+		Until:    until,
+		Ctime:    now,
+	}, domain.AdminAuditLog{
+		Operator:  operator,
+		Action:    domain.AdminActionBanUser,
+		TargetUid: uid,
+		Detail:    string(detail),
+		Ctime:     now,
+	})
+}
+
+// ImpersonationTTL 代办登录的 JWT 多久过期，不会续约，到期了要重新申请
+const ImpersonationTTL = time.Minute * 15
+
+// Impersonate 管理员代办登录成 targetUserID，只检查用户还能不能用，记一条审计日志。
+// JWT 由 web 那边按照返回的 Id 和 ExpiresAt 签发
+func (svc *UserService) Impersonate(ctx context.Context, adminID, targetUserID int64) (domain.Impersonation, error) {
+	if adminID == targetUserID {
+		return domain.Impersonation{}, ErrImpersonateSelf
+	}
+	u, err := svc.repo.FindById(ctx, targetUserID)
+	if err != nil {
+		return domain.Impersonation{}, err
+	}
+	if u.Status != domain.UserStatusActive {
+		return domain.Impersonation{}, ErrUserNotFound
+	}
+	now := time.Now()
+	imp := domain.Impersonation{
+		Id:        uuid.New().String(),
+		AdminId:   adminID,
+		Uid:       targetUserID,
+		Ctime:     now,
+		ExpiresAt: now.Add(ImpersonationTTL),
+	}
+	detail, err := json.Marshal(map[string]any{
+		"jti":        imp.Id,
+		"expires_at": imp.ExpiresAt.UnixMilli(),
+	})
+	if err != nil {
+		return domain.Impersonation{}, err
+	}
+	// 审计日志没记上就不发 JWT
+	err = svc.repo.CreateAuditLog(ctx, domain.AdminAuditLog{
+		Operator:  adminID,
+		Action:    domain.AdminActionImpersonate,
+		TargetUid: targetUserID,
+		Detail:    string(detail),
+		Ctime:     now,
+	})
+	if err != nil {
+		return domain.Impersonation{}, err
+	}
+	return imp, nil
+}
+
+// ListUsers 后台用户列表。withTotal 的时候才查总数，数据多了 COUNT 很慢
+func (svc *UserService) ListUsers(ctx context.Context, q domain.UserListQuery,
+	withTotal bool) ([]domain.User, int64, error) {
+	if q.After == nil && q.Offset > MaxUserListOffset {
+		return nil, 0, ErrUserListPageTooDeep
+	}
+	us, err := svc.repo.List(ctx, q)
+	if err != nil {
+		return nil, 0, err
+	}
+	if !withTotal {
+		return us, 0, nil
+	}
+	total, err := svc.repo.Count(ctx, q)
+	if err != nil {
+		return nil, 0, err
+	}
+	return us, total, nil
+}
+
+// Unban 提前解封，登录态也恢复
+func (svc *UserService) Unban(ctx context.Context, operator int64, uid int64) error {
+	return svc.repo.Unban(ctx, uid, operator, domain.AdminAuditLog{
+		Operator:  operator,
+		Action:    domain.AdminActionUnbanUser,
+		TargetUid: uid,
+		Detail:    "{}",
+		Ctime:     time.Now(),
+	})
+}
+
+// MergeAccounts 管理员把同一个人的重复账号 secondaryId 合并到 primaryId，
+// 两个账号都要是正常状态。冲突的邮箱、手机号以主账号为准，具体见审计日志
+func (svc *UserService) MergeAccounts(ctx context.Context, operator int64, primaryId, secondaryId int64) error {
+	if primaryId == secondaryId {
+		return ErrMergeSameAccount
+	}
+	return svc.repo.MergeUsers(ctx, primaryId, secondaryId, domain.AdminAuditLog{
+		Operator:  operator,
+		Action:    domain.AdminActionMergeUser,
+		TargetUid: primaryId,
+		Ctime:     time.Now(),
+	})
+}
+
+// SignUp 邮箱统一存小写，只是大小写不一样的也算已经注册过了
 func (svc *UserService) SignUp(ctx context.Context, u domain.User) error {
+	u.Email = strings.ToLower(u.Email)
+	if err := svc.checkSignupEmail(ctx, &u); err != nil {
+		return err
+	}
+	if u.Email != "" {
+		_, err := svc.repo.FindByEmailCaseInsensitive(ctx, u.Email)
+		switch err {
+		case nil:
+			return ErrUserDuplicateEmail
+		case repository.ErrUserNotFound:
+		default:
+			return err
+		}
+	}
 	// 你要考虑加密放在哪里的问题了
-	hash, err := bcrypt.GenerateFromPassword([]byte(u.Password), bcrypt.DefaultCost)
+	hash, err := svc.passwords.Hash(u.Password)
 	if err != nil {
 		return err
 	}
-	u.Password = string(hash)
+	u.Password = hash
 	// 然后就是，存起来
 	return svc.repo.Create(ctx, u)
 }
 
-func (svc *UserService) Edit(ctx context.Context, u domain.User) error {
-	return svc.repo.Edit(ctx, u)
+// SignUpByPhone 只有手机号，没有密码，以后用短信验证码登录。
+// 跟 FindOrCreate 不一样，已经注册过了返回 ErrUserDuplicatePhone
+func (svc *UserService) SignUpByPhone(ctx context.Context, phone string) (domain.User, error) {
+	if err := svc.repo.Create(ctx, domain.User{Phone: phone}); err != nil {
+		return domain.User{}, err
+	}
+	return svc.repo.FindByPhone(ctx, phone)
+}
+
+// checkSignupEmail 一次性邮箱和同一个域名的注册频率，没有邮箱的不检查
+func (svc *UserService) checkSignupEmail(ctx context.Context, u *domain.User) error {
+	i := strings.LastIndex(u.Email, "@")
+	if i < 0 {
+		return nil
+	}
+	emailDomain := strings.ToLower(u.Email[i+1:])
+	if svc.disposableList != nil && svc.disposableMode != DisposableEmailAllow &&
+		svc.disposableList.Contains(emailDomain) {
+		if svc.disposableMode == DisposableEmailReject {
+			return ErrDisposableEmail
+		}
+		u.DisposableEmail = true
+	}
+	if svc.signupLimiter == nil {
+		return nil
+	}
+	limited, err := svc.signupLimiter.Limit(ctx, "signup:domain:"+emailDomain)
+	if err != nil {
+		// 只是防刷，Redis 出问题的时候不能让所有人都注册不了
+		log.Println("注册频率检查失败", err)
+		return nil
+	}
+	if limited {
+		return ErrSignupTooFrequent
+	}
+	return nil
+}
+
+// Edit 返回的是清洗之后真正保存下来的数据，前端应该用它来展示
+func (svc *UserService) Edit(ctx context.Context, u domain.User) (domain.User, error) {
+	// 这些都是要展示给别人看的，存之前先清洗一下
+	u.Nickname = sanitize.Text(u.Nickname, profileTextMaxRunes)
+	u.Brief = sanitize.Text(u.Brief, profileTextMaxRunes)
+	u.Location = sanitize.Text(u.Location, locationMaxRunes)
+	var err error
+	u.Nickname, err = svc.filterSensitive(u.Nickname)
+	if err != nil {
+		return domain.User{}, err
+	}
+	u.Brief, err = svc.filterSensitive(u.Brief)
+	if err != nil {
+		return domain.User{}, err
+	}
+	u.Location, err = svc.filterSensitive(u.Location)
+	if err != nil {
+		return domain.User{}, err
+	}
+	old, err := svc.repo.FindById(ctx, u.Id)
+	if err != nil {
+		return domain.User{}, err
+	}
+	// 昵称没变就不算修改，只改简介、生日不受冷却期影响
+	if u.Nickname != old.Nickname {
+		now := time.Now()
+		// 第一次修改（从默认昵称改过来）不受限制
+		if !old.NicknameChangedAt.IsZero() {
+			next := old.NicknameChangedAt.Add(NicknameCooldown)
+			if now.Before(next) {
+				return domain.User{}, &NicknameCooldownError{NextAvailableAt: next}
+			}
+		}
+		u.NicknameChangedAt = now
+	}
+	if err = svc.repo.Edit(ctx, u); err != nil {
+		return domain.User{}, err
+	}
+	return u, nil
+}
+
+func (svc *UserService) filterSensitive(text string) (string, error) {
+	if svc.sensitiveMode == SensitiveModeMask {
+		return svc.filter.Mask(text), nil
+	}
+	if len(svc.filter.Match(text)) > 0 {
+		return "", ErrSensitiveContent
+	}
+	return text, nil
+}
+
+// AdminUpdateNickname 管理员直接改昵称，不检查也不刷新冷却期
+func (svc *UserService) AdminUpdateNickname(ctx context.Context, uid int64, nickname string) error {
+	return svc.repo.UpdateNickname(ctx, uid, sanitize.Text(nickname, profileTextMaxRunes))
 }
 
 func (svc *UserService) GetProfile(ctx context.Context, userId int64) (domain.User, error) {
 	return svc.repo.GetProfile(ctx, userId)
 }
+
+// RefreshProfile 管理工具绕过 Edit 直接改了数据库之后，用这个把缓存里面的公开资料刷新掉，
+// 返回的是刷新之后的公开资料
+func (svc *UserService) RefreshProfile(ctx context.Context, userId int64) (domain.User, error) {
+	return svc.repo.RefreshProfile(ctx, userId)
+}
+
+// CacheAge 缓存里面的公开资料写进去多久了，用来判断是不是太旧了。
+// 没有缓存返回 ErrProfileNotCached
+func (svc *UserService) CacheAge(ctx context.Context, userId int64) (time.Duration, error) {
+	return svc.repo.ProfileCacheAge(ctx, userId)
+}
+
+// ProfileCompleteness 资料完整度，u 要是 GetProfile 查出来的，里面有邮箱和手机号。
+// 算起来只是遍历几个字段，不需要缓存，改了权重也马上生效
+func (svc *UserService) ProfileCompleteness(u domain.User) domain.ProfileCompleteness {
+	return svc.profileWeights.Score(u)
+}
+
+// GetProfiles 批量查别人能看到的资料，比如说评论列表里面的作者。
+// 重复的 id 只查一次，不合法的 id 直接忽略，去重之后超过 MaxProfileBatch 个返回 ErrProfileBatchTooLarge
+func (svc *UserService) GetProfiles(ctx context.Context, ids []int64) (map[int64]domain.User, error) {
+	uniq := make([]int64, 0, len(ids))
+	seen := make(map[int64]struct{}, len(ids))
+	for _, id := range ids {
+		if _, ok := seen[id]; ok || id <= 0 {
+			continue
+		}
+		seen[id] = struct{}{}
+		uniq = append(uniq, id)
+	}
+	if len(uniq) > MaxProfileBatch {
+		return nil, ErrProfileBatchTooLarge
+	}
+	if len(uniq) == 0 {
+		return map[int64]domain.User{}, nil
+	}
+	return svc.repo.GetProfiles(ctx, uniq)
+}
+
+// FindOrCreate 手机号登录，找不到就直接注册一个
+func (svc *UserService) FindOrCreate(ctx context.Context, phone string) (domain.User, error) {
+	// 这时候，这个地方要怎么办？
+	// 这个叫做快路径
+	u, err := svc.repo.FindByPhone(ctx, phone)
+	// 要判断，有没有这个用户
+	if err != repository.ErrUserNotFound {
+		// 绝大部分请求进来这里
+		// nil 会进来这里
+		// 不为 ErrUserNotFound 的也会进来这里
+		return u, err
+	}
+	// 在系统资源不足，触发降级之后，不执行慢路径
+	// 慢路径
+	// 你明确知道，没有这个用户
+	u = domain.User{
+		Phone: phone,
+	}
+	err = svc.repo.Create(ctx, u)
+	// 手机号冲突说明并发的时候别人已经创建好了
+	if err != nil && err != repository.ErrUserDuplicatePhone {
+		return u, err
+	}
+	// 因为这里会遇到主从延迟的问题
+	return svc.repo.FindByPhone(ctx, phone)
+}
+
+// FindByEmail 注销了的账号邮箱已经腾出来了，查不到
+func (svc *UserService) FindByEmail(ctx context.Context, email string) (domain.User, error) {
+	return svc.repo.FindByEmail(ctx, email)
+}
+
+func (svc *UserService) FindById(ctx context.Context, id int64) (domain.User, error) {
+	return svc.repo.FindById(ctx, id)
+}
+
+// CheckPhoneChange 换手机号之前先检查一遍再发验证码，返回当前的用户，要用到原来的手机号。
+// 跟原来的一样返回 ErrPhoneUnchanged，被别人用了返回 ErrUserDuplicatePhone
+func (svc *UserService) CheckPhoneChange(ctx context.Context, uid int64, phone string) (domain.User, error) {
+	u, err := svc.repo.FindById(ctx, uid)
+	if err != nil {
+		return domain.User{}, err
+	}
+	if u.Phone == phone {
+		return domain.User{}, ErrPhoneUnchanged
+	}
+	_, err = svc.repo.FindByPhone(ctx, phone)
+	switch err {
+	case nil:
+		return domain.User{}, ErrUserDuplicatePhone
+	case repository.ErrUserNotFound:
+		return u, nil
+	default:
+		return domain.User{}, err
+	}
+}
+
+// ChangePhone 验证码由调用的人校验过了。
+// 检查完到真的改之间被别人注册了的，靠唯一索引兜底，一样是 ErrUserDuplicatePhone
+func (svc *UserService) ChangePhone(ctx context.Context, uid int64, phone string) error {
+	if _, err := svc.CheckPhoneChange(ctx, uid, phone); err != nil {
+		return err
+	}
+	return svc.repo.UpdatePhone(ctx, uid, phone)
+}
+
+// DeleteAccount 注销账号，进入冷静期
+func (svc *UserService) DeleteAccount(ctx context.Context, id int64) error {
+	return svc.repo.SoftDelete(ctx, id, AccountDeletionGracePeriod)
+}
+
+// Reactivate 冷静期内用邮箱和密码重新登录，恢复账号
+func (svc *UserService) Reactivate(ctx context.Context, email, password string) (domain.User, error) {
+	u, err := svc.findDeletedInGracePeriod(ctx, email, password)
+	if err != nil {
+		return domain.User{}, err
+	}
+	err = svc.repo.Reactivate(ctx, u.Id)
+	if err != nil {
+		return domain.User{}, err
+	}
+	u, err = svc.repo.FindById(ctx, u.Id)
+	if err != nil {
+		return domain.User{}, err
+	}
+	if err = svc.CheckBan(u); err != nil {
+		return domain.User{}, err
+	}
+	if err = svc.checkEmailVerified(u); err != nil {
+		return domain.User{}, err
+	}
+	return u, nil
+}
+
+// VerifyPassword 停用账号这种操作之前再确认一遍密码，不对返回 ErrInvalidUserOrPassword
+func (svc *UserService) VerifyPassword(ctx context.Context, uid int64, password string) error {
+	u, err := svc.repo.FindById(ctx, uid)
+	if err != nil {
+		return err
+	}
+	// 手机号注册的没有密码
+	if _, err = svc.passwords.Compare(u.Password, password); err != nil {
+		return ErrInvalidUserOrPassword
+	}
+	return nil
+}
+
+// Deactivate 用户自己停用账号，所有的登录态马上失效，别人也看不到他的资料了。
+// 邮箱和手机号还是他的，下次登录的时候确认恢复就可以
+func (svc *UserService) Deactivate(ctx context.Context, uid int64) error {
+	return svc.repo.Deactivate(ctx, uid)
+}
+
+// ReactivateDeactivated 停用的账号用邮箱和密码登录，确认之后恢复
+func (svc *UserService) ReactivateDeactivated(ctx context.Context, email, password string) (domain.User, error) {
+	u, err := svc.repo.FindByEmail(ctx, strings.ToLower(email))
+	if err == repository.ErrUserNotFound {
+		return domain.User{}, ErrInvalidUserOrPassword
+	}
+	if err != nil {
+		return domain.User{}, err
+	}
+	if _, err = svc.passwords.Compare(u.Password, password); err != nil {
+		return domain.User{}, ErrInvalidUserOrPassword
+	}
+	if err = svc.CheckBan(u); err != nil {
+		return domain.User{}, err
+	}
+	u, err = svc.ReactivateDeactivatedById(ctx, u.Id)
+	if err != nil {
+		return domain.User{}, err
+	}
+	if err = svc.checkEmailVerified(u); err != nil {
+		return domain.User{}, err
+	}
+	return u, nil
+}
+
+// ReactivateDeactivatedById 调用方已经确认过是本人了，比如说短信验证码登录
+func (svc *UserService) ReactivateDeactivatedById(ctx context.Context, uid int64) (domain.User, error) {
+	err := svc.repo.EndDeactivation(ctx, uid)
+	if err != nil {
+		return domain.User{}, err
+	}
+	return svc.repo.FindById(ctx, uid)
+}
+
+func (svc *UserService) findDeletedInGracePeriod(ctx context.Context,
+	email, password string) (domain.User, error) {
+	u, err := svc.repo.FindDeletedByEmail(ctx, email)
+	if err == repository.ErrUserNotFound {
+		return domain.User{}, ErrInvalidUserOrPassword
+	}
+	if err != nil {
+		return domain.User{}, err
+	}
+	// 过了冷静期，就当作不存在
+	if time.Since(u.DeletedAt) > AccountDeletionGracePeriod {
+		return domain.User{}, ErrInvalidUserOrPassword
+	}
+	if _, err = svc.passwords.Compare(u.Password, password); err != nil {
+		return domain.User{}, ErrInvalidUserOrPassword
+	}
+	return u, nil
+}
+
+// IsRevoked 用户的登录态是不是已经被强制失效了
+func (svc *UserService) IsRevoked(ctx context.Context, id int64) (bool, error) {
+	return svc.repo.IsRevoked(ctx, id)
+}
+
+// PurgeDeletedUsers 彻底删除过了冷静期的账号，返回删除了多少个
+func (svc *UserService) PurgeDeletedUsers(ctx context.Context, batchSize int) (int, error) {
+	before := time.Now().Add(-AccountDeletionGracePeriod)
+	us, err := svc.repo.FindDeletedBefore(ctx, before, batchSize)
+	if err != nil {
+		return 0, err
+	}
+	cnt := 0
+	for _, u := range us {
+		err = svc.repo.HardDelete(ctx, u.Id, before)
+		if err != nil {
+			return cnt, err
+		}
+		cnt++
+	}
+	return cnt, nil
+}
+
+// VerifyIdentity 实名认证，每个账号只能认证一次
+func (svc *UserService) VerifyIdentity(ctx context.Context, uid int64, realName, idCard string) error {
+	if len(svc.idCardHashKey) == 0 {
+		return ErrIDCardHashKeyMissing
+	}
+	u, err := svc.repo.FindById(ctx, uid)
+	if err != nil {
+		return err
+	}
+	if u.VerifiedAt != nil {
+		return ErrAlreadyVerified
+	}
+	ok, err := svc.idVerifier.Verify(realName, idCard)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrIdentityMismatch
+	}
+	return svc.repo.UpdateIdentity(ctx, uid, svc.hashIDCard(idCard), time.Now())
+}
+
+func (svc *UserService) hashIDCard(idCard string) string {
+	mac := hmac.New(sha256.New, svc.idCardHashKey)
+	// 最后一位可能是 x 也可能是 X
+	mac.Write([]byte(strings.ToUpper(idCard)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,22 @@
+package service
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestUserService_hashIDCard(t *testing.T) {
+	svc := &UserService{idCardHashKey: []byte("key1")}
+	// 最后一位大小写都一样
+	assert.Equal(t, svc.hashIDCard("11010519491231002X"), svc.hashIDCard("11010519491231002x"))
+	// 换了 key 就对不上了
+	other := &UserService{idCardHashKey: []byte("key2")}
+	assert.NotEqual(t, svc.hashIDCard("11010519491231002X"), other.hashIDCard("11010519491231002X"))
+}
+
+func TestUserService_VerifyIdentityNoKey(t *testing.T) {
+	svc := NewUserService(nil, nil, nil, SensitiveModeReject, nil, nil)
+	err := svc.VerifyIdentity(context.Background(), 3, "张三", "11010519491231002X")
+	assert.Equal(t, ErrIDCardHashKeyMissing, err)
+}
@@ -0,0 +1,103 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	gormMysql "gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"testing"
+	"time"
+	"webook/internal/domain"
+	"webook/internal/repository"
+	"webook/internal/repository/dao"
+)
+
+func TestUserService_Impersonate(t *testing.T) {
+	testCases := []struct {
+		name   string
+		mock   func(mock sqlmock.Sqlmock)
+		target int64
+
+		wantErr error
+	}{
+		{
+			name:   "成功，记了审计日志",
+			target: 3,
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery("SELECT \\* FROM `users` WHERE id = \\?").
+					WillReturnRows(sqlmock.NewRows([]string{"id", "status"}).AddRow(3, 0))
+				mock.ExpectExec("INSERT INTO `admin_audit_logs` .*").
+					WithArgs(int64(1), "impersonate_user", int64(3), sqlmock.AnyArg(), sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+			},
+		},
+		{
+			name:    "不能代办登录自己",
+			target:  1,
+			mock:    func(mock sqlmock.Sqlmock) {},
+			wantErr: ErrImpersonateSelf,
+		},
+		{
+			name:   "用户不存在",
+			target: 3,
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery("SELECT \\* FROM `users` WHERE id = \\?").
+					WillReturnRows(sqlmock.NewRows([]string{"id"}))
+			},
+			wantErr: ErrUserNotFound,
+		},
+		{
+			name:   "已经注销了",
+			target: 3,
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery("SELECT \\* FROM `users` WHERE id = \\?").
+					WillReturnRows(sqlmock.NewRows([]string{"id", "status"}).
+						AddRow(3, domain.UserStatusDeleted))
+			},
+			wantErr: ErrUserNotFound,
+		},
+		{
+			name:   "审计日志没记上",
+			target: 3,
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery("SELECT \\* FROM `users` WHERE id = \\?").
+					WillReturnRows(sqlmock.NewRows([]string{"id", "status"}).AddRow(3, 0))
+				mock.ExpectExec("INSERT INTO `admin_audit_logs` .*").
+					WillReturnError(errors.New("mock db 错误"))
+			},
+			wantErr: errors.New("mock db 错误"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockDB, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			tc.mock(mock)
+			db, err := gorm.Open(gormMysql.New(gormMysql.Config{
+				Conn:                      mockDB,
+				SkipInitializeWithVersion: true,
+			}), &gorm.Config{
+				DisableAutomaticPing:   true,
+				SkipDefaultTransaction: true,
+			})
+			require.NoError(t, err)
+			svc := NewUserService(repository.NewUserRepository(dao.NewUserDAO(db), nil),
+				nil, nil, SensitiveModeReject, nil, nil)
+
+			imp, err := svc.Impersonate(context.Background(), 1, tc.target)
+			assert.Equal(t, tc.wantErr, err)
+			assert.NoError(t, mock.ExpectationsWereMet())
+			if err != nil {
+				return
+			}
+			assert.Len(t, imp.Id, 36)
+			assert.Equal(t, int64(1), imp.AdminId)
+			assert.Equal(t, int64(3), imp.Uid)
+			assert.Equal(t, ImpersonationTTL, imp.ExpiresAt.Sub(imp.Ctime))
+			assert.WithinDuration(t, time.Now(), imp.Ctime, time.Second)
+		})
+	}
+}
@@ -0,0 +1,14 @@
+package service
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestUserService_MergeAccountsSameAccount(t *testing.T) {
+	// 校验在查数据库之前，repo 是 nil 也不会用到
+	svc := NewUserService(nil, nil, nil, SensitiveModeReject, nil, nil)
+	err := svc.MergeAccounts(context.Background(), 1, 3, 3)
+	assert.Equal(t, ErrMergeSameAccount, err)
+}
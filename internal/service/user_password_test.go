@@ -0,0 +1,129 @@
+package service
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"golang.org/x/crypto/bcrypt"
+	gormMysql "gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"strings"
+	"testing"
+	"webook/internal/repository"
+	cachemocks "webook/internal/repository/cache/mocks"
+	"webook/internal/repository/dao"
+	"webook/internal/service/password"
+)
+
+// argon2HashArg 更新进去的是 argon2id 算的，而且能用原来的密码验证
+type argon2HashArg struct {
+	hasher   *password.Argon2Hasher
+	password string
+}
+
+func (a argon2HashArg) Match(v driver.Value) bool {
+	hash, ok := v.(string)
+	return ok && strings.HasPrefix(hash, "$argon2id$") && a.hasher.Compare(hash, a.password) == nil
+}
+
+func TestUserService_LoginRehash(t *testing.T) {
+	query := "SELECT \\* FROM `users` WHERE email = \\?"
+	update := "UPDATE `users` SET `password`=\\?,`utime`=\\? WHERE id = \\?"
+	// 测试里面用小一点的参数，不然太慢
+	argon := password.NewArgon2Hasher(password.Argon2Params{
+		Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32,
+	})
+	bcryptHash, err := bcrypt.GenerateFromPassword([]byte("hello#world123"), bcrypt.MinCost)
+	require.NoError(t, err)
+	argonHash, err := argon.Hash("hello#world123")
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name     string
+		mock     func(mock sqlmock.Sqlmock)
+		cache    func(ctrl *gomock.Controller) *cachemocks.MockUserCache
+		password string
+
+		wantErr error
+	}{
+		{
+			name: "bcrypt 的换成 argon2id",
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(query).WillReturnRows(sqlmock.NewRows([]string{"id", "email", "password"}).
+					AddRow(3, "tom@qq.com", string(bcryptHash)))
+				mock.ExpectExec(update).
+					WithArgs(argon2HashArg{hasher: argon, password: "hello#world123"}, sqlmock.AnyArg(), 3).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			cache: func(ctrl *gomock.Controller) *cachemocks.MockUserCache {
+				c := cachemocks.NewMockUserCache(ctrl)
+				c.EXPECT().DelProfile(gomock.Any(), int64(3)).Return(nil)
+				c.EXPECT().Del(gomock.Any(), int64(3)).Return(nil)
+				return c
+			},
+			password: "hello#world123",
+		},
+		{
+			name: "已经是 argon2id 了，不用再算",
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(query).WillReturnRows(sqlmock.NewRows([]string{"id", "email", "password"}).
+					AddRow(3, "tom@qq.com", argonHash))
+			},
+			password: "hello#world123",
+		},
+		{
+			name: "密码不对不换",
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(query).WillReturnRows(sqlmock.NewRows([]string{"id", "email", "password"}).
+					AddRow(3, "tom@qq.com", string(bcryptHash)))
+			},
+			password: "hello#world456",
+			wantErr:  ErrInvalidUserOrPassword,
+		},
+		{
+			// 下次登录再换
+			name: "更新失败也能登录",
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(query).WillReturnRows(sqlmock.NewRows([]string{"id", "email", "password"}).
+					AddRow(3, "tom@qq.com", string(bcryptHash)))
+				mock.ExpectExec(update).WillReturnError(errors.New("mock db 错误"))
+			},
+			password: "hello#world123",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			mockDB, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			db, err := gorm.Open(gormMysql.New(gormMysql.Config{
+				Conn:                      mockDB,
+				SkipInitializeWithVersion: true,
+			}), &gorm.Config{
+				DisableAutomaticPing:   true,
+				SkipDefaultTransaction: true,
+			})
+			require.NoError(t, err)
+			tc.mock(mock)
+			userCache := cachemocks.NewMockUserCache(ctrl)
+			if tc.cache != nil {
+				userCache = tc.cache(ctrl)
+			}
+			repo := repository.NewUserRepository(dao.NewUserDAO(db), userCache)
+			svc := NewUserService(repo, nil, nil, SensitiveModeReject, nil, nil,
+				WithPasswordSchemes(password.NewSchemes(argon, password.NewBcryptHasher(bcrypt.MinCost))))
+
+			u, err := svc.Login(context.Background(), "tom@qq.com", tc.password)
+			assert.Equal(t, tc.wantErr, err)
+			if err == nil {
+				assert.Equal(t, int64(3), u.Id)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package service
+
+import (
+	"context"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	gormMysql "gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"testing"
+	"webook/internal/domain"
+	"webook/internal/repository"
+	"webook/internal/repository/dao"
+)
+
+func TestUserService_GetProfiles(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	db, err := gorm.Open(gormMysql.New(gormMysql.Config{
+		Conn:                      mockDB,
+		SkipInitializeWithVersion: true,
+	}), &gorm.Config{
+		DisableAutomaticPing:   true,
+		SkipDefaultTransaction: true,
+	})
+	require.NoError(t, err)
+	c := &memoryProfileCache{profiles: map[int64]domain.User{
+		1: {Id: 1, Nickname: "Tom"},
+	}}
+	svc := NewUserService(repository.NewUserRepository(dao.NewUserDAO(db), c),
+		nil, nil, SensitiveModeReject, nil, nil)
+	ctx := context.Background()
+
+	// 1 在缓存里面，2 和 3 一条 SQL 查，3 不存在。重复的 id 和 0 都去掉
+	mock.ExpectQuery("SELECT \\* FROM `users` WHERE id IN \\(\\?,\\?\\)").
+		WithArgs(int64(2), int64(3), dao.UserStatusActive).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "nickname"}).AddRow(2, "Jerry"))
+	us, err := svc.GetProfiles(ctx, []int64{1, 2, 2, 3, 1, 0})
+	require.NoError(t, err)
+	assert.Equal(t, map[int64]domain.User{
+		1: {Id: 1, Nickname: "Tom"},
+		2: {Id: 2, Nickname: "Jerry"},
+	}, us)
+	// 查到的回写了缓存，不存在的没有
+	assert.Contains(t, c.profiles, int64(2))
+	assert.NotContains(t, c.profiles, int64(3))
+
+	// 第二次只有 3 要查数据库
+	mock.ExpectQuery("SELECT \\* FROM `users` WHERE id IN \\(\\?\\)").
+		WithArgs(int64(3), dao.UserStatusActive).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "nickname"}))
+	us, err = svc.GetProfiles(ctx, []int64{3, 2, 1})
+	require.NoError(t, err)
+	assert.Len(t, us, 2)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUserService_GetProfilesTooLarge(t *testing.T) {
+	svc := NewUserService(nil, nil, nil, SensitiveModeReject, nil, nil)
+	ids := make([]int64, 0, MaxProfileBatch+1)
+	for i := 1; i <= MaxProfileBatch+1; i++ {
+		ids = append(ids, int64(i))
+	}
+	_, err := svc.GetProfiles(context.Background(), ids)
+	assert.Equal(t, ErrProfileBatchTooLarge, err)
+}
@@ -0,0 +1,95 @@
+package service
+
+import (
+	"context"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	gormMysql "gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"testing"
+	"webook/internal/domain"
+	"webook/internal/repository"
+	"webook/internal/repository/cache"
+	"webook/internal/repository/dao"
+)
+
+// memoryProfileCache 只实现了公开资料的缓存，用到别的方法会 panic
+type memoryProfileCache struct {
+	cache.UserCache
+	profiles map[int64]domain.User
+}
+
+func (c *memoryProfileCache) GetProfiles(ctx context.Context, ids []int64) (map[int64]domain.User, error) {
+	res := make(map[int64]domain.User, len(ids))
+	for _, id := range ids {
+		if u, ok := c.profiles[id]; ok {
+			res[id] = u
+		}
+	}
+	return res, nil
+}
+
+func (c *memoryProfileCache) SetProfiles(ctx context.Context, us []domain.User) error {
+	for _, u := range us {
+		c.profiles[u.Id] = u
+	}
+	return nil
+}
+
+func (c *memoryProfileCache) DelProfile(ctx context.Context, id int64) error {
+	delete(c.profiles, id)
+	return nil
+}
+
+// Del 用户自己看的资料这里没有缓存
+func (c *memoryProfileCache) Del(ctx context.Context, id int64) error {
+	return nil
+}
+
+func TestUserService_RefreshProfile(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	db, err := gorm.Open(gormMysql.New(gormMysql.Config{
+		Conn:                      mockDB,
+		SkipInitializeWithVersion: true,
+	}), &gorm.Config{
+		DisableAutomaticPing:   true,
+		SkipDefaultTransaction: true,
+	})
+	require.NoError(t, err)
+	c := &memoryProfileCache{profiles: map[int64]domain.User{}}
+	svc := NewUserService(repository.NewUserRepository(dao.NewUserDAO(db), c),
+		nil, nil, SensitiveModeReject, nil, nil)
+	ctx := context.Background()
+	query := "SELECT \\* FROM `users` WHERE id IN \\(\\?\\) AND status = \\?"
+
+	// 第一次查数据库，回写缓存
+	mock.ExpectQuery(query).WillReturnRows(sqlmock.NewRows([]string{"id", "nickname"}).AddRow(3, "Tom"))
+	us, err := svc.GetProfiles(ctx, []int64{3})
+	require.NoError(t, err)
+	assert.Equal(t, "Tom", us[3].Nickname)
+
+	// 管理工具直接改了数据库，缓存里面还是旧的
+	us, err = svc.GetProfiles(ctx, []int64{3})
+	require.NoError(t, err)
+	assert.Equal(t, "Tom", us[3].Nickname)
+
+	// 刷新的时候不看缓存
+	mock.ExpectQuery(query).WillReturnRows(sqlmock.NewRows([]string{"id", "nickname"}).AddRow(3, "Jerry"))
+	u, err := svc.RefreshProfile(ctx, 3)
+	require.NoError(t, err)
+	assert.Equal(t, "Jerry", u.Nickname)
+
+	// 之后从缓存里面拿到的就是新的了，不会再查数据库
+	us, err = svc.GetProfiles(ctx, []int64{3})
+	require.NoError(t, err)
+	assert.Equal(t, "Jerry", us[3].Nickname)
+
+	// 刷新的时候发现已经注销了，缓存也要删掉
+	mock.ExpectQuery(query).WillReturnRows(sqlmock.NewRows([]string{"id", "nickname"}))
+	_, err = svc.RefreshProfile(ctx, 3)
+	assert.Equal(t, ErrUserNotFound, err)
+	assert.NotContains(t, c.profiles, int64(3))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,212 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	gormMysql "gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"testing"
+	"webook/internal/domain"
+	"webook/internal/repository"
+	"webook/internal/repository/dao"
+	"webook/internal/service/disposable"
+	"webook/pkg/ratelimit"
+	limitmocks "webook/pkg/ratelimit/mocks"
+)
+
+func TestUserService_SignUpEmailChecks(t *testing.T) {
+	testCases := []struct {
+		name  string
+		mode  DisposableEmailMode
+		mock  func(ctrl *gomock.Controller) ratelimit.Limiter
+		email string
+		phone string
+
+		wantErr        error
+		wantInsert     bool
+		wantDisposable bool
+	}{
+		{
+			name:    "一次性邮箱，拒绝",
+			mode:    DisposableEmailReject,
+			email:   "tom@mailinator.com",
+			wantErr: ErrDisposableEmail,
+		},
+		{
+			name:           "一次性邮箱，打标记",
+			mode:           DisposableEmailFlag,
+			email:          "tom@Sub.Mailinator.com",
+			wantInsert:     true,
+			wantDisposable: true,
+		},
+		{
+			name:       "不检查一次性邮箱",
+			email:      "tom@mailinator.com",
+			wantInsert: true,
+		},
+		{
+			name:  "域名注册太频繁",
+			mode:  DisposableEmailReject,
+			email: "tom@QQ.com",
+			mock: func(ctrl *gomock.Controller) ratelimit.Limiter {
+				l := limitmocks.NewMockLimiter(ctrl)
+				l.EXPECT().Limit(gomock.Any(), "signup:domain:qq.com").Return(true, nil)
+				return l
+			},
+			wantErr: ErrSignupTooFrequent,
+		},
+		{
+			name:  "没有触发限流",
+			mode:  DisposableEmailReject,
+			email: "tom@qq.com",
+			mock: func(ctrl *gomock.Controller) ratelimit.Limiter {
+				l := limitmocks.NewMockLimiter(ctrl)
+				l.EXPECT().Limit(gomock.Any(), "signup:domain:qq.com").Return(false, nil)
+				return l
+			},
+			wantInsert: true,
+		},
+		{
+			name:  "限流器出错了还是能注册",
+			email: "tom@qq.com",
+			mock: func(ctrl *gomock.Controller) ratelimit.Limiter {
+				l := limitmocks.NewMockLimiter(ctrl)
+				l.EXPECT().Limit(gomock.Any(), gomock.Any()).Return(false, errors.New("mock redis 错误"))
+				return l
+			},
+			wantInsert: true,
+		},
+		{
+			name:  "没有邮箱不检查",
+			mode:  DisposableEmailReject,
+			phone: "15212345678",
+			mock: func(ctrl *gomock.Controller) ratelimit.Limiter {
+				return limitmocks.NewMockLimiter(ctrl)
+			},
+			wantInsert: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			var inserted *dao.User
+			db := dryRunUserDB(t, func(u *dao.User) {
+				inserted = u
+			})
+			opts := []UserServiceOption{WithDisposableEmailCheck(disposable.NewList(), tc.mode)}
+			if tc.mock != nil {
+				opts = append(opts, WithSignupDomainLimiter(tc.mock(ctrl)))
+			}
+			svc := NewUserService(repository.NewUserRepository(dao.NewUserDAO(db), nil),
+				nil, nil, SensitiveModeReject, nil, nil, opts...)
+
+			err := svc.SignUp(context.Background(), domain.User{
+				Email:    tc.email,
+				Phone:    tc.phone,
+				Password: "hello#world123",
+			})
+			assert.Equal(t, tc.wantErr, err)
+			if !tc.wantInsert {
+				assert.Nil(t, inserted)
+				return
+			}
+			require.NotNil(t, inserted)
+			assert.Equal(t, tc.wantDisposable, inserted.DisposableEmail)
+		})
+	}
+}
+
+// dryRunUserDB 不真的执行 SQL，查询都当作查不到，插入的时候把 dao.User 交给 onCreate
+func dryRunUserDB(t *testing.T, onCreate func(u *dao.User)) *gorm.DB {
+	mockDB, _, err := sqlmock.New()
+	require.NoError(t, err)
+	db, err := gorm.Open(gormMysql.New(gormMysql.Config{
+		Conn:                      mockDB,
+		SkipInitializeWithVersion: true,
+	}), &gorm.Config{
+		DryRun:                 true,
+		DisableAutomaticPing:   true,
+		SkipDefaultTransaction: true,
+	})
+	require.NoError(t, err)
+	err = db.Callback().Create().Before("gorm:create").Register("test:capture", func(db *gorm.DB) {
+		if u, ok := db.Statement.Dest.(*dao.User); ok {
+			onCreate(u)
+		}
+	})
+	require.NoError(t, err)
+	err = db.Callback().Query().After("gorm:query").Register("test:not_found", func(db *gorm.DB) {
+		_ = db.AddError(gorm.ErrRecordNotFound)
+	})
+	require.NoError(t, err)
+	return db
+}
+
+func TestUserService_SignUpCaseInsensitiveEmail(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	db, err := gorm.Open(gormMysql.New(gormMysql.Config{
+		Conn:                      mockDB,
+		SkipInitializeWithVersion: true,
+	}), &gorm.Config{
+		DisableAutomaticPing:   true,
+		SkipDefaultTransaction: true,
+	})
+	require.NoError(t, err)
+	svc := NewUserService(repository.NewUserRepository(dao.NewUserDAO(db), nil),
+		nil, nil, SensitiveModeReject, nil, nil)
+	const findSQL = "SELECT \\* FROM `users` WHERE LOWER\\(email\\) = LOWER\\(\\?\\)"
+
+	mock.ExpectQuery(findSQL).WithArgs("user@test.com").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectExec("INSERT INTO `users` .*").WillReturnResult(sqlmock.NewResult(1, 1))
+	err = svc.SignUp(context.Background(), domain.User{Email: "user@test.com", Password: "hello#world123"})
+	require.NoError(t, err)
+
+	// 大写的再注册一次，查的时候就查到了，不会再插入
+	mock.ExpectQuery(findSQL).WithArgs("user@test.com").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "email"}).AddRow(1, "user@test.com"))
+	err = svc.SignUp(context.Background(), domain.User{Email: "USER@TEST.COM", Password: "hello#world123"})
+	assert.Equal(t, ErrUserDuplicateEmail, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// 存进去的和登录的时候查的都是小写的
+func TestUserService_EmailLowercase(t *testing.T) {
+	var inserted *dao.User
+	db := dryRunUserDB(t, func(u *dao.User) {
+		inserted = u
+	})
+	svc := NewUserService(repository.NewUserRepository(dao.NewUserDAO(db), nil),
+		nil, nil, SensitiveModeReject, nil, nil)
+	err := svc.SignUp(context.Background(), domain.User{Email: "Tom@QQ.com", Password: "hello#world123"})
+	require.NoError(t, err)
+	require.NotNil(t, inserted)
+	assert.Equal(t, "tom@qq.com", inserted.Email.String)
+
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	mock.ExpectQuery("SELECT \\* FROM `users` WHERE email = \\?").WithArgs("tom@qq.com").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	// 查不到还会看一下是不是注销了的
+	mock.ExpectQuery("SELECT \\* FROM `users` WHERE original_email = \\?.*").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	loginDB, err := gorm.Open(gormMysql.New(gormMysql.Config{
+		Conn:                      mockDB,
+		SkipInitializeWithVersion: true,
+	}), &gorm.Config{
+		DisableAutomaticPing:   true,
+		SkipDefaultTransaction: true,
+	})
+	require.NoError(t, err)
+	svc = NewUserService(repository.NewUserRepository(dao.NewUserDAO(loginDB), nil),
+		nil, nil, SensitiveModeReject, nil, nil)
+	_, err = svc.Login(context.Background(), "TOM@qq.com", "hello#world123")
+	assert.Equal(t, ErrInvalidUserOrPassword, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,72 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+	"webook/internal/domain"
+)
+
+// UserStatsCacheTTL 统计是全表扫的，后台首页刷新再多也最多一分钟查一次
+const UserStatsCacheTTL = time.Minute
+
+// UserStatsRepository *repository.UserRepository 实现了这个接口
+type UserStatsRepository interface {
+	CountByStatus(ctx context.Context) (map[string]int64, error)
+	CountRegistrationsInPeriod(ctx context.Context, start, end time.Time) (int64, error)
+}
+
+// UserStatsService 后台首页的用户统计
+type UserStatsService interface {
+	UserStats(ctx context.Context) (domain.UserStats, error)
+}
+
+// userStatsService 缓存在进程里面，多个实例各查各的
+type userStatsService struct {
+	repo UserStatsRepository
+	now  func() time.Time
+
+	// 查询的时候也拿着锁，缓存过期的时候同时来的请求只查一次
+	mutex    sync.Mutex
+	stats    domain.UserStats
+	expireAt time.Time
+}
+
+func NewUserStatsService(repo UserStatsRepository) UserStatsService {
+	return &userStatsService{
+		repo: repo,
+		now:  time.Now,
+	}
+}
+
+func (svc *userStatsService) UserStats(ctx context.Context) (domain.UserStats, error) {
+	svc.mutex.Lock()
+	defer svc.mutex.Unlock()
+	now := svc.now()
+	if now.Before(svc.expireAt) {
+		return svc.stats, nil
+	}
+	cnts, err := svc.repo.CountByStatus(ctx)
+	if err != nil {
+		return domain.UserStats{}, err
+	}
+	// 服务器时区的今天
+	y, m, d := now.Date()
+	today := time.Date(y, m, d, 0, 0, 0, 0, now.Location())
+	registrations, err := svc.repo.CountRegistrationsInPeriod(ctx, today, now)
+	if err != nil {
+		return domain.UserStats{}, err
+	}
+	// 出错了不缓存，下一次再查
+	svc.stats = domain.UserStats{
+		Active:             cnts[domain.UserStatusActive.String()],
+		Suspended:          cnts[domain.UserStatsSuspended],
+		Deleted:            cnts[domain.UserStatusDeleted.String()],
+		Merged:             cnts[domain.UserStatusMerged.String()],
+		Deactivated:        cnts[domain.UserStatusDeactivated.String()],
+		RegistrationsToday: registrations,
+		At:                 now,
+	}
+	svc.expireAt = now.Add(UserStatsCacheTTL)
+	return svc.stats, nil
+}
@@ -0,0 +1,53 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"testing"
+	"time"
+	"webook/internal/domain"
+	svcmocks "webook/internal/service/mocks"
+)
+
+func TestUserStatsService_UserStats(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	loc := time.FixedZone("", 8*3600)
+	now := time.Date(2024, 3, 15, 10, 30, 0, 0, loc)
+	today := time.Date(2024, 3, 15, 0, 0, 0, 0, loc)
+
+	repo := svcmocks.NewMockUserStatsRepository(ctrl)
+	svc := NewUserStatsService(repo).(*userStatsService)
+	svc.now = func() time.Time { return now }
+
+	// 第一次查数据库
+	repo.EXPECT().CountByStatus(gomock.Any()).Return(map[string]int64{
+		"active": 1234, "suspended": 5, "deleted": 89,
+	}, nil)
+	repo.EXPECT().CountRegistrationsInPeriod(gomock.Any(), today, now).Return(int64(12), nil)
+	stats, err := svc.UserStats(context.Background())
+	require.NoError(t, err)
+	want := domain.UserStats{Active: 1234, Suspended: 5, Deleted: 89, RegistrationsToday: 12, At: now}
+	assert.Equal(t, want, stats)
+
+	// 一分钟之内用缓存，mock 没有再设置期望，调了就会失败
+	now = now.Add(59 * time.Second)
+	stats, err = svc.UserStats(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, want, stats)
+
+	// 过期了重新查，出错了不缓存
+	now = now.Add(2 * time.Second)
+	repo.EXPECT().CountByStatus(gomock.Any()).Return(nil, errors.New("mock db 错误"))
+	_, err = svc.UserStats(context.Background())
+	assert.Equal(t, errors.New("mock db 错误"), err)
+
+	repo.EXPECT().CountByStatus(gomock.Any()).Return(map[string]int64{"active": 1235}, nil)
+	repo.EXPECT().CountRegistrationsInPeriod(gomock.Any(), today, now).Return(int64(13), nil)
+	stats, err = svc.UserStats(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, domain.UserStats{Active: 1235, RegistrationsToday: 13, At: now}, stats)
+}
@@ -0,0 +1,585 @@
+package web
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+	"webook/internal/domain"
+	"webook/internal/repository/cache"
+	"webook/internal/service"
+	"webook/internal/service/sensitive"
+	"webook/pkg/mask"
+	"webook/pkg/pagination"
+)
+
+// CodeCacheConfig 运行时查看和修改验证码的限制，*cache.RedisCodeCache 实现了这个接口
+type CodeCacheConfig interface {
+	Options() cache.CodeCacheOptions
+	SetOptions(opts cache.CodeCacheOptions) error
+}
+
+// RedisPoolStats Redis 连接池的情况，*cache.RedisCodeCache 实现了这个接口
+type RedisPoolStats interface {
+	PoolStats() redis.PoolStats
+}
+
+// AdminHandler 管理后台的接口，权限由 AdminMiddlewareBuilder 校验
+type AdminHandler struct {
+	userSvc      *service.UserService
+	filter       sensitive.Filter
+	codeCacheCfg CodeCacheConfig
+	redisStats   RedisPoolStats
+	statsSvc     service.UserStatsService
+}
+
+func NewAdminHandler(userSvc *service.UserService, filter sensitive.Filter,
+	codeCacheCfg CodeCacheConfig, redisStats RedisPoolStats,
+	statsSvc service.UserStatsService) *AdminHandler {
+	return &AdminHandler{
+		userSvc:      userSvc,
+		filter:       filter,
+		codeCacheCfg: codeCacheCfg,
+		redisStats:   redisStats,
+		statsSvc:     statsSvc,
+	}
+}
+
+func (h *AdminHandler) RegisterRoutes(server *gin.Engine) {
+	ag := server.Group("/admin")
+	ag.GET("/users", h.ListUsers)
+	ag.POST("/users/:id/nickname", h.UpdateNickname)
+	ag.POST("/users/:id/refresh_cache", h.RefreshCache)
+	ag.POST("/users/:id/ban", h.Ban)
+	ag.POST("/users/:id/unban", h.Unban)
+	ag.POST("/users/:id/merge", h.MergeAccounts)
+	ag.POST("/users/:id/impersonate", h.Impersonate)
+	ag.POST("/sensitive_words", h.AddSensitiveWords)
+	ag.GET("/config/code_cache", h.CodeCacheOptions)
+	ag.PUT("/config/code_cache", h.SetCodeCacheOptions)
+	ag.GET("/redis/stats", h.RedisStats)
+	ag.GET("/stats/users", h.UserStats)
+}
+
+// UpdateNickname 管理员改昵称，不受 30 天冷却期限制
+// 请求体：JSON
+func (h *AdminHandler) UpdateNickname(ctx *gin.Context) {
+	type Req struct {
+		Nickname string `json:"nickname"`
+	}
+	var req Req
+	if err := bindJSON(ctx, &req); err != nil {
+		return
+	}
+	uid, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  "输入有误",
+		})
+		return
+	}
+	if utf8.RuneCountInString(req.Nickname) > 255 {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  "昵称不超过255个字符",
+		})
+		return
+	}
+	err = h.userSvc.AdminUpdateNickname(ctx, uid, req.Nickname)
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 5,
+			Msg:  "系统错误",
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, Result{
+		Msg: "修改成功",
+	})
+}
+
+// RefreshCache 直接改了数据库之后，把这个用户缓存的公开资料刷新掉
+func (h *AdminHandler) RefreshCache(ctx *gin.Context) {
+	uid, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  "输入有误",
+		})
+		return
+	}
+	_, err = h.userSvc.RefreshProfile(ctx, uid)
+	switch err {
+	case nil:
+		ctx.JSON(http.StatusOK, Result{
+			Msg: "刷新成功",
+		})
+	case service.ErrUserNotFound:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  "用户不存在",
+		})
+	default:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 5,
+			Msg:  "系统错误",
+		})
+	}
+}
+
+// AddSensitiveWords 追加敏感词，立刻生效。
+// 只存在内存里面，重启之后或者别的实例上都没有，要长期生效的还是加到内置词库里面
+// 请求体：JSON
+func (h *AdminHandler) AddSensitiveWords(ctx *gin.Context) {
+	type Req struct {
+		Words []string `json:"words"`
+	}
+	var req Req
+	if err := bindJSON(ctx, &req); err != nil {
+		return
+	}
+	words := make([]string, 0, len(req.Words))
+	for _, w := range req.Words {
+		w = strings.TrimSpace(w)
+		if w != "" {
+			words = append(words, w)
+		}
+	}
+	if len(words) == 0 {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  "输入有误",
+		})
+		return
+	}
+	h.filter.AddWords(words...)
+	ctx.JSON(http.StatusOK, Result{
+		Msg: "添加成功",
+	})
+}
+
+// codeCacheOptionsVO 时间都用秒
+type codeCacheOptionsVO struct {
+	TTLSeconds        int64 `json:"ttl_seconds"`
+	CooldownSeconds   int64 `json:"cooldown_seconds"`
+	MaxVerifyAttempts int   `json:"max_verify_attempts"`
+}
+
+// CodeCacheOptions 当前实例上验证码的过期时间、发送间隔和验证次数
+func (h *AdminHandler) CodeCacheOptions(ctx *gin.Context) {
+	opts := h.codeCacheCfg.Options()
+	ctx.JSON(http.StatusOK, Result{
+		Data: codeCacheOptionsVO{
+			TTLSeconds:        int64(opts.TTL / time.Second),
+			CooldownSeconds:   int64(opts.Cooldown / time.Second),
+			MaxVerifyAttempts: opts.MaxVerifyAttempts,
+		},
+	})
+}
+
+// SetCodeCacheOptions 整个替换掉，三个字段都要传。下一次发验证码就生效，不用重启。
+// 跟敏感词一样只改了当前这个实例，重启之后恢复配置文件里面的值
+// 请求体：JSON
+func (h *AdminHandler) SetCodeCacheOptions(ctx *gin.Context) {
+	var req codeCacheOptionsVO
+	if err := bindJSON(ctx, &req); err != nil {
+		return
+	}
+	err := h.codeCacheCfg.SetOptions(cache.CodeCacheOptions{
+		TTL:               time.Duration(req.TTLSeconds) * time.Second,
+		Cooldown:          time.Duration(req.CooldownSeconds) * time.Second,
+		MaxVerifyAttempts: req.MaxVerifyAttempts,
+	})
+	switch err {
+	case nil:
+		ctx.JSON(http.StatusOK, Result{
+			Msg: "修改成功",
+		})
+	case cache.ErrInvalidCodeCacheOptions:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  "过期时间和发送间隔至少 1 秒，发送间隔要比过期时间短，验证次数至少 1 次",
+		})
+	default:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 5,
+			Msg:  "系统错误",
+		})
+	}
+}
+
+// Ban 封禁用户，until 不传就是永久封禁。被封禁的用户马上就会被踢下线
+// 请求体：JSON
+func (h *AdminHandler) Ban(ctx *gin.Context) {
+	type Req struct {
+		Reason string `json:"reason"`
+		// RFC3339，比如 2024-01-02T15:04:05+08:00
+		Until time.Time `json:"until"`
+	}
+	var req Req
+	if err := bindJSON(ctx, &req); err != nil {
+		return
+	}
+	uid, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  "输入有误",
+		})
+		return
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.Reason == "" || utf8.RuneCountInString(req.Reason) > 255 {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  "封禁原因必填，不超过255个字符",
+		})
+		return
+	}
+	operator, _ := ClaimsUserId(ctx)
+	err = h.userSvc.Ban(ctx, operator, uid, req.Reason, req.Until)
+	switch err {
+	case nil:
+		ctx.JSON(http.StatusOK, Result{
+			Msg: "封禁成功",
+		})
+	case service.ErrInvalidBanUntil:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  "解封时间必须晚于现在",
+		})
+	case service.ErrUserNotFound:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  "用户不存在",
+		})
+	default:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 5,
+			Msg:  "系统错误",
+		})
+	}
+}
+
+// MergeAccounts 把 secondary_id 合并到路径里面的账号上，副账号会被踢下线
+// 请求体：JSON
+func (h *AdminHandler) MergeAccounts(ctx *gin.Context) {
+	type Req struct {
+		SecondaryId int64 `json:"secondary_id"`
+	}
+	var req Req
+	if err := bindJSON(ctx, &req); err != nil {
+		return
+	}
+	uid, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil || req.SecondaryId <= 0 {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  "输入有误",
+		})
+		return
+	}
+	operator, _ := ClaimsUserId(ctx)
+	err = h.userSvc.MergeAccounts(ctx, operator, uid, req.SecondaryId)
+	switch err {
+	case nil:
+		ctx.JSON(http.StatusOK, Result{
+			Msg: "合并成功",
+		})
+	case service.ErrMergeSameAccount:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  "不能把账号合并到自己",
+		})
+	case service.ErrUserNotFound:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  "用户不存在或者已经注销",
+		})
+	default:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 5,
+			Msg:  "系统错误",
+		})
+	}
+}
+
+// Unban 提前解封
+func (h *AdminHandler) Unban(ctx *gin.Context) {
+	uid, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  "输入有误",
+		})
+		return
+	}
+	operator, _ := ClaimsUserId(ctx)
+	err = h.userSvc.Unban(ctx, operator, uid)
+	switch err {
+	case nil:
+		ctx.JSON(http.StatusOK, Result{
+			Msg: "解封成功",
+		})
+	case service.ErrUserNotBanned:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  "用户没有被封禁",
+		})
+	case service.ErrUserNotFound:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  "用户不存在",
+		})
+	default:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 5,
+			Msg:  "系统错误",
+		})
+	}
+}
+
+const (
+	defaultUserListSize = 20
+	maxUserListSize     = 100
+)
+
+// adminUserVO 后台列表里面的邮箱和手机号都是打码的
+type adminUserVO struct {
+	Id       int64  `json:"id"`
+	Email    string `json:"email"`
+	Phone    string `json:"phone"`
+	Nickname string `json:"nickname"`
+	Status   string `json:"status"`
+	Banned   bool   `json:"banned"`
+	// 注册的时候用的是一次性邮箱
+	DisposableEmail bool `json:"disposable_email"`
+	// RFC3339，老数据没有记录注册时间的是空的
+	Ctime string `json:"ctime"`
+}
+
+type adminUserListVO struct {
+	Users []adminUserVO `json:"users"`
+	// 下一页从这里开始，没有下一页就是空的
+	NextCursor string `json:"next_cursor,omitempty"`
+	// with_total=true 的时候才有
+	Total *int64 `json:"total,omitempty"`
+}
+
+// ListUsers 查询参数：
+//   - page、size：浅的页可以直接用页码，超过 1000 条之后要用 cursor
+//   - cursor：上一页返回的 next_cursor，有的话忽略 page
+//   - email：邮箱包含这一段；phone：手机号完全一样；status：active 或者 deleted
+//   - ctime_start、ctime_end：注册时间，RFC3339，左闭右开
+//   - sort：id 或者 ctime，默认 id；order：asc 或者 desc，默认 desc
+//   - with_total：要不要总数，数据多了很慢
+func (h *AdminHandler) ListUsers(ctx *gin.Context) {
+	var req userListReq
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  "输入有误",
+		})
+		return
+	}
+	q, field, ok := req.toQuery()
+	if !ok {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  "输入有误",
+			Data: map[string]string{"field": field},
+		})
+		return
+	}
+
+	us, total, err := h.userSvc.ListUsers(ctx, q, req.WithTotal)
+	if err == service.ErrUserListPageTooDeep {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  "翻页太深了，请用 cursor 翻页",
+		})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 5,
+			Msg:  "系统错误",
+		})
+		return
+	}
+	now := time.Now()
+	res := adminUserListVO{Users: make([]adminUserVO, 0, len(us))}
+	for _, u := range us {
+		vo := adminUserVO{
+			Id:       u.Id,
+			Email:    mask.Email(u.Email),
+			Phone:    mask.Phone(u.Phone),
+			Nickname: u.Nickname,
+			Status:   u.Status.String(),
+			Banned:   u.Ban != nil && u.Ban.Active(now),
+
+			DisposableEmail: u.DisposableEmail,
+		}
+		if u.Ctime.UnixMilli() > 0 {
+			vo.Ctime = u.Ctime.Format(time.RFC3339)
+		}
+		res.Users = append(res.Users, vo)
+	}
+	// 满了一页才可能有下一页
+	if len(us) == q.Limit {
+		last := us[len(us)-1]
+		res.NextCursor = encodeUserCursor(domain.UserCursor{Id: last.Id, Ctime: last.Ctime})
+	}
+	if req.WithTotal {
+		res.Total = &total
+	}
+	ctx.JSON(http.StatusOK, Result{
+		Data: res,
+	})
+}
+
+// userListReq 后台用户列表的查询参数
+type userListReq struct {
+	Page       int    `form:"page"`
+	Size       int    `form:"size"`
+	Cursor     string `form:"cursor"`
+	Email      string `form:"email"`
+	Phone      string `form:"phone"`
+	Status     string `form:"status"`
+	CtimeStart string `form:"ctime_start"`
+	CtimeEnd   string `form:"ctime_end"`
+	Sort       string `form:"sort"`
+	Order      string `form:"order"`
+	WithTotal  bool   `form:"with_total"`
+}
+
+// toQuery 出错的时候返回是哪个参数不对
+func (r userListReq) toQuery() (domain.UserListQuery, string, bool) {
+	q := domain.UserListQuery{
+		Email: strings.TrimSpace(r.Email),
+		Phone: strings.TrimSpace(r.Phone),
+	}
+	switch {
+	case r.Size == 0:
+		q.Limit = defaultUserListSize
+	case r.Size < 0 || r.Size > maxUserListSize:
+		return q, "size", false
+	default:
+		q.Limit = r.Size
+	}
+	if r.Cursor != "" {
+		c, ok := decodeUserCursor(r.Cursor)
+		if !ok {
+			return q, "cursor", false
+		}
+		q.After = &c
+	} else if r.Page > 1 {
+		q.Offset = (r.Page - 1) * q.Limit
+	} else if r.Page < 0 {
+		return q, "page", false
+	}
+	if r.Status != "" {
+		s, ok := domain.ParseUserStatus(r.Status)
+		if !ok {
+			return q, "status", false
+		}
+		q.Status = &s
+	}
+	var err error
+	if r.CtimeStart != "" {
+		if q.CtimeStart, err = time.Parse(time.RFC3339, r.CtimeStart); err != nil {
+			return q, "ctime_start", false
+		}
+	}
+	if r.CtimeEnd != "" {
+		if q.CtimeEnd, err = time.Parse(time.RFC3339, r.CtimeEnd); err != nil {
+			return q, "ctime_end", false
+		}
+	}
+	switch domain.UserSortField(r.Sort) {
+	case "", domain.UserSortById:
+		q.SortBy = domain.UserSortById
+	case domain.UserSortByCtime:
+		q.SortBy = domain.UserSortByCtime
+	default:
+		return q, "sort", false
+	}
+	switch r.Order {
+	case "", "desc":
+		q.Desc = true
+	case "asc":
+	default:
+		return q, "order", false
+	}
+	return q, "", true
+}
+
+// encodeUserCursor 游标对前端是不透明的，换了排序字段之后旧的游标不要再用
+func encodeUserCursor(c domain.UserCursor) string {
+	return pagination.EncodeCursor(c)
+}
+
+func decodeUserCursor(s string) (domain.UserCursor, bool) {
+	c, err := pagination.DecodeCursor(s)
+	return c, err == nil
+}
+
+// redisStatsVO 当前这个实例的连接池，从启动到现在累计的
+type redisStatsVO struct {
+	// 从连接池里面拿到了空闲的连接
+	Hits uint32 `json:"hits"`
+	// 没有空闲的，新建了一个
+	Misses uint32 `json:"misses"`
+	// 等连接等到超时了，这个在涨就要调大 MaxOpenConns 了
+	Timeouts   uint32 `json:"timeouts"`
+	TotalConns uint32 `json:"total_conns"`
+	IdleConns  uint32 `json:"idle_conns"`
+	StaleConns uint32 `json:"stale_conns"`
+}
+
+// RedisStats 当前实例的 Redis 连接池
+func (h *AdminHandler) RedisStats(ctx *gin.Context) {
+	stats := h.redisStats.PoolStats()
+	ctx.JSON(http.StatusOK, Result{
+		Data: redisStatsVO{
+			Hits:       stats.Hits,
+			Misses:     stats.Misses,
+			Timeouts:   stats.Timeouts,
+			TotalConns: stats.TotalConns,
+			IdleConns:  stats.IdleConns,
+			StaleConns: stats.StaleConns,
+		},
+	})
+}
+
+type userStatsVO struct {
+	Active             int64 `json:"active"`
+	Suspended          int64 `json:"suspended"`
+	Deleted            int64 `json:"deleted"`
+	Merged             int64 `json:"merged"`
+	Deactivated        int64 `json:"deactivated"`
+	RegistrationsToday int64 `json:"registrations_today"`
+}
+
+// UserStats 各个状态的用户数和今天的注册数，服务里面缓存了一分钟，不是实时的
+func (h *AdminHandler) UserStats(ctx *gin.Context) {
+	stats, err := h.statsSvc.UserStats(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result{Code: 5, Msg: "系统错误"})
+		return
+	}
+	ctx.JSON(http.StatusOK, Result{
+		Data: userStatsVO{
+			Active:             stats.Active,
+			Suspended:          stats.Suspended,
+			Deleted:            stats.Deleted,
+			Merged:             stats.Merged,
+			Deactivated:        stats.Deactivated,
+			RegistrationsToday: stats.RegistrationsToday,
+		},
+	})
+}
@@ -0,0 +1,216 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	gormMysql "gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"webook/internal/domain"
+	"webook/internal/repository"
+	"webook/internal/repository/dao"
+	"webook/internal/service"
+	svcmocks "webook/internal/service/mocks"
+)
+
+func TestUserListReq_toQuery(t *testing.T) {
+	deleted := domain.UserStatusDeleted
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.FixedZone("", 8*3600))
+	cursor := encodeUserCursor(domain.UserCursor{Id: 100, Ctime: time.UnixMilli(1500)})
+	testCases := []struct {
+		name string
+		req  userListReq
+
+		wantQuery domain.UserListQuery
+		wantField string
+	}{
+		{
+			name: "默认值",
+			wantQuery: domain.UserListQuery{
+				SortBy: domain.UserSortById, Desc: true, Limit: defaultUserListSize,
+			},
+		},
+		{
+			name: "页码",
+			req:  userListReq{Page: 3, Size: 50},
+			wantQuery: domain.UserListQuery{
+				SortBy: domain.UserSortById, Desc: true, Offset: 100, Limit: 50,
+			},
+		},
+		{
+			name: "游标优先于页码",
+			req:  userListReq{Page: 3, Cursor: cursor, Sort: "ctime", Order: "asc"},
+			wantQuery: domain.UserListQuery{
+				SortBy: domain.UserSortByCtime, Limit: defaultUserListSize,
+				After: &domain.UserCursor{Id: 100, Ctime: time.UnixMilli(1500)},
+			},
+		},
+		{
+			name: "过滤条件",
+			req: userListReq{Email: " qq.com ", Phone: "15212345678", Status: "deleted",
+				CtimeStart: "2024-01-01T00:00:00+08:00", CtimeEnd: "2024-02-01T00:00:00+08:00"},
+			wantQuery: domain.UserListQuery{
+				Email: "qq.com", Phone: "15212345678", Status: &deleted,
+				CtimeStart: start, CtimeEnd: start.AddDate(0, 1, 0),
+				SortBy: domain.UserSortById, Desc: true, Limit: defaultUserListSize,
+			},
+		},
+		{name: "size 太大", req: userListReq{Size: maxUserListSize + 1}, wantField: "size"},
+		{name: "page 是负数", req: userListReq{Page: -1}, wantField: "page"},
+		{name: "游标不对", req: userListReq{Cursor: "abc"}, wantField: "cursor"},
+		{name: "状态不对", req: userListReq{Status: "banned"}, wantField: "status"},
+		{name: "时间格式不对", req: userListReq{CtimeStart: "2024-01-01"}, wantField: "ctime_start"},
+		{name: "结束时间格式不对", req: userListReq{CtimeEnd: "2024-01-01"}, wantField: "ctime_end"},
+		{name: "不能按密码排", req: userListReq{Sort: "password"}, wantField: "sort"},
+		{name: "顺序不对", req: userListReq{Order: "up"}, wantField: "order"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			q, field, ok := tc.req.toQuery()
+			assert.Equal(t, tc.wantField, field)
+			assert.Equal(t, tc.wantField == "", ok)
+			if ok {
+				assert.Equal(t, tc.wantQuery, q)
+			}
+		})
+	}
+}
+
+func TestUserCursor(t *testing.T) {
+	c := domain.UserCursor{Id: 123, Ctime: time.UnixMilli(1700000000123)}
+	res, ok := decodeUserCursor(encodeUserCursor(c))
+	require.True(t, ok)
+	assert.Equal(t, c, res)
+
+	_, ok = decodeUserCursor("not base64!")
+	assert.False(t, ok)
+}
+
+func TestAdminHandler_ListUsers(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	mock.ExpectQuery("SELECT \\* FROM `users` WHERE id < \\? ORDER BY id DESC LIMIT 2").
+		WithArgs(int64(10)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "email", "phone", "nickname", "status", "ctime", "original_email"}).
+			AddRow(9, "zhangsan@qq.com", "15212345678", "Tom", 0, int64(1700000000000), "").
+			// 注销了的展示注销之前的邮箱
+			AddRow(8, nil, nil, "", 1, int64(0), "lisi@qq.com"))
+	db, err := gorm.Open(gormMysql.New(gormMysql.Config{
+		Conn:                      mockDB,
+		SkipInitializeWithVersion: true,
+	}), &gorm.Config{
+		DisableAutomaticPing:   true,
+		SkipDefaultTransaction: true,
+	})
+	require.NoError(t, err)
+	svc := service.NewUserService(repository.NewUserRepository(dao.NewUserDAO(db), nil),
+		nil, nil, service.SensitiveModeReject, nil, nil)
+	server := gin.New()
+	NewAdminHandler(svc, nil, nil, nil, nil).RegisterRoutes(server)
+
+	cursor := encodeUserCursor(domain.UserCursor{Id: 10})
+	req, err := http.NewRequest(http.MethodGet, "/admin/users?size=2&cursor="+cursor, nil)
+	require.NoError(t, err)
+	resp := httptest.NewRecorder()
+	server.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusOK, resp.Code)
+
+	var res struct {
+		Code int             `json:"code"`
+		Data json.RawMessage `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &res))
+	assert.Equal(t, 0, res.Code)
+	var data map[string]any
+	require.NoError(t, json.Unmarshal(res.Data, &data))
+	// 没有要总数就不返回
+	assert.NotContains(t, data, "total")
+	var list adminUserListVO
+	require.NoError(t, json.Unmarshal(res.Data, &list))
+	assert.Equal(t, []adminUserVO{
+		{
+			Id: 9, Email: "z***n@qq.com", Phone: "152****5678", Nickname: "Tom",
+			Status: "active", Ctime: time.UnixMilli(1700000000000).Format(time.RFC3339),
+		},
+		{Id: 8, Email: "l***i@qq.com", Status: "deleted"},
+	}, list.Users)
+	// 满了一页，从最后一条接着查
+	next, ok := decodeUserCursor(list.NextCursor)
+	require.True(t, ok)
+	assert.Equal(t, int64(8), next.Id)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+type fakeRedisPoolStats redis.PoolStats
+
+func (s fakeRedisPoolStats) PoolStats() redis.PoolStats {
+	return redis.PoolStats(s)
+}
+
+func TestAdminHandler_RedisStats(t *testing.T) {
+	server := gin.New()
+	NewAdminHandler(nil, nil, nil, fakeRedisPoolStats{
+		Hits: 10, Misses: 2, Timeouts: 1, TotalConns: 2, IdleConns: 1,
+	}, nil).RegisterRoutes(server)
+	req, err := http.NewRequest(http.MethodGet, "/admin/redis/stats", nil)
+	require.NoError(t, err)
+	resp := httptest.NewRecorder()
+	server.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusOK, resp.Code)
+	assert.JSONEq(t, `{"code":0,"msg":"","data":{"hits":10,"misses":2,"timeouts":1,
+		"total_conns":2,"idle_conns":1,"stale_conns":0}}`, resp.Body.String())
+}
+
+func TestAdminHandler_UserStats(t *testing.T) {
+	testCases := []struct {
+		name string
+		mock func(ctrl *gomock.Controller) service.UserStatsService
+
+		wantBody string
+	}{
+		{
+			name: "成功",
+			mock: func(ctrl *gomock.Controller) service.UserStatsService {
+				svc := svcmocks.NewMockUserStatsService(ctrl)
+				svc.EXPECT().UserStats(gomock.Any()).Return(domain.UserStats{
+					Active: 1234, Suspended: 5, Deleted: 89, Merged: 2, Deactivated: 7, RegistrationsToday: 12,
+				}, nil)
+				return svc
+			},
+			wantBody: `{"code":0,"msg":"","data":{"active":1234,"suspended":5,"deleted":89,
+				"merged":2,"deactivated":7,"registrations_today":12}}`,
+		},
+		{
+			name: "系统错误",
+			mock: func(ctrl *gomock.Controller) service.UserStatsService {
+				svc := svcmocks.NewMockUserStatsService(ctrl)
+				svc.EXPECT().UserStats(gomock.Any()).Return(domain.UserStats{}, errors.New("mock db 错误"))
+				return svc
+			},
+			wantBody: `{"code":5,"msg":"系统错误","data":null}`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			server := gin.New()
+			NewAdminHandler(nil, nil, nil, nil, tc.mock(ctrl)).RegisterRoutes(server)
+			req, err := http.NewRequest(http.MethodGet, "/admin/stats/users", nil)
+			require.NoError(t, err)
+			resp := httptest.NewRecorder()
+			server.ServeHTTP(resp, req)
+			require.Equal(t, http.StatusOK, resp.Code)
+			assert.JSONEq(t, tc.wantBody, resp.Body.String())
+		})
+	}
+}
@@ -0,0 +1,28 @@
+package web
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"time"
+)
+
+// AnonymousSessionCookie 登录之前的匿名 ID
+const AnonymousSessionCookie = "anon_id"
+
+func SetAnonymousSessionCookie(ctx *gin.Context, id string, ttl time.Duration) {
+	ctx.SetSameSite(http.SameSiteLaxMode)
+	ctx.SetCookie(AnonymousSessionCookie, id, int(ttl/time.Second), "/", "", true, true)
+}
+
+func AnonymousIDFromCookie(ctx *gin.Context) (string, bool) {
+	val, err := ctx.Cookie(AnonymousSessionCookie)
+	if err != nil || val == "" {
+		return "", false
+	}
+	return val, true
+}
+
+func ClearAnonymousSessionCookie(ctx *gin.Context) {
+	ctx.SetSameSite(http.SameSiteLaxMode)
+	ctx.SetCookie(AnonymousSessionCookie, "", -1, "/", "", true, true)
+}
@@ -0,0 +1,249 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	gormMysql "gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"webook/internal/repository"
+	"webook/internal/repository/cache"
+	cachemocks "webook/internal/repository/cache/mocks"
+	"webook/internal/repository/dao"
+	"webook/internal/service"
+	svcmocks "webook/internal/service/mocks"
+)
+
+func TestUserHandler_LoginSMSAnonymousSession(t *testing.T) {
+	testCases := []struct {
+		name   string
+		cookie string
+		mock   func(ctrl *gomock.Controller) cache.AnonymousSessionCache
+
+		wantAnonID string
+	}{
+		{
+			name:   "升级成功",
+			cookie: "anon-123",
+			mock: func(ctrl *gomock.Controller) cache.AnonymousSessionCache {
+				c := cachemocks.NewMockAnonymousSessionCache(ctrl)
+				c.EXPECT().Bind(gomock.Any(), "anon-123", int64(3)).Return(nil).Times(1)
+				return c
+			},
+			wantAnonID: "anon-123",
+		},
+		{
+			name:   "匿名会话过期了",
+			cookie: "anon-123",
+			mock: func(ctrl *gomock.Controller) cache.AnonymousSessionCache {
+				c := cachemocks.NewMockAnonymousSessionCache(ctrl)
+				c.EXPECT().Bind(gomock.Any(), "anon-123", int64(3)).
+					Return(cache.ErrAnonymousSessionNotFound).Times(1)
+				return c
+			},
+		},
+		{
+			name: "没有匿名会话",
+			mock: func(ctrl *gomock.Controller) cache.AnonymousSessionCache {
+				return cachemocks.NewMockAnonymousSessionCache(ctrl)
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			codeSvc := svcmocks.NewMockCodeService(ctrl)
+			codeSvc.EXPECT().VerifyWithResult(gomock.Any(), bizLogin, "15212345678", "123456").Return(service.VerifyResult{OK: true}, nil)
+			svc := service.NewUserService(repository.NewUserRepository(dao.NewUserDAO(mockFindUserDB(t, "phone",
+				sqlmock.NewRows([]string{"id", "phone"}).AddRow(3, "15212345678"))), nil),
+				nil, nil, service.SensitiveModeReject, nil, tc.mock(ctrl))
+			server := gin.New()
+			NewUserHandler(svc, codeSvc, nil, nil, nil).RegisterRoutes(server)
+
+			req, err := http.NewRequest(http.MethodPost, "/users/login_sms",
+				strings.NewReader(`{"phone":"15212345678","code":"123456"}`))
+			require.NoError(t, err)
+			req.Header.Set("Content-Type", "application/json")
+			if tc.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: AnonymousSessionCookie, Value: tc.cookie})
+			}
+			resp := httptest.NewRecorder()
+			server.ServeHTTP(resp, req)
+			require.Equal(t, http.StatusOK, resp.Code)
+
+			var res Result
+			require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &res))
+			assert.Equal(t, 0, res.Code)
+
+			claims := &UserClaims{}
+			_, err = jwt.ParseWithClaims(resp.Header().Get("x-jwt-token"), claims, func(token *jwt.Token) (interface{}, error) {
+				return []byte("95osj3fUD7fo0mlYdDbncXz4VD2igvf0"), nil
+			})
+			require.NoError(t, err)
+			assert.Equal(t, int64(3), claims.Uid)
+			assert.Equal(t, tc.wantAnonID, claims.AnonID)
+			require.NotNil(t, claims.IssuedAt)
+			require.NotNil(t, claims.NotBefore)
+			assert.Equal(t, claims.IssuedAt, claims.NotBefore)
+			// 有匿名会话的话，登录之后 cookie 要清掉
+			if tc.cookie != "" {
+				assert.Contains(t, resp.Header().Get("Set-Cookie"), AnonymousSessionCookie+"=;")
+			}
+		})
+	}
+}
+
+// mockFindUserDB 按 column 查用户的时候返回 rows
+func mockFindUserDB(t *testing.T, column string, rows *sqlmock.Rows) *gorm.DB {
+	db, _ := mockFindUser(t, column, rows)
+	return db
+}
+
+// mockFindUser 跟 mockFindUserDB 一样，后面还要设置别的 SQL 的用返回的 mock
+func mockFindUser(t *testing.T, column string, rows *sqlmock.Rows) (*gorm.DB, sqlmock.Sqlmock) {
+	db, mock := mockUserDB(t)
+	mock.ExpectQuery("SELECT .* FROM `users` WHERE " + column + " = .*").
+		WillReturnRows(rows)
+	return db, mock
+}
+
+// mockUserDB 什么 SQL 都没有设置，要查几次自己设置
+func mockUserDB(t *testing.T) (*gorm.DB, sqlmock.Sqlmock) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	db, err := gorm.Open(gormMysql.New(gormMysql.Config{
+		Conn:                      mockDB,
+		SkipInitializeWithVersion: true,
+	}), &gorm.Config{
+		DisableAutomaticPing:   true,
+		SkipDefaultTransaction: true,
+	})
+	require.NoError(t, err)
+	return db, mock
+}
+
+func TestUserHandler_AnonymousSession(t *testing.T) {
+	testCases := []struct {
+		name   string
+		cookie string
+		mock   func(ctrl *gomock.Controller) cache.AnonymousSessionCache
+
+		wantId     func(t *testing.T, id string)
+		wantCookie bool
+	}{
+		{
+			name:   "已经有了",
+			cookie: "anon-123",
+			mock: func(ctrl *gomock.Controller) cache.AnonymousSessionCache {
+				return cachemocks.NewMockAnonymousSessionCache(ctrl)
+			},
+			wantId: func(t *testing.T, id string) {
+				assert.Equal(t, "anon-123", id)
+			},
+		},
+		{
+			name: "新建",
+			mock: func(ctrl *gomock.Controller) cache.AnonymousSessionCache {
+				c := cachemocks.NewMockAnonymousSessionCache(ctrl)
+				c.EXPECT().Create(gomock.Any(), gomock.Any(), service.AnonymousSessionTTL).
+					DoAndReturn(func(ctx context.Context, id string, _ any) error {
+						assert.Len(t, id, 36)
+						return nil
+					})
+				return c
+			},
+			wantId: func(t *testing.T, id string) {
+				assert.Len(t, id, 36)
+			},
+			wantCookie: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			svc := service.NewUserService(nil, nil, nil, service.SensitiveModeReject, nil, tc.mock(ctrl))
+			server := gin.New()
+			NewUserHandler(svc, nil, nil, nil, nil).RegisterRoutes(server)
+
+			req, err := http.NewRequest(http.MethodGet, "/session/anonymous", nil)
+			require.NoError(t, err)
+			if tc.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: AnonymousSessionCookie, Value: tc.cookie})
+			}
+			resp := httptest.NewRecorder()
+			server.ServeHTTP(resp, req)
+			require.Equal(t, http.StatusOK, resp.Code)
+
+			var res struct {
+				Code int    `json:"code"`
+				Data string `json:"data"`
+			}
+			require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &res))
+			assert.Equal(t, 0, res.Code)
+			tc.wantId(t, res.Data)
+			if tc.wantCookie {
+				assert.Contains(t, resp.Header().Get("Set-Cookie"), AnonymousSessionCookie+"="+res.Data)
+			} else {
+				assert.Empty(t, resp.Header().Get("Set-Cookie"))
+			}
+		})
+	}
+}
+
+func TestGetAnonymousID(t *testing.T) {
+	testCases := []struct {
+		name   string
+		claims *UserClaims
+		cookie string
+
+		wantId string
+	}{
+		{
+			name:   "JWT 里面有",
+			claims: &UserClaims{Uid: 3, AnonID: "anon-jwt"},
+			cookie: "anon-cookie",
+			wantId: "anon-jwt",
+		},
+		{
+			name:   "JWT 里面没有，看 cookie",
+			claims: &UserClaims{Uid: 3},
+			cookie: "anon-cookie",
+			wantId: "anon-cookie",
+		},
+		{
+			name:   "没登录",
+			cookie: "anon-cookie",
+			wantId: "anon-cookie",
+		},
+		{
+			name: "都没有",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, "/", nil)
+			require.NoError(t, err)
+			if tc.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: AnonymousSessionCookie, Value: tc.cookie})
+			}
+			ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+			ctx.Request = req
+			if tc.claims != nil {
+				ctx.Set("claims", tc.claims)
+			}
+			assert.Equal(t, tc.wantId, GetAnonymousID(ctx))
+		})
+	}
+}
@@ -0,0 +1,159 @@
+package web
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+	"time"
+	"webook/internal/domain"
+	"webook/internal/service"
+)
+
+// ErrForbiddenForAPIKey 用 API key 调了管理 API key、管理后台这些接口，登录校验的中间件直接拒绝
+var ErrForbiddenForAPIKey = errors.New("API key 不能调用这个接口，请登录之后再试")
+
+// APIKeyHandler 管理机器调用用的 API key
+type APIKeyHandler struct {
+	svc service.APIKeyService
+}
+
+func NewAPIKeyHandler(svc service.APIKeyService) *APIKeyHandler {
+	return &APIKeyHandler{
+		svc: svc,
+	}
+}
+
+func (h *APIKeyHandler) RegisterRoutes(server *gin.Engine) {
+	ug := server.Group("/users/api_keys")
+	ug.POST("", h.Create)
+	ug.GET("", h.List)
+	ug.DELETE("/:id", h.Revoke)
+}
+
+type APIKeyVO struct {
+	Id         int64  `json:"id"`
+	Name       string `json:"name"`
+	CreatedAt  string `json:"created_at"`
+	ExpiresAt  string `json:"expires_at,omitempty"`
+	LastUsedAt string `json:"last_used_at,omitempty"`
+	// 只有创建的时候才有
+	Key string `json:"key,omitempty"`
+}
+
+// Create 请求体：JSON
+func (h *APIKeyHandler) Create(ctx *gin.Context) {
+	type Req struct {
+		Name string `json:"name"`
+		// 多少天之后过期，0 代表永不过期
+		ExpiresInDays int `json:"expires_in_days"`
+	}
+	var req Req
+	if err := bindJSON(ctx, &req); err != nil {
+		return
+	}
+	uid, ok := h.uid(ctx)
+	if !ok {
+		return
+	}
+	if req.Name == "" || req.ExpiresInDays < 0 {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  "输入有误",
+		})
+		return
+	}
+	ttl := time.Duration(req.ExpiresInDays) * time.Hour * 24
+	k, rawKey, err := h.svc.Create(ctx, uid, req.Name, ttl)
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 5,
+			Msg:  "系统错误",
+		})
+		return
+	}
+	vo := h.toVO(k)
+	vo.Key = rawKey
+	ctx.JSON(http.StatusOK, Result{
+		Msg:  "创建成功，请妥善保存，key 只会显示这一次",
+		Data: vo,
+	})
+}
+
+func (h *APIKeyHandler) List(ctx *gin.Context) {
+	uid, ok := h.uid(ctx)
+	if !ok {
+		return
+	}
+	ks, err := h.svc.List(ctx, uid)
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 5,
+			Msg:  "系统错误",
+		})
+		return
+	}
+	vos := make([]APIKeyVO, 0, len(ks))
+	for _, k := range ks {
+		vos = append(vos, h.toVO(k))
+	}
+	ctx.JSON(http.StatusOK, Result{
+		Data: vos,
+	})
+}
+
+func (h *APIKeyHandler) Revoke(ctx *gin.Context) {
+	uid, ok := h.uid(ctx)
+	if !ok {
+		return
+	}
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  "输入有误",
+		})
+		return
+	}
+	err = h.svc.Revoke(ctx, uid, id)
+	switch err {
+	case nil:
+		ctx.JSON(http.StatusOK, Result{
+			Msg: "删除成功",
+		})
+	case service.ErrAPIKeyNotFound:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  "API key 不存在",
+		})
+	default:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 5,
+			Msg:  "系统错误",
+		})
+	}
+}
+
+func (h *APIKeyHandler) uid(ctx *gin.Context) (int64, bool) {
+	uid, ok := ClaimsUserId(ctx)
+	if !ok {
+		// 你可以考虑监控住这里
+		ctx.String(http.StatusOK, "系统错误")
+	}
+	return uid, ok
+}
+
+func (h *APIKeyHandler) toVO(k domain.APIKey) APIKeyVO {
+	vo := APIKeyVO{
+		Id:        k.Id,
+		Name:      k.Name,
+		CreatedAt: k.CreatedAt.Format(time.RFC3339),
+	}
+	if !k.ExpiresAt.IsZero() {
+		vo.ExpiresAt = k.ExpiresAt.Format(time.RFC3339)
+	}
+	if !k.LastUsedAt.IsZero() {
+		vo.LastUsedAt = k.LastUsedAt.Format(time.RFC3339)
+	}
+	return vo
+}
@@ -0,0 +1,153 @@
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"webook/internal/domain"
+	"webook/internal/service"
+	svcmocks "webook/internal/service/mocks"
+)
+
+func TestAPIKeyHandler(t *testing.T) {
+	now := time.Now()
+	testCases := []struct {
+		name string
+		mock func(ctrl *gomock.Controller) service.APIKeyService
+
+		method  string
+		url     string
+		reqBody string
+
+		wantBody Result
+	}{
+		{
+			name: "创建成功",
+			mock: func(ctrl *gomock.Controller) service.APIKeyService {
+				svc := svcmocks.NewMockAPIKeyService(ctrl)
+				svc.EXPECT().Create(gomock.Any(), int64(123), "ci", time.Hour*24*30).
+					Return(domain.APIKey{Id: 1, Name: "ci", CreatedAt: now}, "apk_abc", nil)
+				return svc
+			},
+			method:  http.MethodPost,
+			url:     "/users/api_keys",
+			reqBody: `{"name":"ci","expires_in_days":30}`,
+			wantBody: Result{
+				Msg: "创建成功，请妥善保存，key 只会显示这一次",
+				Data: map[string]any{
+					"id":         float64(1),
+					"name":       "ci",
+					"created_at": now.Format(time.RFC3339),
+					"key":        "apk_abc",
+				},
+			},
+		},
+		{
+			name: "创建没有名字",
+			mock: func(ctrl *gomock.Controller) service.APIKeyService {
+				return svcmocks.NewMockAPIKeyService(ctrl)
+			},
+			method:   http.MethodPost,
+			url:      "/users/api_keys",
+			reqBody:  `{"name":""}`,
+			wantBody: Result{Code: 4, Msg: "输入有误"},
+		},
+		{
+			name: "创建系统错误",
+			mock: func(ctrl *gomock.Controller) service.APIKeyService {
+				svc := svcmocks.NewMockAPIKeyService(ctrl)
+				svc.EXPECT().Create(gomock.Any(), int64(123), "ci", time.Duration(0)).
+					Return(domain.APIKey{}, "", errors.New("mock 错误"))
+				return svc
+			},
+			method:   http.MethodPost,
+			url:      "/users/api_keys",
+			reqBody:  `{"name":"ci"}`,
+			wantBody: Result{Code: 5, Msg: "系统错误"},
+		},
+		{
+			name: "列表",
+			mock: func(ctrl *gomock.Controller) service.APIKeyService {
+				svc := svcmocks.NewMockAPIKeyService(ctrl)
+				svc.EXPECT().List(gomock.Any(), int64(123)).
+					Return([]domain.APIKey{{Id: 1, Name: "ci", CreatedAt: now, LastUsedAt: now}}, nil)
+				return svc
+			},
+			method: http.MethodGet,
+			url:    "/users/api_keys",
+			wantBody: Result{
+				Data: []any{
+					map[string]any{
+						"id":           float64(1),
+						"name":         "ci",
+						"created_at":   now.Format(time.RFC3339),
+						"last_used_at": now.Format(time.RFC3339),
+					},
+				},
+			},
+		},
+		{
+			name: "删除成功",
+			mock: func(ctrl *gomock.Controller) service.APIKeyService {
+				svc := svcmocks.NewMockAPIKeyService(ctrl)
+				svc.EXPECT().Revoke(gomock.Any(), int64(123), int64(1)).Return(nil)
+				return svc
+			},
+			method:   http.MethodDelete,
+			url:      "/users/api_keys/1",
+			wantBody: Result{Msg: "删除成功"},
+		},
+		{
+			name: "删除不存在的",
+			mock: func(ctrl *gomock.Controller) service.APIKeyService {
+				svc := svcmocks.NewMockAPIKeyService(ctrl)
+				svc.EXPECT().Revoke(gomock.Any(), int64(123), int64(2)).
+					Return(service.ErrAPIKeyNotFound)
+				return svc
+			},
+			method:   http.MethodDelete,
+			url:      "/users/api_keys/2",
+			wantBody: Result{Code: 4, Msg: "API key 不存在"},
+		},
+		{
+			name: "删除 id 不对",
+			mock: func(ctrl *gomock.Controller) service.APIKeyService {
+				return svcmocks.NewMockAPIKeyService(ctrl)
+			},
+			method:   http.MethodDelete,
+			url:      "/users/api_keys/abc",
+			wantBody: Result{Code: 4, Msg: "输入有误"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			server := gin.New()
+			server.Use(func(ctx *gin.Context) {
+				ctx.Set("claims", &UserClaims{Uid: 123})
+			})
+			NewAPIKeyHandler(tc.mock(ctrl)).RegisterRoutes(server)
+
+			req, err := http.NewRequest(tc.method, tc.url, bytes.NewBufferString(tc.reqBody))
+			require.NoError(t, err)
+			req.Header.Set("Content-Type", "application/json")
+			resp := httptest.NewRecorder()
+			server.ServeHTTP(resp, req)
+
+			assert.Equal(t, http.StatusOK, resp.Code)
+			var res Result
+			err = json.NewDecoder(resp.Body).Decode(&res)
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantBody, res)
+		})
+	}
+}
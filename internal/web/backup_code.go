@@ -0,0 +1,86 @@
+package web
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"webook/internal/service"
+)
+
+// BackupCodeHandler 管理 TOTP 的备用码
+type BackupCodeHandler struct {
+	svc    service.BackupCodeService
+	reauth Reauthenticator
+}
+
+func NewBackupCodeHandler(svc service.BackupCodeService, reauth Reauthenticator) *BackupCodeHandler {
+	return &BackupCodeHandler{
+		svc:    svc,
+		reauth: reauth,
+	}
+}
+
+func (h *BackupCodeHandler) RegisterRoutes(server *gin.Engine) {
+	ug := server.Group("/users/mfa/backup_codes")
+	ug.POST("/generate", h.Generate)
+	ug.GET("/count", h.Count)
+}
+
+// generateBackupCodesReq 跟 Deactivate 一样，有密码的输密码，手机号注册的用登录的短信验证码
+type generateBackupCodesReq struct {
+	Password string `json:"password"`
+	Code     string `json:"code"`
+}
+
+// Generate 以前生成的全部作废。备用码能绕过 TOTP，所以要再验证一次身份。请求体：JSON
+func (h *BackupCodeHandler) Generate(ctx *gin.Context) {
+	var req generateBackupCodesReq
+	if err := bindJSON(ctx, &req); err != nil {
+		return
+	}
+	uid, ok := h.uid(ctx)
+	if !ok {
+		return
+	}
+	if !h.reauth.Reauthenticate(ctx, uid, req.Password, req.Code) {
+		return
+	}
+	codes, err := h.svc.Generate(ctx, uid)
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 5,
+			Msg:  "系统错误",
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, Result{
+		Msg:  "生成成功，请妥善保存，备用码只会显示这一次",
+		Data: codes,
+	})
+}
+
+func (h *BackupCodeHandler) Count(ctx *gin.Context) {
+	uid, ok := h.uid(ctx)
+	if !ok {
+		return
+	}
+	cnt, err := h.svc.Count(ctx, uid)
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 5,
+			Msg:  "系统错误",
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, Result{
+		Data: map[string]int64{"remaining": cnt},
+	})
+}
+
+func (h *BackupCodeHandler) uid(ctx *gin.Context) (int64, bool) {
+	uid, ok := ClaimsUserId(ctx)
+	if !ok {
+		// 你可以考虑监控住这里
+		ctx.String(http.StatusOK, "系统错误")
+	}
+	return uid, ok
+}
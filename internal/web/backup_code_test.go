@@ -0,0 +1,113 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"webook/internal/service"
+	svcmocks "webook/internal/service/mocks"
+)
+
+func TestBackupCodeHandler(t *testing.T) {
+	testCases := []struct {
+		name string
+		mock func(ctrl *gomock.Controller) service.BackupCodeService
+
+		method     string
+		url        string
+		reauthFail bool
+
+		wantBody Result
+	}{
+		{
+			name: "生成成功",
+			mock: func(ctrl *gomock.Controller) service.BackupCodeService {
+				svc := svcmocks.NewMockBackupCodeService(ctrl)
+				svc.EXPECT().Generate(gomock.Any(), int64(123)).
+					Return([]string{"ABCDEFGH", "JKMNPQRS"}, nil)
+				return svc
+			},
+			method: http.MethodPost,
+			url:    "/users/mfa/backup_codes/generate",
+			wantBody: Result{
+				Msg:  "生成成功，请妥善保存，备用码只会显示这一次",
+				Data: []any{"ABCDEFGH", "JKMNPQRS"},
+			},
+		},
+		{
+			name: "生成失败",
+			mock: func(ctrl *gomock.Controller) service.BackupCodeService {
+				svc := svcmocks.NewMockBackupCodeService(ctrl)
+				svc.EXPECT().Generate(gomock.Any(), int64(123)).
+					Return(nil, errors.New("mock db 错误"))
+				return svc
+			},
+			method:   http.MethodPost,
+			url:      "/users/mfa/backup_codes/generate",
+			wantBody: Result{Code: 5, Msg: "系统错误"},
+		},
+		{
+			name: "没通过验证不会生成",
+			mock: func(ctrl *gomock.Controller) service.BackupCodeService {
+				return svcmocks.NewMockBackupCodeService(ctrl)
+			},
+			method:     http.MethodPost,
+			url:        "/users/mfa/backup_codes/generate",
+			reauthFail: true,
+			wantBody:   Result{Code: 4, Msg: "密码不对"},
+		},
+		{
+			name: "剩余个数",
+			mock: func(ctrl *gomock.Controller) service.BackupCodeService {
+				svc := svcmocks.NewMockBackupCodeService(ctrl)
+				svc.EXPECT().Count(gomock.Any(), int64(123)).Return(int64(7), nil)
+				return svc
+			},
+			method: http.MethodGet,
+			url:    "/users/mfa/backup_codes/count",
+			wantBody: Result{
+				Data: map[string]any{"remaining": float64(7)},
+			},
+		},
+		{
+			name: "查剩余个数失败",
+			mock: func(ctrl *gomock.Controller) service.BackupCodeService {
+				svc := svcmocks.NewMockBackupCodeService(ctrl)
+				svc.EXPECT().Count(gomock.Any(), int64(123)).Return(int64(0), errors.New("mock db 错误"))
+				return svc
+			},
+			method:   http.MethodGet,
+			url:      "/users/mfa/backup_codes/count",
+			wantBody: Result{Code: 5, Msg: "系统错误"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			server := gin.New()
+			server.Use(func(ctx *gin.Context) {
+				ctx.Set("claims", &UserClaims{Uid: 123})
+			})
+			NewBackupCodeHandler(tc.mock(ctrl), fakeReauth(!tc.reauthFail)).RegisterRoutes(server)
+
+			req, err := http.NewRequest(tc.method, tc.url, strings.NewReader(`{"password":"hello#world123"}`))
+			require.NoError(t, err)
+			req.Header.Set("Content-Type", "application/json")
+			resp := httptest.NewRecorder()
+			server.ServeHTTP(resp, req)
+
+			assert.Equal(t, http.StatusOK, resp.Code)
+			var res Result
+			require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &res))
+			assert.Equal(t, tc.wantBody, res)
+		})
+	}
+}
@@ -0,0 +1,34 @@
+package web
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"net/http"
+)
+
+// ctx.Bind 是根据 Content-Type 猜的，猜错了字段就是空的。下面这几个都是明确指定怎么解析，
+// 解析失败的时候跟 ctx.Bind 一样返回 400。
+// 放在查询参数里面的（比如 OAuth 回调带回来的 code 和 state），直接用 ctx.ShouldBindQuery，结构体打 form 标签
+
+// bindJSON 请求体只支持 JSON
+func bindJSON(ctx *gin.Context, req any) error {
+	return abortIfErr(ctx, ctx.ShouldBindWith(req, binding.JSON))
+}
+
+// bindJSONOrForm 请求体支持 JSON 和表单（application/x-www-form-urlencoded 或者 multipart/form-data），
+// 表单是给传统的 HTML 页面用的，结构体里面要同时打上 json 和 form 标签
+func bindJSONOrForm(ctx *gin.Context, req any) error {
+	var b binding.Binding = binding.JSON
+	switch ctx.ContentType() {
+	case binding.MIMEPOSTForm, binding.MIMEMultipartPOSTForm:
+		b = binding.Form
+	}
+	return abortIfErr(ctx, ctx.ShouldBindWith(req, b))
+}
+
+func abortIfErr(ctx *gin.Context, err error) error {
+	if err != nil {
+		_ = ctx.AbortWithError(http.StatusBadRequest, err).SetType(gin.ErrorTypeBind)
+	}
+	return err
+}
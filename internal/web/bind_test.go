@@ -0,0 +1,118 @@
+package web
+
+import (
+	"bytes"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestBindJSONOrForm(t *testing.T) {
+	type Req struct {
+		Email      string `json:"email" form:"email"`
+		Reactivate bool   `json:"reactivate" form:"reactivate"`
+	}
+	multipartBody := func() (string, *bytes.Buffer) {
+		body := &bytes.Buffer{}
+		w := multipart.NewWriter(body)
+		_ = w.WriteField("email", "123@qq.com")
+		_ = w.WriteField("reactivate", "true")
+		_ = w.Close()
+		return w.FormDataContentType(), body
+	}
+	testCases := []struct {
+		name        string
+		contentType string
+		body        func() (string, *bytes.Buffer)
+
+		wantCode int
+		wantReq  Req
+	}{
+		{
+			name: "JSON",
+			body: func() (string, *bytes.Buffer) {
+				return "application/json", bytes.NewBufferString(`{"email":"123@qq.com","reactivate":true}`)
+			},
+			wantCode: http.StatusOK,
+			wantReq:  Req{Email: "123@qq.com", Reactivate: true},
+		},
+		{
+			name: "没有 Content-Type 当成 JSON",
+			body: func() (string, *bytes.Buffer) {
+				return "", bytes.NewBufferString(`{"email":"123@qq.com"}`)
+			},
+			wantCode: http.StatusOK,
+			wantReq:  Req{Email: "123@qq.com"},
+		},
+		{
+			name: "表单",
+			body: func() (string, *bytes.Buffer) {
+				form := url.Values{"email": {"123@qq.com"}, "reactivate": {"true"}}
+				return "application/x-www-form-urlencoded", bytes.NewBufferString(form.Encode())
+			},
+			wantCode: http.StatusOK,
+			wantReq:  Req{Email: "123@qq.com", Reactivate: true},
+		},
+		{
+			name:     "multipart 表单",
+			body:     multipartBody,
+			wantCode: http.StatusOK,
+			wantReq:  Req{Email: "123@qq.com", Reactivate: true},
+		},
+		{
+			name: "JSON 格式不对",
+			body: func() (string, *bytes.Buffer) {
+				return "application/json", bytes.NewBufferString(`{"email":`)
+			},
+			wantCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := gin.New()
+			var got Req
+			server.POST("/test", func(ctx *gin.Context) {
+				if err := bindJSONOrForm(ctx, &got); err != nil {
+					return
+				}
+				ctx.Status(http.StatusOK)
+			})
+			contentType, body := tc.body()
+			req := httptest.NewRequest(http.MethodPost, "/test", body)
+			if contentType != "" {
+				req.Header.Set("Content-Type", contentType)
+			}
+			recorder := httptest.NewRecorder()
+			server.ServeHTTP(recorder, req)
+			assert.Equal(t, tc.wantCode, recorder.Code)
+			assert.Equal(t, tc.wantReq, got)
+		})
+	}
+}
+
+func TestBindJSON(t *testing.T) {
+	type Req struct {
+		Email string `json:"email"`
+	}
+	server := gin.New()
+	var got Req
+	server.POST("/test", func(ctx *gin.Context) {
+		if err := bindJSON(ctx, &got); err != nil {
+			return
+		}
+		ctx.Status(http.StatusOK)
+	})
+	// 就算是表单的 Content-Type，也是按 JSON 解析
+	req := httptest.NewRequest(http.MethodPost, "/test", strings.NewReader(`{"email":"123@qq.com"}`))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	server.ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, Req{Email: "123@qq.com"}, got)
+}
@@ -0,0 +1,26 @@
+package web
+
+// CurrentClaimsVersion 往 UserClaims 里面加字段的时候加一，并且在 MigrateClaims 里面补上老 token 的默认值
+const CurrentClaimsVersion = 1
+
+const (
+	RoleUser = "user"
+
+	TokenTypeAccess = "access"
+	// TokenTypeAPIKey 用 API key 调用的，中间件放进去的 claims
+	TokenTypeAPIKey = "api_key"
+)
+
+// MigrateClaims 把老版本 token 里面没有的字段补上默认值，后面的代码就不用到处判断零值了。
+// 版本太老、补不了的，在中间件里面按照 MinClaimsVersion 直接拒绝
+func MigrateClaims(claims *UserClaims) {
+	if claims.Role == "" {
+		claims.Role = RoleUser
+	}
+	if claims.TokenType == "" {
+		claims.TokenType = TokenTypeAccess
+	}
+	if claims.ClaimsVersion < CurrentClaimsVersion {
+		claims.ClaimsVersion = CurrentClaimsVersion
+	}
+}
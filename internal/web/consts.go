@@ -1 +1,7 @@
 package web
+
+// 业务错误码，除了通用的 4（输入有误）和 5（系统错误）之外的
+const (
+	// codeSensitiveContent 昵称、简介之类的用户输入包含敏感词
+	codeSensitiveContent = 6
+)
@@ -0,0 +1,104 @@
+package web
+
+import (
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+	"webook/internal/domain"
+)
+
+// SessionUserId 从 session 里面拿登录的用户 ID，没有或者类型不对都返回 false
+func SessionUserId(ctx *gin.Context) (int64, bool) {
+	return valueAs[int64](sessions.Default(ctx).Get("userId"))
+}
+
+// ClaimsUserId 从 JWT（或者 API key）校验之后放进来的 claims 里面拿用户 ID，
+// 没有或者类型不对都返回 false
+func ClaimsUserId(ctx *gin.Context) (int64, bool) {
+	val, _ := ctx.Get("claims")
+	claims, ok := valueAs[*UserClaims](val)
+	if !ok || claims == nil {
+		return 0, false
+	}
+	return claims.Uid, true
+}
+
+// ImpersonatedBy 代办登录的话返回管理员的 ID
+func ImpersonatedBy(ctx *gin.Context) (int64, bool) {
+	val, _ := ctx.Get("claims")
+	claims, ok := valueAs[*UserClaims](val)
+	if !ok || claims == nil || claims.ImpersonatedBy == 0 {
+		return 0, false
+	}
+	return claims.ImpersonatedBy, true
+}
+
+// contextUserIDKey AuthMiddlewareBuilder 校验通过之后把用户 ID 放在这里
+const contextUserIDKey = "userId"
+
+// SetContextUserID 给 AuthMiddlewareBuilder 用的
+func SetContextUserID(ctx *gin.Context, uid int64) {
+	ctx.Set(contextUserIDKey, uid)
+}
+
+// ContextUserID 先看 AuthMiddlewareBuilder 放进来的，再看 JWT 的 claims，最后看 session，
+// 都没有返回 (0, false)。handler 不用关心自己挂在哪一种登录方式后面
+func ContextUserID(ctx *gin.Context) (int64, bool) {
+	val, _ := ctx.Get(contextUserIDKey)
+	if uid, ok := valueAs[int64](val); ok {
+		return uid, true
+	}
+	if uid, ok := ClaimsUserId(ctx); ok {
+		return uid, true
+	}
+	// 没有用 session 的中间件的时候 sessions.Default 会 panic
+	if _, ok := ctx.Get(sessions.DefaultKey); !ok {
+		return 0, false
+	}
+	return SessionUserId(ctx)
+}
+
+// contextUserKey LoadUserMiddlewareBuilder 查出来的用户放在这个 key 下面
+const contextUserKey = "webook:user"
+
+// SetContextUser 给 LoadUserMiddlewareBuilder 用的
+func SetContextUser(ctx *gin.Context, user domain.User) {
+	ctx.Set(contextUserKey, user)
+}
+
+// ContextUser 前面挂了 LoadUserMiddlewareBuilder 并且查到了用户才有
+func ContextUser(ctx *gin.Context) (domain.User, bool) {
+	val, _ := ctx.Get(contextUserKey)
+	return valueAs[domain.User](val)
+}
+
+// MustUser 只能在挂了 LoadUserMiddlewareBuilder（不是 Optional 的）的路由里面用，
+// 没有就是路由配错了，直接 panic
+func MustUser(ctx *gin.Context) domain.User {
+	user, ok := ContextUser(ctx)
+	if !ok {
+		panic("gin.Context 里面没有用户，路由前面要挂 LoadUserMiddlewareBuilder")
+	}
+	return user
+}
+
+// GetAnonymousID 登录之前的匿名 ID。登录之后从 JWT 或者 session 里面拿，
+// 没登录的话看 cookie，都没有就是空字符串
+func GetAnonymousID(ctx *gin.Context) string {
+	val, _ := ctx.Get("claims")
+	if claims, ok := valueAs[*UserClaims](val); ok && claims != nil && claims.AnonID != "" {
+		return claims.AnonID
+	}
+	// 没有用 session 的中间件的时候 sessions.Default 会 panic
+	if _, ok := ctx.Get(sessions.DefaultKey); ok {
+		if id, ok := valueAs[string](sessions.Default(ctx).Get("anonId")); ok && id != "" {
+			return id
+		}
+	}
+	id, _ := AnonymousIDFromCookie(ctx)
+	return id
+}
+
+func valueAs[T any](val any) (T, bool) {
+	res, ok := val.(T)
+	return res, ok
+}
@@ -0,0 +1,261 @@
+package web
+
+import (
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-contrib/sessions/memstore"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"webook/internal/domain"
+	"webook/pkg/region"
+)
+
+func TestSessionUserId(t *testing.T) {
+	testCases := []struct {
+		name string
+		// 往 session 里面放的东西，nil 就是不放
+		val any
+
+		wantId int64
+		wantOk bool
+	}{
+		{
+			name:   "正常",
+			val:    int64(123),
+			wantId: 123,
+			wantOk: true,
+		},
+		{
+			name: "没有",
+		},
+		{
+			name: "类型不对",
+			val:  "123",
+		},
+		{
+			name: "int 也不行",
+			val:  123,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := gin.New()
+			server.Use(sessions.Sessions("mysession", memstore.NewStore([]byte("secret"))))
+			var (
+				id int64
+				ok bool
+			)
+			server.GET("/test", func(ctx *gin.Context) {
+				if tc.val != nil {
+					sessions.Default(ctx).Set("userId", tc.val)
+				}
+				id, ok = SessionUserId(ctx)
+			})
+			recorder := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/test", nil)
+			server.ServeHTTP(recorder, req)
+			assert.Equal(t, tc.wantOk, ok)
+			assert.Equal(t, tc.wantId, id)
+		})
+	}
+}
+
+func TestClaimsUserId(t *testing.T) {
+	testCases := []struct {
+		name string
+		// 往 ctx 里面放的 claims，nil 就是不放
+		val any
+
+		wantId int64
+		wantOk bool
+	}{
+		{
+			name:   "正常",
+			val:    &UserClaims{Uid: 123},
+			wantId: 123,
+			wantOk: true,
+		},
+		{
+			name: "没有",
+		},
+		{
+			name: "不是指针",
+			val:  UserClaims{Uid: 123},
+		},
+		{
+			name: "类型不对",
+			val:  int64(123),
+		},
+		{
+			name: "nil 指针",
+			val:  (*UserClaims)(nil),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+			if tc.val != nil {
+				ctx.Set("claims", tc.val)
+			}
+			id, ok := ClaimsUserId(ctx)
+			assert.Equal(t, tc.wantOk, ok)
+			assert.Equal(t, tc.wantId, id)
+		})
+	}
+}
+
+func TestContextUserID(t *testing.T) {
+	testCases := []struct {
+		name string
+		// nil 就是不放
+		claims *UserClaims
+		sessId any
+		// AuthMiddlewareBuilder 放进来的
+		ctxUid any
+		// 不挂 session 中间件
+		noSession bool
+
+		wantId int64
+		wantOk bool
+	}{
+		{
+			name:   "只有 claims",
+			claims: &UserClaims{Uid: 123},
+			wantId: 123,
+			wantOk: true,
+		},
+		{
+			name:   "只有 session",
+			sessId: int64(456),
+			wantId: 456,
+			wantOk: true,
+		},
+		{
+			name:   "两个都有，claims 优先",
+			claims: &UserClaims{Uid: 123},
+			sessId: int64(456),
+			wantId: 123,
+			wantOk: true,
+		},
+		{
+			name: "都没有",
+		},
+		{
+			name:      "没有 session 中间件",
+			noSession: true,
+		},
+		{
+			name:   "AuthMiddlewareBuilder 放进来的优先",
+			claims: &UserClaims{Uid: 123},
+			sessId: int64(456),
+			ctxUid: int64(789),
+			wantId: 789,
+			wantOk: true,
+		},
+		{
+			name:      "没有 session 中间件，有 claims",
+			claims:    &UserClaims{Uid: 123},
+			noSession: true,
+			wantId:    123,
+			wantOk:    true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := gin.New()
+			if !tc.noSession {
+				server.Use(sessions.Sessions("mysession", memstore.NewStore([]byte("secret"))))
+			}
+			var (
+				id int64
+				ok bool
+			)
+			server.GET("/test", func(ctx *gin.Context) {
+				if tc.claims != nil {
+					ctx.Set("claims", tc.claims)
+				}
+				if tc.sessId != nil {
+					sessions.Default(ctx).Set("userId", tc.sessId)
+				}
+				if tc.ctxUid != nil {
+					ctx.Set("userId", tc.ctxUid)
+				}
+				id, ok = ContextUserID(ctx)
+			})
+			recorder := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/test", nil)
+			server.ServeHTTP(recorder, req)
+			assert.Equal(t, tc.wantOk, ok)
+			assert.Equal(t, tc.wantId, id)
+		})
+	}
+}
+
+// 前面的中间件已经查过了，handler 不用再调 svc
+func TestUserHandler_MeContextUser(t *testing.T) {
+	server := gin.New()
+	server.Use(func(ctx *gin.Context) {
+		ctx.Set("claims", &UserClaims{Uid: 3})
+		SetContextUser(ctx, domain.User{Id: 3, Nickname: "Tom"})
+	})
+	NewUserHandler(nil, nil, nil, nil, region.NewDictionary()).RegisterRoutes(server)
+	req, err := http.NewRequest(http.MethodGet, "/users/me", nil)
+	require.NoError(t, err)
+	resp := httptest.NewRecorder()
+	server.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusOK, resp.Code)
+	assert.Contains(t, resp.Body.String(), `"nickname":"Tom"`)
+}
+
+func TestUserHandler_WhoAmI(t *testing.T) {
+	testCases := []struct {
+		name   string
+		claims *UserClaims
+		sessId any
+
+		wantBody string
+	}{
+		{
+			name:     "JWT",
+			claims:   &UserClaims{Uid: 123},
+			wantBody: `{"code":0,"msg":"","data":{"uid":123,"authenticated":true}}`,
+		},
+		{
+			name:     "session",
+			sessId:   int64(456),
+			wantBody: `{"code":0,"msg":"","data":{"uid":456,"authenticated":true}}`,
+		},
+		{
+			name:     "没登录也是 200",
+			wantBody: `{"code":0,"msg":"","data":{"uid":0,"authenticated":false}}`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := gin.New()
+			server.Use(sessions.Sessions("mysession", memstore.NewStore([]byte("secret"))))
+			server.Use(func(ctx *gin.Context) {
+				if tc.claims != nil {
+					ctx.Set("claims", tc.claims)
+				}
+				if tc.sessId != nil {
+					sessions.Default(ctx).Set("userId", tc.sessId)
+				}
+			})
+			// svc 是 nil，查数据库的话会 panic
+			NewUserHandler(nil, nil, nil, nil, region.NewDictionary()).RegisterRoutes(server)
+			req, err := http.NewRequest(http.MethodGet, "/users/whoami", nil)
+			require.NoError(t, err)
+			resp := httptest.NewRecorder()
+			server.ServeHTTP(resp, req)
+			require.Equal(t, http.StatusOK, resp.Code)
+			assert.JSONEq(t, tc.wantBody, resp.Body.String())
+		})
+	}
+}
@@ -0,0 +1,192 @@
+package web
+
+import (
+	"encoding/json"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"webook/internal/repository"
+	"webook/internal/repository/cache"
+	cachemocks "webook/internal/repository/cache/mocks"
+	"webook/internal/repository/dao"
+	"webook/internal/service"
+	svcmocks "webook/internal/service/mocks"
+)
+
+// hello#world123
+const testPasswordHash = "$2a$10$MN9ZKKIbjLZDyEpCYW19auY7mvOG9pcpiIcUUoZZI6pA6OmKZKOVi"
+
+func TestUserHandler_Deactivate(t *testing.T) {
+	testCases := []struct {
+		name string
+		body string
+		// 数据库里面的手机号和密码
+		phone    string
+		password string
+		mock     func(ctrl *gomock.Controller, mock sqlmock.Sqlmock) (cache.UserCache, service.CodeService)
+
+		wantCode int
+	}{
+		{
+			name:     "密码对了",
+			body:     `{"password":"hello#world123"}`,
+			password: testPasswordHash,
+			mock: func(ctrl *gomock.Controller, mock sqlmock.Sqlmock) (cache.UserCache, service.CodeService) {
+				return expectDeactivate(ctrl, mock), svcmocks.NewMockCodeService(ctrl)
+			},
+		},
+		{
+			name:     "密码不对",
+			body:     `{"password":"123"}`,
+			password: testPasswordHash,
+			mock: func(ctrl *gomock.Controller, mock sqlmock.Sqlmock) (cache.UserCache, service.CodeService) {
+				return cachemocks.NewMockUserCache(ctrl), svcmocks.NewMockCodeService(ctrl)
+			},
+			wantCode: 4,
+		},
+		{
+			name:  "手机号注册的用验证码",
+			body:  `{"code":"123456"}`,
+			phone: "15212345678",
+			mock: func(ctrl *gomock.Controller, mock sqlmock.Sqlmock) (cache.UserCache, service.CodeService) {
+				codeSvc := svcmocks.NewMockCodeService(ctrl)
+				codeSvc.EXPECT().Verify(gomock.Any(), bizLogin, "15212345678", "123456").Return(true, nil)
+				return expectDeactivate(ctrl, mock), codeSvc
+			},
+		},
+		{
+			name:  "验证码不对",
+			body:  `{"code":"654321"}`,
+			phone: "15212345678",
+			mock: func(ctrl *gomock.Controller, mock sqlmock.Sqlmock) (cache.UserCache, service.CodeService) {
+				codeSvc := svcmocks.NewMockCodeService(ctrl)
+				codeSvc.EXPECT().Verify(gomock.Any(), bizLogin, "15212345678", "654321").Return(false, nil)
+				return cachemocks.NewMockUserCache(ctrl), codeSvc
+			},
+			wantCode: 4,
+		},
+		{
+			name:     "什么都没填",
+			body:     `{}`,
+			password: testPasswordHash,
+			mock: func(ctrl *gomock.Controller, mock sqlmock.Sqlmock) (cache.UserCache, service.CodeService) {
+				return cachemocks.NewMockUserCache(ctrl), svcmocks.NewMockCodeService(ctrl)
+			},
+			wantCode: 4,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			db, mock := mockFindUser(t, "id", sqlmock.NewRows([]string{"id", "phone", "password"}).
+				AddRow(3, tc.phone, tc.password))
+			c, codeSvc := tc.mock(ctrl, mock)
+			svc := service.NewUserService(repository.NewUserRepository(dao.NewUserDAO(db), c),
+				nil, nil, service.SensitiveModeReject, nil, nil)
+			server := gin.New()
+			server.Use(func(ctx *gin.Context) {
+				ctx.Set("claims", &UserClaims{Uid: 3})
+			})
+			NewUserHandler(svc, codeSvc, nil, nil, nil).RegisterRoutes(server)
+
+			req, err := http.NewRequest(http.MethodPost, "/users/deactivate", strings.NewReader(tc.body))
+			require.NoError(t, err)
+			req.Header.Set("Content-Type", "application/json")
+			resp := httptest.NewRecorder()
+			server.ServeHTTP(resp, req)
+			require.Equal(t, http.StatusOK, resp.Code)
+
+			var res Result
+			require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &res))
+			assert.Equal(t, tc.wantCode, res.Code)
+			if tc.wantCode == 0 {
+				assert.Contains(t, resp.Header().Get("Set-Cookie"), RememberMeCookie+"=;")
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+// expectDeactivate 改状态，清缓存，所有的登录态失效并且不会自己过期
+func expectDeactivate(ctrl *gomock.Controller, mock sqlmock.Sqlmock) cache.UserCache {
+	mock.ExpectExec("UPDATE `users` SET .* WHERE id = \\? AND status = \\?").
+		WithArgs(dao.UserStatusDeactivated, sqlmock.AnyArg(), int64(3), dao.UserStatusActive).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	c := cachemocks.NewMockUserCache(ctrl)
+	c.EXPECT().DelProfile(gomock.Any(), int64(3)).Return(nil)
+	c.EXPECT().Del(gomock.Any(), int64(3)).Return(nil)
+	c.EXPECT().Revoke(gomock.Any(), int64(3), time.Duration(0)).Return(nil)
+	return c
+}
+
+func TestUserHandler_LoginSMSDeactivated(t *testing.T) {
+	testCases := []struct {
+		name string
+		body string
+		mock func(ctrl *gomock.Controller, mock sqlmock.Sqlmock) cache.UserCache
+
+		wantCode int
+	}{
+		{
+			name: "要先确认",
+			body: `{"phone":"15212345678","code":"123456"}`,
+			mock: func(ctrl *gomock.Controller, mock sqlmock.Sqlmock) cache.UserCache {
+				return cachemocks.NewMockUserCache(ctrl)
+			},
+			wantCode: 4,
+		},
+		{
+			name: "确认之后恢复",
+			body: `{"phone":"15212345678","code":"123456","reactivate":true}`,
+			mock: func(ctrl *gomock.Controller, mock sqlmock.Sqlmock) cache.UserCache {
+				mock.ExpectExec("UPDATE `users` SET .* WHERE id = \\? AND status = \\?").
+					WithArgs(dao.UserStatusActive, sqlmock.AnyArg(), int64(3), dao.UserStatusDeactivated).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectQuery("SELECT \\* FROM `users` WHERE id = \\?").
+					WillReturnRows(sqlmock.NewRows([]string{"id", "phone", "status"}).
+						AddRow(3, "15212345678", dao.UserStatusActive))
+				c := cachemocks.NewMockUserCache(ctrl)
+				c.EXPECT().DelProfile(gomock.Any(), int64(3)).Return(nil)
+				c.EXPECT().Del(gomock.Any(), int64(3)).Return(nil)
+				c.EXPECT().Restore(gomock.Any(), int64(3)).Return(nil)
+				return c
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			codeSvc := svcmocks.NewMockCodeService(ctrl)
+			codeSvc.EXPECT().VerifyWithResult(gomock.Any(), bizLogin, "15212345678", "123456").Return(service.VerifyResult{OK: true}, nil)
+			db, mock := mockFindUser(t, "phone", sqlmock.NewRows([]string{"id", "phone", "status"}).
+				AddRow(3, "15212345678", dao.UserStatusDeactivated))
+			svc := service.NewUserService(repository.NewUserRepository(dao.NewUserDAO(db), tc.mock(ctrl, mock)),
+				nil, nil, service.SensitiveModeReject, nil, nil)
+			server := gin.New()
+			NewUserHandler(svc, codeSvc, nil, nil, nil).RegisterRoutes(server)
+
+			req, err := http.NewRequest(http.MethodPost, "/users/login_sms", strings.NewReader(tc.body))
+			require.NoError(t, err)
+			req.Header.Set("Content-Type", "application/json")
+			resp := httptest.NewRecorder()
+			server.ServeHTTP(resp, req)
+			require.Equal(t, http.StatusOK, resp.Code)
+
+			var res Result
+			require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &res))
+			assert.Equal(t, tc.wantCode, res.Code)
+			// 没确认之前拿不到 token
+			assert.Equal(t, tc.wantCode == 0, resp.Header().Get("x-jwt-token") != "")
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
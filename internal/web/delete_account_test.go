@@ -0,0 +1,174 @@
+package web
+
+import (
+	"encoding/json"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-contrib/sessions/memstore"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"webook/internal/repository"
+	"webook/internal/repository/cache"
+	cachemocks "webook/internal/repository/cache/mocks"
+	"webook/internal/repository/dao"
+	"webook/internal/service"
+	svcmocks "webook/internal/service/mocks"
+)
+
+func TestUserHandler_DeleteAccount(t *testing.T) {
+	testCases := []struct {
+		name string
+		body string
+		// 数据库里面的邮箱、手机号和密码
+		email    string
+		phone    string
+		password string
+		mock     func(ctrl *gomock.Controller, mock sqlmock.Sqlmock) (cache.UserCache, service.CodeService)
+
+		wantCode int
+	}{
+		{
+			name:     "邮箱注册的用密码",
+			body:     `{"password":"hello#world123"}`,
+			email:    "123@qq.com",
+			password: testPasswordHash,
+			mock: func(ctrl *gomock.Controller, mock sqlmock.Sqlmock) (cache.UserCache, service.CodeService) {
+				expectFindUserAgain(mock, "123@qq.com", "", testPasswordHash)
+				return expectDeleteAccount(ctrl, mock), svcmocks.NewMockCodeService(ctrl)
+			},
+		},
+		{
+			name:     "密码不对",
+			body:     `{"password":"123"}`,
+			email:    "123@qq.com",
+			password: testPasswordHash,
+			mock: func(ctrl *gomock.Controller, mock sqlmock.Sqlmock) (cache.UserCache, service.CodeService) {
+				expectFindUserAgain(mock, "123@qq.com", "", testPasswordHash)
+				return cachemocks.NewMockUserCache(ctrl), svcmocks.NewMockCodeService(ctrl)
+			},
+			wantCode: 4,
+		},
+		{
+			name:     "邮箱注册的没有验证码可用",
+			body:     `{"code":"123456"}`,
+			email:    "123@qq.com",
+			password: testPasswordHash,
+			mock: func(ctrl *gomock.Controller, mock sqlmock.Sqlmock) (cache.UserCache, service.CodeService) {
+				return cachemocks.NewMockUserCache(ctrl), svcmocks.NewMockCodeService(ctrl)
+			},
+			wantCode: 4,
+		},
+		{
+			name:  "手机号注册的用验证码",
+			body:  `{"code":"123456"}`,
+			phone: "15212345678",
+			mock: func(ctrl *gomock.Controller, mock sqlmock.Sqlmock) (cache.UserCache, service.CodeService) {
+				codeSvc := svcmocks.NewMockCodeService(ctrl)
+				codeSvc.EXPECT().Verify(gomock.Any(), bizDeleteAccount, "15212345678", "123456").Return(true, nil)
+				return expectDeleteAccount(ctrl, mock), codeSvc
+			},
+		},
+		{
+			name:  "验证码不对",
+			body:  `{"code":"654321"}`,
+			phone: "15212345678",
+			mock: func(ctrl *gomock.Controller, mock sqlmock.Sqlmock) (cache.UserCache, service.CodeService) {
+				codeSvc := svcmocks.NewMockCodeService(ctrl)
+				codeSvc.EXPECT().Verify(gomock.Any(), bizDeleteAccount, "15212345678", "654321").Return(false, nil)
+				return cachemocks.NewMockUserCache(ctrl), codeSvc
+			},
+			wantCode: 4,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			db, mock := mockFindUser(t, "id", sqlmock.NewRows([]string{"id", "email", "phone", "password"}).
+				AddRow(3, nullIfEmpty(tc.email), nullIfEmpty(tc.phone), tc.password))
+			c, codeSvc := tc.mock(ctrl, mock)
+			svc := service.NewUserService(repository.NewUserRepository(dao.NewUserDAO(db), c),
+				nil, nil, service.SensitiveModeReject, nil, nil)
+			server := gin.New()
+			server.Use(sessions.Sessions("ssid", memstore.NewStore([]byte("secret"))))
+			server.Use(func(ctx *gin.Context) {
+				ctx.Set("claims", &UserClaims{Uid: 3})
+			})
+			NewUserHandler(svc, codeSvc, nil, nil, nil).RegisterRoutes(server)
+
+			req, err := http.NewRequest(http.MethodPost, "/users/delete", strings.NewReader(tc.body))
+			require.NoError(t, err)
+			req.Header.Set("Content-Type", "application/json")
+			resp := httptest.NewRecorder()
+			server.ServeHTTP(resp, req)
+			require.Equal(t, http.StatusOK, resp.Code)
+
+			var res Result
+			require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &res))
+			assert.Equal(t, tc.wantCode, res.Code)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+// 邮箱注册的没有手机号，不发验证码
+func TestUserHandler_SendDeleteAccountCodeNoPhone(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	db, mock := mockFindUser(t, "id", sqlmock.NewRows([]string{"id", "email"}).
+		AddRow(3, "123@qq.com"))
+	svc := service.NewUserService(repository.NewUserRepository(dao.NewUserDAO(db), nil),
+		nil, nil, service.SensitiveModeReject, nil, nil)
+	server := gin.New()
+	server.Use(func(ctx *gin.Context) {
+		ctx.Set("claims", &UserClaims{Uid: 3})
+	})
+	NewUserHandler(svc, svcmocks.NewMockCodeService(ctrl), nil, nil, nil).RegisterRoutes(server)
+
+	req, err := http.NewRequest(http.MethodPost, "/users/delete/code/send", nil)
+	require.NoError(t, err)
+	resp := httptest.NewRecorder()
+	server.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusOK, resp.Code)
+
+	var res Result
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &res))
+	assert.Equal(t, Result{Code: 4, Msg: "没有绑定手机号，请用密码验证"}, res)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// expectFindUserAgain 验证密码的时候还要再查一次
+func expectFindUserAgain(mock sqlmock.Sqlmock, email, phone, password string) {
+	mock.ExpectQuery("SELECT .* FROM `users` WHERE id = .*").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "email", "phone", "password"}).
+			AddRow(3, nullIfEmpty(email), nullIfEmpty(phone), password))
+}
+
+// expectDeleteAccount 进入冷静期，清缓存，登录态在冷静期内失效
+func expectDeleteAccount(ctrl *gomock.Controller, mock sqlmock.Sqlmock) cache.UserCache {
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT \\* FROM `users` WHERE id = \\? AND status = \\?").
+		WithArgs(int64(3), dao.UserStatusActive).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "status"}).AddRow(3, dao.UserStatusActive))
+	mock.ExpectExec("UPDATE `users` SET .* WHERE `id` = \\?").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	c := cachemocks.NewMockUserCache(ctrl)
+	c.EXPECT().DelProfile(gomock.Any(), int64(3)).Return(nil)
+	c.EXPECT().Del(gomock.Any(), int64(3)).Return(nil)
+	c.EXPECT().Revoke(gomock.Any(), int64(3), service.AccountDeletionGracePeriod).Return(nil)
+	return c
+}
+
+func nullIfEmpty(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
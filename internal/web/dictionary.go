@@ -0,0 +1,40 @@
+package web
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"webook/pkg/region"
+)
+
+// DictionaryHandler 给前端下拉框之类的用的字典数据，不需要登录
+type DictionaryHandler struct {
+	regions *region.Dictionary
+}
+
+func NewDictionaryHandler(regions *region.Dictionary) *DictionaryHandler {
+	return &DictionaryHandler{
+		regions: regions,
+	}
+}
+
+func (h *DictionaryHandler) RegisterRoutes(server *gin.Engine) {
+	dg := server.Group("/dictionaries")
+	dg.GET("/regions", h.Regions)
+}
+
+// Regions 所有的省和下面的地级市，allow_overseas 为 true 的时候前端要给海外用户留一个输入框
+func (h *DictionaryHandler) Regions(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, Result{
+		Data: map[string]any{
+			"provinces":      h.regions.Provinces(),
+			"allow_overseas": h.regions.AllowOverseas(),
+		},
+	})
+}
+
+// regionVO 展示用的，名字是读的时候查字典得到的
+type regionVO struct {
+	Code string `json:"code"`
+	// 字典里面没有这个代码了就是空的
+	Name string `json:"name"`
+}
@@ -0,0 +1,99 @@
+package web
+
+import (
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"webook/pkg/region"
+)
+
+func TestDictionaryHandler_Regions(t *testing.T) {
+	server := gin.New()
+	NewDictionaryHandler(region.NewDictionary(region.WithOverseas())).RegisterRoutes(server)
+	req, err := http.NewRequest(http.MethodGet, "/dictionaries/regions", nil)
+	require.NoError(t, err)
+	resp := httptest.NewRecorder()
+	server.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusOK, resp.Code)
+
+	var res struct {
+		Data struct {
+			Provinces     []region.Province `json:"provinces"`
+			AllowOverseas bool              `json:"allow_overseas"`
+		} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &res))
+	assert.True(t, res.Data.AllowOverseas)
+	require.NotEmpty(t, res.Data.Provinces)
+	assert.Equal(t, "110000", res.Data.Provinces[0].Code)
+	assert.Equal(t, "北京市", res.Data.Provinces[0].Name)
+}
+
+func TestUserHandler_validateRegion(t *testing.T) {
+	testCases := []struct {
+		name     string
+		overseas bool
+		province string
+		city     string
+		location string
+
+		wantField string
+		wantOk    bool
+	}{
+		{
+			name:     "省和市",
+			province: "440000",
+			city:     "440300",
+			wantOk:   true,
+		},
+		{
+			name:   "都不填",
+			wantOk: true,
+		},
+		{
+			name:      "省份不对",
+			province:  "990000",
+			wantField: "region",
+		},
+		{
+			name:      "市不属于这个省",
+			province:  "330000",
+			city:      "440300",
+			wantField: "city",
+		},
+		{
+			name:     "海外地区",
+			overseas: true,
+			location: "Tokyo",
+			wantOk:   true,
+		},
+		{
+			name:      "没开海外地区",
+			location:  "Tokyo",
+			wantField: "location",
+		},
+		{
+			name:      "海外地区和省市一起填",
+			overseas:  true,
+			province:  "440000",
+			location:  "Tokyo",
+			wantField: "location",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var opts []region.Option
+			if tc.overseas {
+				opts = append(opts, region.WithOverseas())
+			}
+			u := &UserHandler{regions: region.NewDictionary(opts...)}
+			field, _, ok := u.validateRegion(tc.province, tc.city, tc.location)
+			assert.Equal(t, tc.wantOk, ok)
+			assert.Equal(t, tc.wantField, field)
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package web
+
+import (
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-contrib/sessions/memstore"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"webook/internal/repository"
+	"webook/internal/repository/cache"
+	cachemocks "webook/internal/repository/cache/mocks"
+	"webook/internal/repository/dao"
+	"webook/internal/service"
+	"webook/internal/service/sensitive/ac"
+	"webook/pkg/region"
+)
+
+// TestUserHandler_Edit 用的是 session 登录，userId 从 session 里面拿。
+// UserService 不是接口，传给它的 domain.User 从最后写到数据库里面的参数看
+func TestUserHandler_Edit(t *testing.T) {
+	update := "UPDATE `users` SET .* WHERE `id` = \\?"
+	testCases := []struct {
+		name string
+		// 放进 session 里面的 userId，0 就是没登录
+		uid  int64
+		body string
+		mock func(ctrl *gomock.Controller, mock sqlmock.Sqlmock) cache.UserCache
+
+		wantBody string
+	}{
+		{
+			name: "修改成功",
+			uid:  123,
+			body: `{"nickname":"Tom","birthday":"1992-01-01","brief":"hello","gender":"male"}`,
+			mock: func(ctrl *gomock.Controller, mock sqlmock.Sqlmock) cache.UserCache {
+				mock.ExpectQuery("SELECT \\* FROM `users` WHERE id = \\?").WithArgs(int64(123)).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "nickname"}).AddRow(123, ""))
+				// session 里面的 userId 就是改的这个用户
+				mock.ExpectExec(update).
+					WithArgs("Tom", "1992-01-01", false, "hello", uint8(1), "", "", "", "", "", "",
+						sqlmock.AnyArg(), sqlmock.AnyArg(), int64(123)).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				c := cachemocks.NewMockUserCache(ctrl)
+				c.EXPECT().DelProfile(gomock.Any(), int64(123)).Return(nil)
+				c.EXPECT().Del(gomock.Any(), int64(123)).Return(nil)
+				return c
+			},
+			wantBody: `{"code":0,"msg":"修改成功","data":{"brief":"hello","location":"","nickname":"Tom","website":""}}`,
+		},
+		{
+			name:     "生日格式不对",
+			uid:      123,
+			body:     `{"nickname":"Tom","birthday":"1992/01/01"}`,
+			wantBody: "生日格式不正确（格式:1992-01-01）",
+		},
+		{
+			name:     "昵称太长",
+			uid:      123,
+			body:     `{"nickname":"` + strings.Repeat("汤", 256) + `","birthday":"1992-01-01"}`,
+			wantBody: "昵称不超过255个字符",
+		},
+		{
+			name:     "简介太长",
+			uid:      123,
+			body:     `{"brief":"` + strings.Repeat("a", 256) + `","birthday":"1992-01-01"}`,
+			wantBody: "个人简介不超过255个字符",
+		},
+		{
+			name:     "session 里面没有 userId",
+			body:     `{"nickname":"Tom","birthday":"1992-01-01"}`,
+			wantBody: "系统错误",
+		},
+		{
+			name: "查数据库出错",
+			uid:  123,
+			body: `{"nickname":"Tom","birthday":"1992-01-01"}`,
+			mock: func(ctrl *gomock.Controller, mock sqlmock.Sqlmock) cache.UserCache {
+				mock.ExpectQuery("SELECT \\* FROM `users` WHERE id = \\?").
+					WillReturnError(errors.New("mock db 错误"))
+				return cachemocks.NewMockUserCache(ctrl)
+			},
+			wantBody: "系统错误",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			db, mock := mockUserDB(t)
+			var c cache.UserCache
+			// 校验不通过的时候不会走到 svc
+			if tc.mock != nil {
+				c = tc.mock(ctrl, mock)
+			}
+			svc := service.NewUserService(repository.NewUserRepository(dao.NewUserDAO(db), c),
+				nil, ac.NewFilter(), service.SensitiveModeReject, nil, nil)
+			server := gin.New()
+			server.Use(sessions.Sessions("ssid", memstore.NewStore([]byte("secret"))))
+			server.Use(func(ctx *gin.Context) {
+				if tc.uid > 0 {
+					sessions.Default(ctx).Set("userId", tc.uid)
+				}
+			})
+			NewUserHandler(svc, nil, nil, nil, region.NewDictionary()).RegisterRoutes(server)
+
+			req, err := http.NewRequest(http.MethodPost, "/users/edit", strings.NewReader(tc.body))
+			require.NoError(t, err)
+			req.Header.Set("Content-Type", "application/json")
+			resp := httptest.NewRecorder()
+			server.ServeHTTP(resp, req)
+			assert.Equal(t, http.StatusOK, resp.Code)
+			assert.Equal(t, tc.wantBody, resp.Body.String())
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
@@ -0,0 +1,156 @@
+package web
+
+import (
+	"encoding/json"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"webook/internal/repository"
+	"webook/internal/repository/dao"
+	"webook/internal/service"
+	limitmocks "webook/pkg/ratelimit/mocks"
+	"webook/pkg/region"
+)
+
+func TestUserHandler_LoginJWTEmailNotVerified(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("hello#world123"), bcrypt.MinCost)
+	require.NoError(t, err)
+	testCases := []struct {
+		name       string
+		required   bool
+		verifiedAt int64
+
+		wantCode   int
+		wantResend bool
+		wantToken  bool
+	}{
+		{
+			name:       "要求验证，没有验证",
+			required:   true,
+			wantCode:   4,
+			wantResend: true,
+		},
+		{
+			name:       "要求验证，已经验证过了",
+			required:   true,
+			verifiedAt: 1700000000000,
+			wantToken:  true,
+		},
+		{
+			name:      "默认不要求",
+			wantToken: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db := mockFindUserDB(t, "email", sqlmock.NewRows([]string{"id", "email", "password", "email_verified_at"}).
+				AddRow(3, "tom@qq.com", string(hash), tc.verifiedAt))
+			svc := service.NewUserService(repository.NewUserRepository(dao.NewUserDAO(db), nil),
+				nil, nil, service.SensitiveModeReject, nil, nil,
+				service.WithEmailVerification(nil, tc.required))
+			server := gin.New()
+			// 默认注册的 /users/login 是 session 登录，这里要测 JWT
+			server.POST("/users/login_jwt", NewUserHandler(svc, nil, nil, nil, region.NewDictionary()).LoginJWT)
+
+			req, err := http.NewRequest(http.MethodPost, "/users/login_jwt",
+				strings.NewReader(`{"email":"tom@qq.com","password":"hello#world123"}`))
+			require.NoError(t, err)
+			req.Header.Set("Content-Type", "application/json")
+			resp := httptest.NewRecorder()
+			server.ServeHTTP(resp, req)
+			require.Equal(t, http.StatusOK, resp.Code)
+
+			assert.Equal(t, tc.wantToken, resp.Header().Get("x-jwt-token") != "")
+			if !tc.wantResend {
+				return
+			}
+			var res struct {
+				Code int               `json:"code"`
+				Data map[string]string `json:"data"`
+			}
+			require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &res))
+			assert.Equal(t, tc.wantCode, res.Code)
+			assert.Equal(t, "/users/email/verification/resend", res.Data["resend"])
+		})
+	}
+}
+
+func TestUserHandler_ResendVerificationEmail(t *testing.T) {
+	testCases := []struct {
+		name    string
+		body    string
+		limited bool
+		rows    *sqlmock.Rows
+
+		wantCode int
+		wantMsg  string
+	}{
+		{
+			name:     "已经验证过了",
+			body:     `{"email":"tom@qq.com"}`,
+			rows:     sqlmock.NewRows([]string{"id", "email", "email_verified_at"}).AddRow(3, "tom@qq.com", 1700000000000),
+			wantCode: 4,
+			wantMsg:  "邮箱已经验证过了",
+		},
+		{
+			// 跟真的发出去了一样
+			name:    "没有这个账号",
+			body:    `{"email":"tom@qq.com"}`,
+			rows:    sqlmock.NewRows([]string{"id"}),
+			wantMsg: "验证邮件已发送",
+		},
+		{
+			name:     "重发太频繁",
+			body:     `{"email":"tom@qq.com"}`,
+			limited:  true,
+			wantCode: 4,
+			wantMsg:  "发送太频繁，请稍后再试",
+		},
+		{
+			name:     "没填邮箱",
+			body:     `{}`,
+			wantCode: 4,
+			wantMsg:  "请输入邮箱",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			var db *gorm.DB
+			if tc.rows != nil {
+				db = mockFindUserDB(t, "email", tc.rows)
+			}
+			limiter := limitmocks.NewMockLimiter(ctrl)
+			limiter.EXPECT().Limit(gomock.Any(), "email_resend:tom@qq.com").Return(tc.limited, nil).AnyTimes()
+			svc := service.NewUserService(repository.NewUserRepository(dao.NewUserDAO(db), nil),
+				nil, nil, service.SensitiveModeReject, nil, nil,
+				service.WithEmailVerification(service.NewEmailVerificationService(nil, nil, nil,
+					[]byte("test-key"), "", time.Hour), false),
+				service.WithEmailResendLimiter(limiter))
+			server := gin.New()
+			NewUserHandler(svc, nil, nil, nil, region.NewDictionary()).RegisterRoutes(server)
+
+			req, err := http.NewRequest(http.MethodPost, "/users/verify_email/resend", strings.NewReader(tc.body))
+			require.NoError(t, err)
+			req.Header.Set("Content-Type", "application/json")
+			resp := httptest.NewRecorder()
+			server.ServeHTTP(resp, req)
+			require.Equal(t, http.StatusOK, resp.Code)
+
+			var res Result
+			require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &res))
+			assert.Equal(t, tc.wantCode, res.Code)
+			assert.Equal(t, tc.wantMsg, res.Msg)
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package web
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strings"
+	"webook/internal/domain"
+)
+
+// profileETag 弱 ETag，资料改了 utime 就会变。
+// 缓存里面的资料带着 utime，所以算 ETag 不用查数据库
+func profileETag(user domain.User) string {
+	return fmt.Sprintf(`W/"%d-%d"`, user.Id, user.Utime.UnixMilli())
+}
+
+// notModified 设置 ETag，客户端带上来的 If-None-Match 对得上的话直接返回 304，不用再写响应了
+func notModified(ctx *gin.Context, etag string) bool {
+	ctx.Header("ETag", etag)
+	if !etagMatch(ctx.GetHeader("If-None-Match"), etag) {
+		return false
+	}
+	ctx.AbortWithStatus(http.StatusNotModified)
+	return true
+}
+
+// etagMatch If-None-Match 可以是 * 或者逗号分隔的多个 ETag，用的是弱比较，W/ 前缀不算
+func etagMatch(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,170 @@
+package web
+
+import (
+	"context"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	gormMysql "gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"webook/internal/domain"
+	"webook/internal/repository"
+	"webook/internal/repository/cache"
+	"webook/internal/repository/dao"
+	"webook/internal/service"
+	"webook/internal/service/sensitive/ac"
+	"webook/pkg/region"
+)
+
+// memUserCache 只实现了资料的缓存，够 ETag 的测试用
+type memUserCache struct {
+	cache.UserCache
+	mutex sync.Mutex
+	users map[int64]domain.User
+}
+
+func (c *memUserCache) Get(ctx context.Context, id int64) (domain.User, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	u, ok := c.users[id]
+	if !ok {
+		return domain.User{}, cache.ErrKeyNotExist
+	}
+	return u, nil
+}
+
+func (c *memUserCache) Set(ctx context.Context, u domain.User) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.users[u.Id] = u
+	return nil
+}
+
+func (c *memUserCache) Del(ctx context.Context, id int64) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	delete(c.users, id)
+	return nil
+}
+
+func (c *memUserCache) DelProfile(ctx context.Context, id int64) error {
+	return nil
+}
+
+func TestUserHandler_ProfileETag(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	query := "SELECT \\* FROM `users` WHERE id = \\?"
+	// 第一次查资料
+	mock.ExpectQuery(query).WillReturnRows(sqlmock.NewRows([]string{"id", "nickname", "utime"}).
+		AddRow(3, "Tom", int64(1700000000000)))
+	// 修改资料的时候先查一下原来的，再更新
+	mock.ExpectQuery(query).WillReturnRows(sqlmock.NewRows([]string{"id", "nickname", "utime"}).
+		AddRow(3, "Tom", int64(1700000000000)))
+	mock.ExpectExec("UPDATE `users` SET").WillReturnResult(sqlmock.NewResult(0, 1))
+	// 缓存删掉了，重新查
+	mock.ExpectQuery(query).WillReturnRows(sqlmock.NewRows([]string{"id", "nickname", "brief", "utime"}).
+		AddRow(3, "Tom", "new brief", int64(1700000001000)))
+	db, err := gorm.Open(gormMysql.New(gormMysql.Config{
+		Conn:                      mockDB,
+		SkipInitializeWithVersion: true,
+	}), &gorm.Config{
+		DisableAutomaticPing:   true,
+		SkipDefaultTransaction: true,
+	})
+	require.NoError(t, err)
+
+	svc := service.NewUserService(repository.NewUserRepository(dao.NewUserDAO(db),
+		&memUserCache{users: map[int64]domain.User{}}),
+		nil, ac.NewFilter(), service.SensitiveModeReject, nil, nil)
+	server := gin.New()
+	server.Use(func(ctx *gin.Context) {
+		ctx.Set("claims", &UserClaims{Uid: 3})
+	})
+	NewUserHandler(svc, nil, nil, nil, region.NewDictionary()).RegisterRoutes(server)
+
+	profile := func(ifNoneMatch string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(http.MethodGet, "/users/profile", nil)
+		require.NoError(t, err)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		resp := httptest.NewRecorder()
+		server.ServeHTTP(resp, req)
+		return resp
+	}
+
+	resp := profile("")
+	require.Equal(t, http.StatusOK, resp.Code)
+	etag := resp.Header().Get("ETag")
+	assert.Equal(t, `W/"3-1700000000000"`, etag)
+	assert.Equal(t, "private, max-age=10", resp.Header().Get("Cache-Control"))
+
+	// 资料没变，不返回内容，也不查数据库
+	resp = profile(etag)
+	assert.Equal(t, http.StatusNotModified, resp.Code)
+	assert.Empty(t, resp.Body.String())
+	assert.Equal(t, etag, resp.Header().Get("ETag"))
+
+	req, err := http.NewRequest(http.MethodPost, "/users/edit", strings.NewReader(`{"birthday":"1992-01-01","brief":"new brief"}`))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	editResp := httptest.NewRecorder()
+	server.ServeHTTP(editResp, req)
+	require.Equal(t, http.StatusOK, editResp.Code)
+
+	// 改完之后旧的 ETag 马上就不能用了
+	resp = profile(etag)
+	require.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, `W/"3-1700000001000"`, resp.Header().Get("ETag"))
+	assert.Contains(t, resp.Body.String(), "new brief")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUserHandler_ProfileNotFound(t *testing.T) {
+	db := mockFindUserDB(t, "id", sqlmock.NewRows([]string{"id"}))
+	svc := service.NewUserService(repository.NewUserRepository(dao.NewUserDAO(db),
+		&memUserCache{users: map[int64]domain.User{}}),
+		nil, nil, service.SensitiveModeReject, nil, nil)
+	server := gin.New()
+	server.Use(func(ctx *gin.Context) {
+		ctx.Set("claims", &UserClaims{Uid: 3})
+	})
+	NewUserHandler(svc, nil, nil, nil, region.NewDictionary()).RegisterRoutes(server)
+
+	req, err := http.NewRequest(http.MethodGet, "/users/profile", nil)
+	require.NoError(t, err)
+	resp := httptest.NewRecorder()
+	server.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+	assert.Empty(t, resp.Header().Get("Cache-Control"))
+}
+
+func TestETagMatch(t *testing.T) {
+	testCases := []struct {
+		name        string
+		ifNoneMatch string
+		want        bool
+	}{
+		{name: "一样", ifNoneMatch: `W/"3-1000"`, want: true},
+		{name: "弱比较", ifNoneMatch: `"3-1000"`, want: true},
+		{name: "多个", ifNoneMatch: `W/"3-900", W/"3-1000"`, want: true},
+		{name: "星号", ifNoneMatch: "*", want: true},
+		{name: "不一样", ifNoneMatch: `W/"3-900"`},
+		{name: "没带"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, etagMatch(tc.ifNoneMatch, profileETag(domain.User{
+				Id: 3, Utime: time.UnixMilli(1000),
+			})))
+		})
+	}
+}
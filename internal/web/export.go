@@ -0,0 +1,231 @@
+package web
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"webook/internal/domain"
+	"webook/internal/service"
+)
+
+// UserExportHandler 导出个人数据
+type UserExportHandler struct {
+	svc    service.UserExportService
+	reauth Reauthenticator
+}
+
+// Reauthenticator 敏感操作之前再确认一次是本人，不通过的话响应已经写好了。*UserHandler 实现了它
+type Reauthenticator interface {
+	Reauthenticate(ctx *gin.Context, uid int64, password, code string) bool
+}
+
+func NewUserExportHandler(svc service.UserExportService, reauth Reauthenticator) *UserExportHandler {
+	return &UserExportHandler{
+		svc:    svc,
+		reauth: reauth,
+	}
+}
+
+// RegisterRoutes 发起导出要再验证一次身份，查结果靠的是发起的时候返回的任务 ID，
+// 任务 ID 是 16 字节的随机数，也只能查到自己的
+func (h *UserExportHandler) RegisterRoutes(server *gin.Engine) {
+	ug := server.Group("/users/export")
+	ug.POST("", h.Start)
+	ug.GET("/:id", h.Result)
+
+	mg := server.Group("/users/me/export")
+	mg.POST("", h.StartJob)
+	mg.GET("/:job_id", h.Job)
+	mg.GET("/:job_id/archive", h.Archive)
+}
+
+// exportJobVO 生成好了才有 URL
+type exportJobVO struct {
+	JobId  string `json:"job_id"`
+	Status string `json:"status"`
+	URL    string `json:"url,omitempty"`
+}
+
+// exportReq 跟 Deactivate 一样，有密码的输密码，手机号注册的用登录的短信验证码
+type exportReq struct {
+	Password string `json:"password"`
+	Code     string `json:"code"`
+}
+
+// reauthenticate 不通过的话响应已经写好了
+func (h *UserExportHandler) reauthenticate(ctx *gin.Context) (int64, bool) {
+	var req exportReq
+	if err := bindJSON(ctx, &req); err != nil {
+		return 0, false
+	}
+	uid, ok := ClaimsUserId(ctx)
+	if !ok {
+		ctx.String(http.StatusOK, "系统错误")
+		return 0, false
+	}
+	return uid, h.reauth.Reauthenticate(ctx, uid, req.Password, req.Code)
+}
+
+// Start 发起导出，返回任务 ID，前端拿着 ID 轮询结果。请求体：JSON
+func (h *UserExportHandler) Start(ctx *gin.Context) {
+	uid, ok := h.reauthenticate(ctx)
+	if !ok {
+		return
+	}
+	id, err := h.svc.Start(ctx, uid)
+	switch err {
+	case nil:
+		ctx.JSON(http.StatusOK, Result{
+			Msg:  "导出中",
+			Data: id,
+		})
+	case service.ErrExportTooFrequent:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  "一天只能导出一次",
+		})
+	default:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 5,
+			Msg:  "系统错误",
+		})
+	}
+}
+
+// Result 生成好了就直接下载 JSON 文件
+func (h *UserExportHandler) Result(ctx *gin.Context) {
+	uid, ok := ClaimsUserId(ctx)
+	if !ok {
+		ctx.String(http.StatusOK, "系统错误")
+		return
+	}
+	task, err := h.svc.Get(ctx, uid, ctx.Param("id"))
+	if err == service.ErrExportNotFound {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  "导出任务不存在或者已经过期",
+		})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 5,
+			Msg:  "系统错误",
+		})
+		return
+	}
+	switch task.Status {
+	case domain.ExportTaskDone:
+		// 存的是压缩过的，浏览器会自己解压，下载下来还是 JSON 文件
+		ctx.Header("Content-Encoding", "gzip")
+		ctx.Header("Content-Disposition",
+			fmt.Sprintf(`attachment; filename="webook-export-%d.json"`, uid))
+		ctx.Data(http.StatusOK, "application/json", task.Data)
+	case domain.ExportTaskFailed:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 5,
+			Msg:  "导出失败，请稍后重试",
+		})
+	default:
+		ctx.JSON(http.StatusOK, Result{
+			Msg: "导出中",
+		})
+	}
+}
+
+// StartJob 跟 Start 一样，不过返回 202 和任务 ID，前端拿着 ID 轮询 Job。请求体：JSON
+func (h *UserExportHandler) StartJob(ctx *gin.Context) {
+	uid, ok := h.reauthenticate(ctx)
+	if !ok {
+		return
+	}
+	id, err := h.svc.Start(ctx, uid)
+	switch err {
+	case nil:
+		ctx.JSON(http.StatusAccepted, Result{
+			Msg: "导出中",
+			Data: exportJobVO{
+				JobId:  id,
+				Status: string(domain.ExportTaskPending),
+			},
+		})
+	case service.ErrExportTooFrequent:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  "一天只能导出一次",
+		})
+	default:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 5,
+			Msg:  "系统错误",
+		})
+	}
+}
+
+// Job 生成好了返回下载的地址，地址只有自己登录了才能下载
+func (h *UserExportHandler) Job(ctx *gin.Context) {
+	task, ok := h.getTask(ctx)
+	if !ok {
+		return
+	}
+	vo := exportJobVO{
+		JobId:  task.Id,
+		Status: string(task.Status),
+	}
+	switch task.Status {
+	case domain.ExportTaskDone:
+		vo.URL = "/users/me/export/" + task.Id + "/archive"
+	case domain.ExportTaskFailed:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 5,
+			Msg:  "导出失败，请稍后重试",
+			Data: vo,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, Result{
+		Data: vo,
+	})
+}
+
+// Archive 下载压缩包，不解压
+func (h *UserExportHandler) Archive(ctx *gin.Context) {
+	task, ok := h.getTask(ctx)
+	if !ok {
+		return
+	}
+	if task.Status != domain.ExportTaskDone {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  "导出还没完成",
+		})
+		return
+	}
+	ctx.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, task.FileName))
+	ctx.Data(http.StatusOK, "application/gzip", task.Data)
+}
+
+// getTask 找不到的话响应已经写好了
+func (h *UserExportHandler) getTask(ctx *gin.Context) (domain.ExportTask, bool) {
+	uid, ok := ClaimsUserId(ctx)
+	if !ok {
+		ctx.String(http.StatusOK, "系统错误")
+		return domain.ExportTask{}, false
+	}
+	task, err := h.svc.Get(ctx, uid, ctx.Param("job_id"))
+	if err == service.ErrExportNotFound {
+		ctx.JSON(http.StatusNotFound, Result{
+			Code: 4,
+			Msg:  "导出任务不存在或者已经过期",
+		})
+		return domain.ExportTask{}, false
+	}
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 5,
+			Msg:  "系统错误",
+		})
+		return domain.ExportTask{}, false
+	}
+	return task, true
+}
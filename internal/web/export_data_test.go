@@ -0,0 +1,113 @@
+package web
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"webook/internal/domain"
+	"webook/internal/repository"
+	cachemocks "webook/internal/repository/cache/mocks"
+	"webook/internal/repository/dao"
+	"webook/internal/service"
+	svcmocks "webook/internal/service/mocks"
+)
+
+func TestUserHandler_ExportData(t *testing.T) {
+	ctime := time.UnixMilli(1700000000000)
+	testCases := []struct {
+		name string
+		body string
+		mock func(mock sqlmock.Sqlmock)
+
+		wantCode   int
+		wantExport bool
+	}{
+		{
+			name: "密码对了",
+			// 请求里面带了别人的 uid 也没用，只看登录态
+			body: `{"password":"hello#world123","uid":4}`,
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery("SELECT .* FROM `users` WHERE id = .*").
+					WillReturnRows(sqlmock.NewRows([]string{"id", "nickname", "email", "phone"}).
+						AddRow(3, "Tom", "tom@qq.com", "15212345678"))
+				mock.ExpectQuery("SELECT \\* FROM `login_events` WHERE user_id = \\? ORDER BY ctime DESC, id DESC LIMIT 100").
+					WithArgs(int64(3)).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "ip", "user_agent", "status", "ctime"}).
+						AddRow(2, 3, "10.0.0.2", "Chrome", 1, ctime.Add(time.Minute).UnixMilli()).
+						AddRow(1, 3, "10.0.0.1", "Safari", 1, ctime.UnixMilli()))
+			},
+			wantExport: true,
+		},
+		{
+			name:     "密码不对，什么都不查",
+			body:     `{"password":"123"}`,
+			mock:     func(mock sqlmock.Sqlmock) {},
+			wantCode: 4,
+		},
+		{
+			name:     "什么都没填",
+			body:     `{}`,
+			mock:     func(mock sqlmock.Sqlmock) {},
+			wantCode: 4,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			// 先确认密码，有手机号也优先看密码
+			db, mock := mockFindUser(t, "id", sqlmock.NewRows([]string{"id", "phone", "password"}).
+				AddRow(3, "", testPasswordHash))
+			tc.mock(mock)
+			svc := service.NewUserService(
+				repository.NewUserRepository(dao.NewUserDAO(db), cachemocks.NewMockUserCache(ctrl)),
+				nil, nil, service.SensitiveModeReject, nil, nil,
+				service.WithLoginHistory(repository.NewLoginEventRepository(dao.NewLoginEventDAO(db))))
+			server := gin.New()
+			server.Use(func(ctx *gin.Context) {
+				ctx.Set("claims", &UserClaims{Uid: 3})
+			})
+			NewUserHandler(svc, svcmocks.NewMockCodeService(ctrl), nil, nil, nil).RegisterRoutes(server)
+
+			req, err := http.NewRequest(http.MethodPost, "/users/me/export_data", strings.NewReader(tc.body))
+			require.NoError(t, err)
+			req.Header.Set("Content-Type", "application/json")
+			resp := httptest.NewRecorder()
+			server.ServeHTTP(resp, req)
+			require.Equal(t, http.StatusOK, resp.Code)
+			assert.NoError(t, mock.ExpectationsWereMet())
+
+			if !tc.wantExport {
+				var res Result
+				require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &res))
+				assert.Equal(t, tc.wantCode, res.Code)
+				return
+			}
+			assert.Equal(t, `attachment; filename="webook-export-3.json"`, resp.Header().Get("Content-Disposition"))
+			assert.Equal(t, "no-store", resp.Header().Get("Cache-Control"))
+			gr, err := gzip.NewReader(resp.Body)
+			require.NoError(t, err)
+			var res domain.UserDataExport
+			require.NoError(t, json.NewDecoder(gr).Decode(&res))
+			assert.Equal(t, int64(3), res.Profile.Id)
+			assert.Equal(t, "Tom", res.Profile.Nickname)
+			assert.Equal(t, []domain.BoundIdentity{
+				{Type: "email", Value: "tom@qq.com"},
+				{Type: "phone", Value: "15212345678"},
+			}, res.Identities)
+			require.Len(t, res.LoginHistory, 2)
+			assert.Equal(t, "10.0.0.2", res.LoginHistory[0].IP)
+			assert.Equal(t, "success", res.LoginHistory[0].Status)
+			assert.True(t, ctime.Add(time.Minute).Equal(res.LoginHistory[0].Ctime))
+		})
+	}
+}
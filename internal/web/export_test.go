@@ -0,0 +1,235 @@
+package web
+
+import (
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"webook/internal/domain"
+	"webook/internal/service"
+	svcmocks "webook/internal/service/mocks"
+)
+
+func TestUserExportHandler_Job(t *testing.T) {
+	testCases := []struct {
+		name string
+		mock func(ctrl *gomock.Controller) service.UserExportService
+		// method 不填就是 GET
+		method     string
+		path       string
+		reauthFail bool
+
+		wantHTTPCode int
+		wantCode     int
+		wantJob      exportJobVO
+	}{
+		{
+			name: "发起导出",
+			mock: func(ctrl *gomock.Controller) service.UserExportService {
+				svc := svcmocks.NewMockUserExportService(ctrl)
+				svc.EXPECT().Start(gomock.Any(), int64(3)).Return("job-1", nil)
+				return svc
+			},
+			method:       http.MethodPost,
+			path:         "/users/me/export",
+			wantHTTPCode: http.StatusAccepted,
+			wantJob:      exportJobVO{JobId: "job-1", Status: "pending"},
+		},
+		{
+			name: "一天只能导出一次",
+			mock: func(ctrl *gomock.Controller) service.UserExportService {
+				svc := svcmocks.NewMockUserExportService(ctrl)
+				svc.EXPECT().Start(gomock.Any(), int64(3)).Return("", service.ErrExportTooFrequent)
+				return svc
+			},
+			method:       http.MethodPost,
+			path:         "/users/me/export",
+			wantHTTPCode: http.StatusOK,
+			wantCode:     4,
+		},
+		{
+			name: "没通过验证不会发起",
+			mock: func(ctrl *gomock.Controller) service.UserExportService {
+				return svcmocks.NewMockUserExportService(ctrl)
+			},
+			method:       http.MethodPost,
+			path:         "/users/me/export",
+			reauthFail:   true,
+			wantHTTPCode: http.StatusOK,
+			wantCode:     4,
+		},
+		{
+			name: "还在生成",
+			mock: func(ctrl *gomock.Controller) service.UserExportService {
+				svc := svcmocks.NewMockUserExportService(ctrl)
+				svc.EXPECT().Get(gomock.Any(), int64(3), "job-1").Return(domain.ExportTask{
+					Id: "job-1", Uid: 3, Status: domain.ExportTaskPending,
+				}, nil)
+				return svc
+			},
+			path:         "/users/me/export/job-1",
+			wantHTTPCode: http.StatusOK,
+			wantJob:      exportJobVO{JobId: "job-1", Status: "pending"},
+		},
+		{
+			name: "生成好了",
+			mock: func(ctrl *gomock.Controller) service.UserExportService {
+				svc := svcmocks.NewMockUserExportService(ctrl)
+				svc.EXPECT().Get(gomock.Any(), int64(3), "job-1").Return(domain.ExportTask{
+					Id: "job-1", Uid: 3, Status: domain.ExportTaskDone, Data: []byte("gz"),
+				}, nil)
+				return svc
+			},
+			path:         "/users/me/export/job-1",
+			wantHTTPCode: http.StatusOK,
+			wantJob:      exportJobVO{JobId: "job-1", Status: "done", URL: "/users/me/export/job-1/archive"},
+		},
+		{
+			name: "任务不存在",
+			mock: func(ctrl *gomock.Controller) service.UserExportService {
+				svc := svcmocks.NewMockUserExportService(ctrl)
+				svc.EXPECT().Get(gomock.Any(), int64(3), "job-1").
+					Return(domain.ExportTask{}, service.ErrExportNotFound)
+				return svc
+			},
+			path:         "/users/me/export/job-1",
+			wantHTTPCode: http.StatusNotFound,
+			wantCode:     4,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			server := gin.New()
+			server.Use(func(ctx *gin.Context) {
+				ctx.Set("claims", &UserClaims{Uid: 3})
+			})
+			NewUserExportHandler(tc.mock(ctrl), fakeReauth(!tc.reauthFail)).RegisterRoutes(server)
+			method := tc.method
+			if method == "" {
+				method = http.MethodGet
+			}
+			req, err := http.NewRequest(method, tc.path, strings.NewReader(`{"password":"hello#world123"}`))
+			require.NoError(t, err)
+			req.Header.Set("Content-Type", "application/json")
+			resp := httptest.NewRecorder()
+			server.ServeHTTP(resp, req)
+			require.Equal(t, tc.wantHTTPCode, resp.Code)
+
+			var res struct {
+				Code int         `json:"code"`
+				Data exportJobVO `json:"data"`
+			}
+			require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &res))
+			assert.Equal(t, tc.wantCode, res.Code)
+			assert.Equal(t, tc.wantJob, res.Data)
+		})
+	}
+}
+
+func TestUserExportHandler_Archive(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	svc := svcmocks.NewMockUserExportService(ctrl)
+	svc.EXPECT().Get(gomock.Any(), int64(3), "job-1").Return(domain.ExportTask{
+		Id: "job-1", Uid: 3, Status: domain.ExportTaskDone,
+		Data: []byte("gz"), FileName: "webook-export-3.json.gz",
+	}, nil)
+	server := gin.New()
+	server.Use(func(ctx *gin.Context) {
+		ctx.Set("claims", &UserClaims{Uid: 3})
+	})
+	NewUserExportHandler(svc, fakeReauth(true)).RegisterRoutes(server)
+	req, err := http.NewRequest(http.MethodGet, "/users/me/export/job-1/archive", nil)
+	require.NoError(t, err)
+	resp := httptest.NewRecorder()
+	server.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, "application/gzip", resp.Header().Get("Content-Type"))
+	assert.Equal(t, `attachment; filename="webook-export-3.json.gz"`, resp.Header().Get("Content-Disposition"))
+	assert.Equal(t, "gz", resp.Body.String())
+}
+
+// fakeReauth false 就是密码或者验证码不对
+type fakeReauth bool
+
+func (f fakeReauth) Reauthenticate(ctx *gin.Context, uid int64, password, code string) bool {
+	if !f {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  "密码不对",
+		})
+	}
+	return bool(f)
+}
+
+func TestUserExportHandler_Start(t *testing.T) {
+	testCases := []struct {
+		name   string
+		mock   func(ctrl *gomock.Controller) service.UserExportService
+		reauth bool
+
+		wantCode int
+		wantData string
+	}{
+		{
+			name: "验证通过",
+			mock: func(ctrl *gomock.Controller) service.UserExportService {
+				svc := svcmocks.NewMockUserExportService(ctrl)
+				svc.EXPECT().Start(gomock.Any(), int64(3)).Return("job-1", nil)
+				return svc
+			},
+			reauth:   true,
+			wantData: "job-1",
+		},
+		{
+			name: "没通过验证不会发起",
+			mock: func(ctrl *gomock.Controller) service.UserExportService {
+				return svcmocks.NewMockUserExportService(ctrl)
+			},
+			wantCode: 4,
+		},
+		{
+			name: "一天只能导出一次",
+			mock: func(ctrl *gomock.Controller) service.UserExportService {
+				svc := svcmocks.NewMockUserExportService(ctrl)
+				svc.EXPECT().Start(gomock.Any(), int64(3)).Return("", service.ErrExportTooFrequent)
+				return svc
+			},
+			reauth:   true,
+			wantCode: 4,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			server := gin.New()
+			server.Use(func(ctx *gin.Context) {
+				ctx.Set("claims", &UserClaims{Uid: 3})
+			})
+			NewUserExportHandler(tc.mock(ctrl), fakeReauth(tc.reauth)).RegisterRoutes(server)
+			req, err := http.NewRequest(http.MethodPost, "/users/export",
+				strings.NewReader(`{"password":"hello#world123"}`))
+			require.NoError(t, err)
+			req.Header.Set("Content-Type", "application/json")
+			resp := httptest.NewRecorder()
+			server.ServeHTTP(resp, req)
+			require.Equal(t, http.StatusOK, resp.Code)
+
+			var res struct {
+				Code int    `json:"code"`
+				Data string `json:"data"`
+			}
+			require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &res))
+			assert.Equal(t, tc.wantCode, res.Code)
+			assert.Equal(t, tc.wantData, res.Data)
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package web
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"net/http"
+	"strconv"
+	"time"
+	"webook/internal/service"
+)
+
+// ErrForbiddenWhileImpersonating 代办登录的 token 调了会修改数据的接口，登录校验的中间件直接拒绝
+var ErrForbiddenWhileImpersonating = errors.New("代办登录的时候不能修改数据")
+
+// ErrDeniedWhileImpersonating 导出数据、API key 这些接口，代办登录的时候看都不能看
+var ErrDeniedWhileImpersonating = errors.New("代办登录的时候不能调这个接口")
+
+// Impersonate 客服代办登录成这个用户，token 放在返回值里面，不放在 x-jwt-token 里面，
+// 不然前端会把管理员自己的 token 换掉
+func (h *AdminHandler) Impersonate(ctx *gin.Context) {
+	uid, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  "输入有误",
+		})
+		return
+	}
+	operator, _ := ClaimsUserId(ctx)
+	imp, err := h.userSvc.Impersonate(ctx, operator, uid)
+	switch err {
+	case nil:
+	case service.ErrImpersonateSelf:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  "不能代办登录自己",
+		})
+		return
+	case service.ErrUserNotFound:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  "用户不存在",
+		})
+		return
+	default:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 5,
+			Msg:  "系统错误",
+		})
+		return
+	}
+	claims := UserClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        imp.Id,
+			IssuedAt:  jwt.NewNumericDate(imp.Ctime),
+			NotBefore: jwt.NewNumericDate(imp.Ctime),
+			ExpiresAt: jwt.NewNumericDate(imp.ExpiresAt),
+		},
+		Uid: imp.Uid,
+		// 客服在自己的浏览器里面用
+		UserAgent:      ctx.Request.UserAgent(),
+		SubjectUserID:  imp.Uid,
+		ImpersonatedBy: imp.AdminId,
+		ClaimsVersion:  CurrentClaimsVersion,
+		Role:           RoleUser,
+		TokenType:      TokenTypeAccess,
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS512, claims).
+		SignedString([]byte("95osj3fUD7fo0mlYdDbncXz4VD2igvf0"))
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 5,
+			Msg:  "系统错误",
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, Result{
+		Data: impersonationVO{
+			Token:          token,
+			Impersonating:  true,
+			Uid:            imp.Uid,
+			ImpersonatedBy: imp.AdminId,
+			ExpiresAt:      imp.ExpiresAt.Format(time.RFC3339),
+		},
+	})
+}
+
+type impersonationVO struct {
+	// 只有申请的时候返回
+	Token          string `json:"token,omitempty"`
+	Impersonating  bool   `json:"impersonating"`
+	Uid            int64  `json:"uid,omitempty"`
+	ImpersonatedBy int64  `json:"impersonated_by,omitempty"`
+	ExpiresAt      string `json:"expires_at,omitempty"`
+}
+
+// Impersonation 前端用来判断要不要显示代办登录的横幅
+func (u *UserHandler) Impersonation(ctx *gin.Context) {
+	val, _ := ctx.Get("claims")
+	claims, ok := valueAs[*UserClaims](val)
+	if !ok || claims == nil || claims.ImpersonatedBy == 0 {
+		ctx.JSON(http.StatusOK, Result{
+			Data: impersonationVO{},
+		})
+		return
+	}
+	vo := impersonationVO{
+		Impersonating:  true,
+		Uid:            claims.SubjectUserID,
+		ImpersonatedBy: claims.ImpersonatedBy,
+	}
+	if claims.ExpiresAt != nil {
+		vo.ExpiresAt = claims.ExpiresAt.Format(time.RFC3339)
+	}
+	ctx.JSON(http.StatusOK, Result{
+		Data: vo,
+	})
+}
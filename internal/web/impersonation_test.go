@@ -0,0 +1,109 @@
+package web
+
+import (
+	"encoding/json"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"webook/internal/repository"
+	"webook/internal/repository/dao"
+	"webook/internal/service"
+)
+
+func TestAdminHandler_Impersonate(t *testing.T) {
+	db, mock := mockFindUser(t, "id", sqlmock.NewRows([]string{"id", "status"}).AddRow(3, 0))
+	mock.ExpectExec("INSERT INTO `admin_audit_logs` .*").
+		WithArgs(int64(1), "impersonate_user", int64(3), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	svc := service.NewUserService(repository.NewUserRepository(dao.NewUserDAO(db), nil),
+		nil, nil, service.SensitiveModeReject, nil, nil)
+	server := gin.New()
+	server.Use(func(ctx *gin.Context) {
+		ctx.Set("claims", &UserClaims{Uid: 1})
+	})
+	NewAdminHandler(svc, nil, nil, nil, nil).RegisterRoutes(server)
+
+	req, err := http.NewRequest(http.MethodPost, "/admin/users/3/impersonate", nil)
+	require.NoError(t, err)
+	req.Header.Set("User-Agent", "support-browser")
+	resp := httptest.NewRecorder()
+	server.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusOK, resp.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+	// 管理员自己的 token 不能被换掉
+	assert.Empty(t, resp.Header().Get("x-jwt-token"))
+
+	var res struct {
+		Code int             `json:"code"`
+		Data impersonationVO `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &res))
+	assert.Equal(t, 0, res.Code)
+	assert.True(t, res.Data.Impersonating)
+	claims := &UserClaims{}
+	_, err = jwt.ParseWithClaims(res.Data.Token, claims, func(token *jwt.Token) (interface{}, error) {
+		return []byte("95osj3fUD7fo0mlYdDbncXz4VD2igvf0"), nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), claims.Uid)
+	assert.Equal(t, int64(3), claims.SubjectUserID)
+	assert.Equal(t, int64(1), claims.ImpersonatedBy)
+	assert.Equal(t, "support-browser", claims.UserAgent)
+	assert.Len(t, claims.ID, 36)
+	assert.Equal(t, service.ImpersonationTTL, claims.ExpiresAt.Sub(claims.IssuedAt.Time))
+}
+
+func TestUserHandler_Impersonation(t *testing.T) {
+	expiresAt := time.Now().Add(10 * time.Minute).Truncate(time.Second)
+	testCases := []struct {
+		name   string
+		claims *UserClaims
+
+		wantVO impersonationVO
+	}{
+		{
+			name: "代办登录",
+			claims: &UserClaims{
+				RegisteredClaims: jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(expiresAt)},
+				Uid:              3,
+				SubjectUserID:    3,
+				ImpersonatedBy:   1,
+			},
+			wantVO: impersonationVO{
+				Impersonating:  true,
+				Uid:            3,
+				ImpersonatedBy: 1,
+				ExpiresAt:      expiresAt.Format(time.RFC3339),
+			},
+		},
+		{
+			name:   "自己登录的",
+			claims: &UserClaims{Uid: 3},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := gin.New()
+			server.Use(func(ctx *gin.Context) {
+				ctx.Set("claims", tc.claims)
+			})
+			NewUserHandler(nil, nil, nil, nil, nil).RegisterRoutes(server)
+			req, err := http.NewRequest(http.MethodGet, "/users/me/impersonation", nil)
+			require.NoError(t, err)
+			resp := httptest.NewRecorder()
+			server.ServeHTTP(resp, req)
+			require.Equal(t, http.StatusOK, resp.Code)
+			var res struct {
+				Data impersonationVO `json:"data"`
+			}
+			require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &res))
+			assert.Equal(t, tc.wantVO, res.Data)
+		})
+	}
+}
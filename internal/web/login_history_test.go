@@ -0,0 +1,163 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-contrib/sessions/memstore"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"webook/internal/domain"
+	"webook/internal/repository"
+	cachemocks "webook/internal/repository/cache/mocks"
+	"webook/internal/repository/dao"
+	"webook/internal/service"
+	"webook/pkg/geoip"
+	"webook/pkg/region"
+)
+
+func TestUserHandler_LoginHistory(t *testing.T) {
+	base := time.Date(2023, 11, 14, 22, 0, 0, 0, time.UTC)
+	testCases := []struct {
+		name  string
+		query string
+
+		// 0 就是不会查
+		wantLimit int
+		wantCode  int
+		wantLen   int
+	}{
+		{name: "默认 20 条", wantLimit: 20, wantLen: 20},
+		{name: "指定条数", query: "?limit=3", wantLimit: 3, wantLen: 3},
+		{name: "最多 100 条", query: "?limit=1000", wantLimit: 100, wantLen: 25},
+		{name: "不是数字", query: "?limit=abc", wantCode: 4},
+		{name: "零", query: "?limit=0", wantCode: 4},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, mock := mockUserDB(t)
+			if tc.wantLimit > 0 {
+				rows := sqlmock.NewRows([]string{"id", "user_id", "ip", "user_agent", "status", "ctime"})
+				for i := 25; i > 25-tc.wantLimit && i > 0; i-- {
+					rows.AddRow(i, 123, fmt.Sprintf("8.8.8.%d", i), "Chrome", 1,
+						base.Add(time.Duration(i)*time.Minute).UnixMilli())
+				}
+				mock.ExpectQuery(fmt.Sprintf("SELECT \\* FROM `login_events` WHERE user_id = \\? "+
+					"ORDER BY ctime DESC, id DESC LIMIT %d", tc.wantLimit)).
+					WithArgs(int64(123)).WillReturnRows(rows)
+			}
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			// 时区从资料里面拿
+			userCache := cachemocks.NewMockUserCache(ctrl)
+			userCache.EXPECT().Get(gomock.Any(), int64(123)).
+				Return(domain.User{Id: 123, Timezone: "Asia/Shanghai"}, nil).AnyTimes()
+			svc := service.NewUserService(repository.NewUserRepository(dao.NewUserDAO(db), userCache),
+				nil, nil, service.SensitiveModeReject, nil, nil,
+				service.WithLoginHistory(repository.NewLoginEventRepository(dao.NewLoginEventDAO(db))))
+			server := gin.New()
+			server.Use(func(ctx *gin.Context) {
+				ctx.Set("claims", &UserClaims{Uid: 123})
+			})
+			NewUserHandler(svc, nil, nil, nil, nil, WithGeoLookup(geoip.NewStub())).RegisterRoutes(server)
+
+			req, err := http.NewRequest(http.MethodGet, "/users/me/login_history"+tc.query, nil)
+			require.NoError(t, err)
+			resp := httptest.NewRecorder()
+			server.ServeHTTP(resp, req)
+			require.Equal(t, http.StatusOK, resp.Code)
+			assert.NoError(t, mock.ExpectationsWereMet())
+
+			var res struct {
+				Code int            `json:"code"`
+				Data []loginEventVO `json:"data"`
+			}
+			require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &res))
+			assert.Equal(t, tc.wantCode, res.Code)
+			require.Len(t, res.Data, tc.wantLen)
+			if tc.wantLen == 0 {
+				return
+			}
+			assert.Equal(t, loginEventVO{
+				Id:        25,
+				IP:        "8.8.8.25",
+				Country:   "未知",
+				UserAgent: "Chrome",
+				Status:    "success",
+				// 按用户自己的时区
+				CreatedAt: "2023-11-15T06:25:00+08:00",
+			}, res.Data[0])
+			assert.Equal(t, int64(26-tc.wantLen), res.Data[tc.wantLen-1].Id)
+		})
+	}
+}
+
+// 找到了用户就要记，不管登录成没成功
+func TestUserHandler_LoginRecordsHistory(t *testing.T) {
+	testCases := []struct {
+		name     string
+		path     string
+		password string
+		// 0 就是没有这个用户
+		uid int64
+
+		// 0 就是不会记
+		wantStatus domain.LoginEventStatus
+	}{
+		{name: "session 登录成功", path: "/users/login", password: "hello#world123", uid: 3,
+			wantStatus: domain.LoginEventStatusSuccess},
+		{name: "session 登录密码不对", path: "/users/login", password: "hello#world456", uid: 3,
+			wantStatus: domain.LoginEventStatusFailed},
+		{name: "JWT 登录成功", path: "/users/login_jwt", password: "hello#world123", uid: 3,
+			wantStatus: domain.LoginEventStatusSuccess},
+		{name: "JWT 登录密码不对", path: "/users/login_jwt", password: "hello#world456", uid: 3,
+			wantStatus: domain.LoginEventStatusFailed},
+		{name: "没有这个用户", path: "/users/login_jwt", password: "hello#world123"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rows := sqlmock.NewRows([]string{"id", "email", "password"})
+			if tc.uid != 0 {
+				rows.AddRow(tc.uid, "tom@qq.com", testPasswordHash)
+			}
+			db, mock := mockFindUser(t, "email", rows)
+			if tc.uid == 0 {
+				// 再看看是不是在注销冷静期里面
+				mock.ExpectQuery("SELECT .* FROM `users` WHERE original_email = .*").
+					WillReturnRows(sqlmock.NewRows([]string{"id"}))
+			}
+			if tc.wantStatus != 0 {
+				mock.ExpectExec("INSERT INTO `login_events` .*").
+					WithArgs(tc.uid, "10.0.0.1", "Chrome", uint8(tc.wantStatus), sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+			}
+			svc := service.NewUserService(repository.NewUserRepository(dao.NewUserDAO(db), nil),
+				nil, nil, service.SensitiveModeReject, nil, nil,
+				service.WithLoginHistory(repository.NewLoginEventRepository(dao.NewLoginEventDAO(db))))
+			h := NewUserHandler(svc, nil, nil, nil, region.NewDictionary())
+			server := gin.New()
+			server.Use(sessions.Sessions("ssid", memstore.NewStore([]byte("secret"))))
+			server.POST("/users/login", h.Login)
+			server.POST("/users/login_jwt", h.LoginJWT)
+
+			req, err := http.NewRequest(http.MethodPost, tc.path,
+				strings.NewReader(fmt.Sprintf(`{"email":"tom@qq.com","password":"%s"}`, tc.password)))
+			require.NoError(t, err)
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("User-Agent", "Chrome")
+			req.RemoteAddr = "10.0.0.1:1234"
+			resp := httptest.NewRecorder()
+			server.ServeHTTP(resp, req)
+			require.Equal(t, http.StatusOK, resp.Code)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package web
+
+import (
+	"encoding/json"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"webook/internal/repository"
+	"webook/internal/repository/dao"
+	"webook/internal/service"
+	"webook/pkg/region"
+)
+
+func TestUserHandler_LoginJWTBody(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("hello#world123"), bcrypt.MinCost)
+	require.NoError(t, err)
+	ctime := time.Date(2023, 5, 20, 10, 0, 0, 0, time.UTC)
+	db := mockFindUserDB(t, "email", sqlmock.NewRows([]string{"id", "email", "password",
+		"nickname", "brief", "gender", "region", "city", "ctime"}).
+		AddRow(3, "tom@qq.com", string(hash), "Tom", "hello", 1, "440000", "440300", ctime.UnixMilli()))
+	svc := service.NewUserService(repository.NewUserRepository(dao.NewUserDAO(db), nil),
+		nil, nil, service.SensitiveModeReject, nil, nil)
+	server := gin.New()
+	server.POST("/users/login_jwt", NewUserHandler(svc, nil, nil, nil, region.NewDictionary()).LoginJWT)
+
+	req, err := http.NewRequest(http.MethodPost, "/users/login_jwt",
+		strings.NewReader(`{"email":"tom@qq.com","password":"hello#world123"}`))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+	server.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusOK, resp.Code)
+
+	var res struct {
+		Code int    `json:"code"`
+		Msg  string `json:"msg"`
+		Data struct {
+			Token     string          `json:"token"`
+			ExpiresAt string          `json:"expires_at"`
+			User      publicProfileVO `json:"user"`
+		} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &res))
+	assert.Equal(t, 0, res.Code)
+	assert.Equal(t, "登录成功", res.Msg)
+	// 老的客户端从 header 拿，两个要一样
+	assert.NotEmpty(t, res.Data.Token)
+	assert.Equal(t, resp.Header().Get("x-jwt-token"), res.Data.Token)
+
+	expiresAt, err := time.Parse(time.RFC3339, res.Data.ExpiresAt)
+	require.NoError(t, err)
+	assert.True(t, expiresAt.After(time.Now()))
+	claims := &UserClaims{}
+	_, err = jwt.ParseWithClaims(res.Data.Token, claims, func(token *jwt.Token) (interface{}, error) {
+		return []byte("95osj3fUD7fo0mlYdDbncXz4VD2igvf0"), nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), claims.Uid)
+	assert.Equal(t, claims.ExpiresAt.Unix(), expiresAt.Unix())
+
+	assert.Equal(t, publicProfileVO{
+		Id:       3,
+		Nickname: "Tom",
+		Brief:    "hello",
+		Gender:   "male",
+		Region:   regionVO{Code: "440000", Name: "广东省"},
+		City:     regionVO{Code: "440300", Name: "深圳市"},
+		Joined:   "2023-05",
+	}, res.Data.User)
+
+	// 公开的资料里面不能有邮箱
+	var raw struct {
+		Data struct {
+			User map[string]any `json:"user"`
+		} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &raw))
+	assert.NotContains(t, raw.Data.User, "email")
+}
@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strings"
+	"webook/internal/web"
+)
+
+// AdminMiddlewareBuilder 校验 /admin 下面的接口是不是管理员在调用。
+// 它要放在登录校验的后面，依赖 claims
+type AdminMiddlewareBuilder struct {
+	uids map[int64]struct{}
+}
+
+func NewAdminMiddlewareBuilder(uids []int64) *AdminMiddlewareBuilder {
+	m := make(map[int64]struct{}, len(uids))
+	for _, uid := range uids {
+		m[uid] = struct{}{}
+	}
+	return &AdminMiddlewareBuilder{
+		uids: m,
+	}
+}
+
+func (b *AdminMiddlewareBuilder) Build() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if !strings.HasPrefix(ctx.Request.URL.Path, "/admin/") {
+			return
+		}
+		uid, ok := web.ClaimsUserId(ctx)
+		if !ok {
+			ctx.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		if _, ok = b.uids[uid]; !ok {
+			ctx.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+		// 代办登录成了管理员也不行，管理员自己的 token 不会带这个
+		if _, ok = web.ImpersonatedBy(ctx); ok {
+			ctx.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"webook/internal/web"
+)
+
+func TestAdminMiddlewareBuilder(t *testing.T) {
+	testCases := []struct {
+		name   string
+		claims *web.UserClaims
+
+		wantCode int
+	}{
+		{
+			name:     "管理员",
+			claims:   &web.UserClaims{Uid: 1},
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "不是管理员",
+			claims:   &web.UserClaims{Uid: 3},
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "没登录",
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			// 代办登录成了另一个管理员
+			name:     "代办登录",
+			claims:   &web.UserClaims{Uid: 1, SubjectUserID: 1, ImpersonatedBy: 2},
+			wantCode: http.StatusForbidden,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := gin.New()
+			server.Use(func(ctx *gin.Context) {
+				if tc.claims != nil {
+					ctx.Set("claims", tc.claims)
+				}
+			})
+			server.Use(NewAdminMiddlewareBuilder([]int64{1, 2}).Build())
+			server.POST("/admin/users/3/impersonate", func(ctx *gin.Context) {
+				ctx.Status(http.StatusOK)
+			})
+			req, err := http.NewRequest(http.MethodPost, "/admin/users/3/impersonate", nil)
+			require.NoError(t, err)
+			resp := httptest.NewRecorder()
+			server.ServeHTTP(resp, req)
+			assert.Equal(t, tc.wantCode, resp.Code)
+		})
+	}
+}
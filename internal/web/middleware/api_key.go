@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"log"
+	"net/http"
+	"strings"
+	"webook/internal/service"
+	"webook/internal/web"
+)
+
+// APIKeyMiddlewareBuilder 用 API key 校验登录态。
+// 它要放在 LoginJWTMiddlewareBuilder 前面，
+// Authorization 不是 API key 的请求，它直接放过去交给后面的 JWT 校验
+type APIKeyMiddlewareBuilder struct {
+	svc service.APIKeyService
+}
+
+func NewAPIKeyMiddlewareBuilder(svc service.APIKeyService) *APIKeyMiddlewareBuilder {
+	return &APIKeyMiddlewareBuilder{
+		svc: svc,
+	}
+}
+
+func (b *APIKeyMiddlewareBuilder) Build() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		segs := strings.Split(ctx.GetHeader("Authorization"), " ")
+		if len(segs) != 2 || segs[0] != "Bearer" ||
+			!strings.HasPrefix(segs[1], service.APIKeyPrefix) {
+			// 不是 API key
+			return
+		}
+		k, err := b.svc.Authenticate(ctx, segs[1])
+		if err == service.ErrInvalidAPIKey {
+			ctx.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		if err != nil {
+			log.Println("校验 API key 失败", err)
+			ctx.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		// 跟 JWT 一样放一个 claims 进去，后面的 handler 就不用区分了
+		ctx.Set("claims", &web.UserClaims{
+			Uid:           k.UserId,
+			UserAgent:     ctx.Request.UserAgent(),
+			ClaimsVersion: web.CurrentClaimsVersion,
+			Role:          web.RoleUser,
+			TokenType:     web.TokenTypeAPIKey,
+		})
+		ctx.Set("apiKey", k)
+	}
+}
@@ -0,0 +1,101 @@
+package middleware
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"webook/internal/domain"
+	"webook/internal/service"
+	svcmocks "webook/internal/service/mocks"
+	"webook/internal/web"
+)
+
+func TestAPIKeyMiddlewareBuilder_Build(t *testing.T) {
+	testCases := []struct {
+		name string
+		mock func(ctrl *gomock.Controller) service.APIKeyService
+
+		authorization string
+
+		wantCode int
+		// 0 代表没有 claims
+		wantUid int64
+	}{
+		{
+			name: "校验通过",
+			mock: func(ctrl *gomock.Controller) service.APIKeyService {
+				svc := svcmocks.NewMockAPIKeyService(ctrl)
+				svc.EXPECT().Authenticate(gomock.Any(), "apk_abc").
+					Return(domain.APIKey{Id: 1, UserId: 123}, nil)
+				return svc
+			},
+			authorization: "Bearer apk_abc",
+			wantCode:      http.StatusOK,
+			wantUid:       123,
+		},
+		{
+			name: "key 无效或者过期",
+			mock: func(ctrl *gomock.Controller) service.APIKeyService {
+				svc := svcmocks.NewMockAPIKeyService(ctrl)
+				svc.EXPECT().Authenticate(gomock.Any(), "apk_abc").
+					Return(domain.APIKey{}, service.ErrInvalidAPIKey)
+				return svc
+			},
+			authorization: "Bearer apk_abc",
+			wantCode:      http.StatusUnauthorized,
+		},
+		{
+			name: "系统错误",
+			mock: func(ctrl *gomock.Controller) service.APIKeyService {
+				svc := svcmocks.NewMockAPIKeyService(ctrl)
+				svc.EXPECT().Authenticate(gomock.Any(), "apk_abc").
+					Return(domain.APIKey{}, errors.New("mock 错误"))
+				return svc
+			},
+			authorization: "Bearer apk_abc",
+			wantCode:      http.StatusInternalServerError,
+		},
+		{
+			name: "不是 API key，交给后面",
+			mock: func(ctrl *gomock.Controller) service.APIKeyService {
+				return svcmocks.NewMockAPIKeyService(ctrl)
+			},
+			authorization: "Bearer eyJhbGciOi",
+			wantCode:      http.StatusOK,
+		},
+		{
+			name: "没有 Authorization",
+			mock: func(ctrl *gomock.Controller) service.APIKeyService {
+				return svcmocks.NewMockAPIKeyService(ctrl)
+			},
+			wantCode: http.StatusOK,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			server := gin.New()
+			server.Use(NewAPIKeyMiddlewareBuilder(tc.mock(ctrl)).Build())
+			var uid int64
+			server.GET("/test", func(ctx *gin.Context) {
+				if c, ok := ctx.Get("claims"); ok {
+					uid = c.(*web.UserClaims).Uid
+				}
+				ctx.Status(http.StatusOK)
+			})
+			req, err := http.NewRequest(http.MethodGet, "/test", nil)
+			require.NoError(t, err)
+			req.Header.Set("Authorization", tc.authorization)
+			resp := httptest.NewRecorder()
+			server.ServeHTTP(resp, req)
+			assert.Equal(t, tc.wantCode, resp.Code)
+			assert.Equal(t, tc.wantUid, uid)
+		})
+	}
+}
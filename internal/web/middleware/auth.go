@@ -0,0 +1,101 @@
+package middleware
+
+import (
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"webook/internal/web"
+)
+
+// AuthSource 登录态从哪里来
+type AuthSource string
+
+const (
+	AuthSourceJWT     AuthSource = "jwt"
+	AuthSourceSession AuthSource = "session"
+)
+
+// AuthMiddlewareBuilder 同一个服务同时支持 JWT 和 session 登录，handler 统一用 web.ContextUserID 拿用户 ID。
+// 按 Order 的顺序看请求带了哪一种凭证，用第一种带了的去校验，校验失败不会再换另一种。
+// 都没带的时候交给排在最前面的那一种处理，不需要登录的路径、OptionalPaths 都在各自的 builder 上面配
+type AuthMiddlewareBuilder struct {
+	jwt     *LoginJWTMiddlewareBuilder
+	session *LoginMiddlewareBuilder
+	order   []AuthSource
+}
+
+// NewAuthMiddlewareBuilder 不用哪一种就传 nil。默认先看 JWT 再看 session
+func NewAuthMiddlewareBuilder(jwt *LoginJWTMiddlewareBuilder, session *LoginMiddlewareBuilder) *AuthMiddlewareBuilder {
+	return &AuthMiddlewareBuilder{
+		jwt:     jwt,
+		session: session,
+		order:   []AuthSource{AuthSourceJWT, AuthSourceSession},
+	}
+}
+
+// Order 两种凭证都带了的时候先用哪一种
+func (b *AuthMiddlewareBuilder) Order(sources ...AuthSource) *AuthMiddlewareBuilder {
+	b.order = sources
+	return b
+}
+
+type authHandler struct {
+	// hasCredential 请求里面有没有这一种凭证
+	hasCredential func(ctx *gin.Context) bool
+	handle        gin.HandlerFunc
+}
+
+func (b *AuthMiddlewareBuilder) Build() gin.HandlerFunc {
+	handlers := make([]authHandler, 0, len(b.order))
+	for _, source := range b.order {
+		switch {
+		case source == AuthSourceJWT && b.jwt != nil:
+			handlers = append(handlers, authHandler{
+				hasCredential: hasJWTCredential,
+				handle:        b.jwt.Build(),
+			})
+		case source == AuthSourceSession && b.session != nil:
+			handlers = append(handlers, authHandler{
+				hasCredential: hasSessionCredential,
+				handle:        b.session.Build(),
+			})
+		}
+	}
+	return func(ctx *gin.Context) {
+		if len(handlers) == 0 {
+			ctx.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		hdl := handlers[0]
+		for _, h := range handlers {
+			if h.hasCredential(ctx) {
+				hdl = h
+				break
+			}
+		}
+		hdl.handle(ctx)
+		if ctx.IsAborted() {
+			return
+		}
+		if uid, ok := web.ContextUserID(ctx); ok {
+			web.SetContextUserID(ctx, uid)
+		}
+	}
+}
+
+// hasJWTCredential 记住我的 cookie 也算，JWT 中间件会用它换一个新的 JWT
+func hasJWTCredential(ctx *gin.Context) bool {
+	if ctx.GetHeader("Authorization") != "" {
+		return true
+	}
+	_, _, ok := web.RememberMeFromCookie(ctx)
+	return ok
+}
+
+func hasSessionCredential(ctx *gin.Context) bool {
+	// 没有用 session 的中间件的时候 sessions.Default 会 panic
+	if _, ok := ctx.Get(sessions.DefaultKey); !ok {
+		return false
+	}
+	return sessions.Default(ctx).Get("userId") != nil
+}
@@ -0,0 +1,120 @@
+package middleware
+
+import (
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-contrib/sessions/memstore"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"webook/internal/web"
+)
+
+func TestAuthMiddlewareBuilder(t *testing.T) {
+	testCases := []struct {
+		name  string
+		order []AuthSource
+		path  string
+		// 带不带 JWT（uid 123），session 里面有没有登录（uid 456）
+		withJWT     bool
+		withSession bool
+
+		wantCode int
+		wantUid  int64
+	}{
+		{
+			name:     "只有 JWT",
+			path:     "/users/profile",
+			withJWT:  true,
+			wantCode: http.StatusOK,
+			wantUid:  123,
+		},
+		{
+			name:        "只有 session",
+			path:        "/users/profile",
+			withSession: true,
+			wantCode:    http.StatusOK,
+			wantUid:     456,
+		},
+		{
+			name:        "都有，默认先用 JWT",
+			path:        "/users/profile",
+			withJWT:     true,
+			withSession: true,
+			wantCode:    http.StatusOK,
+			wantUid:     123,
+		},
+		{
+			name:        "都有，配置了先用 session",
+			order:       []AuthSource{AuthSourceSession, AuthSourceJWT},
+			path:        "/users/profile",
+			withJWT:     true,
+			withSession: true,
+			wantCode:    http.StatusOK,
+			wantUid:     456,
+		},
+		{
+			name:     "都没有",
+			path:     "/users/profile",
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name:     "都没有，不需要登录的路径",
+			path:     "/users/login",
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "只配置了 session",
+			order:    []AuthSource{AuthSourceSession},
+			path:     "/users/profile",
+			withJWT:  true,
+			wantCode: http.StatusUnauthorized,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := gin.New()
+			server.Use(sessions.Sessions("mysession", memstore.NewStore([]byte("secret"))))
+			// 假装之前用 session 登录过
+			server.Use(func(ctx *gin.Context) {
+				if tc.withSession {
+					sessions.Default(ctx).Set("userId", int64(456))
+				}
+			})
+			builder := NewAuthMiddlewareBuilder(
+				NewLoginJWTMiddlewareBuilder().IgnorePaths("/users/login"),
+				NewLoginMiddlewareBuilder().IgnorePaths("/users/login"))
+			if tc.order != nil {
+				builder.Order(tc.order...)
+			}
+			server.Use(builder.Build())
+			var uid int64
+			server.GET(tc.path, func(ctx *gin.Context) {
+				uid, _ = web.ContextUserID(ctx)
+				ctx.Status(http.StatusOK)
+			})
+
+			req, err := http.NewRequest(http.MethodGet, tc.path, nil)
+			require.NoError(t, err)
+			if tc.withJWT {
+				token, err := jwt.NewWithClaims(jwt.SigningMethodHS512, web.UserClaims{
+					RegisteredClaims: jwt.RegisteredClaims{
+						ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+					},
+					Uid:           123,
+					ClaimsVersion: web.CurrentClaimsVersion,
+				}).SignedString([]byte("95osj3fUD7fo0mlYdDbncXz4VD2igvf0"))
+				require.NoError(t, err)
+				req.Header.Set("Authorization", "Bearer "+token)
+			}
+			resp := httptest.NewRecorder()
+			server.ServeHTTP(resp, req)
+			assert.Equal(t, tc.wantCode, resp.Code)
+			assert.Equal(t, tc.wantUid, uid)
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestInternalAuthMiddlewareBuilder(t *testing.T) {
+	testCases := []struct {
+		name   string
+		tokens []string
+		path   string
+		token  string
+
+		wantCode int
+	}{
+		{
+			name:     "token 对了",
+			tokens:   []string{"t1"},
+			path:     "/users/batch",
+			token:    "t1",
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "换 token 的时候旧的也能用",
+			tokens:   []string{"t2", "t1"},
+			path:     "/users/batch",
+			token:    "t1",
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "token 不对",
+			tokens:   []string{"t1"},
+			path:     "/users/batch",
+			token:    "t2",
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name:     "没有 token",
+			tokens:   []string{"t1"},
+			path:     "/users/batch",
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name:     "没有配置 token",
+			path:     "/users/batch",
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name:     "配置了空的 token",
+			tokens:   []string{""},
+			path:     "/users/batch",
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name:     "别的路径不管",
+			tokens:   []string{"t1"},
+			path:     "/users/profile",
+			wantCode: http.StatusOK,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := gin.New()
+			server.Use(NewInternalAuthMiddlewareBuilder(tc.tokens).Paths("/users/batch").Build())
+			server.POST(tc.path, func(ctx *gin.Context) {
+				ctx.Status(http.StatusOK)
+			})
+			req, err := http.NewRequest(http.MethodPost, tc.path, nil)
+			require.NoError(t, err)
+			if tc.token != "" {
+				req.Header.Set(InternalTokenHeader, tc.token)
+			}
+			resp := httptest.NewRecorder()
+			server.ServeHTTP(resp, req)
+			assert.Equal(t, tc.wantCode, resp.Code)
+		})
+	}
+}
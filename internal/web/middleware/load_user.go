@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"log"
+	"net/http"
+	"webook/internal/domain"
+	"webook/internal/service"
+	"webook/internal/web"
+)
+
+// UserLoader *service.UserService 实现了这个接口，查的是带缓存的资料
+type UserLoader interface {
+	GetProfile(ctx context.Context, id int64) (domain.User, error)
+}
+
+// LoadUserMiddlewareBuilder 放在登录校验的后面，把当前用户的资料查出来放进 gin.Context，
+// handler 里面用 web.MustUser 拿，不用自己再调一次 GetProfile
+type LoadUserMiddlewareBuilder struct {
+	loader   UserLoader
+	paths    map[string]struct{}
+	optional bool
+}
+
+func NewLoadUserMiddlewareBuilder(loader UserLoader) *LoadUserMiddlewareBuilder {
+	return &LoadUserMiddlewareBuilder{
+		loader: loader,
+		paths:  make(map[string]struct{}),
+	}
+}
+
+// Paths 只有这些路径才去查，没有设置就是所有的路径都查
+func (b *LoadUserMiddlewareBuilder) Paths(paths ...string) *LoadUserMiddlewareBuilder {
+	for _, p := range paths {
+		b.paths[p] = struct{}{}
+	}
+	return b
+}
+
+// Optional 没登录、用户已经不在了或者查询出错都当没有登录，继续往下走，
+// handler 要用 web.ContextUser 自己判断。默认是直接返回 401
+func (b *LoadUserMiddlewareBuilder) Optional() *LoadUserMiddlewareBuilder {
+	b.optional = true
+	return b
+}
+
+func (b *LoadUserMiddlewareBuilder) Build() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if len(b.paths) > 0 {
+			if _, ok := b.paths[ctx.Request.URL.Path]; !ok {
+				return
+			}
+		}
+		uid, ok := web.ContextUserID(ctx)
+		if !ok {
+			if !b.optional {
+				ctx.AbortWithStatus(http.StatusUnauthorized)
+			}
+			return
+		}
+		user, err := b.loader.GetProfile(ctx, uid)
+		switch err {
+		case nil:
+			web.SetContextUser(ctx, user)
+		case service.ErrUserNotFound:
+			// 登录态还在，但是账号已经没有了
+			if !b.optional {
+				ctx.AbortWithStatus(http.StatusUnauthorized)
+			}
+		default:
+			log.Println("查询当前用户失败", uid, err)
+			if !b.optional {
+				ctx.AbortWithStatus(http.StatusInternalServerError)
+			}
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package middleware
+
+import (
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	gormMysql "gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"webook/internal/domain"
+	"webook/internal/repository"
+	"webook/internal/repository/cache"
+	cachemocks "webook/internal/repository/cache/mocks"
+	"webook/internal/repository/dao"
+	"webook/internal/service"
+	"webook/internal/web"
+)
+
+func TestLoadUserMiddlewareBuilder(t *testing.T) {
+	query := "SELECT \\* FROM `users` WHERE id = \\?"
+	testCases := []struct {
+		name     string
+		optional bool
+		// 0 就是没登录
+		uid  int64
+		mock func(ctrl *gomock.Controller, mock sqlmock.Sqlmock) cache.UserCache
+
+		wantCode int
+		wantUser *domain.User
+	}{
+		{
+			name: "缓存命中，不查数据库",
+			uid:  3,
+			mock: func(ctrl *gomock.Controller, mock sqlmock.Sqlmock) cache.UserCache {
+				c := cachemocks.NewMockUserCache(ctrl)
+				c.EXPECT().Get(gomock.Any(), int64(3)).Return(domain.User{Id: 3, Nickname: "Tom"}, nil)
+				return c
+			},
+			wantCode: http.StatusOK,
+			wantUser: &domain.User{Id: 3, Nickname: "Tom"},
+		},
+		{
+			name: "缓存没有，查数据库再回写",
+			uid:  3,
+			mock: func(ctrl *gomock.Controller, mock sqlmock.Sqlmock) cache.UserCache {
+				c := cachemocks.NewMockUserCache(ctrl)
+				c.EXPECT().Get(gomock.Any(), int64(3)).Return(domain.User{}, cache.ErrKeyNotExist)
+				mock.ExpectQuery(query).WillReturnRows(
+					sqlmock.NewRows([]string{"id", "nickname"}).AddRow(3, "Tom"))
+				c.EXPECT().Set(gomock.Any(), domain.User{Id: 3, Nickname: "Tom"}).Return(nil)
+				return c
+			},
+			wantCode: http.StatusOK,
+			wantUser: &domain.User{Id: 3, Nickname: "Tom"},
+		},
+		{
+			name: "账号已经没有了",
+			uid:  3,
+			mock: func(ctrl *gomock.Controller, mock sqlmock.Sqlmock) cache.UserCache {
+				c := cachemocks.NewMockUserCache(ctrl)
+				c.EXPECT().Get(gomock.Any(), int64(3)).Return(domain.User{}, cache.ErrKeyNotExist)
+				mock.ExpectQuery(query).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+				return c
+			},
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name: "没登录",
+			mock: func(ctrl *gomock.Controller, mock sqlmock.Sqlmock) cache.UserCache {
+				return cachemocks.NewMockUserCache(ctrl)
+			},
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name:     "Optional 没登录继续往下走",
+			optional: true,
+			mock: func(ctrl *gomock.Controller, mock sqlmock.Sqlmock) cache.UserCache {
+				return cachemocks.NewMockUserCache(ctrl)
+			},
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "Optional 账号没有了也继续往下走",
+			optional: true,
+			uid:      3,
+			mock: func(ctrl *gomock.Controller, mock sqlmock.Sqlmock) cache.UserCache {
+				c := cachemocks.NewMockUserCache(ctrl)
+				c.EXPECT().Get(gomock.Any(), int64(3)).Return(domain.User{}, cache.ErrKeyNotExist)
+				mock.ExpectQuery(query).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+				return c
+			},
+			wantCode: http.StatusOK,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			mockDB, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			db, err := gorm.Open(gormMysql.New(gormMysql.Config{
+				Conn:                      mockDB,
+				SkipInitializeWithVersion: true,
+			}), &gorm.Config{
+				DisableAutomaticPing:   true,
+				SkipDefaultTransaction: true,
+			})
+			require.NoError(t, err)
+			svc := service.NewUserService(repository.NewUserRepository(dao.NewUserDAO(db), tc.mock(ctrl, mock)),
+				nil, nil, service.SensitiveModeReject, nil, nil)
+
+			builder := NewLoadUserMiddlewareBuilder(svc).Paths("/users/profile")
+			if tc.optional {
+				builder = builder.Optional()
+			}
+			server := gin.New()
+			server.Use(func(ctx *gin.Context) {
+				if tc.uid > 0 {
+					ctx.Set("claims", &web.UserClaims{Uid: tc.uid})
+				}
+			}, builder.Build())
+			var (
+				user   domain.User
+				loaded bool
+			)
+			server.GET("/users/profile", func(ctx *gin.Context) {
+				user, loaded = web.ContextUser(ctx)
+				if loaded {
+					assert.Equal(t, user, web.MustUser(ctx))
+				}
+			})
+			req, err := http.NewRequest(http.MethodGet, "/users/profile", nil)
+			require.NoError(t, err)
+			resp := httptest.NewRecorder()
+			server.ServeHTTP(resp, req)
+
+			assert.Equal(t, tc.wantCode, resp.Code)
+			if tc.wantUser != nil {
+				require.True(t, loaded)
+				assert.Equal(t, *tc.wantUser, user)
+			} else {
+				assert.False(t, loaded)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+// 不在 Paths 里面的不查
+func TestLoadUserMiddlewareBuilder_Paths(t *testing.T) {
+	server := gin.New()
+	server.Use(func(ctx *gin.Context) {
+		ctx.Set("claims", &web.UserClaims{Uid: 3})
+	}, NewLoadUserMiddlewareBuilder(nil).Paths("/users/profile").Build())
+	server.GET("/hello", func(ctx *gin.Context) {
+		_, ok := web.ContextUser(ctx)
+		assert.False(t, ok)
+		assert.Panics(t, func() {
+			web.MustUser(ctx)
+		})
+	})
+	req, err := http.NewRequest(http.MethodGet, "/hello", nil)
+	require.NoError(t, err)
+	resp := httptest.NewRecorder()
+	server.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusOK, resp.Code)
+}
@@ -11,6 +11,9 @@ import (
 // LoginMiddlewareBuilder 扩展性
 type LoginMiddlewareBuilder struct {
 	paths []string
+	// 没登录也放行
+	optionalPaths []string
+	checker       RevokeChecker
 }
 
 func NewLoginMiddlewareBuilder() *LoginMiddlewareBuilder {
@@ -21,6 +24,17 @@ func (l *LoginMiddlewareBuilder) IgnorePaths(path string) *LoginMiddlewareBuilde
 	return l
 }
 
+// OptionalPaths 这些路径没登录也能访问，登录了的话 handler 一样能拿到 userId
+func (l *LoginMiddlewareBuilder) OptionalPaths(paths ...string) *LoginMiddlewareBuilder {
+	l.optionalPaths = append(l.optionalPaths, paths...)
+	return l
+}
+
+func (l *LoginMiddlewareBuilder) RevokeChecker(checker RevokeChecker) *LoginMiddlewareBuilder {
+	l.checker = checker
+	return l
+}
+
 func (l *LoginMiddlewareBuilder) Build() gin.HandlerFunc {
 	// 用 Go 的方式编码解码
 	gob.Register(time.Now())
@@ -36,13 +50,35 @@ func (l *LoginMiddlewareBuilder) Build() gin.HandlerFunc {
 		//	ctx.Request.URL.Path == "/users/signup" {
 		//	return
 		//}
+		optional := false
+		for _, path := range l.optionalPaths {
+			if ctx.Request.URL.Path == path {
+				optional = true
+				break
+			}
+		}
 		sess := sessions.Default(ctx)
 		id := sess.Get("userId")
 		if id == nil {
+			if optional {
+				return
+			}
 			// 没有登录
 			ctx.AbortWithStatus(http.StatusUnauthorized)
 			return
 		}
+		if uid, ok := id.(int64); ok && l.checker != nil {
+			revoked, err := l.checker.IsRevoked(ctx, uid)
+			if err != nil || revoked {
+				if optional {
+					// 只是这次请求里面当没登录，不保存
+					sess.Delete("userId")
+					return
+				}
+				ctx.AbortWithStatus(http.StatusUnauthorized)
+				return
+			}
+		}
 
 		updateTime := sess.Get("update_time")
 		sess.Set("userId", id)
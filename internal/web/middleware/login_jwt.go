@@ -1,7 +1,9 @@
 package middleware
 
 import (
+	"basic-go/webook/internal/service"
 	"basic-go/webook/internal/web"
+	"context"
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 	"log"
@@ -13,6 +15,21 @@ import (
 // LoginJWTMiddlewareBuilder JWT 登录校验
 type LoginJWTMiddlewareBuilder struct {
 	paths []string
+	// 登录了就解析出来，没登录也放行
+	optionalPaths []string
+	checker       RevokeChecker
+	rememberMe    service.RememberMeService
+	sessions      service.SessionService
+	nonces        service.JWTNonceService
+	// 低于这个版本的 JWT 当没登录
+	minClaimsVersion int
+	// 机器之间的时钟误差，iat、nbf、exp 都会放宽这么多
+	clockSkew time.Duration
+}
+
+// RevokeChecker 检查用户的登录态是不是已经被强制失效了，比如说注销了账号
+type RevokeChecker interface {
+	IsRevoked(ctx context.Context, uid int64) (bool, error)
 }
 
 func NewLoginJWTMiddlewareBuilder() *LoginJWTMiddlewareBuilder {
@@ -24,6 +41,50 @@ func (l *LoginJWTMiddlewareBuilder) IgnorePaths(path string) *LoginJWTMiddleware
 	return l
 }
 
+// OptionalPaths 这些路径没登录也能访问，登录了的话 handler 一样能拿到 claims。
+// token 过期了、被踢下线了也当成没登录，不会返回 401
+func (l *LoginJWTMiddlewareBuilder) OptionalPaths(paths ...string) *LoginJWTMiddlewareBuilder {
+	l.optionalPaths = append(l.optionalPaths, paths...)
+	return l
+}
+
+func (l *LoginJWTMiddlewareBuilder) RevokeChecker(checker RevokeChecker) *LoginJWTMiddlewareBuilder {
+	l.checker = checker
+	return l
+}
+
+// MinClaimsVersion UserClaims 的版本低于 v 的 token 不认，要重新登录（或者用记住我换一个新的）
+func (l *LoginJWTMiddlewareBuilder) MinClaimsVersion(v int) *LoginJWTMiddlewareBuilder {
+	l.minClaimsVersion = v
+	return l
+}
+
+// ClockSkew 签发的机器时钟快一点的话，token 在这边看起来是将来签发的。
+// 在 d 以内的都认，超过了就当没登录。exp 也会放宽 d
+func (l *LoginJWTMiddlewareBuilder) ClockSkew(d time.Duration) *LoginJWTMiddlewareBuilder {
+	l.clockSkew = d
+	return l
+}
+
+// RememberMe JWT 没有或者过期了的时候，用记住我的 cookie 恢复登录态
+func (l *LoginJWTMiddlewareBuilder) RememberMe(svc service.RememberMeService) *LoginJWTMiddlewareBuilder {
+	l.rememberMe = svc
+	return l
+}
+
+// Sessions JWT 里面带了 ssid 的话，检查这台设备是不是已经退出登录了。
+// 记住我恢复登录态的时候也会建一个新的会话
+func (l *LoginJWTMiddlewareBuilder) Sessions(svc service.SessionService) *LoginJWTMiddlewareBuilder {
+	l.sessions = svc
+	return l
+}
+
+// Nonces JWT 里面带了 nonce 的话，检查它是不是已经被删掉了。续约的时候会换一个新的 nonce
+func (l *LoginJWTMiddlewareBuilder) Nonces(svc service.JWTNonceService) *LoginJWTMiddlewareBuilder {
+	l.nonces = svc
+	return l
+}
+
 func (l *LoginJWTMiddlewareBuilder) Build() gin.HandlerFunc {
 	// 用 Go 的方式编码解码
 	return func(ctx *gin.Context) {
@@ -33,59 +94,259 @@ func (l *LoginJWTMiddlewareBuilder) Build() gin.HandlerFunc {
 				return
 			}
 		}
-		// 我现在用 JWT 来校验
-		tokenHeader := ctx.GetHeader("Authorization")
-		if tokenHeader == "" {
-			// 没登录
-			ctx.AbortWithStatus(http.StatusUnauthorized)
+		// 前面的 API key 校验已经通过了，API key 没有 nonce 和会话，
+		// 但是账号被封禁、注销、合并了也要跟着失效
+		if val, ok := ctx.Get("claims"); ok {
+			if claims, ok := val.(*web.UserClaims); ok && claims.TokenType == web.TokenTypeAPIKey {
+				l.checkAPIKey(ctx, claims)
+			}
 			return
 		}
-		//segs := strings.SplitN(tokenHeader, " ", 2)
-		segs := strings.Split(tokenHeader, " ")
-		if len(segs) != 2 {
-			// 没登录，有人瞎搞
+		unauthorized := func() {
 			ctx.AbortWithStatus(http.StatusUnauthorized)
-			return
 		}
-		tokenStr := segs[1]
-		claims := &web.UserClaims{}
-		// ParseWithClaims 里面，一定要传入指针
-		token, err := jwt.ParseWithClaims(tokenStr, claims, func(token *jwt.Token) (interface{}, error) {
-			return []byte("95osj3fUD7fo0mlYdDbncXz4VD2igvf0"), nil
-		})
-		if err != nil {
-			// 没登录
-			ctx.AbortWithStatus(http.StatusUnauthorized)
-			return
+		for _, path := range l.optionalPaths {
+			if ctx.Request.URL.Path == path {
+				// 没登录就当匿名用户，直接放行
+				unauthorized = func() {}
+				break
+			}
+		}
+		claims, ok := l.parseClaims(ctx)
+		// 记住我刚建的会话，不用再检查
+		refreshed := false
+		if !ok && l.rememberMe != nil {
+			// JWT 没有或者过期了，看看有没有记住我
+			claims, ok = l.refreshByRememberMe(ctx)
+			refreshed = ok
 		}
-		//claims.ExpiresAt.Time.Before(time.Now()) {
-		//	// 过期了
-		//}
-		// err 为 nil，token 不为 nil
-		if token == nil || !token.Valid || claims.Uid == 0 {
+		if !ok {
 			// 没登录
-			ctx.AbortWithStatus(http.StatusUnauthorized)
+			unauthorized()
 			return
 		}
-		if claims.UserAgent != ctx.Request.UserAgent() {
-			// 严重的安全问题
-			// 你是要监控
-			ctx.AbortWithStatus(http.StatusUnauthorized)
-			return
+		if l.checker != nil {
+			revoked, err := l.checker.IsRevoked(ctx, claims.Uid)
+			if err != nil {
+				// 要不要放行？这里选择保守一点
+				log.Println("检查登录态失败", err)
+				unauthorized()
+				return
+			}
+			if revoked {
+				unauthorized()
+				return
+			}
+		}
+		// 签名对了还要看 nonce 在不在，退出登录的时候会删掉
+		if l.nonces != nil && claims.Nonce != "" && !refreshed {
+			alive, err := l.nonces.Check(ctx, claims.Uid, claims.Nonce)
+			if err != nil {
+				log.Println("检查 jwt nonce 失败", err)
+				unauthorized()
+				return
+			}
+			if !alive {
+				unauthorized()
+				return
+			}
+		}
+		// 老的 token 没有 ssid，等它过期就好了
+		if l.sessions != nil && claims.Ssid != "" && !refreshed {
+			alive, err := l.sessions.Check(ctx, claims.Uid, claims.Ssid)
+			if err != nil {
+				log.Println("检查会话失败", err)
+				unauthorized()
+				return
+			}
+			if !alive {
+				// 这台设备已经退出登录了
+				unauthorized()
+				return
+			}
+		}
+
+		// 代办登录只能看，不能改，有些 GET 也不能调
+		if claims.ImpersonatedBy != 0 {
+			if err := impersonationDenied(ctx.Request); err != nil {
+				ctx.AbortWithStatusJSON(http.StatusForbidden, web.Result{
+					Code: 4,
+					Msg:  err.Error(),
+				})
+				return
+			}
 		}
 
 		now := time.Now()
-		// 每十秒钟刷新一次
-		if claims.ExpiresAt.Sub(now) < time.Second*50 {
-			claims.ExpiresAt = jwt.NewNumericDate(time.Now().Add(time.Minute))
-			tokenStr, err = token.SignedString([]byte("95osj3fUD7fo0mlYdDbncXz4VD2igvf0"))
-			if err != nil {
+		// 每十秒钟刷新一次，代办登录的不续约
+		if claims.ImpersonatedBy == 0 && claims.ExpiresAt.Sub(now) < time.Second*50 {
+			if err := l.setJWTToken(ctx, claims); err != nil {
 				// 记录日志
 				log.Println("jwt 续约失败", err)
 			}
-			ctx.Header("x-jwt-token", tokenStr)
 		}
 		ctx.Set("claims", claims)
 		//ctx.Set("userId", claims.Uid)
 	}
 }
+
+// apiKeyAllowedRoutes API key 只能调这些只读的资料接口，别的都要 JWT 登录了调。
+// 不然泄露了一个 API key 就能改资料、换手机号、生成备用码、签新的 API key，
+// 或者用管理员的 API key 调管理后台。新加的接口默认不让 API key 调
+var apiKeyAllowedRoutes = map[string]bool{
+	http.MethodGet + " /users/profile":   true,
+	http.MethodPost + " /users/profile":  true,
+	http.MethodPost + " /users/profiles": true,
+	http.MethodGet + " /users/me":        true,
+	http.MethodGet + " /users/whoami":    true,
+}
+
+func (l *LoginJWTMiddlewareBuilder) checkAPIKey(ctx *gin.Context, claims *web.UserClaims) {
+	if !apiKeyAllowedRoutes[ctx.Request.Method+" "+ctx.Request.URL.Path] {
+		ctx.AbortWithStatusJSON(http.StatusForbidden, web.Result{
+			Code: 4,
+			Msg:  web.ErrForbiddenForAPIKey.Error(),
+		})
+		return
+	}
+	if l.checker == nil {
+		return
+	}
+	revoked, err := l.checker.IsRevoked(ctx, claims.Uid)
+	if err != nil {
+		log.Println("检查登录态失败", err)
+		ctx.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+	if revoked {
+		ctx.AbortWithStatus(http.StatusUnauthorized)
+	}
+}
+
+// impersonationDeniedPrefixes 代办登录连看都不能看的接口：
+// 导出个人数据、API key、登录记录（哪台设备、哪个 IP），客服排查问题用不到这些
+var impersonationDeniedPrefixes = []string{"/users/me/export", "/users/api_keys", "/users/me/login_history"}
+
+// impersonationDenied 代办登录能调的话返回 nil
+func impersonationDenied(req *http.Request) error {
+	if !safeMethod(req.Method) {
+		return web.ErrForbiddenWhileImpersonating
+	}
+	for _, prefix := range impersonationDeniedPrefixes {
+		if strings.HasPrefix(req.URL.Path, prefix) {
+			return web.ErrDeniedWhileImpersonating
+		}
+	}
+	return nil
+}
+
+// safeMethod 不会修改数据的请求
+func safeMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+// parseClaims 解析 Authorization 里面的 JWT
+func (l *LoginJWTMiddlewareBuilder) parseClaims(ctx *gin.Context) (*web.UserClaims, bool) {
+	tokenHeader := ctx.GetHeader("Authorization")
+	if tokenHeader == "" {
+		// 没登录
+		return nil, false
+	}
+	//segs := strings.SplitN(tokenHeader, " ", 2)
+	segs := strings.Split(tokenHeader, " ")
+	if len(segs) != 2 {
+		// 没登录，有人瞎搞
+		return nil, false
+	}
+	tokenStr := segs[1]
+	claims := &web.UserClaims{}
+	// ParseWithClaims 里面，一定要传入指针
+	// 老的 token 没有 iat 和 nbf，没有就不校验
+	token, err := jwt.ParseWithClaims(tokenStr, claims, func(token *jwt.Token) (interface{}, error) {
+		return []byte("95osj3fUD7fo0mlYdDbncXz4VD2igvf0"), nil
+	}, jwt.WithIssuedAt(), jwt.WithLeeway(l.clockSkew))
+	if err != nil {
+		// 没登录
+		return nil, false
+	}
+	//claims.ExpiresAt.Time.Before(time.Now()) {
+	//	// 过期了
+	//}
+	// err 为 nil，token 不为 nil
+	if token == nil || !token.Valid || claims.Uid == 0 {
+		// 没登录
+		return nil, false
+	}
+	if claims.UserAgent != ctx.Request.UserAgent() {
+		// 严重的安全问题
+		// 你是要监控
+		return nil, false
+	}
+	if claims.ClaimsVersion < l.minClaimsVersion {
+		return nil, false
+	}
+	// 老版本的 token 补上默认值，续约的时候发的就是新版本的了
+	web.MigrateClaims(claims)
+	return claims, true
+}
+
+// refreshByRememberMe 用记住我的 cookie 换一个新的 JWT，cookie 里面的 token 也会换掉
+func (l *LoginJWTMiddlewareBuilder) refreshByRememberMe(ctx *gin.Context) (*web.UserClaims, bool) {
+	series, token, ok := web.RememberMeFromCookie(ctx)
+	if !ok {
+		return nil, false
+	}
+	t, newToken, err := l.rememberMe.Refresh(ctx, series, token)
+	if err == service.ErrRememberMeTheft {
+		web.ClearRememberMeCookie(ctx)
+		return nil, false
+	}
+	if err != nil {
+		if err != service.ErrRememberMeInvalid {
+			log.Println("记住我刷新失败", err)
+		}
+		return nil, false
+	}
+	web.SetRememberMeCookie(ctx, t, newToken)
+	claims := &web.UserClaims{
+		Uid:           t.Uid,
+		UserAgent:     ctx.Request.UserAgent(),
+		ClaimsVersion: web.CurrentClaimsVersion,
+		Role:          web.RoleUser,
+		TokenType:     web.TokenTypeAccess,
+	}
+	if l.sessions != nil {
+		claims.Ssid, err = l.sessions.Create(ctx, t.Uid, ctx.Request.UserAgent())
+		if err != nil {
+			log.Println("记住我创建会话失败", err)
+			return nil, false
+		}
+	}
+	if err = l.setJWTToken(ctx, claims); err != nil {
+		log.Println("记住我生成 jwt 失败", err)
+		return nil, false
+	}
+	return claims, true
+}
+
+func (l *LoginJWTMiddlewareBuilder) setJWTToken(ctx *gin.Context, claims *web.UserClaims) error {
+	now := time.Now()
+	claims.IssuedAt = jwt.NewNumericDate(now)
+	claims.NotBefore = jwt.NewNumericDate(now)
+	claims.ExpiresAt = jwt.NewNumericDate(now.Add(time.Minute))
+	// 旧的 nonce 不删，还在路上的请求带的是旧的 token，让它自己过期
+	if l.nonces != nil {
+		nonce, err := l.nonces.Issue(ctx, claims.Uid, claims.ExpiresAt.Time)
+		if err != nil {
+			return err
+		}
+		claims.Nonce = nonce
+	}
+	tokenStr, err := jwt.NewWithClaims(jwt.SigningMethodHS512, claims).
+		SignedString([]byte("95osj3fUD7fo0mlYdDbncXz4VD2igvf0"))
+	if err != nil {
+		return err
+	}
+	ctx.Header("x-jwt-token", tokenStr)
+	return nil
+}
@@ -0,0 +1,748 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"webook/internal/domain"
+	"webook/internal/repository/cache"
+	"webook/internal/service"
+	svcmocks "webook/internal/service/mocks"
+	"webook/internal/web"
+)
+
+func TestLoginJWTMiddlewareBuilder_RememberMe(t *testing.T) {
+	testCases := []struct {
+		name string
+		mock func(ctrl *gomock.Controller) service.RememberMeService
+
+		cookie string
+
+		wantCode int
+		wantUid  int64
+		// 有没有发新的 JWT
+		wantJWT bool
+		// 期望响应里面 remember_me 这个 cookie 的值，nil 就是没有动 cookie
+		wantCookie *string
+	}{
+		{
+			name: "用记住我恢复登录态",
+			mock: func(ctrl *gomock.Controller) service.RememberMeService {
+				svc := svcmocks.NewMockRememberMeService(ctrl)
+				svc.EXPECT().Refresh(gomock.Any(), "s1", "t1").
+					Return(domain.RememberMeToken{
+						Series:    "s1",
+						Uid:       123,
+						ExpiresAt: time.Now().Add(time.Hour),
+					}, "t2", nil)
+				return svc
+			},
+			cookie:     "s1.t1",
+			wantCode:   http.StatusOK,
+			wantUid:    123,
+			wantJWT:    true,
+			wantCookie: ptr("s1.t2"),
+		},
+		{
+			name: "被盗用，清掉 cookie",
+			mock: func(ctrl *gomock.Controller) service.RememberMeService {
+				svc := svcmocks.NewMockRememberMeService(ctrl)
+				svc.EXPECT().Refresh(gomock.Any(), "s1", "t1").
+					Return(domain.RememberMeToken{}, "", service.ErrRememberMeTheft)
+				return svc
+			},
+			cookie:     "s1.t1",
+			wantCode:   http.StatusUnauthorized,
+			wantCookie: ptr(""),
+		},
+		{
+			name: "凭证无效",
+			mock: func(ctrl *gomock.Controller) service.RememberMeService {
+				svc := svcmocks.NewMockRememberMeService(ctrl)
+				svc.EXPECT().Refresh(gomock.Any(), "s1", "t1").
+					Return(domain.RememberMeToken{}, "", service.ErrRememberMeInvalid)
+				return svc
+			},
+			cookie:   "s1.t1",
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name: "系统错误",
+			mock: func(ctrl *gomock.Controller) service.RememberMeService {
+				svc := svcmocks.NewMockRememberMeService(ctrl)
+				svc.EXPECT().Refresh(gomock.Any(), "s1", "t1").
+					Return(domain.RememberMeToken{}, "", errors.New("mock 错误"))
+				return svc
+			},
+			cookie:   "s1.t1",
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name: "cookie 格式不对",
+			mock: func(ctrl *gomock.Controller) service.RememberMeService {
+				return svcmocks.NewMockRememberMeService(ctrl)
+			},
+			cookie:   "abc",
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name: "没有 cookie",
+			mock: func(ctrl *gomock.Controller) service.RememberMeService {
+				return svcmocks.NewMockRememberMeService(ctrl)
+			},
+			wantCode: http.StatusUnauthorized,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			server := gin.New()
+			server.Use(NewLoginJWTMiddlewareBuilder().RememberMe(tc.mock(ctrl)).Build())
+			var uid int64
+			server.GET("/test", func(ctx *gin.Context) {
+				uid, _ = web.ClaimsUserId(ctx)
+				ctx.Status(http.StatusOK)
+			})
+			req, err := http.NewRequest(http.MethodGet, "/test", nil)
+			require.NoError(t, err)
+			if tc.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: web.RememberMeCookie, Value: tc.cookie})
+			}
+			resp := httptest.NewRecorder()
+			server.ServeHTTP(resp, req)
+			assert.Equal(t, tc.wantCode, resp.Code)
+			assert.Equal(t, tc.wantUid, uid)
+			assert.Equal(t, tc.wantJWT, resp.Header().Get("x-jwt-token") != "")
+
+			var cookie *http.Cookie
+			for _, c := range resp.Result().Cookies() {
+				if c.Name == web.RememberMeCookie {
+					cookie = c
+				}
+			}
+			if tc.wantCookie == nil {
+				assert.Nil(t, cookie)
+				return
+			}
+			require.NotNil(t, cookie)
+			assert.Equal(t, *tc.wantCookie, cookie.Value)
+			assert.True(t, cookie.HttpOnly)
+			assert.True(t, strings.HasPrefix(cookie.Path, "/"))
+		})
+	}
+}
+
+// legacyClaims 加 ClaimsVersion、Role、TokenType 之前的 UserClaims，也就是版本 0
+type legacyClaims struct {
+	jwt.RegisteredClaims
+	Uid       int64
+	UserAgent string
+	AnonID    string
+}
+
+func TestLoginJWTMiddlewareBuilder_MinClaimsVersion(t *testing.T) {
+	testCases := []struct {
+		name       string
+		claims     jwt.Claims
+		minVersion int
+
+		wantCode   int
+		wantClaims *web.UserClaims
+	}{
+		{
+			name: "老的 token 补上默认值",
+			claims: legacyClaims{
+				RegisteredClaims: jwt.RegisteredClaims{
+					ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+				},
+				Uid:    123,
+				AnonID: "anon-123",
+			},
+			wantCode: http.StatusOK,
+			wantClaims: &web.UserClaims{
+				Uid:           123,
+				AnonID:        "anon-123",
+				ClaimsVersion: web.CurrentClaimsVersion,
+				Role:          web.RoleUser,
+				TokenType:     web.TokenTypeAccess,
+			},
+		},
+		{
+			name: "老的 token 版本不够",
+			claims: legacyClaims{
+				RegisteredClaims: jwt.RegisteredClaims{
+					ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+				},
+				Uid: 123,
+			},
+			minVersion: 1,
+			wantCode:   http.StatusUnauthorized,
+		},
+		{
+			name: "新的 token",
+			claims: web.UserClaims{
+				RegisteredClaims: jwt.RegisteredClaims{
+					ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+				},
+				Uid:           123,
+				ClaimsVersion: 1,
+				Role:          "admin",
+				TokenType:     web.TokenTypeAccess,
+			},
+			minVersion: 1,
+			wantCode:   http.StatusOK,
+			wantClaims: &web.UserClaims{
+				Uid:           123,
+				ClaimsVersion: 1,
+				Role:          "admin",
+				TokenType:     web.TokenTypeAccess,
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := gin.New()
+			server.Use(NewLoginJWTMiddlewareBuilder().MinClaimsVersion(tc.minVersion).Build())
+			var claims *web.UserClaims
+			server.GET("/test", func(ctx *gin.Context) {
+				val, _ := ctx.Get("claims")
+				claims, _ = val.(*web.UserClaims)
+				ctx.Status(http.StatusOK)
+			})
+			token, err := jwt.NewWithClaims(jwt.SigningMethodHS512, tc.claims).
+				SignedString([]byte("95osj3fUD7fo0mlYdDbncXz4VD2igvf0"))
+			require.NoError(t, err)
+			req, err := http.NewRequest(http.MethodGet, "/test", nil)
+			require.NoError(t, err)
+			req.Header.Set("Authorization", "Bearer "+token)
+			resp := httptest.NewRecorder()
+			server.ServeHTTP(resp, req)
+			assert.Equal(t, tc.wantCode, resp.Code)
+			if tc.wantClaims == nil {
+				assert.Nil(t, claims)
+				return
+			}
+			require.NotNil(t, claims)
+			claims.RegisteredClaims = jwt.RegisteredClaims{}
+			assert.Equal(t, tc.wantClaims, claims)
+		})
+	}
+}
+
+func TestLoginJWTMiddlewareBuilder_ClockSkew(t *testing.T) {
+	now := time.Now()
+	testCases := []struct {
+		name      string
+		clockSkew time.Duration
+		claims    jwt.RegisteredClaims
+
+		wantCode int
+	}{
+		{
+			name:      "签发的机器快了一点，在误差以内",
+			clockSkew: 5 * time.Second,
+			claims: jwt.RegisteredClaims{
+				IssuedAt:  jwt.NewNumericDate(now.Add(3 * time.Second)),
+				NotBefore: jwt.NewNumericDate(now.Add(3 * time.Second)),
+				ExpiresAt: jwt.NewNumericDate(now.Add(time.Minute)),
+			},
+			wantCode: http.StatusOK,
+		},
+		{
+			name: "不放宽的话，将来签发的不认",
+			claims: jwt.RegisteredClaims{
+				IssuedAt:  jwt.NewNumericDate(now.Add(3 * time.Second)),
+				ExpiresAt: jwt.NewNumericDate(now.Add(time.Minute)),
+			},
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name:      "iat 超过了误差",
+			clockSkew: 5 * time.Second,
+			claims: jwt.RegisteredClaims{
+				IssuedAt:  jwt.NewNumericDate(now.Add(10 * time.Second)),
+				ExpiresAt: jwt.NewNumericDate(now.Add(time.Minute)),
+			},
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name:      "nbf 超过了误差",
+			clockSkew: 5 * time.Second,
+			claims: jwt.RegisteredClaims{
+				IssuedAt:  jwt.NewNumericDate(now),
+				NotBefore: jwt.NewNumericDate(now.Add(10 * time.Second)),
+				ExpiresAt: jwt.NewNumericDate(now.Add(time.Minute)),
+			},
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name:      "签发的机器慢了一点，刚过期也认",
+			clockSkew: 5 * time.Second,
+			claims: jwt.RegisteredClaims{
+				IssuedAt:  jwt.NewNumericDate(now.Add(-time.Minute)),
+				ExpiresAt: jwt.NewNumericDate(now.Add(-3 * time.Second)),
+			},
+			wantCode: http.StatusOK,
+		},
+		{
+			name:      "过期太久了",
+			clockSkew: 5 * time.Second,
+			claims: jwt.RegisteredClaims{
+				ExpiresAt: jwt.NewNumericDate(now.Add(-10 * time.Second)),
+			},
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name: "老的 token 没有 iat 和 nbf",
+			claims: jwt.RegisteredClaims{
+				ExpiresAt: jwt.NewNumericDate(now.Add(time.Minute)),
+			},
+			wantCode: http.StatusOK,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := gin.New()
+			server.Use(NewLoginJWTMiddlewareBuilder().ClockSkew(tc.clockSkew).Build())
+			server.GET("/test", func(ctx *gin.Context) {
+				ctx.Status(http.StatusOK)
+			})
+			token, err := jwt.NewWithClaims(jwt.SigningMethodHS512, web.UserClaims{
+				RegisteredClaims: tc.claims,
+				Uid:              123,
+				ClaimsVersion:    web.CurrentClaimsVersion,
+			}).SignedString([]byte("95osj3fUD7fo0mlYdDbncXz4VD2igvf0"))
+			require.NoError(t, err)
+			req, err := http.NewRequest(http.MethodGet, "/test", nil)
+			require.NoError(t, err)
+			req.Header.Set("Authorization", "Bearer "+token)
+			resp := httptest.NewRecorder()
+			server.ServeHTTP(resp, req)
+			assert.Equal(t, tc.wantCode, resp.Code)
+			if tc.wantCode != http.StatusOK {
+				return
+			}
+			// 快过期的会续约，新的 token 带上 iat 和 nbf
+			if renewed := resp.Header().Get("x-jwt-token"); renewed != "" {
+				claims := &web.UserClaims{}
+				_, err = jwt.ParseWithClaims(renewed, claims, func(token *jwt.Token) (interface{}, error) {
+					return []byte("95osj3fUD7fo0mlYdDbncXz4VD2igvf0"), nil
+				})
+				require.NoError(t, err)
+				require.NotNil(t, claims.IssuedAt)
+				require.NotNil(t, claims.NotBefore)
+				assert.WithinDuration(t, time.Now(), claims.IssuedAt.Time, 2*time.Second)
+			}
+		})
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func TestLoginJWTMiddlewareBuilder_Sessions(t *testing.T) {
+	testCases := []struct {
+		name string
+		mock func(ctrl *gomock.Controller) service.SessionService
+		ssid string
+
+		wantCode int
+	}{
+		{
+			name: "会话还在",
+			mock: func(ctrl *gomock.Controller) service.SessionService {
+				svc := svcmocks.NewMockSessionService(ctrl)
+				svc.EXPECT().Check(gomock.Any(), int64(123), "ssid-1").Return(true, nil)
+				return svc
+			},
+			ssid:     "ssid-1",
+			wantCode: http.StatusOK,
+		},
+		{
+			name: "这台设备已经退出了",
+			mock: func(ctrl *gomock.Controller) service.SessionService {
+				svc := svcmocks.NewMockSessionService(ctrl)
+				svc.EXPECT().Check(gomock.Any(), int64(123), "ssid-1").Return(false, nil)
+				return svc
+			},
+			ssid:     "ssid-1",
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name: "检查失败",
+			mock: func(ctrl *gomock.Controller) service.SessionService {
+				svc := svcmocks.NewMockSessionService(ctrl)
+				svc.EXPECT().Check(gomock.Any(), int64(123), "ssid-1").Return(false, errors.New("mock 错误"))
+				return svc
+			},
+			ssid:     "ssid-1",
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name: "老的 token 没有 ssid",
+			mock: func(ctrl *gomock.Controller) service.SessionService {
+				return svcmocks.NewMockSessionService(ctrl)
+			},
+			wantCode: http.StatusOK,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			server := gin.New()
+			server.Use(NewLoginJWTMiddlewareBuilder().Sessions(tc.mock(ctrl)).Build())
+			server.GET("/test", func(ctx *gin.Context) {
+				ctx.Status(http.StatusOK)
+			})
+			token, err := jwt.NewWithClaims(jwt.SigningMethodHS512, web.UserClaims{
+				RegisteredClaims: jwt.RegisteredClaims{
+					ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+				},
+				Uid:           123,
+				Ssid:          tc.ssid,
+				ClaimsVersion: web.CurrentClaimsVersion,
+			}).SignedString([]byte("95osj3fUD7fo0mlYdDbncXz4VD2igvf0"))
+			require.NoError(t, err)
+			req, err := http.NewRequest(http.MethodGet, "/test", nil)
+			require.NoError(t, err)
+			req.Header.Set("Authorization", "Bearer "+token)
+			resp := httptest.NewRecorder()
+			server.ServeHTTP(resp, req)
+			assert.Equal(t, tc.wantCode, resp.Code)
+		})
+	}
+}
+
+func TestLoginJWTMiddlewareBuilder_OptionalPaths(t *testing.T) {
+	testCases := []struct {
+		name string
+		path string
+		// 空的就是不带 token
+		expiresAt time.Time
+		alive     bool
+
+		wantCode   int
+		wantClaims bool
+	}{
+		{
+			name:       "登录了",
+			path:       "/users/whoami",
+			expiresAt:  time.Now().Add(time.Minute),
+			alive:      true,
+			wantCode:   http.StatusOK,
+			wantClaims: true,
+		},
+		{
+			name:     "没登录也放行",
+			path:     "/users/whoami",
+			wantCode: http.StatusOK,
+		},
+		{
+			name:      "过期了当没登录",
+			path:      "/users/whoami",
+			expiresAt: time.Now().Add(-time.Minute),
+			wantCode:  http.StatusOK,
+		},
+		{
+			name:      "退出登录了当没登录",
+			path:      "/users/whoami",
+			expiresAt: time.Now().Add(time.Minute),
+			wantCode:  http.StatusOK,
+		},
+		{
+			name:     "别的路径还是要登录",
+			path:     "/users/me",
+			wantCode: http.StatusUnauthorized,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			sessions := svcmocks.NewMockSessionService(ctrl)
+			sessions.EXPECT().Check(gomock.Any(), int64(123), "ssid-1").Return(tc.alive, nil).AnyTimes()
+			server := gin.New()
+			server.Use(NewLoginJWTMiddlewareBuilder().
+				OptionalPaths("/users/whoami").
+				Sessions(sessions).Build())
+			var gotClaims bool
+			server.GET(tc.path, func(ctx *gin.Context) {
+				_, gotClaims = web.ClaimsUserId(ctx)
+				ctx.Status(http.StatusOK)
+			})
+			req, err := http.NewRequest(http.MethodGet, tc.path, nil)
+			require.NoError(t, err)
+			if !tc.expiresAt.IsZero() {
+				token, err := jwt.NewWithClaims(jwt.SigningMethodHS512, web.UserClaims{
+					RegisteredClaims: jwt.RegisteredClaims{
+						ExpiresAt: jwt.NewNumericDate(tc.expiresAt),
+					},
+					Uid:           123,
+					Ssid:          "ssid-1",
+					ClaimsVersion: web.CurrentClaimsVersion,
+				}).SignedString([]byte("95osj3fUD7fo0mlYdDbncXz4VD2igvf0"))
+				require.NoError(t, err)
+				req.Header.Set("Authorization", "Bearer "+token)
+			}
+			resp := httptest.NewRecorder()
+			server.ServeHTTP(resp, req)
+			assert.Equal(t, tc.wantCode, resp.Code)
+			assert.Equal(t, tc.wantClaims, gotClaims)
+		})
+	}
+}
+
+// 用记住我恢复登录态，相当于这台设备重新登录了一次，要有一个新的会话
+func TestLoginJWTMiddlewareBuilder_RememberMeSession(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	rememberMe := svcmocks.NewMockRememberMeService(ctrl)
+	rememberMe.EXPECT().Refresh(gomock.Any(), "s1", "t1").
+		Return(domain.RememberMeToken{Series: "s1", Uid: 123, ExpiresAt: time.Now().Add(time.Hour)}, "t2", nil)
+	sessions := svcmocks.NewMockSessionService(ctrl)
+	sessions.EXPECT().Create(gomock.Any(), int64(123), "test-agent").Return("ssid-2", nil)
+
+	server := gin.New()
+	server.Use(NewLoginJWTMiddlewareBuilder().RememberMe(rememberMe).Sessions(sessions).Build())
+	server.GET("/test", func(ctx *gin.Context) {
+		ctx.Status(http.StatusOK)
+	})
+	req, err := http.NewRequest(http.MethodGet, "/test", nil)
+	require.NoError(t, err)
+	req.Header.Set("User-Agent", "test-agent")
+	req.AddCookie(&http.Cookie{Name: web.RememberMeCookie, Value: "s1.t1"})
+	resp := httptest.NewRecorder()
+	server.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusOK, resp.Code)
+
+	claims := &web.UserClaims{}
+	_, err = jwt.ParseWithClaims(resp.Header().Get("x-jwt-token"), claims, func(token *jwt.Token) (interface{}, error) {
+		return []byte("95osj3fUD7fo0mlYdDbncXz4VD2igvf0"), nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "ssid-2", claims.Ssid)
+}
+
+// 同一个 token，退出登录删掉 nonce 之后就不能再用了
+func TestLoginJWTMiddlewareBuilder_Nonces(t *testing.T) {
+	mr := miniredis.RunT(t)
+	nonces := service.NewJWTNonceService(cache.NewJWTNonceCache(redis.NewClient(&redis.Options{Addr: mr.Addr()})))
+	server := gin.New()
+	server.Use(NewLoginJWTMiddlewareBuilder().
+		IgnorePaths("/users/logout").
+		Nonces(nonces).Build())
+	web.NewUserHandler(nil, nil, nil, nil, nil, web.WithJWTNonces(nonces)).RegisterRoutes(server)
+	server.GET("/test", func(ctx *gin.Context) {
+		ctx.Status(http.StatusOK)
+	})
+
+	expiresAt := time.Now().Add(time.Minute)
+	nonce, err := nonces.Issue(context.Background(), 123, expiresAt)
+	require.NoError(t, err)
+	assert.Len(t, nonce, 32)
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS512, web.UserClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+		Uid:           123,
+		Nonce:         nonce,
+		ClaimsVersion: web.CurrentClaimsVersion,
+	}).SignedString([]byte("95osj3fUD7fo0mlYdDbncXz4VD2igvf0"))
+	require.NoError(t, err)
+	do := func(method, path string) int {
+		req, err := http.NewRequest(method, path, nil)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp := httptest.NewRecorder()
+		server.ServeHTTP(resp, req)
+		return resp.Code
+	}
+
+	assert.Equal(t, http.StatusOK, do(http.MethodGet, "/test"))
+	assert.Equal(t, http.StatusOK, do(http.MethodGet, "/test"))
+	assert.Equal(t, http.StatusOK, do(http.MethodPost, "/users/logout"))
+	assert.False(t, mr.Exists("user:jwt_nonce:123:"+nonce))
+	assert.Equal(t, http.StatusUnauthorized, do(http.MethodGet, "/test"))
+}
+
+// 快过期的时候续约，新的 token 换一个新的 nonce
+func TestLoginJWTMiddlewareBuilder_NonceRenew(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	nonces := svcmocks.NewMockJWTNonceService(ctrl)
+	nonces.EXPECT().Check(gomock.Any(), int64(123), "old").Return(true, nil)
+	nonces.EXPECT().Issue(gomock.Any(), int64(123), gomock.Any()).Return("new", nil)
+	server := gin.New()
+	server.Use(NewLoginJWTMiddlewareBuilder().Nonces(nonces).Build())
+	server.GET("/test", func(ctx *gin.Context) {
+		ctx.Status(http.StatusOK)
+	})
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS512, web.UserClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(10 * time.Second)),
+		},
+		Uid:           123,
+		Nonce:         "old",
+		ClaimsVersion: web.CurrentClaimsVersion,
+	}).SignedString([]byte("95osj3fUD7fo0mlYdDbncXz4VD2igvf0"))
+	require.NoError(t, err)
+	req, err := http.NewRequest(http.MethodGet, "/test", nil)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp := httptest.NewRecorder()
+	server.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusOK, resp.Code)
+
+	claims := &web.UserClaims{}
+	_, err = jwt.ParseWithClaims(resp.Header().Get("x-jwt-token"), claims, func(token *jwt.Token) (interface{}, error) {
+		return []byte("95osj3fUD7fo0mlYdDbncXz4VD2igvf0"), nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "new", claims.Nonce)
+}
+
+// 代办登录的 token 只能看不能改，快过期了也不续约
+func TestLoginJWTMiddlewareBuilder_Impersonation(t *testing.T) {
+	testCases := []struct {
+		name   string
+		method string
+		// 不填就是 /users/edit
+		path string
+
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "可以看",
+			method:   http.MethodGet,
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "不能改",
+			method:   http.MethodPost,
+			wantCode: http.StatusForbidden,
+			wantBody: `{"code":4,"msg":"代办登录的时候不能修改数据","data":null}`,
+		},
+		{
+			name:     "不能导出个人数据",
+			method:   http.MethodGet,
+			path:     "/users/me/export_data",
+			wantCode: http.StatusForbidden,
+			wantBody: `{"code":4,"msg":"代办登录的时候不能调这个接口","data":null}`,
+		},
+		{
+			name:     "不能看 API key",
+			method:   http.MethodGet,
+			path:     "/users/api_keys",
+			wantCode: http.StatusForbidden,
+			wantBody: `{"code":4,"msg":"代办登录的时候不能调这个接口","data":null}`,
+		},
+		{
+			name:     "不能看登录记录",
+			method:   http.MethodGet,
+			path:     "/users/me/login_history",
+			wantCode: http.StatusForbidden,
+			wantBody: `{"code":4,"msg":"代办登录的时候不能调这个接口","data":null}`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := tc.path
+			if path == "" {
+				path = "/users/edit"
+			}
+			server := gin.New()
+			server.Use(NewLoginJWTMiddlewareBuilder().Build())
+			server.Handle(tc.method, path, func(ctx *gin.Context) {
+				ctx.Status(http.StatusOK)
+			})
+			token, err := jwt.NewWithClaims(jwt.SigningMethodHS512, web.UserClaims{
+				RegisteredClaims: jwt.RegisteredClaims{
+					ExpiresAt: jwt.NewNumericDate(time.Now().Add(10 * time.Second)),
+				},
+				Uid:            3,
+				SubjectUserID:  3,
+				ImpersonatedBy: 1,
+				ClaimsVersion:  web.CurrentClaimsVersion,
+			}).SignedString([]byte("95osj3fUD7fo0mlYdDbncXz4VD2igvf0"))
+			require.NoError(t, err)
+			req, err := http.NewRequest(tc.method, path, nil)
+			require.NoError(t, err)
+			req.Header.Set("Authorization", "Bearer "+token)
+			resp := httptest.NewRecorder()
+			server.ServeHTTP(resp, req)
+			assert.Equal(t, tc.wantCode, resp.Code)
+			assert.Empty(t, resp.Header().Get("x-jwt-token"))
+			if tc.wantBody != "" {
+				assert.JSONEq(t, tc.wantBody, resp.Body.String())
+			}
+		})
+	}
+}
+
+// fakeRevokeChecker 这些 uid 的登录态已经失效了
+type fakeRevokeChecker map[int64]bool
+
+func (c fakeRevokeChecker) IsRevoked(ctx context.Context, uid int64) (bool, error) {
+	return c[uid], nil
+}
+
+// API key 不走 nonce 和会话的检查，但是账号失效了要跟着失效，也只能调只读的资料接口
+func TestLoginJWTMiddlewareBuilder_APIKey(t *testing.T) {
+	testCases := []struct {
+		name   string
+		uid    int64
+		method string
+		path   string
+
+		wantCode int
+	}{
+		{name: "正常调用", uid: 3, method: http.MethodGet, path: "/users/profile", wantCode: http.StatusOK},
+		{name: "账号被封禁了", uid: 4, method: http.MethodGet, path: "/users/profile", wantCode: http.StatusUnauthorized},
+		{name: "不能签新的 API key", uid: 3, method: http.MethodPost, path: "/users/api_keys", wantCode: http.StatusForbidden},
+		{name: "不能看 API key", uid: 3, method: http.MethodGet, path: "/users/api_keys", wantCode: http.StatusForbidden},
+		{name: "不能调管理后台", uid: 3, method: http.MethodGet, path: "/admin/users/stats", wantCode: http.StatusForbidden},
+		{name: "能批量查资料", uid: 3, method: http.MethodPost, path: "/users/profiles", wantCode: http.StatusOK},
+		{name: "不能改资料", uid: 3, method: http.MethodPost, path: "/users/edit", wantCode: http.StatusForbidden},
+		{name: "不能生成备用码", uid: 3, method: http.MethodPost, path: "/users/mfa/backup_codes/generate", wantCode: http.StatusForbidden},
+		{name: "不能看备用码", uid: 3, method: http.MethodGet, path: "/users/mfa/backup_codes/count", wantCode: http.StatusForbidden},
+		{name: "不能发换手机号的验证码", uid: 3, method: http.MethodPost, path: "/users/phone/change/code/send", wantCode: http.StatusForbidden},
+		{name: "不能换手机号", uid: 3, method: http.MethodPost, path: "/users/phone/change", wantCode: http.StatusForbidden},
+		{name: "不能看登录记录", uid: 3, method: http.MethodGet, path: "/users/me/login_history", wantCode: http.StatusForbidden},
+		{name: "不能注销账号", uid: 3, method: http.MethodPost, path: "/users/delete", wantCode: http.StatusForbidden},
+		{name: "不能停用账号", uid: 3, method: http.MethodPost, path: "/users/deactivate", wantCode: http.StatusForbidden},
+		{name: "不能导出数据", uid: 3, method: http.MethodPost, path: "/users/me/export", wantCode: http.StatusForbidden},
+		{name: "不能退出登录", uid: 3, method: http.MethodPost, path: "/users/logout", wantCode: http.StatusForbidden},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := gin.New()
+			server.Use(func(ctx *gin.Context) {
+				// 模拟 APIKeyMiddlewareBuilder 放进去的
+				ctx.Set("claims", &web.UserClaims{Uid: tc.uid, TokenType: web.TokenTypeAPIKey})
+			}, NewLoginJWTMiddlewareBuilder().RevokeChecker(fakeRevokeChecker{4: true}).Build())
+			server.Handle(tc.method, tc.path, func(ctx *gin.Context) {
+				ctx.Status(http.StatusOK)
+			})
+			req, err := http.NewRequest(tc.method, tc.path, nil)
+			require.NoError(t, err)
+			resp := httptest.NewRecorder()
+			server.ServeHTTP(resp, req)
+			assert.Equal(t, tc.wantCode, resp.Code)
+		})
+	}
+}
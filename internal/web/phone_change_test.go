@@ -0,0 +1,236 @@
+package web
+
+import (
+	"encoding/json"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"gorm.io/gorm"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"webook/internal/repository"
+	cachemocks "webook/internal/repository/cache/mocks"
+	"webook/internal/repository/dao"
+	"webook/internal/service"
+	svcmocks "webook/internal/service/mocks"
+)
+
+const (
+	findUserById    = "SELECT \\* FROM `users` WHERE id = \\?"
+	findUserByPhone = "SELECT \\* FROM `users` WHERE phone = \\?"
+)
+
+// expectPhoneChangeCheck 查当前用户，再看新手机号有没有人用
+func expectPhoneChangeCheck(mock sqlmock.Sqlmock, oldPhone string, taken bool) {
+	mock.ExpectQuery(findUserById).WillReturnRows(
+		sqlmock.NewRows([]string{"id", "phone"}).AddRow(3, oldPhone))
+	rows := sqlmock.NewRows([]string{"id", "phone"})
+	if taken {
+		rows.AddRow(4, "13812345678")
+	}
+	mock.ExpectQuery(findUserByPhone).WillReturnRows(rows)
+}
+
+func TestUserHandler_InitiatePhoneChange(t *testing.T) {
+	testCases := []struct {
+		name string
+		body string
+		mock func(ctrl *gomock.Controller, mock sqlmock.Sqlmock) service.CodeService
+
+		wantCode      int
+		wantMsg       string
+		wantVerifyOld bool
+	}{
+		{
+			name: "新旧手机号都要发",
+			body: `{"phone":"13812345678"}`,
+			mock: func(ctrl *gomock.Controller, mock sqlmock.Sqlmock) service.CodeService {
+				expectPhoneChangeCheck(mock, "15212345678", false)
+				codeSvc := svcmocks.NewMockCodeService(ctrl)
+				codeSvc.EXPECT().Send(gomock.Any(), bizChangePhone, "13812345678", gomock.Any()).Return(nil)
+				codeSvc.EXPECT().Send(gomock.Any(), bizChangePhone, "15212345678", gomock.Any()).Return(nil)
+				codeSvc.EXPECT().Cooldown(gomock.Any(), bizChangePhone, "13812345678").Return(time.Minute, nil)
+				return codeSvc
+			},
+			wantMsg:       "发送成功",
+			wantVerifyOld: true,
+		},
+		{
+			name: "原来没有手机号",
+			body: `{"phone":"13812345678"}`,
+			mock: func(ctrl *gomock.Controller, mock sqlmock.Sqlmock) service.CodeService {
+				expectPhoneChangeCheck(mock, "", false)
+				codeSvc := svcmocks.NewMockCodeService(ctrl)
+				codeSvc.EXPECT().Send(gomock.Any(), bizChangePhone, "13812345678", gomock.Any()).Return(nil)
+				codeSvc.EXPECT().Cooldown(gomock.Any(), bizChangePhone, "13812345678").Return(time.Minute, nil)
+				return codeSvc
+			},
+			wantMsg: "发送成功",
+		},
+		{
+			name: "跟原来的一样",
+			body: `{"phone":"15212345678"}`,
+			mock: func(ctrl *gomock.Controller, mock sqlmock.Sqlmock) service.CodeService {
+				mock.ExpectQuery(findUserById).WillReturnRows(
+					sqlmock.NewRows([]string{"id", "phone"}).AddRow(3, "15212345678"))
+				return svcmocks.NewMockCodeService(ctrl)
+			},
+			wantCode: 4,
+			wantMsg:  "新手机号跟原来的一样",
+		},
+		{
+			name: "新手机号被别人用了",
+			body: `{"phone":"13812345678"}`,
+			mock: func(ctrl *gomock.Controller, mock sqlmock.Sqlmock) service.CodeService {
+				expectPhoneChangeCheck(mock, "15212345678", true)
+				return svcmocks.NewMockCodeService(ctrl)
+			},
+			wantCode: 4,
+			wantMsg:  "手机号已被其他账号使用",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			db, mock := mockUserDB(t)
+			codeSvc := tc.mock(ctrl, mock)
+			res := doPhoneChange(t, db, cachemocks.NewMockUserCache(ctrl), codeSvc,
+				"/users/phone/change/code/send", tc.body)
+			assert.Equal(t, tc.wantCode, res.Code)
+			assert.Equal(t, tc.wantMsg, res.Msg)
+			if tc.wantMsg == "发送成功" {
+				assert.Equal(t, map[string]any{"verify_old": tc.wantVerifyOld}, res.Data)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestUserHandler_ConfirmPhoneChange(t *testing.T) {
+	update := "UPDATE `users` SET .* WHERE id = \\? AND status = \\?"
+	testCases := []struct {
+		name string
+		body string
+		mock func(ctrl *gomock.Controller, mock sqlmock.Sqlmock) (*cachemocks.MockUserCache, service.CodeService)
+
+		wantCode int
+		wantMsg  string
+	}{
+		{
+			name: "两个验证码都对",
+			body: `{"phone":"13812345678","code":"222222","oldCode":"111111"}`,
+			mock: func(ctrl *gomock.Controller, mock sqlmock.Sqlmock) (*cachemocks.MockUserCache, service.CodeService) {
+				expectPhoneChangeCheck(mock, "15212345678", false)
+				codeSvc := svcmocks.NewMockCodeService(ctrl)
+				gomock.InOrder(
+					codeSvc.EXPECT().Verify(gomock.Any(), bizChangePhone, "15212345678", "111111").Return(true, nil),
+					codeSvc.EXPECT().Verify(gomock.Any(), bizChangePhone, "13812345678", "222222").Return(true, nil),
+				)
+				expectPhoneChangeCheck(mock, "15212345678", false)
+				mock.ExpectExec(update).
+					WithArgs("13812345678", sqlmock.AnyArg(), int64(3), dao.UserStatusActive).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				c := cachemocks.NewMockUserCache(ctrl)
+				c.EXPECT().DelProfile(gomock.Any(), int64(3)).Return(nil)
+				c.EXPECT().Del(gomock.Any(), int64(3)).Return(nil)
+				return c, codeSvc
+			},
+			wantMsg: "手机号已更换",
+		},
+		{
+			// 新手机号的验证码不会被用掉
+			name: "原手机号的验证码不对",
+			body: `{"phone":"13812345678","code":"222222","oldCode":"000000"}`,
+			mock: func(ctrl *gomock.Controller, mock sqlmock.Sqlmock) (*cachemocks.MockUserCache, service.CodeService) {
+				expectPhoneChangeCheck(mock, "15212345678", false)
+				codeSvc := svcmocks.NewMockCodeService(ctrl)
+				codeSvc.EXPECT().Verify(gomock.Any(), bizChangePhone, "15212345678", "000000").Return(false, nil)
+				return cachemocks.NewMockUserCache(ctrl), codeSvc
+			},
+			wantCode: 4,
+			wantMsg:  "原手机号的验证码有误",
+		},
+		{
+			name: "新手机号的验证码不对",
+			body: `{"phone":"13812345678","code":"000000"}`,
+			mock: func(ctrl *gomock.Controller, mock sqlmock.Sqlmock) (*cachemocks.MockUserCache, service.CodeService) {
+				expectPhoneChangeCheck(mock, "", false)
+				codeSvc := svcmocks.NewMockCodeService(ctrl)
+				codeSvc.EXPECT().Verify(gomock.Any(), bizChangePhone, "13812345678", "000000").Return(false, nil)
+				return cachemocks.NewMockUserCache(ctrl), codeSvc
+			},
+			wantCode: 4,
+			wantMsg:  "新手机号的验证码有误",
+		},
+		{
+			// 检查的时候还没人用，改的时候撞上了唯一索引
+			name: "改的时候被别人抢先了",
+			body: `{"phone":"13812345678","code":"222222"}`,
+			mock: func(ctrl *gomock.Controller, mock sqlmock.Sqlmock) (*cachemocks.MockUserCache, service.CodeService) {
+				expectPhoneChangeCheck(mock, "", false)
+				codeSvc := svcmocks.NewMockCodeService(ctrl)
+				codeSvc.EXPECT().Verify(gomock.Any(), bizChangePhone, "13812345678", "222222").Return(true, nil)
+				expectPhoneChangeCheck(mock, "", false)
+				mock.ExpectExec(update).WillReturnError(&mysql.MySQLError{
+					Number:  1062,
+					Message: "Duplicate entry '13812345678' for key 'users.phone'",
+				})
+				return cachemocks.NewMockUserCache(ctrl), codeSvc
+			},
+			wantCode: 4,
+			wantMsg:  "手机号已被其他账号使用",
+		},
+		{
+			name: "跟原来的一样",
+			body: `{"phone":"15212345678","code":"222222","oldCode":"111111"}`,
+			mock: func(ctrl *gomock.Controller, mock sqlmock.Sqlmock) (*cachemocks.MockUserCache, service.CodeService) {
+				mock.ExpectQuery(findUserById).WillReturnRows(
+					sqlmock.NewRows([]string{"id", "phone"}).AddRow(3, "15212345678"))
+				return cachemocks.NewMockUserCache(ctrl), svcmocks.NewMockCodeService(ctrl)
+			},
+			wantCode: 4,
+			wantMsg:  "新手机号跟原来的一样",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			db, mock := mockUserDB(t)
+			c, codeSvc := tc.mock(ctrl, mock)
+			res := doPhoneChange(t, db, c, codeSvc, "/users/phone/change", tc.body)
+			assert.Equal(t, tc.wantCode, res.Code)
+			assert.Equal(t, tc.wantMsg, res.Msg)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func doPhoneChange(t *testing.T, db *gorm.DB, c *cachemocks.MockUserCache,
+	codeSvc service.CodeService, path, body string) Result {
+	svc := service.NewUserService(repository.NewUserRepository(dao.NewUserDAO(db), c),
+		nil, nil, service.SensitiveModeReject, nil, nil)
+	server := gin.New()
+	server.Use(func(ctx *gin.Context) {
+		ctx.Set("claims", &UserClaims{Uid: 3})
+	})
+	NewUserHandler(svc, codeSvc, nil, nil, nil).RegisterRoutes(server)
+
+	req, err := http.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+	server.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusOK, resp.Code)
+
+	var res Result
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &res))
+	return res
+}
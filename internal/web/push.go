@@ -0,0 +1,52 @@
+package web
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"strings"
+)
+
+// http2PushTotal 没有配置 MeterProvider 的时候 otel 默认是 noop 的
+var http2PushTotal, _ = otel.Meter("webook/internal/web").Int64Counter("http2_push_total",
+	metric.WithDescription("HTTP/2 server push 的次数，status 是 pushed 或者 fallback"))
+
+// ShouldPush 有些客户端对 server push 的支持有问题，推了反而浪费带宽：
+// Safari 会把推过来的资源重复下载一遍，爬虫和命令行工具根本用不上
+func ShouldPush(userAgent string) bool {
+	if userAgent == "" {
+		return false
+	}
+	ua := strings.ToLower(userAgent)
+	for _, bad := range []string{"bot", "spider", "curl", "wget"} {
+		if strings.Contains(ua, bad) {
+			return false
+		}
+	}
+	// Chrome 的 UA 里面也带了 Safari，要排除掉
+	if strings.Contains(ua, "safari") && !strings.Contains(ua, "chrome") &&
+		!strings.Contains(ua, "chromium") {
+		return false
+	}
+	return true
+}
+
+// pushResource 在返回响应之前把 target 推给浏览器，比如说资料里面的头像。
+// 只有 TLS 上面的 HTTP/2 才有 Pusher，别的情况或者推送失败都悄悄跳过，浏览器会自己再去拿
+func pushResource(ctx *gin.Context, target string) {
+	status := "fallback"
+	defer func() {
+		http2PushTotal.Add(context.Background(), 1,
+			metric.WithAttributes(attribute.String("status", status)))
+	}()
+	pusher := ctx.Writer.Pusher()
+	if pusher == nil || !ShouldPush(ctx.GetHeader("User-Agent")) {
+		return
+	}
+	if err := pusher.Push(target, nil); err != nil {
+		return
+	}
+	status = "pushed"
+}
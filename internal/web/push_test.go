@@ -0,0 +1,83 @@
+package web
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// pushRecorder 模拟 HTTP/2 的连接，记下推送了哪些资源
+type pushRecorder struct {
+	*httptest.ResponseRecorder
+	targets []string
+	err     error
+}
+
+func (p *pushRecorder) Push(target string, opts *http.PushOptions) error {
+	p.targets = append(p.targets, target)
+	return p.err
+}
+
+const chromeUA = "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 " +
+	"(KHTML, like Gecko) Chrome/118.0.0.0 Safari/537.36"
+
+func TestPushResource(t *testing.T) {
+	const avatar = "/static/avatar/123.png"
+	testCases := []struct {
+		name    string
+		ua      string
+		pushErr error
+
+		wantTargets []string
+	}{
+		{
+			name:        "推送成功",
+			ua:          chromeUA,
+			wantTargets: []string{avatar},
+		},
+		{
+			name: "不支持推送的浏览器",
+			ua: "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 " +
+				"(KHTML, like Gecko) Version/17.0 Safari/605.1.15",
+		},
+		{
+			name:        "推送失败也不影响",
+			ua:          chromeUA,
+			pushErr:     errors.New("连接断了"),
+			wantTargets: []string{avatar},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := &pushRecorder{ResponseRecorder: httptest.NewRecorder(), err: tc.pushErr}
+			ctx, _ := gin.CreateTestContext(w)
+			ctx.Request = httptest.NewRequest(http.MethodGet, "/users/profile", nil)
+			ctx.Request.Header.Set("User-Agent", tc.ua)
+			pushResource(ctx, avatar)
+			assert.Equal(t, tc.wantTargets, w.targets)
+		})
+	}
+}
+
+// HTTP/1.1 没有 Pusher，直接跳过
+func TestPushResource_HTTP1(t *testing.T) {
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	ctx.Request = httptest.NewRequest(http.MethodGet, "/users/profile", nil)
+	ctx.Request.Header.Set("User-Agent", chromeUA)
+	assert.NotPanics(t, func() {
+		pushResource(ctx, "/static/avatar/123.png")
+	})
+}
+
+func TestShouldPush(t *testing.T) {
+	assert.True(t, ShouldPush(chromeUA))
+	assert.True(t, ShouldPush("Mozilla/5.0 (X11; Linux x86_64; rv:109.0) Gecko/20100101 Firefox/119.0"))
+	assert.False(t, ShouldPush(""))
+	assert.False(t, ShouldPush("curl/8.4.0"))
+	assert.False(t, ShouldPush("Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)"))
+	assert.False(t, ShouldPush("Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) AppleWebKit/605.1.15 "+
+		"(KHTML, like Gecko) Version/17.0 Mobile/15E148 Safari/604.1"))
+}
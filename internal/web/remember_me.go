@@ -0,0 +1,37 @@
+package web
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strings"
+	"time"
+	"webook/internal/domain"
+)
+
+// RememberMeCookie 记住我的 cookie，值是 series.token
+const RememberMeCookie = "remember_me"
+
+// SetRememberMeCookie 只能通过 HTTP 带过来，前端的 JS 拿不到
+func SetRememberMeCookie(ctx *gin.Context, t domain.RememberMeToken, token string) {
+	maxAge := int(time.Until(t.ExpiresAt) / time.Second)
+	ctx.SetSameSite(http.SameSiteLaxMode)
+	ctx.SetCookie(RememberMeCookie, t.Series+"."+token, maxAge, "/", "", true, true)
+}
+
+// RememberMeFromCookie 返回 cookie 里面的 series 和 token
+func RememberMeFromCookie(ctx *gin.Context) (string, string, bool) {
+	val, err := ctx.Cookie(RememberMeCookie)
+	if err != nil {
+		return "", "", false
+	}
+	series, token, ok := strings.Cut(val, ".")
+	if !ok || series == "" || token == "" {
+		return "", "", false
+	}
+	return series, token, true
+}
+
+func ClearRememberMeCookie(ctx *gin.Context) {
+	ctx.SetSameSite(http.SameSiteLaxMode)
+	ctx.SetCookie(RememberMeCookie, "", -1, "/", "", true, true)
+}
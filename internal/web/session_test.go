@@ -0,0 +1,219 @@
+package web
+
+import (
+	"encoding/json"
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-contrib/sessions/memstore"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"webook/internal/service"
+	svcmocks "webook/internal/service/mocks"
+)
+
+func TestUserHandler_LogoutJWT(t *testing.T) {
+	testCases := []struct {
+		name string
+		mock func(ctrl *gomock.Controller) (service.SessionService, service.RememberMeService)
+		// JWT 还有多久过期，0 就是不带 JWT
+		expiresIn time.Duration
+		logoutAll bool
+		// 代办登录的管理员
+		impersonatedBy int64
+
+		wantCode int
+	}{
+		{
+			name: "只退出这台设备",
+			mock: func(ctrl *gomock.Controller) (service.SessionService, service.RememberMeService) {
+				sessions := svcmocks.NewMockSessionService(ctrl)
+				sessions.EXPECT().Logout(gomock.Any(), int64(3), "ssid-1").Return(nil)
+				rememberMe := svcmocks.NewMockRememberMeService(ctrl)
+				rememberMe.EXPECT().Revoke(gomock.Any(), "s1").Return(nil)
+				return sessions, rememberMe
+			},
+			expiresIn: time.Minute,
+		},
+		{
+			name: "JWT 过期了也能退出这台设备",
+			mock: func(ctrl *gomock.Controller) (service.SessionService, service.RememberMeService) {
+				sessions := svcmocks.NewMockSessionService(ctrl)
+				sessions.EXPECT().Logout(gomock.Any(), int64(3), "ssid-1").Return(nil)
+				rememberMe := svcmocks.NewMockRememberMeService(ctrl)
+				rememberMe.EXPECT().Revoke(gomock.Any(), "s1").Return(nil)
+				return sessions, rememberMe
+			},
+			expiresIn: -time.Hour,
+		},
+		{
+			name: "退出所有设备",
+			mock: func(ctrl *gomock.Controller) (service.SessionService, service.RememberMeService) {
+				sessions := svcmocks.NewMockSessionService(ctrl)
+				sessions.EXPECT().Logout(gomock.Any(), int64(3), "ssid-1").Return(nil)
+				sessions.EXPECT().LogoutAll(gomock.Any(), int64(3)).Return(nil)
+				rememberMe := svcmocks.NewMockRememberMeService(ctrl)
+				rememberMe.EXPECT().Revoke(gomock.Any(), "s1").Return(nil)
+				rememberMe.EXPECT().RevokeAll(gomock.Any(), int64(3)).Return(nil)
+				return sessions, rememberMe
+			},
+			expiresIn: time.Minute,
+			logoutAll: true,
+		},
+		{
+			name: "JWT 过期了不能退出所有设备",
+			mock: func(ctrl *gomock.Controller) (service.SessionService, service.RememberMeService) {
+				rememberMe := svcmocks.NewMockRememberMeService(ctrl)
+				rememberMe.EXPECT().Revoke(gomock.Any(), "s1").Return(nil)
+				return svcmocks.NewMockSessionService(ctrl), rememberMe
+			},
+			expiresIn: -time.Hour,
+			logoutAll: true,
+			wantCode:  4,
+		},
+		{
+			name: "代办登录不能退出所有设备",
+			mock: func(ctrl *gomock.Controller) (service.SessionService, service.RememberMeService) {
+				sessions := svcmocks.NewMockSessionService(ctrl)
+				sessions.EXPECT().Logout(gomock.Any(), int64(3), "ssid-1").Return(nil)
+				rememberMe := svcmocks.NewMockRememberMeService(ctrl)
+				rememberMe.EXPECT().Revoke(gomock.Any(), "s1").Return(nil)
+				return sessions, rememberMe
+			},
+			expiresIn:      time.Minute,
+			logoutAll:      true,
+			impersonatedBy: 1,
+			wantCode:       4,
+		},
+		{
+			name: "没有 JWT",
+			mock: func(ctrl *gomock.Controller) (service.SessionService, service.RememberMeService) {
+				rememberMe := svcmocks.NewMockRememberMeService(ctrl)
+				rememberMe.EXPECT().Revoke(gomock.Any(), "s1").Return(nil)
+				return svcmocks.NewMockSessionService(ctrl), rememberMe
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			sessions, rememberMe := tc.mock(ctrl)
+			server := gin.New()
+			NewUserHandler(nil, nil, nil, rememberMe, nil, WithSessions(sessions)).RegisterRoutes(server)
+
+			path := "/users/logout"
+			if tc.logoutAll {
+				path += "?logoutAll=true"
+			}
+			req, err := http.NewRequest(http.MethodPost, path, nil)
+			require.NoError(t, err)
+			req.AddCookie(&http.Cookie{Name: RememberMeCookie, Value: "s1.t1"})
+			if tc.expiresIn != 0 {
+				token, err := jwt.NewWithClaims(jwt.SigningMethodHS512, UserClaims{
+					RegisteredClaims: jwt.RegisteredClaims{
+						ExpiresAt: jwt.NewNumericDate(time.Now().Add(tc.expiresIn)),
+					},
+					Uid:            3,
+					Ssid:           "ssid-1",
+					ImpersonatedBy: tc.impersonatedBy,
+				}).SignedString([]byte("95osj3fUD7fo0mlYdDbncXz4VD2igvf0"))
+				require.NoError(t, err)
+				req.Header.Set("Authorization", "Bearer "+token)
+			}
+			resp := httptest.NewRecorder()
+			server.ServeHTTP(resp, req)
+			require.Equal(t, http.StatusOK, resp.Code)
+
+			var res Result
+			require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &res))
+			assert.Equal(t, tc.wantCode, res.Code)
+			// 不管怎么样，这台设备的记住我都要清掉
+			assert.Contains(t, resp.Header().Get("Set-Cookie"), RememberMeCookie+"=;")
+		})
+	}
+}
+
+func TestUserHandler_setJWTTokenSession(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	sessions := svcmocks.NewMockSessionService(ctrl)
+	sessions.EXPECT().Create(gomock.Any(), int64(3), "test-agent").Return("ssid-1", nil)
+	u := NewUserHandler(nil, nil, nil, nil, nil, WithSessions(sessions))
+
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	ctx.Request = httptest.NewRequest(http.MethodPost, "/users/login", nil)
+	ctx.Request.Header.Set("User-Agent", "test-agent")
+	tokenStr, _, err := u.setJWTToken(ctx, 3, "")
+	require.NoError(t, err)
+
+	claims := &UserClaims{}
+	_, err = jwt.ParseWithClaims(tokenStr, claims, func(token *jwt.Token) (interface{}, error) {
+		return []byte("95osj3fUD7fo0mlYdDbncXz4VD2igvf0"), nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "ssid-1", claims.Ssid)
+}
+
+// session 和 JWT 登录的都走 /users/logout
+func TestUserHandler_Logout(t *testing.T) {
+	testCases := []struct {
+		name string
+		// JWT 还有多久过期，0 就是不带 JWT
+		expiresIn time.Duration
+		session   bool
+
+		wantRevoke bool
+	}{
+		{name: "只有 session", session: true},
+		{name: "只有 JWT", expiresIn: time.Minute, wantRevoke: true},
+		{name: "两个都有", session: true, expiresIn: time.Minute, wantRevoke: true},
+		// 签名是对的，过期了也删
+		{name: "JWT 过期了", expiresIn: -time.Hour, wantRevoke: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			nonces := svcmocks.NewMockJWTNonceService(ctrl)
+			if tc.wantRevoke {
+				nonces.EXPECT().Revoke(gomock.Any(), int64(3), "nonce-1").Return(nil)
+			}
+			server := gin.New()
+			server.Use(sessions.Sessions("ssid", memstore.NewStore([]byte("secret"))))
+			NewUserHandler(nil, nil, nil, nil, nil, WithJWTNonces(nonces)).RegisterRoutes(server)
+
+			req, err := http.NewRequest(http.MethodPost, "/users/logout", nil)
+			require.NoError(t, err)
+			if tc.session {
+				req.AddCookie(&http.Cookie{Name: "ssid", Value: "whatever"})
+			}
+			if tc.expiresIn != 0 {
+				token, err := jwt.NewWithClaims(jwt.SigningMethodHS512, UserClaims{
+					RegisteredClaims: jwt.RegisteredClaims{
+						ExpiresAt: jwt.NewNumericDate(time.Now().Add(tc.expiresIn)),
+					},
+					Uid:   3,
+					Nonce: "nonce-1",
+				}).SignedString([]byte("95osj3fUD7fo0mlYdDbncXz4VD2igvf0"))
+				require.NoError(t, err)
+				req.Header.Set("Authorization", "Bearer "+token)
+			}
+			resp := httptest.NewRecorder()
+			server.ServeHTTP(resp, req)
+			require.Equal(t, http.StatusOK, resp.Code)
+
+			var res Result
+			require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &res))
+			assert.Equal(t, Result{Msg: "退出登录成功"}, res)
+			// session 的 cookie 不管有没有都清掉
+			assert.Contains(t, strings.Join(resp.Header().Values("Set-Cookie"), "\n"), "ssid=;")
+		})
+	}
+}
@@ -0,0 +1,146 @@
+package web
+
+import (
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	gormMysql "gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"webook/internal/repository"
+	"webook/internal/repository/dao"
+	"webook/internal/service"
+	svcmocks "webook/internal/service/mocks"
+)
+
+func TestUserHandler_SignUpMode(t *testing.T) {
+	testCases := []struct {
+		name string
+		mode SignupMode
+		body string
+		// 数据库里面要执行的 SQL
+		mockDB   func(mock sqlmock.Sqlmock)
+		mockCode func(ctrl *gomock.Controller) service.CodeService
+
+		wantBody string
+		wantJWT  bool
+	}{
+		{
+			name: "手机号注册，直接登录",
+			mode: SignupModePhone,
+			body: `{"phone":"15212345678","code":"123456"}`,
+			mockDB: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec("INSERT INTO `users`").WillReturnResult(sqlmock.NewResult(5, 1))
+				mock.ExpectQuery("SELECT .* FROM `users` WHERE phone = .*").
+					WillReturnRows(sqlmock.NewRows([]string{"id", "phone"}).AddRow(5, "15212345678"))
+			},
+			mockCode: func(ctrl *gomock.Controller) service.CodeService {
+				codeSvc := svcmocks.NewMockCodeService(ctrl)
+				codeSvc.EXPECT().Verify(gomock.Any(), bizLogin, "15212345678", "123456").Return(true, nil)
+				return codeSvc
+			},
+			wantBody: "注册成功",
+			wantJWT:  true,
+		},
+		{
+			name: "手机号已经注册过了",
+			mode: SignupModePhone,
+			body: `{"phone":"15212345678","code":"123456"}`,
+			mockDB: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec("INSERT INTO `users`").WillReturnError(&mysql.MySQLError{
+					Number:  1062,
+					Message: "Duplicate entry '15212345678' for key 'users.phone'",
+				})
+			},
+			mockCode: func(ctrl *gomock.Controller) service.CodeService {
+				codeSvc := svcmocks.NewMockCodeService(ctrl)
+				codeSvc.EXPECT().Verify(gomock.Any(), bizLogin, "15212345678", "123456").Return(true, nil)
+				return codeSvc
+			},
+			wantBody: "该手机号已注册",
+		},
+		{
+			name: "验证码不对",
+			mode: SignupModePhone,
+			body: `{"phone":"15212345678","code":"123456"}`,
+			mockCode: func(ctrl *gomock.Controller) service.CodeService {
+				codeSvc := svcmocks.NewMockCodeService(ctrl)
+				codeSvc.EXPECT().Verify(gomock.Any(), bizLogin, "15212345678", "123456").Return(false, nil)
+				return codeSvc
+			},
+			wantBody: "验证码有误",
+		},
+		{
+			name:     "手机号注册不填验证码",
+			mode:     SignupModePhone,
+			body:     `{"phone":"15212345678","email":"tom@qq.com","password":"hello#world123"}`,
+			wantBody: "请输入验证码",
+		},
+		{
+			name:     "两种都行，都没填",
+			mode:     SignupModeEither,
+			body:     `{"password":"hello#world123"}`,
+			wantBody: "请填写邮箱或者手机号",
+		},
+		{
+			name:     "两种都行，填了邮箱就按邮箱注册",
+			mode:     SignupModeEither,
+			body:     `{"email":"tom","phone":"15212345678","code":"123456"}`,
+			wantBody: "你的邮箱格式不对",
+		},
+		{
+			name: "两种都行，只填了手机号",
+			mode: SignupModeEither,
+			body: `{"phone":"15212345678","code":"123456"}`,
+			mockCode: func(ctrl *gomock.Controller) service.CodeService {
+				codeSvc := svcmocks.NewMockCodeService(ctrl)
+				codeSvc.EXPECT().Verify(gomock.Any(), bizLogin, "15212345678", "123456").Return(false, nil)
+				return codeSvc
+			},
+			wantBody: "验证码有误",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			mockDB, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			if tc.mockDB != nil {
+				tc.mockDB(mock)
+			}
+			db, err := gorm.Open(gormMysql.New(gormMysql.Config{
+				Conn:                      mockDB,
+				SkipInitializeWithVersion: true,
+			}), &gorm.Config{
+				DisableAutomaticPing:   true,
+				SkipDefaultTransaction: true,
+			})
+			require.NoError(t, err)
+			svc := service.NewUserService(repository.NewUserRepository(dao.NewUserDAO(db), nil),
+				nil, nil, service.SensitiveModeReject, nil, nil)
+			var codeSvc service.CodeService
+			if tc.mockCode != nil {
+				codeSvc = tc.mockCode(ctrl)
+			}
+			server := gin.New()
+			NewUserHandler(svc, codeSvc, nil, nil, nil, WithSignupMode(tc.mode)).RegisterRoutes(server)
+
+			req, err := http.NewRequest(http.MethodPost, "/users/signup", strings.NewReader(tc.body))
+			require.NoError(t, err)
+			req.Header.Set("Content-Type", "application/json")
+			resp := httptest.NewRecorder()
+			server.ServeHTTP(resp, req)
+			require.Equal(t, http.StatusOK, resp.Code)
+			assert.Equal(t, tc.wantBody, resp.Body.String())
+			assert.Equal(t, tc.wantJWT, resp.Header().Get("x-jwt-token") != "")
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
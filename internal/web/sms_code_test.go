@@ -0,0 +1,280 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"webook/internal/service"
+	svcmocks "webook/internal/service/mocks"
+	"webook/pkg/ginx/middlewares/ratelimit"
+)
+
+// okFormTokenService 什么 token 都放过
+type okFormTokenService struct{}
+
+func (okFormTokenService) Issue(ctx context.Context) (string, error) {
+	return "token", nil
+}
+
+func (okFormTokenService) Consume(ctx context.Context, token string) error {
+	return nil
+}
+
+func TestUserHandler_SendLoginSMSCodeRateLimitHeaders(t *testing.T) {
+	testCases := []struct {
+		name string
+		mock func(ctrl *gomock.Controller) service.CodeService
+
+		wantLimit      string
+		wantRemaining  string
+		wantRetryAfter string
+	}{
+		{
+			name: "发送成功，要等一个冷却期",
+			mock: func(ctrl *gomock.Controller) service.CodeService {
+				codeSvc := svcmocks.NewMockCodeService(ctrl)
+				codeSvc.EXPECT().Send(gomock.Any(), bizLogin, "15212345678", gomock.Any()).Return(nil)
+				codeSvc.EXPECT().Cooldown(gomock.Any(), bizLogin, "15212345678").Return(time.Minute, nil)
+				return codeSvc
+			},
+			wantLimit:      "1",
+			wantRemaining:  "0",
+			wantRetryAfter: "60",
+		},
+		{
+			name: "发送太频繁，返回剩下的冷却时间",
+			mock: func(ctrl *gomock.Controller) service.CodeService {
+				codeSvc := svcmocks.NewMockCodeService(ctrl)
+				codeSvc.EXPECT().Send(gomock.Any(), bizLogin, "15212345678", gomock.Any()).
+					Return(service.ErrCodeSendTooMany)
+				codeSvc.EXPECT().Cooldown(gomock.Any(), bizLogin, "15212345678").
+					Return(12*time.Second+time.Millisecond, nil)
+				return codeSvc
+			},
+			wantLimit:      "1",
+			wantRemaining:  "0",
+			wantRetryAfter: "13",
+		},
+		{
+			name: "发送太频繁，错误里面带了要等多久，不用再查",
+			mock: func(ctrl *gomock.Controller) service.CodeService {
+				codeSvc := svcmocks.NewMockCodeService(ctrl)
+				codeSvc.EXPECT().Send(gomock.Any(), bizLogin, "15212345678", gomock.Any()).
+					Return(&service.CodeSendTooManyError{RetryAfter: 37 * time.Second})
+				return codeSvc
+			},
+			wantLimit:      "1",
+			wantRemaining:  "0",
+			wantRetryAfter: "37",
+		},
+		{
+			name: "查冷却时间出错了，不带这几个头",
+			mock: func(ctrl *gomock.Controller) service.CodeService {
+				codeSvc := svcmocks.NewMockCodeService(ctrl)
+				codeSvc.EXPECT().Send(gomock.Any(), bizLogin, "15212345678", gomock.Any()).Return(nil)
+				codeSvc.EXPECT().Cooldown(gomock.Any(), bizLogin, "15212345678").
+					Return(time.Duration(0), context.DeadlineExceeded)
+				return codeSvc
+			},
+		},
+		{
+			name: "系统错误，不查冷却时间",
+			mock: func(ctrl *gomock.Controller) service.CodeService {
+				codeSvc := svcmocks.NewMockCodeService(ctrl)
+				codeSvc.EXPECT().Send(gomock.Any(), bizLogin, "15212345678", gomock.Any()).
+					Return(service.ErrCodeServiceBusy)
+				return codeSvc
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			server := gin.New()
+			NewUserHandler(nil, tc.mock(ctrl), okFormTokenService{}, nil, nil).RegisterRoutes(server)
+
+			req, err := http.NewRequest(http.MethodPost, "/users/login_sms/code/send",
+				strings.NewReader(`{"phone":"15212345678","formToken":"token"}`))
+			require.NoError(t, err)
+			req.Header.Set("Content-Type", "application/json")
+			resp := httptest.NewRecorder()
+			server.ServeHTTP(resp, req)
+			require.Equal(t, http.StatusOK, resp.Code)
+
+			assert.Equal(t, tc.wantLimit, resp.Header().Get(ratelimit.HeaderLimit))
+			assert.Equal(t, tc.wantRemaining, resp.Header().Get(ratelimit.HeaderRemaining))
+			assert.Equal(t, tc.wantRetryAfter, resp.Header().Get(ratelimit.HeaderRetryAfter))
+		})
+	}
+}
+
+func TestUserHandler_ResendLoginSMSCode(t *testing.T) {
+	testCases := []struct {
+		name string
+		mock func(ctrl *gomock.Controller) service.CodeService
+
+		wantMsg        string
+		wantRetryAfter int64
+		wantData       bool
+	}{
+		{
+			name: "重发成功，下一次要等两分钟",
+			mock: func(ctrl *gomock.Controller) service.CodeService {
+				codeSvc := svcmocks.NewMockCodeService(ctrl)
+				codeSvc.EXPECT().Send(gomock.Any(), bizLogin, "15212345678", gomock.Any()).Return(nil)
+				codeSvc.EXPECT().Cooldown(gomock.Any(), bizLogin, "15212345678").Return(2*time.Minute, nil)
+				return codeSvc
+			},
+			wantMsg:        "发送成功",
+			wantRetryAfter: 120,
+			wantData:       true,
+		},
+		{
+			name: "还没到时间",
+			mock: func(ctrl *gomock.Controller) service.CodeService {
+				codeSvc := svcmocks.NewMockCodeService(ctrl)
+				codeSvc.EXPECT().Send(gomock.Any(), bizLogin, "15212345678", gomock.Any()).
+					Return(service.ErrCodeSendTooMany)
+				codeSvc.EXPECT().Cooldown(gomock.Any(), bizLogin, "15212345678").
+					Return(12*time.Second+time.Millisecond, nil)
+				return codeSvc
+			},
+			wantMsg:        "发送太频繁，请稍后再试",
+			wantRetryAfter: 13,
+			wantData:       true,
+		},
+		{
+			name: "查冷却时间出错了，不返回时间",
+			mock: func(ctrl *gomock.Controller) service.CodeService {
+				codeSvc := svcmocks.NewMockCodeService(ctrl)
+				codeSvc.EXPECT().Send(gomock.Any(), bizLogin, "15212345678", gomock.Any()).Return(nil)
+				codeSvc.EXPECT().Cooldown(gomock.Any(), bizLogin, "15212345678").
+					Return(time.Duration(0), context.DeadlineExceeded)
+				return codeSvc
+			},
+			wantMsg: "发送成功",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			server := gin.New()
+			NewUserHandler(nil, tc.mock(ctrl), okFormTokenService{}, nil, nil).RegisterRoutes(server)
+
+			req, err := http.NewRequest(http.MethodPost, "/users/login_sms/code/resend",
+				strings.NewReader(`{"phone":"15212345678","formToken":"token"}`))
+			require.NoError(t, err)
+			req.Header.Set("Content-Type", "application/json")
+			resp := httptest.NewRecorder()
+			now := time.Now()
+			server.ServeHTTP(resp, req)
+			require.Equal(t, http.StatusOK, resp.Code)
+
+			var res struct {
+				Code int         `json:"code"`
+				Msg  string      `json:"msg"`
+				Data *codeSentVO `json:"data"`
+			}
+			require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &res))
+			assert.Equal(t, 0, res.Code)
+			assert.Equal(t, tc.wantMsg, res.Msg)
+			if !tc.wantData {
+				assert.Nil(t, res.Data)
+				return
+			}
+			require.NotNil(t, res.Data)
+			assert.Equal(t, tc.wantRetryAfter, res.Data.RetryAfter)
+			next, err := time.Parse(time.RFC3339, res.Data.NextAllowedAt)
+			require.NoError(t, err)
+			assert.WithinDuration(t, now.Add(time.Duration(tc.wantRetryAfter)*time.Second), next, 2*time.Second)
+		})
+	}
+}
+
+func TestUserHandler_LoginSMSRemainingAttempts(t *testing.T) {
+	testCases := []struct {
+		name     string
+		res      service.VerifyResult
+		err      error
+		wantMsg  string
+		wantData any
+	}{
+		{
+			name:     "还剩两次",
+			res:      service.VerifyResult{RemainingAttempts: 2},
+			wantMsg:  "验证码有误，还可以再试 2 次",
+			wantData: map[string]any{"remaining_attempts": float64(2)},
+		},
+		{
+			name:    "这一次刚好用完，不再说还剩几次",
+			res:     service.VerifyResult{RemainingAttempts: 0},
+			wantMsg: "验证次数太多，请重新获取验证码",
+		},
+		{
+			name:    "早就用完了",
+			err:     service.ErrCodeVerifyTooManyTimes,
+			wantMsg: "验证次数太多，请重新获取验证码",
+		},
+		{
+			name:    "没发过或者过期了，不知道还剩几次",
+			res:     service.VerifyResult{RemainingAttempts: -1},
+			wantMsg: "验证码有误",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			codeSvc := svcmocks.NewMockCodeService(ctrl)
+			codeSvc.EXPECT().VerifyWithResult(gomock.Any(), bizLogin, "15212345678", "000000").
+				Return(tc.res, tc.err)
+			server := gin.New()
+			NewUserHandler(nil, codeSvc, nil, nil, nil).RegisterRoutes(server)
+
+			req, err := http.NewRequest(http.MethodPost, "/users/login_sms",
+				strings.NewReader(`{"phone":"15212345678","code":"000000"}`))
+			require.NoError(t, err)
+			req.Header.Set("Content-Type", "application/json")
+			resp := httptest.NewRecorder()
+			server.ServeHTTP(resp, req)
+			require.Equal(t, http.StatusOK, resp.Code)
+
+			var res Result
+			require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &res))
+			assert.Equal(t, 4, res.Code)
+			assert.Equal(t, tc.wantMsg, res.Msg)
+			assert.Equal(t, tc.wantData, res.Data)
+		})
+	}
+}
+
+func TestUserHandler_SendLoginSMSCodeDailyQuota(t *testing.T) {
+	resetAt := time.Date(2023, 8, 2, 0, 0, 0, 0, time.UTC)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	codeSvc := svcmocks.NewMockCodeService(ctrl)
+	codeSvc.EXPECT().Send(gomock.Any(), bizLogin, "15212345678", gomock.Any()).
+		Return(&service.SMSQuotaExceededError{ResetAt: resetAt})
+	server := gin.New()
+	NewUserHandler(nil, codeSvc, okFormTokenService{}, nil, nil).RegisterRoutes(server)
+
+	req, err := http.NewRequest(http.MethodPost, "/users/login_sms/code/send",
+		strings.NewReader(`{"phone":"15212345678","formToken":"token"}`))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+	server.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusOK, resp.Code)
+	assert.JSONEq(t, `{"code":4,"msg":"今日验证码发送次数已达上限，请明天再试",
+		"data":{"reset_at":"2023-08-02T00:00:00Z"}}`, resp.Body.String())
+}
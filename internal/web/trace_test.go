@@ -0,0 +1,86 @@
+package web
+
+import (
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"webook/internal/repository"
+	"webook/internal/repository/cache"
+	"webook/internal/service"
+	"webook/internal/service/sms/memory"
+	smsotel "webook/internal/service/sms/opentelemetry"
+)
+
+// 发验证码的时候 Redis 和短信服务商的 span 都要挂在 HTTP 的 span 下面
+func TestUserHandler_SendLoginSMSCodeTrace(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() {
+		_ = client.Close()
+	})
+	codeSvc := service.NewCodeService(
+		repository.NewCodeRepository(cache.NewTracedCodeCache(cache.NewCodeCacheGoBestPractice(client), tp)),
+		smsotel.NewTracedSMSService(memory.NewService(), tp),
+		cache.NewSMSQuotaCache(client), service.SMSQuotaConfig{}, nil)
+
+	server := gin.New()
+	// 跟 ioc.InitWebServer 一样，不然 gin.Context 里面拿不到 otelgin 放进去的 span
+	server.ContextWithFallback = true
+	server.Use(otelgin.Middleware("webook", otelgin.WithTracerProvider(tp)))
+	NewUserHandler(nil, codeSvc, okFormTokenService{}, nil, nil).RegisterRoutes(server)
+
+	req, err := http.NewRequest(http.MethodPost, "/users/login_sms/code/send",
+		strings.NewReader(`{"phone":"15212345678","formToken":"token"}`))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+	server.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusOK, resp.Code)
+
+	spans := exporter.GetSpans()
+	byName := make(map[string]tracetest.SpanStub, len(spans))
+	for _, s := range spans {
+		byName[s.Name] = s
+	}
+	root, ok := byName["/users/login_sms/code/send"]
+	require.True(t, ok)
+	for _, name := range []string{"CodeCache.set_code", "SMS.Send", "CodeCache.cooldown"} {
+		child, ok := byName[name]
+		require.True(t, ok, name)
+		assert.Equal(t, root.SpanContext.TraceID(), child.SpanContext.TraceID(), name)
+		assert.Equal(t, root.SpanContext.SpanID(), child.Parent.SpanID(), name)
+	}
+	require.Len(t, spans, 4)
+
+	attrs := func(s tracetest.SpanStub) map[attribute.Key]string {
+		res := make(map[attribute.Key]string)
+		for _, kv := range s.Attributes {
+			res[kv.Key] = kv.Value.Emit()
+		}
+		return res
+	}
+	set := attrs(byName["CodeCache.set_code"])
+	assert.Equal(t, bizLogin, set["phone_biz"])
+	assert.Equal(t, cache.PhoneHash("15212345678"), set["phone_hash"])
+	assert.Equal(t, "ok", set["code_cache.result"])
+	send := attrs(byName["SMS.Send"])
+	assert.Equal(t, "1", send["sms.numbers"])
+	// 手机号和验证码都不能出现在 span 里面
+	for _, s := range spans {
+		for k, v := range attrs(s) {
+			assert.NotContains(t, v, "15212345678", k)
+		}
+	}
+}
@@ -3,39 +3,183 @@ package web
 import (
 	"basic-go/webook/internal/domain"
 	"basic-go/webook/internal/service"
+	"basic-go/webook/pkg/geoip"
+	"basic-go/webook/pkg/ginx/middlewares/ratelimit"
+	ratelimitx "basic-go/webook/pkg/ratelimit"
+	"basic-go/webook/pkg/region"
+	"errors"
 	"fmt"
 	regexp "github.com/dlclark/regexp2"
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
 	jwt "github.com/golang-jwt/jwt/v5"
+	"io"
+	"log"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 	"unicode/utf8"
 )
 
 // UserHandler 我准备在它上面定义跟用户有关的路由
 type UserHandler struct {
-	svc         *service.UserService
-	emailExp    *regexp.Regexp
-	passwordExp *regexp.Regexp
-	birthdayExp *regexp.Regexp
+	svc           *service.UserService
+	codeSvc       service.CodeService
+	formTokenSvc  service.FormTokenService
+	rememberMeSvc service.RememberMeService
+	regions       *region.Dictionary
+	emailExp      *regexp.Regexp
+	passwordExp   *regexp.Regexp
+	birthdayExp   *regexp.Regexp
+	idCardExp     *regexp.Regexp
+	phoneExp      *regexp.Regexp
+	// 小写的域名，空的就是不限制
+	allowedEmailDomains map[string]struct{}
+	sessionCfg          SessionConfig
+	// 填生日的话至少要满几周岁，0 就是不限制
+	minimumAge int
+	// 没有的话 JWT 里面不带 ssid，退出登录也只管记住我
+	sessionSvc service.SessionService
+	// 没有的话 JWT 里面不带 nonce
+	nonceSvc   service.JWTNonceService
+	signupMode SignupMode
+	// 登录历史里面显示 IP 是哪个国家的，nil 就不显示
+	geo geoip.GeoLookup
 }
 
-func NewUserHandler(svc *service.UserService) *UserHandler {
+// SignupMode 注册的时候要填什么
+type SignupMode uint8
+
+const (
+	// SignupModeEmail 邮箱加密码，手机号可以不填。默认是这个
+	SignupModeEmail SignupMode = iota
+	// SignupModePhone 手机号加短信验证码，不用设置密码，注册完直接登录
+	SignupModePhone
+	// SignupModeEither 两种都可以，填了邮箱就按邮箱注册
+	SignupModeEither
+)
+
+type UserHandlerOption func(u *UserHandler)
+
+// SessionConfig 登录态 cookie 的属性
+type SessionConfig struct {
+	// 只通过 HTTPS 发送，本地用 HTTP 开发的时候要关掉
+	Secure bool
+	// 不设置就是 http.SameSiteLaxMode
+	SameSite http.SameSite
+}
+
+// WithSessionConfig 不传的话 Secure 是 true，SameSite 是 Lax
+func WithSessionConfig(cfg SessionConfig) UserHandlerOption {
+	return func(u *UserHandler) {
+		if cfg.SameSite == 0 {
+			cfg.SameSite = http.SameSiteLaxMode
+		}
+		u.sessionCfg = cfg
+	}
+}
+
+// WithAllowedEmailDomains 只允许这些域名的邮箱注册，比如说 mycompany.com，前面带不带 @ 都可以。
+// 不区分大小写，子域名不会自动放行，要单独配置。不传就是不限制
+func WithAllowedEmailDomains(domains ...string) UserHandlerOption {
+	return func(u *UserHandler) {
+		u.allowedEmailDomains = make(map[string]struct{}, len(domains))
+		for _, d := range domains {
+			d = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(d), "@"))
+			if d != "" {
+				u.allowedEmailDomains[d] = struct{}{}
+			}
+		}
+	}
+}
+
+// WithMinimumAge 生日要满 years 周岁，0 就是不限制。不传就是 13 岁
+func WithMinimumAge(years int) UserHandlerOption {
+	return func(u *UserHandler) {
+		u.minimumAge = years
+	}
+}
+
+// WithSignupMode 不传就是 SignupModeEmail
+func WithSignupMode(mode SignupMode) UserHandlerOption {
+	return func(u *UserHandler) {
+		u.signupMode = mode
+	}
+}
+
+// WithSessions 每台设备登录之后一个会话，可以只退出这台设备，也可以退出所有设备
+func WithSessions(svc service.SessionService) UserHandlerOption {
+	return func(u *UserHandler) {
+		u.sessionSvc = svc
+	}
+}
+
+// WithJWTNonces 每个 JWT 带一个 nonce，退出登录之后这个 token 马上失效
+func WithJWTNonces(svc service.JWTNonceService) UserHandlerOption {
+	return func(u *UserHandler) {
+		u.nonceSvc = svc
+	}
+}
+
+// WithGeoLookup 登录历史里面按 IP 显示国家或者地区
+func WithGeoLookup(geo geoip.GeoLookup) UserHandlerOption {
+	return func(u *UserHandler) {
+		u.geo = geo
+	}
+}
+
+func NewUserHandler(svc *service.UserService, codeSvc service.CodeService,
+	formTokenSvc service.FormTokenService, rememberMeSvc service.RememberMeService,
+	regions *region.Dictionary, opts ...UserHandlerOption) *UserHandler {
 	const (
 		emailRegexPattern    = "^\\w+([-+.]\\w+)*@\\w+([-.]\\w+)*\\.\\w+([-.]\\w+)*$"
 		passwordRegexPattern = `^(?=.*[A-Za-z])(?=.*\d)(?=.*[$@$!%*#?&])[A-Za-z\d$@$!%*#?&]{8,}$`
 		birthdayPattern      = `\d{4}-\d{2}-\d{2}`
+		// 18 位身份证号码，最后一位可能是 X
+		idCardPattern = `^[1-9]\d{5}(18|19|20)\d{2}(0[1-9]|1[0-2])(0[1-9]|[12]\d|3[01])\d{3}[\dXx]$`
+		// 大陆的手机号
+		phonePattern = `^1[3-9]\d{9}$`
 	)
 	emailExp := regexp.MustCompile(emailRegexPattern, regexp.None)
 	passwordExp := regexp.MustCompile(passwordRegexPattern, regexp.None)
 	birthdayExp := regexp.MustCompile(birthdayPattern, regexp.None)
-	return &UserHandler{
-		svc:         svc,
-		emailExp:    emailExp,
-		passwordExp: passwordExp,
-		birthdayExp: birthdayExp,
+	idCardExp := regexp.MustCompile(idCardPattern, regexp.None)
+	phoneExp := regexp.MustCompile(phonePattern, regexp.None)
+	u := &UserHandler{
+		svc:           svc,
+		codeSvc:       codeSvc,
+		formTokenSvc:  formTokenSvc,
+		rememberMeSvc: rememberMeSvc,
+		regions:       regions,
+		emailExp:      emailExp,
+		passwordExp:   passwordExp,
+		birthdayExp:   birthdayExp,
+		idCardExp:     idCardExp,
+		phoneExp:      phoneExp,
+		sessionCfg: SessionConfig{
+			Secure:   true,
+			SameSite: http.SameSiteLaxMode,
+		},
+		minimumAge: 13,
+	}
+	for _, opt := range opts {
+		opt(u)
+	}
+	return u
+}
+
+// emailDomainAllowed 邮箱格式要先校验过
+func (u *UserHandler) emailDomainAllowed(email string) bool {
+	if len(u.allowedEmailDomains) == 0 {
+		return true
 	}
+	i := strings.LastIndex(email, "@")
+	if i < 0 {
+		return false
+	}
+	_, ok := u.allowedEmailDomains[strings.ToLower(email[i+1:])]
+	return ok
 }
 
 func (u *UserHandler) RegisterRoutesV1(ug *gin.RouterGroup) {
@@ -47,26 +191,70 @@ func (u *UserHandler) RegisterRoutesV1(ug *gin.RouterGroup) {
 
 func (u *UserHandler) RegisterRoutes(server *gin.Engine) {
 	ug := server.Group("/users")
-	ug.GET("/profile", u.ProfileJWT)
+	ug.GET("/profile", u.Profile)
 	ug.POST("/signup", u.SignUp)
 	ug.POST("/login", u.Login)
 	//ug.POST("/login", u.LoginJWT)
 	ug.POST("/edit", u.Edit)
 	ug.POST("/profile", u.Profile)
+	ug.POST("/profiles", u.GetProfiles)
+	// 给内部服务用的，跟 /profiles 一样，校验的是内部的 token 而不是用户的登录态
+	ug.POST("/batch", u.GetProfiles)
+	ug.GET("/me", u.Me)
+	ug.GET("/whoami", u.WhoAmI)
+	ug.GET("/me/impersonation", u.Impersonation)
+	ug.GET("/me/login_history", u.LoginHistory)
+	ug.GET("/sms/prepare", u.PrepareSMS)
+	ug.POST("/login_sms/code/send", u.SendLoginSMSCode)
+	// 重发跟发送是一样的：换一个新的验证码，旧的不能用了，每重发一次要多等一分钟
+	ug.POST("/login_sms/code/resend", u.SendLoginSMSCode)
+	ug.POST("/login_sms", u.LoginSMS)
+	ug.POST("/logout", u.LogoutJWT)
+	ug.POST("/delete/code/send", u.SendDeleteAccountCode)
+	ug.POST("/delete", u.DeleteAccount)
+	ug.POST("/deactivate", u.Deactivate)
+	ug.POST("/me/export_data", u.ExportData)
+	ug.POST("/verify_identity", u.VerifyIdentity)
+	ug.POST("/phone/change/code/send", u.InitiatePhoneChange)
+	ug.POST("/phone/change", u.ConfirmPhoneChange)
+	ug.POST("/verify_email/resend", u.ResendVerificationEmail)
+	// 老的路径，以前要带密码，现在带了也不看
+	ug.POST("/email/verification/resend", u.ResendVerificationEmail)
+	ug.GET("/email/verify", u.VerifyEmail)
+	server.GET("/session/anonymous", u.AnonymousSession)
 }
 
+// SignUp 请求体：JSON 或者表单
 func (u *UserHandler) SignUp(ctx *gin.Context) {
 	type SignUpReq struct {
-		Email           string `json:"email"`
-		ConfirmPassword string `json:"confirmPassword"`
-		Password        string `json:"password"`
+		Email           string `json:"email" form:"email"`
+		ConfirmPassword string `json:"confirmPassword" form:"confirmPassword"`
+		Password        string `json:"password" form:"password"`
+		// 按邮箱注册的时候可以不填
+		Phone string `json:"phone" form:"phone"`
+		// 按手机号注册的时候，/users/login_sms/code/send 发的验证码
+		Code string `json:"code" form:"code"`
 	}
 
 	var req SignUpReq
-	// Bind 方法会根据 Content-Type 来解析你的数据到 req 里面
+	// 根据 Content-Type 按 JSON 或者表单来解析你的数据到 req 里面
 	// 解析错了，就会直接写回一个 400 的错误
-	if err := ctx.Bind(&req); err != nil {
+	if err := bindJSONOrForm(ctx, &req); err != nil {
+		return
+	}
+	switch u.signupMode {
+	case SignupModePhone:
+		u.signUpByPhone(ctx, req.Phone, req.Code)
 		return
+	case SignupModeEither:
+		if req.Email == "" && req.Phone == "" {
+			ctx.String(http.StatusOK, "请填写邮箱或者手机号")
+			return
+		}
+		if req.Email == "" {
+			u.signUpByPhone(ctx, req.Phone, req.Code)
+			return
+		}
 	}
 
 	ok, err := u.emailExp.MatchString(req.Email)
@@ -78,6 +266,10 @@ func (u *UserHandler) SignUp(ctx *gin.Context) {
 		ctx.String(http.StatusOK, "你的邮箱格式不对")
 		return
 	}
+	if !u.emailDomainAllowed(req.Email) {
+		ctx.String(http.StatusOK, "注册仅限公司邮箱")
+		return
+	}
 	if req.ConfirmPassword != req.Password {
 		ctx.String(http.StatusOK, "两次输入的密码不一致")
 		return
@@ -92,16 +284,40 @@ func (u *UserHandler) SignUp(ctx *gin.Context) {
 		ctx.String(http.StatusOK, "密码必须大于8位，包含数字、特殊字符")
 		return
 	}
+	if req.Phone != "" {
+		ok, err = u.phoneExp.MatchString(req.Phone)
+		if err != nil {
+			ctx.String(http.StatusOK, "系统错误")
+			return
+		}
+		if !ok {
+			ctx.String(http.StatusOK, "手机号格式不对")
+			return
+		}
+	}
 
 	// 调用一下 svc 的方法
 	err = u.svc.SignUp(ctx, domain.User{
 		Email:    req.Email,
 		Password: req.Password,
+		Phone:    req.Phone,
 	})
 	if err == service.ErrUserDuplicateEmail {
 		ctx.String(http.StatusOK, "邮箱冲突")
 		return
 	}
+	if err == service.ErrUserDuplicatePhone {
+		ctx.String(http.StatusOK, "该手机号已注册")
+		return
+	}
+	if err == service.ErrDisposableEmail {
+		ctx.String(http.StatusOK, "不支持使用一次性邮箱注册")
+		return
+	}
+	if err == service.ErrSignupTooFrequent {
+		ctx.String(http.StatusOK, "注册太频繁，请稍后再试")
+		return
+	}
 	if err != nil {
 		ctx.String(http.StatusOK, "系统异常")
 		return
@@ -110,17 +326,98 @@ func (u *UserHandler) SignUp(ctx *gin.Context) {
 	ctx.String(http.StatusOK, "注册成功")
 }
 
+// signUpByPhone 验证码跟短信登录用的是同一个，注册完直接登录
+func (u *UserHandler) signUpByPhone(ctx *gin.Context, phone, code string) {
+	ok, err := u.phoneExp.MatchString(phone)
+	if err != nil {
+		ctx.String(http.StatusOK, "系统错误")
+		return
+	}
+	if !ok {
+		ctx.String(http.StatusOK, "手机号格式不对")
+		return
+	}
+	if code == "" {
+		ctx.String(http.StatusOK, "请输入验证码")
+		return
+	}
+	ok, err = u.codeSvc.Verify(ctx, bizLogin, phone, code)
+	if err == service.ErrCodeServiceBusy {
+		ctx.String(http.StatusOK, "系统繁忙，请稍后再试")
+		return
+	}
+	if err != nil {
+		ctx.String(http.StatusOK, "系统错误")
+		return
+	}
+	if !ok {
+		ctx.String(http.StatusOK, "验证码有误")
+		return
+	}
+	user, err := u.svc.SignUpByPhone(ctx, phone)
+	if err == service.ErrUserDuplicatePhone {
+		ctx.String(http.StatusOK, "该手机号已注册")
+		return
+	}
+	if err != nil {
+		ctx.String(http.StatusOK, "系统异常")
+		return
+	}
+	if _, _, err = u.setJWTToken(ctx, user.Id, u.upgradeAnonymousSession(ctx, user.Id)); err != nil {
+		ctx.String(http.StatusOK, "系统异常")
+		return
+	}
+	ctx.String(http.StatusOK, "注册成功")
+}
+
+// LoginJWT 请求体：JSON 或者表单
 func (u *UserHandler) LoginJWT(ctx *gin.Context) {
 	type LoginReq struct {
-		Email    string `json:"email"`
-		Password string `json:"password"`
+		Email    string `json:"email" form:"email"`
+		Password string `json:"password" form:"password"`
+		// 账号在注销冷静期内或者停用了，确认要恢复账号
+		Reactivate bool `json:"reactivate" form:"reactivate"`
+		// 记住我，JWT 过期了也能用 cookie 换一个新的
+		RememberMe bool `json:"remember_me" form:"remember_me"`
 	}
 
 	var req LoginReq
-	if err := ctx.Bind(&req); err != nil {
+	if err := bindJSONOrForm(ctx, &req); err != nil {
 		return
 	}
 	user, err := u.svc.Login(ctx, req.Email, req.Password)
+	if err == service.ErrAccountPendingDeletion && req.Reactivate {
+		user, err = u.svc.Reactivate(ctx, req.Email, req.Password)
+	}
+	if err == service.ErrAccountDeactivated && req.Reactivate {
+		user, err = u.svc.ReactivateDeactivated(ctx, req.Email, req.Password)
+	}
+	// 找到了用户但是没登录上的，也记一笔
+	if err != nil && user.Id != 0 {
+		u.recordLogin(ctx, user.Id, false)
+	}
+	if err == service.ErrAccountDeactivated {
+		ctx.String(http.StatusOK, "账号已停用，确认之后可以恢复")
+		return
+	}
+	if err == service.ErrAccountPendingDeletion {
+		ctx.String(http.StatusOK, "账号已注销，30 天内重新登录可以恢复账号")
+		return
+	}
+	if err == service.ErrUserDuplicateEmail {
+		ctx.String(http.StatusOK, "邮箱已经被别人注册，无法恢复账号")
+		return
+	}
+	if err == service.ErrUserDuplicatePhone {
+		ctx.String(http.StatusOK, "手机号已经被别人注册，无法恢复账号")
+		return
+	}
+	if u.writeBanned(ctx, err) {
+		return
+	}
+	if u.writeEmailNotVerified(ctx, err) {
+		return
+	}
 	if err == service.ErrInvalidUserOrPassword {
 		ctx.String(http.StatusOK, "用户名或密码不对")
 		return
@@ -133,37 +430,511 @@ func (u *UserHandler) LoginJWT(ctx *gin.Context) {
 	// 步骤2
 	// 在这里用 JWT 设置登录态
 	// 生成一个 JWT token
+	token, expiresAt, err := u.setJWTToken(ctx, user.Id, u.upgradeAnonymousSession(ctx, user.Id))
+	if err != nil {
+		ctx.String(http.StatusInternalServerError, "系统错误")
+		return
+	}
+	if req.RememberMe {
+		u.rememberMe(ctx, user.Id)
+	}
+	u.recordLogin(ctx, user.Id, true)
+	// 老的客户端还是从 x-jwt-token 里面拿 token
+	ctx.JSON(http.StatusOK, Result{
+		Msg: "登录成功",
+		Data: loginJWTVO{
+			Token:     token,
+			ExpiresAt: expiresAt.Format(time.RFC3339),
+			User:      u.publicProfileVO(user),
+		},
+	})
+}
 
+// loginJWTVO 登录的时候把资料一起返回，前端不用再查一次 profile
+type loginJWTVO struct {
+	Token string `json:"token"`
+	// RFC3339，跟 JWT 里面的 exp 一样
+	ExpiresAt string          `json:"expires_at"`
+	User      publicProfileVO `json:"user"`
+}
+
+// setJWTToken 放到 x-jwt-token 里面，返回 token 和它的过期时间
+func (u *UserHandler) setJWTToken(ctx *gin.Context, uid int64, anonID string) (string, time.Time, error) {
+	var ssid string
+	if u.sessionSvc != nil {
+		var err error
+		ssid, err = u.sessionSvc.Create(ctx, uid, ctx.Request.UserAgent())
+		if err != nil {
+			return "", time.Time{}, err
+		}
+	}
+	now := time.Now()
 	claims := UserClaims{
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Minute)),
 		},
-		Uid:       user.Id,
-		UserAgent: ctx.Request.UserAgent(),
+		Uid:           uid,
+		UserAgent:     ctx.Request.UserAgent(),
+		AnonID:        anonID,
+		Ssid:          ssid,
+		ClaimsVersion: CurrentClaimsVersion,
+		Role:          RoleUser,
+		TokenType:     TokenTypeAccess,
+	}
+	if u.nonceSvc != nil {
+		var err error
+		claims.Nonce, err = u.nonceSvc.Issue(ctx, uid, claims.ExpiresAt.Time)
+		if err != nil {
+			return "", time.Time{}, err
+		}
 	}
 	token := jwt.NewWithClaims(jwt.SigningMethodHS512, claims)
 	tokenStr, err := token.SignedString([]byte("95osj3fUD7fo0mlYdDbncXz4VD2igvf0"))
 	if err != nil {
-		ctx.String(http.StatusInternalServerError, "系统错误")
-		return
+		return "", time.Time{}, err
 	}
 	ctx.Header("x-jwt-token", tokenStr)
-	fmt.Println(user)
-	ctx.String(http.StatusOK, "登录成功")
-	return
+	return tokenStr, claims.ExpiresAt.Time, nil
+}
+
+// rememberMe 勾选了记住我，发一个持久的 cookie。发不出来也不影响这次登录
+func (u *UserHandler) rememberMe(ctx *gin.Context, uid int64) {
+	t, token, err := u.rememberMeSvc.Issue(ctx, uid)
+	if err != nil {
+		log.Println("生成记住我的凭证失败", err)
+		return
+	}
+	SetRememberMeCookie(ctx, t, token)
+}
+
+// bannedVO 登录的时候告诉用户为什么被封禁，until 为空是永久封禁
+type bannedVO struct {
+	Reason string `json:"reason"`
+	Until  string `json:"until,omitempty"`
+}
+
+// writeBanned 账号被封禁了就返回原因和解封时间，不是封禁的错误返回 false
+func (u *UserHandler) writeBanned(ctx *gin.Context, err error) bool {
+	var bannedErr *service.UserBannedError
+	if !errors.As(err, &bannedErr) {
+		return false
+	}
+	vo := bannedVO{Reason: bannedErr.Reason}
+	if !bannedErr.Until.IsZero() {
+		vo.Until = bannedErr.Until.Format(time.RFC3339)
+	}
+	ctx.JSON(http.StatusOK, Result{
+		Code: 4,
+		Msg:  "账号已被封禁",
+		Data: vo,
+	})
+	return true
+}
+
+// writeEmailNotVerified 邮箱还没验证的话告诉前端可以重发验证邮件，不是这个错误返回 false
+func (u *UserHandler) writeEmailNotVerified(ctx *gin.Context, err error) bool {
+	if err != service.ErrEmailNotVerified {
+		return false
+	}
+	ctx.JSON(http.StatusOK, Result{
+		Code: 4,
+		Msg:  "邮箱还没有验证",
+		Data: map[string]string{"resend": "/users/email/verification/resend"},
+	})
+	return true
+}
+
+// ResendVerificationEmail 请求体：JSON 或者表单，只要邮箱。
+// 邮箱没注册过也是返回发送成功
+func (u *UserHandler) ResendVerificationEmail(ctx *gin.Context) {
+	type Req struct {
+		Email string `json:"email" form:"email"`
+	}
+	var req Req
+	if err := bindJSONOrForm(ctx, &req); err != nil {
+		return
+	}
+	if req.Email == "" {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  "请输入邮箱",
+		})
+		return
+	}
+	err := u.svc.ResendVerificationEmail(ctx, req.Email)
+	switch err {
+	case nil:
+		ctx.JSON(http.StatusOK, Result{
+			Msg: "验证邮件已发送",
+		})
+	case service.ErrEmailAlreadyVerified:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  "邮箱已经验证过了",
+		})
+	case service.ErrEmailVerifyTooFrequent:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  "发送太频繁，请稍后再试",
+		})
+	default:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 5,
+			Msg:  "系统错误",
+		})
+	}
+}
+
+// VerifyEmail 验证邮件里面的链接，token 在查询参数里面
+func (u *UserHandler) VerifyEmail(ctx *gin.Context) {
+	err := u.svc.VerifyEmail(ctx, ctx.Query("token"))
+	switch err {
+	case nil:
+		ctx.JSON(http.StatusOK, Result{
+			Msg: "邮箱验证成功",
+		})
+	case service.ErrEmailVerifyTokenInvalid:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  "验证链接无效或者已经过期",
+		})
+	default:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 5,
+			Msg:  "系统错误",
+		})
+	}
+}
+
+// upgradeAnonymousSession 登录成功之后把匿名会话升级到这个用户上，返回要放进 JWT 的匿名 ID。
+// 失败了不影响登录，只是关联不上登录之前的行为
+func (u *UserHandler) upgradeAnonymousSession(ctx *gin.Context, uid int64) string {
+	anonID, ok := AnonymousIDFromCookie(ctx)
+	if !ok {
+		return ""
+	}
+	// 升级完 cookie 就没用了，后面从 JWT 里面拿
+	ClearAnonymousSessionCookie(ctx)
+	err := u.svc.UpgradeAnonymousSession(ctx, anonID, uid)
+	switch err {
+	case nil:
+		return anonID
+	case service.ErrAnonymousSessionInvalid, service.ErrAnonymousSessionBound:
+		return ""
+	default:
+		log.Println("升级匿名会话失败", err)
+		return ""
+	}
+}
+
+// AnonymousSession 还没登录的时候拿一个匿名 ID，已经有了就继续用
+func (u *UserHandler) AnonymousSession(ctx *gin.Context) {
+	if id, ok := AnonymousIDFromCookie(ctx); ok {
+		ctx.JSON(http.StatusOK, Result{
+			Data: id,
+		})
+		return
+	}
+	id, err := u.svc.CreateAnonymousSession(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 5,
+			Msg:  "系统错误",
+		})
+		return
+	}
+	SetAnonymousSessionCookie(ctx, id, service.AnonymousSessionTTL)
+	ctx.JSON(http.StatusOK, Result{
+		Data: id,
+	})
+}
+
+const bizLogin = "login"
+
+// PrepareSMS 页面加载的时候先拿一个一次性的表单 token，发验证码的时候要带上
+func (u *UserHandler) PrepareSMS(ctx *gin.Context) {
+	token, err := u.formTokenSvc.Issue(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 5,
+			Msg:  "系统错误",
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, Result{
+		Data: token,
+	})
+}
+
+// setCodeRateLimitHeaders 一个冷却期内只能发一条，告诉客户端还要等多久，
+// 它就不用一直点了。拿不到就不设置，不影响这一次的结果，返回的 ok 是 false。
+// sendErr 是 Send 返回的，里面带了要等多久的话就不用再查一次
+func (u *UserHandler) setCodeRateLimitHeaders(ctx *gin.Context, biz, phone string, sendErr error) (time.Duration, bool) {
+	var retryAfter time.Duration
+	var tooMany *service.CodeSendTooManyError
+	if errors.As(sendErr, &tooMany) {
+		retryAfter = tooMany.RetryAfter
+	} else {
+		var err error
+		retryAfter, err = u.codeSvc.Cooldown(ctx, biz, phone)
+		if err != nil {
+			return 0, false
+		}
+	}
+	quota := ratelimitx.Quota{Limit: 1, RetryAfter: retryAfter}
+	if retryAfter <= 0 {
+		quota.Remaining = 1
+	}
+	ratelimit.SetHeaders(ctx, quota)
+	return retryAfter, true
+}
+
+// smsQuotaExceededResult 当天的配额用完了，告诉前端什么时候可以再发
+func smsQuotaExceededResult(err error) Result {
+	res := Result{
+		Code: 4,
+		Msg:  "今日验证码发送次数已达上限，请明天再试",
+	}
+	var quotaErr *service.SMSQuotaExceededError
+	if errors.As(err, &quotaErr) {
+		res.Data = map[string]string{"reset_at": quotaErr.ResetAt.Format(time.RFC3339)}
+	}
+	return res
+}
+
+// codeSentVO 前端拿来倒计时的
+type codeSentVO struct {
+	// NextAllowedAt 什么时候可以重发，RFC3339
+	NextAllowedAt string `json:"next_allowed_at"`
+	// RetryAfter 还要等几秒
+	RetryAfter int64 `json:"retry_after"`
+}
+
+func newCodeSentVO(retryAfter time.Duration, ok bool) any {
+	if !ok {
+		return nil
+	}
+	// 跟 Retry-After 一样向上取整
+	seconds := int64((retryAfter + time.Second - 1) / time.Second)
+	return codeSentVO{
+		NextAllowedAt: time.Now().Add(time.Duration(seconds) * time.Second).Format(time.RFC3339),
+		RetryAfter:    seconds,
+	}
+}
+
+// SendLoginSMSCode 请求体：JSON 或者表单。
+// 重发也走这里，一样要先拿 formToken
+func (u *UserHandler) SendLoginSMSCode(ctx *gin.Context) {
+	type Req struct {
+		Phone     string `json:"phone" form:"phone"`
+		FormToken string `json:"formToken" form:"formToken"`
+	}
+	var req Req
+	if err := bindJSONOrForm(ctx, &req); err != nil {
+		return
+	}
+	// 是不是一个合法的手机号码
+	// 考虑正则表达式
+	if req.Phone == "" {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  "输入有误",
+		})
+		return
+	}
+	err := u.formTokenSvc.Consume(ctx, req.FormToken)
+	switch err {
+	case nil:
+	case service.ErrFormTokenInvalid, service.ErrFormTokenTooFast, service.ErrFormTokenUsed:
+		// 不告诉对方具体是哪个原因
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  "请刷新页面后重试",
+		})
+		return
+	default:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 5,
+			Msg:  "系统错误",
+		})
+		return
+	}
+	err = u.codeSvc.Send(ctx, bizLogin, req.Phone, ctx.ClientIP())
+	var next any
+	if err == nil || errors.Is(err, service.ErrCodeSendTooMany) {
+		next = newCodeSentVO(u.setCodeRateLimitHeaders(ctx, bizLogin, req.Phone, err))
+	}
+	switch {
+	case err == nil:
+		ctx.JSON(http.StatusOK, Result{
+			Msg:  "发送成功",
+			Data: next,
+		})
+	case errors.Is(err, service.ErrCodeSendTooMany):
+		ctx.JSON(http.StatusOK, Result{
+			Msg:  "发送太频繁，请稍后再试",
+			Data: next,
+		})
+	case errors.Is(err, service.ErrSMSQuotaExceeded):
+		ctx.JSON(http.StatusOK, smsQuotaExceededResult(err))
+	case err == service.ErrCodeServiceBusy:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 5,
+			Msg:  "系统繁忙，请稍后再试",
+		})
+	default:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 5,
+			Msg:  "系统错误",
+		})
+	}
+}
+
+// LoginSMS 请求体：JSON 或者表单
+func (u *UserHandler) LoginSMS(ctx *gin.Context) {
+	type Req struct {
+		Phone string `json:"phone" form:"phone"`
+		Code  string `json:"code" form:"code"`
+		// 账号停用了，确认要恢复
+		Reactivate bool `json:"reactivate" form:"reactivate"`
+		// 记住我，JWT 过期了也能用 cookie 换一个新的
+		RememberMe bool `json:"remember_me" form:"remember_me"`
+	}
+	var req Req
+	if err := bindJSONOrForm(ctx, &req); err != nil {
+		return
+	}
+	res, err := u.codeSvc.VerifyWithResult(ctx, bizLogin, req.Phone, req.Code)
+	if err == service.ErrCodeServiceBusy {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 5,
+			Msg:  "系统繁忙，请稍后再试",
+		})
+		return
+	}
+	// 次数用完了，这一次输错刚好用完的也一样，都不再告诉还剩几次
+	if err == service.ErrCodeVerifyTooManyTimes || (err == nil && !res.OK && res.RemainingAttempts == 0) {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  "验证次数太多，请重新获取验证码",
+		})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 5,
+			Msg:  "系统错误",
+		})
+		return
+	}
+	if !res.OK {
+		result := Result{
+			Code: 4,
+			Msg:  "验证码有误",
+		}
+		// 没发过、过期了的不知道还剩几次，就不带
+		if res.RemainingAttempts > 0 {
+			result.Msg = fmt.Sprintf("验证码有误，还可以再试 %d 次", res.RemainingAttempts)
+			result.Data = gin.H{"remaining_attempts": res.RemainingAttempts}
+		}
+		ctx.JSON(http.StatusOK, result)
+		return
+	}
+	// 我这个手机号，会不会是一个新用户呢？
+	user, err := u.svc.FindOrCreate(ctx, req.Phone)
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 5,
+			Msg:  "系统错误",
+		})
+		return
+	}
+	if u.writeBanned(ctx, u.svc.CheckBan(user)) {
+		u.recordLogin(ctx, user.Id, false)
+		return
+	}
+	if u.svc.CheckDeactivated(user) != nil {
+		if !req.Reactivate {
+			u.recordLogin(ctx, user.Id, false)
+			ctx.JSON(http.StatusOK, Result{
+				Code: 4,
+				Msg:  "账号已停用，确认之后可以恢复",
+			})
+			return
+		}
+		user, err = u.svc.ReactivateDeactivatedById(ctx, user.Id)
+		if err != nil {
+			ctx.JSON(http.StatusOK, Result{
+				Code: 5,
+				Msg:  "系统错误",
+			})
+			return
+		}
+	}
+	if _, _, err = u.setJWTToken(ctx, user.Id, u.upgradeAnonymousSession(ctx, user.Id)); err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 5,
+			Msg:  "系统错误",
+		})
+		return
+	}
+	if req.RememberMe {
+		u.rememberMe(ctx, user.Id)
+	}
+	u.recordLogin(ctx, user.Id, true)
+	ctx.JSON(http.StatusOK, Result{
+		Msg: "验证码校验通过",
+	})
 }
 
+// Login 请求体：JSON 或者表单
 func (u *UserHandler) Login(ctx *gin.Context) {
 	type LoginReq struct {
-		Email    string `json:"email"`
-		Password string `json:"password"`
+		Email    string `json:"email" form:"email"`
+		Password string `json:"password" form:"password"`
+		// 账号在注销冷静期内或者停用了，确认要恢复账号
+		Reactivate bool `json:"reactivate" form:"reactivate"`
 	}
 
 	var req LoginReq
-	if err := ctx.Bind(&req); err != nil {
+	if err := bindJSONOrForm(ctx, &req); err != nil {
 		return
 	}
 	user, err := u.svc.Login(ctx, req.Email, req.Password)
+	if err == service.ErrAccountPendingDeletion && req.Reactivate {
+		user, err = u.svc.Reactivate(ctx, req.Email, req.Password)
+	}
+	if err == service.ErrAccountDeactivated && req.Reactivate {
+		user, err = u.svc.ReactivateDeactivated(ctx, req.Email, req.Password)
+	}
+	// 找到了用户但是没登录上的，也记一笔
+	if err != nil && user.Id != 0 {
+		u.recordLogin(ctx, user.Id, false)
+	}
+	if err == service.ErrAccountDeactivated {
+		ctx.String(http.StatusOK, "账号已停用，确认之后可以恢复")
+		return
+	}
+	if err == service.ErrAccountPendingDeletion {
+		ctx.String(http.StatusOK, "账号已注销，30 天内重新登录可以恢复账号")
+		return
+	}
+	if err == service.ErrUserDuplicateEmail {
+		ctx.String(http.StatusOK, "邮箱已经被别人注册，无法恢复账号")
+		return
+	}
+	if err == service.ErrUserDuplicatePhone {
+		ctx.String(http.StatusOK, "手机号已经被别人注册，无法恢复账号")
+		return
+	}
+	if u.writeBanned(ctx, err) {
+		return
+	}
+	if u.writeEmailNotVerified(ctx, err) {
+		return
+	}
 	if err == service.ErrInvalidUserOrPassword {
 		ctx.String(http.StatusOK, "用户名或密码不对")
 		return
@@ -180,59 +951,331 @@ func (u *UserHandler) Login(ctx *gin.Context) {
 	// 我可以随便设置值了
 	// 你要放在 session 里面的值
 	sess.Set("userId", user.Id)
-	sess.Options(sessions.Options{
-		Secure:   true,
-		HttpOnly: true,
-		// 一分钟过期
-		MaxAge: 60,
-	})
+	if anonID := u.upgradeAnonymousSession(ctx, user.Id); anonID != "" {
+		sess.Set("anonId", anonID)
+	}
+	// 一分钟过期
+	sess.Options(u.sessionOptions(60))
 	sess.Save()
+	u.recordLogin(ctx, user.Id, true)
 	ctx.String(http.StatusOK, "登录成功")
 	return
 }
 
-func (u *UserHandler) Logout(ctx *gin.Context) {
-	sess := sessions.Default(ctx)
-	// 我可以随便设置值了
-	// 你要放在 session 里面的值
-	sess.Options(sessions.Options{
-		//Secure: true,
-		//HttpOnly: true,
-		MaxAge: -1,
-	})
-	sess.Save()
-	ctx.String(http.StatusOK, "退出登录成功")
+// recordLogin 记录失败了也不影响这次登录
+func (u *UserHandler) recordLogin(ctx *gin.Context, uid int64, success bool) {
+	err := u.svc.RecordLogin(ctx, uid, ctx.ClientIP(), ctx.Request.UserAgent(), success)
+	if err != nil {
+		log.Println("记录登录历史失败", uid, err)
+	}
 }
 
-func (u *UserHandler) Edit(ctx *gin.Context) {
-	sess := sessions.Default(ctx)
-	id := sess.Get("userId")
-	userId, _ := id.(int64)
-	type Request struct {
-		Nickname string `json:"nickname"`
-		Birthday string `json:"birthday"`
-		Brief    string `json:"brief"`
-	}
+type loginEventVO struct {
+	Id        int64  `json:"id"`
+	IP        string `json:"ip"`
+	Country   string `json:"country,omitempty"`
+	UserAgent string `json:"user_agent"`
+	Status    string `json:"status"`
+	// RFC3339，按用户自己的时区
+	CreatedAt string `json:"created_at"`
+}
 
-	var req Request
-	if err := ctx.Bind(&req); err != nil {
+// LoginHistory 最近的登录记录，?limit= 默认 20，最多 100
+func (u *UserHandler) LoginHistory(ctx *gin.Context) {
+	uid, ok := ContextUserID(ctx)
+	if !ok {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  "请先登录",
+		})
 		return
 	}
-
-	ok, err := u.birthdayExp.MatchString(req.Birthday)
+	limit := 0
+	if s := ctx.Query("limit"); s != "" {
+		var err error
+		limit, err = strconv.Atoi(s)
+		if err != nil || limit <= 0 {
+			ctx.JSON(http.StatusOK, Result{
+				Code: 4,
+				Msg:  "limit 要是正整数",
+			})
+			return
+		}
+	}
+	events, err := u.svc.GetLoginHistory(ctx, uid, limit)
 	if err != nil {
-		// 记录日志
-		ctx.String(http.StatusOK, "系统错误")
+		ctx.JSON(http.StatusOK, Result{
+			Code: 5,
+			Msg:  "系统错误",
+		})
 		return
 	}
-	if !ok {
-		ctx.String(http.StatusOK, "生日格式不正确（格式:1992-01-01）")
-		return
+	// 时区是用户自己设置的，查不到就用默认的
+	loc := time.Local
+	if user, err := u.currentProfile(ctx, uid); err == nil {
+		loc = user.TimeLocation()
 	}
-
-	if utf8.RuneCountInString(req.Nickname) > 255 {
-		ctx.String(http.StatusOK, "昵称不超过255个字符")
-		return
+	vos := make([]loginEventVO, 0, len(events))
+	for _, e := range events {
+		vo := loginEventVO{
+			Id:        e.ID,
+			IP:        e.IP,
+			UserAgent: e.UserAgent,
+			Status:    e.Status.String(),
+			CreatedAt: formatCtime(e.CreatedAt, loc, time.RFC3339),
+		}
+		if u.geo != nil {
+			vo.Country = u.geo.Lookup(e.IP)
+		}
+		vos = append(vos, vo)
+	}
+	ctx.JSON(http.StatusOK, Result{
+		Data: vos,
+	})
+}
+
+// sessionOptions 设置和清掉 cookie 的时候属性要一样，不然浏览器可能不认
+func (u *UserHandler) sessionOptions(maxAge int) sessions.Options {
+	return sessions.Options{
+		Secure:   u.sessionCfg.Secure,
+		HttpOnly: true,
+		SameSite: u.sessionCfg.SameSite,
+		MaxAge:   maxAge,
+	}
+}
+
+// LogoutJWT 默认只退出这台设备：删掉 JWT 的 nonce、里面的会话和记住我的 cookie，
+// session 登录的也一起清掉。JWT 过期了、签名不对都当没带，不告诉调用的人 token 有没有效。
+// 带上 ?logoutAll=true 的话，这个用户所有设备的会话和记住我都删掉
+func (u *UserHandler) LogoutJWT(ctx *gin.Context) {
+	logoutAll, _ := strconv.ParseBool(ctx.Query("logoutAll"))
+	// 这个接口不过登录校验，JWT 过期了也要能退出，自己解析一下
+	claims, hasClaims := logoutClaims(ctx, logoutAll)
+	if hasClaims && claims.Nonce != "" && u.nonceSvc != nil {
+		if err := u.nonceSvc.Revoke(ctx, claims.Uid, claims.Nonce); err != nil {
+			ctx.JSON(http.StatusOK, Result{
+				Code: 5,
+				Msg:  "系统错误",
+			})
+			return
+		}
+	}
+	if hasClaims && claims.Ssid != "" && u.sessionSvc != nil {
+		if err := u.sessionSvc.Logout(ctx, claims.Uid, claims.Ssid); err != nil {
+			ctx.JSON(http.StatusOK, Result{
+				Code: 5,
+				Msg:  "系统错误",
+			})
+			return
+		}
+	}
+	if series, _, ok := RememberMeFromCookie(ctx); ok {
+		if err := u.rememberMeSvc.Revoke(ctx, series); err != nil {
+			ctx.JSON(http.StatusOK, Result{
+				Code: 5,
+				Msg:  "系统错误",
+			})
+			return
+		}
+	}
+	// 没有用 session 的中间件的时候 sessions.Default 会 panic
+	if _, ok := ctx.Get(sessions.DefaultKey); ok {
+		sess := sessions.Default(ctx)
+		sess.Options(u.sessionOptions(-1))
+		sess.Save()
+	}
+	ClearRememberMeCookie(ctx)
+	if !logoutAll {
+		ctx.JSON(http.StatusOK, Result{
+			Msg: "退出登录成功",
+		})
+		return
+	}
+	if !hasClaims {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  "登录已经过期，只退出了这台设备",
+		})
+		return
+	}
+	// 代办登录的 token 只能结束代办自己，不能把用户所有设备都踢下线
+	if claims.ImpersonatedBy != 0 {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  ErrForbiddenWhileImpersonating.Error(),
+		})
+		return
+	}
+	if u.sessionSvc != nil {
+		if err := u.sessionSvc.LogoutAll(ctx, claims.Uid); err != nil {
+			ctx.JSON(http.StatusOK, Result{
+				Code: 5,
+				Msg:  "系统错误",
+			})
+			return
+		}
+	}
+	if err := u.rememberMeSvc.RevokeAll(ctx, claims.Uid); err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 5,
+			Msg:  "系统错误",
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, Result{
+		Msg: "已退出所有设备",
+	})
+}
+
+// logoutClaims 解析退出登录带过来的 JWT。只退出这台设备的话签名对就行，过期了也认；
+// 退出所有设备影响比较大，要求 token 还没过期
+func logoutClaims(ctx *gin.Context, validate bool) (*UserClaims, bool) {
+	segs := strings.Split(ctx.GetHeader("Authorization"), " ")
+	if len(segs) != 2 {
+		return nil, false
+	}
+	var opts []jwt.ParserOption
+	if !validate {
+		opts = append(opts, jwt.WithoutClaimsValidation())
+	}
+	claims := &UserClaims{}
+	token, err := jwt.ParseWithClaims(segs[1], claims, func(token *jwt.Token) (interface{}, error) {
+		return []byte("95osj3fUD7fo0mlYdDbncXz4VD2igvf0"), nil
+	}, opts...)
+	if err != nil || !token.Valid || claims.Uid == 0 {
+		return nil, false
+	}
+	return claims, true
+}
+
+// Edit 请求体：JSON
+func (u *UserHandler) Edit(ctx *gin.Context) {
+	userId, ok := ContextUserID(ctx)
+	if !ok {
+		ctx.String(http.StatusOK, "系统错误")
+		return
+	}
+	type Request struct {
+		Nickname string `json:"nickname"`
+		Birthday string `json:"birthday"`
+		// 公开的资料里面生日带不带年份，不传就是不带
+		ShowBirthYear bool   `json:"show_birth_year"`
+		Brief         string `json:"brief"`
+		// 可以不填，不填就是 unknown
+		Gender string `json:"gender"`
+		// 省和地级市的代码，从 /dictionaries/regions 里面选
+		Region string `json:"region"`
+		City   string `json:"city"`
+		// 海外用户不选省市，直接填
+		Location string `json:"location"`
+		// 个人主页，http 或者 https 开头，可以不填
+		Website string `json:"website"`
+		// IANA 时区名，比如 Asia/Shanghai，可以不填
+		Timezone string `json:"timezone"`
+		// 比如 zh-CN，可以不填
+		Locale string `json:"locale"`
+	}
+
+	var req Request
+	if err := bindJSON(ctx, &req); err != nil {
+		return
+	}
+
+	gender := domain.GenderUnknown
+	if req.Gender != "" {
+		var ok bool
+		gender, ok = domain.ParseGender(req.Gender)
+		if !ok {
+			ctx.JSON(http.StatusOK, Result{
+				Code: 4,
+				Msg:  "输入有误",
+				Data: map[string]string{
+					"gender": "只能是 unknown、male、female、other 中的一个",
+				},
+			})
+			return
+		}
+	}
+
+	if field, msg, ok := u.validateRegion(req.Region, req.City, req.Location); !ok {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  "输入有误",
+			Data: map[string]string{
+				field: msg,
+			},
+		})
+		return
+	}
+
+	if req.Timezone != "" {
+		var ok bool
+		req.Timezone, ok = domain.ParseTimezone(req.Timezone)
+		if !ok {
+			ctx.JSON(http.StatusOK, Result{
+				Code: 4,
+				Msg:  "输入有误",
+				Data: map[string]string{
+					"timezone": "不是合法的 IANA 时区名，比如 Asia/Shanghai",
+				},
+			})
+			return
+		}
+	}
+	req.Website = strings.TrimSpace(req.Website)
+	if req.Website != "" {
+		var ok bool
+		req.Website, ok = domain.ParseWebsite(req.Website)
+		if !ok {
+			ctx.JSON(http.StatusOK, Result{
+				Code: 4,
+				Msg:  "输入有误",
+				Data: map[string]string{
+					"website": "个人主页要是 http 或者 https 开头的链接，不超过 255 个字符",
+				},
+			})
+			return
+		}
+	}
+	if req.Locale != "" {
+		var ok bool
+		req.Locale, ok = domain.ParseLocale(req.Locale)
+		if !ok {
+			ctx.JSON(http.StatusOK, Result{
+				Code: 4,
+				Msg:  "输入有误",
+				Data: map[string]string{
+					"locale": "不是合法的语言标签，比如 zh-CN",
+				},
+			})
+			return
+		}
+	}
+
+	ok, err := u.birthdayExp.MatchString(req.Birthday)
+	if err != nil {
+		// 记录日志
+		ctx.String(http.StatusOK, "系统错误")
+		return
+	}
+	if !ok {
+		ctx.String(http.StatusOK, "生日格式不正确（格式:1992-01-01）")
+		return
+	}
+	if msg, ok := u.validateBirthday(req.Birthday); !ok {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  "输入有误",
+			Data: map[string]string{
+				"birthday": msg,
+			},
+		})
+		return
+	}
+
+	if utf8.RuneCountInString(req.Nickname) > 255 {
+		ctx.String(http.StatusOK, "昵称不超过255个字符")
+		return
 	}
 
 	if utf8.RuneCountInString(req.Brief) > 255 {
@@ -241,62 +1284,820 @@ func (u *UserHandler) Edit(ctx *gin.Context) {
 	}
 
 	// 调用一下 svc 的方法
-	err = u.svc.Edit(ctx, domain.User{
-		Id:       userId,
-		Nickname: req.Nickname,
-		Birthday: req.Birthday,
-		Brief:    req.Brief,
+	input := domain.User{
+		Id:            userId,
+		Nickname:      req.Nickname,
+		Birthday:      req.Birthday,
+		ShowBirthYear: req.ShowBirthYear,
+		Brief:         req.Brief,
+		Gender:        gender,
+		Region:        req.Region,
+		City:          req.City,
+		Location:      req.Location,
+		Website:       req.Website,
+		Timezone:      req.Timezone,
+		Locale:        req.Locale,
+	}
+	user, err := u.svc.Edit(ctx, input)
+	if err == service.ErrSensitiveContent {
+		ctx.JSON(http.StatusOK, Result{
+			Code: codeSensitiveContent,
+			Msg:  "昵称或简介包含敏感词",
+		})
+		return
+	}
+	var cooldownErr *service.NicknameCooldownError
+	if errors.As(err, &cooldownErr) {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  "昵称 30 天内只能修改一次",
+			Data: map[string]any{
+				// 按用户自己的时区算是哪一天
+				"next_available_at": cooldownErr.NextAvailableAt.In(input.TimeLocation()).Format(time.DateOnly),
+			},
+		})
+		return
+	}
+	if err != nil {
+		ctx.String(http.StatusOK, "系统错误")
+		return
+	}
+
+	// 昵称和简介存之前会被清洗，返回真正存下来的，前端用这个来展示
+	ctx.JSON(http.StatusOK, Result{
+		Msg: "修改成功",
+		Data: map[string]string{
+			"nickname": user.Nickname,
+			"brief":    user.Brief,
+			"location": user.Location,
+			"website":  user.Website,
+		},
 	})
+}
 
-	ctx.String(http.StatusOK, "修改成功")
+// validateBirthday 正则只管格式，这里看是不是真的有这一天，年龄够不够。
+// 按服务器的日期算，差一天的时区问题不管
+func (u *UserHandler) validateBirthday(birthday string) (string, bool) {
+	b, err := time.Parse("2006-01-02", birthday)
+	if err != nil {
+		return "生日格式不正确（格式:1992-01-01）", false
+	}
+	age := domain.Age(b, time.Now())
+	if age < 0 {
+		return "生日不能是未来日期", false
+	}
+	if age < u.minimumAge {
+		return fmt.Sprintf("需要年满 %d 周岁", u.minimumAge), false
+	}
+	return "", true
 }
 
-func (u *UserHandler) ProfileJWT(ctx *gin.Context) {
-	c, _ := ctx.Get("claims")
-	// 你可以断定，必然有 claims
-	//if !ok {
-	//	// 你可以考虑监控住这里
-	//	ctx.String(http.StatusOK, "系统错误")
-	//	return
-	//}
-	// ok 代表是不是 *UserClaims
-	claims, ok := c.(*UserClaims)
+// validateRegion 校验不通过的时候返回出错的字段和原因
+func (u *UserHandler) validateRegion(province, city, location string) (string, string, bool) {
+	if location != "" {
+		if !u.regions.AllowOverseas() {
+			return "location", "暂时不支持填写海外地区", false
+		}
+		if province != "" || city != "" {
+			return "location", "海外地区和省市只能填一个", false
+		}
+		return "", "", true
+	}
+	switch u.regions.Validate(province, city) {
+	case region.ErrInvalidProvince:
+		return "region", "省份不存在", false
+	case region.ErrInvalidCity:
+		return "city", "城市不存在或者不属于这个省份", false
+	}
+	return "", "", true
+}
+
+// Profile session 和 JWT 登录都能用
+func (u *UserHandler) Profile(ctx *gin.Context) {
+	userId, ok := ContextUserID(ctx)
 	if !ok {
-		// 你可以考虑监控住这里
 		ctx.String(http.StatusOK, "系统错误")
 		return
 	}
-	println(claims.Uid)
-	ctx.String(http.StatusOK, "你的 profile")
-	// 这边就是你补充 profile 的其它代码
+	u.profile(ctx, userId)
 }
 
-func (u *UserHandler) Profile(ctx *gin.Context) {
+// Me 当前登录用户的公开资料，session 和 JWT 登录都能用
+func (u *UserHandler) Me(ctx *gin.Context) {
+	uid, ok := ContextUserID(ctx)
+	if !ok {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  "请先登录",
+		})
+		return
+	}
+	user, err := u.currentProfile(ctx, uid)
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 5,
+			Msg:  "系统错误",
+		})
+		return
+	}
+	if notModified(ctx, profileETag(user)) {
+		return
+	}
+	ctx.JSON(http.StatusOK, Result{
+		Data: u.publicProfileVO(user),
+	})
+}
 
-	sess := sessions.Default(ctx)
-	id := sess.Get("userId")
-	userId, _ := id.(int64)
+// WhoAmI 前端用来判断登录了没有，不查数据库。没登录也是 200，看 authenticated 就行，
+// 所以登录校验的中间件要把它放进 OptionalPaths
+func (u *UserHandler) WhoAmI(ctx *gin.Context) {
+	type whoAmIVO struct {
+		Uid           int64 `json:"uid"`
+		Authenticated bool  `json:"authenticated"`
+	}
+	uid, ok := ContextUserID(ctx)
+	ctx.JSON(http.StatusOK, Result{
+		Data: whoAmIVO{Uid: uid, Authenticated: ok},
+	})
+}
 
-	user, err := u.svc.GetProfile(ctx, userId)
+// currentProfile 前面的 LoadUserMiddlewareBuilder 已经查过了就直接用，没有再查一次
+func (u *UserHandler) currentProfile(ctx *gin.Context, uid int64) (domain.User, error) {
+	if user, ok := ContextUser(ctx); ok && user.Id == uid {
+		return user, nil
+	}
+	return u.svc.GetProfile(ctx, uid)
+}
+
+// profile session 和 JWT 两种登录方式返回的都是一样的。
+// App 会频繁地轮询这个接口，资料没变的话返回 304。
+// 每次都查也不怕，资料在 repository 里面有 Redis 和进程内两层缓存
+func (u *UserHandler) profile(ctx *gin.Context, userId int64) {
+	user, err := u.currentProfile(ctx, userId)
+	if err == service.ErrUserNotFound {
+		// 登录态还在，但是账号已经注销了
+		ctx.String(http.StatusNotFound, "用户不存在")
+		return
+	}
 	if err != nil {
 		ctx.String(http.StatusOK, "系统错误")
 		return
 	}
+	// 是自己的资料，只能让浏览器缓存，不能让 CDN 这种共享的缓存存下来
+	ctx.Header("Cache-Control", "private, max-age=10")
+	if notModified(ctx, profileETag(user)) {
+		return
+	}
 	ctx.JSONP(http.StatusOK, struct {
-		Nickname string
-		Birthday string
-		Brief    string
+		Nickname      string
+		Birthday      string
+		ShowBirthYear bool `json:"show_birth_year"`
+		Brief         string
+		Gender        string   `json:"gender"`
+		Region        regionVO `json:"region"`
+		City          regionVO `json:"city"`
+		Location      string   `json:"location"`
+		Website       string   `json:"website"`
+		Timezone      string   `json:"timezone"`
+		Locale        string   `json:"locale"`
+		VerifiedBadge bool     `json:"verified_badge"`
+		// 注册时间，RFC3339，按用户自己的时区
+		MemberSince string `json:"member_since"`
+		// 引导用户补全资料用
+		Completeness completenessVO `json:"completeness"`
 	}{
-		Nickname: user.Nickname,
-		Birthday: user.Birthday,
-		Brief:    user.Brief,
+		Nickname:      user.Nickname,
+		Birthday:      user.Birthday,
+		ShowBirthYear: user.ShowBirthYear,
+		Brief:         user.Brief,
+		Gender:        user.Gender.String(),
+		Region:        u.regionVO(user.Region),
+		City:          u.regionVO(user.City),
+		Location:      user.Location,
+		Website:       user.Website,
+		Timezone:      user.Timezone,
+		Locale:        user.Locale,
+		VerifiedBadge: user.VerifiedAt != nil,
+		MemberSince:   formatCtime(user.Ctime, user.TimeLocation(), time.RFC3339),
+		Completeness:  newCompletenessVO(u.svc.ProfileCompleteness(user)),
+	})
+}
+
+// formatCtime 连 utime 都没有的老数据不知道是什么时候注册的，返回空字符串
+func formatCtime(ctime time.Time, loc *time.Location, layout string) string {
+	if ctime.IsZero() {
+		return ""
+	}
+	return ctime.In(loc).Format(layout)
+}
+
+type completenessVO struct {
+	Percent int                `json:"percent"`
+	Missing []missingProfileVO `json:"missing"`
+}
+
+type missingProfileVO struct {
+	Item   string `json:"item"`
+	Weight int    `json:"weight"`
+}
+
+func newCompletenessVO(c domain.ProfileCompleteness) completenessVO {
+	missing := make([]missingProfileVO, 0, len(c.Missing))
+	for _, m := range c.Missing {
+		missing = append(missing, missingProfileVO{
+			Item:   string(m.Item),
+			Weight: m.Weight,
+		})
+	}
+	return completenessVO{
+		Percent: c.Percent,
+		Missing: missing,
+	}
+}
+
+// publicProfileVO 别人能看到的资料，生日、时区这些不返回
+type publicProfileVO struct {
+	Id       int64  `json:"id"`
+	Nickname string `json:"nickname"`
+	// 用户没有选择公开年份的话只有月和日，比如 03-15
+	Birthday      string   `json:"birthday"`
+	Brief         string   `json:"brief"`
+	Gender        string   `json:"gender"`
+	Region        regionVO `json:"region"`
+	City          regionVO `json:"city"`
+	Location      string   `json:"location"`
+	Website       string   `json:"website"`
+	VerifiedBadge bool     `json:"verified_badge"`
+	// 只精确到月，比如说 2023-05，不暴露具体的注册时间
+	Joined string `json:"joined"`
+}
+
+// GetProfiles 批量查用户的公开资料，比如说渲染评论列表的时候一次把作者都查出来。
+// 请求体：JSON {"ids": [1, 2, 3]}，返回 id 到资料的 map，不存在的用户不在里面
+func (u *UserHandler) GetProfiles(ctx *gin.Context) {
+	type Req struct {
+		Ids []int64 `json:"ids"`
+	}
+	var req Req
+	if err := bindJSON(ctx, &req); err != nil {
+		return
+	}
+	users, err := u.svc.GetProfiles(ctx, req.Ids)
+	switch err {
+	case nil:
+	case service.ErrProfileBatchTooLarge:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  fmt.Sprintf("一次最多查询 %d 个用户", service.MaxProfileBatch),
+		})
+		return
+	default:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 5,
+			Msg:  "系统错误",
+		})
+		return
+	}
+	res := make(map[int64]publicProfileVO, len(users))
+	for id, user := range users {
+		res[id] = u.publicProfileVO(user)
+	}
+	ctx.JSON(http.StatusOK, Result{
+		Data: res,
+	})
+}
+
+func (u *UserHandler) publicProfileVO(user domain.User) publicProfileVO {
+	return publicProfileVO{
+		Id:            user.Id,
+		Nickname:      user.Nickname,
+		Birthday:      user.PublicBirthday(),
+		Brief:         user.Brief,
+		Gender:        user.Gender.String(),
+		Region:        u.regionVO(user.Region),
+		City:          u.regionVO(user.City),
+		Location:      user.Location,
+		Website:       user.Website,
+		VerifiedBadge: user.VerifiedAt != nil,
+		// 看的人在哪个时区不知道，统一用 UTC
+		Joined: formatCtime(user.Ctime, time.UTC, "2006-01"),
+	}
+}
+
+func (u *UserHandler) regionVO(code string) regionVO {
+	return regionVO{
+		Code: code,
+		Name: u.regions.Name(code),
+	}
+}
+
+// VerifyIdentity 实名认证
+// 请求体：JSON
+func (u *UserHandler) VerifyIdentity(ctx *gin.Context) {
+	type Req struct {
+		RealName     string `json:"real_name"`
+		IdCardNumber string `json:"id_card_number"`
+	}
+	var req Req
+	if err := bindJSON(ctx, &req); err != nil {
+		return
+	}
+	uid, ok := ClaimsUserId(ctx)
+	if !ok {
+		ctx.String(http.StatusOK, "系统错误")
+		return
+	}
+	if req.RealName == "" {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  "请输入真实姓名",
+		})
+		return
+	}
+	ok, err := u.idCardExp.MatchString(req.IdCardNumber)
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 5,
+			Msg:  "系统错误",
+		})
+		return
+	}
+	if !ok {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  "身份证号码格式不对",
+		})
+		return
+	}
+	err = u.svc.VerifyIdentity(ctx, uid, req.RealName, req.IdCardNumber)
+	switch err {
+	case nil:
+		ctx.JSON(http.StatusOK, Result{
+			Msg: "认证成功",
+		})
+	case service.ErrAlreadyVerified:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  "已经实名认证过了",
+		})
+	case service.ErrIdentityMismatch:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  "姓名和身份证号不匹配",
+		})
+	default:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 5,
+			Msg:  "系统错误",
+		})
+	}
+}
+
+const bizDeleteAccount = "delete_account"
+
+// SendDeleteAccountCode 注销账号之前，先给绑定的手机号发一个验证码。
+// 没有绑定手机号的（邮箱注册的）不用发，注销的时候输密码
+func (u *UserHandler) SendDeleteAccountCode(ctx *gin.Context) {
+	uid, ok := ClaimsUserId(ctx)
+	if !ok {
+		ctx.String(http.StatusOK, "系统错误")
+		return
+	}
+	user, err := u.svc.FindById(ctx, uid)
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 5,
+			Msg:  "系统错误",
+		})
+		return
+	}
+	if user.Phone == "" {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  "没有绑定手机号，请用密码验证",
+		})
+		return
+	}
+	err = u.codeSvc.Send(ctx, bizDeleteAccount, user.Phone, ctx.ClientIP())
+	if err == nil || errors.Is(err, service.ErrCodeSendTooMany) {
+		u.setCodeRateLimitHeaders(ctx, bizDeleteAccount, user.Phone, err)
+	}
+	switch {
+	case err == nil:
+		ctx.JSON(http.StatusOK, Result{
+			Msg: "发送成功",
+		})
+	case errors.Is(err, service.ErrCodeSendTooMany):
+		ctx.JSON(http.StatusOK, Result{
+			Msg: "发送太频繁，请稍后再试",
+		})
+	case errors.Is(err, service.ErrSMSQuotaExceeded):
+		ctx.JSON(http.StatusOK, smsQuotaExceededResult(err))
+	case err == service.ErrCodeServiceBusy:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 5,
+			Msg:  "系统繁忙，请稍后再试",
+		})
+	default:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 5,
+			Msg:  "系统错误",
+		})
+	}
+}
+
+const bizChangePhone = "change_phone"
+
+// InitiatePhoneChange 换绑手机号，给新手机号发验证码，原来绑了手机号的也给原来的发一个，
+// 两个都验证通过了才会换。请求体：JSON
+func (u *UserHandler) InitiatePhoneChange(ctx *gin.Context) {
+	type Req struct {
+		Phone string `json:"phone"`
+	}
+	var req Req
+	if err := bindJSON(ctx, &req); err != nil {
+		return
+	}
+	if req.Phone == "" {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  "输入有误",
+		})
+		return
+	}
+	uid, ok := ContextUserID(ctx)
+	if !ok {
+		ctx.String(http.StatusOK, "系统错误")
+		return
+	}
+	user, err := u.svc.CheckPhoneChange(ctx, uid, req.Phone)
+	if u.writePhoneChangeErr(ctx, err) {
+		return
+	}
+	err = u.codeSvc.Send(ctx, bizChangePhone, req.Phone, ctx.ClientIP())
+	if err == nil && user.Phone != "" {
+		err = u.codeSvc.Send(ctx, bizChangePhone, user.Phone, ctx.ClientIP())
+	}
+	if err == nil || errors.Is(err, service.ErrCodeSendTooMany) {
+		u.setCodeRateLimitHeaders(ctx, bizChangePhone, req.Phone, err)
+	}
+	switch {
+	case err == nil:
+		ctx.JSON(http.StatusOK, Result{
+			Msg: "发送成功",
+			// 前端据此决定要不要让用户填原来手机号的验证码
+			Data: map[string]bool{"verify_old": user.Phone != ""},
+		})
+	case errors.Is(err, service.ErrCodeSendTooMany):
+		ctx.JSON(http.StatusOK, Result{
+			Msg: "发送太频繁，请稍后再试",
+		})
+	case errors.Is(err, service.ErrSMSQuotaExceeded):
+		ctx.JSON(http.StatusOK, smsQuotaExceededResult(err))
+	case err == service.ErrCodeServiceBusy:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 5,
+			Msg:  "系统繁忙，请稍后再试",
+		})
+	default:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 5,
+			Msg:  "系统错误",
+		})
+	}
+}
+
+// ConfirmPhoneChange 先验原来手机号的验证码，再验新手机号的，都对了才换。
+// 请求体：JSON
+func (u *UserHandler) ConfirmPhoneChange(ctx *gin.Context) {
+	type Req struct {
+		Phone string `json:"phone"`
+		Code  string `json:"code"`
+		// 原来绑了手机号的，原来的手机号收到的验证码
+		OldCode string `json:"oldCode"`
+	}
+	var req Req
+	if err := bindJSON(ctx, &req); err != nil {
+		return
+	}
+	if req.Phone == "" || req.Code == "" {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  "输入有误",
+		})
+		return
+	}
+	uid, ok := ContextUserID(ctx)
+	if !ok {
+		ctx.String(http.StatusOK, "系统错误")
+		return
+	}
+	user, err := u.svc.CheckPhoneChange(ctx, uid, req.Phone)
+	if u.writePhoneChangeErr(ctx, err) {
+		return
+	}
+	if user.Phone != "" {
+		ok, err = u.codeSvc.Verify(ctx, bizChangePhone, user.Phone, req.OldCode)
+		if u.writePhoneCodeErr(ctx, ok, err, "原手机号的验证码有误") {
+			return
+		}
+	}
+	ok, err = u.codeSvc.Verify(ctx, bizChangePhone, req.Phone, req.Code)
+	if u.writePhoneCodeErr(ctx, ok, err, "新手机号的验证码有误") {
+		return
+	}
+	err = u.svc.ChangePhone(ctx, uid, req.Phone)
+	if u.writePhoneChangeErr(ctx, err) {
+		return
+	}
+	ctx.JSON(http.StatusOK, Result{
+		Msg: "手机号已更换",
 	})
 }
 
+// writePhoneChangeErr 没有出错返回 false
+func (u *UserHandler) writePhoneChangeErr(ctx *gin.Context, err error) bool {
+	switch err {
+	case nil:
+		return false
+	case service.ErrPhoneUnchanged:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  "新手机号跟原来的一样",
+		})
+	case service.ErrUserDuplicatePhone:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  "手机号已被其他账号使用",
+		})
+	default:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 5,
+			Msg:  "系统错误",
+		})
+	}
+	return true
+}
+
+// writePhoneCodeErr 验证码对了返回 false
+func (u *UserHandler) writePhoneCodeErr(ctx *gin.Context, ok bool, err error, msg string) bool {
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 5,
+			Msg:  "系统错误",
+		})
+		return true
+	}
+	if !ok {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  msg,
+		})
+		return true
+	}
+	return false
+}
+
+// DeleteAccount 注销账号。账号会先进入 30 天的冷静期，冷静期过了才彻底删除。
+// 绑了手机号的用 SendDeleteAccountCode 发的验证码，也可以输密码；邮箱注册的没有手机号，输密码。
+// 请求体：JSON
+func (u *UserHandler) DeleteAccount(ctx *gin.Context) {
+	type Req struct {
+		Code     string `json:"code"`
+		Password string `json:"password"`
+	}
+	var req Req
+	if err := bindJSON(ctx, &req); err != nil {
+		return
+	}
+	uid, ok := ClaimsUserId(ctx)
+	if !ok {
+		ctx.String(http.StatusOK, "系统错误")
+		return
+	}
+	user, err := u.svc.FindById(ctx, uid)
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 5,
+			Msg:  "系统错误",
+		})
+		return
+	}
+	if !u.verifyDeleteAccount(ctx, user, req.Password, req.Code) {
+		return
+	}
+	err = u.svc.DeleteAccount(ctx, uid)
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 5,
+			Msg:  "系统错误",
+		})
+		return
+	}
+	// 当前这个登录态也清掉，其它的登录态靠中间件拦住
+	sess := sessions.Default(ctx)
+	sess.Options(u.sessionOptions(-1))
+	sess.Save()
+	ctx.JSON(http.StatusOK, Result{
+		Msg: "注销成功",
+	})
+}
+
+// verifyDeleteAccount 带了密码的验证密码，不然用注销的短信验证码。不通过的话响应已经写好了
+func (u *UserHandler) verifyDeleteAccount(ctx *gin.Context, user domain.User, password, code string) bool {
+	if password != "" {
+		return u.verifyPassword(ctx, user.Id, password)
+	}
+	if user.Phone == "" || code == "" {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  "请输入密码或者验证码",
+		})
+		return false
+	}
+	ok, err := u.codeSvc.Verify(ctx, bizDeleteAccount, user.Phone, code)
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 5,
+			Msg:  "系统错误",
+		})
+		return false
+	}
+	if !ok {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  "验证码有误",
+		})
+		return false
+	}
+	return true
+}
+
+// Deactivate 停用账号，跟注销不一样，邮箱和手机号还是自己的，下次登录确认一下就恢复了。
+// 要再验证一次身份：有密码的输密码，手机号注册的没有密码，用登录的短信验证码。
+// 请求体：JSON
+func (u *UserHandler) Deactivate(ctx *gin.Context) {
+	type Req struct {
+		Password string `json:"password"`
+		Code     string `json:"code"`
+	}
+	var req Req
+	if err := bindJSON(ctx, &req); err != nil {
+		return
+	}
+	uid, ok := ContextUserID(ctx)
+	if !ok {
+		ctx.String(http.StatusOK, "系统错误")
+		return
+	}
+	if !u.reauthenticate(ctx, uid, req.Password, req.Code) {
+		return
+	}
+	if err := u.svc.Deactivate(ctx, uid); err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 5,
+			Msg:  "系统错误",
+		})
+		return
+	}
+	// 登录态靠中间件拦住，记住我的 cookie 顺便清掉
+	ClearRememberMeCookie(ctx)
+	ctx.JSON(http.StatusOK, Result{
+		Msg: "账号已停用",
+	})
+}
+
+// ExportData 下载自己的个人数据：资料、绑定的邮箱手机号、登录记录，一个 JSON 文件。
+// 跟 Deactivate 一样要再验证一次身份，防止别人拿着没退出登录的电脑把数据拖走。
+// 只查登录态里面的 uid，请求里面没有能指定用户的参数。请求体：JSON
+func (u *UserHandler) ExportData(ctx *gin.Context) {
+	type Req struct {
+		Password string `json:"password"`
+		Code     string `json:"code"`
+	}
+	var req Req
+	if err := bindJSON(ctx, &req); err != nil {
+		return
+	}
+	uid, ok := ContextUserID(ctx)
+	if !ok {
+		ctx.String(http.StatusOK, "系统错误")
+		return
+	}
+	if !u.reauthenticate(ctx, uid, req.Password, req.Code) {
+		return
+	}
+	r, _, err := u.svc.ExportUserData(ctx, uid)
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 5,
+			Msg:  "系统错误",
+		})
+		return
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 5,
+			Msg:  "系统错误",
+		})
+		return
+	}
+	// 跟 UserExportHandler.Result 一样，浏览器会自己解压，下载下来是 JSON 文件
+	ctx.Header("Cache-Control", "no-store")
+	ctx.Header("Content-Encoding", "gzip")
+	ctx.Header("Content-Disposition",
+		fmt.Sprintf(`attachment; filename="webook-export-%d.json"`, uid))
+	ctx.Data(http.StatusOK, "application/json", data)
+}
+
+// Reauthenticate 给别的 handler 用，见 Reauthenticator
+func (u *UserHandler) Reauthenticate(ctx *gin.Context, uid int64, password, code string) bool {
+	return u.reauthenticate(ctx, uid, password, code)
+}
+
+// reauthenticate 敏感操作之前再确认一次是本人：有密码的输密码，
+// 手机号注册的没有密码，用登录的短信验证码。不通过的话响应已经写好了
+func (u *UserHandler) reauthenticate(ctx *gin.Context, uid int64, password, code string) bool {
+	if password == "" {
+		return u.verifyLoginCode(ctx, uid, code)
+	}
+	return u.verifyPassword(ctx, uid, password)
+}
+
+// verifyPassword 不通过的话响应已经写好了
+func (u *UserHandler) verifyPassword(ctx *gin.Context, uid int64, password string) bool {
+	err := u.svc.VerifyPassword(ctx, uid, password)
+	switch err {
+	case nil:
+		return true
+	case service.ErrInvalidUserOrPassword:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  "密码不对",
+		})
+	default:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 5,
+			Msg:  "系统错误",
+		})
+	}
+	return false
+}
+
+// verifyLoginCode 用发给绑定的手机号的登录验证码确认是本人，不通过的话响应已经写好了
+func (u *UserHandler) verifyLoginCode(ctx *gin.Context, uid int64, code string) bool {
+	user, err := u.svc.FindById(ctx, uid)
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 5,
+			Msg:  "系统错误",
+		})
+		return false
+	}
+	if user.Phone == "" || code == "" {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  "请输入密码或者验证码",
+		})
+		return false
+	}
+	ok, err := u.codeSvc.Verify(ctx, bizLogin, user.Phone, code)
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 5,
+			Msg:  "系统错误",
+		})
+		return false
+	}
+	if !ok {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  "验证码有误",
+		})
+		return false
+	}
+	return true
+}
+
 type UserClaims struct {
 	jwt.RegisteredClaims
 	// 声明你自己的要放进去 token 里面的数据
 	Uid int64
 	// 自己随便加
 	UserAgent string
+	// 登录之前的匿名 ID，没有就是空的
+	AnonID string
+	// 这台设备的会话，老的 token 没有，没有就不检查
+	Ssid string
+	// 每个 token 一个，16 字节随机数的 hex。退出登录之后删掉，老的 token 没有，没有就不检查
+	Nonce string
+	// 代办登录的时候是被代办的用户，跟 Uid 一样；ImpersonatedBy 是管理员的 ID。
+	// 代办登录的 token 只能看不能改，也不会续约
+	SubjectUserID  int64 `json:",omitempty"`
+	ImpersonatedBy int64 `json:",omitempty"`
+	// 加字段的时候加一，老的 token 是 0。见 MigrateClaims
+	ClaimsVersion int
+	Role          string
+	TokenType     string
 }
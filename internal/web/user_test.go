@@ -1,9 +1,27 @@
 package web
 
 import (
+	"encoding/json"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-contrib/sessions/memstore"
+	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
 	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
+	"webook/internal/domain"
+	"webook/internal/repository"
+	"webook/internal/repository/dao"
+	"webook/internal/service"
+	svcmocks "webook/internal/service/mocks"
+	"webook/pkg/region"
 )
 
 func TestEncrypt(t *testing.T) {
@@ -24,3 +42,372 @@ func testTypeAssert(c any) {
 	claims := c.(*UserClaims)
 	println(claims.Uid)
 }
+
+func TestUserHandler_emailDomainAllowed(t *testing.T) {
+	testCases := []struct {
+		name    string
+		domains []string
+		email   string
+
+		wantOk bool
+	}{
+		{
+			name:   "没有配置就不限制",
+			email:  "123@qq.com",
+			wantOk: true,
+		},
+		{
+			name:    "域名匹配",
+			domains: []string{"mycompany.com"},
+			email:   "tom@mycompany.com",
+			wantOk:  true,
+		},
+		{
+			name:    "域名不匹配",
+			domains: []string{"mycompany.com"},
+			email:   "tom@qq.com",
+		},
+		{
+			name:    "子域名不放行",
+			domains: []string{"mycompany.com"},
+			email:   "tom@dev.mycompany.com",
+		},
+		{
+			name:    "后缀一样但不是同一个域名",
+			domains: []string{"mycompany.com"},
+			email:   "tom@notmycompany.com",
+		},
+		{
+			name:    "不区分大小写",
+			domains: []string{"MyCompany.com"},
+			email:   "Tom@MYCOMPANY.COM",
+			wantOk:  true,
+		},
+		{
+			name:    "配置里面带了 @",
+			domains: []string{"@mycompany.com"},
+			email:   "tom@mycompany.com",
+			wantOk:  true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			u := NewUserHandler(nil, nil, nil, nil, nil, WithAllowedEmailDomains(tc.domains...))
+			assert.Equal(t, tc.wantOk, u.emailDomainAllowed(tc.email))
+		})
+	}
+}
+
+func TestUserHandler_SignUpEmailDomain(t *testing.T) {
+	server := gin.New()
+	// 被拒绝的时候还没有调用 svc
+	NewUserHandler(nil, nil, nil, nil, nil,
+		WithAllowedEmailDomains("mycompany.com")).RegisterRoutes(server)
+	body := `{"email":"tom@qq.com","password":"hello#world123","confirmPassword":"hello#world123"}`
+	req, err := http.NewRequest(http.MethodPost, "/users/signup", strings.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+	server.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, "注册仅限公司邮箱", resp.Body.String())
+}
+
+func TestUserHandler_EditWebsite(t *testing.T) {
+	testCases := []struct {
+		name    string
+		website string
+	}{
+		{name: "没有协议", website: "example.com"},
+		{name: "javascript", website: "javascript:alert(1)"},
+		{name: "带用户名", website: "https://good.com@evil.com"},
+		{name: "太长", website: "https://example.com/" + strings.Repeat("a", 240)},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := gin.New()
+			server.Use(func(ctx *gin.Context) {
+				ctx.Set("claims", &UserClaims{Uid: 3})
+			})
+			// 校验不通过的时候还没有调用 svc
+			NewUserHandler(nil, nil, nil, nil, region.NewDictionary()).RegisterRoutes(server)
+			body, err := json.Marshal(map[string]string{"website": tc.website})
+			require.NoError(t, err)
+			req, err := http.NewRequest(http.MethodPost, "/users/edit", strings.NewReader(string(body)))
+			require.NoError(t, err)
+			req.Header.Set("Content-Type", "application/json")
+			resp := httptest.NewRecorder()
+			server.ServeHTTP(resp, req)
+			require.Equal(t, http.StatusOK, resp.Code)
+
+			var res struct {
+				Code int               `json:"code"`
+				Data map[string]string `json:"data"`
+			}
+			require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &res))
+			assert.Equal(t, 4, res.Code)
+			assert.NotEmpty(t, res.Data["website"])
+		})
+	}
+}
+
+func TestUserHandler_validateBirthday(t *testing.T) {
+	today := time.Now()
+	testCases := []struct {
+		name     string
+		birthday string
+		// 配置成 0
+		noMinimumAge bool
+
+		wantMsg string
+		wantOk  bool
+	}{
+		{
+			name:     "今天刚好满 13 岁",
+			birthday: today.AddDate(-13, 0, 0).Format("2006-01-02"),
+			wantOk:   true,
+		},
+		{
+			name:     "12 岁 364 天",
+			birthday: today.AddDate(-13, 0, 1).Format("2006-01-02"),
+			wantMsg:  "需要年满 13 周岁",
+		},
+		{
+			name:     "未来日期",
+			birthday: today.AddDate(0, 0, 1).Format("2006-01-02"),
+			wantMsg:  "生日不能是未来日期",
+		},
+		{
+			name:         "不限制年龄，今天出生",
+			birthday:     today.Format("2006-01-02"),
+			noMinimumAge: true,
+			wantOk:       true,
+		},
+		{
+			name:     "平年没有 2 月 29 日",
+			birthday: "2001-02-29",
+			wantMsg:  "生日格式不正确（格式:1992-01-01）",
+		},
+		{
+			name:     "闰年的 2 月 29 日",
+			birthday: "2000-02-29",
+			wantOk:   true,
+		},
+		{
+			name:     "后面多了东西",
+			birthday: "1992-01-01abc",
+			wantMsg:  "生日格式不正确（格式:1992-01-01）",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var opts []UserHandlerOption
+			if tc.noMinimumAge {
+				opts = append(opts, WithMinimumAge(0))
+			}
+			u := NewUserHandler(nil, nil, nil, nil, nil, opts...)
+			msg, ok := u.validateBirthday(tc.birthday)
+			assert.Equal(t, tc.wantOk, ok)
+			assert.Equal(t, tc.wantMsg, msg)
+		})
+	}
+}
+
+func TestUserHandler_EditBirthday(t *testing.T) {
+	server := gin.New()
+	server.Use(func(ctx *gin.Context) {
+		ctx.Set("claims", &UserClaims{Uid: 3})
+	})
+	// 校验不通过的时候还没有调用 svc
+	NewUserHandler(nil, nil, nil, nil, region.NewDictionary()).RegisterRoutes(server)
+	birthday := time.Now().AddDate(0, 0, 1).Format("2006-01-02")
+	req, err := http.NewRequest(http.MethodPost, "/users/edit",
+		strings.NewReader(`{"birthday":"`+birthday+`"}`))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+	server.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusOK, resp.Code)
+
+	var res struct {
+		Code int               `json:"code"`
+		Data map[string]string `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &res))
+	assert.Equal(t, 4, res.Code)
+	assert.Equal(t, "生日不能是未来日期", res.Data["birthday"])
+}
+
+func TestFormatCtime(t *testing.T) {
+	shanghai, err := time.LoadLocation("Asia/Shanghai")
+	require.NoError(t, err)
+	// UTC 的 5 月 31 号晚上，上海已经是 6 月 1 号了
+	ctime := time.Date(2023, 5, 31, 20, 0, 0, 0, time.UTC)
+	assert.Equal(t, "2023-06-01T04:00:00+08:00", formatCtime(ctime, shanghai, time.RFC3339))
+	assert.Equal(t, "2023-05", formatCtime(ctime, time.UTC, "2006-01"))
+	// 老数据没有创建时间
+	assert.Equal(t, "", formatCtime(time.Time{}, time.UTC, "2006-01"))
+}
+
+func TestUserHandler_LoginSMSBanned(t *testing.T) {
+	until := time.Now().Add(time.Hour).Truncate(time.Second)
+	testCases := []struct {
+		name string
+		// users 表里面的封禁字段
+		bannedAt    int64
+		bannedUntil int64
+
+		wantCode int
+		wantData map[string]any
+	}{
+		{
+			name:        "临时封禁",
+			bannedAt:    until.Add(-time.Hour * 2).UnixMilli(),
+			bannedUntil: until.UnixMilli(),
+			wantCode:    4,
+			wantData:    map[string]any{"reason": "广告", "until": until.Format(time.RFC3339)},
+		},
+		{
+			name:     "永久封禁",
+			bannedAt: until.Add(-time.Hour * 2).UnixMilli(),
+			wantCode: 4,
+			wantData: map[string]any{"reason": "广告"},
+		},
+		{
+			name:        "到期自动解封",
+			bannedAt:    until.Add(-time.Hour * 2).UnixMilli(),
+			bannedUntil: until.Add(-time.Hour * 2).UnixMilli(),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			codeSvc := svcmocks.NewMockCodeService(ctrl)
+			codeSvc.EXPECT().VerifyWithResult(gomock.Any(), bizLogin, "15212345678", "123456").Return(service.VerifyResult{OK: true}, nil)
+			db := mockFindUserDB(t, "phone", sqlmock.NewRows([]string{"id", "phone", "banned_at", "banned_until", "ban_reason"}).
+				AddRow(3, "15212345678", tc.bannedAt, tc.bannedUntil, "广告"))
+			svc := service.NewUserService(repository.NewUserRepository(dao.NewUserDAO(db), nil),
+				nil, nil, service.SensitiveModeReject, nil, nil)
+			server := gin.New()
+			NewUserHandler(svc, codeSvc, nil, nil, nil).RegisterRoutes(server)
+
+			req, err := http.NewRequest(http.MethodPost, "/users/login_sms",
+				strings.NewReader(`{"phone":"15212345678","code":"123456"}`))
+			require.NoError(t, err)
+			req.Header.Set("Content-Type", "application/json")
+			resp := httptest.NewRecorder()
+			server.ServeHTTP(resp, req)
+			require.Equal(t, http.StatusOK, resp.Code)
+
+			var res struct {
+				Code int            `json:"code"`
+				Data map[string]any `json:"data"`
+			}
+			require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &res))
+			assert.Equal(t, tc.wantCode, res.Code)
+			assert.Equal(t, tc.wantData, res.Data)
+			// 封禁中的不能拿到 token
+			assert.Equal(t, tc.wantCode == 0, resp.Header().Get("x-jwt-token") != "")
+		})
+	}
+}
+
+func TestUserHandler_SessionSameSite(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("hello#world123"), bcrypt.MinCost)
+	require.NoError(t, err)
+	testCases := []struct {
+		name string
+		req  func(t *testing.T) *http.Request
+		// 登录要查数据库
+		db func(t *testing.T) *gorm.DB
+	}{
+		{
+			name: "登录",
+			req: func(t *testing.T) *http.Request {
+				req, err := http.NewRequest(http.MethodPost, "/users/login",
+					strings.NewReader(`{"email":"123@qq.com","password":"hello#world123"}`))
+				require.NoError(t, err)
+				req.Header.Set("Content-Type", "application/json")
+				return req
+			},
+			db: func(t *testing.T) *gorm.DB {
+				return mockFindUserDB(t, "email", sqlmock.NewRows([]string{"id", "email", "password"}).
+					AddRow(3, "123@qq.com", string(hash)))
+			},
+		},
+		{
+			name: "退出登录",
+			req: func(t *testing.T) *http.Request {
+				req, err := http.NewRequest(http.MethodPost, "/users/logout", nil)
+				require.NoError(t, err)
+				return req
+			},
+			db: func(t *testing.T) *gorm.DB {
+				return nil
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			svc := service.NewUserService(repository.NewUserRepository(dao.NewUserDAO(tc.db(t)), nil),
+				nil, nil, service.SensitiveModeReject, nil, nil)
+			server := gin.New()
+			server.Use(sessions.Sessions("ssid", memstore.NewStore([]byte("secret"))))
+			h := NewUserHandler(svc, nil, nil, nil, nil,
+				WithSessionConfig(SessionConfig{Secure: true, SameSite: http.SameSiteStrictMode}))
+			server.POST("/users/login", h.Login)
+			server.POST("/users/logout", h.LogoutJWT)
+
+			resp := httptest.NewRecorder()
+			server.ServeHTTP(resp, tc.req(t))
+			require.Equal(t, http.StatusOK, resp.Code)
+			cookie := resp.Header().Get("Set-Cookie")
+			assert.Contains(t, cookie, "SameSite=Strict")
+			assert.Contains(t, cookie, "Secure")
+			assert.Contains(t, cookie, "HttpOnly")
+		})
+	}
+}
+
+func TestUserHandler_BirthdayPrivacy(t *testing.T) {
+	testCases := []struct {
+		name          string
+		showBirthYear bool
+
+		wantPublic string
+	}{
+		{name: "不公开年份", wantPublic: "03-15"},
+		{name: "公开年份", showBirthYear: true, wantPublic: "1992-03-15"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			svc := service.NewUserService(nil, nil, nil, service.SensitiveModeReject, nil, nil)
+			server := gin.New()
+			server.Use(func(ctx *gin.Context) {
+				ctx.Set("claims", &UserClaims{Uid: 3})
+				SetContextUser(ctx, domain.User{Id: 3, Birthday: "1992-03-15", ShowBirthYear: tc.showBirthYear})
+			})
+			NewUserHandler(svc, nil, nil, nil, region.NewDictionary()).RegisterRoutes(server)
+			get := func(path string) map[string]any {
+				req, err := http.NewRequest(http.MethodGet, path, nil)
+				require.NoError(t, err)
+				resp := httptest.NewRecorder()
+				server.ServeHTTP(resp, req)
+				require.Equal(t, http.StatusOK, resp.Code)
+				var res map[string]any
+				require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &res))
+				return res
+			}
+
+			// 别人看到的
+			public := get("/users/me")["data"].(map[string]any)
+			assert.Equal(t, tc.wantPublic, public["birthday"])
+			// 自己看到的一直是完整的
+			own := get("/users/profile")
+			assert.Equal(t, "1992-03-15", own["Birthday"])
+			assert.Equal(t, tc.showBirthYear, own["show_birth_year"])
+		})
+	}
+}
@@ -0,0 +1,24 @@
+package web
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"webook/pkg/buildinfo"
+)
+
+// VersionHandler 运维确认线上部署的是哪个版本，不需要登录
+type VersionHandler struct{}
+
+func NewVersionHandler() *VersionHandler {
+	return &VersionHandler{}
+}
+
+func (h *VersionHandler) RegisterRoutes(server *gin.Engine) {
+	server.GET("/version", h.Version)
+}
+
+func (h *VersionHandler) Version(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, Result{
+		Data: buildinfo.Get(),
+	})
+}
@@ -0,0 +1,23 @@
+package web
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestVersionHandler_Version(t *testing.T) {
+	server := gin.New()
+	NewVersionHandler().RegisterRoutes(server)
+	req, err := http.NewRequest(http.MethodGet, "/version", nil)
+	require.NoError(t, err)
+	resp := httptest.NewRecorder()
+	server.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusOK, resp.Code)
+	// 测试没有注入 ldflags，都是默认值
+	assert.JSONEq(t, `{"code":0,"msg":"","data":{"version":"dev","commit":"unknown","build_time":"unknown"}}`,
+		resp.Body.String())
+}
@@ -0,0 +1,27 @@
+package ioc
+
+import (
+	"github.com/redis/go-redis/v9"
+	"time"
+	"webook/config"
+	"webook/internal/repository"
+	"webook/internal/service"
+	"webook/internal/service/email/logger"
+	"webook/pkg/ratelimit"
+)
+
+// InitEmailVerificationService 还没有接邮件服务商，邮件只打日志
+func InitEmailVerificationService(repo *repository.UserRepository,
+	cmd redis.Cmdable) service.EmailVerificationService {
+	key := config.Config.Email.TokenKey
+	if key == "" {
+		panic("没有配置验证邮件的 TokenKey")
+	}
+	interval := config.Config.Email.ResendInterval
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	limiter := ratelimit.NewRedisSlidingWindowLimiter(cmd, interval, 1)
+	return service.NewEmailVerificationService(repo, logger.NewService(), limiter,
+		[]byte(key), config.Config.Email.VerifyLinkPrefix, time.Hour*24)
+}
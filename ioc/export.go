@@ -0,0 +1,15 @@
+package ioc
+
+import (
+	"github.com/redis/go-redis/v9"
+	"time"
+	"webook/internal/repository/cache"
+	"webook/internal/service"
+	"webook/pkg/ratelimit"
+)
+
+func InitUserExportService(userSvc *service.UserService, cmd redis.Cmdable) service.UserExportService {
+	// 每个用户一天只能导出一次
+	limiter := ratelimit.NewRedisSlidingWindowLimiter(cmd, time.Hour*24, 1)
+	return service.NewUserExportService(userSvc, cache.NewUserExportCache(cmd), limiter)
+}
@@ -0,0 +1,19 @@
+package ioc
+
+import (
+	"github.com/redis/go-redis/v9"
+	"time"
+	"webook/config"
+	"webook/internal/repository/cache"
+	"webook/internal/service"
+)
+
+func InitFormTokenService(cmd redis.Cmdable) service.FormTokenService {
+	key := config.Config.FormToken.Key
+	if key == "" {
+		panic("没有配置表单 token 的 HMAC 密钥")
+	}
+	// 人从打开页面到点发送，怎么也要一秒钟
+	return service.NewFormTokenService(cache.NewFormTokenCache(cmd),
+		[]byte(key), time.Second, time.Minute*10)
+}
@@ -0,0 +1,11 @@
+package ioc
+
+import (
+	"webook/internal/service/idverify"
+	"webook/internal/service/idverify/checksum"
+)
+
+func InitIDVerifier() idverify.IDVerifier {
+	// 还没有接入真实的实名认证服务，先只校验身份证号码
+	return checksum.NewVerifier()
+}
@@ -0,0 +1,11 @@
+package ioc
+
+import (
+	"webook/internal/job"
+	"webook/internal/service"
+)
+
+// InitPurgeDeletedUserJob 这里只创建，在 main 里面启动，退出的时候 cancel 掉
+func InitPurgeDeletedUserJob(svc *service.UserService) *job.PurgeDeletedUserJob {
+	return job.NewPurgeDeletedUserJob(svc)
+}
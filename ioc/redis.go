@@ -3,11 +3,26 @@ package ioc
 import (
 	"github.com/redis/go-redis/v9"
 	"webook/config"
+	"webook/internal/repository/cache"
+	"webook/pkg/redisx"
 )
 
 func InitRedis() redis.Cmdable {
-	redisClient := redis.NewClient(&redis.Options{
-		Addr: config.Config.Redis.Addr,
-	})
+	cfg := config.Config.Redis
+	redisClient := redis.NewClient(cache.RedisPoolConfig{
+		MaxOpenConns:    cfg.Pool.MaxOpenConns,
+		MinIdleConns:    cfg.Pool.MinIdleConns,
+		ConnMaxLifetime: cfg.Pool.ConnMaxLifetime,
+		ConnMaxIdleTime: cfg.Pool.ConnMaxIdleTime,
+		PoolTimeout:     cfg.Pool.PoolTimeout,
+	}.Options(cfg.Addr))
+	// Redis 不稳定的时候快速失败，不要让每个请求都等到超时
+	if cfg.Breaker.ConsecutiveFailures > 0 {
+		redisClient.AddHook(redisx.NewBreakerHook("redis", redisx.BreakerConfig{
+			ConsecutiveFailures: cfg.Breaker.ConsecutiveFailures,
+			OpenTimeout:         cfg.Breaker.OpenTimeout,
+			HalfOpenRequests:    cfg.Breaker.HalfOpenRequests,
+		}))
+	}
 	return redisClient
 }
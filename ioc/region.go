@@ -0,0 +1,14 @@
+package ioc
+
+import (
+	"webook/config"
+	"webook/pkg/region"
+)
+
+func InitRegionDictionary() *region.Dictionary {
+	var opts []region.Option
+	if config.Config.Region.AllowOverseas {
+		opts = append(opts, region.WithOverseas())
+	}
+	return region.NewDictionary(opts...)
+}
@@ -1,11 +1,75 @@
 package ioc
 
 import (
+	"context"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel"
+	"time"
+	"webook/config"
+	"webook/internal/repository"
+	"webook/internal/repository/cache"
+	"webook/internal/service"
 	"webook/internal/service/sms"
 	"webook/internal/service/sms/memory"
+	smsotel "webook/internal/service/sms/opentelemetry"
+	smsratelimit "webook/internal/service/sms/ratelimit"
+	"webook/pkg/ratelimit"
 )
 
-func InitSMSService() sms.Service {
+func InitSMSService(cmd redis.Cmdable) sms.Service {
 	// 换内存，还是换别的
-	return memory.NewService()
+	svc := memory.NewService()
+	if config.Config.SMS.DryRun {
+		svc = memory.NewDryRunService()
+	}
+	// 短信服务商那边一秒钟最多 100 个请求
+	limiter := ratelimit.NewRedisSlidingWindowLimiter(cmd, time.Second, 100)
+	// 放在最外面，被限流了在链路上也能看到
+	return smsotel.NewTracedSMSService(smsratelimit.NewRatelimitSMSService(svc, limiter), otel.GetTracerProvider())
+}
+
+// InitRedisCodeCache 验证码放在 Redis 里面，多个实例才能共享。
+// 返回具体类型，管理后台要用它来改配置
+func InitRedisCodeCache(cmd redis.Cmdable) *cache.RedisCodeCache {
+	var opts []cache.RedisCodeCacheOption
+	if ttl := config.Config.SMS.CodeTTL; ttl > 0 {
+		opts = append(opts, cache.WithTTL(ttl))
+	}
+	if interval := config.Config.SMS.CodeResendInterval; interval > 0 {
+		opts = append(opts, cache.WithResendInterval(interval))
+	}
+	return cache.NewCodeCacheGoBestPractice(cmd, opts...)
+}
+
+// InitCodeCache 没有配置 TracerProvider 的时候 otel 默认是 noop 的。
+// 打开了 CodeCacheFallback 的话 Redis 连不上就用本地的，
+// 本地的用的是启动时候的配置，管理后台改了也不会跟着变。
+// 指标注册在 prometheus 默认的 registry 上，/metrics 能看到
+func InitCodeCache(c *cache.RedisCodeCache, cmd redis.Cmdable) cache.CodeCache {
+	metrics, err := cache.NewCodeCacheMetrics(prometheus.DefaultRegisterer)
+	if err != nil {
+		panic(err)
+	}
+	primary := cache.NewTracedCodeCache(cache.NewRedisCodeCacheWithMetrics(c, metrics), otel.GetTracerProvider())
+	if !config.Config.SMS.CodeCacheFallback {
+		return primary
+	}
+	opts := c.Options()
+	local := cache.NewLocalCodeCacheWithContext(context.Background(), cache.WithTTL(opts.TTL),
+		cache.WithCooldown(opts.Cooldown), cache.WithMaxAttempts(opts.MaxVerifyAttempts))
+	return cache.NewFailoverCodeCache(context.Background(), primary,
+		cache.NewLocalCodeCacheWithMetrics(local, metrics), func(ctx context.Context) error {
+			return cmd.Ping(ctx).Err()
+		})
+}
+
+func InitCodeService(repo repository.CodeRepository, smsSvc sms.Service, cmd redis.Cmdable) service.CodeService {
+	return service.NewCodeService(repo, smsSvc, cache.NewSMSQuotaCache(cmd), service.SMSQuotaConfig{
+		PhoneDaily: config.Config.SMS.PhoneDailyQuota,
+		IPDaily:    config.Config.SMS.IPDailyQuota,
+	}, service.NewCodeGenerator(service.CodeConfig{
+		Length:  config.Config.SMS.CodeLength,
+		Charset: service.CodeCharset(config.Config.SMS.CodeCharset),
+	}))
 }
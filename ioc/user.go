@@ -0,0 +1,168 @@
+package ioc
+
+import (
+	"context"
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/crypto/bcrypt"
+	"log"
+	"net/http"
+	"time"
+	"webook/config"
+	"webook/internal/domain"
+	"webook/internal/repository"
+	"webook/internal/repository/cache"
+	"webook/internal/repository/dao"
+	"webook/internal/service"
+	"webook/internal/service/disposable"
+	"webook/internal/service/idverify"
+	"webook/internal/service/password"
+	"webook/internal/service/sensitive"
+	"webook/internal/service/sensitive/ac"
+	"webook/pkg/idgen"
+	"webook/pkg/ratelimit"
+)
+
+func InitSensitiveFilter() sensitive.Filter {
+	return ac.NewFilter()
+}
+
+// InitUserRepository 配置了 Profile.LocalCacheSize 才在 Redis 前面加一层本地缓存
+func InitUserRepository(d *dao.UserDAO, c cache.UserCache, cmd redis.Cmdable) *repository.UserRepository {
+	opts := initIDGenOptions()
+	cfg := config.Config.Profile
+	if cfg.LocalCacheSize <= 0 || cfg.LocalCacheTTL <= 0 {
+		return repository.NewUserRepository(d, c, opts...)
+	}
+	local := cache.NewLocalProfileCache(cmd, cfg.LocalCacheSize, cfg.LocalCacheTTL)
+	// 单机和 cluster 的客户端都能订阅，测试里面的 mock 不行
+	if sub, ok := cmd.(interface {
+		Subscribe(ctx context.Context, channels ...string) *redis.PubSub
+	}); ok {
+		pubsub := sub.Subscribe(context.Background(), cache.ProfileInvalidationChannel)
+		go local.Listen(context.Background(), pubsub.Channel())
+	} else {
+		log.Println("资料缓存：Redis 客户端不支持订阅，收不到别的实例的删除通知")
+	}
+	opts = append(opts, repository.WithLocalProfileCache(local))
+	return repository.NewUserRepository(d, c, opts...)
+}
+
+// initIDGenOptions 节点编号配错了直接 panic，不然不同实例可能生成重复的 ID
+func initIDGenOptions() []repository.UserRepositoryOption {
+	cfg := config.Config.IDGen
+	if !cfg.Snowflake {
+		return nil
+	}
+	gen, err := idgen.NewSnowflake(cfg.NodeID)
+	if err != nil {
+		panic(err)
+	}
+	return []repository.UserRepositoryOption{repository.WithIDGenerator(gen)}
+}
+
+func InitUserService(repo *repository.UserRepository, idVerifier idverify.IDVerifier,
+	filter sensitive.Filter, anonCache cache.AnonymousSessionCache, cmd redis.Cmdable,
+	emailVerifier service.EmailVerificationService,
+	loginEvents repository.LoginEventRepository) *service.UserService {
+	mode := service.SensitiveModeReject
+	if config.Config.Sensitive.Mask {
+		mode = service.SensitiveModeMask
+	}
+	idCardHashKey := config.Config.Identity.IDCardHashKey
+	if idCardHashKey == "" {
+		panic("没有配置身份证号码的 HMAC 密钥")
+	}
+	opts := append(initSignupOptions(cmd),
+		service.WithEmailVerification(emailVerifier, config.Config.Email.RequireVerified),
+		// 一个邮箱一小时最多重发三次。没有用 CodeCache：它每发一次都重新算过期时间，
+		// 冷却时间还越来越长，做不到一小时三次。key 跟 CodeCache 的 biz 一样是 email_resend
+		service.WithEmailResendLimiter(ratelimit.NewRedisSlidingWindowLimiter(cmd, time.Hour, 3)),
+		service.WithPasswordSchemes(initPasswordSchemes()),
+		service.WithLoginHistory(loginEvents),
+		service.WithIDCardHashKey([]byte(idCardHashKey)))
+	return service.NewUserService(repo, idVerifier, filter, mode, initProfileWeights(), anonCache, opts...)
+}
+
+// initPasswordSchemes 另一种算法也留着，切过去再切回来老的密码也能登录
+func initPasswordSchemes() *password.Schemes {
+	bc := password.NewBcryptHasher(bcrypt.DefaultCost)
+	ar := password.NewArgon2Hasher(password.DefaultArgon2Params())
+	switch config.Config.Password.Scheme {
+	case "", "bcrypt":
+		return password.NewSchemes(bc, ar)
+	case "argon2id":
+		return password.NewSchemes(ar, bc)
+	default:
+		panic("不认识的密码算法 " + config.Config.Password.Scheme)
+	}
+}
+
+// initSignupOptions 注册防刷的几个功能都要在配置里面打开
+func initSignupOptions(cmd redis.Cmdable) []service.UserServiceOption {
+	cfg := config.Config.Signup
+	var opts []service.UserServiceOption
+	switch cfg.DisposableEmail {
+	case "":
+	case "reject", "flag":
+		mode := service.DisposableEmailReject
+		if cfg.DisposableEmail == "flag" {
+			mode = service.DisposableEmailFlag
+		}
+		list := disposable.NewList()
+		if cfg.DisposableListURL != "" {
+			go refreshDisposableList(list, cfg.DisposableListURL, cfg.DisposableListRefresh)
+		}
+		opts = append(opts, service.WithDisposableEmailCheck(list, mode))
+	default:
+		log.Printf("一次性邮箱：不认识的处理方式 %s，不检查", cfg.DisposableEmail)
+	}
+	if cfg.DomainRate > 0 && cfg.DomainRateInterval > 0 {
+		opts = append(opts, service.WithSignupDomainLimiter(
+			ratelimit.NewRedisSlidingWindowLimiter(cmd, cfg.DomainRateInterval, cfg.DomainRate)))
+	}
+	return opts
+}
+
+// refreshDisposableList 拉不下来就先用内置的，不影响启动
+func refreshDisposableList(list *disposable.List, url string, interval time.Duration) {
+	if interval <= 0 {
+		interval = time.Hour * 24
+	}
+	client := &http.Client{Timeout: time.Second * 10}
+	for {
+		if err := list.Fetch(context.Background(), client, url); err != nil {
+			log.Println("拉取一次性邮箱列表失败", err)
+		}
+		time.Sleep(interval)
+	}
+}
+
+// initProfileWeights 配置里面写错的项打个日志跳过，不影响启动
+func initProfileWeights() service.ProfileWeights {
+	cfg := config.Config.Profile.CompletenessWeights
+	if len(cfg) == 0 {
+		return service.DefaultProfileWeights()
+	}
+	known := make(map[domain.ProfileItem]struct{}, len(domain.ProfileItems))
+	for _, item := range domain.ProfileItems {
+		known[item] = struct{}{}
+	}
+	res := make(service.ProfileWeights, len(cfg))
+	for name, weight := range cfg {
+		item := domain.ProfileItem(name)
+		if _, ok := known[item]; !ok {
+			log.Printf("资料完整度：不认识的项 %s，忽略", name)
+			continue
+		}
+		res[item] = weight
+	}
+	return res
+}
+
+func InitRememberMeService(repo repository.RememberMeRepository) service.RememberMeService {
+	ttl := config.Config.RememberMe.TTL
+	if ttl <= 0 {
+		ttl = time.Hour * 24 * 30
+	}
+	return service.NewRememberMeService(repo, ttl)
+}
@@ -1,33 +1,150 @@
 package ioc
 
 import (
+	"context"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"log"
 	"strings"
 	"time"
+	"webook/config"
+	"webook/internal/service"
 	"webook/internal/web"
 	"webook/internal/web/middleware"
+	"webook/pkg/geoip"
+	"webook/pkg/ginx/middlewares/accesslog"
 	"webook/pkg/ginx/middlewares/ratelimit"
+	ratelimitx "webook/pkg/ratelimit"
+	"webook/pkg/region"
 )
 
-func InitWebServer(mdls []gin.HandlerFunc, userHdl *web.UserHandler) *gin.Engine {
+func InitWebServer(mdls []gin.HandlerFunc, userHdl *web.UserHandler,
+	apiKeyHdl *web.APIKeyHandler,
+	backupCodeHdl *web.BackupCodeHandler,
+	exportHdl *web.UserExportHandler,
+	adminHdl *web.AdminHandler,
+	dictHdl *web.DictionaryHandler,
+	versionHdl *web.VersionHandler) *gin.Engine {
 	server := gin.Default()
+	// 不设置的话 gin 谁的 X-Forwarded-For 都信
+	if err := server.SetTrustedProxies(config.Config.Server.TrustedProxies); err != nil {
+		panic(err)
+	}
+	// 业务代码拿 gin.Context 当 context 用，要能拿到 Request.Context 里面的 span，
+	// 客户端断开的时候下游的调用也能跟着取消
+	server.ContextWithFallback = true
 	server.Use(mdls...)
+	// 只给内网的 Prometheus 抓，要带 X-Internal-Token
+	server.GET("/metrics", gin.WrapH(promhttp.Handler()))
 	userHdl.RegisterRoutes(server)
+	apiKeyHdl.RegisterRoutes(server)
+	backupCodeHdl.RegisterRoutes(server)
+	exportHdl.RegisterRoutes(server)
+	adminHdl.RegisterRoutes(server)
+	dictHdl.RegisterRoutes(server)
+	versionHdl.RegisterRoutes(server)
 	return server
 }
 
-func InitMiddlewares(redisClient redis.Cmdable) []gin.HandlerFunc {
+func InitUserHandler(svc *service.UserService, codeSvc service.CodeService,
+	formTokenSvc service.FormTokenService, rememberMeSvc service.RememberMeService,
+	sessionSvc service.SessionService, nonceSvc service.JWTNonceService,
+	regions *region.Dictionary) *web.UserHandler {
+	opts := []web.UserHandlerOption{
+		web.WithSessionConfig(web.SessionConfig{
+			Secure:   config.Config.Session.Secure,
+			SameSite: config.Config.Session.SameSite,
+		}),
+		web.WithMinimumAge(config.Config.Validation.MinimumAgeYears),
+		web.WithSessions(sessionSvc),
+		web.WithJWTNonces(nonceSvc),
+		web.WithSignupMode(initSignupMode()),
+		// 还没接真正的 IP 库，先用桩
+		web.WithGeoLookup(geoip.NewStub()),
+	}
+	if domains := config.Config.Validation.AllowedEmailDomains; len(domains) > 0 {
+		opts = append(opts, web.WithAllowedEmailDomains(domains...))
+	}
+	return web.NewUserHandler(svc, codeSvc, formTokenSvc, rememberMeSvc, regions, opts...)
+}
+
+// initSignupMode 配错了也能启动，按默认的邮箱注册
+func initSignupMode() web.SignupMode {
+	switch mode := config.Config.Signup.Mode; mode {
+	case "", "email":
+		return web.SignupModeEmail
+	case "phone":
+		return web.SignupModePhone
+	case "either":
+		return web.SignupModeEither
+	default:
+		log.Printf("注册方式：不认识的 %s，按邮箱注册", mode)
+		return web.SignupModeEmail
+	}
+}
+
+func InitMiddlewares(redisClient redis.Cmdable, userSvc *service.UserService,
+	apiKeySvc service.APIKeyService, rememberMeSvc service.RememberMeService,
+	sessionSvc service.SessionService, nonceSvc service.JWTNonceService) []gin.HandlerFunc {
 	return []gin.HandlerFunc{
+		// 放在最前面，后面的 span 都挂在这次 HTTP 请求下面
+		otelgin.Middleware("webook"),
+		accessLogHdl(),
 		corsHdl(),
+		middleware.NewAPIKeyMiddlewareBuilder(apiKeySvc).Build(),
+		middleware.NewInternalAuthMiddlewareBuilder(config.Config.Internal.Tokens).
+			Paths("/users/batch", "/metrics").Build(),
 		middleware.NewLoginJWTMiddlewareBuilder().
 			IgnorePaths("/users/signup").
+			IgnorePaths("/users/sms/prepare").
 			IgnorePaths("/users/login_sms/code/send").
+			IgnorePaths("/users/login_sms/code/resend").
 			IgnorePaths("/users/login_sms").
-			IgnorePaths("/users/login").Build(),
-		ratelimit.NewBuilder(redisClient, time.Second, 100).Build(),
+			IgnorePaths("/users/login").
+			IgnorePaths("/users/logout").
+			IgnorePaths("/dictionaries/regions").
+			IgnorePaths("/version").
+			IgnorePaths("/session/anonymous").
+			IgnorePaths("/users/email/verification/resend").
+			IgnorePaths("/users/verify_email/resend").
+			IgnorePaths("/users/email/verify").
+			// 内部服务调的，上面校验过了
+			IgnorePaths("/users/batch").
+			IgnorePaths("/metrics").
+			OptionalPaths("/users/whoami").
+			MinClaimsVersion(config.Config.JWT.MinClaimsVersion).
+			ClockSkew(config.Config.JWT.ClockSkew).
+			RememberMe(rememberMeSvc).
+			Sessions(sessionSvc).
+			Nonces(nonceSvc).
+			RevokeChecker(userSvc).Build(),
+		// handler 自己会处理没登录的情况，这里查到了就顺便放进去
+		middleware.NewLoadUserMiddlewareBuilder(userSvc).Optional().
+			Paths("/users/profile", "/users/me").Build(),
+		middleware.NewAdminMiddlewareBuilder(config.Config.Admin.Uids).Build(),
+		ratelimit.NewBuilder(ratelimitx.NewRedisSlidingWindowLimiter(redisClient, time.Second, 100)).Build(),
+	}
+}
+
+func accessLogHdl() gin.HandlerFunc {
+	cfg := config.Config.AccessLog
+	if !cfg.Enabled {
+		return func(ctx *gin.Context) {}
+	}
+	builder := accesslog.NewBuilder(func(ctx context.Context, al accesslog.AccessLog) {
+		log.Printf("access %s %s %d %s req=%s resp=%s",
+			al.Method, al.Path, al.Status, al.Duration, al.ReqBody, al.RespBody)
+	})
+	if cfg.CaptureBody {
+		builder.AllowBody()
+	}
+	if cfg.MaxBodyLength > 0 {
+		builder.MaxBodyLength(cfg.MaxBodyLength)
 	}
+	return builder.Build()
 }
 
 func corsHdl() gin.HandlerFunc {
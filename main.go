@@ -1,19 +1,33 @@
 package main
 
 import (
+	userv1 "basic-go/webook/api/proto/gen/user/v1"
+	"basic-go/webook/config"
+	grpcx "basic-go/webook/internal/grpc"
 	"basic-go/webook/internal/repository"
+	"basic-go/webook/internal/repository/cache"
 	"basic-go/webook/internal/repository/dao"
 	"basic-go/webook/internal/service"
+	emaillogger "basic-go/webook/internal/service/email/logger"
+	"basic-go/webook/internal/service/idverify/checksum"
+	"basic-go/webook/internal/service/sensitive/ac"
+	"basic-go/webook/internal/service/sms/memory"
 	"basic-go/webook/internal/web"
 	"basic-go/webook/internal/web/middleware"
+	"basic-go/webook/ioc"
 	"basic-go/webook/pkg/ginx/middlewares/ratelimit"
+	ratelimitx "basic-go/webook/pkg/ratelimit"
+	"basic-go/webook/pkg/region"
+	"context"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-contrib/sessions/memstore"
 	"github.com/gin-gonic/gin"
 	"github.com/redis/go-redis/v9"
+	"google.golang.org/grpc"
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
+	"net"
 	"net/http"
 	"strings"
 	"time"
@@ -24,9 +38,26 @@ func main() {
 	db := initDB()
 	server := initWebServer()
 
-	u := initUser(db)
+	redisClient := redis.NewClient(&redis.Options{
+		Addr: "localhost:6379",
+	})
+	svc := initUserService(db, redisClient)
+	u := initUser(db, redisClient, svc)
 	u.RegisterRoutes(server)
 
+	// 后台清理过了冷静期的注销账号，退出的时候跟着停下来
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go ioc.InitPurgeDeletedUserJob(svc).Start(ctx)
+
+	// gRPC 跟 gin 一起启动，给内部的其它服务用
+	grpcServer, lis := initGRPCServer(svc)
+	go func() {
+		if err := grpcServer.Serve(lis); err != nil {
+			panic(err)
+		}
+	}()
+
 	//server := gin.Default()
 	server.GET("/hello", func(ctx *gin.Context) {
 		ctx.String(http.StatusOK, "你好，你来了")
@@ -49,7 +80,7 @@ func initWebServer() *gin.Engine {
 	redisClient := redis.NewClient(&redis.Options{
 		Addr: "localhost:6379",
 	})
-	server.Use(ratelimit.NewBuilder(redisClient, time.Second, 100).Build())
+	server.Use(ratelimit.NewBuilder(ratelimitx.NewRedisSlidingWindowLimiter(redisClient, time.Second, 100)).Build())
 
 	server.Use(cors.New(cors.Config{
 		//AllowOrigins: []string{"*"},
@@ -85,10 +116,19 @@ func initWebServer() *gin.Engine {
 	//myStore := &sqlx_store.Store{}
 
 	server.Use(sessions.Sessions("mysession", store))
+	// 跟 config/dev.go 里面的 Internal.Tokens 一样
+	server.Use(middleware.NewInternalAuthMiddlewareBuilder([]string{"dev-internal-token"}).
+		Paths("/users/batch").Build())
 	// 步骤3
-	server.Use(middleware.NewLoginMiddlewareBuilder().
-		IgnorePaths("/users/signup").
-		IgnorePaths("/users/login").Build())
+	// session 和 JWT 登录都认，带了 Authorization 的先用 JWT
+	sessionLogin := middleware.NewLoginMiddlewareBuilder().OptionalPaths("/users/whoami")
+	jwtLogin := middleware.NewLoginJWTMiddlewareBuilder().OptionalPaths("/users/whoami")
+	for _, path := range []string{"/users/batch", "/users/signup", "/users/login", "/session/anonymous",
+		"/users/email/verification/resend", "/users/verify_email/resend", "/users/email/verify"} {
+		sessionLogin.IgnorePaths(path)
+		jwtLogin.IgnorePaths(path)
+	}
+	server.Use(middleware.NewAuthMiddlewareBuilder(jwtLogin, sessionLogin).Build())
 	//server.Use(middleware.NewLoginJWTMiddlewareBuilder().
 	//	IgnorePaths("/users/signup").
 	//	IgnorePaths("/users/login").Build())
@@ -103,11 +143,44 @@ func initWebServer() *gin.Engine {
 	return server
 }
 
-func initUser(db *gorm.DB) *web.UserHandler {
+func initUserService(db *gorm.DB, redisClient redis.Cmdable) *service.UserService {
 	ud := dao.NewUserDAO(db)
-	repo := repository.NewUserRepository(ud)
-	svc := service.NewUserService(repo)
-	u := web.NewUserHandler(svc)
+	repo := repository.NewUserRepository(ud, cache.NewUserCache(redisClient))
+	emailVerifier := service.NewEmailVerificationService(repo, emaillogger.NewService(),
+		ratelimitx.NewRedisSlidingWindowLimiter(redisClient, time.Minute, 1),
+		[]byte(config.Config.Email.TokenKey), config.Config.Email.VerifyLinkPrefix,
+		time.Hour*24)
+	return service.NewUserService(repo, checksum.NewVerifier(), ac.NewFilter(),
+		service.SensitiveModeReject, service.DefaultProfileWeights(),
+		cache.NewAnonymousSessionCache(redisClient), service.WithEmailVerification(emailVerifier, false),
+		service.WithEmailResendLimiter(ratelimitx.NewRedisSlidingWindowLimiter(redisClient, time.Hour, 3)),
+		service.WithIDCardHashKey([]byte(config.Config.Identity.IDCardHashKey)))
+}
+
+// initGRPCServer 这个端口只在内网开放，没有登录校验。
+// 在这里 Listen，端口被占用的话启动的时候就会失败
+func initGRPCServer(svc *service.UserService) (*grpc.Server, net.Listener) {
+	lis, err := net.Listen("tcp", ":8090")
+	if err != nil {
+		panic(err)
+	}
+	server := grpc.NewServer()
+	userv1.RegisterUserServiceServer(server, grpcx.NewUserServiceServer(svc))
+	return server, lis
+}
+
+func initUser(db *gorm.DB, redisClient redis.Cmdable, svc *service.UserService) *web.UserHandler {
+	codeRepo := repository.NewCodeRepository(cache.NewLocalCodeCache())
+	codeSvc := service.NewCodeService(codeRepo, memory.NewService(),
+		cache.NewSMSQuotaCache(redisClient), service.SMSQuotaConfig{
+			PhoneDaily: 10,
+			IPDaily:    50,
+		}, nil)
+	formTokenSvc := service.NewFormTokenService(cache.NewFormTokenCache(redisClient),
+		[]byte("k6CswdUm75WKcbM68UQUuxVsHSpTCwgA"), time.Second, time.Minute*10)
+	rememberMeSvc := service.NewRememberMeService(
+		repository.NewRememberMeRepository(dao.NewRememberMeDAO(db)), time.Hour*24*30)
+	u := web.NewUserHandler(svc, codeSvc, formTokenSvc, rememberMeSvc, region.NewDictionary())
 	return u
 }
 
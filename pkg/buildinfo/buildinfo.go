@@ -0,0 +1,28 @@
+// Package buildinfo 编译的时候用 ldflags 注入，看线上跑的是哪个版本：
+//
+//	go build -ldflags "-X webook/pkg/buildinfo.Version=v0.0.1 \
+//		-X webook/pkg/buildinfo.Commit=$(git rev-parse HEAD) \
+//		-X webook/pkg/buildinfo.BuildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)" .
+//
+// 没注入的时候是下面的默认值，本地 go run 的就是这样
+package buildinfo
+
+var (
+	Version   = "dev"
+	Commit    = "unknown"
+	BuildTime = "unknown"
+)
+
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildTime string `json:"build_time"`
+}
+
+func Get() Info {
+	return Info{
+		Version:   Version,
+		Commit:    Commit,
+		BuildTime: BuildTime,
+	}
+}
@@ -0,0 +1,27 @@
+package geoip
+
+import "net/netip"
+
+// GeoLookup 根据 IP 查国家或者地区的名字，查不到返回空字符串。
+// 真实环境下对接 IP 库，比如说 MaxMind 或者 ip2region
+type GeoLookup interface {
+	Lookup(ip string) string
+}
+
+// Stub 还没有接 IP 库的时候用，只认得出内网地址
+type Stub struct{}
+
+func NewStub() Stub {
+	return Stub{}
+}
+
+func (Stub) Lookup(ip string) string {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return ""
+	}
+	if addr.IsLoopback() || addr.IsPrivate() {
+		return "局域网"
+	}
+	return "未知"
+}
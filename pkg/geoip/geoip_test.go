@@ -0,0 +1,25 @@
+package geoip
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestStub_Lookup(t *testing.T) {
+	testCases := []struct {
+		ip   string
+		want string
+	}{
+		{ip: "127.0.0.1", want: "局域网"},
+		{ip: "192.168.1.10", want: "局域网"},
+		{ip: "::1", want: "局域网"},
+		{ip: "8.8.8.8", want: "未知"},
+		{ip: "", want: ""},
+		{ip: "abc", want: ""},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.ip, func(t *testing.T) {
+			assert.Equal(t, tc.want, NewStub().Lookup(tc.ip))
+		})
+	}
+}
@@ -0,0 +1,246 @@
+// Package accesslog 打访问日志，调试的时候可以把请求和响应的 body 也带上。
+// 带 body 的时候密码之类的字段一定会被替换掉，不管有没有配置
+package accesslog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const redacted = "***"
+
+// AccessLog 一个请求的访问日志。Path 里面不带查询参数，有些链接的 token 就放在查询参数里面
+type AccessLog struct {
+	Method   string
+	Path     string
+	Status   int
+	Duration time.Duration
+	// 没有开启 AllowBody 的时候是空的
+	ReqBody  string
+	RespBody string
+}
+
+// sensitiveFields 不区分大小写，也不管下划线和中划线。带 password 的字段都会被替换掉
+var sensitiveFields = map[string]struct{}{
+	"code":    {},
+	"token":   {},
+	"secret":  {},
+	"idcard":  {},
+	"apikey":  {},
+	"captcha": {},
+}
+
+type Builder struct {
+	logFunc   func(ctx context.Context, al AccessLog)
+	allowBody bool
+	// body 最多记录这么长，超过的部分截断
+	maxBodyLength int
+	// 超过这么大的 body 不解析，只记录长度
+	maxParseLength int
+	fields         map[string]struct{}
+}
+
+// NewBuilder 日志怎么打由 logFunc 决定，比如说 log.Printf 或者别的日志库
+func NewBuilder(logFunc func(ctx context.Context, al AccessLog)) *Builder {
+	fields := make(map[string]struct{}, len(sensitiveFields))
+	for f := range sensitiveFields {
+		fields[f] = struct{}{}
+	}
+	return &Builder{
+		logFunc:        logFunc,
+		maxBodyLength:  1024,
+		maxParseLength: 64 * 1024,
+		fields:         fields,
+	}
+}
+
+// AllowBody 记录请求和响应的 body，默认不记录。
+// 只认 JSON 和表单，敏感字段替换成 ***，别的格式只记录长度
+func (b *Builder) AllowBody() *Builder {
+	b.allowBody = true
+	return b
+}
+
+// MaxBodyLength 替换掉敏感字段之后，超过 n 个字节的截断
+func (b *Builder) MaxBodyLength(n int) *Builder {
+	b.maxBodyLength = n
+	return b
+}
+
+// RedactFields 除了默认的，这些字段也要替换掉
+func (b *Builder) RedactFields(fields ...string) *Builder {
+	for _, f := range fields {
+		b.fields[normalizeField(f)] = struct{}{}
+	}
+	return b
+}
+
+func (b *Builder) Build() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		start := time.Now()
+		al := AccessLog{
+			Method: ctx.Request.Method,
+			Path:   ctx.Request.URL.Path,
+		}
+		var w *responseWriter
+		if b.allowBody {
+			al.ReqBody = b.requestBody(ctx)
+			w = &responseWriter{ResponseWriter: ctx.Writer, limit: b.maxParseLength}
+			ctx.Writer = w
+		}
+		// 后面 panic 了也要打日志
+		defer func() {
+			al.Status = ctx.Writer.Status()
+			al.Duration = time.Since(start)
+			if w != nil {
+				al.RespBody = b.body(ctx.Writer.Header().Get("Content-Type"), w.body.Bytes(), w.size, true)
+			}
+			b.logFunc(ctx, al)
+		}()
+		ctx.Next()
+	}
+}
+
+// requestBody 读出来之后要放回去，后面的 handler 还要用
+func (b *Builder) requestBody(ctx *gin.Context) string {
+	if ctx.Request.Body == nil {
+		return ""
+	}
+	head, err := io.ReadAll(io.LimitReader(ctx.Request.Body, int64(b.maxParseLength)+1))
+	ctx.Request.Body = readCloser{
+		Reader: io.MultiReader(bytes.NewReader(head), ctx.Request.Body),
+		Closer: ctx.Request.Body,
+	}
+	if err != nil {
+		return ""
+	}
+	size := len(head)
+	if ctx.Request.ContentLength > int64(size) {
+		size = int(ctx.Request.ContentLength)
+	}
+	return b.body(ctx.ContentType(), head, size, false)
+}
+
+// body 解析不了的一律不记录内容，保证密码不会漏出去。
+// 响应里面的纯文本是 handler 自己写的提示，可以记录
+func (b *Builder) body(contentType string, data []byte, size int, allowText bool) string {
+	if size == 0 {
+		return ""
+	}
+	if size > b.maxParseLength || len(data) < size {
+		return fmt.Sprintf("<%d bytes>", size)
+	}
+	var res string
+	switch {
+	case strings.Contains(contentType, "json"):
+		var val any
+		if err := json.Unmarshal(data, &val); err != nil {
+			return fmt.Sprintf("<%d bytes>", size)
+		}
+		out, err := json.Marshal(b.redactJSON(val))
+		if err != nil {
+			return fmt.Sprintf("<%d bytes>", size)
+		}
+		res = string(out)
+	case strings.Contains(contentType, "x-www-form-urlencoded"):
+		vals, err := url.ParseQuery(string(data))
+		if err != nil {
+			return fmt.Sprintf("<%d bytes>", size)
+		}
+		for k := range vals {
+			if b.sensitive(k) {
+				vals[k] = []string{redacted}
+			}
+		}
+		res = vals.Encode()
+	case allowText && strings.HasPrefix(contentType, "text/plain"):
+		res = string(data)
+	default:
+		return fmt.Sprintf("<%d bytes>", size)
+	}
+	return b.truncate(res)
+}
+
+func (b *Builder) redactJSON(val any) any {
+	switch v := val.(type) {
+	case map[string]any:
+		for k, item := range v {
+			if b.sensitive(k) {
+				v[k] = redacted
+				continue
+			}
+			v[k] = b.redactJSON(item)
+		}
+	case []any:
+		for i, item := range v {
+			v[i] = b.redactJSON(item)
+		}
+	}
+	return val
+}
+
+func (b *Builder) sensitive(field string) bool {
+	f := normalizeField(field)
+	if strings.Contains(f, "password") {
+		return true
+	}
+	_, ok := b.fields[f]
+	return ok
+}
+
+// truncate 不能把一个 UTF-8 字符切成两半
+func (b *Builder) truncate(s string) string {
+	if len(s) <= b.maxBodyLength {
+		return s
+	}
+	end := b.maxBodyLength
+	for end > 0 && !utf8.RuneStart(s[end]) {
+		end--
+	}
+	return s[:end] + "..."
+}
+
+func normalizeField(field string) string {
+	return strings.ToLower(strings.NewReplacer("_", "", "-", "").Replace(field))
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// responseWriter 写给客户端的同时留一份，最多留 limit 个字节
+type responseWriter struct {
+	gin.ResponseWriter
+	body  bytes.Buffer
+	size  int
+	limit int
+}
+
+func (w *responseWriter) Write(data []byte) (int, error) {
+	w.capture(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *responseWriter) WriteString(s string) (int, error) {
+	w.capture([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *responseWriter) capture(data []byte) {
+	w.size += len(data)
+	if remain := w.limit - w.body.Len(); remain > 0 {
+		if len(data) > remain {
+			data = data[:remain]
+		}
+		w.body.Write(data)
+	}
+}
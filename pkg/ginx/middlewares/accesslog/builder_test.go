@@ -0,0 +1,127 @@
+package accesslog
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestBuilder_Redact(t *testing.T) {
+	testCases := []struct {
+		name        string
+		allowBody   bool
+		contentType string
+		body        string
+
+		wantReqBody  string
+		wantRespBody string
+	}{
+		{
+			name:        "默认不记录 body",
+			contentType: "application/json",
+			body:        `{"email":"tom@qq.com","password":"hello#world123"}`,
+		},
+		{
+			name:         "JSON 里面的密码替换掉",
+			allowBody:    true,
+			contentType:  "application/json",
+			body:         `{"email":"tom@qq.com","password":"hello#world123","confirmPassword":"hello#world123"}`,
+			wantReqBody:  `{"confirmPassword":"***","email":"tom@qq.com","password":"***"}`,
+			wantRespBody: `{"msg":"ok"}`,
+		},
+		{
+			name:         "嵌套的也要替换",
+			allowBody:    true,
+			contentType:  "application/json; charset=utf-8",
+			body:         `{"users":[{"Old_Password":"a"},{"code":"123456"}]}`,
+			wantReqBody:  `{"users":[{"Old_Password":"***"},{"code":"***"}]}`,
+			wantRespBody: `{"msg":"ok"}`,
+		},
+		{
+			name:         "表单里面的密码替换掉",
+			allowBody:    true,
+			contentType:  "application/x-www-form-urlencoded",
+			body:         "email=tom%40qq.com&password=hello%23world123",
+			wantReqBody:  "email=tom%40qq.com&password=%2A%2A%2A",
+			wantRespBody: `{"msg":"ok"}`,
+		},
+		{
+			name:         "解析不了的只记录长度",
+			allowBody:    true,
+			contentType:  "application/json",
+			body:         `{"password":"hello#world123"`,
+			wantReqBody:  "<28 bytes>",
+			wantRespBody: `{"msg":"ok"}`,
+		},
+		{
+			name:         "不认识的格式只记录长度",
+			allowBody:    true,
+			contentType:  "text/plain",
+			body:         "password=hello#world123",
+			wantReqBody:  "<23 bytes>",
+			wantRespBody: `{"msg":"ok"}`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var logs []AccessLog
+			builder := NewBuilder(func(ctx context.Context, al AccessLog) {
+				logs = append(logs, al)
+			})
+			if tc.allowBody {
+				builder.AllowBody()
+			}
+			server := gin.New()
+			server.Use(builder.Build())
+			var handlerBody string
+			server.POST("/users/login", func(ctx *gin.Context) {
+				data, _ := io.ReadAll(ctx.Request.Body)
+				handlerBody = string(data)
+				ctx.JSON(http.StatusCreated, gin.H{"msg": "ok"})
+			})
+			req, err := http.NewRequest(http.MethodPost, "/users/login?token=abc", strings.NewReader(tc.body))
+			require.NoError(t, err)
+			req.Header.Set("Content-Type", tc.contentType)
+			resp := httptest.NewRecorder()
+			server.ServeHTTP(resp, req)
+
+			// handler 拿到的还是原来的 body
+			assert.Equal(t, tc.body, handlerBody)
+			assert.Equal(t, `{"msg":"ok"}`, resp.Body.String())
+			require.Len(t, logs, 1)
+			al := logs[0]
+			assert.Equal(t, http.MethodPost, al.Method)
+			assert.Equal(t, "/users/login", al.Path)
+			assert.Equal(t, http.StatusCreated, al.Status)
+			assert.True(t, al.Duration > 0)
+			assert.Equal(t, tc.wantReqBody, al.ReqBody)
+			assert.Equal(t, tc.wantRespBody, al.RespBody)
+			assert.NotContains(t, al.ReqBody, "hello")
+		})
+	}
+}
+
+func TestBuilder_Truncate(t *testing.T) {
+	var al AccessLog
+	server := gin.New()
+	server.Use(NewBuilder(func(ctx context.Context, l AccessLog) {
+		al = l
+	}).AllowBody().MaxBodyLength(10).RedactFields("nickname").Build())
+	server.POST("/users/edit", func(ctx *gin.Context) {
+		ctx.String(http.StatusOK, "修改成功了")
+	})
+	req, err := http.NewRequest(http.MethodPost, "/users/edit",
+		strings.NewReader(`{"nickname":"Tom","brief":"hello"}`))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	server.ServeHTTP(httptest.NewRecorder(), req)
+	assert.Equal(t, `{"brief":"...`, al.ReqBody)
+	// 不会把中文切成两半
+	assert.Equal(t, "修改成...", al.RespBody)
+}
@@ -1,32 +1,31 @@
 package ratelimit
 
 import (
-	_ "embed"
 	"fmt"
 	"github.com/gin-gonic/gin"
-	"github.com/redis/go-redis/v9"
 	"log"
 	"net/http"
+	"strconv"
 	"time"
+	"webook/pkg/ratelimit"
+)
+
+const (
+	HeaderLimit      = "X-RateLimit-Limit"
+	HeaderRemaining  = "X-RateLimit-Remaining"
+	HeaderRetryAfter = "Retry-After"
 )
 
 type Builder struct {
-	prefix   string
-	cmd      redis.Cmdable
-	interval time.Duration
-	// 阈值
-	rate int
+	prefix  string
+	limiter ratelimit.Limiter
 }
 
-//go:embed slide_window.lua
-var luaScript string
-
-func NewBuilder(cmd redis.Cmdable, interval time.Duration, rate int) *Builder {
+// NewBuilder limiter 实现了 ratelimit.QuotaLimiter 的话，响应里面会带上剩余的额度
+func NewBuilder(limiter ratelimit.Limiter) *Builder {
 	return &Builder{
-		cmd:      cmd,
-		prefix:   "ip-limiter",
-		interval: interval,
-		rate:     rate,
+		prefix:  "ip-limiter",
+		limiter: limiter,
 	}
 }
 
@@ -56,6 +55,25 @@ func (b *Builder) Build() gin.HandlerFunc {
 
 func (b *Builder) limit(ctx *gin.Context) (bool, error) {
 	key := fmt.Sprintf("%s:%s", b.prefix, ctx.ClientIP())
-	return b.cmd.Eval(ctx, luaScript, []string{key},
-		b.interval.Milliseconds(), b.rate, time.Now().UnixMilli()).Bool()
+	limiter, ok := b.limiter.(ratelimit.QuotaLimiter)
+	if !ok {
+		return b.limiter.Limit(ctx, key)
+	}
+	quota, err := limiter.Quota(ctx, key)
+	if err != nil {
+		return false, err
+	}
+	SetHeaders(ctx, quota)
+	return quota.Limited, nil
+}
+
+// SetHeaders 告诉客户端还剩多少额度，让它自己慢下来。
+// Retry-After 只有在要等的时候才设置，单位是秒，向上取整
+func SetHeaders(ctx *gin.Context, quota ratelimit.Quota) {
+	ctx.Header(HeaderLimit, strconv.Itoa(quota.Limit))
+	ctx.Header(HeaderRemaining, strconv.Itoa(quota.Remaining))
+	if quota.RetryAfter > 0 {
+		seconds := (quota.RetryAfter + time.Second - 1) / time.Second
+		ctx.Header(HeaderRetryAfter, strconv.FormatInt(int64(seconds), 10))
+	}
 }
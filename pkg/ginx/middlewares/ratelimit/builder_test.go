@@ -0,0 +1,82 @@
+package ratelimit
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"webook/pkg/ratelimit"
+	limitmocks "webook/pkg/ratelimit/mocks"
+)
+
+func TestBuilder_Headers(t *testing.T) {
+	testCases := []struct {
+		name string
+		mock func(ctrl *gomock.Controller) ratelimit.Limiter
+
+		wantCode       int
+		wantLimit      string
+		wantRemaining  string
+		wantRetryAfter string
+	}{
+		{
+			name: "还有额度",
+			mock: func(ctrl *gomock.Controller) ratelimit.Limiter {
+				l := limitmocks.NewMockQuotaLimiter(ctrl)
+				l.EXPECT().Quota(gomock.Any(), "ip-limiter:192.0.2.1").
+					Return(ratelimit.Quota{Limit: 100, Remaining: 99}, nil)
+				return l
+			},
+			wantCode:      http.StatusOK,
+			wantLimit:     "100",
+			wantRemaining: "99",
+		},
+		{
+			name: "限流了，不满一秒按一秒算",
+			mock: func(ctrl *gomock.Controller) ratelimit.Limiter {
+				l := limitmocks.NewMockQuotaLimiter(ctrl)
+				l.EXPECT().Quota(gomock.Any(), "ip-limiter:192.0.2.1").
+					Return(ratelimit.Quota{Limited: true, Limit: 100, RetryAfter: 300 * time.Millisecond}, nil)
+				return l
+			},
+			wantCode:       http.StatusTooManyRequests,
+			wantLimit:      "100",
+			wantRemaining:  "0",
+			wantRetryAfter: "1",
+		},
+		{
+			name: "只实现了 Limiter 的不带额度",
+			mock: func(ctrl *gomock.Controller) ratelimit.Limiter {
+				l := limitmocks.NewMockLimiter(ctrl)
+				l.EXPECT().Limit(gomock.Any(), "ip-limiter:192.0.2.1").Return(true, nil)
+				return l
+			},
+			wantCode: http.StatusTooManyRequests,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			server := gin.New()
+			server.Use(NewBuilder(tc.mock(ctrl)).Build())
+			server.GET("/hello", func(ctx *gin.Context) {
+				ctx.String(http.StatusOK, "hello")
+			})
+			req, err := http.NewRequest(http.MethodGet, "/hello", nil)
+			require.NoError(t, err)
+			req.RemoteAddr = "192.0.2.1:1234"
+			resp := httptest.NewRecorder()
+			server.ServeHTTP(resp, req)
+
+			assert.Equal(t, tc.wantCode, resp.Code)
+			assert.Equal(t, tc.wantLimit, resp.Header().Get(HeaderLimit))
+			assert.Equal(t, tc.wantRemaining, resp.Header().Get(HeaderRemaining))
+			assert.Equal(t, tc.wantRetryAfter, resp.Header().Get(HeaderRetryAfter))
+		})
+	}
+}
@@ -0,0 +1,13 @@
+package idgen
+
+// IDGenerator 生成主键。返回 0 代表交给数据库自增
+type IDGenerator interface {
+	NextID() (int64, error)
+}
+
+// AutoIncrement 什么都不做，还是用数据库的自增主键。分库分表之前都可以用这个
+type AutoIncrement struct{}
+
+func (AutoIncrement) NextID() (int64, error) {
+	return 0, nil
+}
@@ -0,0 +1,79 @@
+package idgen
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	nodeBits     = 10
+	sequenceBits = 12
+
+	MaxNodeID   = 1<<nodeBits - 1
+	maxSequence = 1<<sequenceBits - 1
+	// 时钟回拨在这个范围之内就等一下，再多就报错
+	maxBackwards = 10 * time.Millisecond
+)
+
+// Epoch 2024-01-01 00:00:00 UTC，41 位的毫秒数从这里开始够用六十多年
+var Epoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+var (
+	ErrInvalidNodeID  = errors.New("snowflake 的节点编号不合法")
+	ErrClockBackwards = errors.New("时钟回拨了，不能生成 ID")
+)
+
+// Snowflake 1 位符号位 + 41 位毫秒时间戳 + 10 位节点编号 + 12 位序号。
+// 每个实例的节点编号必须不一样，不然会生成重复的 ID。
+// 生成的 ID 超过了 JavaScript 能精确表示的范围，前端要当成字符串处理
+type Snowflake struct {
+	mutex    sync.Mutex
+	node     int64
+	lastMs   int64
+	sequence int64
+	now      func() time.Time
+}
+
+func NewSnowflake(node int64) (*Snowflake, error) {
+	if node < 0 || node > MaxNodeID {
+		return nil, ErrInvalidNodeID
+	}
+	return &Snowflake{
+		node: node,
+		now:  time.Now,
+	}, nil
+}
+
+func (s *Snowflake) NextID() (int64, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	ms := s.millis()
+	if ms < s.lastMs {
+		// 回拨的不多就等到追上来
+		if time.Duration(s.lastMs-ms)*time.Millisecond > maxBackwards {
+			return 0, ErrClockBackwards
+		}
+		for ms < s.lastMs {
+			time.Sleep(time.Duration(s.lastMs-ms) * time.Millisecond)
+			ms = s.millis()
+		}
+	}
+	if ms == s.lastMs {
+		s.sequence = (s.sequence + 1) & maxSequence
+		if s.sequence == 0 {
+			// 这一毫秒的序号用完了，等下一毫秒
+			for ms <= s.lastMs {
+				ms = s.millis()
+			}
+		}
+	} else {
+		s.sequence = 0
+	}
+	s.lastMs = ms
+	return ms<<(nodeBits+sequenceBits) | s.node<<sequenceBits | s.sequence, nil
+}
+
+func (s *Snowflake) millis() int64 {
+	return s.now().Sub(Epoch).Milliseconds()
+}
@@ -0,0 +1,83 @@
+package idgen
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestNewSnowflake(t *testing.T) {
+	_, err := NewSnowflake(-1)
+	assert.Equal(t, ErrInvalidNodeID, err)
+	_, err = NewSnowflake(MaxNodeID + 1)
+	assert.Equal(t, ErrInvalidNodeID, err)
+	_, err = NewSnowflake(MaxNodeID)
+	assert.NoError(t, err)
+}
+
+func TestSnowflake_NextID(t *testing.T) {
+	s, err := NewSnowflake(5)
+	require.NoError(t, err)
+	const n = 8
+	const perGoroutine = 10000
+	ids := make([][]int64, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < perGoroutine; j++ {
+				id, err := s.NextID()
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				ids[i] = append(ids[i], id)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	seen := make(map[int64]struct{}, n*perGoroutine)
+	for _, list := range ids {
+		for j, id := range list {
+			_, ok := seen[id]
+			require.False(t, ok, "重复的 ID %d", id)
+			seen[id] = struct{}{}
+			assert.Equal(t, int64(5), id>>sequenceBits&MaxNodeID)
+			// 同一个 goroutine 拿到的是递增的
+			if j > 0 {
+				assert.Greater(t, id, list[j-1])
+			}
+		}
+	}
+}
+
+func TestSnowflake_ClockBackwards(t *testing.T) {
+	s, err := NewSnowflake(1)
+	require.NoError(t, err)
+	now := time.Now()
+	s.now = func() time.Time { return now }
+	first, err := s.NextID()
+	require.NoError(t, err)
+
+	// 回拨一点点，等时钟追上来
+	calls := 0
+	s.now = func() time.Time {
+		calls++
+		if calls == 1 {
+			return now.Add(-5 * time.Millisecond)
+		}
+		return now.Add(time.Millisecond)
+	}
+	second, err := s.NextID()
+	require.NoError(t, err)
+	assert.Greater(t, second, first)
+
+	// 回拨太多直接报错
+	s.now = func() time.Time { return now.Add(-time.Second) }
+	_, err = s.NextID()
+	assert.Equal(t, ErrClockBackwards, err)
+}
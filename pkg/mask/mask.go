@@ -0,0 +1,43 @@
+// Package mask 给后台列表之类的地方展示邮箱、手机号，只留几位能认出来就行。
+// 日志和错误信息里面要带上联系方式的，也统一用这里的函数打码
+package mask
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// Email 用户名只留第一个和最后一个字符，域名完整保留：zhangsan@qq.com => z***n@qq.com。
+// 用户名不超过两个字符的只留第一个，不是邮箱的整个都遮掉
+func Email(email string) string {
+	name, domain, ok := strings.Cut(email, "@")
+	if !ok || name == "" {
+		return stars(email)
+	}
+	first, size := utf8.DecodeRuneInString(name)
+	if utf8.RuneCountInString(name) <= 2 {
+		return string(first) + "***@" + domain
+	}
+	last, _ := utf8.DecodeLastRuneInString(name[size:])
+	return string(first) + "***" + string(last) + "@" + domain
+}
+
+// Phone 大陆手机号留前三位和后四位：15212345678 => 152****5678。
+// 不到 11 位的只留最后两位，不然几乎全露出来了
+func Phone(phone string) string {
+	n := len(phone)
+	switch {
+	case n == 0:
+		return ""
+	case n >= 11:
+		return phone[:3] + "****" + phone[n-4:]
+	case n > 2:
+		return strings.Repeat("*", n-2) + phone[n-2:]
+	default:
+		return stars(phone)
+	}
+}
+
+func stars(s string) string {
+	return strings.Repeat("*", utf8.RuneCountInString(s))
+}
@@ -0,0 +1,48 @@
+package mask
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestEmail(t *testing.T) {
+	testCases := []struct {
+		name  string
+		email string
+		want  string
+	}{
+		{name: "普通邮箱", email: "zhangsan@qq.com", want: "z***n@qq.com"},
+		{name: "三个字符", email: "abc@qq.com", want: "a***c@qq.com"},
+		{name: "两个字符", email: "ab@qq.com", want: "a***@qq.com"},
+		{name: "一个字符", email: "a@qq.com", want: "a***@qq.com"},
+		{name: "中文用户名", email: "张三丰@example.com", want: "张***丰@example.com"},
+		{name: "没有用户名", email: "@qq.com", want: "*******"},
+		{name: "不是邮箱", email: "abc", want: "***"},
+		{name: "空的", email: "", want: ""},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, Email(tc.email))
+		})
+	}
+}
+
+func TestPhone(t *testing.T) {
+	testCases := []struct {
+		name  string
+		phone string
+		want  string
+	}{
+		{name: "大陆手机号", phone: "15212345678", want: "152****5678"},
+		{name: "带区号", phone: "+8615212345678", want: "+86****5678"},
+		{name: "座机", phone: "12345678", want: "******78"},
+		{name: "短号", phone: "12345", want: "***45"},
+		{name: "两位", phone: "12", want: "**"},
+		{name: "空的", phone: "", want: ""},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, Phone(tc.phone))
+		})
+	}
+}
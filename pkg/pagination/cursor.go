@@ -0,0 +1,53 @@
+package pagination
+
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidCursor 游标是前端传上来的，解不开、被改过的都是这个错误
+var ErrInvalidCursor = errors.New("翻页游标不合法")
+
+// maxCursorLength 两个 int64 加一个点，编码之后也就三十来个字符，
+// 太长的直接拒绝，不用去解
+const maxCursorLength = 64
+
+// Cursor 上一页最后一条的 id 和 ctime，按 ctime 排序的时候 ctime 一样的再按 id 排。
+// 列表都是按 (ctime, id) 或者 id 做 keyset 翻页的，所以这两个就够了
+type Cursor struct {
+	Id    int64
+	Ctime time.Time
+}
+
+// EncodeCursor 游标对前端是不透明的，换了排序字段之后旧的游标不要再用
+func EncodeCursor(c Cursor) string {
+	raw := strconv.FormatInt(c.Id, 10) + "." + strconv.FormatInt(c.Ctime.UnixMilli(), 10)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// DecodeCursor 不合法的都返回 ErrInvalidCursor
+func DecodeCursor(s string) (Cursor, error) {
+	if s == "" || len(s) > maxCursorLength {
+		return Cursor{}, ErrInvalidCursor
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+	idStr, ctimeStr, ok := strings.Cut(string(raw), ".")
+	if !ok {
+		return Cursor{}, ErrInvalidCursor
+	}
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil || id <= 0 {
+		return Cursor{}, ErrInvalidCursor
+	}
+	ctime, err := strconv.ParseInt(ctimeStr, 10, 64)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+	return Cursor{Id: id, Ctime: time.UnixMilli(ctime)}, nil
+}
@@ -0,0 +1,49 @@
+package pagination
+
+import (
+	"encoding/base64"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCursor(t *testing.T) {
+	c := Cursor{Id: 123, Ctime: time.UnixMilli(1700000000123)}
+	res, err := DecodeCursor(EncodeCursor(c))
+	require.NoError(t, err)
+	assert.Equal(t, c, res)
+
+	// 按 id 翻页的时候 ctime 是零值，也要能解出来
+	res, err = DecodeCursor(EncodeCursor(Cursor{Id: 10}))
+	require.NoError(t, err)
+	assert.Equal(t, int64(10), res.Id)
+}
+
+func TestDecodeCursor_Invalid(t *testing.T) {
+	encode := func(raw string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(raw))
+	}
+	testCases := []struct {
+		name   string
+		cursor string
+	}{
+		{name: "空的", cursor: ""},
+		{name: "不是 base64", cursor: "not base64!"},
+		{name: "没有点", cursor: encode("123")},
+		{name: "id 不是数字", cursor: encode("abc.1500")},
+		{name: "id 是负数", cursor: encode("-1.1500")},
+		{name: "id 是 0", cursor: encode("0.1500")},
+		{name: "ctime 不是数字", cursor: encode("123.abc")},
+		{name: "多了一段", cursor: encode("123.1500.1")},
+		{name: "溢出", cursor: encode("99999999999999999999.1500")},
+		{name: "太长了", cursor: strings.Repeat("A", 1000)},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := DecodeCursor(tc.cursor)
+			assert.Equal(t, ErrInvalidCursor, err)
+		})
+	}
+}
@@ -0,0 +1,104 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: webook/pkg/ratelimit/types.go
+
+// Package limitmocks is a generated GoMock package.
+package limitmocks
+
+import (
+	context "context"
+	reflect "reflect"
+	ratelimit "webook/pkg/ratelimit"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockLimiter is a mock of Limiter interface.
+type MockLimiter struct {
+	ctrl     *gomock.Controller
+	recorder *MockLimiterMockRecorder
+}
+
+// MockLimiterMockRecorder is the mock recorder for MockLimiter.
+type MockLimiterMockRecorder struct {
+	mock *MockLimiter
+}
+
+// NewMockLimiter creates a new mock instance.
+func NewMockLimiter(ctrl *gomock.Controller) *MockLimiter {
+	mock := &MockLimiter{ctrl: ctrl}
+	mock.recorder = &MockLimiterMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockLimiter) EXPECT() *MockLimiterMockRecorder {
+	return m.recorder
+}
+
+// Limit mocks base method.
+func (m *MockLimiter) Limit(ctx context.Context, key string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Limit", ctx, key)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Limit indicates an expected call of Limit.
+func (mr *MockLimiterMockRecorder) Limit(ctx, key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Limit", reflect.TypeOf((*MockLimiter)(nil).Limit), ctx, key)
+}
+
+// MockQuotaLimiter is a mock of QuotaLimiter interface.
+type MockQuotaLimiter struct {
+	ctrl     *gomock.Controller
+	recorder *MockQuotaLimiterMockRecorder
+}
+
+// MockQuotaLimiterMockRecorder is the mock recorder for MockQuotaLimiter.
+type MockQuotaLimiterMockRecorder struct {
+	mock *MockQuotaLimiter
+}
+
+// NewMockQuotaLimiter creates a new mock instance.
+func NewMockQuotaLimiter(ctrl *gomock.Controller) *MockQuotaLimiter {
+	mock := &MockQuotaLimiter{ctrl: ctrl}
+	mock.recorder = &MockQuotaLimiterMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockQuotaLimiter) EXPECT() *MockQuotaLimiterMockRecorder {
+	return m.recorder
+}
+
+// Limit mocks base method.
+func (m *MockQuotaLimiter) Limit(ctx context.Context, key string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Limit", ctx, key)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Limit indicates an expected call of Limit.
+func (mr *MockQuotaLimiterMockRecorder) Limit(ctx, key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Limit", reflect.TypeOf((*MockQuotaLimiter)(nil).Limit), ctx, key)
+}
+
+// Quota mocks base method.
+func (m *MockQuotaLimiter) Quota(ctx context.Context, key string) (ratelimit.Quota, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Quota", ctx, key)
+	ret0, _ := ret[0].(ratelimit.Quota)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Quota indicates an expected call of Quota.
+func (mr *MockQuotaLimiterMockRecorder) Quota(ctx, key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Quota", reflect.TypeOf((*MockQuotaLimiter)(nil).Quota), ctx, key)
+}
@@ -0,0 +1,59 @@
+package ratelimit
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"github.com/redis/go-redis/v9"
+	"time"
+)
+
+//go:embed slide_window.lua
+var luaSlideWindow string
+
+//go:embed slide_window_quota.lua
+var luaSlideWindowQuota string
+
+// RedisSlidingWindowLimiter Redis 上的滑动窗口算法限流器实现
+type RedisSlidingWindowLimiter struct {
+	cmd redis.Cmdable
+
+	// 窗口大小
+	interval time.Duration
+	// 阈值
+	rate int
+	// interval 内允许 rate 个请求
+	// 1s 内允许 3000 个请求
+}
+
+// NewRedisSlidingWindowLimiter 返回的也实现了 QuotaLimiter
+func NewRedisSlidingWindowLimiter(cmd redis.Cmdable,
+	interval time.Duration, rate int) Limiter {
+	return &RedisSlidingWindowLimiter{
+		cmd:      cmd,
+		interval: interval,
+		rate:     rate,
+	}
+}
+
+func (r *RedisSlidingWindowLimiter) Limit(ctx context.Context, key string) (bool, error) {
+	return r.cmd.Eval(ctx, luaSlideWindow, []string{key},
+		r.interval.Milliseconds(), r.rate, time.Now().UnixMilli()).Bool()
+}
+
+func (r *RedisSlidingWindowLimiter) Quota(ctx context.Context, key string) (Quota, error) {
+	res, err := r.cmd.Eval(ctx, luaSlideWindowQuota, []string{key},
+		r.interval.Milliseconds(), r.rate, time.Now().UnixMilli()).Int64Slice()
+	if err != nil {
+		return Quota{}, err
+	}
+	if len(res) != 3 {
+		return Quota{}, errors.New("限流脚本的返回值不对")
+	}
+	return Quota{
+		Limited:    res[0] == 1,
+		Limit:      r.rate,
+		Remaining:  int(res[1]),
+		RetryAfter: time.Duration(res[2]) * time.Millisecond,
+	}, nil
+}
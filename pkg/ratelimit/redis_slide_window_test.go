@@ -0,0 +1,143 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"testing"
+	"time"
+	"webook/internal/repository/cache/redismocks"
+)
+
+func TestRedisSlidingWindowLimiter_Limit(t *testing.T) {
+	testCases := []struct {
+		name string
+		mock func(ctrl *gomock.Controller) redis.Cmdable
+
+		key string
+
+		wantLimited bool
+		wantErr     error
+	}{
+		{
+			name: "未触发限流",
+			mock: func(ctrl *gomock.Controller) redis.Cmdable {
+				cmd := redismocks.NewMockCmdable(ctrl)
+				res := redis.NewCmd(context.Background())
+				res.SetVal("false")
+				cmd.EXPECT().Eval(gomock.Any(), luaSlideWindow,
+					[]string{"ip-limiter:127.0.0.1"},
+					int64(1000), 100, gomock.Any(),
+				).Return(res)
+				return cmd
+			},
+			key:         "ip-limiter:127.0.0.1",
+			wantLimited: false,
+		},
+		{
+			name: "触发限流",
+			mock: func(ctrl *gomock.Controller) redis.Cmdable {
+				cmd := redismocks.NewMockCmdable(ctrl)
+				res := redis.NewCmd(context.Background())
+				res.SetVal("true")
+				cmd.EXPECT().Eval(gomock.Any(), luaSlideWindow,
+					[]string{"ip-limiter:127.0.0.1"},
+					int64(1000), 100, gomock.Any(),
+				).Return(res)
+				return cmd
+			},
+			key:         "ip-limiter:127.0.0.1",
+			wantLimited: true,
+		},
+		{
+			name: "redis错误",
+			mock: func(ctrl *gomock.Controller) redis.Cmdable {
+				cmd := redismocks.NewMockCmdable(ctrl)
+				res := redis.NewCmd(context.Background())
+				res.SetErr(errors.New("mock redis 错误"))
+				cmd.EXPECT().Eval(gomock.Any(), luaSlideWindow,
+					[]string{"ip-limiter:127.0.0.1"},
+					int64(1000), 100, gomock.Any(),
+				).Return(res)
+				return cmd
+			},
+			key:     "ip-limiter:127.0.0.1",
+			wantErr: errors.New("mock redis 错误"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			limiter := NewRedisSlidingWindowLimiter(tc.mock(ctrl), time.Second, 100)
+			limited, err := limiter.Limit(context.Background(), tc.key)
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.wantLimited, limited)
+		})
+	}
+}
+
+func TestRedisSlidingWindowLimiter_Quota(t *testing.T) {
+	testCases := []struct {
+		name string
+		// Eval 的返回值
+		val []any
+		err error
+
+		wantQuota Quota
+		wantErr   error
+	}{
+		{
+			name: "未触发限流",
+			val:  []any{int64(0), int64(99), int64(0)},
+			wantQuota: Quota{
+				Limit:     100,
+				Remaining: 99,
+			},
+		},
+		{
+			name: "触发限流",
+			val:  []any{int64(1), int64(0), int64(300)},
+			wantQuota: Quota{
+				Limited:    true,
+				Limit:      100,
+				RetryAfter: 300 * time.Millisecond,
+			},
+		},
+		{
+			name:    "返回值不对",
+			val:     []any{int64(1)},
+			wantErr: errors.New("限流脚本的返回值不对"),
+		},
+		{
+			name:    "redis错误",
+			err:     errors.New("mock redis 错误"),
+			wantErr: errors.New("mock redis 错误"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			cmd := redismocks.NewMockCmdable(ctrl)
+			res := redis.NewCmd(context.Background())
+			if tc.err != nil {
+				res.SetErr(tc.err)
+			} else {
+				res.SetVal(tc.val)
+			}
+			cmd.EXPECT().Eval(gomock.Any(), luaSlideWindowQuota,
+				[]string{"ip-limiter:127.0.0.1"},
+				int64(1000), 100, gomock.Any(),
+			).Return(res)
+			limiter := NewRedisSlidingWindowLimiter(cmd, time.Second, 100).(QuotaLimiter)
+			quota, err := limiter.Quota(context.Background(), "ip-limiter:127.0.0.1")
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.wantQuota, quota)
+		})
+	}
+}
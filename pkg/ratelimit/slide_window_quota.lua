@@ -0,0 +1,24 @@
+-- 跟 slide_window.lua 一样，只是把剩余的额度也返回去
+-- 返回 {是否限流, 剩余多少个, 多少毫秒之后可以再来}
+
+local key = KEYS[1]
+local window = tonumber(ARGV[1])
+local threshold = tonumber( ARGV[2])
+local now = tonumber(ARGV[3])
+local min = now - window
+
+redis.call('ZREMRANGEBYSCORE', key, '-inf', min)
+local cnt = redis.call('ZCOUNT', key, '-inf', '+inf')
+if cnt >= threshold then
+    -- 最早的那个请求滑出窗口之后，就可以再来了
+    local retry = window
+    local oldest = redis.call('ZRANGE', key, 0, 0, 'WITHSCORES')
+    if oldest[2] then
+        retry = tonumber(oldest[2]) + window - now
+    end
+    return {1, 0, retry}
+else
+    redis.call('ZADD', key, now, now)
+    redis.call('PEXPIRE', key, window)
+    return {0, threshold - cnt - 1, 0}
+end
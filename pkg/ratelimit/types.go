@@ -0,0 +1,32 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+)
+
+type Limiter interface {
+	// Limit 有没有触发限流。key 就是限流对象
+	// bool 代表是否限流，true 就是要限流
+	// err 限流器本身有没有错误
+	Limit(ctx context.Context, key string) (bool, error)
+}
+
+// Quota 一次限流判断之后的额度
+type Quota struct {
+	// Limited 是否限流，跟 Limit 方法返回的一样
+	Limited bool
+	// Limit 一个窗口内一共允许多少个请求
+	Limit int
+	// Remaining 算上这一次，窗口内还剩多少个
+	Remaining int
+	// RetryAfter 被限流了的话，多久之后可以再来
+	RetryAfter time.Duration
+}
+
+// QuotaLimiter 除了告诉你是不是限流了，还告诉你还剩多少额度，
+// 客户端可以据此自己慢下来
+type QuotaLimiter interface {
+	Limiter
+	Quota(ctx context.Context, key string) (Quota, error)
+}
@@ -0,0 +1,108 @@
+// Package redisx go-redis 的一些扩展
+package redisx
+
+import (
+	"context"
+	"errors"
+	"github.com/redis/go-redis/v9"
+	"github.com/sony/gobreaker"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"log"
+	"time"
+)
+
+// ErrCircuitOpen Redis 连续出错被熔断了，命令没有发出去
+var ErrCircuitOpen = errors.New("redis 熔断中")
+
+type BreakerConfig struct {
+	// 连续失败多少次之后熔断
+	ConsecutiveFailures uint32
+	// 熔断之后多久进入半开状态
+	OpenTimeout time.Duration
+	// 半开状态下最多放多少个请求过去试探，都成功了就恢复
+	HalfOpenRequests uint32
+}
+
+// BreakerHook 熔断器，用 client.AddHook 装到 Redis 客户端上，所有的命令都会经过它。
+// 熔断的时候直接返回 ErrCircuitOpen，不用等超时，调用方可以快速降级
+type BreakerHook struct {
+	cb *gobreaker.CircuitBreaker
+}
+
+// NewBreakerHook name 用来区分不同的 Redis，会出现在日志和监控里面。
+// 状态通过 otel 的 redis_circuit_breaker_state 上报：0 关闭，1 半开，2 熔断，
+// 没有配置 MeterProvider 的时候 otel 默认是 noop 的
+func NewBreakerHook(name string, cfg BreakerConfig) *BreakerHook {
+	h := &BreakerHook{}
+	h.cb = gobreaker.NewCircuitBreaker(gobreaker.Settings{
+		Name:        name,
+		MaxRequests: cfg.HalfOpenRequests,
+		Timeout:     cfg.OpenTimeout,
+		ReadyToTrip: func(counts gobreaker.Counts) bool {
+			return counts.ConsecutiveFailures >= cfg.ConsecutiveFailures
+		},
+		IsSuccessful: func(err error) bool {
+			return !isFailure(err)
+		},
+		OnStateChange: func(name string, from gobreaker.State, to gobreaker.State) {
+			log.Printf("redis 熔断器 %s: %s => %s", name, from, to)
+		},
+	})
+	_, err := otel.Meter("webook/pkg/redisx").Int64ObservableGauge("redis_circuit_breaker_state",
+		metric.WithDescription("Redis 熔断器的状态，0 关闭，1 半开，2 熔断"),
+		metric.WithInt64Callback(func(ctx context.Context, o metric.Int64Observer) error {
+			o.Observe(int64(h.cb.State()), metric.WithAttributes(attribute.String("name", name)))
+			return nil
+		}))
+	if err != nil {
+		log.Println("注册 redis 熔断器的监控失败", err)
+	}
+	return h
+}
+
+func (h *BreakerHook) State() gobreaker.State {
+	return h.cb.State()
+}
+
+func (h *BreakerHook) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (h *BreakerHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		return h.execute(func() error {
+			return next(ctx, cmd)
+		})
+	}
+}
+
+// ProcessPipelineHook 整个 pipeline 算一次
+func (h *BreakerHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		return h.execute(func() error {
+			return next(ctx, cmds)
+		})
+	}
+}
+
+func (h *BreakerHook) execute(fn func() error) error {
+	_, err := h.cb.Execute(func() (any, error) {
+		return nil, fn()
+	})
+	if err == gobreaker.ErrOpenState || err == gobreaker.ErrTooManyRequests {
+		return ErrCircuitOpen
+	}
+	return err
+}
+
+// isFailure 只有 Redis 本身不健康才算失败。key 不存在、Lua 脚本报错这些
+// 说明 Redis 好好地回复了；调用方自己取消的也跟 Redis 没关系
+func isFailure(err error) bool {
+	if err == nil || err == redis.Nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	var redisErr redis.Error
+	return !errors.As(err, &redisErr)
+}
@@ -0,0 +1,63 @@
+package redisx
+
+import (
+	"context"
+	"errors"
+	"github.com/redis/go-redis/v9"
+	"github.com/sony/gobreaker"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestBreakerHook(t *testing.T) {
+	h := NewBreakerHook("test", BreakerConfig{
+		ConsecutiveFailures: 3,
+		OpenTimeout:         time.Millisecond * 100,
+		HalfOpenRequests:    1,
+	})
+	var calls int
+	var nextErr error
+	process := h.ProcessHook(func(ctx context.Context, cmd redis.Cmder) error {
+		calls++
+		return nextErr
+	})
+	ctx := context.Background()
+	cmd := redis.NewStringCmd(ctx, "get", "key")
+
+	// key 不存在不算失败
+	nextErr = redis.Nil
+	for i := 0; i < 5; i++ {
+		assert.Equal(t, redis.Nil, process(ctx, cmd))
+	}
+	assert.Equal(t, gobreaker.StateClosed, h.State())
+
+	// 连续超时三次就熔断
+	timeout := errors.New("i/o timeout")
+	nextErr = timeout
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, timeout, process(ctx, cmd))
+	}
+	assert.Equal(t, gobreaker.StateOpen, h.State())
+
+	// 熔断之后不会再发给 Redis
+	calls = 0
+	assert.Equal(t, ErrCircuitOpen, process(ctx, cmd))
+	assert.Equal(t, 0, calls)
+
+	// 过了 OpenTimeout 放一个过去，成功了就恢复
+	time.Sleep(time.Millisecond * 150)
+	assert.Equal(t, gobreaker.StateHalfOpen, h.State())
+	nextErr = nil
+	assert.NoError(t, process(ctx, cmd))
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, gobreaker.StateClosed, h.State())
+}
+
+func TestIsFailure(t *testing.T) {
+	assert.False(t, isFailure(nil))
+	assert.False(t, isFailure(redis.Nil))
+	assert.False(t, isFailure(context.Canceled))
+	assert.True(t, isFailure(context.DeadlineExceeded))
+	assert.True(t, isFailure(errors.New("dial tcp: connection refused")))
+}
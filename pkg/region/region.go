@@ -0,0 +1,119 @@
+// Package region 行政区划字典，只到省和地级市两级，代码按 GB/T 2260。
+// 数据库里面只存代码，展示的名字在读的时候再查，字典里面改了名字不需要迁移数据
+package region
+
+import (
+	_ "embed"
+	"errors"
+	"strings"
+)
+
+//go:embed cn.txt
+var cnData string
+
+var (
+	ErrInvalidProvince = errors.New("省份不存在")
+	ErrInvalidCity     = errors.New("城市不存在或者不属于这个省份")
+)
+
+type City struct {
+	Code string `json:"code"`
+	Name string `json:"name"`
+}
+
+type Province struct {
+	Code   string `json:"code"`
+	Name   string `json:"name"`
+	Cities []City `json:"cities"`
+}
+
+// Dictionary 初始化之后就是只读的，可以并发使用
+type Dictionary struct {
+	provinces []Province
+	// 省和市的代码到名字
+	names map[string]string
+	// 市的代码到省的代码
+	parents       map[string]string
+	allowOverseas bool
+}
+
+type Option func(d *Dictionary)
+
+// WithOverseas 允许海外用户不选省市，直接填一段文字
+func WithOverseas() Option {
+	return func(d *Dictionary) {
+		d.allowOverseas = true
+	}
+}
+
+// NewDictionary 用内置的数据
+func NewDictionary(opts ...Option) *Dictionary {
+	d := parse(cnData)
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d
+}
+
+// parse 每一行是 代码 名字，xx0000 是省，其它的是它前两位对应的那个省下面的市。
+// 省必须出现在它的市前面
+func parse(data string) *Dictionary {
+	d := &Dictionary{
+		names:   make(map[string]string),
+		parents: make(map[string]string),
+	}
+	// 省的代码到它在 provinces 里面的下标
+	idx := make(map[string]int)
+	for _, line := range strings.Split(data, "\n") {
+		code, name, ok := strings.Cut(strings.TrimSpace(line), " ")
+		if !ok {
+			continue
+		}
+		d.names[code] = name
+		if strings.HasSuffix(code, "0000") {
+			idx[code] = len(d.provinces)
+			d.provinces = append(d.provinces, Province{Code: code, Name: name, Cities: []City{}})
+			continue
+		}
+		parent := code[:2] + "0000"
+		i, ok := idx[parent]
+		if !ok {
+			panic("region: 市 " + code + " 前面没有对应的省")
+		}
+		d.parents[code] = parent
+		d.provinces[i].Cities = append(d.provinces[i].Cities, City{Code: code, Name: name})
+	}
+	return d
+}
+
+// Provinces 所有的省和下面的市，给前端的选择器用。不要修改返回值
+func (d *Dictionary) Provinces() []Province {
+	return d.provinces
+}
+
+// Name 省或者市的名字，代码不存在就返回空字符串
+func (d *Dictionary) Name(code string) string {
+	return d.names[code]
+}
+
+func (d *Dictionary) AllowOverseas() bool {
+	return d.allowOverseas
+}
+
+// Validate 两个都不填是合法的，代表清空。可以只选省不选市，
+// 但是选了市就一定要选省，并且市要属于这个省
+func (d *Dictionary) Validate(province, city string) error {
+	if province == "" {
+		if city != "" {
+			return ErrInvalidProvince
+		}
+		return nil
+	}
+	if _, ok := d.names[province]; !ok || !strings.HasSuffix(province, "0000") {
+		return ErrInvalidProvince
+	}
+	if city != "" && d.parents[city] != province {
+		return ErrInvalidCity
+	}
+	return nil
+}
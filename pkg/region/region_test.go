@@ -0,0 +1,118 @@
+package region
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestDictionary_Validate(t *testing.T) {
+	testCases := []struct {
+		name     string
+		province string
+		city     string
+
+		wantErr error
+	}{
+		{
+			name:     "省和市都对",
+			province: "440000",
+			city:     "440300",
+		},
+		{
+			name:     "直辖市",
+			province: "110000",
+			city:     "110100",
+		},
+		{
+			name:     "省直辖的县级市",
+			province: "420000",
+			city:     "429004",
+		},
+		{
+			name:     "只选省",
+			province: "440000",
+		},
+		{
+			name: "都不填",
+		},
+		{
+			name:     "省不存在",
+			province: "990000",
+			wantErr:  ErrInvalidProvince,
+		},
+		{
+			name:     "拿市当省",
+			province: "440300",
+			wantErr:  ErrInvalidProvince,
+		},
+		{
+			name:    "只选市",
+			city:    "440300",
+			wantErr: ErrInvalidProvince,
+		},
+		{
+			name:     "市不属于这个省",
+			province: "330000",
+			city:     "440300",
+			wantErr:  ErrInvalidCity,
+		},
+		{
+			name:     "市不存在",
+			province: "440000",
+			city:     "449900",
+			wantErr:  ErrInvalidCity,
+		},
+		{
+			name:     "拿省当市",
+			province: "440000",
+			city:     "440000",
+			wantErr:  ErrInvalidCity,
+		},
+		{
+			name:     "没有下级的地区",
+			province: "810000",
+		},
+	}
+
+	d := NewDictionary()
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.wantErr, d.Validate(tc.province, tc.city))
+		})
+	}
+}
+
+func TestDictionary_Name(t *testing.T) {
+	d := NewDictionary()
+	assert.Equal(t, "广东省", d.Name("440000"))
+	assert.Equal(t, "深圳市", d.Name("440300"))
+	assert.Equal(t, "", d.Name("990000"))
+	assert.Equal(t, "", d.Name(""))
+}
+
+// 内置的数据本身要是对的
+func TestDictionary_Data(t *testing.T) {
+	d := NewDictionary()
+	provinces := d.Provinces()
+	// 34 个省级行政区
+	assert.Len(t, provinces, 34)
+	seen := make(map[string]struct{})
+	for _, p := range provinces {
+		assert.Len(t, p.Code, 6)
+		assert.NotEmpty(t, p.Name)
+		for _, c := range p.Cities {
+			assert.Len(t, c.Code, 6)
+			assert.NotEmpty(t, c.Name)
+			assert.True(t, strings.HasPrefix(c.Code, p.Code[:2]), c.Code)
+			_, ok := seen[c.Code]
+			assert.False(t, ok, "重复的代码 "+c.Code)
+			seen[c.Code] = struct{}{}
+		}
+	}
+}
+
+func TestWithOverseas(t *testing.T) {
+	assert.False(t, NewDictionary().AllowOverseas())
+	assert.True(t, NewDictionary(WithOverseas()).AllowOverseas())
+}
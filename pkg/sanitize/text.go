@@ -0,0 +1,183 @@
+// Package sanitize 清洗用户输入的纯文本，比如昵称、个人简介。
+// 这些内容后面会被展示在各种地方，所以存之前就把 HTML 之类的东西去掉
+package sanitize
+
+import (
+	"golang.org/x/text/unicode/norm"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// 不直接展示，但是会改变文字方向或者看不见的字符。
+// U+200D（零宽连接符）不在这里，emoji 组合要用到它
+var invisibles = map[rune]struct{}{
+	'\u200b': {}, // 零宽空格
+	'\u200e': {}, // LRM
+	'\u200f': {}, // RLM
+	'\u202a': {}, // LRE
+	'\u202b': {}, // RLE
+	'\u202c': {}, // PDF
+	'\u202d': {}, // LRO
+	'\u202e': {}, // RLO
+	'\u2066': {}, // LRI
+	'\u2067': {}, // RLI
+	'\u2068': {}, // FSI
+	'\u2069': {}, // PDI
+	'\ufeff': {}, // BOM
+}
+
+// Text 清洗纯文本：
+//  1. Unicode 规范化成 NFC
+//  2. 去掉 HTML 标签和注释，<script> 和 <style> 连里面的内容一起去掉，落单的尖括号也去掉
+//  3. 去掉控制文字方向的字符和零宽字符
+//  4. 换行、制表符之类的控制字符和连续的空白合并成一个空格，去掉首尾空白
+//  5. 最多保留 maxRunes 个字符，maxRunes <= 0 就是不限制
+//
+// 截断是在规范化之后做的，所以不会因为组合字符绕过长度限制
+func Text(s string, maxRunes int) string {
+	s = norm.NFC.String(s)
+	s = stripTags(s)
+	s = cleanRunes(s)
+	return truncate(s, maxRunes)
+}
+
+func cleanRunes(s string) string {
+	var sb strings.Builder
+	sb.Grow(len(s))
+	// 上一个写进去的是不是空白，开头当成空白，这样就不会有前导空格
+	lastSpace := true
+	for _, r := range s {
+		if r == utf8.RuneError {
+			continue
+		}
+		if _, ok := invisibles[r]; ok {
+			continue
+		}
+		if unicode.IsControl(r) || unicode.IsSpace(r) {
+			if !lastSpace {
+				sb.WriteByte(' ')
+				lastSpace = true
+			}
+			continue
+		}
+		sb.WriteRune(r)
+		lastSpace = false
+	}
+	return strings.TrimRight(sb.String(), " ")
+}
+
+func truncate(s string, maxRunes int) string {
+	if maxRunes <= 0 || utf8.RuneCountInString(s) <= maxRunes {
+		return s
+	}
+	runes := []rune(s)[:maxRunes]
+	// 别在 emoji 组合的中间截断，留一个孤零零的连接符
+	for len(runes) > 0 && runes[len(runes)-1] == '\u200d' {
+		runes = runes[:len(runes)-1]
+	}
+	return strings.TrimRight(string(runes), " ")
+}
+
+// stripTags 去掉 HTML 标签。这里不需要完整地解析 HTML，
+// 只要保证结果里面不会有 < 和 >，浏览器就不可能把它当成标签
+func stripTags(s string) string {
+	var sb strings.Builder
+	sb.Grow(len(s))
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c == '>' {
+			i++
+			continue
+		}
+		if c != '<' {
+			sb.WriteByte(c)
+			i++
+			continue
+		}
+		if !isTagStart(s, i) {
+			// 落单的 <，比如 1 < 2
+			i++
+			continue
+		}
+		if strings.HasPrefix(s[i:], "<!--") {
+			// 注释里面可能还有标签，直接找注释的结尾
+			end := strings.Index(s[i+4:], "-->")
+			if end < 0 {
+				break
+			}
+			i += 4 + end + 3
+			continue
+		}
+		end := tagEnd(s, i)
+		if end < 0 {
+			// 标签没有闭合，后面的都不要了
+			break
+		}
+		if name := tagName(s[i:end]); name == "script" || name == "style" {
+			// 连里面的内容一起去掉
+			closing := indexFold(s[end:], "</"+name)
+			if closing < 0 {
+				break
+			}
+			end += closing
+			end = tagEnd(s, end)
+			if end < 0 {
+				break
+			}
+		}
+		i = end
+	}
+	return sb.String()
+}
+
+// tagEnd 返回从 start 开始的标签结束之后的下标，引号里面的 > 不算
+func tagEnd(s string, start int) int {
+	var quote byte
+	for i := start + 1; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '>':
+			return i + 1
+		}
+	}
+	return -1
+}
+
+// tagName 开始标签的名字，小写，结束标签返回空字符串
+func tagName(tag string) string {
+	tag = strings.TrimPrefix(tag, "<")
+	end := strings.IndexFunc(tag, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if end < 0 {
+		end = len(tag)
+	}
+	return strings.ToLower(tag[:end])
+}
+
+// isTagStart < 后面跟着字母、/、! 或者 ? 才有可能被浏览器当成标签
+func isTagStart(s string, i int) bool {
+	if i+1 >= len(s) {
+		return false
+	}
+	c := s[i+1]
+	return c == '/' || c == '!' || c == '?' ||
+		(c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// indexFold 不区分大小写的 strings.Index，substr 只能是 ASCII
+func indexFold(s, substr string) int {
+	for i := 0; i+len(substr) <= len(s); i++ {
+		if strings.EqualFold(s[i:i+len(substr)], substr) {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,235 @@
+package sanitize
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestText(t *testing.T) {
+	testCases := []struct {
+		name     string
+		input    string
+		maxRunes int
+
+		want string
+	}{
+		{
+			name:  "普通文本不变",
+			input: "我是一个程序员，喜欢 Go",
+			want:  "我是一个程序员，喜欢 Go",
+		},
+		{
+			name:  "script 标签连内容一起去掉",
+			input: `你好<script>alert('xss')</script>世界`,
+			want:  "你好世界",
+		},
+		{
+			name:  "script 标签大小写混着写",
+			input: `你好<ScRiPt type="text/javascript">alert(1)</sCrIpT >世界`,
+			want:  "你好世界",
+		},
+		{
+			name:  "script 没有闭合",
+			input: `你好<script>alert(1)`,
+			want:  "你好",
+		},
+		{
+			name:  "style 标签",
+			input: `<style>body{display:none}</style>简介`,
+			want:  "简介",
+		},
+		{
+			name:  "事件处理器",
+			input: `<img src=x onerror="alert(1)">头像`,
+			want:  "头像",
+		},
+		{
+			name:  "属性里面有 >",
+			input: `<a title="a>b" onclick='alert(1)'>点我</a>`,
+			want:  "点我",
+		},
+		{
+			name:  "svg onload",
+			input: `<svg/onload=alert(1)>`,
+			want:  "",
+		},
+		{
+			name:  "data URI",
+			input: `<a href="data:text/html;base64,PHNjcmlwdD5hbGVydCgxKTwvc2NyaXB0Pg==">链接</a>`,
+			want:  "链接",
+		},
+		{
+			name:  "data URI 里面带标签",
+			input: `data:text/html,<script>alert(1)</script>`,
+			want:  "data:text/html,",
+		},
+		{
+			name:  "javascript 链接",
+			input: `<a href="javascript:alert(1)">链接</a>`,
+			want:  "链接",
+		},
+		{
+			name:  "iframe",
+			input: `<iframe src="https://evil.com"></iframe>正常`,
+			want:  "正常",
+		},
+		{
+			name:  "注释",
+			input: `前<!-- <script>alert(1)</script> -->后`,
+			want:  "前后",
+		},
+		{
+			name:  "注释没有闭合",
+			input: `前<!-- <b>`,
+			want:  "前",
+		},
+		{
+			name:  "嵌套拼出来的标签",
+			input: `<<b>script>alert(1)<</b>/script>`,
+			want:  "scriptalert(1)/script",
+		},
+		{
+			name:  "标签没有闭合",
+			input: `简介<img src=x onerror=alert(1)`,
+			want:  "简介",
+		},
+		{
+			name:  "落单的尖括号",
+			input: "1 < 2 > 0",
+			want:  "1 2 0",
+		},
+		{
+			name:  "实体编码保持原样",
+			input: "&lt;script&gt;",
+			want:  "&lt;script&gt;",
+		},
+		{
+			name:  "RTL override",
+			input: "user\u202eexe.txt",
+			want:  "userexe.txt",
+		},
+		{
+			name:  "各种方向控制字符",
+			input: "a\u200eb\u200fc\u202ad\u202be\u202cf\u202dg\u2066h\u2067i\u2068j\u2069k",
+			want:  "abcdefghijk",
+		},
+		{
+			name:  "零宽空格和 BOM",
+			input: "\ufeff管\u200b理\u200b员",
+			want:  "管理员",
+		},
+		{
+			name:  "控制字符合并成空格",
+			input: "第一行\r\n\r\n第二行\t\t第三行\x00\x07结束",
+			want:  "第一行 第二行 第三行 结束",
+		},
+		{
+			name:  "连续空格和首尾空格",
+			input: "   a    b   ",
+			want:  "a b",
+		},
+		{
+			name:  "NFC 规范化",
+			input: "cafe\u0301",
+			want:  "caf\u00e9",
+		},
+		{
+			name:  "emoji 组合保留",
+			input: "家庭👨\u200d👩\u200d👧",
+			want:  "家庭👨\u200d👩\u200d👧",
+		},
+		{
+			name:     "截断",
+			input:    "一二三四五六",
+			maxRunes: 4,
+			want:     "一二三四",
+		},
+		{
+			name:     "规范化之后再截断",
+			input:    strings.Repeat("e\u0301", 4),
+			maxRunes: 4,
+			want:     strings.Repeat("\u00e9", 4),
+		},
+		{
+			name:     "不在 emoji 连接符后面截断",
+			input:    "ab👨\u200d👩",
+			maxRunes: 4,
+			want:     "ab👨",
+		},
+		{
+			name:     "截断之后不留空格",
+			input:    "abc def",
+			maxRunes: 4,
+			want:     "abc",
+		},
+		{
+			name:  "非法 UTF-8",
+			input: "a\xffb",
+			want:  "ab",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := Text(tc.input, tc.maxRunes)
+			assert.Equal(t, tc.want, got)
+			// 不管怎么样，结果里面都不能有尖括号
+			assert.False(t, strings.ContainsAny(got, "<>"))
+			if tc.maxRunes > 0 {
+				assert.LessOrEqual(t, utf8.RuneCountInString(got), tc.maxRunes)
+			}
+		})
+	}
+}
+
+// 常见的 XSS payload，大部分来自 OWASP 的 XSS Filter Evasion Cheat Sheet。
+// 清洗之后不能再有任何标签，也就是不能有尖括号
+func TestText_XSSPayloads(t *testing.T) {
+	payloads := []string{
+		`<script>alert(1)</script>`,
+		`<SCRIPT SRC=https://evil.com/xss.js></SCRIPT>`,
+		`<IMG SRC="javascript:alert('XSS');">`,
+		`<IMG SRC=javascript:alert('XSS')>`,
+		`<IMG SRC=JaVaScRiPt:alert('XSS')>`,
+		"<IMG SRC=`javascript:alert(\"RSnake says, 'XSS'\")`>",
+		`<a onmouseover="alert(document.cookie)">xxs link</a>`,
+		`<IMG """><SCRIPT>alert("XSS")</SCRIPT>"\>`,
+		`<IMG SRC=/ onerror="alert(String.fromCharCode(88,83,83))"></img>`,
+		`<img src=x onerror="&#0000106&#0000097&#0000118&#0000097&#0000115&#0000099&#0000114&#0000105&#0000112&#0000116&#0000058&#0000097&#0000108&#0000101&#0000114&#0000116&#0000040&#0000039&#0000088&#0000083&#0000083&#0000039&#0000041">`,
+		"<IMG SRC=\"jav\tascript:alert('XSS');\">",
+		"<IMG SRC=\"jav\x00ascript:alert('XSS');\">",
+		`<SCRIPT/XSS SRC="http://xss.rocks/xss.js"></SCRIPT>`,
+		`<BODY onload!#$%&()*~+-_.,:;?@[/|\]^` + "`" + `=alert("XSS")>`,
+		`<<SCRIPT>alert("XSS");//\<</SCRIPT>`,
+		`<SCRIPT SRC=http://xss.rocks/xss.js?< B >`,
+		`<IMG SRC="`,
+		`<iframe src=http://xss.rocks/scriptlet.html <`,
+		`</TITLE><SCRIPT>alert("XSS");</SCRIPT>`,
+		`<INPUT TYPE="IMAGE" SRC="javascript:alert('XSS');">`,
+		`<svg/onload=alert('XSS')>`,
+		`<svg><script>alert(1)</script></svg>`,
+		`<math><mtext><table><mglyph><style><img src=x onerror=alert(1)></style>`,
+		`<details open ontoggle=alert(1)>`,
+		`<LINK REL="stylesheet" HREF="javascript:alert('XSS');">`,
+		`<META HTTP-EQUIV="refresh" CONTENT="0;url=javascript:alert('XSS');">`,
+		`<DIV STYLE="background-image: url(javascript:alert('XSS'))">`,
+		`<!--[if gte IE 4]><SCRIPT>alert('XSS');</SCRIPT><![endif]-->`,
+		`<OBJECT TYPE="text/x-scriptlet" DATA="http://xss.rocks/scriptlet.html"></OBJECT>`,
+		`<EMBED SRC="data:image/svg+xml;base64,PHN2ZyB4bWxuczpzdmc9Imh0dH A6Ly93d3cudzMub3JnLzIwMDAvc3ZnIiB4bWxucz0iaHR0cDovL3d3dy53My5vcmcv MjAwMC9zdmciIHhtbG5zOnhsaW5rPSJodHRwOi8vd3d3LnczLm9yZy8xOTk5L3hs aW5rIiB2ZXJzaW9uPSIxLjAiIHg9IjAiIHk9IjAiIHdpZHRoPSIxOTQiIGhlaWdodD0iMjAw IiBpZD0ieHNzIj48c2NyaXB0IHR5cGU9InRleHQvZWNtYXNjcmlwdCI+YWxlcnQoIlh TUyIpOzwvc2NyaXB0Pjwvc3ZnPg==" AllowScriptAccess="always"></EMBED>`,
+		// 全角的尖括号浏览器不会当成标签
+		"＜script＞alert(1)＜/script＞",
+		"<scr\u200bipt>alert(1)</scr\u200bipt>",
+		`"><script>alert(1)</script>`,
+		`'><img src=x onerror=alert(1)>`,
+	}
+	for _, payload := range payloads {
+		t.Run(payload, func(t *testing.T) {
+			got := Text(payload, 0)
+			assert.False(t, strings.ContainsAny(got, "<>"), got)
+			// 脚本标签的内容要一起去掉
+			assert.NotContains(t, strings.ToLower(got), "<script")
+		})
+	}
+}
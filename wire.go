@@ -20,18 +20,52 @@ func InitWebServer() *gin.Engine {
 
 		// 初始化 DAO
 		dao.NewUserDAO,
+		dao.NewAPIKeyDAO,
+		dao.NewBackupCodeDAO,
+		dao.NewRememberMeDAO,
+		dao.NewLoginEventDAO,
 
 		cache.NewUserCache,
-		cache.NewCodeCache,
+		cache.NewAnonymousSessionCache,
+		cache.NewSessionCache,
+		cache.NewJWTNonceCache,
+		ioc.InitRedisCodeCache,
+		ioc.InitCodeCache,
+		wire.Bind(new(web.CodeCacheConfig), new(*cache.RedisCodeCache)),
+		wire.Bind(new(web.RedisPoolStats), new(*cache.RedisCodeCache)),
 
-		repository.NewUserRepository,
+		ioc.InitUserRepository,
 		repository.NewCodeRepository,
+		repository.NewAPIKeyRepository,
+		repository.NewBackupCodeRepository,
+		repository.NewRememberMeRepository,
+		repository.NewLoginEventRepository,
 
-		service.NewUserService,
-		service.NewCodeService,
+		ioc.InitUserService,
+		ioc.InitEmailVerificationService,
+		ioc.InitCodeService,
+		service.NewAPIKeyService,
+		service.NewBackupCodeService,
+		ioc.InitRememberMeService,
+		service.NewSessionService,
+		service.NewJWTNonceService,
 		// 直接基于内存实现
 		ioc.InitSMSService,
-		web.NewUserHandler,
+		ioc.InitFormTokenService,
+		ioc.InitIDVerifier,
+		ioc.InitSensitiveFilter,
+		ioc.InitUserExportService,
+		service.NewUserStatsService,
+		wire.Bind(new(service.UserStatsRepository), new(*repository.UserRepository)),
+		ioc.InitRegionDictionary,
+		ioc.InitUserHandler,
+		web.NewAPIKeyHandler,
+		web.NewBackupCodeHandler,
+		web.NewUserExportHandler,
+		wire.Bind(new(web.Reauthenticator), new(*web.UserHandler)),
+		web.NewAdminHandler,
+		web.NewDictionaryHandler,
+		web.NewVersionHandler,
 		// 你中间件呢？
 		// 你注册路由呢？
 		// 你这个地方没有用到前面的任何东西
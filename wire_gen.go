@@ -20,17 +20,47 @@ import (
 
 func InitWebServer() *gin.Engine {
 	cmdable := ioc.InitRedis()
-	v := ioc.InitMiddlewares(cmdable)
 	db := ioc.InitDB()
 	userDAO := dao.NewUserDAO(db)
 	userCache := cache.NewUserCache(cmdable)
-	userRepository := repository.NewUserRepository(userDAO, userCache)
-	userService := service.NewUserService(userRepository)
-	codeCache := cache.NewCodeCache(cmdable)
+	userRepository := ioc.InitUserRepository(userDAO, userCache, cmdable)
+	idVerifier := ioc.InitIDVerifier()
+	filter := ioc.InitSensitiveFilter()
+	anonymousSessionCache := cache.NewAnonymousSessionCache(cmdable)
+	emailVerificationService := ioc.InitEmailVerificationService(userRepository, cmdable)
+	loginEventDAO := dao.NewLoginEventDAO(db)
+	loginEventRepository := repository.NewLoginEventRepository(loginEventDAO)
+	userService := ioc.InitUserService(userRepository, idVerifier, filter, anonymousSessionCache, cmdable, emailVerificationService, loginEventRepository)
+	apiKeyDAO := dao.NewAPIKeyDAO(db)
+	apiKeyRepository := repository.NewAPIKeyRepository(apiKeyDAO)
+	apiKeyService := service.NewAPIKeyService(apiKeyRepository)
+	rememberMeDAO := dao.NewRememberMeDAO(db)
+	rememberMeRepository := repository.NewRememberMeRepository(rememberMeDAO)
+	rememberMeService := ioc.InitRememberMeService(rememberMeRepository)
+	sessionCache := cache.NewSessionCache(cmdable)
+	sessionService := service.NewSessionService(sessionCache)
+	jwtNonceCache := cache.NewJWTNonceCache(cmdable)
+	jwtNonceService := service.NewJWTNonceService(jwtNonceCache)
+	v := ioc.InitMiddlewares(cmdable, userService, apiKeyService, rememberMeService, sessionService, jwtNonceService)
+	redisCodeCache := ioc.InitRedisCodeCache(cmdable)
+	codeCache := ioc.InitCodeCache(redisCodeCache, cmdable)
 	codeRepository := repository.NewCodeRepository(codeCache)
-	smsService := ioc.InitSMSService()
-	codeService := service.NewCodeService(codeRepository, smsService)
-	userHandler := web.NewUserHandler(userService, codeService)
-	engine := ioc.InitWebServer(v, userHandler)
+	smsService := ioc.InitSMSService(cmdable)
+	codeService := ioc.InitCodeService(codeRepository, smsService, cmdable)
+	formTokenService := ioc.InitFormTokenService(cmdable)
+	dictionary := ioc.InitRegionDictionary()
+	userHandler := ioc.InitUserHandler(userService, codeService, formTokenService, rememberMeService, sessionService, jwtNonceService, dictionary)
+	apiKeyHandler := web.NewAPIKeyHandler(apiKeyService)
+	backupCodeDAO := dao.NewBackupCodeDAO(db)
+	backupCodeRepository := repository.NewBackupCodeRepository(backupCodeDAO)
+	backupCodeService := service.NewBackupCodeService(backupCodeRepository)
+	backupCodeHandler := web.NewBackupCodeHandler(backupCodeService, userHandler)
+	userExportService := ioc.InitUserExportService(userService, cmdable)
+	userExportHandler := web.NewUserExportHandler(userExportService, userHandler)
+	userStatsService := service.NewUserStatsService(userRepository)
+	adminHandler := web.NewAdminHandler(userService, filter, redisCodeCache, redisCodeCache, userStatsService)
+	dictionaryHandler := web.NewDictionaryHandler(dictionary)
+	versionHandler := web.NewVersionHandler()
+	engine := ioc.InitWebServer(v, userHandler, apiKeyHandler, backupCodeHandler, userExportHandler, adminHandler, dictionaryHandler, versionHandler)
 	return engine
 }